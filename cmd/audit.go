@@ -0,0 +1,182 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/audit"
+	"github.com/bdwyertech/go-berkshelf/pkg/owners"
+	"github.com/bdwyertech/go-berkshelf/pkg/ui"
+)
+
+func init() {
+	rootCmd.AddCommand(auditCmd)
+
+	auditCmd.Flags().StringP("format", "f", "table", "Output format (table, json)")
+	auditCmd.Flags().Int("max-age", 3, "Flag cookbooks with no release in this many years (0 disables)")
+	auditCmd.Flags().String("advisories", "", "Gem advisory feed (file or URL) to check cookbook gem dependencies against")
+	auditCmd.Flags().String("fail-on", "high", "Lowest severity that fails the audit (low, medium, high, critical)")
+	auditCmd.Flags().String("owner", "", "Only report cookbooks owned by this team")
+
+	auditCmd.ValidArgsFunction = completeCookbookNames
+	registerFormatCompletion(auditCmd, "table", "json")
+}
+
+var auditCmd = &cobra.Command{
+	Use:   "audit [COOKBOOK...]",
+	Short: "Check locked cookbooks for deprecations and known problems",
+	Long: `Check the cookbooks in the lock file for problems:
+
+  deprecated    the cookbook is deprecated on its Supermarket (medium)
+  yanked        the locked version, or the whole cookbook, was removed (high)
+  unmaintained  no release in --max-age years (low)
+  advisory      a gem dependency allows a version with a security advisory
+                in the --advisories feed (the advisory's severity)
+
+The advisory feed is a JSON document {"advisories": [...]} whose entries
+follow the Ruby Advisory Database: id, gem, title, url, severity,
+patched_versions and unaffected_versions. Cookbooks are only downloaded to
+read their gem dependencies when a feed is given.
+
+The exit code reports the worst finding at or above --fail-on: 2 for low,
+3 for medium, 4 for high and 5 for critical. Findings below --fail-on are
+listed but exit 0.
+
+Findings carry the owners of their cookbook when owners are configured (see
+'berks owners'); --owner limits the audit to one team's cookbooks.
+
+Examples:
+  berks audit                                  # Audit all locked cookbooks
+  berks audit nginx                            # Audit one cookbook
+  berks audit --fail-on medium                 # Also fail on deprecations
+  berks audit --owner @web-team                # Audit the web team's cookbooks
+  berks audit --advisories advisories.json     # Check gem dependencies
+  berks audit --max-age 0 --format json        # Skip the age check, print JSON`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format := strings.ToLower(viper.GetString("format"))
+		if err := checkFormat(format, "table", "json"); err != nil {
+			return err
+		}
+		failOn, err := audit.ParseSeverity(viper.GetString("fail-on"))
+		if err != nil {
+			return fmt.Errorf("invalid --fail-on: %w", err)
+		}
+
+		result := newResult("audit", format)
+		findings, cookbookOwners, err := runAudit(cmd, args, result)
+		if err == nil && result == nil {
+			err = outputAuditTable(findings, cookbookOwners)
+		}
+		if err == nil {
+			err = auditOutcome(findings, failOn)
+		}
+		if err != nil {
+			cmd.SilenceUsage = true
+		}
+		return result.Write(os.Stdout, err)
+	},
+}
+
+func runAudit(cmd *cobra.Command, args []string, result *Result) ([]audit.Finding, *owners.Owners, error) {
+	bf, err := LoadBerksfile()
+	if err != nil {
+		return nil, nil, err
+	}
+	lockFile, manager, err := LoadLockFile()
+	if err != nil {
+		return nil, nil, err
+	}
+	if !manager.Exists() {
+		return nil, nil, fmt.Errorf("no lock file found. Run 'berks install' first")
+	}
+	cookbookOwners, err := loadOwners()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sourceManager, err := CreateSourceManager(bf)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Audit only the team's cookbooks
+	if owner := viper.GetString("owner"); owner != "" {
+		var owned []string
+		for name := range lockFile.ListCookbooks() {
+			if (len(args) == 0 || slices.Contains(args, name)) && ownedBy(cookbookOwners, name, owner) {
+				owned = append(owned, name)
+			}
+		}
+		if len(owned) == 0 {
+			return nil, cookbookOwners, nil
+		}
+		args = owned
+	}
+
+	options := audit.Options{MaxAge: time.Duration(viper.GetInt("max-age")) * 365 * 24 * time.Hour}
+	if location := viper.GetString("advisories"); location != "" {
+		options.Advisories, err = audit.LoadAdvisories(cmd.Context(), location)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	start := time.Now()
+	findings, err := audit.New(lockFile, sourceManager, options).Audit(cmd.Context(), args)
+	result.Phase("audit", start)
+	if err != nil {
+		return nil, nil, fmt.Errorf("audit failed: %w", err)
+	}
+	for i := range findings {
+		findings[i].Owners = cookbookOwners.For(findings[i].Cookbook)
+		result.AddFinding(findings[i])
+	}
+	return findings, cookbookOwners, nil
+}
+
+// auditOutcome returns an exitError for the worst finding at or above failOn
+func auditOutcome(findings []audit.Finding, failOn audit.Severity) error {
+	var worst audit.Severity
+	failing := 0
+	for _, finding := range findings {
+		if finding.Severity >= failOn {
+			failing++
+			worst = max(worst, finding.Severity)
+		}
+	}
+	if failing == 0 {
+		return nil
+	}
+	return &exitError{
+		code: worst.ExitCode(),
+		err:  fmt.Errorf("%d finding(s) at or above %s severity", failing, failOn),
+	}
+}
+
+// outputAuditTable prints the findings, most severe first
+func outputAuditTable(findings []audit.Finding, cookbookOwners *owners.Owners) error {
+	if len(findings) == 0 {
+		fmt.Println("No problems found.")
+		return nil
+	}
+
+	// Keep the audit's most-severe-first order
+	opts := tableOptions()
+	opts.Unsorted = true
+	table := ui.NewTable(opts, withOwnersHeader(cookbookOwners, "SEVERITY", "COOKBOOK", "VERSION", "ISSUE", "DETAIL")...)
+	for _, finding := range findings {
+		detail := finding.Message
+		if finding.Advisory != "" {
+			detail = finding.Advisory + ": " + detail
+		}
+		table.Append(withOwners(cookbookOwners, finding.Cookbook,
+			strings.ToUpper(finding.Severity.String()), finding.Cookbook, finding.Version, finding.Kind, detail)...)
+	}
+	return table.Render(os.Stdout)
+}
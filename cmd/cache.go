@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/bdwyertech/go-berkshelf/internal/config"
+	"github.com/bdwyertech/go-berkshelf/pkg/cache"
+	"github.com/bdwyertech/go-berkshelf/pkg/source"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	rootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cacheClearCmd)
+	cacheCmd.AddCommand(cacheInvalidateCmd)
+	cacheCmd.AddCommand(cachePathCmd)
+
+	cacheInvalidateCmd.Flags().String("source", "", "Remove entries fetched from this source URL")
+	cacheInvalidateCmd.Flags().String("cookbook", "", "Remove entries for this cookbook")
+	cobra.CheckErr(cacheInvalidateCmd.RegisterFlagCompletionFunc("cookbook", completeCookbookNames))
+}
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the local cookbook cache",
+}
+
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Remove all cached cookbooks, git clones and resolutions",
+	Long: `Remove all cached cookbooks, git clones and resolutions.
+
+You are asked to confirm before anything is removed. In CI or without a
+terminal, pass --yes to proceed.
+
+Examples:
+  berks cache clear        # Prompt, then clear the cache
+  berks cache clear --yes  # Clear without prompting`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dirs, err := cacheDirs()
+		if err != nil {
+			return err
+		}
+
+		for _, dir := range dirs {
+			fmt.Fprintf(os.Stderr, "  %s\n", dir)
+		}
+		ok, err := newPrompter().Confirm("Remove the cache directories listed above?")
+		if err != nil {
+			return err
+		}
+		if !ok {
+			fmt.Println("Aborted.")
+			return nil
+		}
+
+		for _, dir := range dirs {
+			log.Debugf("Removing %s", dir)
+			if err := os.RemoveAll(dir); err != nil {
+				return fmt.Errorf("failed to remove %s: %w", dir, err)
+			}
+		}
+
+		fmt.Println("Cache cleared.")
+		return nil
+	},
+}
+
+var cacheInvalidateCmd = &cobra.Command{
+	Use:   "invalidate",
+	Short: "Remove cached entries from one source or for one cookbook",
+	Long: `Remove only the cache entries that came from a source or belong to a
+cookbook: cached version lists, source capabilities, git clones, and any
+cached resolution that locks a matching cookbook. Use this when a private
+Supermarket republished artifacts and clearing the whole cache is too much.
+
+With both --source and --cookbook, only entries matching both are removed.
+
+Examples:
+  berks cache invalidate --source https://supermarket.example.com
+  berks cache invalidate --cookbook nginx
+  berks cache invalidate --source https://supermarket.example.com --cookbook nginx`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sel := cache.Selector{
+			SourceURL: viper.GetString("source"),
+			Cookbook:  viper.GetString("cookbook"),
+		}
+		if sel.SourceURL == "" && sel.Cookbook == "" {
+			return fmt.Errorf("--source or --cookbook is required; use 'berks cache clear' to remove everything")
+		}
+		cmd.SilenceUsage = true
+
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		removed := 0
+		for _, dir := range []string{cfg.GetCachePathResolved(), resolutionCacheDir()} {
+			if _, err := os.Stat(dir); os.IsNotExist(err) {
+				continue
+			}
+			n, err := cache.Invalidate(dir, sel)
+			if err != nil {
+				return fmt.Errorf("failed to invalidate %s: %w", dir, err)
+			}
+			log.Debugf("Removed %d entries from %s", n, dir)
+			removed += n
+		}
+
+		n, err := source.RemoveGitClones(source.GitCacheDir(), sel.SourceURL, sel.Cookbook)
+		if err != nil {
+			return fmt.Errorf("failed to remove git clones: %w", err)
+		}
+		removed += n
+
+		fmt.Printf("Removed %d cache entries.\n", removed)
+		return nil
+	},
+}
+
+var cachePathCmd = &cobra.Command{
+	Use:   "path [cookbooks|resolutions|git|downloads]",
+	Short: "Print the location of a cache",
+	Long: `Print the absolute path of a cache after the config is applied, so
+scripts need not work it out themselves:
+
+  cookbooks    downloaded cookbooks (cache_path, the default)
+  resolutions  cached resolutions and version lists
+  git          git clones
+  downloads    tarball downloads, kept to resume interrupted ones
+
+Examples:
+  berks cache path                 # Print the cookbook cache
+  du -sh "$(berks cache path git)" # Size of the git clones`,
+	Args:      cobra.MatchAll(cobra.MaximumNArgs(1), cobra.OnlyValidArgs),
+	ValidArgs: []string{"cookbooks", "resolutions", "git", "downloads"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		kind := "cookbooks"
+		if len(args) == 1 {
+			kind = args[0]
+		}
+
+		var dir string
+		switch kind {
+		case "cookbooks":
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+			dir = cfg.GetCachePathResolved()
+		case "resolutions":
+			dir = resolutionCacheDir()
+		case "git":
+			dir = source.GitCacheDir()
+		case "downloads":
+			dir = source.DefaultDownloadDir()
+		}
+		return printPath(dir)
+	},
+}
+
+// printPath prints path made absolute, for scripts to use
+func printPath(path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	fmt.Println(abs)
+	return nil
+}
+
+// cacheDirs returns every directory berks caches data in
+func cacheDirs() ([]string, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	return []string{
+		cfg.GetCachePathResolved(),
+		resolutionCacheDir(),
+		source.GitCacheDir(),
+	}, nil
+}
+
+// resolutionCacheDir returns the directory cached resolutions are stored in
+func resolutionCacheDir() string {
+	return filepath.Join(config.GetConfigDir(), "resolutions")
+}
@@ -1,14 +1,30 @@
 package cmd
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
+	log "github.com/sirupsen/logrus"
+
+	"github.com/bdwyertech/go-berkshelf/internal/config"
 	"github.com/bdwyertech/go-berkshelf/pkg/berksfile"
+	"github.com/bdwyertech/go-berkshelf/pkg/cache"
+	"github.com/bdwyertech/go-berkshelf/pkg/digest"
+	berrors "github.com/bdwyertech/go-berkshelf/pkg/errors"
+	"github.com/bdwyertech/go-berkshelf/pkg/events"
 	"github.com/bdwyertech/go-berkshelf/pkg/lockfile"
+	"github.com/bdwyertech/go-berkshelf/pkg/owners"
 	"github.com/bdwyertech/go-berkshelf/pkg/source"
+	"github.com/bdwyertech/go-berkshelf/pkg/ui"
+
+	"github.com/spf13/viper"
+	"golang.org/x/term"
 )
 
 // CommonFlags holds flags that are used across multiple commands
@@ -17,13 +33,11 @@ type CommonFlags struct {
 	Except []string
 }
 
-// LoadBerksfile loads and parses the Berksfile from the current directory
+// LoadBerksfile loads and parses the Berksfile given by --berksfile
 func LoadBerksfile() (*berksfile.Berksfile, error) {
-	berksfilePath := filepath.Join(".", "Berksfile")
-
 	// Check if Berksfile exists
 	if _, err := os.Stat(berksfilePath); os.IsNotExist(err) {
-		return nil, fmt.Errorf("no Berksfile found in current directory. Run 'berks init' to create one")
+		return nil, fmt.Errorf("no Berksfile found at %s. Run 'berks init' to create one", berksfilePath)
 	}
 
 	// Parse Berksfile
@@ -37,7 +51,7 @@ func LoadBerksfile() (*berksfile.Berksfile, error) {
 
 // CreateSourceManager creates a source manager from a parsed Berksfile
 func CreateSourceManager(bf *berksfile.Berksfile) (*source.Manager, error) {
-	factory := source.NewFactory()
+	factory := newSourceFactory()
 	manager, err := factory.CreateFromBerksfile(bf)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create source manager: %w", err)
@@ -45,9 +59,15 @@ func CreateSourceManager(bf *berksfile.Berksfile) (*source.Manager, error) {
 	return manager, nil
 }
 
-// LoadLockFile loads the lock file from the current directory
+// projectDir returns the directory of the Berksfile, which holds its lock
+// files and the cookbook its metadata directive adds
+func projectDir() string {
+	return filepath.Dir(berksfilePath)
+}
+
+// LoadLockFile loads the lock file of the Berksfile
 func LoadLockFile() (*lockfile.LockFile, *lockfile.Manager, error) {
-	manager := lockfile.NewManager(".")
+	manager := newLockManager(projectDir())
 	lockFile, err := manager.Load()
 	if err != nil {
 		return nil, manager, err
@@ -55,6 +75,117 @@ func LoadLockFile() (*lockfile.LockFile, *lockfile.Manager, error) {
 	return lockFile, manager, nil
 }
 
+// lockFileOptions names the lock files of the project in dir as the
+// berkshelf config says, and as --berksfile and --lockfile do for the
+// Berksfile they give. An unreadable config is logged and the default names
+// are used.
+func lockFileOptions(dir string) lockfile.Options {
+	var opts lockfile.Options
+	if cfg, err := config.Load(); err != nil {
+		log.Warnf("Using the default lock file names: %v", err)
+	} else {
+		opts = lockfile.Options{Name: cfg.GetLockfileName(), SkipRuby: !cfg.GetRubyLockfile()}
+	}
+	if sameDir(dir, projectDir()) {
+		opts.Path, opts.Berksfile = lockfilePath, berksfilePath
+	}
+	return opts
+}
+
+// sameDir reports whether two paths name the same directory
+func sameDir(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
+
+// checksumAlgorithm returns the configured checksum algorithm. An unreadable
+// config is logged and the default algorithm is used.
+func checksumAlgorithm() digest.Algorithm {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Warnf("Using the default checksum algorithm: %v", err)
+		return digest.Default
+	}
+	return cfg.GetChecksumAlgorithm()
+}
+
+// newLockManager returns the manager of the lock files in dir. A lock file
+// left under the default name after lockfile_name changed stays in use until
+// it is migrated, so that berks never works from two lock files.
+func newLockManager(dir string) *lockfile.Manager {
+	opts := lockFileOptions(dir)
+	manager := lockfile.NewManagerWithOptions(dir, opts)
+	if legacy := manager.LegacyPath(); legacy != "" && !manager.Exists() {
+		log.Debugf("Using %s until it is renamed to %s", legacy, manager.GetPath())
+		opts.Name = lockfile.DefaultLockFileName
+		return lockfile.NewManagerWithOptions(dir, opts)
+	}
+	return manager
+}
+
+// checkLockFiles offers to clean up the lock files in dir that berks no
+// longer writes: one left under the default name after lockfile_name
+// changed, and Berksfile.lock once ruby_lockfile is off. Unless --force
+// rewrites them anyway, it then warns when Berksfile.lock, which Ruby
+// Berkshelf updates on its own, locks other versions than the lock file.
+func checkLockFiles(dir string) error {
+	manager := lockfile.NewManagerWithOptions(dir, lockFileOptions(dir))
+	prompter := newPrompter()
+	if legacy := manager.LegacyPath(); legacy != "" {
+		question := fmt.Sprintf("Rename the legacy lock file %s to %s?", legacy, manager.GetPath())
+		if manager.Exists() {
+			question = fmt.Sprintf("Remove the legacy lock file %s? %s is the lock file in use.", legacy, manager.GetPath())
+		}
+		if err := confirmLockCleanup(prompter, question, manager.Migrate); err != nil {
+			return err
+		}
+	}
+	if stale := manager.StaleRubyPath(); stale != "" {
+		question := fmt.Sprintf("Remove %s? It is no longer updated with ruby_lockfile off.", stale)
+		if err := confirmLockCleanup(prompter, question, manager.RemoveRuby); err != nil {
+			return err
+		}
+	}
+
+	manager = newLockManager(dir)
+	if viper.GetBool("force") || !manager.Exists() {
+		return nil
+	}
+	lockFile, err := manager.Load()
+	if err != nil {
+		// Reported where the lock file is used
+		return nil
+	}
+	differences, err := manager.RubyDivergence(lockFile)
+	if err != nil {
+		log.Warnf("Cannot compare the lock files: %v", err)
+		return nil
+	}
+	if len(differences) > 0 {
+		log.Warnf("%s and %s lock different versions:", manager.GetRubyPath(), manager.GetPath())
+		for _, difference := range differences {
+			log.Warnf("  %s", difference)
+		}
+		log.Warn("Run 'berks install --force' to rewrite both from the Berksfile")
+	}
+	return nil
+}
+
+// confirmLockCleanup runs cleanup once confirmed. Where no one can answer,
+// the lock files are left as they are.
+func confirmLockCleanup(prompter ui.Prompter, question string, cleanup func() error) error {
+	ok, err := prompter.Confirm(question)
+	if errors.Is(err, ui.ErrNonInteractive) {
+		log.Warnf("%s Pass --yes to confirm.", question)
+		return nil
+	}
+	if err != nil || !ok {
+		return err
+	}
+	return cleanup()
+}
+
 // CheckLockFileStatus checks if the lock file exists and whether it's outdated
 func CheckLockFileStatus(manager *lockfile.Manager, force bool) (shouldProceed bool, err error) {
 	if force {
@@ -64,18 +195,168 @@ func CheckLockFileStatus(manager *lockfile.Manager, force bool) (shouldProceed b
 	outdated, err := manager.IsOutdated()
 	if err != nil {
 		// If we can't check status, proceed with warning
-		fmt.Printf("Warning: failed to check lock file status: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Warning: failed to check lock file status: %v\n", err)
 		return true, nil
 	}
 
 	if !outdated && manager.Exists() {
-		fmt.Println("Berksfile.lock is up to date. Use --force to reinstall.")
+		fmt.Fprintf(os.Stderr, "%s is up to date. Use --force to reinstall.\n", filepath.Base(manager.GetPath()))
 		return false, nil
 	}
 
 	return true, nil
 }
 
+// loadGroupSources returns the group to default source mapping from the berkshelf config.
+// An unreadable config is logged and treated as having no mapping.
+func loadGroupSources() map[string]string {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Warnf("Ignoring group sources: %v", err)
+		return nil
+	}
+	return cfg.GetGroupSources()
+}
+
+// newSourceFactory returns a source factory using the api_keys and rate limit
+// from the berkshelf config. An unreadable config is logged and treated as
+// having no keys or limit. Supermarket sources probe their capabilities and
+// revalidate their API responses, both cached in the resolution cache.
+func newSourceFactory() *source.Factory {
+	factory := source.NewFactory()
+	if capabilities, err := cache.NewCapabilityCache(resolutionCacheDir(), cache.DefaultCapabilityTTL); err == nil {
+		factory.SetCapabilityStore(capabilities)
+	} else {
+		log.Debugf("Capability cache disabled: %v", err)
+	}
+	if responses, err := cache.NewResponseCache(resolutionCacheDir(), cache.DefaultResponseTTL); err == nil {
+		factory.SetResponseStore(responses)
+	} else {
+		log.Debugf("Response cache disabled: %v", err)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Warnf("Ignoring API keys: %v", err)
+		return factory
+	}
+	factory.SetAPIKeys(cfg.GetAPIKeys())
+	factory.SetChefServerCredentials(chefServerCredentials(cfg.ChefConfig), chefServerProfiles(cfg.ChefConfig))
+	factory.SetRateLimit(cfg.GetRateLimit())
+	if cfg.GetSigning().Verifies() {
+		factory.SetArtifactVerifier(trustPolicy(cfg.GetSigning()))
+	}
+	return factory
+}
+
+// chefServerCredentials returns the Chef Server connection of a chef section
+func chefServerCredentials(chef *config.ChefConfig) source.ChefServerCredentials {
+	return source.ChefServerCredentials{
+		URL:        chef.GetOrganizationURL(),
+		ClientName: chef.GetNodeName(),
+		ClientKey:  chef.GetClientKey(),
+	}
+}
+
+// chefServerProfiles returns the Chef Server connection of each configured
+// chef profile
+func chefServerProfiles(chef *config.ChefConfig) map[string]source.ChefServerCredentials {
+	profiles := make(map[string]source.ChefServerCredentials, len(chef.GetProfiles()))
+	for name := range chef.GetProfiles() {
+		selected, _ := chef.ForProfile(name)
+		profiles[name] = chefServerCredentials(selected)
+	}
+	return profiles
+}
+
+// loadOwners returns the cookbook owners from the owners config section and
+// the Berksfile comments
+func loadOwners() (*owners.Owners, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	o, err := owners.Load(berksfilePath, cfg.GetOwners())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read owners: %w", err)
+	}
+	return o, nil
+}
+
+// withVersionCache serves remote version lookups from the resolution cache
+// until min_check_interval has passed since a cookbook was last checked.
+// The sources are returned unchanged if the interval is 0 or the cache cannot be opened.
+func withVersionCache(sources []source.CookbookSource) []source.CookbookSource {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Debugf("Version cache disabled: %v", err)
+		return sources
+	}
+
+	interval := time.Duration(cfg.GetMinCheckInterval()) * time.Second
+	if interval <= 0 {
+		return sources
+	}
+
+	versions, err := cache.NewVersionCache(resolutionCacheDir(), interval)
+	if err != nil {
+		log.Debugf("Version cache disabled: %v", err)
+		return sources
+	}
+	return versions.WrapAll(sources)
+}
+
+// phaseContext limits ctx to the timeout configured for phase, one of
+// berrors.PhaseResolve, PhaseDownload or PhaseUpload. Pass the phase's error
+// through berrors.PhaseError to report which phase timed out.
+func phaseContext(ctx context.Context, phase string) (context.Context, context.CancelFunc) {
+	var seconds int
+	if cfg, err := config.Load(); err != nil {
+		log.Debugf("No %s timeout: %v", phase, err)
+	} else {
+		switch phase {
+		case berrors.PhaseResolve:
+			seconds = cfg.GetResolveTimeout()
+		case berrors.PhaseDownload:
+			seconds = cfg.GetDownloadTimeout()
+		case berrors.PhaseUpload:
+			seconds = cfg.GetUploadTimeout()
+		}
+	}
+	return berrors.WithPhaseTimeout(ctx, phase, time.Duration(seconds)*time.Second)
+}
+
+// newPrompter returns the confirmation prompter honoring --yes and CI detection
+func newPrompter() ui.Prompter {
+	return ui.NewPrompter(viper.GetBool("yes"))
+}
+
+// newTable returns a table sized to the terminal on stdout
+func newTable(headers ...string) *ui.Table {
+	return ui.NewTable(tableOptions(), headers...)
+}
+
+// tableOptions sizes tables to the terminal on stdout. Output that is not a
+// terminal, or --wide, is never truncated.
+func tableOptions() ui.TableOptions {
+	opts := ui.TableOptions{Wide: viper.GetBool("wide")}
+	if fd := int(os.Stdout.Fd()); term.IsTerminal(fd) {
+		if width, _, err := term.GetSize(fd); err == nil {
+			opts.Width = width
+		}
+	}
+	return opts
+}
+
+// newEventHandler returns the progress event handler for an output format.
+// Only ndjson streams events; other formats return a nil handler.
+func newEventHandler(format string) events.Handler {
+	if strings.EqualFold(format, "ndjson") {
+		return events.NewNDJSONWriter(os.Stdout).Handler()
+	}
+	return nil
+}
+
 func outputJSON(cookbooks []CookbookListItem) error {
 	encoder := json.NewEncoder(os.Stdout)
 	encoder.SetIndent("", "  ")
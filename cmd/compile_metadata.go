@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/metadata"
+)
+
+var compileMetadataStdout bool
+
+func init() {
+	rootCmd.AddCommand(compileMetadataCmd)
+
+	compileMetadataCmd.Flags().BoolVar(&compileMetadataStdout, "stdout", false, "Print metadata.json instead of writing it")
+}
+
+var compileMetadataCmd = &cobra.Command{
+	Use:   "compile-metadata [COOKBOOK_DIR...]",
+	Short: "Compile metadata.rb into metadata.json",
+	Long: `Compile a cookbook's metadata.rb into the metadata.json Chef Server expects,
+as 'knife cookbook metadata' does, including chef_version, ohai_version and
+gem requirements. The current directory is compiled if no directory is given.
+
+Only the declarative subset of Ruby used in metadata.rb is evaluated: method
+calls with literal arguments, string interpolation, %w arrays, heredocs,
+File.read/IO.read of files in the cookbook, respond_to? modifiers and .each
+loops. Other statements are skipped with a warning.
+
+Examples:
+  berks compile-metadata                   # Write ./metadata.json
+  berks compile-metadata cookbooks/*       # Compile several cookbooks
+  berks compile-metadata --stdout | jq .   # Print instead of writing`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dirs := args
+		if len(dirs) == 0 {
+			dirs = []string{"."}
+		}
+
+		for _, dir := range dirs {
+			if compileMetadataStdout {
+				if err := printMetadata(dir); err != nil {
+					return err
+				}
+				continue
+			}
+
+			m, err := metadata.Compile(dir)
+			if err != nil {
+				return fmt.Errorf("failed to compile %s: %w", dir, err)
+			}
+			log.Infof("Compiled %s (%s) to %s", m.Name, m.Version, filepath.Join(dir, "metadata.json"))
+		}
+		return nil
+	},
+}
+
+// printMetadata writes the metadata.json for the cookbook in dir to stdout
+func printMetadata(dir string) error {
+	path := filepath.Join(dir, "metadata.rb")
+	m, err := metadata.ParseFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to compile %s: %w", dir, err)
+	}
+	for _, warning := range m.Warnings {
+		log.Warnf("%s: %s", path, warning)
+	}
+	if err := m.Validate(); err != nil {
+		return fmt.Errorf("failed to compile %s: %w", dir, err)
+	}
+
+	data, err := m.JSON()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stdout, string(data))
+	return nil
+}
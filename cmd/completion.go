@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/bdwyertech/go-berkshelf/internal/config"
+)
+
+func init() {
+	rootCmd.AddCommand(completionCmd)
+
+	// Replace cobra's default completion command with our own
+	rootCmd.CompletionOptions.DisableDefaultCmd = true
+}
+
+var completionCmd = &cobra.Command{
+	Use:   "completion [bash|zsh|fish|powershell]",
+	Short: "Generate shell completion scripts",
+	Long: `Generate a shell completion script for berks.
+
+Completions include cookbook names from the lock file and group names from
+the Berksfile in the current directory.
+
+Examples:
+  source <(berks completion bash)                     # Load in the current bash session
+  berks completion zsh > "${fpath[1]}/_berks"         # Install for zsh
+  berks completion fish > ~/.config/fish/completions/berks.fish
+  berks completion powershell | Out-String | Invoke-Expression`,
+	ValidArgs:             []string{"bash", "zsh", "fish", "powershell"},
+	Args:                  cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+	DisableFlagsInUseLine: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		switch args[0] {
+		case "bash":
+			return rootCmd.GenBashCompletionV2(os.Stdout, true)
+		case "zsh":
+			return rootCmd.GenZshCompletion(os.Stdout)
+		case "fish":
+			return rootCmd.GenFishCompletion(os.Stdout, true)
+		case "powershell":
+			return rootCmd.GenPowerShellCompletionWithDesc(os.Stdout)
+		default:
+			return fmt.Errorf("unsupported shell: %s", args[0])
+		}
+	},
+}
+
+// completeCookbookNames completes cookbook names from the lock file, skipping names already given
+func completeCookbookNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	lockFile, _, err := LoadLockFile()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var names []string
+	for name := range lockFile.ListCookbooks() {
+		if strings.HasPrefix(name, toComplete) && !slices.Contains(args, name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeFirstCookbookName completes a single leading cookbook name argument
+func completeFirstCookbookName(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return completeCookbookNames(cmd, args, toComplete)
+}
+
+// completeGroupNames completes group names from the Berksfile
+func completeGroupNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	bf, err := LoadBerksfile()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	groups := bf.GetGroups()
+	sort.Strings(groups)
+
+	return groups, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeChefProfiles completes --chef-profile with the configured chef.profiles
+func completeChefProfiles(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	layers, err := config.LoadLayers()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return slices.Sorted(maps.Keys(layers.Merge().ChefConfig.GetProfiles())), cobra.ShellCompDirectiveNoFileComp
+}
+
+// registerGroupCompletion adds group name completion to the --only and --except flags of cmd
+func registerGroupCompletion(cmd *cobra.Command) {
+	for _, flag := range []string{"only", "except"} {
+		cobra.CheckErr(cmd.RegisterFlagCompletionFunc(flag, completeGroupNames))
+	}
+}
+
+// registerFormatCompletion adds fixed value completion to the --format flag of cmd
+func registerFormatCompletion(cmd *cobra.Command, formats ...string) {
+	cobra.CheckErr(cmd.RegisterFlagCompletionFunc("format", cobra.FixedCompletions(formats, cobra.ShellCompDirectiveNoFileComp)))
+}
@@ -0,0 +1,268 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/bdwyertech/go-berkshelf/internal/config"
+	"github.com/bdwyertech/go-berkshelf/pkg/ui"
+)
+
+var (
+	configListFormat  string
+	configListVerbose bool
+	configInitForce   bool
+	configProject     bool
+)
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configListCmd, configGetCmd, configSetCmd, configInitCmd)
+
+	configListCmd.Flags().StringVarP(&configListFormat, "format", "f", "table", "Output format (table, json)")
+	configListCmd.Flags().BoolVarP(&configListVerbose, "verbose", "v", false, "Also show the lower layers each value overrides")
+	configInitCmd.Flags().BoolVar(&configInitForce, "force", false, "Overwrite an existing config file")
+	for _, cmd := range []*cobra.Command{configSetCmd, configInitCmd} {
+		cmd.Flags().BoolVar(&configProject, "project", false, "Write the project config (.berkshelf/config.json) instead of the user config")
+	}
+
+	registerFormatCompletion(configListCmd, "table", "json")
+	configGetCmd.ValidArgsFunction = completeFirstConfigKey
+	configSetCmd.ValidArgsFunction = completeConfigKeys
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Show and edit berks configuration",
+}
+
+var configListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Show the effective configuration and where each value came from",
+	Long: `Show the effective configuration along with the layer each value came from.
+
+Layers are merged in this order, later layers taking precedence:
+  default      built-in defaults
+  workstation  ~/.chef/credentials (profile from CHEF_PROFILE or ~/.chef/context),
+               then ~/.chef/config.rb or knife.rb
+  global       /etc/berkshelf/config.json
+  user         $HOME/.berkshelf/config.json
+  project      ./config.json, then ./.berkshelf/config.json
+  file         the file given by --config
+  env          BERKSHELF_* and CHEF_* environment variables
+  flags        --set KEY=VALUE
+
+Examples:
+  berks config list                # Show as table (default)
+  berks config list --verbose      # Also show overridden layers
+  berks config list --format json  # Show as JSON`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := checkFormat(configListFormat, "table", "json"); err != nil {
+			return err
+		}
+
+		settings, err := config.Provenance()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		if strings.EqualFold(configListFormat, "json") {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(settings)
+		}
+
+		var table *ui.Table
+		if configListVerbose {
+			table = newTable("KEY", "VALUE", "ORIGIN", "OVERRIDES")
+			for _, s := range settings {
+				table.Append(s.Key, formatConfigValue(s.Value), s.Origin, strings.Join(s.Overridden, "\n"))
+			}
+		} else {
+			table = newTable("KEY", "VALUE", "ORIGIN")
+			for _, s := range settings {
+				table.Append(s.Key, formatConfigValue(s.Value), s.Origin)
+			}
+		}
+
+		return table.Render(os.Stdout)
+	},
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get KEY",
+	Short: "Print the effective value of a configuration key",
+	Long: `Print the effective value of a configuration key. Nested keys use dots,
+and group_sources entries are addressed as group_sources.<group>.
+
+Examples:
+  berks config get cache_path
+  berks config get chef.chef_server_url
+  berks config get group_sources.integration`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		key := args[0]
+		if err := config.ValidateKey(key); err != nil {
+			return err
+		}
+
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		value, ok := cfg.Get(key)
+		if !ok {
+			return fmt.Errorf("%s is not set", key)
+		}
+
+		fmt.Println(formatConfigValue(value))
+		return nil
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set KEY VALUE [KEY VALUE...]",
+	Short: "Set configuration keys in the config file",
+	Long: `Set configuration keys in the config file given by --config, the project
+config with --project, or $HOME/.berkshelf/config.json by default. Lists are
+comma-separated.
+
+The file is only written if the resulting configuration is valid, so keys
+that must be set together (such as the chef section) are given in one call.
+
+Examples:
+  berks config set concurrency 10
+  berks config set default_sources https://supermarket.example.com,https://supermarket.chef.io
+  berks config set group_sources.integration https://artifactory.example.com/api/chef/chef
+  berks config set --project ssl_verify false
+  berks config set chef.node_name deployer chef.client_key ~/.chef/deployer.pem \
+    chef.chef_server_url https://chef.example.com/organizations/acme`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 || len(args)%2 != 0 {
+			return fmt.Errorf("expected KEY VALUE pairs, got %d argument(s)", len(args))
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := configPath()
+
+		cfg, err := config.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			cfg = &config.Config{}
+		} else if err != nil {
+			return fmt.Errorf("failed to read config: %w", err)
+		}
+
+		for i := 0; i < len(args); i += 2 {
+			if err := cfg.Set(args[i], args[i+1]); err != nil {
+				return err
+			}
+		}
+
+		// Load refuses invalid files, so never write one
+		if err := cfg.Validate(); err != nil {
+			return fmt.Errorf("refusing to write invalid config: %w", err)
+		}
+
+		if err := cfg.Save(path); err != nil {
+			return err
+		}
+
+		for i := 0; i < len(args); i += 2 {
+			log.Debugf("Set %s = %s", args[i], args[i+1])
+		}
+		fmt.Printf("Updated %s\n", path)
+		return nil
+	},
+}
+
+var configInitCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Write a default config file",
+	Long: `Write a config file containing the default settings to the path given by
+--config, or $HOME/.berkshelf/config.json by default. With --project an empty
+.berkshelf/config.json is created in the current directory, ready for
+project-specific settings that override the user config.
+
+Examples:
+  berks config init            # Create the user config
+  berks config init --project  # Create .berkshelf/config.json in this project
+  berks config init --force    # Overwrite an existing config`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := configPath()
+
+		if _, err := os.Stat(path); err == nil && !configInitForce {
+			return fmt.Errorf("%s already exists (use --force to overwrite)", path)
+		}
+
+		// A project config starts empty so it does not shadow the user config
+		cfg := config.DefaultConfig()
+		if configProject {
+			cfg = &config.Config{}
+		}
+
+		if err := cfg.Save(path); err != nil {
+			return err
+		}
+
+		fmt.Printf("Created %s\n", path)
+		return nil
+	},
+}
+
+// configPath returns the config file that config set and init write to
+func configPath() string {
+	switch {
+	case configFile != "":
+		return configFile
+	case configProject:
+		return filepath.Join(".berkshelf", "config.json")
+	default:
+		return config.GetDefaultConfigPath()
+	}
+}
+
+// formatConfigValue renders a config value on a single line
+func formatConfigValue(value any) string {
+	switch v := value.(type) {
+	case []string:
+		return strings.Join(v, ",")
+	case map[string]string:
+		entries := make([]string, 0, len(v))
+		for _, key := range slices.Sorted(maps.Keys(v)) {
+			entries = append(entries, key+"="+v[key])
+		}
+		return strings.Join(entries, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// completeConfigKeys completes the KEY arguments of config set
+func completeConfigKeys(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args)%2 != 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return config.Keys(), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeFirstConfigKey completes the single key argument of config get
+func completeFirstConfigKey(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return config.Keys(), cobra.ShellCompDirectiveNoFileComp
+}
@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berksfile"
+	"github.com/bdwyertech/go-berkshelf/pkg/convert"
+	"github.com/bdwyertech/go-berkshelf/pkg/policyfile"
+)
+
+var (
+	convertToPolicyfile bool
+	convertToBerksfile  bool
+	convertName         string
+	convertOutput       string
+	convertForce        bool
+)
+
+func init() {
+	rootCmd.AddCommand(convertCmd)
+
+	convertCmd.Flags().BoolVar(&convertToPolicyfile, "to-policyfile", false, "Convert a Berksfile to a Policyfile.rb")
+	convertCmd.Flags().BoolVar(&convertToBerksfile, "to-berksfile", false, "Convert a Policyfile.rb to a Berksfile")
+	convertCmd.Flags().StringVar(&convertName, "name", "", "Policy name (default: the metadata cookbook's name)")
+	convertCmd.Flags().StringVarP(&convertOutput, "output", "o", "", "File to write, or - for stdout (default: Policyfile.rb or Berksfile next to the input)")
+	convertCmd.Flags().BoolVar(&convertForce, "force", false, "Overwrite an existing output file")
+	convertCmd.MarkFlagsMutuallyExclusive("to-policyfile", "to-berksfile")
+	convertCmd.MarkFlagsOneRequired("to-policyfile", "to-berksfile")
+}
+
+var convertCmd = &cobra.Command{
+	Use:   "convert [FILE]",
+	Short: "Convert between a Berksfile and a Policyfile",
+	Long: `Translate a Berksfile into a Policyfile.rb, or a Policyfile.rb into a
+Berksfile, to ease migrating between the two workflows.
+
+Sources become default sources and cookbooks keep their constraints and git or
+path sources. The metadata directive becomes the cookbook in '.', and the
+policy's run list is its default recipe, or else the default recipes of the
+cookbooks outside any group. Each group becomes a named run list of its
+cookbooks' default recipes. Converting back, the cookbooks the run lists
+call for are kept, but the run lists have no Berksfile equivalent and are
+dropped. Anything else that cannot be carried over is reported as a
+warning.
+
+Examples:
+  berks convert --to-policyfile                  # Berksfile to Policyfile.rb
+  berks convert --to-policyfile --name web -o -  # Print the Policyfile
+  berks convert --to-berksfile Policyfile.rb     # Policyfile.rb to Berksfile`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		input, output := "Policyfile.rb", "Berksfile"
+		if convertToPolicyfile {
+			input, output = "Berksfile", "Policyfile.rb"
+			if berksfilePath != "" {
+				input = berksfilePath
+			}
+		}
+		if len(args) > 0 {
+			input = args[0]
+		}
+		if convertOutput != "" {
+			output = convertOutput
+		} else {
+			output = filepath.Join(filepath.Dir(input), output)
+		}
+
+		var conv *convert.Conversion
+		var err error
+		if convertToPolicyfile {
+			conv, err = berksfileToPolicyfile(input)
+		} else {
+			conv, err = policyfileToBerksfile(input)
+		}
+		if err != nil {
+			return err
+		}
+		for _, warning := range conv.Warnings {
+			log.Warn(warning)
+		}
+
+		if output == "-" {
+			fmt.Fprint(os.Stdout, conv.Content)
+			return nil
+		}
+		if _, err := os.Stat(output); err == nil && !convertForce {
+			return fmt.Errorf("%s already exists (use --force to overwrite)", output)
+		}
+		if err := os.WriteFile(output, []byte(conv.Content), 0o644); err != nil {
+			return err
+		}
+		fmt.Printf("Converted %s to %s\n", input, output)
+		return nil
+	},
+}
+
+// berksfileToPolicyfile converts the Berksfile at path, reading the name of
+// the cookbook its metadata directive adds from beside it
+func berksfileToPolicyfile(path string) (*convert.Conversion, error) {
+	bf, err := berksfile.Load(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	opts := convert.PolicyfileOptions{Name: convertName}
+	if bf.HasMetadata {
+		md, err := berksfile.ReadMetadata(filepath.Dir(path))
+		if err != nil {
+			return nil, err
+		}
+		opts.Metadata = md.Name
+	}
+	if opts.Name == "" && opts.Metadata == "" {
+		return nil, fmt.Errorf("the Berksfile has no metadata to name the policy after, use --name")
+	}
+	return convert.ToPolicyfile(bf, opts)
+}
+
+// policyfileToBerksfile converts the Policyfile.rb at path
+func policyfileToBerksfile(path string) (*convert.Conversion, error) {
+	p, err := policyfile.Load(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return convert.ToBerksfile(p)
+}
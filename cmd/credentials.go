@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/credentials"
+)
+
+var credentialsSetFromEnv string
+
+func init() {
+	rootCmd.AddCommand(credentialsCmd)
+	credentialsCmd.AddCommand(credentialsSetCmd, credentialsGetCmd, credentialsDeleteCmd)
+
+	credentialsSetCmd.Flags().StringVar(&credentialsSetFromEnv, "from-env", "", "Read the secret from this environment variable instead of prompting")
+}
+
+var credentialsCmd = &cobra.Command{
+	Use:   "credentials",
+	Short: "Manage API keys in the OS keychain",
+	Long: `Manage API keys stored in the OS keychain (macOS Keychain, Windows Credential
+Manager, or the Secret Service via secret-tool on Linux).
+
+API keys in the config file and in Policyfile/Berksfile source options can
+reference a secret instead of containing it:
+  env:VAR_NAME     read from the environment variable VAR_NAME
+  keychain:NAME    read NAME from the OS keychain
+
+Examples:
+  berks credentials set artifactory
+  berks config set api_keys.https://artifactory.example.com/api/chef/chef keychain:artifactory
+  berks config set api_keys.https://supermarket.example.com env:SUPERMARKET_API_KEY`,
+}
+
+var credentialsSetCmd = &cobra.Command{
+	Use:   "set NAME",
+	Short: "Store a secret in the OS keychain",
+	Long: `Store a secret in the OS keychain under NAME. The secret is read from the
+terminal without echoing, from standard input when it is not a terminal, or
+from an environment variable with --from-env.
+
+Examples:
+  berks credentials set artifactory
+  echo "$TOKEN" | berks credentials set artifactory
+  berks credentials set artifactory --from-env ARTIFACTORY_API_KEY`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+
+		secret, err := readSecret(name)
+		if err != nil {
+			return err
+		}
+		if secret == "" {
+			return fmt.Errorf("refusing to store an empty secret")
+		}
+
+		if err := credentials.DefaultKeyring.Set(name, secret); err != nil {
+			return fmt.Errorf("failed to store credential: %w", err)
+		}
+
+		fmt.Printf("Stored %s in %s; reference it as %s%s\n", name, credentials.DefaultKeyring.Backend(), credentials.KeychainPrefix, name)
+		return nil
+	},
+}
+
+var credentialsGetCmd = &cobra.Command{
+	Use:   "get NAME|REFERENCE",
+	Short: "Print a secret from the OS keychain",
+	Long: `Print a secret from the OS keychain. An env: or keychain: reference may be
+given instead of a name to check what it resolves to.
+
+Examples:
+  berks credentials get artifactory
+  berks credentials get env:SUPERMARKET_API_KEY`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ref := args[0]
+		if !credentials.IsReference(ref) {
+			ref = credentials.KeychainPrefix + ref
+		}
+
+		secret, err := credentials.Resolve(ref)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(secret)
+		return nil
+	},
+}
+
+var credentialsDeleteCmd = &cobra.Command{
+	Use:   "delete NAME",
+	Short: "Remove a secret from the OS keychain",
+	Long: `Remove a secret from the OS keychain.
+
+Examples:
+  berks credentials delete artifactory`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+
+		err := credentials.DefaultKeyring.Delete(name)
+		if errors.Is(err, credentials.ErrNotFound) {
+			return fmt.Errorf("no credential named %s", name)
+		} else if err != nil {
+			return fmt.Errorf("failed to delete credential: %w", err)
+		}
+
+		fmt.Printf("Deleted %s\n", name)
+		return nil
+	},
+}
+
+// readSecret reads the secret for credentials set
+func readSecret(name string) (string, error) {
+	if credentialsSetFromEnv != "" {
+		secret, ok := os.LookupEnv(credentialsSetFromEnv)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", credentialsSetFromEnv)
+		}
+		return secret, nil
+	}
+
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		fmt.Fprintf(os.Stderr, "Secret for %s: ", name)
+		secret, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret: %w", err)
+		}
+		return strings.TrimSpace(string(secret)), nil
+	}
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("failed to read secret from stdin: %w", err)
+	}
+	return strings.TrimSpace(line), nil
+}
@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berksfile"
+	"github.com/bdwyertech/go-berkshelf/pkg/diff"
+	"github.com/bdwyertech/go-berkshelf/pkg/source"
+)
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+
+	diffCmd.Flags().Bool("names-only", false, "List the added, removed and modified files without their diffs")
+	diffCmd.Flags().Bool("metadata-only", false, "Compare only the cookbook metadata")
+	diffCmd.Flags().IntP("unified", "U", diff.DefaultContext, "Lines of context around each change")
+	diffCmd.Flags().StringP("format", "f", "text", "Output format (text, json)")
+	diffCmd.MarkFlagsMutuallyExclusive("names-only", "metadata-only")
+
+	diffCmd.ValidArgsFunction = completeFirstCookbookName
+	registerFormatCompletion(diffCmd, "text", "json")
+}
+
+var diffCmd = &cobra.Command{
+	Use:   "diff COOKBOOK FROM TO",
+	Short: "Compare two versions of a cookbook",
+	Long: `Download two versions of a cookbook and show what changed between them as a
+unified diff: the metadata, compared field by field whether a version ships
+metadata.rb or metadata.json, followed by every added, removed and modified
+file. Review it before approving an upgrade.
+
+Both versions come from the source the cookbook is locked to, and otherwise
+from the first Berksfile source that has the FROM version. Outside a project
+the public Supermarket is used.
+
+In the names-only list, A is added, D removed and M modified.
+
+Examples:
+  berks diff nginx 2.7.5 2.7.6
+  berks diff nginx 2.7.5 2.7.6 --names-only
+  berks diff nginx 2.7.5 2.7.6 --metadata-only
+  berks diff nginx 2.7.5 2.7.6 --format json`,
+	Args: cobra.ExactArgs(3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format := strings.ToLower(viper.GetString("format"))
+		if err := checkFormat(format, "text", "json"); err != nil {
+			return err
+		}
+		cmd.SilenceUsage = true
+
+		sources, err := diffSources(args[0])
+		if err != nil {
+			return err
+		}
+		opts := diff.Options{
+			NamesOnly:    viper.GetBool("names-only"),
+			MetadataOnly: viper.GetBool("metadata-only"),
+			Context:      viper.GetInt("unified"),
+		}
+		result, err := diff.Compare(cmd.Context(), sources, args[0], args[1], args[2], opts)
+		if err != nil {
+			return err
+		}
+
+		if format == "json" {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(result)
+		}
+		if result.Empty() {
+			fmt.Printf("%s %s and %s are identical\n", result.Cookbook, result.From, result.To)
+			return nil
+		}
+		return result.Write(os.Stdout, opts.NamesOnly)
+	},
+}
+
+// diffSources returns the sources to look the cookbook up in: the Berksfile
+// sources, with the one the cookbook is locked to first, or the public
+// Supermarket outside a project
+func diffSources(name string) ([]source.CookbookSource, error) {
+	factory := newSourceFactory()
+	if _, err := os.Stat(berksfilePath); err != nil {
+		supermarket, err := factory.CreateFromURL(source.PUBLIC_SUPERMARKET)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create supermarket source: %w", err)
+		}
+		return []source.CookbookSource{supermarket}, nil
+	}
+
+	bf, err := berksfile.Load(berksfilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Berksfile: %w", err)
+	}
+	manager, err := factory.CreateFromBerksfile(bf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create source manager: %w", err)
+	}
+	sources := manager.GetSources()
+
+	lockFile, _, err := LoadLockFile()
+	if err != nil || lockFile == nil {
+		return sources, nil
+	}
+	locked, _, ok := lockFile.GetCookbook(name)
+	if !ok || locked.Source == nil || locked.Source.URL == "" {
+		return sources, nil
+	}
+	lockedURL := strings.TrimSuffix(locked.Source.URL, "/")
+	if i := slices.IndexFunc(sources, func(src source.CookbookSource) bool {
+		return strings.TrimSuffix(src.GetSourceURL(), "/") == lockedURL
+	}); i > 0 {
+		sources = append([]source.CookbookSource{sources[i]}, slices.Delete(slices.Clone(sources), i, i+1)...)
+	}
+	return sources, nil
+}
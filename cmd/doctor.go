@@ -0,0 +1,233 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/bdwyertech/go-berkshelf/internal/config"
+	"github.com/bdwyertech/go-berkshelf/pkg/berksfile"
+	"github.com/bdwyertech/go-berkshelf/pkg/doctor"
+	"github.com/bdwyertech/go-berkshelf/pkg/source"
+	"github.com/bdwyertech/go-berkshelf/pkg/ui"
+)
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+
+	doctorCmd.Flags().String("cookbook", "", "Cookbook to resolve end to end (default: the first Berksfile cookbook without a source of its own, or apt)")
+	doctorCmd.Flags().Duration("check-timeout", doctor.DefaultTimeout, "Time limit for each check")
+	doctorCmd.Flags().String("format", "text", "Output format (text, json)")
+
+	registerFormatCompletion(doctorCmd, "text", "json")
+}
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the configuration, sources and caches berks depends on",
+	Long: `Check that berks can work here, and say how to fix what is wrong:
+
+  config        the berkshelf config loads and is valid
+  keys          the Chef client key and publish target keys are private keys
+  caches        the cookbook, git and download caches are writable
+  sources       each source of the Berksfile answers and accepts its
+                credentials, as do the configured Chef Server and publish
+                targets
+  ssh           an ssh-agent or key is available, when the Berksfile has git
+                sources cloned over SSH
+  resolve       a cookbook resolves with its dependencies
+
+Without a Berksfile the public Supermarket is checked. Checks that do not
+apply are skipped, and warnings do not fail the command.
+
+Examples:
+  berks doctor                      # Run every check
+  berks doctor --cookbook nginx     # Resolve nginx end to end
+  berks doctor --format json        # Print the results as JSON`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format := viper.GetString("format")
+		if err := checkFormat(format, "text", "json"); err != nil {
+			return err
+		}
+
+		result := newResult("doctor", format)
+		start := time.Now()
+		results := doctor.Run(cmd.Context(), doctorChecks(), viper.GetDuration("check-timeout"))
+		result.Phase("checks", start)
+		for _, check := range results {
+			result.AddCheck(check)
+		}
+		var err error
+		if result == nil {
+			err = outputDoctorTable(results)
+		}
+		if failed := doctor.Failed(results); err == nil && failed > 0 {
+			cmd.SilenceUsage = true
+			err = &exitError{code: 1, err: fmt.Errorf("%d of %d checks failed", failed, len(results))}
+		}
+		return result.Write(os.Stdout, err)
+	},
+}
+
+// doctorChecks returns the checks for the config, and the Berksfile in the
+// current directory if there is one
+func doctorChecks() []doctor.Check {
+	cfg, err := config.Load()
+	checks := []doctor.Check{{Name: "config", Run: func(ctx context.Context) doctor.Result {
+		if err != nil {
+			return doctor.Fail("Fix the config file named in the error; 'berks config list' shows where each setting comes from", "%v", err)
+		}
+		if err := cfg.Validate(); err != nil {
+			return doctor.Fail("Correct the setting with 'berks config set'", "%v", err)
+		}
+		return doctor.Pass("loaded")
+	}}}
+	if err != nil {
+		cfg = config.DefaultConfig()
+	}
+
+	// Keys
+	chefCfg := cfg.ChefConfig
+	if chefCfg != nil && (chefCfg.GetChefServerURL() != "" || chefCfg.GetClientKey() != "") {
+		checks = append(checks, doctor.KeyFile("chef client key", chefCfg.GetClientKey(), "chef.client_key"))
+	}
+	targets := cfg.GetPublishTargets()
+	names := make([]string, 0, len(targets))
+	for name := range targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if key := targets[name].ClientKey; key != "" {
+			checks = append(checks, doctor.KeyFile("publish target "+name+" key", key, "publish_targets."+name+".client_key"))
+		}
+	}
+
+	// Caches
+	checks = append(checks,
+		doctor.Writable("cookbook cache", cfg.GetCachePathResolved(), "Set cache_path to a directory you can write to, or fix its permissions"),
+		doctor.Writable("git cache", source.GitCacheDir(), "Fix the permissions of the directory, or remove it to have it recreated"),
+		doctor.Writable("download directory", source.DefaultDownloadDir(), "Make the directory yours with 0700 permissions, or remove it to have it recreated"),
+	)
+
+	// Sources, and the cookbook to resolve from them
+	cookbook := viper.GetString("cookbook")
+	var bf *berksfile.Berksfile
+	if _, err := os.Stat(berksfilePath); err == nil {
+		parsed, err := LoadBerksfile()
+		if err != nil {
+			checks = append(checks, doctor.Check{Name: "Berksfile", Run: func(ctx context.Context) doctor.Result {
+				return doctor.Fail("Fix the Berksfile at the position in the error", "%v", err)
+			}})
+		} else {
+			bf = parsed
+			for _, cb := range bf.Cookbooks {
+				// Cookbooks with a source of their own are not resolved from the sources
+				if cookbook == "" && cb.Source == nil {
+					cookbook = cb.Name
+				}
+			}
+		}
+	}
+	if cookbook == "" {
+		cookbook = "apt"
+	}
+
+	var sources []source.CookbookSource
+	if bf != nil {
+		manager, err := SetupSourcesFromBerksfile(bf)
+		if err == nil {
+			sources = manager.GetSources()
+		}
+	} else {
+		sources = []source.CookbookSource{source.NewSupermarketSource(source.PUBLIC_SUPERMARKET)}
+	}
+	for _, src := range sources {
+		checks = append(checks, doctor.Source(src, cookbook))
+	}
+	if chefCfg != nil && chefCfg.GetChefServerURL() != "" {
+		checks = append(checks, chefServerCheck(cookbook))
+	} else {
+		checks = append(checks, skipped("chef server", "chef.chef_server_url is not set"))
+	}
+	client := &http.Client{}
+	for _, name := range names {
+		checks = append(checks, doctor.Reachable("publish target "+name, targets[name].URL, client))
+	}
+
+	if bf != nil && hasSSHGitSources(bf) {
+		checks = append(checks, doctor.SSHAuth())
+	} else {
+		checks = append(checks, skipped("ssh authentication", "no git sources cloned over SSH"))
+	}
+
+	return append(checks, doctor.Resolve(sources, cookbook))
+}
+
+// chefServerCheck checks the Chef Server of the chef section of the config
+func chefServerCheck(cookbook string) doctor.Check {
+	chefServer, err := newChefServerSource()
+	if err != nil {
+		return doctor.Check{Name: "chef server", Run: func(ctx context.Context) doctor.Result {
+			return doctor.Fail("Set chef.chef_server_url, chef.node_name and chef.client_key with 'berks config set'", "%v", err)
+		}}
+	}
+	check := doctor.Source(chefServer, cookbook)
+	check.Name = "chef server"
+	return check
+}
+
+// skipped returns a check that does not apply
+func skipped(name, reason string) doctor.Check {
+	return doctor.Check{Name: name, Run: func(ctx context.Context) doctor.Result {
+		return doctor.Skip("%s", reason)
+	}}
+}
+
+// hasSSHGitSources reports whether any cookbook of bf is cloned over SSH
+func hasSSHGitSources(bf *berksfile.Berksfile) bool {
+	for _, cookbook := range bf.Cookbooks {
+		if cookbook.Source == nil || cookbook.Source.Type != "git" {
+			continue
+		}
+		if strings.HasPrefix(cookbook.Source.URL, "git@") || strings.Contains(cookbook.Source.URL, "ssh://") {
+			return true
+		}
+	}
+	return false
+}
+
+// outputDoctorTable prints the result of each check, then the hints for the
+// checks that did not pass
+func outputDoctorTable(results []doctor.Result) error {
+	// Keep the checks in the order they ran
+	opts := tableOptions()
+	opts.Unsorted = true
+	table := ui.NewTable(opts, "CHECK", "STATUS", "DETAIL")
+	for _, result := range results {
+		table.Append(result.Name, strings.ToUpper(string(result.Status)), result.Detail)
+	}
+	if err := table.Render(os.Stdout); err != nil {
+		return err
+	}
+
+	var hints []string
+	for _, result := range results {
+		if result.Hint != "" && (result.Status == doctor.StatusFail || result.Status == doctor.StatusWarn) {
+			hints = append(hints, fmt.Sprintf("  %s: %s", result.Name, result.Hint))
+		}
+	}
+	if len(hints) > 0 {
+		fmt.Println("\nTo fix:")
+		fmt.Println(strings.Join(hints, "\n"))
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berksfmt"
+)
+
+var fmtCheck bool
+
+func init() {
+	rootCmd.AddCommand(fmtCmd)
+
+	fmtCmd.Flags().BoolVar(&fmtCheck, "check", false, "List files that are not formatted and exit 1, without rewriting them")
+}
+
+var fmtCmd = &cobra.Command{
+	Use:   "fmt [FILE...]",
+	Short: "Format a Berksfile or Policyfile",
+	Long: `Rewrite a Berksfile or Policyfile.rb in a canonical layout: sources first,
+then metadata, cookbooks and groups (for a Policyfile: name, default sources,
+run lists, cookbooks and attributes), two-space indentation, single-quoted
+strings where possible, 'key: value' options and cookbook constraints aligned
+in each run of cookbook statements. Comments stay with the statement they
+precede, and statements within conditionals are not reordered.
+
+A file named Policyfile.rb (or *.rb) is formatted as a Policyfile, any other
+as a Berksfile. The Berksfile (see --berksfile) is formatted if no file is
+given. A Berksfile that does not parse is left as it is.
+
+Examples:
+  berks fmt                    # Format ./Berksfile
+  berks fmt Policyfile.rb      # Format a Policyfile
+  berks fmt --check            # Fail in CI if ./Berksfile is not formatted`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		files := args
+		if len(files) == 0 {
+			files = []string{"Berksfile"}
+			if berksfilePath != "" {
+				files = []string{berksfilePath}
+			}
+		}
+
+		unformatted := 0
+		for _, file := range files {
+			changed, err := formatFile(file, !fmtCheck)
+			if err != nil {
+				return fmt.Errorf("failed to format %s: %w", file, err)
+			}
+			if !changed {
+				continue
+			}
+			if fmtCheck {
+				fmt.Fprintln(os.Stdout, file)
+				unformatted++
+				continue
+			}
+			log.Infof("Formatted %s", file)
+		}
+
+		if unformatted > 0 {
+			cmd.SilenceUsage = true
+			return &exitError{code: 1, err: fmt.Errorf("%d file(s) are not formatted, run 'berks fmt'", unformatted)}
+		}
+		return nil
+	},
+}
+
+// formatFile formats a Berksfile or Policyfile, rewriting it when write is
+// set, and reports whether formatting changed it
+func formatFile(file string, write bool) (bool, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return false, err
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return false, err
+	}
+
+	format := berksfmt.Berksfile
+	if strings.HasSuffix(filepath.Base(file), ".rb") {
+		format = berksfmt.Policyfile
+	}
+	formatted, err := format(string(content))
+	if err != nil {
+		return false, err
+	}
+	if formatted == string(content) {
+		return false, nil
+	}
+	if write {
+		if err := os.WriteFile(file, []byte(formatted), info.Mode().Perm()); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
@@ -3,10 +3,8 @@ package cmd
 
 import (
 	"fmt"
-	"os"
 	"strings"
 
-	"github.com/bdwyertech/go-berkshelf/pkg/lockfile"
 	"github.com/spf13/cobra"
 )
 
@@ -17,6 +15,8 @@ func init() {
 
 	// Add flags
 	graphCmd.Flags().StringVarP(&graphFormat, "format", "f", "text", "Output format (dot, text)")
+
+	registerFormatCompletion(graphCmd, "dot", "text")
 }
 
 var graphCmd = &cobra.Command{
@@ -30,12 +30,7 @@ Examples:
   berks graph --format dot      # Output graph in DOT format`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		// Load lock file
-		workDir, err := os.Getwd()
-		if err != nil {
-			return fmt.Errorf("failed to get working directory: %w", err)
-		}
-
-		manager := lockfile.NewManager(workDir)
+		manager := newLockManager(projectDir())
 		lockFile, err := manager.Load()
 		if err != nil {
 			return fmt.Errorf("failed to load lock file: %w", err)
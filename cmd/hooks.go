@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/bdwyertech/go-berkshelf/internal/config"
+	berrors "github.com/bdwyertech/go-berkshelf/pkg/errors"
+	"github.com/bdwyertech/go-berkshelf/pkg/hooks"
+	"github.com/bdwyertech/go-berkshelf/pkg/lockfile"
+	"github.com/bdwyertech/go-berkshelf/pkg/vendor"
+)
+
+var (
+	// hookRunner runs the configured lifecycle hooks; nil when none are
+	hookRunner *hooks.Runner
+	// hookConfigErr is why the hooks could not be read
+	hookConfigErr error
+	// hookCommand names the running command in hook payloads, e.g. "install"
+	hookCommand string
+)
+
+// startHooks reads the hooks configured for the command being run. A config
+// that cannot be read fails the command once it reaches a lifecycle event,
+// rather than skipping hooks that may be compliance checks, while commands
+// that reach none, such as config, still run.
+func startHooks(cmd *cobra.Command) {
+	hookCommand = strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()+" ")
+	cfg, err := config.Load()
+	if err != nil {
+		log.Debugf("Hooks not loaded: %v", err)
+		hookConfigErr = err
+		return
+	}
+	if configured := cfg.GetHooks(); len(configured) > 0 {
+		hookRunner = hooks.NewRunner(configured)
+	}
+}
+
+// runHooks runs the hooks for an event of the running command, in the
+// project directory unless the payload names another. A failing hook is a
+// validation error, so a compliance check can stop the command.
+func runHooks(ctx context.Context, payload hooks.Payload) error {
+	if hookConfigErr != nil {
+		return berrors.WithType(fmt.Errorf("cannot run %s hooks: %w", payload.Event, hookConfigErr), berrors.ErrorTypeConfiguration)
+	}
+	if !hookRunner.Has(payload.Event) {
+		return nil
+	}
+	payload.Command = hookCommand
+	if payload.Dir == "" {
+		payload.Dir = projectDir()
+	}
+	if abs, err := filepath.Abs(payload.Dir); err == nil {
+		payload.Dir = abs
+	}
+	if err := hookRunner.Run(ctx, payload); err != nil {
+		return berrors.WithType(err, berrors.ErrorTypeValidation)
+	}
+	return nil
+}
+
+// vendorWithHooks vendors the cookbooks of lockFile with the vendorer made
+// from options, running the pre-vendor hooks before and the post-vendor
+// hooks on the cookbooks vendored after. A dry run vendors nothing, so only
+// the pre-vendor hooks run.
+func vendorWithHooks(ctx context.Context, vendorer *vendor.Vendorer, lockFile *lockfile.LockFile, options vendor.Options) (*vendor.Result, error) {
+	path := options.TargetPath
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	var only func(string) bool
+	if len(options.OnlyCookbooks) > 0 {
+		only = func(name string) bool { return slices.Contains(options.OnlyCookbooks, name) }
+	}
+	if err := runHooks(ctx, hooks.Payload{Event: hooks.PreVendor, Path: path, Cookbooks: hooks.Locked(lockFile, only)}); err != nil {
+		return nil, err
+	}
+
+	result, err := vendorer.Vendor(ctx)
+	if err != nil || options.DryRun {
+		return result, err
+	}
+	vendored := result.Vendored()
+	payload := hooks.Payload{Event: hooks.PostVendor, Path: result.TargetPath, Cookbooks: hooks.Locked(lockFile, func(name string) bool {
+		return slices.Contains(vendored, name)
+	})}
+	return result, runHooks(ctx, payload)
+}
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	log "github.com/sirupsen/logrus"
+
+	berrors "github.com/bdwyertech/go-berkshelf/pkg/errors"
+	"github.com/bdwyertech/go-berkshelf/pkg/lockfile"
+	"github.com/bdwyertech/go-berkshelf/pkg/server"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+
+	// Add flags
+	importCmd.Flags().String("environment", "", "Import the versions this environment's cookbook constraints select")
+	importCmd.Flags().Bool("force", false, "Overwrite an existing Berksfile")
+	importCmd.Flags().String("server-url", "", "Chef Server URL, including the organization (default: chef.chef_server_url from config)")
+	importCmd.Flags().String("client-name", "", "Client name used to authenticate (default: chef.node_name from config)")
+	importCmd.Flags().String("client-key", "", "Path to the client private key (default: chef.client_key from config)")
+}
+
+var importCmd = &cobra.Command{
+	Use:   "import [COOKBOOK...]",
+	Short: "Create a Berksfile and lock file from the cookbooks on a Chef Server",
+	Long: `Create a Berksfile and lock file from the cookbooks on a Chef Server, to
+bring the cookbooks a server already runs under source control.
+
+The Berksfile resolves from the Chef Server and pins each cookbook to the
+newest version uploaded, or with --environment to the newest version the
+environment's cookbook constraints allow. Name cookbooks to import only them;
+their dependencies are locked as the server resolves them. The Berksfile is
+then resolved and the lock files written as 'berks install' would.
+
+Connection settings are read from the chef section of the berkshelf config,
+or the profile selected with --chef-profile or $CHEF_PROFILE, and may be
+overridden with --server-url, --client-name and --client-key. The Berksfile
+names only the server URL, so whoever installs it uses their own
+credentials.
+
+Examples:
+  berks import                              # Every cookbook on the server
+  berks import --environment production     # What production runs
+  berks import nginx postgresql             # Only these cookbooks and their dependencies
+  berks import -b infra/Berksfile --force   # Replace an existing Berksfile`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := os.Stat(berksfilePath); err == nil && !viper.GetBool("force") {
+			return fmt.Errorf("%s already exists (use --force to overwrite)", berksfilePath)
+		}
+
+		cmd.SilenceUsage = true
+
+		chefServer, err := newChefServerSource()
+		if err != nil {
+			return berrors.WithType(err, berrors.ErrorTypeConfiguration)
+		}
+
+		options := server.ImportOptions{
+			Environment: viper.GetString("environment"),
+			Cookbooks:   args,
+		}
+		pins, err := server.Import(chefServer.Client(), options)
+		if err != nil {
+			return berrors.WithType(fmt.Errorf("import failed: %w", err), berrors.ErrorTypeNetwork)
+		}
+		if len(pins) == 0 {
+			return fmt.Errorf("the Chef Server has no cookbooks to import")
+		}
+
+		if err := os.MkdirAll(filepath.Dir(berksfilePath), 0755); err != nil {
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+		content := server.Berksfile(chefServer.GetSourceURL(), options, pins)
+		if err := os.WriteFile(berksfilePath, []byte(content), 0644); err != nil {
+			return fmt.Errorf("failed to write Berksfile: %w", err)
+		}
+		log.Infof("Wrote %s pinning %d cookbooks", berksfilePath, len(pins))
+
+		lockFile, err := resolveLockFile(cmd.Context())
+		if err != nil {
+			return fmt.Errorf("the imported versions do not resolve, adjust %s and run 'berks install': %w", berksfilePath, err)
+		}
+		berks, err := LoadBerksfile()
+		if err != nil {
+			return err
+		}
+		lockManager := newLockManager(projectDir())
+		if err := lockManager.SaveBoth(lockFile, lockfile.DirectDependencies(berks.Cookbooks)); err != nil {
+			return fmt.Errorf("failed to write lock files: %w", err)
+		}
+		log.Infof("Locked %d cookbooks in %s", len(lockFile.ListCookbooks()), lockManager.GetPath())
+		if lockManager.WritesRuby() {
+			log.Infof("Generated %s", lockManager.GetRubyPath())
+		}
+		return nil
+	},
+}
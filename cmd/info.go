@@ -3,7 +3,9 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
+	"maps"
 	"os"
+	"slices"
 	"strings"
 
 	log "github.com/sirupsen/logrus"
@@ -22,6 +24,9 @@ func init() {
 
 	// Add flags
 	infoCmd.Flags().StringVarP(&infoFormat, "format", "f", "text", "Output format (text, json)")
+
+	infoCmd.ValidArgsFunction = completeFirstCookbookName
+	registerFormatCompletion(infoCmd, "text", "json")
 }
 
 var infoCmd = &cobra.Command{
@@ -45,10 +50,10 @@ Examples:
 		// Try to parse Berksfile to get sources
 		var sourceManager *source.Manager
 
-		if _, err := os.Stat("Berksfile"); err == nil {
-			bf, err := berksfile.Load("Berksfile")
+		if _, err := os.Stat(berksfilePath); err == nil {
+			bf, err := berksfile.Load(berksfilePath)
 			if err == nil {
-				factory := source.NewFactory()
+				factory := newSourceFactory()
 				sourceManager, err = factory.CreateFromBerksfile(bf)
 				if err != nil {
 					log.Error(err)
@@ -58,7 +63,7 @@ Examples:
 
 		// If no Berksfile or failed to parse, create default source manager
 		if sourceManager == nil {
-			factory := source.NewFactory()
+			factory := newSourceFactory()
 			sourceManager = source.NewManager()
 			supermarketSource, err := factory.CreateFromURL(source.PUBLIC_SUPERMARKET)
 			if err != nil {
@@ -115,6 +120,47 @@ func outputInfoText(info *info.CookbookInfo) error {
 
 	fmt.Printf("Source: %s\n", info.Source)
 
+	if info.Deprecated {
+		fmt.Printf("Deprecated: yes\n")
+	}
+
+	if info.SourceURL != "" {
+		fmt.Printf("Source URL: %s\n", info.SourceURL)
+	}
+
+	if info.IssuesURL != "" {
+		fmt.Printf("Issues URL: %s\n", info.IssuesURL)
+	}
+
+	if info.ExternalURL != "" {
+		fmt.Printf("External URL: %s\n", info.ExternalURL)
+	}
+
+	if len(info.Platforms) > 0 {
+		fmt.Printf("\nSupports:\n")
+		for _, platform := range slices.Sorted(maps.Keys(info.Platforms)) {
+			fmt.Printf("  %s (%s)\n", platform, info.Platforms[platform])
+		}
+	}
+
+	if len(info.Recipes) > 0 {
+		fmt.Printf("\nRecipes:\n")
+		for _, recipe := range slices.Sorted(maps.Keys(info.Recipes)) {
+			if description := info.Recipes[recipe]; description != "" {
+				fmt.Printf("  %s - %s\n", recipe, description)
+			} else {
+				fmt.Printf("  %s\n", recipe)
+			}
+		}
+	}
+
+	if len(info.RootFiles) > 0 {
+		fmt.Printf("\nRoot Files:\n")
+		for _, file := range info.RootFiles {
+			fmt.Printf("  %s\n", file)
+		}
+	}
+
 	if len(info.Dependencies) > 0 {
 		fmt.Printf("\nDependencies:\n")
 		for depName, constraint := range info.Dependencies {
@@ -3,14 +3,25 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
+	"github.com/bdwyertech/go-berkshelf/internal/config"
 	"github.com/bdwyertech/go-berkshelf/pkg/berksfile"
 	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	"github.com/bdwyertech/go-berkshelf/pkg/cache"
+	"github.com/bdwyertech/go-berkshelf/pkg/events"
 	"github.com/bdwyertech/go-berkshelf/pkg/lockfile"
-	"github.com/bdwyertech/go-berkshelf/pkg/resolver"
+	"github.com/bdwyertech/go-berkshelf/pkg/replay"
+	"github.com/bdwyertech/go-berkshelf/pkg/server"
 	"github.com/bdwyertech/go-berkshelf/pkg/source"
+	"github.com/bdwyertech/go-berkshelf/pkg/vendor"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -23,6 +34,17 @@ func init() {
 	installCmd.Flags().StringSliceP("only", "o", nil, "Only install cookbooks in specified groups")
 	installCmd.Flags().StringSliceP("except", "e", nil, "Install all cookbooks except those in specified groups")
 	installCmd.Flags().BoolP("force", "f", false, "Force installation even if Berksfile.lock is up to date")
+	installCmd.Flags().Bool("no-cache", false, "Always resolve dependencies and query sources instead of using cached results")
+	installCmd.Flags().String("format", "text", "Output format (text, ndjson, json)")
+	installCmd.Flags().Bool("detect-chef", false, "Only select cookbook versions whose chef_version supports the local chef-client/cinc-client")
+	installCmd.Flags().String("environment", "", "Constrain cookbooks to a Chef environment's cookbook_versions, from the Chef Server or a JSON file")
+	installCmd.Flags().String("record", "", "Record the source answers and chosen versions to this fixture file")
+	installCmd.Flags().Bool("watch", false, "Vendor to ./berks-cookbooks, then re-vendor path cookbooks as their files change")
+
+	installCmd.MarkFlagsMutuallyExclusive("watch", "record")
+
+	registerGroupCompletion(installCmd)
+	registerFormatCompletion(installCmd, "text", "ndjson", "json")
 }
 
 var installCmd = &cobra.Command{
@@ -36,111 +58,486 @@ This command will:
 - Download cookbooks to the cache
 - Generate or update Berksfile.lock
 
+When the Berksfile, metadata, lock file, sources and group filters are
+unchanged since a recent install, the cached resolution is reused. Versions
+available from remote sources are re-checked at most once per
+min_check_interval (15 minutes by default) per cookbook. Pass --no-cache to
+always resolve and query sources.
+
+With --format ndjson, progress events (resolution started, cookbook resolved,
+download progress, completed) are written to stdout as one JSON object per
+line while logs continue to go to stderr. With --format json, a single result
+document (resolved cookbooks, actions, warnings, durations) is written to
+stdout when the command finishes.
+
+Resolution stops at the first cookbook that cannot be resolved or
+downloaded. With --keep-going every other cookbook is still resolved, and
+all the failures are reported together.
+
+With --detect-chef, the local chef-client or cinc-client is run to read its
+version, and cookbook versions whose chef_version excludes it are skipped.
+
+With --environment, the cookbook_versions constraints of a Chef environment
+must hold as well, to check that the cookbooks can be deployed to it before
+they are. It names an environment on the Chef Server, connected to as 'berks
+server' is, or a JSON file of one ('knife environment show -F json'). The
+constraints only apply to cookbooks the Berksfile resolves. An up-to-date
+lock file is checked against them instead of resolved again.
+
+Cookbooks can be pinned to an exact version, and optionally a source,
+outside the Berksfile with an "overrides" section in Berksfile.go.lock, e.g.
+to ship an emergency hotfix:
+
+  "overrides": {
+    "nginx": {"version": "12.0.1", "reason": "CVE-2024-1234 hotfix"}
+  }
+
+An override replaces every constraint on its cookbook and is kept when the
+lock file is rewritten. Every install warns about each override until it is
+removed.
+
+Overrides can also be declared in the Berksfile, where they take precedence
+over those in the lock file:
+
+  override 'nginx', '= 12.0.1'
+
+A warning is logged for each constraint, from the Berksfile or a cookbook's
+dependencies, that the pinned version does not satisfy.
+
+Cookbooks provided out of band, e.g. through a Chef Server run list, are
+left out of the resolution with 'ignore' (or 'exclude') in the Berksfile.
+They are not resolved, their dependencies are not traversed, and the lock
+file lists those that were skipped:
+
+  ignore 'chef-client', 'audit'
+
+Cookbooks whose names match a source_routes pattern in the berkshelf config
+are resolved, with their dependencies' versions, only from that route's
+source; the first matching route wins. Cookbooks with a source in the
+Berksfile keep it:
+
+  "source_routes": [{"pattern": "^mycorp-", "source": "https://supermarket.mycorp.example"}]
+
+With --record, what every source answered during resolution and the
+versions chosen are written to a fixture file. The fixture replays the
+resolution without the network (see pkg/replay), so a hard resolution can
+be kept as a test case that fails if the resolver starts choosing
+different versions. Recording always resolves, never reusing a cached
+resolution.
+
+With --watch, the locked cookbooks are vendored to ./berks-cookbooks and the
+command keeps running, re-vendoring each path cookbook as its files change,
+e.g. while iterating on a cookbook with Test Kitchen. A change to a
+cookbook's metadata re-resolves the dependencies before re-vendoring. Press
+Ctrl-C to stop.
+
 Examples:
   berks install                 # Install all dependencies
   berks install --only group1   # Install only group1 dependencies
-  berks install --except test   # Install all except test group`,
+  berks install --except test   # Install all except test group
+  berks install --format ndjson # Stream progress events as JSON lines
+  berks install --format json   # Print a JSON result when done
+  berks install --detect-chef   # Resolve against the local Chef Infra Client version
+  berks install --environment production  # Fail if production would not allow the versions
+  berks install --record pkg/replay/testdata/acme.json  # Keep this resolution as a fixture
+  berks install --watch         # Keep berks-cookbooks in sync with path cookbooks`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		log.Info("Installing cookbooks from Berksfile...")
-
-		// 1. Parse Berksfile
-		log.Info("Parsing Berksfile...")
-		berks, err := LoadBerksfile()
-		if err != nil {
+		format := viper.GetString("format")
+		if err := checkFormat(format, "text", "ndjson", "json"); err != nil {
 			return err
 		}
 
-		workDir, err := os.Getwd()
-		if err != nil {
-			return fmt.Errorf("failed to get working directory: %w", err)
+		if viper.GetBool("watch") && format == "json" {
+			return fmt.Errorf("--watch cannot be used with --format json, which prints one result when done")
 		}
 
-		// 2. Check lock file status
-		lockManager := lockfile.NewManager(workDir)
-		log.Info("Checking lock file status...")
+		emit := newEventHandler(format)
+		result := newResult("install", format)
 
-		shouldProceed, err := CheckLockFileStatus(lockManager, viper.GetBool("force"))
-		if err != nil {
-			return err
+		err := runInstall(cmd, emit, result)
+		if err == nil && viper.GetBool("watch") {
+			err = watchInstall(cmd, emit)
 		}
-		if !shouldProceed {
-			return nil
+		if err != nil {
+			emit.Emit(events.Event{Type: events.Failed, Error: err.Error()})
 		}
+		return result.Write(os.Stdout, err)
+	},
+}
 
-		// Filter cookbooks by groups
-		only, except := viper.GetStringSlice("only"), viper.GetStringSlice("except")
+// runInstall resolves and locks the Berksfile dependencies. Progress is sent to
+// emit and the outcome is recorded in result; both may be nil.
+func runInstall(cmd *cobra.Command, emit events.Handler, result *Result) error {
+	defer result.Phase("install", time.Now())
+	log.Info("Installing cookbooks from Berksfile...")
 
-		cookbooks := berksfile.FilterCookbooksByGroup(berks.Cookbooks, only, except)
-		if len(only) > 0 || len(except) > 0 {
-			log.Infof("Filtered to %d cookbooks based on group selection", len(cookbooks))
-		}
+	// 1. Parse Berksfile
+	log.Info("Parsing Berksfile...")
+	berks, err := LoadBerksfile()
+	if err != nil {
+		return err
+	}
 
-		// 3. Create requirements from cookbooks
-		log.Info("Creating requirements...")
-		requirements := CreateRequirementsFromCookbooks(cookbooks)
-		if berks.HasMetadata {
-			pathSrc, err := source.NewPathSource(".")
+	// 2. Check lock file status
+	if err := checkLockFiles(projectDir()); err != nil {
+		return err
+	}
+	lockManager := newLockManager(projectDir())
+	log.Info("Checking lock file status...")
+
+	shouldProceed, err := CheckLockFileStatus(lockManager, viper.GetBool("force"))
+	if err != nil {
+		return err
+	}
+	if !shouldProceed {
+		if lockFile, err := lockManager.Load(); err == nil {
+			// An up-to-date lock file must still hold in the environment
+			env, err := loadEnvironment()
 			if err != nil {
-				return fmt.Errorf("failed to create path source for metadata: %w", err)
+				return err
 			}
-			metadata, err := pathSrc.ReadMetadata(".")
-			if err != nil {
-				return fmt.Errorf("failed to read metadata: %w", err)
+			if env != nil {
+				if err := checkEnvironment(lockFile, env); err != nil {
+					return err
+				}
 			}
+			warnOverrides(lockFile, lockManager.GetPath(), result)
+			result.AddLockFile(lockFile)
+		}
+		result.Act("skipped", "", "lock file is up to date")
+		emit.Emit(events.Event{Type: events.Completed, Message: "lock file is up to date"})
+		return nil
+	}
 
-			log.Debugf("Found cookbook %s (%s) via metadata", metadata.Name, metadata.Version)
+	// Filter cookbooks by groups
+	only, except := viper.GetStringSlice("only"), viper.GetStringSlice("except")
 
-			req := resolver.NewRequirementWithSource(metadata.Name, nil, &berkshelf.SourceLocation{
-				Type: "path",
-				Path: ".",
-			})
-			requirements = append(requirements, req)
-		}
+	cookbooks := berksfile.FilterCookbooksByGroup(berks.Cookbooks, only, except)
+	if len(only) > 0 || len(except) > 0 {
+		log.Infof("Filtered to %d cookbooks based on group selection", len(cookbooks))
+	}
+
+	groupSources := loadGroupSources()
+	cookbooks = berksfile.ApplyGroupSources(cookbooks, groupSources)
 
-		// 4. Set up sources
-		log.Info("Setting up sources...")
-		sourceManager, err := SetupSourcesFromBerksfile(berks)
+	// 3. Create requirements from cookbooks
+	log.Info("Creating requirements...")
+	// The metadata directive adds the cookbook here and its dependencies
+	metadataCookbooks, err := berks.MetadataCookbooks(projectDir())
+	if err != nil {
+		return err
+	}
+	requirements := CreateRequirementsFromCookbooks(slices.Concat(cookbooks, metadataCookbooks))
+
+	// Extract direct dependencies from Berksfile for DEPENDENCIES section
+	var groups []string
+	if len(only) > 0 {
+		groups = only
+	}
+
+	dependencies, err := lockfile.ExtractDirectDependencies(berksfilePath, groups)
+	if err != nil {
+		log.Warnf("Failed to extract direct dependencies for Ruby lock file: %v", err)
+		result.Warn("failed to extract direct dependencies for Ruby lock file: %v", err)
+		// Continue with empty dependencies list
+		dependencies = []string{}
+	}
+
+	chefVersion, err := detectChefVersion(cmd.Context())
+	if err != nil {
+		return err
+	}
+
+	overrides, err := resolutionOverrides(berks, lockManager)
+	if err != nil {
+		return err
+	}
+
+	// 4. Reuse a cached resolution if the inputs are unchanged
+	var solutions *cache.SolutionCache
+	var solutionHash string
+	recordPath := viper.GetString("record")
+	if !viper.GetBool("no-cache") && recordPath == "" {
+		env, err := loadEnvironment()
 		if err != nil {
 			return err
 		}
+		solutions, solutionHash = openSolutionCache(berks, lockManager, groupSources, only, except, chefVersion, env)
+		// --force resolves again, refreshing the cached resolution
+		if solutions != nil && !viper.GetBool("force") {
+			if lockFile, ok := solutions.Get(solutionHash); ok {
+				log.Info("Reusing cached resolution (inputs unchanged)")
+				if err := lockManager.SaveBoth(lockFile, dependencies); err != nil {
+					return fmt.Errorf("failed to update lock files: %w", err)
+				}
 
-		// 5. Resolve dependencies
-		log.Info("Resolving dependencies...")
-		resolution, err := ResolveDependencies(cmd.Context(), requirements, sourceManager.GetSources())
-		if err != nil {
-			return err
+				log.Info("")
+				log.Info("Installation complete!")
+				log.Infof("Resolved %d cookbooks", len(lockFile.ListCookbooks()))
+				log.Infof("Updated %s", lockManager.GetPath())
+				warnOverrides(lockFile, lockManager.GetPath(), result)
+				result.AddLockFile(lockFile)
+				result.Act("reused_resolution", "", solutionHash)
+				result.Act("wrote_lockfile", "", lockManager.GetPath())
+				if lockManager.WritesRuby() {
+					log.Infof("Generated %s", lockManager.GetRubyPath())
+					result.Act("wrote_lockfile", "", lockManager.GetRubyPath())
+				}
+				emit.Emit(events.Event{Type: events.Completed, Count: len(lockFile.ListCookbooks()), Message: "reused cached resolution"})
+				return nil
+			}
 		}
+	}
 
-		log.Infof("Resolved %d cookbooks", resolution.CookbookCount())
+	// 5. Set up sources
+	log.Info("Setting up sources...")
+	sourceManager, err := SetupSourcesFromBerksfile(berks)
+	if err != nil {
+		return err
+	}
 
-		// 6. Generate/update lock files
-		log.Info("Updating Berksfile.lock...")
+	// 6. Resolve dependencies
+	log.Info("Resolving dependencies...")
+	resolveStart := time.Now()
+	sources := sourceManager.GetSources()
+	if !viper.GetBool("no-cache") {
+		sources = withVersionCache(sources)
+	}
+	var recorder *replay.Recorder
+	var factory source.SourceFactory
+	if recordPath != "" {
+		recorder = replay.NewRecorder(source.NewFactory())
+		sources, factory = recorder.WrapAll(sources), recorder
+	}
+	resolution, err := ResolveDependencies(cmd.Context(), requirements, overrides, berks.Ignored, sources, factory, chefVersion, emit)
+	result.Phase("resolve", resolveStart)
+	if err != nil {
+		return err
+	}
+	for _, warning := range resolution.Warnings {
+		result.Warn("%s", warning)
+	}
 
-		// Extract direct dependencies from Berksfile for DEPENDENCIES section
-		berksfilePath := "Berksfile"
-		var groups []string
-		if len(only) > 0 {
-			groups = only
+	if recorder != nil {
+		if err := recorder.Fixture(requirements, chefVersion, resolution).Save(recordPath); err != nil {
+			return fmt.Errorf("failed to write fixture: %w", err)
 		}
+		log.Infof("Recorded resolution to %s", recordPath)
+		result.Act("recorded", "", recordPath)
+	}
+
+	log.Infof("Resolved %d cookbooks", resolution.CookbookCount())
+
+	// 7. Generate/update lock files
+	log.Info("Updating lock files...")
+
+	lockFile, err := lockManager.Generate(resolution)
+	if err != nil {
+		return fmt.Errorf("failed to generate lock file: %w", err)
+	}
 
-		dependencies, err := lockfile.ExtractDirectDependencies(berksfilePath, groups)
+	// Update both JSON and Ruby lock files
+	if err := lockManager.SaveBoth(lockFile, dependencies); err != nil {
+		return fmt.Errorf("failed to update lock files: %w", err)
+	}
+
+	if solutions != nil {
+		if err := solutions.Put(solutionHash, lockFile); err != nil {
+			log.Debugf("Failed to cache resolution: %v", err)
+		}
+	}
+
+	log.Info("")
+	log.Info("Installation complete!")
+	log.Infof("Resolved %d cookbooks", resolution.CookbookCount())
+	log.Infof("Updated %s", lockManager.GetPath())
+	warnOverrides(lockFile, lockManager.GetPath(), result)
+	result.AddLockFile(lockFile)
+	result.Act("wrote_lockfile", "", lockManager.GetPath())
+	if lockManager.WritesRuby() {
+		log.Infof("Generated %s", lockManager.GetRubyPath())
+		result.Act("wrote_lockfile", "", lockManager.GetRubyPath())
+	}
+	emit.Emit(events.Event{Type: events.Completed, Count: resolution.CookbookCount()})
+
+	return nil
+}
+
+// openSolutionCache opens the resolution cache and computes the key for the current inputs.
+// It returns a nil cache if the cache cannot be used; resolution then proceeds as normal.
+func openSolutionCache(berks *berksfile.Berksfile, lockManager *lockfile.Manager, groupSources map[string]string, only, except []string, chefVersion *berkshelf.Version, env *server.Environment) (*cache.SolutionCache, string) {
+	// Hash what the Berksfile declares once evaluated, as its conditionals
+	// may declare other cookbooks with the same text in another environment
+	fingerprint, err := berks.Fingerprint()
+	if err != nil {
+		log.Debugf("Resolution cache disabled: %v", err)
+		return nil, ""
+	}
+
+	key := &cache.SolutionKey{
+		Berksfile: fingerprint,
+		Only:      only,
+		Except:    except,
+		Algorithm: checksumAlgorithm(),
+	}
+	if chefVersion != nil {
+		key.ChefVersion = chefVersion.String()
+	}
+	if env != nil {
+		key.Constraints = env.Keys()
+	}
+
+	if berks.HasMetadata {
+		for _, name := range []string{"metadata.json", "metadata.rb"} {
+			if data, err := os.ReadFile(filepath.Join(projectDir(), name)); err == nil {
+				key.Metadata = data
+				break
+			}
+		}
+	}
+
+	// Path cookbooks change without the Berksfile changing
+	for _, cookbook := range berks.Cookbooks {
+		if cookbook.Source == nil || cookbook.Source.Type != "path" {
+			continue
+		}
+		dir := cookbook.Source.Path
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(projectDir(), dir)
+		}
+		for _, candidate := range []string{dir, filepath.Join(dir, cookbook.Name)} {
+			for _, name := range []string{"metadata.json", "metadata.rb"} {
+				path := filepath.Join(candidate, name)
+				if data, err := os.ReadFile(path); err == nil {
+					if key.PathMetadata == nil {
+						key.PathMetadata = make(map[string][]byte)
+					}
+					key.PathMetadata[path] = data
+				}
+			}
+		}
+	}
+
+	if cfg, err := config.Load(); err == nil {
+		for _, url := range cfg.GetDefaultSources() {
+			key.Sources = append(key.Sources, "default="+url)
+		}
+		for _, route := range cfg.GetSourceRoutes() {
+			key.Sources = append(key.Sources, route.Pattern+"=>"+route.Source)
+		}
+	}
+	for group, url := range groupSources {
+		key.Sources = append(key.Sources, group+"="+url)
+	}
+
+	if lockManager.Exists() {
+		if lf, err := lockManager.Load(); err == nil {
+			key.LockFile = lf
+		}
+	}
+
+	hash, err := key.Hash()
+	if err != nil {
+		log.Debugf("Resolution cache disabled: %v", err)
+		return nil, ""
+	}
+
+	solutions, err := cache.NewSolutionCache(resolutionCacheDir(), cache.DefaultSolutionTTL)
+	if err != nil {
+		log.Debugf("Resolution cache disabled: %v", err)
+		return nil, ""
+	}
+
+	return solutions, hash
+}
+
+// watchInstall vendors the locked cookbooks to berks-cookbooks, then
+// re-vendors path cookbooks as their files change until interrupted. A
+// changed metadata file may change versions or dependencies, so it
+// re-resolves and re-vendors every cookbook.
+func watchInstall(cmd *cobra.Command, emit events.Handler) error {
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Path cookbooks are relative to the Berksfile
+	workDir, err := filepath.Abs(projectDir())
+	if err != nil {
+		return err
+	}
+
+	vendorCookbooks := func(cookbooks []string) (*lockfile.LockFile, error) {
+		lockFile, _, err := LoadLockFile()
 		if err != nil {
-			log.Warnf("Failed to extract direct dependencies for Ruby lock file: %v", err)
-			// Continue with empty dependencies list
-			dependencies = []string{}
+			return nil, err
+		}
+		berks, err := LoadBerksfile()
+		if err != nil {
+			return nil, err
+		}
+		sourceManager, err := CreateSourceManager(berks)
+		if err != nil {
+			return nil, err
 		}
 
-		// Update both JSON and Ruby lock files
-		if err := lockManager.UpdateBoth(resolution, dependencies); err != nil {
-			return fmt.Errorf("failed to update lock files: %w", err)
+		options := vendor.Options{
+			TargetPath:    defaultVendorPath,
+			OnlyCookbooks: cookbooks,
+			KeepGoing:     viper.GetBool("keep-going"),
+			Factory:       newSourceFactory(),
+		}
+		vendorResult, err := vendorWithHooks(ctx, vendor.New(lockFile, sourceManager, options), lockFile, options)
+		if err != nil {
+			return nil, err
+		}
+		for name, errMsg := range vendorResult.FailedDownloads {
+			log.Warnf("Failed to vendor %s: %s", name, errMsg)
+		}
+		for _, collision := range vendorResult.Collisions {
+			log.Warnf("Vendor collision: %s", collision)
 		}
+		log.Infof("Vendored %d cookbook(s) to %s", vendorResult.SuccessfulDownloads, vendorResult.TargetPath)
+		emit.Emit(events.Event{Type: events.Completed, Count: vendorResult.SuccessfulDownloads, Message: "vendored"})
+		return lockFile, nil
+	}
 
-		log.Info("")
-		log.Info("Installation complete!")
-		log.Infof("Resolved %d cookbooks", resolution.CookbookCount())
-		log.Infof("Updated %s", lockManager.GetPath())
-		log.Infof("Generated %s", lockManager.GetRubyPath())
+	lockFile, err := vendorCookbooks(nil)
+	if err != nil {
+		return err
+	}
+	dirs, err := vendor.PathCookbooks(ctx, lockFile, workDir)
+	if err != nil {
+		return err
+	}
+	if len(dirs) == 0 {
+		return fmt.Errorf("no path cookbooks to watch")
+	}
 
-		return nil
-	},
+	watcher, err := vendor.NewWatcher(dirs, defaultVendorPath)
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	log.Infof("Watching %d path cookbook(s) for changes, press Ctrl-C to stop", len(dirs))
+	return watcher.Run(ctx, func(change vendor.Change) {
+		cookbooks := change.Cookbooks
+		if change.Metadata {
+			log.Infof("Metadata of %s changed, resolving dependencies...", strings.Join(change.Cookbooks, ", "))
+			// The lock file is newer than the Berksfile, so only forcing re-resolves
+			if err := cmd.Flags().Set("force", "true"); err != nil {
+				log.Errorf("Failed to resolve dependencies: %v", err)
+				return
+			}
+			if err := runInstall(cmd, emit, nil); err != nil {
+				log.Errorf("Failed to resolve dependencies: %v", err)
+				return
+			}
+			cookbooks = nil
+		} else {
+			log.Infof("%s changed, re-vendoring...", strings.Join(change.Cookbooks, ", "))
+		}
+		if _, err := vendorCookbooks(cookbooks); err != nil && ctx.Err() == nil {
+			log.Errorf("Failed to vendor: %v", err)
+		}
+	})
 }
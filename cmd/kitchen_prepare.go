@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/kitchen"
+	"github.com/bdwyertech/go-berkshelf/pkg/vendor"
+)
+
+func init() {
+	rootCmd.AddCommand(kitchenPrepareCmd)
+
+	kitchenPrepareCmd.Flags().StringSlice("suite", nil, "Only prepare the named Test Kitchen suite(s)")
+	kitchenPrepareCmd.Flags().Bool("install", true, "Automatically create/update lockfile")
+	kitchenPrepareCmd.Flags().Bool("force", false, "Force installation even if Berksfile.lock is up to date")
+	kitchenPrepareCmd.Flags().String("format", "text", "Output format (text, json)")
+
+	cobra.CheckErr(kitchenPrepareCmd.RegisterFlagCompletionFunc("suite", completeSuiteNames))
+	registerFormatCompletion(kitchenPrepareCmd, "text", "json")
+}
+
+var kitchenPrepareCmd = &cobra.Command{
+	Use:   "kitchen-prepare",
+	Short: "Vendor each Test Kitchen suite's cookbooks",
+	Long: `Vendor the cookbooks of each suite in kitchen.yml (or .kitchen.yml, or
+$KITCHEN_YAML) for Test Kitchen, so kitchen workflows do not need the
+berkshelf gem.
+
+Each suite's cookbooks are vendored to .kitchen/berkshelf/<suite>, one
+directory per cookbook as Test Kitchen's berkshelf provisioner lays them out
+in the sandbox. A suite gets the cookbooks it converges (see 'berks suites')
+and their dependencies; a suite that names none gets every locked cookbook,
+as the berkshelf provisioner would vendor. Each suite's directory is
+replaced, so cookbooks dropped from it do not linger.
+
+The prepared suites are listed in .kitchen/berkshelf.json, with each suite's
+directory and the version and source of each cookbook:
+
+  {
+    "generated_at": "2024-05-01T12:00:00Z",
+    "lockfile": "Berksfile.go.lock",
+    "suites": {
+      "default": {
+        "path": ".kitchen/berkshelf/default",
+        "cookbooks": {"nginx": {"version": "12.0.1", "source": "https://supermarket.chef.io"}}
+      }
+    }
+  }
+
+ERB in the kitchen file is ignored rather than evaluated.
+
+Examples:
+  berks kitchen-prepare                  # Prepare every suite
+  berks kitchen-prepare --suite default  # Prepare only the default suite`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format := viper.GetString("format")
+		if err := checkFormat(format, "text", "json"); err != nil {
+			return err
+		}
+
+		result := newResult("kitchen-prepare", format)
+		return result.Write(os.Stdout, runKitchenPrepare(cmd, result))
+	},
+}
+
+// runKitchenPrepare vendors each suite's cookbooks and writes the kitchen
+// manifest, recording the outcome in result (which may be nil)
+func runKitchenPrepare(cmd *cobra.Command, result *Result) error {
+	if viper.GetBool("install") {
+		if err := runInstall(cmd, nil, nil); err != nil {
+			return fmt.Errorf("failed to run install command: %w", err)
+		}
+	}
+
+	bf, err := LoadBerksfile()
+	if err != nil {
+		return err
+	}
+	lockFile, lockManager, err := LoadLockFile()
+	if err != nil {
+		return fmt.Errorf("no lock file found. Run 'berks install' first: %w", err)
+	}
+	sourceManager, err := CreateSourceManager(bf)
+	if err != nil {
+		return err
+	}
+	mappings, err := loadSuiteMappings(bf)
+	if err != nil {
+		return err
+	}
+
+	suites := viper.GetStringSlice("suite")
+	for _, suite := range suites {
+		if !slices.ContainsFunc(mappings, func(m kitchen.Mapping) bool { return m.Suite == suite }) {
+			return fmt.Errorf("no suite %q in the kitchen file (see 'berks suites')", suite)
+		}
+	}
+
+	manifest := &kitchen.Manifest{
+		GeneratedAt: time.Now().UTC(),
+		LockFile:    filepath.Base(lockManager.GetPath()),
+		Suites:      make(map[string]*kitchen.SuiteCookbooks),
+	}
+	start := time.Now()
+	for _, mapping := range mappings {
+		if len(suites) > 0 && !slices.Contains(suites, mapping.Suite) {
+			continue
+		}
+		if len(mapping.Undeclared) > 0 {
+			log.Warnf("Suite %s uses cookbook(s) the Berksfile does not declare: %s (see 'berks suites --stubs')",
+				mapping.Suite, strings.Join(mapping.Undeclared, ", "))
+			result.Warn("suite %s uses undeclared cookbook(s): %s", mapping.Suite, strings.Join(mapping.Undeclared, ", "))
+		}
+
+		var cookbooks []string
+		if len(mapping.Cookbooks) > 0 {
+			for _, name := range vendor.FindTransitiveDependencies(lockFile, mapping.Cookbooks) {
+				if lockFile.HasCookbook(name) {
+					cookbooks = append(cookbooks, name)
+				}
+			}
+			if len(cookbooks) == 0 {
+				return fmt.Errorf("no locked cookbooks are converged by suite %s", mapping.Suite)
+			}
+		}
+
+		dir := kitchen.SuiteDir(mapping.Suite)
+		log.Infof("Preparing suite %s in %s", mapping.Suite, dir)
+		options := vendor.Options{
+			TargetPath:    dir,
+			Delete:        true,
+			OnlyCookbooks: cookbooks,
+			KeepGoing:     viper.GetBool("keep-going"),
+			Factory:       newSourceFactory(),
+		}
+		vendorResult, err := vendorWithHooks(cmd.Context(), vendor.New(lockFile, sourceManager, options), lockFile, options)
+		if err != nil {
+			return fmt.Errorf("failed to prepare suite %s: %w", mapping.Suite, err)
+		}
+		if len(vendorResult.FailedDownloads) > 0 {
+			for name, errMsg := range vendorResult.FailedDownloads {
+				log.Errorf("  - %s: %s", name, errMsg)
+			}
+			return fmt.Errorf("failed to vendor %d cookbook(s) for suite %s", len(vendorResult.FailedDownloads), mapping.Suite)
+		}
+		if len(vendorResult.Collisions) > 0 {
+			return fmt.Errorf("%d vendor path collision(s) in %s", len(vendorResult.Collisions), dir)
+		}
+
+		prepared := &kitchen.SuiteCookbooks{Path: dir, Cookbooks: make(map[string]kitchen.PreparedCookbook)}
+		for name, locked := range lockFile.ListCookbooks() {
+			if len(cookbooks) > 0 && !slices.Contains(cookbooks, name) {
+				continue
+			}
+			_, sourceKey, _ := lockFile.GetCookbook(name)
+			prepared.Cookbooks[name] = kitchen.PreparedCookbook{Version: locked.Version, Source: sourceKey}
+		}
+		manifest.Suites[mapping.Suite] = prepared
+		result.Act("prepared", mapping.Suite, dir)
+	}
+	result.Phase("vendor", start)
+
+	if err := manifest.Save(kitchen.ManifestFile); err != nil {
+		return err
+	}
+	log.Infof("Prepared %d suite(s), see %s", len(manifest.Suites), kitchen.ManifestFile)
+	result.Act("wrote_manifest", "", kitchen.ManifestFile)
+	return nil
+}
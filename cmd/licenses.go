@@ -0,0 +1,187 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/bdwyertech/go-berkshelf/internal/config"
+	"github.com/bdwyertech/go-berkshelf/pkg/license"
+	"github.com/bdwyertech/go-berkshelf/pkg/ui"
+)
+
+func init() {
+	rootCmd.AddCommand(licensesCmd)
+
+	licensesCmd.Flags().StringP("format", "f", "table", "Output format (table, json, spdx)")
+	licensesCmd.Flags().Bool("summary", false, "Group the table by license")
+	licensesCmd.Flags().StringSlice("allow", nil, "Only accept these licenses (overrides licenses.allow in config)")
+	licensesCmd.Flags().StringSlice("deny", nil, "Never accept these licenses (overrides licenses.deny in config)")
+
+	licensesCmd.ValidArgsFunction = completeCookbookNames
+	registerFormatCompletion(licensesCmd, "table", "json", "spdx")
+}
+
+var licensesCmd = &cobra.Command{
+	Use:   "licenses [COOKBOOK...]",
+	Short: "Report the licenses of locked cookbooks",
+	Long: `Collect the license declared in the metadata of each cookbook in the lock
+file and report it with its SPDX identifier. Common spellings such as
+"Apache 2.0" are mapped to their identifier; cookbooks declaring no license
+are reported as NOASSERTION.
+
+Licenses are checked against licenses.allow and licenses.deny in the
+berkshelf config, or --allow and --deny. A denied license, or one missing
+from a non-empty allowlist, is forbidden and fails the command, which makes
+it suitable for CI. SPDX expressions such as "MIT OR Apache-2.0" are accepted
+when any alternative is.
+
+The spdx format writes an SPDX 2.3 tag-value document.
+
+Examples:
+  berks licenses                              # Table of every locked cookbook
+  berks licenses --summary                    # Cookbooks grouped by license
+  berks licenses --deny GPL-3.0-only,AGPL-3.0-only
+  berks licenses --allow Apache-2.0,MIT       # Fail on anything else
+  berks licenses --format spdx > licenses.spdx`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format := strings.ToLower(viper.GetString("format"))
+		if err := checkFormat(format, "table", "json", "spdx"); err != nil {
+			return err
+		}
+
+		result := newResult("licenses", format)
+		err := runLicenses(cmd, args, format, result)
+		if err != nil {
+			cmd.SilenceUsage = true
+		}
+		return result.Write(os.Stdout, err)
+	},
+}
+
+func runLicenses(cmd *cobra.Command, args []string, format string, result *Result) error {
+	bf, err := LoadBerksfile()
+	if err != nil {
+		return err
+	}
+	lockFile, manager, err := LoadLockFile()
+	if err != nil {
+		return err
+	}
+	if !manager.Exists() {
+		return fmt.Errorf("no lock file found. Run 'berks install' first")
+	}
+	sourceManager, err := CreateSourceManager(bf)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	policy := license.Policy{Allow: cfg.Licenses.GetAllow(), Deny: cfg.Licenses.GetDeny()}
+	if allow := viper.GetStringSlice("allow"); len(allow) > 0 {
+		policy.Allow = allow
+	}
+	if deny := viper.GetStringSlice("deny"); len(deny) > 0 {
+		policy.Deny = deny
+	}
+
+	start := time.Now()
+	entries, err := license.Collect(cmd.Context(), lockFile, sourceManager, args)
+	result.Phase("collect", start)
+	if err != nil {
+		return fmt.Errorf("failed to collect licenses: %w", err)
+	}
+	forbidden := policy.Apply(entries)
+
+	for _, entry := range entries {
+		result.AddCookbook(ResultCookbook{Name: entry.Cookbook, Version: entry.Version, Source: entry.Location})
+		result.Act("license", entry.Cookbook, entry.SPDX)
+		if entry.Forbidden {
+			result.Warn("%s (%s): %s", entry.Cookbook, entry.Version, entry.Reason)
+		}
+	}
+
+	switch {
+	case format == "spdx":
+		dir, _ := os.Getwd()
+		err = license.WriteSPDX(os.Stdout, license.Document{
+			Name:      filepath.Base(dir),
+			Namespace: "https://spdx.org/spdxdocs/berks-" + filepath.Base(dir) + "-" + strconv.FormatInt(time.Now().Unix(), 10),
+		}, entries)
+	case result != nil:
+	case viper.GetBool("summary"):
+		err = outputLicenseSummary(entries)
+	default:
+		err = outputLicenseTable(entries)
+	}
+	if err != nil {
+		return err
+	}
+
+	if forbidden > 0 {
+		return &exitError{code: 1, err: fmt.Errorf("%d cookbook(s) have forbidden licenses", forbidden)}
+	}
+	return nil
+}
+
+// outputLicenseTable prints one row per cookbook
+func outputLicenseTable(entries []license.Entry) error {
+	if len(entries) == 0 {
+		fmt.Println("No cookbooks found.")
+		return nil
+	}
+
+	table := newTable("COOKBOOK", "VERSION", "LICENSE", "SPDX", "STATUS")
+	for _, entry := range entries {
+		table.Append(entry.Cookbook, entry.Version, entry.License, entry.SPDX, licenseStatus(entry))
+	}
+	return table.Render(os.Stdout)
+}
+
+// outputLicenseSummary prints one row per license with its cookbooks
+func outputLicenseSummary(entries []license.Entry) error {
+	byLicense := make(map[string][]license.Entry)
+	for _, entry := range entries {
+		byLicense[entry.SPDX] = append(byLicense[entry.SPDX], entry)
+	}
+	licenses := make([]string, 0, len(byLicense))
+	for id := range byLicense {
+		licenses = append(licenses, id)
+	}
+	// Most used first
+	sort.Slice(licenses, func(i, j int) bool {
+		if len(byLicense[licenses[i]]) != len(byLicense[licenses[j]]) {
+			return len(byLicense[licenses[i]]) > len(byLicense[licenses[j]])
+		}
+		return licenses[i] < licenses[j]
+	})
+
+	opts := tableOptions()
+	opts.Unsorted = true
+	table := ui.NewTable(opts, "LICENSE", "COUNT", "COOKBOOKS", "STATUS")
+	for _, id := range licenses {
+		var names []string
+		for _, entry := range byLicense[id] {
+			names = append(names, entry.Cookbook)
+		}
+		table.Append(id, strconv.Itoa(len(names)), strings.Join(names, ", "), licenseStatus(byLicense[id][0]))
+	}
+	return table.Render(os.Stdout)
+}
+
+func licenseStatus(entry license.Entry) string {
+	if entry.Forbidden {
+		return "forbidden: " + entry.Reason
+	}
+	return "ok"
+}
@@ -6,8 +6,6 @@ import (
 	"sort"
 	"strings"
 
-	"github.com/olekukonko/tablewriter"
-	"github.com/olekukonko/tablewriter/tw"
 	"github.com/spf13/cobra"
 )
 
@@ -37,6 +35,9 @@ func init() {
 
 	// Add flags
 	listCmd.Flags().StringVarP(&listFormat, "format", "f", "table", "Output format (table, json)")
+
+	listCmd.ValidArgsFunction = completeCookbookNames
+	registerFormatCompletion(listCmd, "table", "json")
 }
 
 type CookbookListItem struct {
@@ -152,13 +153,7 @@ func outputTable(cookbooks []CookbookListItem) error {
 	}
 
 	// Print status information
-	table := tablewriter.NewTable(os.Stdout)
-	table.Configure(func(config *tablewriter.Config) {
-		config.Row.Alignment.Global = tw.AlignLeft
-	})
-	table.Header("COOKBOOK", "VERSION", "SOURCE")
-
-	data := [][]any{}
+	table := newTable("COOKBOOK", "VERSION", "SOURCE")
 	for _, cookbook := range cookbooks {
 		version := cookbook.Version
 		if version == "" {
@@ -168,9 +163,8 @@ func outputTable(cookbooks []CookbookListItem) error {
 		if source == "" {
 			source = "(local)"
 		}
-		data = append(data, []any{cookbook.Name, version, source})
+		table.Append(cookbook.Name, version, source)
 	}
 
-	table.Bulk(data)
-	return table.Render()
+	return table.Render(os.Stdout)
 }
@@ -0,0 +1,207 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berksfile"
+	"github.com/bdwyertech/go-berkshelf/pkg/lockfile"
+)
+
+func init() {
+	rootCmd.AddCommand(lockCmd)
+	lockCmd.AddCommand(lockPathCmd, lockDiffCmd)
+
+	lockPathCmd.Flags().Bool("ruby", false, "Print the Ruby Berkshelf compatible Berksfile.lock instead")
+
+	lockDiffCmd.Flags().Bool("against-resolve", false, "Compare against a fresh resolution of the Berksfile instead of a second lock file")
+	lockDiffCmd.Flags().StringSliceP("only", "o", nil, "Only resolve cookbooks in specified groups, with --against-resolve")
+	lockDiffCmd.Flags().StringSliceP("except", "e", nil, "Resolve all cookbooks except those in specified groups, with --against-resolve")
+	lockDiffCmd.Flags().StringP("format", "f", "table", "Output format (table, json)")
+	registerFormatCompletion(lockDiffCmd, "table", "json")
+}
+
+var lockCmd = &cobra.Command{
+	Use:   "lock",
+	Short: "Query the lock file",
+}
+
+var lockPathCmd = &cobra.Command{
+	Use:   "path",
+	Short: "Print the location of the lock file",
+	Long: `Print the absolute path of the lock file install and update write for
+the Berksfile in the current directory, whether or not it exists yet. The
+lock file is Berksfile.go.lock unless lockfile_name is set in the config.
+
+Examples:
+  berks lock path          # Berksfile.go.lock
+  berks lock path --ruby   # Berksfile.lock`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, manager, _ := LoadLockFile()
+		if viper.GetBool("ruby") {
+			return printPath(manager.GetRubyPath())
+		}
+		return printPath(manager.GetPath())
+	},
+}
+
+var lockDiffCmd = &cobra.Command{
+	Use:   "diff [OLD [NEW]]",
+	Short: "Compare two lock files",
+	Long: `Show the cookbooks added, removed, upgraded or downgraded between two lock
+files, and the cookbooks whose source changed, such as a git cookbook locked
+to another revision. NEW defaults to the project lock file.
+
+With --against-resolve, OLD is compared against a fresh resolution of the
+Berksfile, which is not written, to preview what 'berks install' would
+change. OLD then defaults to the project lock file.
+
+Examples:
+  git show main:Berksfile.go.lock > /tmp/main.lock
+  berks lock diff /tmp/main.lock
+  berks lock diff old/Berksfile.go.lock new/Berksfile.go.lock --format json
+  berks lock diff --against-resolve`,
+	Args: cobra.MaximumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format := strings.ToLower(viper.GetString("format"))
+		if err := checkFormat(format, "table", "json"); err != nil {
+			return err
+		}
+		againstResolve := viper.GetBool("against-resolve")
+		if againstResolve && len(args) > 1 {
+			return fmt.Errorf("--against-resolve compares a single lock file, got %d", len(args))
+		}
+		if !againstResolve && len(args) == 0 {
+			return fmt.Errorf("a lock file to compare is required, or use --against-resolve")
+		}
+		cmd.SilenceUsage = true
+
+		var base, head *lockfile.LockFile
+		var err error
+		switch {
+		case againstResolve && len(args) == 0:
+			// A project without a lock file yet adds every cookbook
+			if base, _, err = LoadLockFile(); err != nil {
+				return err
+			}
+		default:
+			if base, err = readLockFile(args[0]); err != nil {
+				return err
+			}
+		}
+		switch {
+		case againstResolve:
+			if head, err = resolveLockFile(cmd.Context()); err != nil {
+				return err
+			}
+		case len(args) == 2:
+			if head, err = readLockFile(args[1]); err != nil {
+				return err
+			}
+		default:
+			if head, err = readLockFile(newLockManager(projectDir()).GetPath()); err != nil {
+				return err
+			}
+		}
+
+		changes := lockfile.Diff(base, head)
+		if format == "json" {
+			if changes == nil {
+				changes = []lockfile.Change{}
+			}
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(changes)
+		}
+		return printLockChanges(changes)
+	},
+}
+
+// readLockFile reads the lock file at path
+func readLockFile(path string) (*lockfile.LockFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lock file: %w", err)
+	}
+	lockFile, err := lockfile.FromJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return lockFile, nil
+}
+
+// resolveLockFile resolves the Berksfile as install does and returns the
+// lock file it would write, without writing it
+func resolveLockFile(ctx context.Context) (*lockfile.LockFile, error) {
+	berks, err := LoadBerksfile()
+	if err != nil {
+		return nil, err
+	}
+	lockManager := newLockManager(projectDir())
+
+	only, except := viper.GetStringSlice("only"), viper.GetStringSlice("except")
+	cookbooks := berksfile.FilterCookbooksByGroup(berks.Cookbooks, only, except)
+	cookbooks = berksfile.ApplyGroupSources(cookbooks, loadGroupSources())
+
+	metadataCookbooks, err := berks.MetadataCookbooks(projectDir())
+	if err != nil {
+		return nil, err
+	}
+	requirements := CreateRequirementsFromCookbooks(slices.Concat(cookbooks, metadataCookbooks))
+
+	chefVersion, err := detectChefVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	overrides, err := resolutionOverrides(berks, lockManager)
+	if err != nil {
+		return nil, err
+	}
+
+	sourceManager, err := SetupSourcesFromBerksfile(berks)
+	if err != nil {
+		return nil, err
+	}
+	sources := sourceManager.GetSources()
+	if !viper.GetBool("no-cache") {
+		sources = withVersionCache(sources)
+	}
+
+	resolution, err := ResolveDependencies(ctx, requirements, overrides, berks.Ignored, sources, nil, chefVersion, nil)
+	if err != nil {
+		return nil, err
+	}
+	lockFile, err := lockManager.Generate(resolution)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate lock file: %w", err)
+	}
+	return lockFile, nil
+}
+
+// printLockChanges prints the lock file changes as a table
+func printLockChanges(changes []lockfile.Change) error {
+	if len(changes) == 0 {
+		fmt.Println("No changes.")
+		return nil
+	}
+
+	table := newTable("COOKBOOK", "CHANGE", "FROM", "TO", "SOURCE")
+	for _, change := range changes {
+		source := change.ToSource
+		if change.FromSource != "" && change.ToSource != "" {
+			source = change.FromSource + " -> " + change.ToSource
+		} else if source == "" {
+			source = change.FromSource
+		}
+		table.Append(change.Cookbook, string(change.Type), change.From, change.To, source)
+	}
+	return table.Render(os.Stdout)
+}
@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/bdwyertech/go-berkshelf/internal/config"
+	"github.com/bdwyertech/go-berkshelf/pkg/metrics"
+)
+
+// otlpExportTimeout bounds the export of metrics at exit, so an unreachable
+// collector does not hold up the command
+const otlpExportTimeout = 10 * time.Second
+
+// registry aggregates the metrics of this run, when an exporter is configured
+var registry *metrics.Registry
+
+// startMetrics starts recording metrics when the config enables an exporter
+func startMetrics() {
+	cfg, err := config.Load()
+	if err != nil || !cfg.GetMetrics().Enabled() {
+		return
+	}
+	registry = metrics.NewRegistry()
+	metrics.Set(registry)
+}
+
+// exportMetrics writes the metrics recorded during the run to the
+// configured exporters. Failures are logged, as telemetry must not fail the
+// command.
+func exportMetrics() {
+	if registry == nil {
+		return
+	}
+	metrics.Set(nil)
+	cfg, err := config.Load()
+	if err != nil {
+		log.Warnf("Failed to load config, metrics not exported: %v", err)
+		return
+	}
+	settings := cfg.GetMetrics()
+
+	if path := settings.GetPrometheusFile(); path != "" {
+		if err := writePrometheusFile(path); err != nil {
+			log.Warnf("Failed to write metrics to %s: %v", path, err)
+		} else {
+			log.Debugf("Wrote metrics to %s", path)
+		}
+	}
+
+	if settings.GetOTLP() {
+		endpoint := settings.GetOTLPEndpoint()
+		ctx, cancel := context.WithTimeout(context.Background(), otlpExportTimeout)
+		defer cancel()
+		if err := registry.ExportOTLP(ctx, endpoint, otlpHeaders()); err != nil {
+			log.Warnf("Failed to export metrics to %s: %v", endpoint, err)
+		} else {
+			log.Debugf("Exported metrics to %s", endpoint)
+		}
+	}
+}
+
+// writePrometheusFile replaces path with the metrics in the Prometheus text
+// format. The file is renamed into place so a collector reading it never
+// sees it half written.
+func writePrometheusFile(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := registry.WritePrometheus(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// otlpHeaders returns the headers to send to the collector, from the
+// standard OTEL_EXPORTER_OTLP_METRICS_HEADERS or OTEL_EXPORTER_OTLP_HEADERS
+// list of key=value pairs
+func otlpHeaders() map[string]string {
+	list := os.Getenv("OTEL_EXPORTER_OTLP_METRICS_HEADERS")
+	if list == "" {
+		list = os.Getenv("OTEL_EXPORTER_OTLP_HEADERS")
+	}
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(list, ",") {
+		key, value, ok := strings.Cut(pair, "=")
+		if key = strings.TrimSpace(key); ok && key != "" {
+			headers[key] = strings.TrimSpace(value)
+		}
+	}
+	return headers
+}
@@ -0,0 +1,193 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	"github.com/bdwyertech/go-berkshelf/pkg/mirror"
+	"github.com/bdwyertech/go-berkshelf/pkg/publish"
+	"github.com/bdwyertech/go-berkshelf/pkg/source"
+)
+
+func init() {
+	rootCmd.AddCommand(mirrorCmd)
+
+	mirrorCmd.Flags().String("from", "supermarket", "Origin Supermarket URL, or 'supermarket' for the public Supermarket")
+	mirrorCmd.Flags().String("to", "", "Target Supermarket or Artifactory Chef repository URL")
+	mirrorCmd.Flags().String("manifest", mirror.DefaultManifestFile, "Manifest of synced cookbooks, used to resume an interrupted mirror")
+	mirrorCmd.Flags().String("category", publish.DefaultCategory, "Supermarket category for published cookbooks")
+	mirrorCmd.Flags().String("client-name", "", "Target Supermarket user name (defaults to the configured node name)")
+	mirrorCmd.Flags().String("client-key", "", "Path to the target Supermarket user's private key (defaults to the configured client key)")
+	mirrorCmd.Flags().String("api-key", "", "Target Artifactory API key, or an env:/keychain: reference to one")
+	mirrorCmd.Flags().Bool("dry-run", false, "Download and package cookbooks without publishing them")
+	mirrorCmd.Flags().String("format", "text", "Output format (text, json)")
+
+	registerFormatCompletion(mirrorCmd, "text", "json")
+}
+
+var mirrorCmd = &cobra.Command{
+	Use:   "mirror [LIST_FILE]",
+	Short: "Copy cookbooks from one Supermarket to another",
+	Long: `Copy pinned cookbook versions from an origin Supermarket to a private
+Supermarket or Artifactory Chef repository, for example to seed an air-gapped
+environment.
+
+The cookbooks are read from LIST_FILE, one per line as "name" or
+"name version" (unpinned cookbooks use the latest version on the origin), or
+from a lock file. Git and path cookbooks in a lock file are skipped.
+
+Each synced version is recorded in a manifest as soon as it is published, so
+an interrupted mirror resumes where it stopped. Versions the target already
+has are recorded as present rather than failing.
+
+Examples:
+  berks mirror --to https://supermarket.example.com
+  berks mirror cookbooks.txt --from supermarket --to https://artifactory.example.com/artifactory/api/chef/chef-local
+  berks mirror --lockfile app/Berksfile.go.lock --to https://supermarket.example.com --manifest sync.json
+  berks mirror --to https://supermarket.example.com --dry-run   # Download and package only`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format := viper.GetString("format")
+		if err := checkFormat(format, "text", "json"); err != nil {
+			return err
+		}
+
+		result := newResult("mirror", format)
+		return result.Write(os.Stdout, runMirror(cmd, args, result))
+	},
+}
+
+func runMirror(cmd *cobra.Command, args []string, result *Result) error {
+	from := viper.GetString("from")
+	if strings.EqualFold(from, "supermarket") {
+		from = source.PUBLIC_SUPERMARKET
+	}
+	to := viper.GetString("to")
+	if to == "" {
+		return fmt.Errorf("--to is required")
+	}
+
+	items, err := mirrorItems(args, result)
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		log.Info("Nothing to mirror.")
+		return nil
+	}
+
+	origin, err := newSourceFactory().CreateFromLocation(&berkshelf.SourceLocation{Type: "supermarket", URL: from})
+	if err != nil {
+		return fmt.Errorf("failed to create source for %s: %w", from, err)
+	}
+
+	options := mirror.Options{
+		Publish: publish.Options{
+			URL:       to,
+			Category:  viper.GetString("category"),
+			DryRun:    viper.GetBool("dry-run"),
+			Algorithm: checksumAlgorithm(),
+		},
+	}
+	if !options.Publish.DryRun {
+		auth, err := newPublishAuth(to)
+		if err != nil {
+			return err
+		}
+		options.Publish.Auth = auth
+	}
+
+	manifest, err := mirror.LoadManifest(viper.GetString("manifest"), from, to)
+	if err != nil {
+		return err
+	}
+	options.Manifest = manifest
+
+	log.Infof("Mirroring %d cookbook(s) from %s to %s...", len(items), from, to)
+	start := time.Now()
+	mirrored, err := mirror.New(origin, options).Mirror(cmd.Context(), items)
+	result.Phase("mirror", start)
+	if mirrored != nil {
+		reportMirror(mirrored, options.Publish.DryRun, result)
+	}
+	if err != nil {
+		return fmt.Errorf("mirror interrupted: %w", err)
+	}
+
+	if len(mirrored.Failed) > 0 {
+		return fmt.Errorf("%d cookbook(s) failed to mirror", len(mirrored.Failed))
+	}
+	if !options.Publish.DryRun {
+		log.Infof("Manifest written to %s", manifest.Path())
+	}
+	return nil
+}
+
+// mirrorItems reads the cookbooks to mirror from the list file argument, or
+// from the lock file
+func mirrorItems(args []string, result *Result) ([]mirror.Item, error) {
+	if len(args) > 0 {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to read cookbook list: %w", err)
+		}
+		defer f.Close()
+		items, err := mirror.ParseList(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", args[0], err)
+		}
+		return items, nil
+	}
+
+	manager := newLockManager(projectDir())
+	if !manager.Exists() {
+		return nil, fmt.Errorf("no lock file found at %s; run 'berks install' or pass a cookbook list", manager.GetPath())
+	}
+	lockFile, err := manager.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	items, skipped := mirror.ItemsFromLockFile(lockFile)
+	for _, name := range skipped {
+		result.Warn("skipping %s: git and path cookbooks cannot be mirrored", name)
+		log.Warnf("Skipping %s: git and path cookbooks cannot be mirrored", name)
+	}
+	return items, nil
+}
+
+// reportMirror logs and records the outcome of each cookbook
+func reportMirror(mirrored *mirror.Result, dryRun bool, result *Result) {
+	for _, entry := range mirrored.Synced {
+		result.AddCookbook(ResultCookbook{Name: entry.Name, Version: entry.Version})
+		switch {
+		case dryRun:
+			result.Act("would_publish", entry.Name, entry.Version)
+			log.Infof("Would publish %s (%s)", entry.Name, entry.Version)
+		case entry.Status == mirror.StatusPresent:
+			result.Act("present", entry.Name, entry.Version)
+			log.Infof("Already present %s (%s)", entry.Name, entry.Version)
+		default:
+			result.Act("published", entry.Name, entry.Version)
+			log.Infof("Published %s (%s)", entry.Name, entry.Version)
+		}
+	}
+	for _, item := range mirrored.Skipped {
+		result.Act("skipped", item.Name, item.Version)
+		log.Debugf("Skipped %s (%s), already in the manifest", item.Name, item.Version)
+	}
+	if len(mirrored.Skipped) > 0 {
+		log.Infof("Skipped %d cookbook(s) already in the manifest", len(mirrored.Skipped))
+	}
+	for _, failure := range mirrored.Failed {
+		result.Warn("%s: %v", failure.Item, failure.Error)
+		log.Errorf("Failed to mirror %s: %v", failure.Item, failure.Error)
+	}
+}
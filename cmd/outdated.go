@@ -1,21 +1,21 @@
 package cmd
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"os"
+	"slices"
 	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
 	"github.com/bdwyertech/go-berkshelf/pkg/berksfile"
-	"github.com/bdwyertech/go-berkshelf/pkg/lockfile"
+	"github.com/bdwyertech/go-berkshelf/pkg/changelog"
 	"github.com/bdwyertech/go-berkshelf/pkg/outdated"
+	"github.com/bdwyertech/go-berkshelf/pkg/owners"
 	"github.com/bdwyertech/go-berkshelf/pkg/source"
 
-	"github.com/olekukonko/tablewriter"
-	"github.com/olekukonko/tablewriter/tw"
-
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -25,6 +25,13 @@ func init() {
 
 	// Add flags
 	outdatedCmd.Flags().StringP("format", "f", "table", "Output format (table, json)")
+	outdatedCmd.Flags().Bool("no-cache", false, "Query sources even if a cookbook was checked recently")
+	outdatedCmd.Flags().Bool("fix", false, "Rewrite the Berksfile to use the replacements of deprecated cookbooks")
+	outdatedCmd.Flags().String("owner", "", "Only report cookbooks owned by this team")
+	outdatedCmd.Flags().Bool("changelog", false, "Show the changelog entries between the locked and latest versions")
+
+	outdatedCmd.ValidArgsFunction = completeCookbookNames
+	registerFormatCompletion(outdatedCmd, "table", "json")
 }
 
 var outdatedCmd = &cobra.Command{
@@ -36,89 +43,267 @@ This command compares the versions in your lock file with the latest
 available versions from configured sources and shows which cookbooks
 can be updated.
 
+Each cookbook is checked against remote sources at most once per
+min_check_interval (15 minutes by default); pass --no-cache to check now.
+
+Deprecated cookbooks are listed with a suggested migration to the
+replacement their maintainers declared, following chains of deprecated
+replacements. With --fix the Berksfile is rewritten to use the
+replacements; run 'berks install' afterwards to update the lock file.
+
+When cookbook owners are configured (see 'berks owners'), each cookbook is
+reported with its owners and --owner limits the report to one team.
+
+With --changelog, the CHANGELOG.md of each outdated cookbook is fetched from
+its source repository, the Supermarket source_url or the git repository it is
+locked to, and the entries after the locked version up to the latest are
+shown. GitHub and GitLab changelogs are downloaded directly; other
+repositories are cloned.
+
 Examples:
   berks outdated           # Show all outdated cookbooks
+  berks outdated --no-cache  # Ignore recent checks and query sources
+  berks outdated --fix     # Replace deprecated cookbooks in the Berksfile
   berks outdated nginx     # Check if nginx is outdated
-  berks outdated --format json  # Output as JSON`,
+  berks outdated --owner @web-team  # Only cookbooks the web team owns
+  berks outdated --changelog  # Show what changed in each upgrade
+  berks outdated --format json  # Output a JSON result document`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		// Check if Berksfile exists
-		if _, err := os.Stat("Berksfile"); os.IsNotExist(err) {
-			return fmt.Errorf("no Berksfile found in current directory")
+		outdatedFormat := strings.ToLower(viper.GetString("format"))
+		if err := checkFormat(outdatedFormat, "table", "json"); err != nil {
+			return err
 		}
 
-		// Parse Berksfile
-		bf, err := berksfile.Load("Berksfile")
+		bf, err := LoadBerksfile()
 		if err != nil {
-			return fmt.Errorf("failed to parse Berksfile: %w", err)
+			return err
 		}
 
 		// Load lock file
-		manager := lockfile.NewManager(".")
+		manager := newLockManager(projectDir())
 		lockFile, err := manager.Load()
 		if err != nil {
 			return fmt.Errorf("no lock file found. Run 'berks install' first: %w", err)
 		}
 
+		cookbookOwners, err := loadOwners()
+		if err != nil {
+			return err
+		}
+
 		// Create source manager
-		factory := source.NewFactory()
+		factory := newSourceFactory()
 		sourceManager, err := factory.CreateFromBerksfile(bf)
 		if err != nil {
 			return fmt.Errorf("failed to create source manager: %w", err)
 		}
 
+		if !viper.GetBool("no-cache") {
+			cached := source.NewManager()
+			for _, src := range withVersionCache(sourceManager.GetSources()) {
+				cached.AddSource(src)
+			}
+			sourceManager = cached
+		}
+
 		log.Infoln("Checking for outdated cookbooks...")
 
 		// Create outdated checker
 		checker := outdated.New(lockFile, sourceManager)
 
 		// Check for outdated cookbooks
+		result := newResult("outdated", outdatedFormat)
+		checkStart := time.Now()
 		outdatedCookbooks, err := checker.Check(cmd.Context(), args)
+		result.Phase("check", checkStart)
 		if err != nil {
-			return fmt.Errorf("failed to check for outdated cookbooks: %w", err)
+			return result.Write(os.Stdout, fmt.Errorf("failed to check for outdated cookbooks: %w", err))
+		}
+
+		deprecationStart := time.Now()
+		migrations, err := checker.Migrations(cmd.Context(), args)
+		result.Phase("deprecations", deprecationStart)
+		if err != nil {
+			return result.Write(os.Stdout, fmt.Errorf("failed to check for deprecated cookbooks: %w", err))
+		}
+
+		if owner := viper.GetString("owner"); owner != "" {
+			outdatedCookbooks = slices.DeleteFunc(outdatedCookbooks, func(c outdated.Cookbook) bool {
+				return !ownedBy(cookbookOwners, c.Name, owner)
+			})
+			migrations = slices.DeleteFunc(migrations, func(m outdated.Migration) bool {
+				return !ownedBy(cookbookOwners, m.Name, owner)
+			})
+		}
+
+		var changelogs map[string]*outdated.Changelog
+		if viper.GetBool("changelog") {
+			changelogStart := time.Now()
+			changelogs = fetchChangelogs(cmd.Context(), checker, outdatedCookbooks, result)
+			result.Phase("changelogs", changelogStart)
+		}
+
+		if viper.GetBool("fix") {
+			if err := applyMigrations(berksfilePath, migrations, result); err != nil {
+				return result.Write(os.Stdout, err)
+			}
 		}
 
 		// Output results
+		if result != nil {
+			for _, cookbook := range outdatedCookbooks {
+				result.AddCookbook(ResultCookbook{
+					Name:      cookbook.Name,
+					Version:   cookbook.CurrentVersion,
+					Latest:    cookbook.LatestVersion,
+					Source:    cookbook.Source,
+					Owners:    cookbookOwners.For(cookbook.Name),
+					Changelog: changelogs[cookbook.Name],
+				})
+			}
+			addMigrations(result, migrations, cookbookOwners)
+			return result.Write(os.Stdout, nil)
+		}
+
 		if len(outdatedCookbooks) == 0 {
 			fmt.Println("All cookbooks are up to date!")
-			return nil
+		} else if err := outputOutdatedTable(outdatedCookbooks, cookbookOwners); err != nil {
+			return err
 		}
+		outputChangelogs(outdatedCookbooks, changelogs)
 
-		switch outdatedFormat := strings.ToLower(viper.GetString("format")); outdatedFormat {
-		case "json":
-			return outputOutdatedJSON(outdatedCookbooks)
-		case "table":
-			return outputOutdatedTable(outdatedCookbooks)
-		default:
-			return fmt.Errorf("unsupported format: %s (supported: table, json)", outdatedFormat)
+		if len(migrations) > 0 {
+			return outputMigrationTable(migrations, cookbookOwners)
 		}
+		return nil
 	},
 }
 
-func outputOutdatedJSON(cookbooks []outdated.Cookbook) error {
-	encoder := json.NewEncoder(os.Stdout)
-	encoder.SetIndent("", "  ")
-	return encoder.Encode(cookbooks)
+// fetchChangelogs fetches the changelog of each outdated cookbook, by name.
+// Cookbooks without one are warned about and left out.
+func fetchChangelogs(ctx context.Context, checker *outdated.Checker, cookbooks []outdated.Cookbook, result *Result) map[string]*outdated.Changelog {
+	fetcher := changelog.NewFetcher(nil)
+	changelogs := make(map[string]*outdated.Changelog, len(cookbooks))
+	for _, cookbook := range cookbooks {
+		notes, err := checker.Changelog(ctx, cookbook, fetcher)
+		if err != nil {
+			log.Warnf("No changelog for %s: %v", cookbook.Name, err)
+			result.Warn("no changelog for %s: %v", cookbook.Name, err)
+			continue
+		}
+		changelogs[cookbook.Name] = notes
+	}
+	return changelogs
 }
 
-func outputOutdatedTable(cookbooks []outdated.Cookbook) error {
-	log.Printf("Found %d outdated cookbook(s):\n\n", len(cookbooks))
+// outputChangelogs prints the changelog entries of each outdated cookbook
+func outputChangelogs(cookbooks []outdated.Cookbook, changelogs map[string]*outdated.Changelog) {
+	for _, cookbook := range cookbooks {
+		notes, ok := changelogs[cookbook.Name]
+		if !ok {
+			continue
+		}
+		fmt.Printf("\n%s %s → %s (%s)\n", cookbook.Name, cookbook.CurrentVersion, cookbook.LatestVersion, notes.Repository)
+		if len(notes.Sections) == 0 {
+			fmt.Println("\nNo changelog entries for these versions.")
+			continue
+		}
+		for _, section := range notes.Sections {
+			fmt.Printf("\n## %s\n", section.Heading)
+			if section.Notes != "" {
+				fmt.Printf("\n%s\n", section.Notes)
+			}
+		}
+	}
+}
+
+// addMigrations marks deprecated cookbooks in the result, adding those that are not outdated
+func addMigrations(result *Result, migrations []outdated.Migration, cookbookOwners *owners.Owners) {
+	for _, m := range migrations {
+		i := slices.IndexFunc(result.Cookbooks, func(c ResultCookbook) bool { return c.Name == m.Name })
+		if i < 0 {
+			result.AddCookbook(ResultCookbook{Name: m.Name, Version: m.CurrentVersion, Owners: cookbookOwners.For(m.Name)})
+			i = len(result.Cookbooks) - 1
+		}
+		result.Cookbooks[i].Deprecated = true
+		result.Cookbooks[i].Replacement = m.Replacement
+		result.Cookbooks[i].Constraint = m.Constraint
+	}
+}
 
-	table := tablewriter.NewTable(os.Stdout)
-	table.Configure(func(config *tablewriter.Config) {
-		config.Row.Alignment.Global = tw.AlignLeft
-	})
-	table.Header("COOKBOOK", "CURRENT", "LATEST", "SOURCE")
+// applyMigrations rewrites the Berksfile at path to use the replacement of each
+// deprecated cookbook it declares. Deprecated cookbooks that are only pulled in
+// as dependencies are reported, since only the cookbooks depending on them can change that.
+func applyMigrations(path string, migrations []outdated.Migration, result *Result) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read Berksfile: %w", err)
+	}
 
-	data := [][]any{}
+	content := string(data)
+	changed := false
+	for _, m := range migrations {
+		if m.Replacement == "" {
+			continue
+		}
+
+		var found bool
+		content, found = berksfile.ReplaceCookbook(content, m.Name, m.Replacement, m.Constraint)
+		if !found {
+			log.Warnf("%s is deprecated but not declared in the Berksfile; update the cookbooks that depend on it", m.Name)
+			result.Warn("%s is deprecated but not declared in the Berksfile", m.Name)
+			continue
+		}
+
+		changed = true
+		log.Infof("Migrating %s", m)
+		result.Act("migrate", m.Name, m.String())
+	}
+
+	if !changed {
+		return nil
+	}
+
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write Berksfile: %w", err)
+	}
+	log.Infof("Updated %s; run 'berks install' to update the lock file", path)
+	return nil
+}
+
+func outputOutdatedTable(cookbooks []outdated.Cookbook, cookbookOwners *owners.Owners) error {
+	log.Printf("Found %d outdated cookbook(s):\n\n", len(cookbooks))
+
+	table := newTable(withOwnersHeader(cookbookOwners, "COOKBOOK", "CURRENT", "LATEST", "SOURCE")...)
 	for _, cookbook := range cookbooks {
-		data = append(data, []any{
+		table.Append(withOwners(cookbookOwners, cookbook.Name,
 			cookbook.Name,
 			cookbook.CurrentVersion,
 			cookbook.LatestVersion,
 			cookbook.Source,
-		})
+		)...)
+	}
+
+	return table.Render(os.Stdout)
+}
+
+func outputMigrationTable(migrations []outdated.Migration, cookbookOwners *owners.Owners) error {
+	log.Printf("Found %d deprecated cookbook(s):\n\n", len(migrations))
+
+	table := newTable(withOwnersHeader(cookbookOwners, "COOKBOOK", "CURRENT", "REPLACEMENT", "CONSTRAINT", "NOTE")...)
+	for _, m := range migrations {
+		var notes []string
+		if len(m.Via) > 0 {
+			notes = append(notes, "via "+strings.Join(m.Via, ", "))
+		}
+		if m.Locked {
+			notes = append(notes, "already locked")
+		}
+		if m.Replacement == "" {
+			notes = append(notes, "no replacement declared")
+		}
+		table.Append(withOwners(cookbookOwners, m.Name, m.Name, m.CurrentVersion, m.Replacement, m.Constraint, strings.Join(notes, "; "))...)
 	}
 
-	table.Bulk(data)
-	return table.Render()
+	return table.Render(os.Stdout)
 }
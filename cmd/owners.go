@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/owners"
+)
+
+func init() {
+	rootCmd.AddCommand(ownersCmd)
+
+	ownersCmd.Flags().StringP("format", "f", "table", "Output format (table, json)")
+	ownersCmd.Flags().String("owner", "", "Only list cookbooks owned by this team")
+	ownersCmd.Flags().Bool("unowned", false, "Only list cookbooks with no owner")
+
+	ownersCmd.ValidArgsFunction = completeCookbookNames
+	registerFormatCompletion(ownersCmd, "table", "json")
+}
+
+var ownersCmd = &cobra.Command{
+	Use:   "owners [COOKBOOK...]",
+	Short: "Show which teams own the locked cookbooks",
+	Long: `Show the teams that own each cookbook in the lock file.
+
+Owners are assigned in the owners section of the berkshelf config, mapping
+cookbook names or glob patterns to space or comma separated teams:
+
+  "owners": {"nginx": "@web-team", "acme_*": "@platform @sre"}
+
+or in Berksfile comments, which take precedence over the config:
+
+  # owners: acme_* @platform
+  cookbook 'nginx', '~> 12.0' # owner: @web-team
+
+Exact names win over patterns in the config; in the Berksfile the last
+matching comment wins. Owners are also included in the outdated and audit
+reports, which accept --owner to report on one team's cookbooks.
+
+Examples:
+  berks owners                     # Every locked cookbook and its owners
+  berks owners --owner @web-team   # Cookbooks the web team owns
+  berks owners --unowned           # Cookbooks nobody owns yet
+  berks config set owners.nginx @web-team`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format := strings.ToLower(viper.GetString("format"))
+		if err := checkFormat(format, "table", "json"); err != nil {
+			return err
+		}
+
+		result := newResult("owners", format)
+		rows, err := runOwners(args)
+		if err != nil {
+			cmd.SilenceUsage = true
+			return result.Write(os.Stdout, err)
+		}
+		if result != nil {
+			for _, row := range rows {
+				result.AddCookbook(row)
+			}
+			return result.Write(os.Stdout, nil)
+		}
+		return outputOwnersTable(rows)
+	},
+}
+
+func runOwners(args []string) ([]ResultCookbook, error) {
+	lockFile, manager, err := LoadLockFile()
+	if err != nil {
+		return nil, err
+	}
+	if !manager.Exists() {
+		return nil, fmt.Errorf("no lock file found. Run 'berks install' first")
+	}
+	cookbookOwners, err := loadOwners()
+	if err != nil {
+		return nil, err
+	}
+
+	owner, unowned := viper.GetString("owner"), viper.GetBool("unowned")
+	var rows []ResultCookbook
+	for name, cookbook := range lockFile.ListCookbooks() {
+		if len(args) > 0 && !slices.Contains(args, name) {
+			continue
+		}
+		teams := cookbookOwners.For(name)
+		if (owner != "" && !ownedBy(cookbookOwners, name, owner)) || (unowned && len(teams) > 0) {
+			continue
+		}
+		rows = append(rows, ResultCookbook{Name: name, Version: cookbook.Version, Owners: teams})
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Name < rows[j].Name })
+	return rows, nil
+}
+
+func outputOwnersTable(rows []ResultCookbook) error {
+	if len(rows) == 0 {
+		fmt.Println("No cookbooks found.")
+		return nil
+	}
+
+	table := newTable("COOKBOOK", "VERSION", "OWNERS")
+	for _, row := range rows {
+		table.Append(row.Name, row.Version, formatOwners(row.Owners))
+	}
+	return table.Render(os.Stdout)
+}
+
+// ownedBy reports whether owner owns cookbook, ignoring case and a leading @
+func ownedBy(cookbookOwners *owners.Owners, cookbook, owner string) bool {
+	return slices.ContainsFunc(cookbookOwners.For(cookbook), func(o string) bool {
+		return strings.EqualFold(strings.TrimPrefix(o, "@"), strings.TrimPrefix(owner, "@"))
+	})
+}
+
+// withOwnersHeader appends an OWNERS column to headers when owners are configured
+func withOwnersHeader(cookbookOwners *owners.Owners, headers ...string) []string {
+	if cookbookOwners.Empty() {
+		return headers
+	}
+	return append(headers, "OWNERS")
+}
+
+// withOwners appends the owners of cookbook to a row when owners are configured
+func withOwners(cookbookOwners *owners.Owners, cookbook string, cells ...string) []string {
+	if cookbookOwners.Empty() {
+		return cells
+	}
+	return append(cells, formatOwners(cookbookOwners.For(cookbook)))
+}
+
+func formatOwners(teams []string) string {
+	if len(teams) == 0 {
+		return "-"
+	}
+	return strings.Join(teams, ", ")
+}
@@ -0,0 +1,327 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berksfile"
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	"github.com/bdwyertech/go-berkshelf/pkg/cookbook"
+	berrors "github.com/bdwyertech/go-berkshelf/pkg/errors"
+	"github.com/bdwyertech/go-berkshelf/pkg/policyfile"
+	"github.com/bdwyertech/go-berkshelf/pkg/resolver"
+)
+
+func init() {
+	rootCmd.AddCommand(policyCmd)
+	policyCmd.AddCommand(policyCompileCmd)
+	policyCmd.AddCommand(policyExportCmd)
+
+	// Add flags
+	policyCompileCmd.Flags().String("cookbooks-dir", "", "Keep the compiled cookbooks in this directory (default: a temporary directory, removed afterwards)")
+	policyExportCmd.Flags().Bool("force", false, "Export into a directory that is not empty, replacing a previous export")
+}
+
+var policyCmd = &cobra.Command{
+	Use:   "policy",
+	Short: "Compile Policyfiles",
+}
+
+var policyCompileCmd = &cobra.Command{
+	Use:   "compile [POLICYFILE]",
+	Short: "Resolve a Policyfile and check its run lists",
+	Long: `Resolve the cookbooks of a Policyfile.rb, as 'chef install' would, fetch
+each at the version it resolves to, and check that every recipe the run_list
+and named run lists call for is in it as recipes/NAME.rb. A missing recipe
+would only fail once a node converges, so it fails the compile here, naming
+the version of the cookbook inspected.
+
+Each cookbook is listed with the identifier a Policyfile.lock.json locks it
+with, the SHA-1 of its files as chef-cli computes it.
+
+Examples:
+  berks policy compile                         # Policyfile.rb here
+  berks policy compile policies/web.rb
+  berks policy compile --cookbooks-dir out     # Keep the fetched cookbooks`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := "Policyfile.rb"
+		if len(args) > 0 {
+			path = args[0]
+		}
+
+		dir := viper.GetString("cookbooks-dir")
+		if dir == "" {
+			tmp, err := os.MkdirTemp("", "berks-policy-")
+			if err != nil {
+				return err
+			}
+			defer os.RemoveAll(tmp)
+			dir = tmp
+		}
+
+		cmd.SilenceUsage = true
+		compiled, err := compilePolicy(cmd.Context(), path, dir)
+		if err != nil {
+			return err
+		}
+
+		table := newTable("COOKBOOK", "VERSION", "IDENTIFIER", "SOURCE")
+		for _, cookbook := range compiled.Resolution.AllCookbooks() {
+			source := ""
+			if cookbook.Source != nil {
+				source = cookbook.Source.String()
+			}
+			table.Append(cookbook.Name, cookbook.Version.String(), compiled.Identifiers[cookbook.Name].ID, source)
+		}
+		if err := table.Render(os.Stdout); err != nil {
+			return err
+		}
+		fmt.Printf("Compiled %s: %d cookbooks\n", path, len(compiled.Cookbooks))
+		return nil
+	},
+}
+
+var policyExportCmd = &cobra.Command{
+	Use:   "export [POLICYFILE] DESTINATION",
+	Short: "Export a Policyfile and its cookbooks for chef-client local mode",
+	Long: `Compile a Policyfile.rb, as 'berks policy compile' does, and export it to
+DESTINATION in the layout of 'chef export', so that chef-client can apply it
+in local mode without a Chef Server:
+
+  cookbook_artifacts/NAME-IDENTIFIER   the files of each cookbook
+  policies/NAME-REVISION.json          the policy's lock
+  policy_groups/local.json             the local group, with the policy
+  .chef/config.rb                      applies the policy in the local group
+  Policyfile.lock.json                 the policy's lock
+
+Then run 'chef-client -z' in DESTINATION. The Policyfile must name the
+policy. Attributes set in the Policyfile are not exported.
+
+Examples:
+  berks policy export out                      # Policyfile.rb here
+  berks policy export policies/web.rb out
+  berks policy export out --force              # Replace a previous export`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, dest := "Policyfile.rb", args[0]
+		if len(args) == 2 {
+			path, dest = args[0], args[1]
+		}
+		if entries, err := os.ReadDir(dest); err == nil && len(entries) > 0 && !viper.GetBool("force") {
+			return fmt.Errorf("%s is not empty (use --force to export into it)", dest)
+		}
+
+		cmd.SilenceUsage = true
+		staging, err := os.MkdirTemp("", "berks-policy-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(staging)
+
+		compiled, err := compilePolicy(cmd.Context(), path, staging)
+		if err != nil {
+			return err
+		}
+		lock, err := compiled.Lock(filepath.Dir(path))
+		if err != nil {
+			return berrors.WithType(fmt.Errorf("failed to lock %s: %w", path, err), berrors.ErrorTypeValidation)
+		}
+
+		// A previous export's cookbooks and policies would linger
+		for _, dir := range []string{"cookbook_artifacts", "policies", "policy_groups"} {
+			if err := os.RemoveAll(filepath.Join(dest, dir)); err != nil {
+				return err
+			}
+		}
+		dirs := make(map[string]string, len(compiled.Cookbooks))
+		for name, cookbook := range compiled.Cookbooks {
+			dirs[name] = cookbook.Path
+		}
+		if err := policyfile.Export(lock, dirs, dest); err != nil {
+			return fmt.Errorf("failed to export %s: %w", path, err)
+		}
+		log.Infof("Exported policy %s revision %s with %d cookbooks to %s", lock.Name, lock.RevisionID, len(lock.CookbookLocks), dest)
+		return nil
+	},
+}
+
+// compiledPolicy is a Policyfile resolved to cookbook versions, with the
+// files of each extracted
+type compiledPolicy struct {
+	Policyfile  *policyfile.Policyfile
+	Resolution  *resolver.Resolution
+	Cookbooks   map[string]policyfile.CompiledCookbook
+	Identifiers map[string]*cookbook.Identifier
+	// Fetched holds each cookbook as its source described it
+	Fetched map[string]*berkshelf.Cookbook
+}
+
+// compilePolicy resolves the Policyfile at path, extracts each cookbook it
+// resolves to under dir, as NAME-VERSION, computes their identifiers and
+// checks that the cookbooks have the recipes its run lists call for
+func compilePolicy(ctx context.Context, path, dir string) (*compiledPolicy, error) {
+	p, err := policyfile.Load(path)
+	if err != nil {
+		return nil, berrors.WithType(fmt.Errorf("failed to parse %s: %w", path, err), berrors.ErrorTypeParsing)
+	}
+	equivalent, err := p.ToBerksfileEquivalent()
+	if err != nil {
+		return nil, err
+	}
+
+	// Path sources are relative to the Policyfile, as for chef-cli
+	policyDir := filepath.Dir(path)
+	var cookbooks []*berksfile.CookbookDef
+	for _, def := range equivalent.Cookbooks {
+		cookbooks = append(cookbooks, &berksfile.CookbookDef{
+			Name:       def.Name,
+			Constraint: def.Constraint,
+			Source:     absPathSource(def.Source, policyDir),
+		})
+	}
+	requirements := CreateRequirementsFromCookbooks(cookbooks)
+
+	sourceManager, err := SetupSourcesFromBerksfile(&berksfile.Berksfile{Sources: equivalent.Sources})
+	if err != nil {
+		return nil, err
+	}
+	sources := sourceManager.GetSources()
+	if !viper.GetBool("no-cache") {
+		sources = withVersionCache(sources)
+	}
+	chefVersion, err := detectChefVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Infof("Resolving %s...", path)
+	resolution, err := ResolveDependencies(ctx, requirements, nil, nil, sources, nil, chefVersion, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	compiled := &compiledPolicy{
+		Policyfile:  p,
+		Resolution:  resolution,
+		Cookbooks:   make(map[string]policyfile.CompiledCookbook),
+		Identifiers: make(map[string]*cookbook.Identifier),
+		Fetched:     make(map[string]*berkshelf.Cookbook),
+	}
+	for _, resolved := range resolution.AllCookbooks() {
+		if resolved.SourceRef == nil {
+			return nil, fmt.Errorf("no source for cookbook %s", resolved.Name)
+		}
+		version := resolved.Version.String()
+		target := filepath.Join(dir, resolved.Name+"-"+version)
+		fetched, err := resolved.SourceRef.FetchCookbook(ctx, resolved.Name, resolved.Version)
+		if err == nil {
+			err = resolved.SourceRef.DownloadAndExtractCookbook(ctx, fetched, target)
+		}
+		if err != nil {
+			return nil, berrors.WithType(fmt.Errorf("failed to fetch %s %s: %w", resolved.Name, version, err), berrors.ErrorTypeNetwork)
+		}
+		id, err := cookbook.ComputeIdentifier(target)
+		if err != nil {
+			return nil, fmt.Errorf("failed to identify %s %s: %w", resolved.Name, version, err)
+		}
+		compiled.Cookbooks[resolved.Name] = policyfile.CompiledCookbook{Version: version, Path: target}
+		compiled.Identifiers[resolved.Name] = id
+		compiled.Fetched[resolved.Name] = fetched
+	}
+
+	if recipeErrs := p.CheckRecipes(compiled.Cookbooks); len(recipeErrs) > 0 {
+		messages := make([]string, 0, len(recipeErrs))
+		for _, recipeErr := range recipeErrs {
+			messages = append(messages, recipeErr.Error())
+		}
+		err := errors.New("failed to compile " + path + ":\n  " + strings.Join(messages, "\n  "))
+		return nil, berrors.WithType(err, berrors.ErrorTypeValidation)
+	}
+	return compiled, nil
+}
+
+// Lock returns the Policyfile.lock.json of the compiled policy, with path
+// sources relative to policyDir, the directory of the Policyfile
+func (c *compiledPolicy) Lock(policyDir string) (*policyfile.Lock, error) {
+	locks := make(map[string]*policyfile.CookbookLock, len(c.Cookbooks))
+	solution := policyfile.SolutionDependencies{Dependencies: make(map[string][][2]string)}
+	for _, resolved := range c.Resolution.AllCookbooks() {
+		version := resolved.Version.String()
+		lock := &policyfile.CookbookLock{
+			Version:                 version,
+			Identifier:              c.Identifiers[resolved.Name].ID,
+			DottedDecimalIdentifier: c.Identifiers[resolved.Name].DottedDecimal,
+		}
+		fetched := c.Fetched[resolved.Name]
+		src := resolved.Source
+		if src == nil {
+			src = &berkshelf.SourceLocation{}
+		}
+		switch src.Type {
+		case "path":
+			rel := src.Path
+			if abs, err := filepath.Abs(policyDir); err == nil {
+				if r, err := filepath.Rel(abs, src.Path); err == nil {
+					rel = filepath.ToSlash(r)
+				}
+			}
+			lock.Source = rel
+			lock.SourceOptions = map[string]any{"path": rel}
+		case "git":
+			cacheKey := resolved.Name + "-" + src.Ref
+			lock.CacheKey = &cacheKey
+			lock.Origin = src.URL
+			lock.SourceOptions = map[string]any{"git": src.URL, "revision": src.Ref}
+			if src.Path != "" {
+				lock.SourceOptions["rel"] = src.Path
+			}
+		default:
+			origin := src.URL
+			if fetched != nil && fetched.TarballURL != "" {
+				origin = fetched.TarballURL
+			}
+			host := src.URL
+			if u, err := url.Parse(src.URL); err == nil && u.Host != "" {
+				host = u.Hostname()
+			}
+			cacheKey := fmt.Sprintf("%s-%s-%s", resolved.Name, version, host)
+			lock.CacheKey = &cacheKey
+			lock.Origin = origin
+			lock.SourceOptions = map[string]any{"artifactserver": origin, "version": version}
+		}
+		locks[resolved.Name] = lock
+
+		dependencies := [][2]string{}
+		if fetched != nil {
+			for _, name := range slices.Sorted(maps.Keys(fetched.Dependencies)) {
+				dependencies = append(dependencies, [2]string{name, constraintString(fetched.Dependencies[name])})
+			}
+		}
+		solution.Dependencies[fmt.Sprintf("%s (%s)", resolved.Name, version)] = dependencies
+	}
+	for _, def := range c.Policyfile.Requirements() {
+		solution.Policyfile = append(solution.Policyfile, [2]string{def.Name, constraintString(def.Constraint)})
+	}
+	return c.Policyfile.NewLock(locks, solution)
+}
+
+// constraintString returns a constraint as a lock records it, >= 0.0.0 when
+// there is none
+func constraintString(constraint *berkshelf.Constraint) string {
+	if constraint == nil {
+		return ">= 0.0.0"
+	}
+	return constraint.String()
+}
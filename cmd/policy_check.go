@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/bdwyertech/go-berkshelf/internal/config"
+	"github.com/bdwyertech/go-berkshelf/pkg/policy"
+	"github.com/bdwyertech/go-berkshelf/pkg/ui"
+)
+
+func init() {
+	rootCmd.AddCommand(policyCheckCmd)
+
+	policyCheckCmd.Flags().StringP("format", "f", "table", "Output format (table, json)")
+	registerFormatCompletion(policyCheckCmd, "table", "json")
+}
+
+var policyCheckCmd = &cobra.Command{
+	Use:   "policy-check",
+	Short: "Check the lock file against the dependency policy",
+	Long: `Check the locked cookbooks and the Berksfile constraints against the rules
+in the policy section of the berkshelf config, and fail when any is broken,
+for use in CI:
+
+  "policy": {
+    "max_age_days": 365,
+    "disallow_prerelease": true,
+    "require_upper_bounds": true,
+    "exempt": ["legacy_*"]
+  }
+
+  max_age_days          the locked version was released more than this
+                        many days ago, as its Supermarket reports
+  disallow_prerelease   the locked version is 0.x, which is not yet stable
+  require_upper_bounds  a Berksfile constraint allows any newer major
+                        version, such as >= 1.0 or none at all; cookbooks
+                        from git or path sources are exempt
+  exempt                cookbook names, or glob patterns, no rule applies to
+
+The command exits 1 when any rule is broken.
+
+Examples:
+  berks policy-check
+  berks policy-check --set policy.max_age_days=180
+  berks policy-check --format json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format := strings.ToLower(viper.GetString("format"))
+		if err := checkFormat(format, "table", "json"); err != nil {
+			return err
+		}
+
+		result := newResult("policy-check", format)
+		violations, err := runPolicyCheck(cmd, result)
+		if err == nil && result == nil {
+			err = outputViolationTable(violations)
+		}
+		if err == nil && len(violations) > 0 {
+			err = &exitError{code: 1, err: fmt.Errorf("%d policy violation(s)", len(violations))}
+		}
+		if err != nil {
+			cmd.SilenceUsage = true
+		}
+		return result.Write(os.Stdout, err)
+	},
+}
+
+// runPolicyCheck checks the lock file against the configured policy,
+// recording the violations in result (which may be nil)
+func runPolicyCheck(cmd *cobra.Command, result *Result) ([]policy.Violation, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	rules := policy.Policy{
+		MaxAge:             time.Duration(cfg.Policy.GetMaxAgeDays()) * 24 * time.Hour,
+		DisallowPrerelease: cfg.Policy.GetDisallowPrerelease(),
+		RequireUpperBounds: cfg.Policy.GetRequireUpperBounds(),
+		Exempt:             cfg.Policy.GetExempt(),
+	}
+	if rules.Empty() {
+		log.Warn("No dependency policy rules are configured; see 'berks policy-check --help'")
+		result.Warn("no dependency policy rules are configured")
+	}
+
+	bf, err := LoadBerksfile()
+	if err != nil {
+		return nil, err
+	}
+	lockFile, manager, err := LoadLockFile()
+	if err != nil {
+		return nil, err
+	}
+	if !manager.Exists() {
+		return nil, fmt.Errorf("no lock file found. Run 'berks install' first")
+	}
+	sourceManager, err := CreateSourceManager(bf)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	violations := rules.Check(cmd.Context(), bf, lockFile, sourceManager)
+	result.Phase("check", start)
+	for _, violation := range violations {
+		result.AddViolation(violation)
+	}
+	return violations, nil
+}
+
+// outputViolationTable prints the violations, grouped by cookbook
+func outputViolationTable(violations []policy.Violation) error {
+	if len(violations) == 0 {
+		fmt.Println("No policy violations found.")
+		return nil
+	}
+
+	opts := tableOptions()
+	opts.Unsorted = true
+	table := ui.NewTable(opts, "COOKBOOK", "VERSION", "RULE", "DETAIL")
+	for _, violation := range violations {
+		table.Append(violation.Cookbook, violation.Version, violation.Rule, violation.Message)
+	}
+	return table.Render(os.Stdout)
+}
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/spf13/viper"
+)
+
+// profiling is held by the resolution being profiled
+var profiling sync.Mutex
+
+// profileResolution runs resolve, profiling it when --profile is set: a CPU
+// profile of resolve is written to <prefix>.cpu.pprof and a heap profile
+// taken once it returns to <prefix>.heap.pprof, for `go tool pprof`. Only
+// one resolution is profiled at a time; those running alongside it, as in a
+// workspace, are not.
+func profileResolution(resolve func() error) error {
+	prefix := viper.GetString("profile")
+	if prefix == "" || !profiling.TryLock() {
+		return resolve()
+	}
+	defer profiling.Unlock()
+
+	cpuPath := prefix + ".cpu.pprof"
+	cpu, err := os.Create(cpuPath)
+	if err != nil {
+		return fmt.Errorf("failed to create CPU profile: %w", err)
+	}
+	defer cpu.Close()
+	if err := pprof.StartCPUProfile(cpu); err != nil {
+		return fmt.Errorf("failed to start CPU profile: %w", err)
+	}
+	resolveErr := resolve()
+	pprof.StopCPUProfile()
+	log.Infof("Wrote CPU profile to %s", cpuPath)
+
+	if err := writeHeapProfile(prefix + ".heap.pprof"); err != nil {
+		log.Warnf("Failed to write heap profile: %v", err)
+	}
+	return resolveErr
+}
+
+// writeHeapProfile writes a heap profile, after a GC so it reflects the live
+// allocations, to path
+func writeHeapProfile(path string) error {
+	heap, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer heap.Close()
+	runtime.GC()
+	if err := pprof.WriteHeapProfile(heap); err != nil {
+		return err
+	}
+	log.Infof("Wrote heap profile to %s", path)
+	return nil
+}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/bdwyertech/go-berkshelf/internal/config"
+	"github.com/bdwyertech/go-berkshelf/pkg/credentials"
+	berrors "github.com/bdwyertech/go-berkshelf/pkg/errors"
+	"github.com/bdwyertech/go-berkshelf/pkg/proxy"
+	"github.com/bdwyertech/go-berkshelf/pkg/source"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	rootCmd.AddCommand(proxyCmd)
+
+	// Add flags
+	proxyCmd.Flags().String("listen", "127.0.0.1:8081", "Address to listen on")
+	proxyCmd.Flags().StringArray("upstream", []string{source.PUBLIC_SUPERMARKET}, "Supermarket to proxy; repeat to chain several, first match wins")
+	proxyCmd.Flags().String("cache-dir", "", "Directory caching upstream responses (default: ~/.berkshelf/proxy)")
+	proxyCmd.Flags().Duration("ttl", proxy.DefaultTTL, "How long cached API responses are served before the upstreams are asked again")
+	proxyCmd.Flags().Bool("offline", false, "Serve only from the cache, never asking the upstreams")
+	proxyCmd.Flags().String("base-url", "", "URL clients reach the proxy at, written into responses (default: from each request)")
+	proxyCmd.Flags().String("tls-cert", "", "TLS certificate file; serves HTTPS with --tls-key")
+	proxyCmd.Flags().String("tls-key", "", "TLS private key file")
+	proxyCmd.MarkFlagsRequiredTogether("tls-cert", "tls-key")
+}
+
+var proxyCmd = &cobra.Command{
+	Use:   "proxy",
+	Short: "Serve a caching proxy of the Supermarket API",
+	Long: `Serve a caching proxy of the Supermarket API.
+
+The proxy answers /universe and /api/v1/cookbooks/... from its cache, asking
+the --upstream Supermarkets for what it has not cached or has cached for
+longer than --ttl. Cookbook tarballs are cached for good. When no upstream
+can be reached, cached responses are served however old, so the proxy keeps
+working through upstream outages.
+
+With several upstreams, a cookbook comes from the first that has it, and
+/universe lists the cookbooks of all of them. An upstream may be another
+proxy, so a proxy inside an air-gapped network can chain to one at its
+edge. Run the inner proxy with --offline to serve only what has been
+cached, for instance from a cache directory carried across the air gap.
+
+URLs of the upstreams in responses are rewritten to the proxy's, taken from
+each request or --base-url when the proxy sits behind a load balancer, so
+downloads go through it too. Upstreams are sent their api_keys from config.
+
+Examples:
+  berks proxy                                   # Proxy supermarket.chef.io on 127.0.0.1:8081
+  berks proxy --listen :8081 \
+    --upstream https://supermarket.example.com \
+    --upstream https://supermarket.chef.io      # Prefer a private Supermarket
+  berks proxy --offline --cache-dir /mnt/mirror # Serve a carried cache
+
+Then point Berksfiles at the proxy:
+  source "http://proxy.example.com:8081"`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		upstreams, err := cmd.Flags().GetStringArray("upstream")
+		if err != nil {
+			return err
+		}
+		cacheDir := viper.GetString("cache-dir")
+		if cacheDir == "" {
+			cacheDir = filepath.Join(config.GetConfigDir(), "proxy")
+		}
+		apiKeys, err := proxyAPIKeys(upstreams)
+		if err != nil {
+			return berrors.WithType(err, berrors.ErrorTypeConfiguration)
+		}
+
+		handler, err := proxy.New(proxy.Options{
+			Upstreams: upstreams,
+			APIKeys:   apiKeys,
+			CacheDir:  cacheDir,
+			TTL:       viper.GetDuration("ttl"),
+			Offline:   viper.GetBool("offline"),
+			BaseURL:   viper.GetString("base-url"),
+		})
+		if err != nil {
+			return berrors.WithType(err, berrors.ErrorTypeConfiguration)
+		}
+
+		log.Infof("Proxying %s, caching in %s", strings.Join(upstreams, ", "), cacheDir)
+		return listenAndServe(cmd, handler, "the Supermarket proxy")
+	},
+}
+
+// proxyAPIKeys returns the configured API keys of upstreams, resolving
+// keychain references. An unreadable config is logged and treated as having
+// no keys.
+func proxyAPIKeys(upstreams []string) (map[string]string, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Warnf("Ignoring API keys: %v", err)
+		return nil, nil
+	}
+	configured := cfg.GetAPIKeys()
+	apiKeys := make(map[string]string)
+	for _, upstream := range upstreams {
+		upstream = strings.TrimSuffix(upstream, "/")
+		apiKey := configured[upstream]
+		if apiKey == "" {
+			apiKey = configured[upstream+"/"]
+		}
+		if apiKey == "" {
+			continue
+		}
+		key, err := credentials.Resolve(apiKey)
+		if err != nil {
+			return nil, fmt.Errorf("API key for %s: %w", upstream, err)
+		}
+		apiKeys[upstream] = key
+	}
+	return apiKeys, nil
+}
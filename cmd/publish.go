@@ -0,0 +1,637 @@
+package cmd
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/bdwyertech/go-berkshelf/internal/config"
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	"github.com/bdwyertech/go-berkshelf/pkg/credentials"
+	berrors "github.com/bdwyertech/go-berkshelf/pkg/errors"
+	"github.com/bdwyertech/go-berkshelf/pkg/hooks"
+	"github.com/bdwyertech/go-berkshelf/pkg/metadata"
+	"github.com/bdwyertech/go-berkshelf/pkg/publish"
+	"github.com/bdwyertech/go-berkshelf/pkg/resolver"
+	"github.com/bdwyertech/go-berkshelf/pkg/signing"
+	"github.com/bdwyertech/go-berkshelf/pkg/ui"
+	"github.com/bdwyertech/go-berkshelf/pkg/vendor"
+)
+
+func init() {
+	rootCmd.AddCommand(publishCmd)
+
+	publishCmd.Flags().String("url", "", "Supermarket or Artifactory Chef repository URL (instead of configured targets)")
+	publishCmd.Flags().StringSlice("target", nil, "Configured publish target(s) to upload to (default: all)")
+	publishCmd.Flags().Bool("include-dependencies", false, "Also upload the cookbook's locked dependencies")
+	publishCmd.Flags().Bool("force", false, "Replace versions a Chef Server already has, unless frozen")
+	publishCmd.Flags().String("category", publish.DefaultCategory, "Supermarket category for the cookbook")
+	publishCmd.Flags().String("client-name", "", "Supermarket or Chef Server user name (defaults to the configured node name)")
+	publishCmd.Flags().String("client-key", "", "Path to the user's private key (defaults to the configured client key)")
+	publishCmd.Flags().String("api-key", "", "Artifactory API key, or an env:/keychain: reference to one")
+	publishCmd.Flags().Bool("dry-run", false, "Package the cookbook and show what would be uploaded")
+	publishCmd.Flags().String("format", "text", "Output format (text, json)")
+
+	registerFormatCompletion(publishCmd, "text", "json")
+	cobra.CheckErr(publishCmd.RegisterFlagCompletionFunc("target", completePublishTargets))
+}
+
+var publishCmd = &cobra.Command{
+	Use:   "publish [COOKBOOK_DIR]",
+	Short: "Upload a cookbook to Supermarkets, Artifactory and Chef Servers",
+	Long: `Package a local cookbook and upload it to every configured publish target,
+or to the Supermarket-compatible API given with --url. The current directory
+is published if no directory is given.
+
+Targets are defined under publish_targets in the berkshelf config:
+
+  "publish_targets": {
+    "supermarket": {"type": "supermarket", "url": "https://supermarket.example.com"},
+    "chef": {"type": "chef_server", "url": "https://chef.example.com",
+             "organizations": ["dev", "prod"], "freeze": true},
+    "prod": {"type": "chef_server", "profile": "prod"}
+  }
+
+A target naming a profile from chef.profiles takes its client, and a Chef
+Server target without a url its organization URL, from the profile.
+
+A chef_server target uploads to each of its organizations, as 'knife cookbook
+upload' does. Supermarket uploads are signed with a Chef user key; Artifactory
+Chef repositories (URLs containing /api/chef/) authenticate with an API key.
+Credentials a target does not set come from the flags, then from api_keys
+and the chef section of the config.
+
+With --include-dependencies the cookbook's dependencies are downloaded at the
+versions in the lock file and uploaded before the cookbooks that depend on
+them. Versions a target already has are skipped. A Chef Server version is
+compared file by file: one with the same files is skipped as unchanged,
+without uploading anything, and one that differs is replaced with --force
+unless it is frozen, which is reported as a conflict. A cookbook whose
+dependency failed to upload to a Chef Server is not uploaded there. Every
+target is attempted; the command fails if any upload did. The uploaded,
+skipped and failed counts are printed after the uploads.
+
+With signing.key configured, each cookbook uploaded to a Supermarket or
+Artifactory is signed and its signature stored in signing.store, for the
+machines that verify downloads against a trust policy (see 'berks signing').
+
+Examples:
+  berks publish                                        # Upload to every configured target
+  berks publish --target chef --include-dependencies   # Upload with dependencies to one target
+  berks publish --url https://supermarket.example.com
+  berks publish cookbooks/apt --url https://supermarket.example.com --category Utilities
+  berks publish --url https://artifactory.example.com/artifactory/api/chef/chef-local --api-key keychain:artifactory
+  berks publish --dry-run                              # Package without uploading`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format := viper.GetString("format")
+		if err := checkFormat(format, "text", "json"); err != nil {
+			return err
+		}
+
+		result := newResult("publish", format)
+		err := runPublish(cmd, args, result)
+		if err != nil {
+			cmd.SilenceUsage = true
+		}
+		return result.Write(os.Stdout, err)
+	},
+}
+
+// publishDestination is one place a cookbook is uploaded to: a Supermarket
+// target, or one organization of a Chef Server target
+type publishDestination struct {
+	name   string
+	target config.PublishTarget
+}
+
+// publishItem is a cookbook directory to upload
+type publishItem struct {
+	name    string
+	version string
+	dir     string
+	// dependencies are the cookbooks its metadata depends on
+	dependencies []string
+}
+
+// publishStatus is the outcome of one upload
+type publishStatus struct {
+	destination string
+	cookbook    string
+	version     string
+	status      string
+	detail      string
+}
+
+func runPublish(cmd *cobra.Command, args []string, result *Result) error {
+	dir := "."
+	if len(args) > 0 {
+		dir = args[0]
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	destinations, err := publishDestinations(cfg)
+	if err != nil {
+		return err
+	}
+
+	// A cookbook without readable metadata is reported when it fails to package
+	items := []publishItem{newPublishItem(dir)}
+	name := items[0].name
+	if viper.GetBool("include-dependencies") {
+		staging, err := os.MkdirTemp("", "berks-publish-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(staging)
+
+		start := time.Now()
+		dependencies, err := stagePublishDependencies(cmd, dir, name, staging)
+		result.Phase("stage", start)
+		if err != nil {
+			return err
+		}
+		if items, err = orderPublishItems(append(dependencies, items...)); err != nil {
+			return err
+		}
+	}
+
+	dryRun := viper.GetBool("dry-run")
+	var signer *publishSigning
+	if !dryRun {
+		key, store, err := publishSigner(cfg)
+		if err != nil {
+			return err
+		}
+		if key != nil {
+			signer = &publishSigning{key: key, store: store}
+		}
+	}
+
+	// Every destination's pre-upload hooks pass before anything is uploaded
+	cookbooks := make([]hooks.Cookbook, len(items))
+	for i, item := range items {
+		cookbooks[i] = hooks.Cookbook{Name: item.name, Version: item.version}
+	}
+	for _, destination := range destinations {
+		payload := hooks.Payload{Event: hooks.PreUpload, Dir: dir, Target: destination.target.URL, Cookbooks: cookbooks}
+		if err := runHooks(cmd.Context(), payload); err != nil {
+			return err
+		}
+	}
+
+	var statuses []publishStatus
+	start := time.Now()
+	ctx, cancel := phaseContext(cmd.Context(), berrors.PhaseUpload)
+	defer cancel()
+	for _, destination := range destinations {
+		statuses = append(statuses, publishTo(ctx, cfg, destination, items, dryRun, signer, result)...)
+	}
+	result.Phase("publish", start)
+
+	counts := make(map[string]int)
+	for _, status := range statuses {
+		outcome := publishOutcome(status.status)
+		counts[outcome]++
+		result.Count(outcome)
+	}
+	if result == nil {
+		if err := outputPublishTable(statuses); err != nil {
+			return err
+		}
+		fmt.Println(publishSummary(counts))
+	}
+
+	if failed := counts["failed"]; failed > 0 {
+		return &exitError{code: 1, err: fmt.Errorf("%d of %d upload(s) failed", failed, len(statuses))}
+	}
+	return nil
+}
+
+// publishSigning signs the cookbooks published to Supermarkets and
+// Artifactory, keeping the signatures in store
+type publishSigning struct {
+	key   *signing.Signer
+	store *signing.Store
+}
+
+// sign signs and stores the signature of a published cookbook
+func (s *publishSigning) sign(ctx context.Context, published *publish.Result) error {
+	signature, err := s.key.SignDigest(published.SHA256)
+	if err != nil {
+		return err
+	}
+	return s.store.Put(ctx, published.Name, published.Version, signature)
+}
+
+// publishTo uploads every item to one destination and returns their statuses.
+// Credential errors fail each item rather than the whole run, so the other
+// destinations are still attempted. An item depending on one that failed is
+// not uploaded to a Chef Server, which would otherwise hold a cookbook whose
+// dependency it lacks. signer, if set, signs each cookbook uploaded to a
+// Supermarket.
+func publishTo(ctx context.Context, cfg *config.Config, destination publishDestination, items []publishItem, dryRun bool, signer *publishSigning, result *Result) []publishStatus {
+	target := destination.target
+	var auth publish.Authenticator
+	var authErr error
+	if !dryRun {
+		auth, authErr = targetAuth(cfg, target)
+	}
+
+	statuses := make([]publishStatus, 0, len(items))
+	failed := make(map[string]bool)
+	for _, item := range items {
+		status := publishStatus{destination: destination.name, cookbook: item.name}
+		if authErr != nil {
+			status.status, status.detail = "failed", authErr.Error()
+			statuses = append(statuses, recordPublishStatus(result, status))
+			continue
+		}
+		if target.Type == config.PublishTargetChefServer {
+			if i := slices.IndexFunc(item.dependencies, func(name string) bool { return failed[name] }); i >= 0 {
+				failed[item.name] = true
+				status.status, status.detail = "failed", fmt.Sprintf("not uploaded, its dependency %s failed", item.dependencies[i])
+				statuses = append(statuses, recordPublishStatus(result, status))
+				continue
+			}
+		}
+
+		var published *publish.Result
+		var err error
+		switch target.Type {
+		case config.PublishTargetChefServer:
+			published, err = publish.UploadChefServer(ctx, item.dir, publish.ChefServerOptions{
+				URL:       target.URL,
+				Auth:      auth,
+				Freeze:    target.Freeze,
+				Force:     viper.GetBool("force"),
+				DryRun:    dryRun,
+				Algorithm: cfg.GetChecksumAlgorithm(),
+			})
+		default:
+			published, err = publish.Publish(ctx, item.dir, publish.Options{
+				URL:       target.URL,
+				Category:  target.Category,
+				Auth:      auth,
+				DryRun:    dryRun,
+				Algorithm: cfg.GetChecksumAlgorithm(),
+			})
+		}
+		err = berrors.PhaseError(ctx, err)
+
+		var already *publish.ErrAlreadyPublished
+		var frozen *publish.ErrFrozen
+		switch {
+		case errors.As(err, &already):
+			status.cookbook, status.version = already.Name, already.Version
+			status.status, status.detail = "present", "already published"
+		case errors.As(err, &frozen):
+			// The frozen version stays on the server, so its dependents can
+			// still be uploaded
+			status.cookbook, status.version = frozen.Name, frozen.Version
+			status.status, status.detail = "frozen", "frozen on the server, bump the version to replace it"
+		case err != nil:
+			failed[item.name] = true
+			status.status, status.detail = "failed", err.Error()
+		case published.Unchanged:
+			status.cookbook, status.version = published.Name, published.Version
+			status.status, status.detail = "unchanged", "same files already on the server"
+		case published.DryRun:
+			status.cookbook, status.version = published.Name, published.Version
+			status.status, status.detail = "would_publish", published.Endpoint
+			result.AddCookbook(ResultCookbook{Name: published.Name, Version: published.Version, Source: target.URL})
+		default:
+			status.cookbook, status.version = published.Name, published.Version
+			status.status, status.detail = "published", published.Endpoint
+			if published.URI != "" {
+				status.detail = published.URI
+			}
+			if signer != nil && target.Type != config.PublishTargetChefServer {
+				if err := signer.sign(ctx, published); err != nil {
+					status.status, status.detail = "failed", fmt.Sprintf("published but not signed: %v", err)
+					break
+				}
+				log.Debugf("%s: signed %s (%s) in %s", destination.name, published.Name, published.Version, signer.store.Location())
+			}
+			result.AddCookbook(ResultCookbook{Name: published.Name, Version: published.Version, Source: target.URL})
+		}
+		statuses = append(statuses, recordPublishStatus(result, status))
+	}
+	return statuses
+}
+
+// recordPublishStatus logs an upload outcome and adds it to the JSON result
+func recordPublishStatus(result *Result, status publishStatus) publishStatus {
+	if status.cookbook == "" {
+		status.cookbook = "-"
+	}
+	switch status.status {
+	case "failed":
+		log.Errorf("%s: failed to publish %s: %s", status.destination, status.cookbook, status.detail)
+	case "present":
+		log.Infof("%s: %s (%s) is already published", status.destination, status.cookbook, status.version)
+	case "unchanged":
+		log.Infof("%s: %s (%s) is unchanged on the server", status.destination, status.cookbook, status.version)
+	case "frozen":
+		log.Errorf("%s: %s (%s) is frozen and was not replaced", status.destination, status.cookbook, status.version)
+	case "would_publish":
+		log.Infof("%s: would publish %s (%s) to %s", status.destination, status.cookbook, status.version, status.detail)
+	default:
+		log.Infof("%s: published %s (%s)", status.destination, status.cookbook, status.version)
+	}
+	result.ActFor(status.destination, status.status, status.cookbook, status.detail)
+	return status
+}
+
+// publishOutcome groups an upload status into the outcomes that are counted:
+// uploaded, would_upload, skipped or failed
+func publishOutcome(status string) string {
+	switch status {
+	case "published":
+		return "uploaded"
+	case "would_publish":
+		return "would_upload"
+	case "present", "unchanged":
+		return "skipped"
+	}
+	return "failed"
+}
+
+// publishSummary returns the counts line printed after the table, e.g.
+// "2 uploaded, 5 skipped, 0 failed"
+func publishSummary(counts map[string]int) string {
+	uploaded := fmt.Sprintf("%d uploaded", counts["uploaded"])
+	if counts["would_upload"] > 0 {
+		uploaded = fmt.Sprintf("%d would be uploaded", counts["would_upload"])
+	}
+	return fmt.Sprintf("%s, %d skipped, %d failed", uploaded, counts["skipped"], counts["failed"])
+}
+
+// outputPublishTable prints one row per upload
+func outputPublishTable(statuses []publishStatus) error {
+	opts := tableOptions()
+	opts.Unsorted = true
+	table := ui.NewTable(opts, "TARGET", "COOKBOOK", "VERSION", "STATUS", "DETAIL")
+	for _, status := range statuses {
+		table.Append(status.destination, status.cookbook, status.version, strings.ReplaceAll(status.status, "_", " "), status.detail)
+	}
+	return table.Render(os.Stdout)
+}
+
+// publishDestinations returns the destinations for this run: the --url
+// Supermarket, or the configured targets selected with --target, with Chef
+// Server targets expanded to one destination per organization
+func publishDestinations(cfg *config.Config) ([]publishDestination, error) {
+	if url := viper.GetString("url"); url != "" {
+		return []publishDestination{{
+			name:   url,
+			target: config.PublishTarget{Type: config.PublishTargetSupermarket, URL: url, Category: viper.GetString("category")},
+		}}, nil
+	}
+
+	targets := cfg.GetPublishTargets()
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("--url is required when no publish_targets are configured")
+	}
+
+	names := viper.GetStringSlice("target")
+	if len(names) == 0 {
+		for name := range targets {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	var destinations []publishDestination
+	for _, name := range names {
+		target, ok := targets[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown publish target %q (configured: %s)", name, strings.Join(slices.Sorted(maps.Keys(targets)), ", "))
+		}
+		if target.Category == "" {
+			target.Category = viper.GetString("category")
+		}
+		if target.Profile != "" {
+			chef, ok := cfg.ChefConfig.ForProfile(target.Profile)
+			if !ok {
+				return nil, fmt.Errorf("publish target %q uses unknown chef profile %q", name, target.Profile)
+			}
+			target = withChefProfile(target, chef)
+		}
+		if target.Type != config.PublishTargetChefServer || len(target.Organizations) == 0 {
+			destinations = append(destinations, publishDestination{name: name, target: target})
+			continue
+		}
+		base := strings.TrimSuffix(target.URL, "/")
+		for _, org := range target.Organizations {
+			orgTarget := target
+			orgTarget.URL = base + "/organizations/" + org
+			destinations = append(destinations, publishDestination{name: name + "/" + org, target: orgTarget})
+		}
+	}
+	return destinations, nil
+}
+
+// withChefProfile fills in the client of a target, and the URL of a Chef
+// Server target, from a chef profile. A target with organizations takes the
+// server URL without the profile's organization.
+func withChefProfile(target config.PublishTarget, chef *config.ChefConfig) config.PublishTarget {
+	if target.Type == config.PublishTargetChefServer && target.URL == "" {
+		target.URL = chef.GetOrganizationURL()
+		if len(target.Organizations) > 0 {
+			target.URL, _, _ = strings.Cut(target.URL, "/organizations/")
+		}
+	}
+	target.ClientName = cmp.Or(target.ClientName, chef.GetNodeName())
+	target.ClientKey = cmp.Or(target.ClientKey, chef.GetClientKey())
+	return target
+}
+
+// stagePublishDependencies downloads the locked dependencies of the named
+// cookbook in dir into staging and returns them sorted by name
+func stagePublishDependencies(cmd *cobra.Command, dir, name, staging string) ([]publishItem, error) {
+	if name == "" {
+		_, err := cookbookName(dir)
+		return nil, cmp.Or(err, fmt.Errorf("%s has no cookbook name", dir))
+	}
+
+	bf, err := LoadBerksfile()
+	if err != nil {
+		return nil, err
+	}
+	lockFile, manager, err := LoadLockFile()
+	if err != nil {
+		return nil, err
+	}
+	if !manager.Exists() {
+		return nil, fmt.Errorf("no lock file found. Run 'berks install' first")
+	}
+	sourceManager, err := CreateSourceManager(bf)
+	if err != nil {
+		return nil, err
+	}
+
+	var dependencies []string
+	for _, dependency := range vendor.FindTransitiveDependencies(lockFile, []string{name}) {
+		if dependency != name {
+			dependencies = append(dependencies, dependency)
+		}
+	}
+	if len(dependencies) == 0 {
+		return nil, nil
+	}
+	sort.Strings(dependencies)
+
+	ctx, cancel := phaseContext(cmd.Context(), berrors.PhaseDownload)
+	defer cancel()
+	vendored, err := vendor.New(lockFile, sourceManager, vendor.Options{
+		TargetPath:    staging,
+		OnlyCookbooks: dependencies,
+		Factory:       newSourceFactory(),
+	}).Vendor(ctx)
+	if err = berrors.PhaseError(ctx, err); err != nil {
+		return nil, fmt.Errorf("failed to download dependencies: %w", err)
+	}
+	if len(vendored.FailedDownloads) > 0 || len(vendored.Collisions) > 0 {
+		return nil, fmt.Errorf("failed to download %d dependenc(ies)", len(vendored.FailedDownloads)+len(vendored.Collisions))
+	}
+
+	items := make([]publishItem, 0, len(dependencies))
+	for _, dependency := range dependencies {
+		item := newPublishItem(filepath.Join(staging, dependency))
+		item.name = dependency
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// newPublishItem reads the name and dependencies of the cookbook in dir,
+// leaving them empty if its metadata cannot be read
+func newPublishItem(dir string) publishItem {
+	item := publishItem{dir: dir}
+	if md, err := cookbookMetadata(dir); err == nil {
+		item.name, item.version = md.Name, md.Version
+		item.dependencies = slices.Sorted(maps.Keys(md.Dependencies))
+	}
+	return item
+}
+
+// orderPublishItems sorts items so each comes after the items it depends on.
+// Dependencies on cookbooks that are not being published are ignored.
+func orderPublishItems(items []publishItem) ([]publishItem, error) {
+	graph := resolver.NewDependencyGraph()
+	byName := make(map[string]publishItem, len(items))
+	// Added in reverse, so items the dependencies leave unordered keep their order
+	for _, item := range slices.Backward(items) {
+		graph.AddCookbook(&berkshelf.Cookbook{Name: item.name})
+		byName[item.name] = item
+	}
+	for _, item := range items {
+		from, _ := graph.GetCookbook(item.name)
+		for _, dependency := range item.dependencies {
+			if to, ok := graph.GetCookbook(dependency); ok && dependency != item.name {
+				graph.AddDependency(from, to, nil)
+			}
+		}
+	}
+
+	// The sort puts dependents first; uploads go the other way
+	sorted, err := graph.TopologicalSort()
+	if err != nil {
+		return nil, fmt.Errorf("cannot order uploads: %w", err)
+	}
+	ordered := make([]publishItem, 0, len(sorted))
+	for _, node := range slices.Backward(sorted) {
+		ordered = append(ordered, byName[node.Name])
+	}
+	return ordered, nil
+}
+
+// cookbookName reads the cookbook name from the metadata in dir
+func cookbookName(dir string) (string, error) {
+	md, err := cookbookMetadata(dir)
+	if err != nil {
+		return "", err
+	}
+	return md.Name, nil
+}
+
+// cookbookMetadata reads metadata.rb in dir, or metadata.json if there is none
+func cookbookMetadata(dir string) (*metadata.Metadata, error) {
+	md, err := metadata.ParseFile(filepath.Join(dir, "metadata.rb"))
+	if err != nil {
+		data, jsonErr := os.ReadFile(filepath.Join(dir, "metadata.json"))
+		if jsonErr != nil {
+			return nil, fmt.Errorf("failed to read metadata in %s: %w", dir, err)
+		}
+		if md, err = metadata.ParseJSON(data); err != nil {
+			return nil, fmt.Errorf("failed to read metadata in %s: %w", dir, err)
+		}
+	}
+	return md, nil
+}
+
+// completePublishTargets completes --target with the configured target names
+func completePublishTargets(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return slices.Sorted(maps.Keys(cfg.GetPublishTargets())), cobra.ShellCompDirectiveNoFileComp
+}
+
+// newPublishAuth returns the credentials for a Supermarket or Artifactory url
+func newPublishAuth(url string) (publish.Authenticator, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	return targetAuth(cfg, config.PublishTarget{Type: config.PublishTargetSupermarket, URL: url})
+}
+
+// targetAuth returns the credentials for a target: an API key for
+// Artifactory or when one is configured, and a Chef user key otherwise.
+// Values the target leaves empty come from the flags, then the config.
+func targetAuth(cfg *config.Config, target config.PublishTarget) (publish.Authenticator, error) {
+	if target.Type != config.PublishTargetChefServer {
+		apiKey := target.APIKey
+		if apiKey == "" {
+			apiKey = viper.GetString("api-key")
+		}
+		if apiKey == "" {
+			apiKey = cfg.GetAPIKeys()[strings.TrimSuffix(target.URL, "/")]
+		}
+		if apiKey != "" {
+			key, err := credentials.Resolve(apiKey)
+			if err != nil {
+				return nil, fmt.Errorf("API key for %s: %w", target.URL, err)
+			}
+			return publish.ArtifactoryAuth{APIKey: key}, nil
+		}
+		if publish.IsArtifactory(target.URL) {
+			return nil, fmt.Errorf("an API key is required for %s (pass --api-key or set api_keys in config)", target.URL)
+		}
+	}
+
+	clientName := cmp.Or(target.ClientName, viper.GetString("client-name"), cfg.ChefConfig.GetNodeName())
+	clientKey := cmp.Or(target.ClientKey, viper.GetString("client-key"), cfg.ChefConfig.GetClientKey())
+	if clientName == "" || clientKey == "" {
+		return nil, fmt.Errorf("a user and key are required for %s (set them in config or pass --client-name and --client-key)", target.URL)
+	}
+	return publish.NewChefKeyAuth(clientName, clientKey)
+}
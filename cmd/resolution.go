@@ -3,18 +3,32 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
 
 	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
 
+	"github.com/bdwyertech/go-berkshelf/internal/config"
 	"github.com/bdwyertech/go-berkshelf/pkg/berksfile"
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	"github.com/bdwyertech/go-berkshelf/pkg/chefclient"
+	berrors "github.com/bdwyertech/go-berkshelf/pkg/errors"
+	"github.com/bdwyertech/go-berkshelf/pkg/events"
+	"github.com/bdwyertech/go-berkshelf/pkg/hooks"
+	"github.com/bdwyertech/go-berkshelf/pkg/lockfile"
 	"github.com/bdwyertech/go-berkshelf/pkg/resolver"
+	"github.com/bdwyertech/go-berkshelf/pkg/server"
 	"github.com/bdwyertech/go-berkshelf/pkg/source"
 )
 
 // SetupSourcesFromBerksfile sets up the source manager with sources from the Berksfile
 func SetupSourcesFromBerksfile(berks *berksfile.Berksfile) (*source.Manager, error) {
 	sourceManager := source.NewManager()
-	factory := source.NewFactory()
+	factory := newSourceFactory()
 
 	// Add sources from Berksfile
 	for _, sourceLocation := range berks.Sources {
@@ -28,20 +42,58 @@ func SetupSourcesFromBerksfile(berks *berksfile.Berksfile) (*source.Manager, err
 
 	// Add default Supermarket if no sources specified
 	if len(berks.Sources) == 0 {
-		defaultSource := source.NewSupermarketSource(source.PUBLIC_SUPERMARKET)
+		defaultSource, err := factory.CreateFromURL(source.PUBLIC_SUPERMARKET)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the default source: %w", err)
+		}
 		sourceManager.AddSource(defaultSource)
 	}
 
 	return sourceManager, nil
 }
 
-// ResolveDependencies resolves cookbook dependencies and handles errors
-func ResolveDependencies(ctx context.Context, requirements []*resolver.Requirement, sources []source.CookbookSource) (*resolver.Resolution, error) {
+// ResolveDependencies resolves cookbook dependencies and handles errors.
+// When chefVersion is set, cookbook versions whose chef_version excludes it are skipped.
+// Progress events are sent to emit, which may be nil. The sources of
+// requirements naming their own source are created by factory, or by a
+// default source.Factory when it is nil. overrides pin cookbooks regardless
+// of requirements and dependencies (see resolutionOverrides), and ignored
+// cookbooks are skipped. Cookbooks are resolved from the sources the
+// configured source_routes send them to. Resolution is limited to the
+// configured resolve_timeout, and profiled with --profile. The pre-resolve
+// and post-resolve hooks run around it.
+func ResolveDependencies(ctx context.Context, requirements, overrides []*resolver.Requirement, ignored []string, sources []source.CookbookSource, factory source.SourceFactory, chefVersion *berkshelf.Version, emit events.Handler) (*resolver.Resolution, error) {
 	resolverImpl := resolver.NewResolver(sources)
+	resolverImpl.SetEventHandler(emit)
+	resolverImpl.SetChefVersion(chefVersion)
+	resolverImpl.Override(overrides...)
+	resolverImpl.Ignore(ignored...)
+	resolverImpl.SetKeepGoing(viper.GetBool("keep-going"))
+	routes, err := loadSourceRoutes()
+	if err != nil {
+		return nil, err
+	}
+	resolverImpl.Route(routes...)
+	if err := injectEnvironment(resolverImpl); err != nil {
+		return nil, err
+	}
+	if factory != nil {
+		resolverImpl.SetSourceFactory(factory)
+	}
+
+	if err := runHooks(ctx, hooks.Payload{Event: hooks.PreResolve, Cookbooks: hooks.Requirements(requirements)}); err != nil {
+		return nil, err
+	}
 
-	resolution, err := resolverImpl.Resolve(ctx, requirements)
+	ctx, cancel := phaseContext(ctx, berrors.PhaseResolve)
+	defer cancel()
+	var resolution *resolver.Resolution
+	err = profileResolution(func() (err error) {
+		resolution, err = resolverImpl.Resolve(ctx, requirements)
+		return err
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to resolve dependencies: %w", err)
+		return nil, berrors.WithType(fmt.Errorf("failed to resolve dependencies: %w", berrors.PhaseError(ctx, err)), berrors.ErrorTypeResolution)
 	}
 
 	if resolution.HasErrors() {
@@ -49,12 +101,71 @@ func ResolveDependencies(ctx context.Context, requirements []*resolver.Requireme
 		for _, resErr := range resolution.Errors {
 			log.Error(resErr)
 		}
-		return nil, fmt.Errorf("dependency resolution failed with %d errors", len(resolution.Errors))
+		return nil, berrors.PhaseError(ctx, resolution.Failure())
 	}
 
+	if err := runHooks(ctx, hooks.Payload{Event: hooks.PostResolve, Cookbooks: hooks.Resolved(resolution)}); err != nil {
+		return nil, err
+	}
 	return resolution, nil
 }
 
+// loadSourceRoutes returns the source routes from the berkshelf config. An
+// unreadable config is logged and treated as having no routes.
+func loadSourceRoutes() ([]resolver.SourceRoute, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Warnf("Ignoring source routes: %v", err)
+		return nil, nil
+	}
+
+	var routes []resolver.SourceRoute
+	factory := newSourceFactory()
+	for _, route := range cfg.GetSourceRoutes() {
+		pattern, err := regexp.Compile(route.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid source route pattern %q: %w", route.Pattern, err)
+		}
+		src, err := factory.CreateFromURL(route.Source)
+		if err != nil {
+			return nil, fmt.Errorf("invalid source route to %s: %w", route.Source, err)
+		}
+		routes = append(routes, resolver.SourceRoute{Pattern: pattern, Source: src})
+	}
+	return routes, nil
+}
+
+// resolutionOverrides returns the overrides in the lock file followed by those
+// declared in the Berksfile, which take precedence, as resolver
+// requirements. A lock file that cannot be read has none.
+func resolutionOverrides(bf *berksfile.Berksfile, lockManager *lockfile.Manager) ([]*resolver.Requirement, error) {
+	declared := CreateRequirementsFromCookbooks(bf.Overrides)
+	lockFile, err := lockManager.Load()
+	if err != nil {
+		return declared, nil
+	}
+	overrides, err := lockFile.OverrideRequirements()
+	if err != nil {
+		return nil, fmt.Errorf("invalid override in %s: %w", lockManager.GetPath(), err)
+	}
+	return append(overrides, declared...), nil
+}
+
+// warnOverrides flags every override in lockFile. Overrides are meant to be
+// temporary, so they are reported on every install until removed.
+func warnOverrides(lockFile *lockfile.LockFile, path string, result *Result) {
+	for _, name := range slices.Sorted(maps.Keys(lockFile.Overrides)) {
+		override := lockFile.Overrides[name]
+		msg := fmt.Sprintf("%s is pinned to %s by an override in %s", name, override.Version, path)
+		if override.Reason != "" {
+			msg += " (" + override.Reason + ")"
+		}
+		msg += "; remove the override once it is no longer needed"
+		log.Warn(msg)
+		result.Warn("%s", msg)
+	}
+}
+
 // CreateRequirementsFromCookbooks creates resolver requirements from cookbook definitions
 func CreateRequirementsFromCookbooks(cookbooks []*berksfile.CookbookDef) []*resolver.Requirement {
 	requirements := make([]*resolver.Requirement, 0, len(cookbooks))
@@ -68,8 +179,81 @@ func CreateRequirementsFromCookbooks(cookbooks []*berksfile.CookbookDef) []*reso
 			// Use global sources for cookbooks without specific sources
 			req = resolver.NewRequirement(cookbook.Name, cookbook.Constraint)
 		}
+		req.MetadataName = cookbook.MetadataName
 
 		requirements = append(requirements, req)
 	}
 	return requirements
 }
+
+// detectChefVersion returns the version of the local Chef Infra Client when
+// --detect-chef is set, and nil otherwise
+func detectChefVersion(ctx context.Context) (*berkshelf.Version, error) {
+	if !viper.GetBool("detect-chef") {
+		return nil, nil
+	}
+
+	client, err := chefclient.Detect(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect Chef Infra Client: %w", err)
+	}
+	log.Infof("Enforcing chef_version constraints for Chef Infra Client %s", client)
+	return client.Version, nil
+}
+
+// loadEnvironment returns the Chef environment given by --environment when
+// it is set, and nil otherwise. A path to a JSON file is read, any other
+// value names an environment on the Chef Server.
+func loadEnvironment() (*server.Environment, error) {
+	name := viper.GetString("environment")
+	if name == "" {
+		return nil, nil
+	}
+
+	var env *server.Environment
+	if info, err := os.Stat(name); strings.HasSuffix(name, ".json") || (err == nil && !info.IsDir()) {
+		if env, err = server.LoadEnvironment(name); err != nil {
+			return nil, berrors.WithType(err, berrors.ErrorTypeConfiguration)
+		}
+	} else {
+		chefServer, err := newChefServerSource()
+		if err != nil {
+			return nil, berrors.WithType(fmt.Errorf("--environment %s: %w", name, err), berrors.ErrorTypeConfiguration)
+		}
+		if env, err = server.FetchEnvironment(chefServer.Client(), name); err != nil {
+			return nil, berrors.WithType(err, berrors.ErrorTypeNetwork)
+		}
+	}
+	log.Infof("Enforcing the cookbook constraints of %s", env.Origin())
+	return env, nil
+}
+
+// injectEnvironment constrains a resolution by the --environment
+// constraints, when it is set
+func injectEnvironment(r *resolver.DefaultResolver) error {
+	env, err := loadEnvironment()
+	if err != nil || env == nil {
+		return err
+	}
+	r.InjectRequirements(env.Origin(), env.Requirements()...)
+	return nil
+}
+
+// checkEnvironment fails when a cookbook locked in lockFile violates the
+// constraints of env
+func checkEnvironment(lockFile *lockfile.LockFile, env *server.Environment) error {
+	versions := make(map[string]*berkshelf.Version)
+	for name, cookbook := range lockFile.ListCookbooks() {
+		if v, err := berkshelf.NewVersion(cookbook.Version); err == nil {
+			versions[name] = v
+		}
+	}
+	violations := env.Violations(versions)
+	if len(violations) == 0 {
+		return nil
+	}
+	for _, violation := range violations {
+		log.Error(violation)
+	}
+	return berrors.WithType(fmt.Errorf("%d locked cookbook(s) violate %s; run 'berks install --force' to resolve within it", len(violations), env.Origin()), berrors.ErrorTypeResolution)
+}
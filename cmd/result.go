@@ -0,0 +1,232 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/audit"
+	"github.com/bdwyertech/go-berkshelf/pkg/cache"
+	"github.com/bdwyertech/go-berkshelf/pkg/doctor"
+	"github.com/bdwyertech/go-berkshelf/pkg/lockfile"
+	"github.com/bdwyertech/go-berkshelf/pkg/outdated"
+	"github.com/bdwyertech/go-berkshelf/pkg/policy"
+	"github.com/bdwyertech/go-berkshelf/pkg/workspace"
+)
+
+// Result is the JSON envelope written by install, vendor, update, outdated
+// audit, policy-check, ws install, shelf and doctor when --format json is passed
+type Result struct {
+	Command   string           `json:"command"`
+	Success   bool             `json:"success"`
+	Error     string           `json:"error,omitempty"`
+	Cookbooks []ResultCookbook `json:"cookbooks"`
+	Actions   []ResultAction   `json:"actions"`
+	Warnings  []string         `json:"warnings"`
+	Findings  []audit.Finding  `json:"findings,omitempty"`
+	// Violations are the dependency policy rules policy-check found broken
+	Violations []policy.Violation `json:"violations,omitempty"`
+	// Disagreements are the cookbooks a workspace's projects locked differently
+	Disagreements []workspace.Disagreement `json:"disagreements,omitempty"`
+	DurationsMS   map[string]int64         `json:"durations_ms"`
+	// Counts tallies outcomes, such as the uploads publish made and skipped
+	Counts map[string]int `json:"counts,omitempty"`
+	// Checks are the diagnostics run by doctor
+	Checks []doctor.Result `json:"checks,omitempty"`
+	// Shelf lists the cookbook versions in the cookbook cache
+	Shelf []ShelfCookbook `json:"shelf,omitempty"`
+
+	start time.Time
+}
+
+// ResultCookbook describes a cookbook affected by a command
+type ResultCookbook struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Latest  string `json:"latest,omitempty"`
+	Source  string `json:"source,omitempty"`
+	// Owners are the teams that own the cookbook, when configured
+	Owners []string `json:"owners,omitempty"`
+	// Deprecated cookbooks carry the suggested replacement and constraint
+	Deprecated  bool   `json:"deprecated,omitempty"`
+	Replacement string `json:"replacement,omitempty"`
+	Constraint  string `json:"constraint,omitempty"`
+	// Override is set for cookbooks pinned by a lock file override
+	Override bool `json:"override,omitempty"`
+	// Changelog holds the release notes up to Latest, with outdated --changelog
+	Changelog *outdated.Changelog `json:"changelog,omitempty"`
+}
+
+// ShelfCookbook is a cached cookbook version and the locked cookbooks that
+// depend on it
+type ShelfCookbook struct {
+	cache.ShelvedCookbook
+	// Locked is set when the project lock file locks this version
+	Locked     bool     `json:"locked,omitempty"`
+	Dependents []string `json:"dependents,omitempty"`
+}
+
+// ResultAction describes something a command did
+type ResultAction struct {
+	Type     string `json:"type"`
+	Cookbook string `json:"cookbook,omitempty"`
+	Detail   string `json:"detail,omitempty"`
+	// Target is the destination the action applied to, for commands with several
+	Target string `json:"target,omitempty"`
+}
+
+// newResult returns a result envelope when format is json, and nil otherwise.
+// All Result methods are no-ops on a nil receiver.
+func newResult(command, format string) *Result {
+	if !strings.EqualFold(format, "json") {
+		return nil
+	}
+	return &Result{
+		Command:     command,
+		Cookbooks:   []ResultCookbook{},
+		Actions:     []ResultAction{},
+		Warnings:    []string{},
+		DurationsMS: make(map[string]int64),
+		start:       time.Now(),
+	}
+}
+
+// checkFormat returns an error if format is not one of supported
+func checkFormat(format string, supported ...string) error {
+	for _, s := range supported {
+		if strings.EqualFold(format, s) {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported format: %s (supported: %s)", format, strings.Join(supported, ", "))
+}
+
+// Phase records the time elapsed since start under name
+func (r *Result) Phase(name string, start time.Time) {
+	if r == nil {
+		return
+	}
+	r.DurationsMS[name] = time.Since(start).Milliseconds()
+}
+
+// AddCookbook records an affected cookbook
+func (r *Result) AddCookbook(cookbook ResultCookbook) {
+	if r == nil {
+		return
+	}
+	r.Cookbooks = append(r.Cookbooks, cookbook)
+}
+
+// AddFinding records an audit finding
+func (r *Result) AddFinding(finding audit.Finding) {
+	if r == nil {
+		return
+	}
+	r.Findings = append(r.Findings, finding)
+}
+
+// AddViolation records a broken dependency policy rule
+func (r *Result) AddViolation(violation policy.Violation) {
+	if r == nil {
+		return
+	}
+	r.Violations = append(r.Violations, violation)
+}
+
+// AddCheck records the result of a doctor check
+func (r *Result) AddCheck(check doctor.Result) {
+	if r == nil {
+		return
+	}
+	r.Checks = append(r.Checks, check)
+}
+
+// AddShelved records a cookbook version in the cookbook cache
+func (r *Result) AddShelved(cookbook ShelfCookbook) {
+	if r == nil {
+		return
+	}
+	r.Shelf = append(r.Shelf, cookbook)
+}
+
+// AddDisagreement records a cookbook the projects of a workspace disagree on
+func (r *Result) AddDisagreement(disagreement workspace.Disagreement) {
+	if r == nil {
+		return
+	}
+	r.Disagreements = append(r.Disagreements, disagreement)
+}
+
+// AddLockFile records every cookbook in a lock file, sorted by name
+func (r *Result) AddLockFile(lf *lockfile.LockFile) {
+	if r == nil || lf == nil {
+		return
+	}
+	for sourceKey, src := range lf.Sources {
+		for name, cookbook := range src.Cookbooks {
+			r.Cookbooks = append(r.Cookbooks, ResultCookbook{Name: name, Version: cookbook.Version, Source: sourceKey, Override: lf.Overrides[name] != nil})
+		}
+	}
+	sort.Slice(r.Cookbooks, func(i, j int) bool {
+		return r.Cookbooks[i].Name < r.Cookbooks[j].Name
+	})
+}
+
+// Act records an action taken
+func (r *Result) Act(actionType, cookbook, detail string) {
+	if r == nil {
+		return
+	}
+	r.Actions = append(r.Actions, ResultAction{Type: actionType, Cookbook: cookbook, Detail: detail})
+}
+
+// ActFor records an action taken against one of several targets
+func (r *Result) ActFor(target, actionType, cookbook, detail string) {
+	if r == nil {
+		return
+	}
+	r.Actions = append(r.Actions, ResultAction{Type: actionType, Cookbook: cookbook, Detail: detail, Target: target})
+}
+
+// Count adds one to the tally of an outcome
+func (r *Result) Count(outcome string) {
+	if r == nil {
+		return
+	}
+	if r.Counts == nil {
+		r.Counts = make(map[string]int)
+	}
+	r.Counts[outcome]++
+}
+
+// Warn records a warning
+func (r *Result) Warn(format string, args ...any) {
+	if r == nil {
+		return
+	}
+	r.Warnings = append(r.Warnings, fmt.Sprintf(format, args...))
+}
+
+// Write finalizes the envelope with the command error and writes it to w.
+// It returns err unchanged so callers can `return result.Write(os.Stdout, err)`.
+func (r *Result) Write(w io.Writer, err error) error {
+	if r == nil {
+		return err
+	}
+
+	r.Success = err == nil
+	if err != nil {
+		r.Error = err.Error()
+	}
+	r.DurationsMS["total"] = time.Since(r.start).Milliseconds()
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if encodeErr := encoder.Encode(r); encodeErr != nil && err == nil {
+		return fmt.Errorf("failed to write result: %w", encodeErr)
+	}
+	return err
+}
@@ -1,8 +1,20 @@
 package cmd
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
 	log "github.com/sirupsen/logrus"
 
+	"github.com/bdwyertech/go-berkshelf/internal/config"
+	"github.com/bdwyertech/go-berkshelf/pkg/berksfile"
+	"github.com/bdwyertech/go-berkshelf/pkg/digest"
+	berrors "github.com/bdwyertech/go-berkshelf/pkg/errors"
+	"github.com/bdwyertech/go-berkshelf/pkg/logging"
+
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -10,6 +22,7 @@ import (
 var (
 	// Global flags
 	berksfilePath string
+	lockfilePath  string
 	configFile    string
 )
 
@@ -17,10 +30,22 @@ func init() {
 	cobra.OnInitialize(initConfig)
 
 	// Global flags
-	rootCmd.PersistentFlags().StringVarP(&berksfilePath, "berksfile", "b", "", "Path to Berksfile (default: ./Berksfile)")
-	rootCmd.PersistentFlags().StringVar(&configFile, "config", "", "Config file (default: $HOME/.berkshelf/config.json)")
+	rootCmd.PersistentFlags().StringVarP(&berksfilePath, "berksfile", "b", "", "Path to Berksfile (default: $BERKS_BERKSFILE or ./Berksfile)")
+	rootCmd.PersistentFlags().StringVar(&lockfilePath, "lockfile", "", "Path to the lock file (default: the lock file next to the Berksfile)")
+	rootCmd.PersistentFlags().StringVar(&configFile, "config", "", "Additional config file, merged over the global, user and project config files")
+	rootCmd.PersistentFlags().StringArray("set", nil, "Override a config value for this run, e.g. --set concurrency=10 (repeatable)")
 	rootCmd.PersistentFlags().BoolP("debug", "d", false, "Enable debug output")
 	rootCmd.PersistentFlags().Bool("no-color", false, "Disable colored output")
+	rootCmd.PersistentFlags().BoolP("yes", "y", false, "Assume yes for confirmation prompts (required for destructive commands in CI)")
+	rootCmd.PersistentFlags().Bool("wide", false, "Do not truncate table columns to the terminal width")
+	rootCmd.PersistentFlags().String("log-format", "text", "Log output format (text, json)")
+	rootCmd.PersistentFlags().String("log-level", "info", "Default log level (trace, debug, info, warn, error)")
+	rootCmd.PersistentFlags().StringSlice("log-levels", nil, "Per-subsystem log levels, e.g. resolver=debug,cache=warn")
+	rootCmd.PersistentFlags().Bool("keep-going", false, "After a cookbook fails to resolve or download, complete every other cookbook and report all failures, instead of stopping at the first")
+	rootCmd.PersistentFlags().String("profile", "", "Write pprof CPU and heap profiles of dependency resolution to <prefix>.cpu.pprof and <prefix>.heap.pprof")
+	rootCmd.PersistentFlags().String("chef-profile", "", "Chef Server profile from chef.profiles or ~/.chef/credentials to connect with (default: $CHEF_PROFILE)")
+
+	cobra.CheckErr(rootCmd.RegisterFlagCompletionFunc("chef-profile", completeChefProfiles))
 }
 
 // rootCmd represents the base command when called without any subcommands
@@ -34,35 +59,146 @@ It resolves cookbook dependencies from various sources including:
 - Chef Supermarket
 - Git repositories  
 - Local paths
-- Chef Server`,
-	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+- Chef Server
+
+A failed command exits with a code for the category of its error, and
+writes a final JSON line {"error": {"type": ..., "exit_code": ...,
+"message": ...}} to stderr:
+
+  1   other errors       14  filesystem
+  10  parsing            15  integrity (checksum mismatch)
+  11  resolution         16  configuration
+  12  network            17  validation
+  13  authentication
+
+'berks audit' exits 2 to 5 for the severity of its findings.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 		viper.BindPFlags(cmd.Flags())
+		if err := configureLogging(); err != nil {
+			return err
+		}
+		if err := configureConfig(cmd); err != nil {
+			return berrors.WithType(err, berrors.ErrorTypeConfiguration)
+		}
+		startMetrics()
+		startHooks(cmd)
+		return nil
 	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
+// A failed run exits with the code of its error category (see the root
+// command's help) after a JSON summary of the error on stderr.
 func Execute() error {
-	return rootCmd.Execute()
+	err := rootCmd.Execute()
+	exportMetrics()
+	if err != nil {
+		summary := berrors.Summarize(err)
+		var exit *exitError
+		if errors.As(err, &exit) {
+			summary.ExitCode = exit.code
+		}
+		writeErrorSummary(summary)
+		os.Exit(summary.ExitCode)
+	}
+	return err
+}
+
+// writeErrorSummary writes the final machine-readable line of a failed run
+// to stderr, for wrappers that act on the category of the failure
+func writeErrorSummary(summary berrors.Summary) {
+	line, err := json.Marshal(map[string]berrors.Summary{"error": summary})
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%s\n", line)
+}
+
+// exitError is returned by commands whose outcome is reported through a
+// specific process exit code
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
 }
 
 // initConfig reads in config file and ENV variables if set.
 func initConfig() {
-	if viper.GetBool("debug") || viper.GetBool("trace") {
-		log.SetLevel(log.DebugLevel)
-		if viper.GetBool("trace") {
-			log.SetReportCaller(true)
+	// Set default Berksfile path if not provided
+	if berksfilePath == "" {
+		berksfilePath = os.Getenv("BERKS_BERKSFILE")
+	}
+	if berksfilePath == "" {
+		berksfilePath = "Berksfile"
+	}
+	// TODO: Initialize color output based on noColor flag
+}
+
+// configureLogging applies the logging flags to all subsystem loggers
+func configureLogging() error {
+	level, err := log.ParseLevel(viper.GetString("log-level"))
+	if err != nil {
+		return fmt.Errorf("invalid --log-level: %w", err)
+	}
+	if viper.GetBool("debug") && level < log.DebugLevel {
+		level = log.DebugLevel
+	}
+
+	levels, err := logging.ParseLevels(viper.GetStringSlice("log-levels"))
+	if err != nil {
+		return err
+	}
+
+	return logging.Configure(logging.Options{
+		Format:       viper.GetString("log-format"),
+		Level:        level,
+		Levels:       levels,
+		ReportCaller: level == log.TraceLevel,
+	})
+}
+
+// configureConfig adds the --config file, --set overrides and the
+// --chef-profile selection to the config layers
+func configureConfig(cmd *cobra.Command) error {
+	opts := config.Options{File: configFile}
+
+	overrides, err := cmd.Flags().GetStringArray("set")
+	if err != nil {
+		return err
+	}
+	if profile, _ := cmd.Flags().GetString("chef-profile"); profile != "" {
+		overrides = append(overrides, "chef.profile="+profile)
+	}
+	if len(overrides) > 0 {
+		opts.Flags = &config.Config{}
+		for _, override := range overrides {
+			key, value, ok := strings.Cut(override, "=")
+			if !ok {
+				return fmt.Errorf("invalid --set %q: expected KEY=VALUE", override)
+			}
+			if err := opts.Flags.Set(strings.TrimSpace(key), value); err != nil {
+				return fmt.Errorf("invalid --set %q: %w", override, err)
+			}
 		}
 	}
 
 	if configFile != "" {
-		// TODO: Load configuration from file
-		// For now, we'll just acknowledge it
-		log.Debugf("Using config file: %s\n", configFile)
+		log.Debugf("Using config file: %s", configFile)
 	}
+	config.SetOptions(opts)
 
-	// Set default Berksfile path if not provided
-	if berksfilePath == "" {
-		berksfilePath = "Berksfile"
+	// Conditionals are evaluated as the Berksfile is parsed, so this has to be
+	// set before any command reads it
+	if cfg, err := config.Load(); err == nil {
+		berksfile.EvalEnv = cfg.GetEvalEnv()
+		digest.RequireFIPS(cfg.GetFIPS())
 	}
-	// TODO: Initialize color output based on noColor flag
+	return nil
 }
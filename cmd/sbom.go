@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/license"
+	"github.com/bdwyertech/go-berkshelf/pkg/sbom"
+)
+
+func init() {
+	rootCmd.AddCommand(sbomCmd)
+
+	sbomCmd.Flags().StringP("format", "f", "cyclonedx", "Output format (cyclonedx, spdx)")
+	sbomCmd.Flags().Bool("checksums", false, "Download Supermarket artifacts to record their SHA-256")
+	sbomCmd.Flags().Bool("licenses", false, "Read each cookbook's metadata to record its license")
+
+	registerFormatCompletion(sbomCmd, "cyclonedx", "spdx")
+}
+
+var sbomCmd = &cobra.Command{
+	Use:   "sbom",
+	Short: "Generate a software bill of materials from the lock file",
+	Long: `Convert the lock file into a CycloneDX 1.5 or SPDX 2.3 JSON document for
+supply-chain compliance pipelines. Each locked cookbook becomes a component
+with a package URL (pkg:supermarket/NAME@VERSION for Supermarket cookbooks,
+with the repository and git revision as qualifiers otherwise) and its
+dependency relationships.
+
+By default only the lock file is read. --checksums downloads each Supermarket
+artifact to record its SHA-256, and --licenses reads each cookbook's metadata
+to record its license, both using the sources and credentials configured for
+the Berksfile.
+
+Examples:
+  berks sbom > bom.cdx.json                          # CycloneDX
+  berks sbom --format spdx > bom.spdx.json           # SPDX
+  berks sbom --checksums --licenses > bom.cdx.json   # With hashes and licenses`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format := strings.ToLower(viper.GetString("format"))
+		if err := checkFormat(format, "cyclonedx", "spdx"); err != nil {
+			return err
+		}
+		cmd.SilenceUsage = true
+
+		bf, err := LoadBerksfile()
+		if err != nil {
+			return err
+		}
+		lockFile, manager, err := LoadLockFile()
+		if err != nil {
+			return err
+		}
+		if !manager.Exists() {
+			return fmt.Errorf("no lock file found. Run 'berks install' first")
+		}
+
+		dir, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("failed to get working directory: %w", err)
+		}
+		bom := sbom.New(filepath.Base(dir), lockFile)
+
+		var direct []string
+		for _, cookbook := range bf.GetCookbooks() {
+			direct = append(direct, cookbook.Name)
+		}
+		bom.SetDirect(direct)
+
+		if viper.GetBool("checksums") || viper.GetBool("licenses") {
+			sourceManager, err := CreateSourceManager(bf)
+			if err != nil {
+				return err
+			}
+			if viper.GetBool("checksums") {
+				if err := bom.AddChecksums(cmd.Context(), sourceManager); err != nil {
+					return fmt.Errorf("failed to fetch checksums: %w", err)
+				}
+			}
+			if viper.GetBool("licenses") {
+				entries, err := license.Collect(cmd.Context(), lockFile, sourceManager, nil)
+				if err != nil {
+					return fmt.Errorf("failed to collect licenses: %w", err)
+				}
+				bom.SetLicenses(entries)
+			}
+		}
+
+		if format == "spdx" {
+			return sbom.WriteSPDX(os.Stdout, bom)
+		}
+		return sbom.WriteCycloneDX(os.Stdout, bom)
+	},
+}
@@ -0,0 +1,186 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/bdwyertech/go-berkshelf/internal/config"
+	"github.com/bdwyertech/go-berkshelf/pkg/berks"
+	berrors "github.com/bdwyertech/go-berkshelf/pkg/errors"
+	"github.com/bdwyertech/go-berkshelf/pkg/metrics"
+	"github.com/bdwyertech/go-berkshelf/pkg/service"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// serveShutdownTimeout is how long requests in progress may take to finish
+// once the service is stopped
+const serveShutdownTimeout = 30 * time.Second
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+
+	// Add flags
+	serveCmd.Flags().String("listen", "127.0.0.1:8080", "Address to listen on")
+	serveCmd.Flags().Duration("refresh", 0, "How long sources keep what they have fetched before asking again (default: min_check_interval from config)")
+	serveCmd.Flags().String("token", "", "Bearer token clients must send (default: $BERKS_SERVE_TOKEN)")
+	serveCmd.Flags().String("tls-cert", "", "TLS certificate file; serves HTTPS with --tls-key")
+	serveCmd.Flags().String("tls-key", "", "TLS private key file")
+	serveCmd.Flags().StringSlice("trust-source", nil, "URL of a Chef Server, git, S3 or OCI source Berksfiles may name (repeatable)")
+	serveCmd.MarkFlagsRequiredTogether("tls-cert", "tls-key")
+}
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve dependency resolution over HTTP",
+	Long: `Serve dependency resolution over HTTP.
+
+The sources cookbooks are resolved from are kept between requests with the
+versions and metadata they have fetched, so many CI jobs can share one warm
+resolver rather than each querying the Supermarket again. Sources are
+created again after --refresh, the configured min_check_interval (15
+minutes) by default, to pick up new releases.
+
+Endpoints take a JSON body {"berksfile": "...", "lockfile": {...},
+"only": [...], "except": [...]}, where lockfile is the content of
+Berksfile.go.lock:
+
+  POST /v1/resolve   the resolved cookbooks and their dependencies
+  POST /v1/lock      the Berksfile.go.lock and Berksfile.lock of the resolution
+  POST /v1/outdated  the cookbooks of the lockfile with newer versions;
+                     "cookbooks": [...] limits the check
+  GET  /healthz      whether the service is up
+  GET  /metrics      resolution metrics in the Prometheus text format
+
+Overrides in the lockfile pin cookbooks as in 'berks install'. The service
+cannot read the client's disk, so path sources and the metadata directive
+are rejected. Other sources are reached with the credentials, API keys,
+group sources and source routes of the service's own config. Berksfiles may
+name any Supermarket, but Chef Server, git, S3 and OCI sources only when
+their URL is a default source, group source, source route or Chef Server of
+the config, or is given with --trust-source; client_key options and
+credential references are rejected. Berksfiles cannot read the service's
+environment or files: conditionals on ENV and File are skipped, and
+interpolated sources are rejected.
+
+Failures are answered with {"error": {"type": ..., "message": ...}} and a
+status for their category: 400 for invalid Berksfiles, 422 for unresolvable
+dependencies and 504 when resolve_timeout runs out.
+
+Set --token or $BERKS_SERVE_TOKEN to require clients to send
+"Authorization: Bearer <token>". Serve over HTTPS with --tls-cert and
+--tls-key when the service is reachable from other hosts.
+
+Examples:
+  berks serve                                  # Serve on 127.0.0.1:8080
+  berks serve --listen :8080 --refresh 5m      # Serve on all interfaces
+  curl -s localhost:8080/v1/resolve \
+    -d "$(jq -n --rawfile b Berksfile '{berksfile: $b}')"`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		options, err := berks.DefaultOptions()
+		if err != nil {
+			return berrors.WithType(err, berrors.ErrorTypeConfiguration)
+		}
+		refresh := viper.GetDuration("refresh")
+		if refresh == 0 {
+			refresh = options.MinCheckInterval
+		}
+		token := viper.GetString("token")
+		if token == "" {
+			token = os.Getenv("BERKS_SERVE_TOKEN")
+		}
+
+		// Metrics are served whether or not an exporter is configured
+		if registry == nil {
+			registry = metrics.NewRegistry()
+			metrics.Set(registry)
+		}
+
+		handler := service.New(service.Options{
+			Factory:        newSourceFactory(),
+			Refresh:        refresh,
+			GroupSources:   options.GroupSources,
+			SourceRoutes:   options.SourceRoutes,
+			TrustedSources: append(trustedSources(), viper.GetStringSlice("trust-source")...),
+			ResolveTimeout: options.ResolveTimeout,
+			Token:          token,
+			Metrics:        registry,
+		})
+
+		if token == "" {
+			log.Warn("No token is set; any client that can connect may resolve")
+		}
+		return listenAndServe(cmd, handler, "resolution")
+	},
+}
+
+// trustedSources returns the default sources and Chef Server URLs of the
+// config, which Berksfiles sent to the service may name
+func trustedSources() []string {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Warnf("Trusting no configured sources: %v", err)
+		return nil
+	}
+	trusted := slices.Clone(cfg.GetDefaultSources())
+	if url := cfg.ChefConfig.GetOrganizationURL(); url != "" {
+		trusted = append(trusted, url)
+	}
+	for _, profile := range chefServerProfiles(cfg.ChefConfig) {
+		if profile.URL != "" {
+			trusted = append(trusted, profile.URL)
+		}
+	}
+	return trusted
+}
+
+// listenAndServe serves handler on --listen, over HTTPS with --tls-cert and
+// --tls-key, until the command is interrupted, then lets the requests in
+// progress finish
+func listenAndServe(cmd *cobra.Command, handler http.Handler, what string) error {
+	listener, err := net.Listen("tcp", viper.GetString("listen"))
+	if err != nil {
+		return berrors.WithType(fmt.Errorf("failed to listen: %w", err), berrors.ErrorTypeConfiguration)
+	}
+	cmd.SilenceUsage = true
+
+	server := &http.Server{Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	served := make(chan error, 1)
+	go func() {
+		certFile, keyFile := viper.GetString("tls-cert"), viper.GetString("tls-key")
+		if certFile != "" {
+			log.Infof("Serving %s on https://%s", what, listener.Addr())
+			served <- server.ServeTLS(listener, certFile, keyFile)
+		} else {
+			log.Infof("Serving %s on http://%s", what, listener.Addr())
+			served <- server.Serve(listener)
+		}
+	}()
+
+	select {
+	case err := <-served:
+		return err
+	case <-ctx.Done():
+	}
+	log.Info("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), serveShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
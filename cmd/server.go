@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/bdwyertech/go-berkshelf/internal/config"
+	"github.com/bdwyertech/go-berkshelf/pkg/server"
+	"github.com/bdwyertech/go-berkshelf/pkg/source"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	rootCmd.AddCommand(serverCmd)
+	serverCmd.AddCommand(serverCleanCmd)
+
+	// Connection flags shared by all server subcommands
+	serverCmd.PersistentFlags().String("server-url", "", "Chef Server URL, including the organization (default: chef.chef_server_url from config)")
+	serverCmd.PersistentFlags().String("client-name", "", "Client name used to authenticate (default: chef.node_name from config)")
+	serverCmd.PersistentFlags().String("client-key", "", "Path to the client private key (default: chef.client_key from config)")
+
+	// Add flags
+	serverCleanCmd.Flags().Int("keep", 3, "Number of newest versions to keep for each cookbook")
+	serverCleanCmd.Flags().Bool("dry-run", false, "Show what would be deleted without deleting anything")
+}
+
+var serverCmd = &cobra.Command{
+	Use:   "server",
+	Short: "Maintenance commands for a Chef Server",
+	Long: `Maintenance commands for a Chef Server.
+
+Connection settings are read from the chef section of the berkshelf config,
+or the profile selected with --chef-profile or $CHEF_PROFILE, and may be
+overridden with --server-url, --client-name and --client-key.`,
+}
+
+var serverCleanCmd = &cobra.Command{
+	Use:   "clean [COOKBOOK...]",
+	Short: "Delete old cookbook versions from the Chef Server",
+	Long: `Delete old cookbook versions from the Chef Server.
+
+For each cookbook the newest --keep versions are retained. Versions selected
+by an environment's cookbook constraints or locked by any policy revision are
+never deleted.
+
+The versions to delete are listed and must be confirmed. In CI or without a
+terminal, pass --yes to proceed.
+
+Examples:
+  berks server clean --keep 5             # Keep the 5 newest versions of every cookbook
+  berks server clean --keep 2 nginx       # Only clean the nginx cookbook
+  berks server clean --keep 3 --dry-run   # Show what would be deleted
+  berks server clean --keep 3 --yes       # Delete without prompting (e.g. in CI)`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		chefServer, err := newChefServerSource()
+		if err != nil {
+			return err
+		}
+
+		aborted := false
+		prompter := newPrompter()
+		options := server.CleanOptions{
+			Keep:      viper.GetInt("keep"),
+			DryRun:    viper.GetBool("dry-run"),
+			Cookbooks: args,
+			Confirm: func(planned []server.Deletion) (bool, error) {
+				for _, d := range planned {
+					fmt.Fprintf(os.Stderr, "  %s (%s)\n", d.Name, d.Version)
+				}
+				ok, err := prompter.Confirm(fmt.Sprintf("Delete %d cookbook version(s) from the Chef Server?", len(planned)))
+				aborted = !ok
+				return ok, err
+			},
+		}
+
+		cleaner := server.NewCleaner(chefServer.Client(), options)
+		result, err := cleaner.Clean(cmd.Context())
+		if err != nil {
+			return fmt.Errorf("server clean failed: %w", err)
+		}
+
+		if aborted {
+			fmt.Println("Aborted.")
+			return nil
+		}
+
+		if len(result.Deleted) == 0 && len(result.Failed) == 0 {
+			fmt.Println("Nothing to clean.")
+			return nil
+		}
+
+		verb := "Deleted"
+		if options.DryRun {
+			verb = "Would delete"
+		}
+		for _, d := range result.Deleted {
+			fmt.Printf("%s %s (%s)\n", verb, d.Name, d.Version)
+		}
+
+		if len(result.Failed) > 0 {
+			for name, errMsg := range result.Failed {
+				log.Warnf("Failed to delete %s: %s", name, errMsg)
+			}
+			return fmt.Errorf("failed to delete %d cookbook version(s)", len(result.Failed))
+		}
+
+		return nil
+	},
+}
+
+// newChefServerSource connects to the Chef Server using flags, falling back to the berkshelf config
+func newChefServerSource() (*source.ChefServerSource, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	serverURL := viper.GetString("server-url")
+	if serverURL == "" {
+		serverURL = cfg.ChefConfig.GetOrganizationURL()
+	}
+	clientName := viper.GetString("client-name")
+	if clientName == "" {
+		clientName = cfg.ChefConfig.GetNodeName()
+	}
+	clientKey := viper.GetString("client-key")
+	if clientKey == "" {
+		clientKey = cfg.ChefConfig.GetClientKey()
+	}
+
+	if serverURL == "" || clientName == "" || clientKey == "" {
+		return nil, fmt.Errorf("chef server URL, client name and client key are required (set them in config or pass --server-url, --client-name and --client-key)")
+	}
+
+	return source.NewChefServerSource(serverURL, clientName, clientKey)
+}
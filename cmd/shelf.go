@@ -0,0 +1,314 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/bdwyertech/go-berkshelf/internal/config"
+	"github.com/bdwyertech/go-berkshelf/pkg/cache"
+	"github.com/bdwyertech/go-berkshelf/pkg/lockfile"
+)
+
+func init() {
+	rootCmd.AddCommand(shelfCmd)
+	shelfCmd.AddCommand(shelfListCmd)
+	shelfCmd.AddCommand(shelfShowCmd)
+	shelfCmd.AddCommand(shelfUninstallCmd)
+
+	for _, cmd := range []*cobra.Command{shelfListCmd, shelfShowCmd} {
+		cmd.Flags().StringP("format", "f", "table", "Output format (table, json)")
+		registerFormatCompletion(cmd, "table", "json")
+	}
+
+	shelfListCmd.ValidArgsFunction = completeShelvedCookbooks
+	shelfShowCmd.ValidArgsFunction = completeShelvedVersions
+	shelfUninstallCmd.ValidArgsFunction = completeShelvedVersions
+}
+
+var shelfCmd = &cobra.Command{
+	Use:   "shelf",
+	Short: "Manage the cookbooks in the cookbook cache",
+	Long: `List, inspect and remove the cookbook versions kept in the cookbook cache
+(cache_path, see 'berks cache path'), as 'berks shelf' did in Ruby Berkshelf.`,
+}
+
+var shelfListCmd = &cobra.Command{
+	Use:   "list [COOKBOOK...]",
+	Short: "List the cookbook versions in the cookbook cache",
+	Long: `List the cookbook versions in the cookbook cache, sorted by name and
+version. Versions the project lock file locks are marked.
+
+Examples:
+  berks shelf list                # Every cached cookbook version
+  berks shelf list nginx apt      # Cached versions of nginx and apt
+  berks shelf list --format json  # Include paths and checksums`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format := strings.ToLower(viper.GetString("format"))
+		if err := checkFormat(format, "table", "json"); err != nil {
+			return err
+		}
+
+		result := newResult("shelf", format)
+		cookbooks, err := shelvedCookbooks(args, nil)
+		if err != nil {
+			cmd.SilenceUsage = true
+			return result.Write(os.Stdout, err)
+		}
+		if result != nil {
+			for _, cookbook := range cookbooks {
+				result.AddShelved(cookbook)
+			}
+			return result.Write(os.Stdout, nil)
+		}
+
+		if len(cookbooks) == 0 {
+			fmt.Println("No cookbooks in the cache.")
+			return nil
+		}
+		table := newTable("COOKBOOK", "VERSION", "SIZE", "CACHED", "LOCKED")
+		for _, cookbook := range cookbooks {
+			locked := ""
+			if cookbook.Locked {
+				locked = "yes"
+			}
+			table.Append(cookbook.Name, cookbook.Version, formatSize(cookbook.Size),
+				cookbook.CreatedAt.Format("2006-01-02"), locked)
+		}
+		return table.Render(os.Stdout)
+	},
+}
+
+var shelfShowCmd = &cobra.Command{
+	Use:   "show COOKBOOK [VERSION...]",
+	Short: "Show where cached versions of a cookbook are and what uses them",
+	Long: `Show the path, size, checksum and age of the cached versions of a
+cookbook, and the locked cookbooks that depend on the version the project
+lock file locks. Without versions, every cached version is shown.
+
+Examples:
+  berks shelf show nginx         # Every cached version of nginx
+  berks shelf show nginx 12.0.0  # One version`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format := strings.ToLower(viper.GetString("format"))
+		if err := checkFormat(format, "table", "json"); err != nil {
+			return err
+		}
+		cmd.SilenceUsage = true
+
+		result := newResult("shelf", format)
+		cookbooks, err := shelvedCookbooks(args[:1], args[1:])
+		if err == nil && len(cookbooks) == 0 {
+			err = fmt.Errorf("no cached versions of %s", describeVersions(args[0], args[1:]))
+		}
+		if err != nil {
+			return result.Write(os.Stdout, err)
+		}
+		if result != nil {
+			for _, cookbook := range cookbooks {
+				result.AddShelved(cookbook)
+			}
+			return result.Write(os.Stdout, nil)
+		}
+
+		for i, cookbook := range cookbooks {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Printf("%s (%s)\n", cookbook.Name, cookbook.Version)
+			fmt.Printf("  Path:      %s\n", cookbook.Path)
+			fmt.Printf("  Size:      %s\n", formatSize(cookbook.Size))
+			if cookbook.Checksum != "" {
+				fmt.Printf("  Checksum:  %s\n", cookbook.Checksum)
+			}
+			fmt.Printf("  Cached:    %s\n", cookbook.CreatedAt.Format("2006-01-02 15:04:05"))
+			fmt.Printf("  Last used: %s\n", cookbook.AccessedAt.Format("2006-01-02 15:04:05"))
+			if cookbook.Locked {
+				fmt.Printf("  Locked:    yes, %s\n", describeDependents(cookbook.Dependents))
+			}
+		}
+		return nil
+	},
+}
+
+var shelfUninstallCmd = &cobra.Command{
+	Use:   "uninstall COOKBOOK [VERSION...]",
+	Short: "Remove cached versions of a cookbook",
+	Long: `Remove versions of a cookbook from the cookbook cache, or every cached
+version when none is given.
+
+Removing a version the project lock file locks is only a cache miss, as the
+next install downloads it again, but you are warned, told which locked
+cookbooks depend on it, and asked to confirm. In CI or without a terminal,
+pass --yes to proceed.
+
+Examples:
+  berks shelf uninstall nginx          # Every cached version of nginx
+  berks shelf uninstall nginx 11.0.0   # One version
+  berks shelf uninstall nginx --yes    # Without asking about locked versions`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		name, versions := args[0], args[1:]
+
+		c, err := cookbookCache()
+		if err != nil {
+			return err
+		}
+		cookbooks, err := shelvedCookbooks(args[:1], versions)
+		if err != nil {
+			return err
+		}
+		if len(cookbooks) == 0 {
+			return fmt.Errorf("no cached versions of %s", describeVersions(name, versions))
+		}
+
+		var inUse bool
+		for _, cookbook := range cookbooks {
+			if cookbook.Locked {
+				inUse = true
+				log.Warnf("%s %s is locked in the lock file, %s", cookbook.Name, cookbook.Version, describeDependents(cookbook.Dependents))
+			}
+		}
+		if inUse {
+			ok, err := newPrompter().Confirm("Remove the locked versions from the cache anyway?")
+			if err != nil {
+				return err
+			}
+			if !ok {
+				fmt.Println("Aborted.")
+				return nil
+			}
+		}
+
+		for _, cookbook := range cookbooks {
+			if _, err := c.DeleteCookbook(cookbook.Name, cookbook.Version); err != nil {
+				return fmt.Errorf("failed to remove %s %s: %w", cookbook.Name, cookbook.Version, err)
+			}
+			fmt.Printf("Removed %s (%s)\n", cookbook.Name, cookbook.Version)
+		}
+		return nil
+	},
+}
+
+// cookbookCache opens the cookbook cache at cache_path
+func cookbookCache() (*cache.Cache, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	return cache.NewCache(cfg.GetCachePathResolved(), 0, 0)
+}
+
+// shelvedCookbooks returns the cached versions of the named cookbooks, or of
+// every cookbook without names, limited to versions when given, and marks
+// those the project lock file uses. A lock file that cannot be read is
+// logged and treated as locking nothing.
+func shelvedCookbooks(names, versions []string) ([]ShelfCookbook, error) {
+	c, err := cookbookCache()
+	if err != nil {
+		return nil, err
+	}
+	cached, err := c.Cookbooks(names...)
+	if err != nil {
+		return nil, err
+	}
+
+	lockFile, _, err := LoadLockFile()
+	if err != nil {
+		log.Debugf("Not checking which cached cookbooks are locked: %v", err)
+	}
+
+	var cookbooks []ShelfCookbook
+	for _, cookbook := range cached {
+		if len(versions) > 0 && !slices.Contains(versions, cookbook.Version) {
+			continue
+		}
+		cookbooks = append(cookbooks, shelfCookbook(cookbook, lockFile))
+	}
+	return cookbooks, nil
+}
+
+func shelfCookbook(cookbook cache.ShelvedCookbook, lockFile *lockfile.LockFile) ShelfCookbook {
+	locked, dependents := cache.LockUsage(lockFile, cookbook.Name, cookbook.Version)
+	return ShelfCookbook{ShelvedCookbook: cookbook, Locked: locked, Dependents: dependents}
+}
+
+// describeVersions names a cookbook and the versions asked for, e.g. "nginx 1.0.0, 1.1.0"
+func describeVersions(name string, versions []string) string {
+	if len(versions) == 0 {
+		return name
+	}
+	return name + " " + strings.Join(versions, ", ")
+}
+
+// describeDependents says which locked cookbooks depend on a locked version
+func describeDependents(dependents []string) string {
+	if len(dependents) == 0 {
+		return "required by the Berksfile"
+	}
+	return "required by " + strings.Join(dependents, ", ")
+}
+
+// formatSize formats a size in bytes with a binary unit, e.g. "1.5 MiB"
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// completeShelvedCookbooks completes the names of cached cookbooks
+func completeShelvedCookbooks(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	c, err := cookbookCache()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	cookbooks, err := c.Cookbooks()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var names []string
+	for _, cookbook := range cookbooks {
+		if strings.HasPrefix(cookbook.Name, toComplete) && !slices.Contains(args, cookbook.Name) && !slices.Contains(names, cookbook.Name) {
+			names = append(names, cookbook.Name)
+		}
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeShelvedVersions completes a cached cookbook name, then its cached versions
+func completeShelvedVersions(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) == 0 {
+		return completeShelvedCookbooks(cmd, args, toComplete)
+	}
+	c, err := cookbookCache()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	cookbooks, err := c.Cookbooks(args[0])
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var versions []string
+	for _, cookbook := range cookbooks {
+		if strings.HasPrefix(cookbook.Version, toComplete) && !slices.Contains(args[1:], cookbook.Version) {
+			versions = append(versions, cookbook.Version)
+		}
+	}
+	return versions, cobra.ShellCompDirectiveNoFileComp
+}
@@ -0,0 +1,335 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/bdwyertech/go-berkshelf/internal/config"
+	"github.com/bdwyertech/go-berkshelf/pkg/credentials"
+	berrors "github.com/bdwyertech/go-berkshelf/pkg/errors"
+	"github.com/bdwyertech/go-berkshelf/pkg/signing"
+	"github.com/bdwyertech/go-berkshelf/pkg/source"
+)
+
+var (
+	signingKeyPrefix  string
+	signingKeyFlag    string
+	signingPublicKeys []string
+	signingForce      bool
+)
+
+func init() {
+	rootCmd.AddCommand(signingCmd)
+	signingCmd.AddCommand(signingGenerateKeyCmd, signingSignCmd, signingVerifyCmd)
+
+	signingGenerateKeyCmd.Flags().StringVar(&signingKeyPrefix, "output-prefix", "cosign", "Write the key pair to PREFIX.key and PREFIX.pub")
+	signingGenerateKeyCmd.Flags().BoolVar(&signingForce, "force", false, "Overwrite an existing key pair")
+	signingSignCmd.Flags().StringVar(&signingKeyFlag, "key", "", "Private key path, or an env:/keychain: reference (defaults to signing.key)")
+	signingVerifyCmd.Flags().StringSliceVar(&signingPublicKeys, "key", nil, "Trusted public key path(s) (defaults to signing.public_keys)")
+}
+
+var signingCmd = &cobra.Command{
+	Use:   "signing",
+	Short: "Sign cookbook artifacts and verify their signatures",
+	Long: `Sign cookbook artifacts with a key pair and verify them against a trust policy.
+
+When signing.key is configured, 'berks publish' signs each cookbook it uploads
+to a Supermarket or Artifactory and puts the signature in the signature store
+as NAME/VERSION.sig. When signing.public_keys are configured, every cookbook
+downloaded from a Supermarket source the policy covers must have a signature
+by one of those keys, or it is refused before it is extracted:
+
+  "signing": {
+    "key": "keychain:berks-signing",
+    "public_keys": ["/etc/berkshelf/cosign.pub"],
+    "store": "https://artifactory.example.com/artifactory/cookbook-signatures",
+    "store_token": "env:SIGNATURE_STORE_TOKEN",
+    "sources": ["https://supermarket.example.com"]
+  }
+
+The store is a directory or an http(s) URL accepting GET and PUT. An empty
+sources list applies the policy to every Supermarket source. Keys are Ed25519
+or ECDSA, PEM encoded as PKCS #8 private and PKIX public keys, so cosign
+key pairs exported as PEM work too. Keyless signing is not supported.
+
+Examples:
+  berks signing generate-key
+  berks signing sign Berksfile.lock.json
+  berks signing verify Berksfile.lock.json --key cosign.pub`,
+}
+
+var signingGenerateKeyCmd = &cobra.Command{
+	Use:   "generate-key",
+	Short: "Generate an Ed25519 signing key pair",
+	Long: `Generate an Ed25519 key pair, written to cosign.key and cosign.pub unless
+--output-prefix is given. Keep the private key secret, e.g. in the OS keychain
+with 'berks credentials set', and distribute the public key to the machines
+that verify.
+
+Examples:
+  berks signing generate-key
+  berks signing generate-key --output-prefix release`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		privatePath, publicPath := signingKeyPrefix+".key", signingKeyPrefix+".pub"
+		if !signingForce {
+			for _, path := range []string{privatePath, publicPath} {
+				if _, err := os.Stat(path); err == nil {
+					return fmt.Errorf("%s already exists, use --force to overwrite it", path)
+				}
+			}
+		}
+
+		privateKey, publicKey, err := signing.GenerateKey()
+		if err != nil {
+			return fmt.Errorf("failed to generate key: %w", err)
+		}
+		if err := os.WriteFile(privatePath, privateKey, 0600); err != nil {
+			return err
+		}
+		if err := os.WriteFile(publicPath, publicKey, 0644); err != nil {
+			return err
+		}
+
+		fmt.Printf("Private key written to %s\nPublic key written to %s\n", privatePath, publicPath)
+		return nil
+	},
+}
+
+var signingSignCmd = &cobra.Command{
+	Use:   "sign FILE...",
+	Short: "Sign files such as lock files and cookbook tarballs",
+	Long: `Sign each FILE with the signing key, writing the signature next to it as
+FILE.sig.
+
+Examples:
+  berks signing sign Berksfile.lock.json
+  berks signing sign apt-7.5.0.tar.gz --key keychain:berks-signing`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		key := signingKeyFlag
+		if key == "" {
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+			key = cfg.GetSigning().GetKey()
+		}
+		if key == "" {
+			return fmt.Errorf("no signing key, use --key or set signing.key")
+		}
+		signer, err := loadSigner(key)
+		if err != nil {
+			return err
+		}
+
+		for _, path := range args {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			signature, err := signer.Sign(data)
+			if err != nil {
+				return fmt.Errorf("failed to sign %s: %w", path, err)
+			}
+			encoded, err := signing.Encode(signature)
+			if err != nil {
+				return err
+			}
+			if err := os.WriteFile(path+signing.Extension, encoded, 0644); err != nil {
+				return err
+			}
+			fmt.Printf("Signed %s (%s)\n", path, signature.Digest)
+		}
+		return nil
+	},
+}
+
+var signingVerifyCmd = &cobra.Command{
+	Use:   "verify FILE...",
+	Short: "Verify the signatures of signed files",
+	Long: `Verify that each FILE.sig signs FILE with a trusted public key. The command
+fails if any signature is missing or invalid.
+
+Examples:
+  berks signing verify Berksfile.lock.json
+  berks signing verify Berksfile.lock.json --key cosign.pub`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		keys := signingPublicKeys
+		if len(keys) == 0 {
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+			keys = cfg.GetSigning().GetPublicKeys()
+		}
+		if len(keys) == 0 {
+			return fmt.Errorf("no trusted public keys, use --key or set signing.public_keys")
+		}
+		verifier, err := loadVerifier(keys)
+		if err != nil {
+			return err
+		}
+
+		var failed []error
+		for _, path := range args {
+			if err := verifyFile(verifier, path); err != nil {
+				failed = append(failed, fmt.Errorf("%s: %w", path, err))
+				continue
+			}
+			fmt.Printf("Verified %s\n", path)
+		}
+		return errors.Join(failed...)
+	},
+}
+
+// verifyFile checks path against the signature in path.sig
+func verifyFile(verifier *signing.Verifier, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	encoded, err := os.ReadFile(path + signing.Extension)
+	if errors.Is(err, os.ErrNotExist) {
+		return signing.ErrNotSigned
+	} else if err != nil {
+		return err
+	}
+	signature, err := signing.Decode(encoded)
+	if err != nil {
+		return err
+	}
+	return verifier.Verify(data, signature)
+}
+
+// loadSigner reads the private key at a path, or the key an env: or
+// keychain: reference resolves to
+func loadSigner(key string) (*signing.Signer, error) {
+	var data []byte
+	if credentials.IsReference(key) {
+		secret, err := credentials.Resolve(key)
+		if err != nil {
+			return nil, fmt.Errorf("signing key: %w", err)
+		}
+		data = []byte(secret)
+	} else {
+		var err error
+		if data, err = os.ReadFile(expandHome(key)); err != nil {
+			return nil, fmt.Errorf("reading signing key: %w", err)
+		}
+	}
+	signer, err := signing.NewSigner(data)
+	if err != nil {
+		return nil, fmt.Errorf("signing key: %w", err)
+	}
+	return signer, nil
+}
+
+// loadVerifier trusts the public keys at paths
+func loadVerifier(paths []string) (*signing.Verifier, error) {
+	keys := make([][]byte, len(paths))
+	for i, path := range paths {
+		data, err := os.ReadFile(expandHome(path))
+		if err != nil {
+			return nil, fmt.Errorf("reading public key: %w", err)
+		}
+		keys[i] = data
+	}
+	verifier, err := signing.NewVerifier(keys...)
+	if err != nil {
+		return nil, fmt.Errorf("public keys: %w", err)
+	}
+	return verifier, nil
+}
+
+// signatureStore opens the configured signature store
+func signatureStore(cfg *config.SigningConfig) (*signing.Store, error) {
+	if cfg.GetStore() == "" {
+		return nil, fmt.Errorf("no signature store, set signing.store")
+	}
+	token, err := credentials.Resolve(cfg.GetStoreToken())
+	if err != nil {
+		return nil, fmt.Errorf("signature store token: %w", err)
+	}
+	return signing.NewStore(expandHome(cfg.GetStore()), token), nil
+}
+
+// publishSigner returns the signer and store publish signs cookbooks with,
+// or nil when no signing key is configured
+func publishSigner(cfg *config.Config) (*signing.Signer, *signing.Store, error) {
+	signingConfig := cfg.GetSigning()
+	if signingConfig.GetKey() == "" {
+		return nil, nil, nil
+	}
+	signer, err := loadSigner(signingConfig.GetKey())
+	if err != nil {
+		return nil, nil, err
+	}
+	store, err := signatureStore(signingConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	return signer, store, nil
+}
+
+// trustPolicy returns the verifier for each Supermarket source URL, nil for
+// the sources the configured trust policy does not cover. A policy that
+// cannot be loaded refuses every download, rather than letting unverified
+// cookbooks through.
+func trustPolicy(cfg *config.SigningConfig) func(url string) source.ArtifactVerifier {
+	policy, err := loadTrustPolicy(cfg)
+	if err != nil {
+		log.Warnf("Refusing cookbook downloads: %v", err)
+		refuse := refuseArtifacts{err: berrors.WithType(fmt.Errorf("trust policy: %w", err), berrors.ErrorTypeConfiguration)}
+		return func(string) source.ArtifactVerifier { return refuse }
+	}
+	return func(url string) source.ArtifactVerifier {
+		if !policy.Covers(url) {
+			return nil
+		}
+		return policy
+	}
+}
+
+// loadTrustPolicy builds the configured trust policy
+func loadTrustPolicy(cfg *config.SigningConfig) (*signing.Policy, error) {
+	verifier, err := loadVerifier(cfg.GetPublicKeys())
+	if err != nil {
+		return nil, err
+	}
+	store, err := signatureStore(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return signing.NewPolicy(verifier, store, cfg.GetSources()...), nil
+}
+
+// refuseArtifacts fails every verification with the error that kept the
+// trust policy from loading
+type refuseArtifacts struct {
+	err error
+}
+
+func (r refuseArtifacts) VerifyArtifact(ctx context.Context, name, version, sha256Hex string) error {
+	return r.err
+}
+
+// expandHome expands a leading ~/ to the home directory
+func expandHome(path string) string {
+	if strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[2:])
+		}
+	}
+	return path
+}
@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berksfile"
+	"github.com/bdwyertech/go-berkshelf/pkg/kitchen"
+)
+
+func init() {
+	rootCmd.AddCommand(suitesCmd)
+
+	suitesCmd.Flags().Bool("stubs", false, "Print Berksfile groups declaring the cookbooks suites use but the Berksfile does not")
+	suitesCmd.Flags().StringP("format", "f", "table", "Output format (table, json)")
+
+	registerFormatCompletion(suitesCmd, "table", "json")
+}
+
+var suitesCmd = &cobra.Command{
+	Use:   "suites",
+	Short: "Show how Test Kitchen suites map to Berksfile groups",
+	Long: `Read the suites in kitchen.yml (or .kitchen.yml, or $KITCHEN_YAML) and show
+the cookbooks each one converges: the Berksfile group named after the suite,
+if there is one, plus the cookbooks in the suite's run list.
+
+Cookbooks a run list names that the Berksfile does not declare are flagged.
+With --stubs, a Berksfile group is printed for each suite that has some,
+ready to paste; test fixture cookbooks are declared with their path.
+
+ERB in the kitchen file is ignored rather than evaluated.
+
+Examples:
+  berks suites                  # Show each suite's group and cookbooks
+  berks suites --stubs          # Print group stubs for the Berksfile
+  berks vendor --suite default  # Vendor what the default suite converges`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format := strings.ToLower(viper.GetString("format"))
+		if err := checkFormat(format, "table", "json"); err != nil {
+			return err
+		}
+
+		bf, err := LoadBerksfile()
+		if err != nil {
+			return err
+		}
+		mappings, err := loadSuiteMappings(bf)
+		if err != nil {
+			return err
+		}
+
+		if viper.GetBool("stubs") {
+			var stubs []string
+			for _, mapping := range mappings {
+				if stub := mapping.Stub("."); stub != "" {
+					stubs = append(stubs, stub)
+				}
+			}
+			if len(stubs) == 0 {
+				log.Info("Every suite's cookbooks are declared in the Berksfile")
+				return nil
+			}
+			fmt.Print(strings.Join(stubs, "\n"))
+			return nil
+		}
+
+		if format == "json" {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(mappings)
+		}
+
+		table := newTable("SUITE", "GROUP", "COOKBOOKS", "UNDECLARED")
+		for _, mapping := range mappings {
+			table.Append(mapping.Suite, mapping.Group, strings.Join(mapping.Cookbooks, ", "), strings.Join(mapping.Undeclared, ", "))
+		}
+		return table.Render(os.Stdout)
+	},
+}
+
+// loadSuiteMappings reads the kitchen file in the current directory and maps
+// its suites to bf
+func loadSuiteMappings(bf *berksfile.Berksfile) ([]kitchen.Mapping, error) {
+	path, err := kitchen.Find(".")
+	if err != nil {
+		return nil, err
+	}
+	config, err := kitchen.Load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	metadataName := ""
+	if bf.HasMetadata {
+		if metadataName, err = cookbookName("."); err != nil {
+			return nil, err
+		}
+	}
+	return kitchen.Map(config, bf, metadataName), nil
+}
+
+// suiteCookbooks returns the cookbooks the named suites converge, warning
+// about run list cookbooks the Berksfile does not declare
+func suiteCookbooks(bf *berksfile.Berksfile, suites []string, result *Result) ([]string, error) {
+	mappings, err := loadSuiteMappings(bf)
+	if err != nil {
+		return nil, err
+	}
+
+	var cookbooks []string
+	for _, suite := range suites {
+		var mapping *kitchen.Mapping
+		for i := range mappings {
+			if mappings[i].Suite == suite {
+				mapping = &mappings[i]
+				break
+			}
+		}
+		if mapping == nil {
+			return nil, fmt.Errorf("no suite %q in the kitchen file (see 'berks suites')", suite)
+		}
+		if len(mapping.Undeclared) > 0 {
+			log.Warnf("Suite %s uses cookbook(s) the Berksfile does not declare: %s (see 'berks suites --stubs')",
+				suite, strings.Join(mapping.Undeclared, ", "))
+			result.Warn("suite %s uses undeclared cookbook(s): %s", suite, strings.Join(mapping.Undeclared, ", "))
+		}
+		cookbooks = append(cookbooks, mapping.Cookbooks...)
+	}
+	return cookbooks, nil
+}
+
+// completeSuiteNames completes --suite with the suites in the kitchen file
+func completeSuiteNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	path, err := kitchen.Find(".")
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	config, err := kitchen.Load(path)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	names := make([]string, 0, len(config.Suites))
+	for _, suite := range config.Suites {
+		names = append(names, suite.Name)
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
@@ -1,16 +1,27 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
 	"github.com/bdwyertech/go-berkshelf/pkg/berksfile"
 	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	"github.com/bdwyertech/go-berkshelf/pkg/changelog"
+	berrors "github.com/bdwyertech/go-berkshelf/pkg/errors"
+	"github.com/bdwyertech/go-berkshelf/pkg/hooks"
 	"github.com/bdwyertech/go-berkshelf/pkg/lockfile"
+	"github.com/bdwyertech/go-berkshelf/pkg/outdated"
+	"github.com/bdwyertech/go-berkshelf/pkg/report"
 	"github.com/bdwyertech/go-berkshelf/pkg/resolver"
+	"github.com/bdwyertech/go-berkshelf/pkg/source"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 var (
@@ -24,6 +35,15 @@ func init() {
 	// Add flags
 	updateCmd.Flags().StringSliceVar(&updateExcept, "except", []string{}, "Exclude groups from update")
 	updateCmd.Flags().StringSliceVar(&updateOnly, "only", []string{}, "Include only specified groups")
+	updateCmd.Flags().String("format", "text", "Output format (text, json)")
+	updateCmd.Flags().Bool("detect-chef", false, "Only select cookbook versions whose chef_version supports the local chef-client/cinc-client")
+	updateCmd.Flags().String("environment", "", "Constrain cookbooks to a Chef environment's cookbook_versions, from the Chef Server or a JSON file")
+	updateCmd.Flags().String("report", "", "Write a report of the lock file changes (markdown)")
+	updateCmd.Flags().String("report-file", "", "Write the report to this file instead of stdout")
+
+	updateCmd.ValidArgsFunction = completeCookbookNames
+	registerGroupCompletion(updateCmd)
+	registerFormatCompletion(updateCmd, "text", "json")
 }
 
 var updateCmd = &cobra.Command{
@@ -40,161 +60,308 @@ This command will:
 3. Resolve dependencies with updated constraints
 4. Update the lock file with new versions
 
+With --detect-chef, cookbook versions whose chef_version excludes the local
+chef-client or cinc-client are skipped. With --environment, versions are
+only chosen within the cookbook_versions constraints of a Chef environment,
+named on the Chef Server or given as a JSON file (see 'berks install').
+
+With --report markdown, a report of what changed in the lock file is written
+for a pull request description or a CI bot comment: the cookbooks added,
+removed, upgraded and downgraded, whether each new version satisfies the
+Berksfile and the cookbooks depending on it, and links to their changelogs.
+
 Examples:
   berks update              # Update all cookbooks
   berks update nginx        # Update only nginx cookbook
-  berks update nginx apache # Update nginx and apache cookbooks`,
+  berks update nginx apache # Update nginx and apache cookbooks
+  berks update --environment environments/production.json # Stay within production
+  berks update --format json # Print a JSON result when done
+  berks update --detect-chef # Only pick versions that support the local chef-client
+  berks update --report markdown --report-file update.md # Write a pull request report`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		log.Infoln("Updating cookbook dependencies...")
-
-		// Parse Berksfile
-		bf, err := LoadBerksfile()
-		if err != nil {
+		format := viper.GetString("format")
+		if err := checkFormat(format, "text", "json"); err != nil {
 			return err
 		}
-
-		// Get cookbooks to update
-		var cookbooksToUpdate []*berksfile.CookbookDef
-		if len(args) > 0 {
-			// Update specific cookbooks
-			requestedSet := make(map[string]bool)
-			for _, name := range args {
-				requestedSet[name] = true
+		if report := viper.GetString("report"); report != "" {
+			if err := checkFormat(report, "markdown"); err != nil {
+				return err
 			}
-
-			for _, cookbook := range bf.Cookbooks {
-				if requestedSet[cookbook.Name] {
-					cookbooksToUpdate = append(cookbooksToUpdate, cookbook)
-				}
-			}
-
-			// Check if all requested cookbooks were found
-			if len(cookbooksToUpdate) != len(args) {
-				missing := []string{}
-				for _, name := range args {
-					found := false
-					for _, cookbook := range cookbooksToUpdate {
-						if cookbook.Name == name {
-							found = true
-							break
-						}
-					}
-					if !found {
-						missing = append(missing, name)
-					}
-				}
-				if len(missing) > 0 {
-					return fmt.Errorf("cookbooks not found in Berksfile: %v", missing)
-				}
+			if strings.EqualFold(format, "json") && viper.GetString("report-file") == "" {
+				return fmt.Errorf("--report with --format json requires --report-file, since both are written to stdout")
 			}
-		} else {
-			// Update all cookbooks, filtered by groups if specified
-			cookbooksToUpdate = berksfile.FilterCookbooksByGroup(bf.Cookbooks, updateOnly, updateExcept)
-		}
-
-		if len(cookbooksToUpdate) == 0 {
-			fmt.Println("No cookbooks to update.")
-			return nil
 		}
 
-		// Display what will be updated
-		log.Infof("Updating %d cookbook(s):", len(cookbooksToUpdate))
-		for _, cookbook := range cookbooksToUpdate {
-			fmt.Printf("  - %s", cookbook.Name)
-		}
-		fmt.Println("")
+		result := newResult("update", format)
+		return result.Write(os.Stdout, runUpdate(cmd, args, result))
+	},
+}
 
-		// Create source manager
-		manager, err := CreateSourceManager(bf)
-		if err != nil {
-			return err
+// runUpdate re-resolves the requested cookbooks, recording the outcome in result (which may be nil)
+func runUpdate(cmd *cobra.Command, args []string, result *Result) error {
+	log.Infoln("Updating cookbook dependencies...")
+
+	// Parse Berksfile
+	bf, err := LoadBerksfile()
+	if err != nil {
+		return err
+	}
+
+	// Get cookbooks to update
+	var cookbooksToUpdate []*berksfile.CookbookDef
+	if len(args) > 0 {
+		// Update specific cookbooks
+		requestedSet := make(map[string]bool)
+		for _, name := range args {
+			requestedSet[name] = true
 		}
 
-		// Create resolver
-		defaultResolver := resolver.NewResolver(manager.GetSources())
-
-		// Convert to berkshelf requirements (for all cookbooks, not just those being updated)
-		requirements := make([]*resolver.Requirement, 0, len(bf.Cookbooks))
 		for _, cookbook := range bf.Cookbooks {
-			// For cookbooks being updated, remove version constraints to get latest
-			constraint := cookbook.Constraint
-			isBeingUpdated := false
-			for _, updateCookbook := range cookbooksToUpdate {
-				if updateCookbook.Name == cookbook.Name {
-					isBeingUpdated = true
-					break
-				}
+			if requestedSet[cookbook.Name] {
+				cookbooksToUpdate = append(cookbooksToUpdate, cookbook)
 			}
+		}
 
-			// If being updated, use unconstrained requirement to get latest
-			if isBeingUpdated {
-				constraint = nil // This will default to ">= 0.0.0" for latest
+		// Check if all requested cookbooks were found
+		if len(cookbooksToUpdate) != len(args) {
+			missing := []string{}
+			for _, name := range args {
+				found := false
+				for _, cookbook := range cookbooksToUpdate {
+					if cookbook.Name == name {
+						found = true
+						break
+					}
+				}
+				if !found {
+					missing = append(missing, name)
+				}
 			}
-
-			// Convert source
-			var sourceLocation *berkshelf.SourceLocation
-			if cookbook.Source != nil && cookbook.Source.Type != "" {
-				sourceLocation = cookbook.Source
+			if len(missing) > 0 {
+				return fmt.Errorf("cookbooks not found in Berksfile: %v", missing)
 			}
+		}
+	} else {
+		// Update all cookbooks, filtered by groups if specified
+		cookbooksToUpdate = berksfile.FilterCookbooksByGroup(bf.Cookbooks, updateOnly, updateExcept)
+	}
 
-			req := &resolver.Requirement{
-				Name:       cookbook.Name,
-				Constraint: constraint,
-				Source:     sourceLocation,
+	if len(cookbooksToUpdate) == 0 {
+		log.Info("No cookbooks to update.")
+		return nil
+	}
+
+	// Display what will be updated
+	log.Infof("Updating %d cookbook(s):", len(cookbooksToUpdate))
+	for _, cookbook := range cookbooksToUpdate {
+		log.Infof("  - %s", cookbook.Name)
+	}
+
+	// Create source manager
+	manager, err := CreateSourceManager(bf)
+	if err != nil {
+		return err
+	}
+
+	chefVersion, err := detectChefVersion(cmd.Context())
+	if err != nil {
+		return err
+	}
+
+	// Update lock files
+	if err := checkLockFiles(projectDir()); err != nil {
+		return err
+	}
+	lockManager := newLockManager(projectDir())
+	before, err := lockManager.Load()
+	if err != nil {
+		return err
+	}
+	overrides, err := resolutionOverrides(bf, lockManager)
+	if err != nil {
+		return err
+	}
+
+	// Create resolver
+	defaultResolver := resolver.NewResolver(manager.GetSources())
+	defaultResolver.SetChefVersion(chefVersion)
+	defaultResolver.Override(overrides...)
+	defaultResolver.Ignore(bf.Ignored...)
+	defaultResolver.SetKeepGoing(viper.GetBool("keep-going"))
+	routes, err := loadSourceRoutes()
+	if err != nil {
+		return err
+	}
+	defaultResolver.Route(routes...)
+	if err := injectEnvironment(defaultResolver); err != nil {
+		return err
+	}
+
+	// Convert to berkshelf requirements (for all cookbooks, not just those being updated)
+	requirements := make([]*resolver.Requirement, 0, len(bf.Cookbooks))
+	for _, cookbook := range berksfile.ApplyGroupSources(bf.Cookbooks, loadGroupSources()) {
+		// For cookbooks being updated, remove version constraints to get latest
+		constraint := cookbook.Constraint
+		isBeingUpdated := false
+		for _, updateCookbook := range cookbooksToUpdate {
+			if updateCookbook.Name == cookbook.Name {
+				isBeingUpdated = true
+				break
 			}
-			requirements = append(requirements, req)
 		}
 
-		// Resolve dependencies
-		log.Info("Resolving dependencies...")
-
-		resolution, err := defaultResolver.Resolve(cmd.Context(), requirements)
-		if err != nil {
-			return fmt.Errorf("dependency resolution failed: %w", err)
+		// If being updated, use unconstrained requirement to get latest
+		if isBeingUpdated {
+			constraint = nil // This will default to ">= 0.0.0" for latest
 		}
 
-		if len(resolution.Errors) > 0 {
-			log.Info("Resolution errors:")
-			for _, resolverErr := range resolution.Errors {
-				log.Infof("  - %v", resolverErr)
-			}
-			return fmt.Errorf("dependency resolution completed with errors")
+		// Convert source
+		var sourceLocation *berkshelf.SourceLocation
+		if cookbook.Source != nil && cookbook.Source.Type != "" {
+			sourceLocation = cookbook.Source
 		}
 
-		log.Infof("Resolved %d cookbook(s)", len(resolution.Cookbooks))
-
-		// Update lock files
-		lockManager := lockfile.NewManager(".")
-
-		// Extract direct dependencies from Berksfile for DEPENDENCIES section
-		berksfilePath := "Berksfile"
-		var groups []string
-		if len(updateOnly) > 0 {
-			groups = updateOnly
+		req := &resolver.Requirement{
+			Name:         cookbook.Name,
+			Constraint:   constraint,
+			Source:       sourceLocation,
+			MetadataName: cookbook.MetadataName,
 		}
-
-		dependencies, err := lockfile.ExtractDirectDependencies(berksfilePath, groups)
-		if err != nil {
-			log.Warnf("Failed to extract direct dependencies for Ruby lock file: %v", err)
-			// Continue with empty dependencies list
-			dependencies = []string{}
+		requirements = append(requirements, req)
+	}
+
+	// Resolve dependencies
+	log.Info("Resolving dependencies...")
+
+	if err := runHooks(cmd.Context(), hooks.Payload{Event: hooks.PreResolve, Cookbooks: hooks.Requirements(requirements)}); err != nil {
+		return err
+	}
+
+	resolveStart := time.Now()
+	ctx, cancel := phaseContext(cmd.Context(), berrors.PhaseResolve)
+	defer cancel()
+	var resolution *resolver.Resolution
+	err = profileResolution(func() (err error) {
+		resolution, err = defaultResolver.Resolve(ctx, requirements)
+		return err
+	})
+	result.Phase("resolve", resolveStart)
+	if err = berrors.PhaseError(ctx, err); err != nil {
+		return berrors.WithType(fmt.Errorf("dependency resolution failed: %w", err), berrors.ErrorTypeResolution)
+	}
+
+	if len(resolution.Errors) > 0 {
+		log.Info("Resolution errors:")
+		for _, resolverErr := range resolution.Errors {
+			log.Infof("  - %v", resolverErr)
+			result.Warn("%v", resolverErr)
 		}
-
-		// Generate and save both formats
-		if err := lockManager.GenerateBoth(resolution, dependencies); err != nil {
-			return fmt.Errorf("failed to generate lock files: %w", err)
+		return berrors.PhaseError(ctx, resolution.Failure())
+	}
+
+	for _, warning := range resolution.Warnings {
+		result.Warn("%s", warning)
+	}
+
+	if err := runHooks(cmd.Context(), hooks.Payload{Event: hooks.PostResolve, Cookbooks: hooks.Resolved(resolution)}); err != nil {
+		return err
+	}
+
+	log.Infof("Resolved %d cookbook(s)", len(resolution.Cookbooks))
+
+	// Extract direct dependencies from Berksfile for DEPENDENCIES section
+	var groups []string
+	if len(updateOnly) > 0 {
+		groups = updateOnly
+	}
+
+	dependencies, err := lockfile.ExtractDirectDependencies(berksfilePath, groups)
+	if err != nil {
+		log.Warnf("Failed to extract direct dependencies for Ruby lock file: %v", err)
+		result.Warn("failed to extract direct dependencies for Ruby lock file: %v", err)
+		// Continue with empty dependencies list
+		dependencies = []string{}
+	}
+
+	// Generate and save both formats
+	lockFile, err := lockManager.Generate(resolution)
+	if err != nil {
+		return fmt.Errorf("failed to generate lock files: %w", err)
+	}
+	if err := lockManager.SaveBoth(lockFile, dependencies); err != nil {
+		return fmt.Errorf("failed to generate lock files: %w", err)
+	}
+
+	warnOverrides(lockFile, lockManager.GetPath(), result)
+	result.Act("wrote_lockfile", "", lockManager.GetPath())
+	if lockManager.WritesRuby() {
+		log.Infof("Lock files updated: %s and %s", lockManager.GetPath(), lockManager.GetRubyPath())
+		result.Act("wrote_lockfile", "", lockManager.GetRubyPath())
+	} else {
+		log.Infof("Lock file updated: %s", lockManager.GetPath())
+	}
+
+	// Show what was updated
+	log.Info("\nUpdated cookbooks:")
+	for _, cookbook := range cookbooksToUpdate {
+		if resolvedCookbook, exists := resolution.Cookbooks[cookbook.Name]; exists {
+			log.Infof("  - %s (%s)", cookbook.Name, resolvedCookbook.Cookbook.Version)
+			result.AddCookbook(ResultCookbook{
+				Name:    cookbook.Name,
+				Version: resolvedCookbook.Cookbook.Version.String(),
+				Source:  resolvedCookbook.Source.String(),
+			})
+			result.Act("updated", cookbook.Name, resolvedCookbook.Cookbook.Version.String())
 		}
+	}
 
-		log.Infof("Lock files updated: %s and %s", lockManager.GetPath(), lockManager.GetRubyPath())
+	if viper.GetString("report") != "" {
+		return writeUpdateReport(cmd.Context(), bf, manager, before, lockFile, result)
+	}
+	return nil
+}
 
-		// Show what was updated
-		log.Info("\nUpdated cookbooks:")
-		for _, cookbook := range cookbooksToUpdate {
-			if resolvedCookbook, exists := resolution.Cookbooks[cookbook.Name]; exists {
-				fmt.Printf("  - %s (%s)", cookbook.Name, resolvedCookbook.Cookbook.Version)
-			}
+// writeUpdateReport writes the markdown report of the lock file changes to
+// --report-file, or stdout
+func writeUpdateReport(ctx context.Context, bf *berksfile.Berksfile, manager *source.Manager, before, after *lockfile.LockFile, result *Result) error {
+	declared := make(map[string]string)
+	for _, cookbook := range bf.Cookbooks {
+		// Unconstrained cookbooks default to >= 0.0.0, which says nothing
+		if cookbook.Constraint != nil && cookbook.Constraint.String() != ">= 0.0.0" {
+			declared[cookbook.Name] = cookbook.Constraint.String()
 		}
+	}
+	update := report.NewUpdate(before, after, declared)
 
-		return nil
-	},
+	checker := outdated.New(after, manager)
+	for i, entry := range update.Entries {
+		if entry.Type == lockfile.ChangeRemoved {
+			continue
+		}
+		repository, err := checker.Repository(ctx, entry.Cookbook)
+		if err != nil {
+			log.Debugf("No changelog link for %s: %v", entry.Cookbook, err)
+			continue
+		}
+		update.Entries[i].Changelog = changelog.WebURL(repository)
+	}
+
+	path := viper.GetString("report-file")
+	if path == "" {
+		return update.WriteMarkdown(os.Stdout)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	if err := update.WriteMarkdown(f); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	log.Infof("Report written to %s", path)
+	result.Act("wrote_report", "", path)
+	return nil
 }
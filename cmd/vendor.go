@@ -2,10 +2,17 @@ package cmd
 
 import (
 	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
 	"github.com/bdwyertech/go-berkshelf/pkg/berksfile"
+	berrors "github.com/bdwyertech/go-berkshelf/pkg/errors"
+	"github.com/bdwyertech/go-berkshelf/pkg/lockfile"
 	"github.com/bdwyertech/go-berkshelf/pkg/vendor"
 
 	"github.com/spf13/cobra"
@@ -14,6 +21,7 @@ import (
 
 func init() {
 	rootCmd.AddCommand(vendorCmd)
+	vendorCmd.AddCommand(vendorPathCmd)
 
 	// Add flags
 	vendorCmd.Flags().Bool("delete", false, "Delete the target directory before vendoring")
@@ -22,8 +30,19 @@ func init() {
 	vendorCmd.Flags().Bool("force", false, "Force installation even if Berksfile.lock is up to date")
 	vendorCmd.Flags().StringSliceP("only", "o", nil, "Only vendor cookbooks in specified groups")
 	vendorCmd.Flags().StringSliceP("except", "e", nil, "Vendor all cookbooks except those in specified groups")
+	vendorCmd.Flags().StringSlice("suite", nil, "Only vendor the cookbooks the Test Kitchen suite(s) converge")
+	vendorCmd.Flags().String("format", "text", "Output format (text, json)")
+
+	registerGroupCompletion(vendorCmd)
+	cobra.CheckErr(vendorCmd.RegisterFlagCompletionFunc("suite", completeSuiteNames))
+	vendorCmd.MarkFlagsMutuallyExclusive("suite", "only")
+	vendorCmd.MarkFlagsMutuallyExclusive("suite", "except")
+	registerFormatCompletion(vendorCmd, "text", "json")
 }
 
+// defaultVendorPath is where cookbooks are vendored without a PATH
+const defaultVendorPath = "berks-cookbooks"
+
 var vendorCmd = &cobra.Command{
 	Use:   "vendor [PATH]",
 	Short: "Download cookbooks to a directory",
@@ -34,111 +53,222 @@ maintaining the proper directory structure for Chef. This is useful for
 packaging cookbooks for deployment or creating a self-contained cookbook bundle.
 
 If no PATH is provided, cookbooks will be vendored to ./berks-cookbooks.
+'berks vendor path' prints that location; to vendor into a directory named
+path, pass ./path.
+
+Vendoring stops at the first cookbook that fails to download. With
+--keep-going every other cookbook is vendored first; either way the command
+fails listing the status of each cookbook.
 
 Examples:
      berks vendor
      berks vendor ./vendor
  	 berks vendor --delete                    # Delete target directory first
  	 berks vendor ./vendor --only production  # Vendor only production group cookbooks
- 	 berks vendor ./vendor --except test      # Vendor all except test group cookbooks`,
+ 	 berks vendor ./vendor --except test      # Vendor all except test group cookbooks
+ 	 berks vendor --suite default             # Vendor what the default kitchen suite converges
+ 	 berks vendor --format json               # Print a JSON result when done`,
 	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		targetPath := "berks-cookbooks"
+		format := viper.GetString("format")
+		if err := checkFormat(format, "text", "json"); err != nil {
+			return err
+		}
+
+		result := newResult("vendor", format)
+		return result.Write(os.Stdout, runVendor(cmd, args, result))
+	},
+}
+
+var vendorPathCmd = &cobra.Command{
+	Use:   "path [PATH]",
+	Short: "Print the directory cookbooks are vendored to",
+	Long: `Print the absolute path 'berks vendor [PATH]' vendors cookbooks to.
+
+Examples:
+  berks vendor path            # The default, ./berks-cookbooks
+  berks vendor path ./vendor   # PATH made absolute`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		targetPath := defaultVendorPath
 		if len(args) == 1 {
 			targetPath = args[0]
 		}
+		return printPath(targetPath)
+	},
+}
 
-		if viper.GetBool("install") {
-			if err := installCmd.RunE(cmd, args); err != nil {
-				return fmt.Errorf("failed to run install command: %w", err)
-			}
+// runVendor vendors the locked cookbooks, recording the outcome in result (which may be nil)
+func runVendor(cmd *cobra.Command, args []string, result *Result) error {
+	targetPath := defaultVendorPath
+	if len(args) == 1 {
+		targetPath = args[0]
+	}
+
+	if viper.GetBool("install") {
+		// Cookbooks are reported from the vendored set below, not the install
+		var installResult *Result
+		if result != nil {
+			installResult = newResult("install", "json")
+		}
+		if err := runInstall(cmd, nil, installResult); err != nil {
+			return fmt.Errorf("failed to run install command: %w", err)
+		}
+		if installResult != nil {
+			result.Actions = append(result.Actions, installResult.Actions...)
+			result.Warnings = append(result.Warnings, installResult.Warnings...)
+			maps.Copy(result.DurationsMS, installResult.DurationsMS)
 		}
+	}
+
+	// Parse Berksfile
+	bf, err := LoadBerksfile()
+	if err != nil {
+		return err
+	}
+
+	// Load lock file
+	lockFile, _, err := LoadLockFile()
+	if err != nil {
+		return fmt.Errorf("no lock file found. Run 'berks install' first: %w", err)
+	}
 
-		// Parse Berksfile
-		bf, err := LoadBerksfile()
+	// Create source manager
+	sourceManager, err := CreateSourceManager(bf)
+	if err != nil {
+		return err
+	}
+
+	// Filter cookbooks by groups if needed
+	var allowedCookbooks []string
+	only, except := viper.GetStringSlice("only"), viper.GetStringSlice("except")
+	if suites := viper.GetStringSlice("suite"); len(suites) > 0 {
+		cookbooks, err := suiteCookbooks(bf, suites, result)
 		if err != nil {
 			return err
 		}
+		allowedCookbooks = vendor.FindTransitiveDependencies(lockFile, cookbooks)
+		if len(allowedCookbooks) == 0 {
+			return fmt.Errorf("no locked cookbooks are converged by suite(s) %s", strings.Join(suites, ", "))
+		}
+		log.Infof("Including %d cookbook(s) for suite(s) %s", len(allowedCookbooks), strings.Join(suites, ", "))
+	} else if len(only) > 0 || len(except) > 0 {
+		// Filter cookbooks from Berksfile
+		filtered := berksfile.FilterCookbooksByGroup(bf.Cookbooks, only, except)
 
-		// Load lock file
-		lockFile, _, err := LoadLockFile()
-		if err != nil {
-			return fmt.Errorf("no lock file found. Run 'berks install' first: %w", err)
+		// Extract cookbook names
+		filteredNames := make([]string, 0, len(filtered))
+		for _, cb := range filtered {
+			filteredNames = append(filteredNames, cb.Name)
 		}
 
-		// Create source manager
-		sourceManager, err := CreateSourceManager(bf)
-		if err != nil {
-			return err
+		// If using --only, include transitive dependencies
+		if len(only) > 0 {
+			allowedCookbooks = vendor.FindTransitiveDependencies(lockFile, filteredNames)
+			log.Infof("Including %d cookbook(s) with dependencies", len(allowedCookbooks))
+		} else {
+			// For --except, don't include dependencies of excluded cookbooks
+			allowedCookbooks = filteredNames
 		}
 
-		// Filter cookbooks by groups if needed
-		var allowedCookbooks []string
-		only, except := viper.GetStringSlice("only"), viper.GetStringSlice("except")
-		if len(only) > 0 || len(except) > 0 {
-			// Filter cookbooks from Berksfile
-			filtered := berksfile.FilterCookbooksByGroup(bf.Cookbooks, only, except)
+		if len(allowedCookbooks) == 0 {
+			return fmt.Errorf("no cookbooks match the specified group filters")
+		}
+	}
 
-			// Extract cookbook names
-			filteredNames := make([]string, 0, len(filtered))
-			for _, cb := range filtered {
-				filteredNames = append(filteredNames, cb.Name)
-			}
+	// Create vendor options
+	options := vendor.Options{
+		TargetPath:    targetPath,
+		Delete:        viper.GetBool("delete"),
+		DryRun:        viper.GetBool("dry-run"),
+		OnlyCookbooks: allowedCookbooks,
+		KeepGoing:     viper.GetBool("keep-going"),
+		Factory:       newSourceFactory(),
+	}
 
-			// If using --only, include transitive dependencies
-			if len(only) > 0 {
-				allowedCookbooks = vendor.FindTransitiveDependencies(lockFile, filteredNames)
-				log.Infof("Including %d cookbook(s) with dependencies", len(allowedCookbooks))
-			} else {
-				// For --except, don't include dependencies of excluded cookbooks
-				allowedCookbooks = filteredNames
-			}
+	// Create vendorer
+	vendorer := vendor.New(lockFile, sourceManager, options)
 
-			if len(allowedCookbooks) == 0 {
-				return fmt.Errorf("no cookbooks match the specified group filters")
-			}
+	if options.DryRun {
+		log.Infof("Dry run: Would vendor cookbooks to: %s\n", targetPath)
+		if options.Delete {
+			log.Infof("Would delete existing directory first\n")
 		}
+	} else {
+		log.Infof("Vendoring cookbooks to: %s\n", targetPath)
+	}
 
-		// Create vendor options
-		options := vendor.Options{
-			TargetPath:    targetPath,
-			Delete:        viper.GetBool("delete"),
-			DryRun:        viper.GetBool("dry-run"),
-			OnlyCookbooks: allowedCookbooks,
-		}
+	vendorStart := time.Now()
+	ctx, cancel := phaseContext(cmd.Context(), berrors.PhaseDownload)
+	defer cancel()
+	vendorResult, err := vendorWithHooks(ctx, vendorer, lockFile, options)
+	result.Phase("vendor", vendorStart)
+	if err = berrors.PhaseError(ctx, err); err != nil {
+		return fmt.Errorf("vendor failed: %w", err)
+	}
 
-		// Create vendorer
-		vendorer := vendor.New(lockFile, sourceManager, options)
+	// Report results
+	if options.DryRun {
+		log.Infof("\nDry run completed. %d cookbook(s) would be downloaded.\n", vendorResult.TotalCookbooks)
+	} else {
+		log.Infof("\nVendoring completed. %d cookbook(s) successfully downloaded to %s\n",
+			vendorResult.SuccessfulDownloads, vendorResult.TargetPath)
 
-		if options.DryRun {
-			log.Infof("Dry run: Would vendor cookbooks to: %s\n", targetPath)
-			if options.Delete {
-				log.Infof("Would delete existing directory first\n")
+		if len(vendorResult.FailedDownloads) > 0 {
+			log.Warnf("\nWarning: Failed to download %d cookbook(s):\n", len(vendorResult.FailedDownloads))
+			for name, errMsg := range vendorResult.FailedDownloads {
+				log.Warnf("  - %s: %s\n", name, errMsg)
+				result.Warn("failed to download %s: %s", name, errMsg)
 			}
-		} else {
-			log.Infof("Vendoring cookbooks to: %s\n", targetPath)
 		}
+		if len(vendorResult.Skipped) > 0 {
+			log.Warnf("Skipped %d cookbook(s) after the first failure, pass --keep-going to vendor them: %s",
+				len(vendorResult.Skipped), strings.Join(vendorResult.Skipped, ", "))
+		}
+	}
 
-		result, err := vendorer.Vendor(cmd.Context())
-		if err != nil {
-			return fmt.Errorf("vendor failed: %w", err)
+	if len(vendorResult.Collisions) > 0 {
+		log.Warnf("\nWarning: %d vendor path collision(s), the colliding cookbooks were not vendored:\n", len(vendorResult.Collisions))
+		for _, collision := range vendorResult.Collisions {
+			log.Warnf("  - %s\n", collision)
+			result.Warn("vendor collision: %s", collision)
 		}
+	}
 
-		// Report results
-		if options.DryRun {
-			log.Infof("\nDry run completed. %d cookbook(s) would be downloaded.\n", result.TotalCookbooks)
-		} else {
-			log.Infof("\nVendoring completed. %d cookbook(s) successfully downloaded to %s\n",
-				result.SuccessfulDownloads, result.TargetPath)
-
-			if len(result.FailedDownloads) > 0 {
-				log.Warnf("\nWarning: Failed to download %d cookbook(s):\n", len(result.FailedDownloads))
-				for name, errMsg := range result.FailedDownloads {
-					log.Warnf("  - %s: %s\n", name, errMsg)
-				}
+	if result != nil {
+		recordVendored(result, lockFile, allowedCookbooks, vendorResult, options.DryRun)
+	}
+
+	if len(vendorResult.Collisions) > 0 {
+		return fmt.Errorf("%d vendor path collision(s) in %s", len(vendorResult.Collisions), vendorResult.TargetPath)
+	}
+	return vendorResult.Failure()
+}
+
+// recordVendored adds the vendored cookbooks and their actions to result
+func recordVendored(result *Result, lockFile *lockfile.LockFile, allowed []string, vendorResult *vendor.Result, dryRun bool) {
+	result.AddLockFile(lockFile)
+	if len(allowed) > 0 {
+		filtered := result.Cookbooks[:0]
+		for _, cookbook := range result.Cookbooks {
+			if slices.Contains(allowed, cookbook.Name) {
+				filtered = append(filtered, cookbook)
 			}
 		}
+		result.Cookbooks = filtered
+	}
 
-		return nil
-	},
+	action := "vendored"
+	if dryRun {
+		action = "would_vendor"
+	}
+	for _, cookbook := range result.Cookbooks {
+		if _, failed := vendorResult.FailedDownloads[cookbook.Name]; failed {
+			continue
+		}
+		if vendorResult.Collided(cookbook.Name) || slices.Contains(vendorResult.Skipped, cookbook.Name) {
+			continue
+		}
+		result.Act(action, cookbook.Name, vendorResult.TargetPath)
+	}
 }
@@ -0,0 +1,351 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/sourcegraph/conc/pool"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berksfile"
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	"github.com/bdwyertech/go-berkshelf/pkg/lockfile"
+	"github.com/bdwyertech/go-berkshelf/pkg/ui"
+	"github.com/bdwyertech/go-berkshelf/pkg/workspace"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	rootCmd.AddCommand(wsCmd)
+	wsCmd.AddCommand(wsInstallCmd)
+
+	wsInstallCmd.Flags().IntP("concurrency", "j", runtime.NumCPU(), "Number of projects to resolve at once")
+	wsInstallCmd.Flags().StringSliceP("only", "o", nil, "Only install cookbooks in specified groups")
+	wsInstallCmd.Flags().StringSliceP("except", "e", nil, "Install all cookbooks except those in specified groups")
+	wsInstallCmd.Flags().BoolP("force", "f", false, "Resolve projects even if their Berksfile.lock is up to date")
+	wsInstallCmd.Flags().Bool("no-cache", false, "Query sources instead of using versions checked within min_check_interval")
+	wsInstallCmd.Flags().StringSlice("exclude", nil, "Skip directories matching these patterns, relative to the root")
+	wsInstallCmd.Flags().Bool("strict", false, "Fail when projects lock a cookbook at different versions")
+	wsInstallCmd.Flags().Bool("detect-chef", false, "Only select cookbook versions whose chef_version supports the local chef-client/cinc-client")
+	wsInstallCmd.Flags().String("format", "text", "Output format (text, json)")
+
+	registerFormatCompletion(wsInstallCmd, "text", "json")
+}
+
+var wsCmd = &cobra.Command{
+	Use:     "ws",
+	Aliases: []string{"workspace"},
+	Short:   "Work with every Berksfile in a repository at once",
+}
+
+var wsInstallCmd = &cobra.Command{
+	Use:   "install [ROOT]",
+	Short: "Install every Berksfile under a directory",
+	Long: `Find every Berksfile under ROOT (the current directory by default) and
+install each of them, resolving several projects at once.
+
+The projects share the cookbook cache and one view of the available
+versions: each remote source is asked for a cookbook's versions and metadata
+once, however many projects depend on it. Hidden directories, vendored
+cookbooks (berks-cookbooks, vendor) and test fixtures are not searched;
+--exclude skips more.
+
+Projects whose Berksfile.lock is up to date are skipped unless --force is
+given. A project that fails to resolve does not stop the others, but makes
+the command fail.
+
+When it is done, cookbooks the projects locked at different versions are
+listed with the constraints that led there. A disagreement marked as a
+conflict cannot be settled, as no known version satisfies every project;
+the others could be aligned with 'berks update'. Pass --strict to fail on
+any disagreement.
+
+Examples:
+  berks ws install                       # Install every project under .
+  berks ws install cookbooks -j 8        # Resolve 8 projects at a time
+  berks ws install --exclude 'legacy/*'  # Skip the legacy projects
+  berks ws install --strict              # Fail if projects disagree
+  berks ws install --format json         # Print a JSON result when done`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format := viper.GetString("format")
+		if err := checkFormat(format, "text", "json"); err != nil {
+			return err
+		}
+
+		root := "."
+		if len(args) > 0 {
+			root = args[0]
+		}
+
+		result := newResult("ws install", format)
+		err := runWorkspaceInstall(cmd.Context(), root, result)
+		if err != nil {
+			cmd.SilenceUsage = true
+		}
+		return result.Write(os.Stdout, err)
+	},
+}
+
+func runWorkspaceInstall(ctx context.Context, root string, result *Result) error {
+	projects, err := workspace.Discover(root, viper.GetStringSlice("exclude"))
+	if err != nil {
+		return fmt.Errorf("failed to search %s: %w", root, err)
+	}
+	if len(projects) == 0 {
+		return fmt.Errorf("no Berksfile found under %s", root)
+	}
+	log.Infof("Found %d projects under %s", len(projects), root)
+
+	workspace.Load(projects)
+
+	// Lock file cleanup may prompt, so it runs before the projects resolve
+	for _, project := range projects {
+		if project.Err == nil {
+			project.Err = checkLockFiles(project.Dir)
+		}
+	}
+
+	chefVersion, err := detectChefVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Resolve the projects concurrently against one universe
+	start := time.Now()
+	universe := workspace.NewUniverse()
+	p := pool.New().WithMaxGoroutines(max(viper.GetInt("concurrency"), 1))
+	for _, project := range projects {
+		if project.Err != nil {
+			continue
+		}
+		p.Go(func() {
+			project.Err = installProject(ctx, project, universe, chefVersion)
+		})
+	}
+	p.Wait()
+	result.Phase("resolve", start)
+
+	failed := 0
+	for _, project := range projects {
+		lockPath := newLockManager(project.Dir).GetPath()
+		switch {
+		case project.Err != nil:
+			failed++
+			log.Errorf("%s: %v", project.Name, project.Err)
+			result.ActFor(project.Name, "failed", "", project.Err.Error())
+		case project.Skipped:
+			result.ActFor(project.Name, "skipped", "", "lock file is up to date")
+		default:
+			result.ActFor(project.Name, "wrote_lockfile", "", lockPath)
+		}
+		if project.LockFile != nil {
+			warnOverrides(project.LockFile, lockPath, result)
+		}
+	}
+
+	disagreements := workspace.Disagreements(projects, universe)
+	for _, disagreement := range disagreements {
+		result.AddDisagreement(disagreement)
+	}
+
+	if result == nil {
+		if err := outputWorkspaceTables(projects, disagreements); err != nil {
+			return err
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d projects failed to install", failed, len(projects))
+	}
+	if viper.GetBool("strict") && len(disagreements) > 0 {
+		return fmt.Errorf("projects disagree on %d cookbook(s)", len(disagreements))
+	}
+	return nil
+}
+
+// installProject resolves and locks one project of a workspace. It works from
+// the project directory without changing the working directory, which the
+// other projects share.
+func installProject(ctx context.Context, project *workspace.Project, universe *workspace.Universe, chefVersion *berkshelf.Version) error {
+	lockManager := newLockManager(project.Dir)
+	if !viper.GetBool("force") && lockManager.Exists() {
+		if outdated, err := lockManager.IsOutdated(); err == nil && !outdated {
+			lockFile, err := lockManager.Load()
+			if err != nil {
+				return err
+			}
+			project.LockFile, project.Skipped = lockFile, true
+			return nil
+		}
+	}
+
+	bf := project.Berksfile
+	only, except := viper.GetStringSlice("only"), viper.GetStringSlice("except")
+	cookbooks := berksfile.FilterCookbooksByGroup(bf.Cookbooks, only, except)
+	cookbooks = berksfile.ApplyGroupSources(cookbooks, loadGroupSources())
+
+	metadataCookbooks, err := bf.MetadataCookbooks(project.Dir)
+	if err != nil {
+		return err
+	}
+	requirements := CreateRequirementsFromCookbooks(slices.Concat(cookbooks, metadataCookbooks))
+	for _, req := range requirements {
+		req.Source = absPathSource(req.Source, project.Dir)
+	}
+
+	overrides, err := resolutionOverrides(bf, lockManager)
+	if err != nil {
+		return err
+	}
+	for _, req := range overrides {
+		req.Source = absPathSource(req.Source, project.Dir)
+	}
+
+	sourceManager, err := SetupSourcesFromBerksfile(bf)
+	if err != nil {
+		return err
+	}
+	sources := sourceManager.GetSources()
+	if !viper.GetBool("no-cache") {
+		sources = withVersionCache(sources)
+	}
+
+	resolution, err := ResolveDependencies(ctx, requirements, overrides, bf.Ignored, universe.WrapAll(sources), nil, chefVersion, nil)
+	if err != nil {
+		return err
+	}
+
+	lockFile, err := lockManager.Generate(resolution)
+	if err != nil {
+		return fmt.Errorf("failed to generate lock file: %w", err)
+	}
+	relPathSources(lockFile, project.Dir)
+
+	var groups []string
+	if len(only) > 0 {
+		groups = only
+	}
+	dependencies, err := bf.ExtractDirectDependencies(groups)
+	if err != nil {
+		log.Warnf("%s: failed to extract direct dependencies for Ruby lock file: %v", project.Name, err)
+		dependencies = []string{}
+	}
+	if err := lockManager.SaveBoth(lockFile, dependencies); err != nil {
+		return fmt.Errorf("failed to update lock files: %w", err)
+	}
+
+	log.Infof("%s: resolved %d cookbooks", project.Name, resolution.CookbookCount())
+	project.LockFile = lockFile
+	return nil
+}
+
+// absPathSource returns a copy of a path source with its path made relative
+// to dir absolute; other sources are returned unchanged
+func absPathSource(loc *berkshelf.SourceLocation, dir string) *berkshelf.SourceLocation {
+	if loc == nil || loc.Type != "path" || filepath.IsAbs(loc.Path) {
+		return loc
+	}
+	abs := *loc
+	abs.Path = filepath.Join(dir, loc.Path)
+	return &abs
+}
+
+// relPathSources makes the path sources of a lock file relative to dir again,
+// so it matches a lock file written by 'berks install' in that directory
+func relPathSources(lockFile *lockfile.LockFile, dir string) {
+	for _, cookbook := range lockFile.ListCookbooks() {
+		if cookbook.Source == nil || cookbook.Source.Type != "path" {
+			continue
+		}
+		if rel, err := filepath.Rel(dir, cookbook.Source.Path); err == nil {
+			cookbook.Source.Path = rel
+		}
+	}
+}
+
+// outputWorkspaceTables prints the outcome of each project, then the
+// cookbooks the projects disagree on
+func outputWorkspaceTables(projects []*workspace.Project, disagreements []workspace.Disagreement) error {
+	table := newTable("PROJECT", "STATUS", "COOKBOOKS")
+	for _, project := range projects {
+		status, count := "installed", "-"
+		switch {
+		case project.Err != nil:
+			status = "failed"
+		case project.Skipped:
+			status = "up to date"
+		}
+		if project.LockFile != nil {
+			count = fmt.Sprint(len(project.LockFile.ListCookbooks()))
+		}
+		table.Append(project.Name, status, count)
+	}
+	if err := table.Render(os.Stdout); err != nil {
+		return err
+	}
+
+	fmt.Println()
+	if len(disagreements) == 0 {
+		fmt.Println("All projects agree on their cookbook versions.")
+		return nil
+	}
+
+	// Keep each cookbook's versions together
+	opts := tableOptions()
+	opts.Unsorted = true
+	table = ui.NewTable(opts, "COOKBOOK", "VERSION", "PROJECTS", "CONFLICT")
+	for _, disagreement := range disagreements {
+		versions := make([]string, 0, len(disagreement.Locked))
+		for version := range disagreement.Locked {
+			versions = append(versions, version)
+		}
+		sort.Slice(versions, func(i, j int) bool {
+			vi, errI := berkshelf.NewVersion(versions[i])
+			vj, errJ := berkshelf.NewVersion(versions[j])
+			if errI != nil || errJ != nil {
+				return versions[i] < versions[j]
+			}
+			return vi.LessThan(vj)
+		})
+
+		conflict := "no"
+		if disagreement.Conflict {
+			conflict = "yes"
+		}
+		for i, version := range versions {
+			name := disagreement.Cookbook
+			if i > 0 {
+				name, conflict = "", ""
+			}
+			table.Append(name, version, strings.Join(disagreement.Locked[version], ", "), conflict)
+		}
+	}
+	if err := table.Render(os.Stdout); err != nil {
+		return err
+	}
+
+	for _, disagreement := range disagreements {
+		if !disagreement.Conflict {
+			continue
+		}
+		fmt.Printf("\n%s cannot be aligned:\n", disagreement.Cookbook)
+		for _, req := range disagreement.Requirements {
+			via := "Berksfile"
+			if req.From != "" {
+				via = req.From
+			}
+			fmt.Printf("  %s requires %s (via %s)\n", req.Project, req.Constraint, via)
+		}
+	}
+	return nil
+}
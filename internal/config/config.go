@@ -3,29 +3,141 @@ package config
 import (
 	"encoding/json"
 	"fmt"
+	"maps"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 
 	"dario.cat/mergo"
 
+	"github.com/bdwyertech/go-berkshelf/pkg/digest"
+	berrors "github.com/bdwyertech/go-berkshelf/pkg/errors"
+	"github.com/bdwyertech/go-berkshelf/pkg/hooks"
+	"github.com/bdwyertech/go-berkshelf/pkg/logging"
 	"github.com/bdwyertech/go-berkshelf/pkg/source"
 )
 
+var log = logging.For("config")
+
 // Config represents berkshelf configuration with pointer fields for optional values
 // and envconfig tags for automatic environment variable loading
 type Config struct {
-	CachePath      *string     `json:"cache_path,omitempty" env:"BERKSHELF_CACHE_PATH"`
-	DefaultSources []string    `json:"default_sources,omitempty" env:"BERKSHELF_DEFAULT_SOURCES" env-separator:","`
-	SSLVerify      *bool       `json:"ssl_verify,omitempty" env:"BERKSHELF_SSL_VERIFY"`
-	Proxy          *string     `json:"proxy,omitempty" env:"BERKSHELF_PROXY"`
-	NoProxy        []string    `json:"no_proxy,omitempty" env:"BERKSHELF_NO_PROXY" env-separator:","`
-	ChefConfig     *ChefConfig `json:"chef,omitempty"`
-	APITimeout     *int        `json:"api_timeout,omitempty" env:"BERKSHELF_API_TIMEOUT"`
-	RetryCount     *int        `json:"retry_count,omitempty" env:"BERKSHELF_RETRY_COUNT"`
-	RetryDelay     *int        `json:"retry_delay,omitempty" env:"BERKSHELF_RETRY_DELAY"`
-	Concurrency    *int        `json:"concurrency,omitempty" env:"BERKSHELF_CONCURRENCY"`
+	CachePath      *string           `json:"cache_path,omitempty" env:"BERKSHELF_CACHE_PATH"`
+	DefaultSources []string          `json:"default_sources,omitempty" env:"BERKSHELF_DEFAULT_SOURCES" env-separator:","`
+	SSLVerify      *bool             `json:"ssl_verify,omitempty" env:"BERKSHELF_SSL_VERIFY"`
+	Proxy          *string           `json:"proxy,omitempty" env:"BERKSHELF_PROXY"`
+	NoProxy        []string          `json:"no_proxy,omitempty" env:"BERKSHELF_NO_PROXY" env-separator:","`
+	GroupSources   map[string]string `json:"group_sources,omitempty"`
+	// SourceRoutes send the cookbooks whose name matches a pattern to a
+	// source instead of the default sources, evaluated in order
+	SourceRoutes []SourceRoute `json:"source_routes,omitempty"`
+	ChefConfig   *ChefConfig   `json:"chef,omitempty"`
+	APITimeout   *int          `json:"api_timeout,omitempty" env:"BERKSHELF_API_TIMEOUT"`
+	RetryCount   *int          `json:"retry_count,omitempty" env:"BERKSHELF_RETRY_COUNT"`
+	RetryDelay   *int          `json:"retry_delay,omitempty" env:"BERKSHELF_RETRY_DELAY"`
+	Concurrency  *int          `json:"concurrency,omitempty" env:"BERKSHELF_CONCURRENCY"`
+	// MinCheckInterval is the minimum number of seconds between remote version checks per cookbook
+	MinCheckInterval *int `json:"min_check_interval,omitempty" env:"BERKSHELF_MIN_CHECK_INTERVAL"`
+	// RequestsPerSecond limits the requests sent to each Supermarket host,
+	// allowing Burst at once; 0 is no limit
+	RequestsPerSecond *float64 `json:"requests_per_second,omitempty" env:"BERKSHELF_REQUESTS_PER_SECOND"`
+	Burst             *int     `json:"burst,omitempty" env:"BERKSHELF_BURST"`
+	// ResolveTimeout, DownloadTimeout and UploadTimeout limit the seconds
+	// spent resolving, downloading and uploading cookbooks; 0 is no limit
+	ResolveTimeout  *int `json:"resolve_timeout,omitempty" env:"BERKSHELF_RESOLVE_TIMEOUT"`
+	DownloadTimeout *int `json:"download_timeout,omitempty" env:"BERKSHELF_DOWNLOAD_TIMEOUT"`
+	UploadTimeout   *int `json:"upload_timeout,omitempty" env:"BERKSHELF_UPLOAD_TIMEOUT"`
+	// LockfileName is the name of the lock file written next to the Berksfile
+	LockfileName *string `json:"lockfile_name,omitempty" env:"BERKSHELF_LOCKFILE_NAME"`
+	// RubyLockfile writes a Ruby Berkshelf compatible Berksfile.lock alongside it
+	RubyLockfile *bool `json:"ruby_lockfile,omitempty" env:"BERKSHELF_RUBY_LOCKFILE"`
+	// ChecksumAlgorithm computes cache checksums and package digests
+	ChecksumAlgorithm *string `json:"checksum_algorithm,omitempty" env:"BERKSHELF_CHECKSUM_ALGORITHM"`
+	// FIPS allows only FIPS 140-3 approved checksum algorithms, as Go's FIPS
+	// mode (GODEBUG=fips140=on) does
+	FIPS *bool `json:"fips,omitempty" env:"BERKSHELF_FIPS"`
+	// EvalEnv evaluates ENV checks in Berksfile conditionals; when off they
+	// are skipped like other dynamic code
+	EvalEnv *bool `json:"eval_env,omitempty" env:"BERKSHELF_EVAL_ENV"`
+	// APIKeys maps source URLs to API keys, usually env: or keychain: references
+	APIKeys map[string]string `json:"api_keys,omitempty" keys:"url"`
+	// PublishTargets are the named destinations `berks publish` uploads to
+	PublishTargets map[string]PublishTarget `json:"publish_targets,omitempty"`
+	// Licenses restricts the cookbook licenses `berks licenses` accepts
+	Licenses *LicensePolicy `json:"licenses,omitempty"`
+	// Policy holds the dependency rules `berks policy-check` enforces
+	Policy *DependencyPolicy `json:"policy,omitempty"`
+	// Owners maps cookbook names, or glob patterns, to the teams that own
+	// them, separated by spaces or commas
+	Owners map[string]string `json:"owners,omitempty"`
+	// Metrics exports resolution metrics when each command finishes
+	Metrics *MetricsConfig `json:"metrics,omitempty"`
+	// Signing signs published cookbooks and verifies downloaded ones
+	Signing *SigningConfig `json:"signing,omitempty"`
+	// Hooks run commands or registered plugins at lifecycle events; those
+	// of every config layer run, lowest precedence first
+	Hooks []hooks.Hook `json:"hooks,omitempty"`
+}
+
+// SourceRoute resolves the cookbooks whose name matches the Pattern regular
+// expression, e.g. ^mycorp-, from the Source URL
+type SourceRoute struct {
+	Pattern string `json:"pattern"`
+	Source  string `json:"source"`
+}
+
+// LicensePolicy lists SPDX license identifiers. A license is forbidden when
+// it is denied, or when an allowlist is set and it is not on it.
+type LicensePolicy struct {
+	Allow []string `json:"allow,omitempty"`
+	Deny  []string `json:"deny,omitempty"`
+}
+
+// DependencyPolicy holds organizational rules on the locked cookbooks.
+// Each rule is off unless set.
+type DependencyPolicy struct {
+	// MaxAgeDays flags locked versions released more than this many days ago
+	MaxAgeDays *int `json:"max_age_days,omitempty"`
+	// DisallowPrerelease flags locked 0.x versions, which are not yet stable
+	DisallowPrerelease *bool `json:"disallow_prerelease,omitempty"`
+	// RequireUpperBounds flags Berksfile constraints that allow any newer
+	// major version, such as >= 1.0 or none at all
+	RequireUpperBounds *bool `json:"require_upper_bounds,omitempty"`
+	// Exempt lists cookbook names, or glob patterns, the rules do not apply to
+	Exempt []string `json:"exempt,omitempty"`
+}
+
+// Publish target types
+const (
+	PublishTargetSupermarket = "supermarket"
+	PublishTargetChefServer  = "chef_server"
+)
+
+// PublishTarget is a destination for `berks publish`. Credentials left empty
+// fall back to api_keys and the chef section.
+type PublishTarget struct {
+	// Type is "supermarket" (including Artifactory) or "chef_server"
+	Type string `json:"type"`
+	// URL is the Supermarket or Artifactory base URL, or the Chef Server URL
+	// without /organizations
+	URL string `json:"url"`
+	// Organizations are the Chef Server organizations to upload to; the
+	// target URL is used as-is when empty
+	Organizations []string `json:"organizations,omitempty"`
+	ClientName    string   `json:"client_name,omitempty"`
+	ClientKey     string   `json:"client_key,omitempty"`
+	// APIKey is an Artifactory API key or an env:/keychain: reference to one
+	APIKey string `json:"api_key,omitempty"`
+	// Category is the Supermarket category
+	Category string `json:"category,omitempty"`
+	// Freeze marks versions uploaded to Chef Server as frozen
+	Freeze bool `json:"freeze,omitempty"`
+	// Profile names a chef profile supplying the client, and the URL of a
+	// chef_server target that sets none
+	Profile string `json:"profile,omitempty"`
 }
 
 // ChefConfig contains Chef-specific configuration with envconfig tags
@@ -35,12 +147,57 @@ type ChefConfig struct {
 	ChefServerURL *string `json:"chef_server_url,omitempty" env:"CHEF_SERVER_URL"`
 	Organization  *string `json:"organization,omitempty" env:"CHEF_ORGANIZATION"`
 	Environment   *string `json:"environment,omitempty" env:"CHEF_ENVIRONMENT"`
+	// Profile selects one of Profiles, whose settings take precedence over
+	// the rest of the chef section. It also selects the profile read from
+	// the Chef Workstation credentials file.
+	Profile *string `json:"profile,omitempty" env:"CHEF_PROFILE"`
+	// Profiles are named Chef Server connections, like knife profiles
+	Profiles map[string]*ChefProfile `json:"profiles,omitempty"`
+}
+
+// ChefProfile is a named Chef Server organization and the client that
+// connects to it
+type ChefProfile struct {
+	ChefServerURL *string `json:"chef_server_url,omitempty"`
+	Organization  *string `json:"organization,omitempty"`
+	NodeName      *string `json:"node_name,omitempty"`
+	ClientKey     *string `json:"client_key,omitempty"`
+}
+
+// MetricsConfig selects where resolution metrics are exported
+type MetricsConfig struct {
+	// PrometheusFile is written in the Prometheus text format, e.g. for the
+	// node_exporter textfile collector
+	PrometheusFile *string `json:"prometheus_file,omitempty" env:"BERKSHELF_METRICS_PROMETHEUS_FILE"`
+	// OTLP sends the metrics to an OpenTelemetry collector at OTLPEndpoint
+	OTLP         *bool   `json:"otlp,omitempty" env:"BERKSHELF_METRICS_OTLP"`
+	OTLPEndpoint *string `json:"otlp_endpoint,omitempty" env:"OTEL_EXPORTER_OTLP_METRICS_ENDPOINT"`
+}
+
+// SigningConfig configures cookbook artifact signing. A trust policy is in
+// force when PublicKeys are set: the artifacts downloaded from Sources, or
+// from every Supermarket source when Sources is empty, must have a signature
+// by one of the keys in the Store.
+type SigningConfig struct {
+	// Key is the PEM private key that signs published cookbooks, as a path
+	// or an env: or keychain: reference
+	Key *string `json:"key,omitempty" env:"BERKSHELF_SIGNING_KEY"`
+	// PublicKeys are the paths of the trusted PEM public keys
+	PublicKeys []string `json:"public_keys,omitempty"`
+	// Store is the directory or http(s) URL signatures are kept in
+	Store *string `json:"store,omitempty" env:"BERKSHELF_SIGNATURE_STORE"`
+	// StoreToken authenticates to an HTTP Store, usually an env: or
+	// keychain: reference
+	StoreToken *string `json:"store_token,omitempty" env:"BERKSHELF_SIGNATURE_STORE_TOKEN"`
+	// Sources are the source URLs the trust policy covers
+	Sources []string `json:"sources,omitempty"`
 }
 
 // Helper functions for creating pointers
-func StringPtr(s string) *string { return &s }
-func BoolPtr(b bool) *bool       { return &b }
-func IntPtr(i int) *int          { return &i }
+func StringPtr(s string) *string    { return &s }
+func BoolPtr(b bool) *bool          { return &b }
+func IntPtr(i int) *int             { return &i }
+func Float64Ptr(f float64) *float64 { return &f }
 
 // =============================================================================
 // GETTER METHODS WITH DEFAULTS
@@ -61,6 +218,46 @@ func (c *Config) GetDefaultSources() []string {
 	return []string{source.PUBLIC_SUPERMARKET}
 }
 
+// GetGroupSources returns the default source URL for each Berksfile group
+func (c *Config) GetGroupSources() map[string]string {
+	return c.GroupSources // maps can be nil/empty naturally
+}
+
+// GetSourceRoutes returns the source routes, in the order they are evaluated
+func (c *Config) GetSourceRoutes() []SourceRoute {
+	return c.SourceRoutes
+}
+
+// GetAPIKeys returns the API key, or credential reference, for each source URL
+func (c *Config) GetAPIKeys() map[string]string {
+	return c.APIKeys
+}
+
+// GetOwners returns the owning teams for each cookbook name or pattern
+func (c *Config) GetOwners() map[string]string {
+	return c.Owners
+}
+
+// GetMetrics returns the metrics exporters, which may be nil
+func (c *Config) GetMetrics() *MetricsConfig {
+	return c.Metrics
+}
+
+// GetSigning returns the signing configuration, which may be nil
+func (c *Config) GetSigning() *SigningConfig {
+	return c.Signing
+}
+
+// GetHooks returns the lifecycle hooks, in the order they run
+func (c *Config) GetHooks() []hooks.Hook {
+	return c.Hooks
+}
+
+// GetPublishTargets returns the configured publish targets by name
+func (c *Config) GetPublishTargets() map[string]PublishTarget {
+	return c.PublishTargets
+}
+
 func (c *Config) GetSSLVerify() bool {
 	if c.SSLVerify != nil {
 		return *c.SSLVerify
@@ -107,6 +304,88 @@ func (c *Config) GetConcurrency() int {
 	return 5 // default 5 concurrent operations
 }
 
+func (c *Config) GetMinCheckInterval() int {
+	if c.MinCheckInterval != nil {
+		return *c.MinCheckInterval
+	}
+	return 900 // default 15 minutes; 0 always checks
+}
+
+func (c *Config) GetRequestsPerSecond() float64 {
+	if c.RequestsPerSecond != nil {
+		return *c.RequestsPerSecond
+	}
+	return 0 // default no limit
+}
+
+func (c *Config) GetBurst() int {
+	if c.Burst != nil {
+		return *c.Burst
+	}
+	return 10 // default 10 requests at once
+}
+
+// GetRateLimit returns the limit on the requests sent to each Supermarket host
+func (c *Config) GetRateLimit() source.RateLimit {
+	return source.RateLimit{RequestsPerSecond: c.GetRequestsPerSecond(), Burst: c.GetBurst()}
+}
+
+func (c *Config) GetResolveTimeout() int {
+	if c.ResolveTimeout != nil {
+		return *c.ResolveTimeout
+	}
+	return 0 // default no limit
+}
+
+func (c *Config) GetDownloadTimeout() int {
+	if c.DownloadTimeout != nil {
+		return *c.DownloadTimeout
+	}
+	return 0 // default no limit
+}
+
+func (c *Config) GetUploadTimeout() int {
+	if c.UploadTimeout != nil {
+		return *c.UploadTimeout
+	}
+	return 0 // default no limit
+}
+
+func (c *Config) GetLockfileName() string {
+	if c.LockfileName != nil {
+		return *c.LockfileName
+	}
+	return "Berksfile.go.lock"
+}
+
+func (c *Config) GetRubyLockfile() bool {
+	if c.RubyLockfile != nil {
+		return *c.RubyLockfile
+	}
+	return true // default to Ruby Berkshelf compatibility
+}
+
+func (c *Config) GetEvalEnv() bool {
+	if c.EvalEnv != nil {
+		return *c.EvalEnv
+	}
+	return true
+}
+
+func (c *Config) GetChecksumAlgorithm() digest.Algorithm {
+	if c.ChecksumAlgorithm != nil {
+		return digest.Algorithm(*c.ChecksumAlgorithm)
+	}
+	return digest.Default
+}
+
+func (c *Config) GetFIPS() bool {
+	if c.FIPS != nil {
+		return *c.FIPS
+	}
+	return false
+}
+
 // ChefConfig getter methods
 func (c *ChefConfig) GetNodeName() string {
 	if c != nil && c.NodeName != nil {
@@ -143,36 +422,198 @@ func (c *ChefConfig) GetEnvironment() string {
 	return ""
 }
 
+func (c *ChefConfig) GetProfile() string {
+	if c != nil && c.Profile != nil {
+		return *c.Profile
+	}
+	return ""
+}
+
+func (c *ChefConfig) GetProfiles() map[string]*ChefProfile {
+	if c != nil {
+		return c.Profiles
+	}
+	return nil
+}
+
+// GetOrganizationURL returns the Chef Server URL of the organization: the
+// chef_server_url, with /organizations/<organization> appended when an
+// organization is set and the URL does not already name one
+func (c *ChefConfig) GetOrganizationURL() string {
+	serverURL := strings.TrimSuffix(c.GetChefServerURL(), "/")
+	org := c.GetOrganization()
+	if serverURL == "" || org == "" || strings.Contains(serverURL, "/organizations/") {
+		return serverURL
+	}
+	return serverURL + "/organizations/" + org
+}
+
+// ForProfile returns a copy of the chef section with the settings of the
+// named profile applied, and false if no such profile is configured
+func (c *ChefConfig) ForProfile(name string) (*ChefConfig, bool) {
+	profile := c.GetProfiles()[name]
+	if profile == nil {
+		return c, false
+	}
+	applied := *c
+	applied.Profile = &name
+	if profile.ChefServerURL != nil {
+		applied.ChefServerURL = profile.ChefServerURL
+		// The profile's URL names its own organization, if any
+		applied.Organization = profile.Organization
+	}
+	if profile.Organization != nil {
+		applied.Organization = profile.Organization
+	}
+	if profile.NodeName != nil {
+		applied.NodeName = profile.NodeName
+	}
+	if profile.ClientKey != nil {
+		applied.ClientKey = profile.ClientKey
+	}
+	return &applied, true
+}
+
+// MetricsConfig getter methods
+func (c *MetricsConfig) GetPrometheusFile() string {
+	if c != nil && c.PrometheusFile != nil {
+		return *c.PrometheusFile
+	}
+	return ""
+}
+
+func (c *MetricsConfig) GetOTLP() bool {
+	if c != nil && c.OTLP != nil {
+		return *c.OTLP
+	}
+	return false
+}
+
+func (c *MetricsConfig) GetOTLPEndpoint() string {
+	if c != nil && c.OTLPEndpoint != nil {
+		return *c.OTLPEndpoint
+	}
+	return "http://localhost:4318/v1/metrics"
+}
+
+// Enabled reports whether metrics are exported anywhere
+func (c *MetricsConfig) Enabled() bool {
+	return c.GetPrometheusFile() != "" || c.GetOTLP()
+}
+
+// SigningConfig getter methods
+func (c *SigningConfig) GetKey() string {
+	if c != nil && c.Key != nil {
+		return *c.Key
+	}
+	return ""
+}
+
+func (c *SigningConfig) GetPublicKeys() []string {
+	if c != nil {
+		return c.PublicKeys
+	}
+	return nil
+}
+
+func (c *SigningConfig) GetStore() string {
+	if c != nil && c.Store != nil {
+		return *c.Store
+	}
+	return ""
+}
+
+func (c *SigningConfig) GetStoreToken() string {
+	if c != nil && c.StoreToken != nil {
+		return *c.StoreToken
+	}
+	return ""
+}
+
+func (c *SigningConfig) GetSources() []string {
+	if c != nil {
+		return c.Sources
+	}
+	return nil
+}
+
+// Verifies reports whether a trust policy is configured
+func (c *SigningConfig) Verifies() bool {
+	return len(c.GetPublicKeys()) > 0
+}
+
+// LicensePolicy getter methods
+func (c *LicensePolicy) GetAllow() []string {
+	if c != nil {
+		return c.Allow
+	}
+	return nil
+}
+
+func (c *LicensePolicy) GetDeny() []string {
+	if c != nil {
+		return c.Deny
+	}
+	return nil
+}
+
+// DependencyPolicy getter methods
+func (c *DependencyPolicy) GetMaxAgeDays() int {
+	if c != nil && c.MaxAgeDays != nil {
+		return *c.MaxAgeDays
+	}
+	return 0
+}
+
+func (c *DependencyPolicy) GetDisallowPrerelease() bool {
+	if c != nil && c.DisallowPrerelease != nil {
+		return *c.DisallowPrerelease
+	}
+	return false
+}
+
+func (c *DependencyPolicy) GetRequireUpperBounds() bool {
+	if c != nil && c.RequireUpperBounds != nil {
+		return *c.RequireUpperBounds
+	}
+	return false
+}
+
+func (c *DependencyPolicy) GetExempt() []string {
+	if c != nil {
+		return c.Exempt
+	}
+	return nil
+}
+
 // =============================================================================
 // CONFIGURATION LOADING
 // =============================================================================
 
-// Load reads configuration from standard locations and environment variables
+// Load reads configuration from all layers and merges them
 func Load() (*Config, error) {
-	// Start with defaults
-	config := DefaultConfig()
-
-	// Try to load from file
-	configPaths := getConfigPaths()
-	for _, path := range configPaths {
-		if _, err := os.Stat(path); err == nil {
-			fileConfig, err := loadFromFile(path)
-			if err != nil {
-				return nil, fmt.Errorf("failed to load config from %s: %w", path, err)
-			}
-			// Merge file config over defaults
-			config = MergeConfigs(config, fileConfig)
-			break
-		}
+	layers, err := LoadLayers()
+	if err != nil {
+		return nil, berrors.WithType(err, berrors.ErrorTypeConfiguration)
 	}
-
-	// Load environment variables and merge them over file/defaults
-	envConfig := loadFromEnvironment()
-	if envConfig != nil {
-		config = MergeConfigs(config, envConfig)
+	cfg := layers.Merge()
+	if err := checkChefProfile(cfg.ChefConfig); err != nil {
+		return nil, berrors.WithType(err, berrors.ErrorTypeConfiguration)
 	}
+	return cfg, nil
+}
 
-	return config, nil
+// checkChefProfile reports a selected chef profile that neither the chef
+// section nor the Chef Workstation credentials file defines
+func checkChefProfile(c *ChefConfig) error {
+	name := c.GetProfile()
+	if name == "" || c.GetProfiles()[name] != nil || credentialsProfileExists(name) {
+		return nil
+	}
+	if len(c.GetProfiles()) == 0 {
+		return fmt.Errorf("unknown chef profile %q: no profiles are configured", name)
+	}
+	return fmt.Errorf("unknown chef profile %q (configured: %s)", name, strings.Join(slices.Sorted(maps.Keys(c.GetProfiles())), ", "))
 }
 
 // LoadFromFile loads configuration from a specific file
@@ -180,6 +621,22 @@ func LoadFromFile(path string) (*Config, error) {
 	return loadFromFile(path)
 }
 
+// ReadFile reads a config file without validating it, so that partially
+// written configuration can be inspected and edited
+func ReadFile(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &Config{}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	return config, nil
+}
+
 // DefaultConfig returns default configuration
 func DefaultConfig() *Config {
 	home, _ := os.UserHomeDir()
@@ -189,11 +646,16 @@ func DefaultConfig() *Config {
 		DefaultSources: []string{
 			source.PUBLIC_SUPERMARKET,
 		},
-		SSLVerify:   BoolPtr(true),
-		APITimeout:  IntPtr(30),
-		RetryCount:  IntPtr(3),
-		RetryDelay:  IntPtr(1),
-		Concurrency: IntPtr(5),
+		SSLVerify:         BoolPtr(true),
+		APITimeout:        IntPtr(30),
+		RetryCount:        IntPtr(3),
+		RetryDelay:        IntPtr(1),
+		Concurrency:       IntPtr(5),
+		MinCheckInterval:  IntPtr(900),
+		LockfileName:      StringPtr("Berksfile.go.lock"),
+		RubyLockfile:      BoolPtr(true),
+		ChecksumAlgorithm: StringPtr(string(digest.Default)),
+		EvalEnv:           BoolPtr(true),
 	}
 }
 
@@ -290,6 +752,90 @@ func loadFromEnvironment() *Config {
 		}
 	}
 
+	// BERKSHELF_MIN_CHECK_INTERVAL
+	if val := os.Getenv("BERKSHELF_MIN_CHECK_INTERVAL"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil && parsed >= 0 {
+			config.MinCheckInterval = IntPtr(parsed)
+			hasValues = true
+		}
+	}
+
+	// BERKSHELF_REQUESTS_PER_SECOND
+	if val := os.Getenv("BERKSHELF_REQUESTS_PER_SECOND"); val != "" {
+		if parsed, err := strconv.ParseFloat(val, 64); err == nil && parsed >= 0 {
+			config.RequestsPerSecond = Float64Ptr(parsed)
+			hasValues = true
+		}
+	}
+
+	// BERKSHELF_BURST
+	if val := os.Getenv("BERKSHELF_BURST"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil && parsed > 0 {
+			config.Burst = IntPtr(parsed)
+			hasValues = true
+		}
+	}
+
+	// BERKSHELF_RESOLVE_TIMEOUT
+	if val := os.Getenv("BERKSHELF_RESOLVE_TIMEOUT"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil && parsed >= 0 {
+			config.ResolveTimeout = IntPtr(parsed)
+			hasValues = true
+		}
+	}
+
+	// BERKSHELF_DOWNLOAD_TIMEOUT
+	if val := os.Getenv("BERKSHELF_DOWNLOAD_TIMEOUT"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil && parsed >= 0 {
+			config.DownloadTimeout = IntPtr(parsed)
+			hasValues = true
+		}
+	}
+
+	// BERKSHELF_UPLOAD_TIMEOUT
+	if val := os.Getenv("BERKSHELF_UPLOAD_TIMEOUT"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil && parsed >= 0 {
+			config.UploadTimeout = IntPtr(parsed)
+			hasValues = true
+		}
+	}
+
+	// BERKSHELF_LOCKFILE_NAME
+	if val := os.Getenv("BERKSHELF_LOCKFILE_NAME"); val != "" {
+		config.LockfileName = StringPtr(val)
+		hasValues = true
+	}
+
+	// BERKSHELF_RUBY_LOCKFILE
+	if val := os.Getenv("BERKSHELF_RUBY_LOCKFILE"); val != "" {
+		if parsed, err := strconv.ParseBool(val); err == nil {
+			config.RubyLockfile = BoolPtr(parsed)
+			hasValues = true
+		}
+	}
+
+	// BERKSHELF_EVAL_ENV
+	if val := os.Getenv("BERKSHELF_EVAL_ENV"); val != "" {
+		if parsed, err := strconv.ParseBool(val); err == nil {
+			config.EvalEnv = BoolPtr(parsed)
+			hasValues = true
+		}
+	}
+
+	// BERKSHELF_CHECKSUM_ALGORITHM
+	if val := os.Getenv("BERKSHELF_CHECKSUM_ALGORITHM"); val != "" {
+		config.ChecksumAlgorithm = StringPtr(val)
+		hasValues = true
+	}
+
+	// BERKSHELF_FIPS
+	if val := os.Getenv("BERKSHELF_FIPS"); val != "" {
+		if parsed, err := strconv.ParseBool(val); err == nil {
+			config.FIPS = BoolPtr(parsed)
+			hasValues = true
+		}
+	}
+
 	// Chef configuration
 	chefConfig := loadChefConfigFromEnvironment()
 	if chefConfig != nil {
@@ -297,6 +843,20 @@ func loadFromEnvironment() *Config {
 		hasValues = true
 	}
 
+	// Metrics configuration
+	metricsConfig := loadMetricsConfigFromEnvironment()
+	if metricsConfig != nil {
+		config.Metrics = metricsConfig
+		hasValues = true
+	}
+
+	// Signing configuration
+	signingConfig := loadSigningConfigFromEnvironment()
+	if signingConfig != nil {
+		config.Signing = signingConfig
+		hasValues = true
+	}
+
 	if !hasValues {
 		return nil
 	}
@@ -304,6 +864,64 @@ func loadFromEnvironment() *Config {
 	return config
 }
 
+// loadMetricsConfigFromEnvironment loads the metrics exporters from
+// environment variables
+func loadMetricsConfigFromEnvironment() *MetricsConfig {
+	metricsConfig := &MetricsConfig{}
+	hasValues := false
+
+	if val := os.Getenv("BERKSHELF_METRICS_PROMETHEUS_FILE"); val != "" {
+		metricsConfig.PrometheusFile = StringPtr(val)
+		hasValues = true
+	}
+
+	if val := os.Getenv("BERKSHELF_METRICS_OTLP"); val != "" {
+		if parsed, err := strconv.ParseBool(val); err == nil {
+			metricsConfig.OTLP = BoolPtr(parsed)
+			hasValues = true
+		}
+	}
+
+	if val := os.Getenv("OTEL_EXPORTER_OTLP_METRICS_ENDPOINT"); val != "" {
+		metricsConfig.OTLPEndpoint = StringPtr(val)
+		hasValues = true
+	}
+
+	if !hasValues {
+		return nil
+	}
+
+	return metricsConfig
+}
+
+// loadSigningConfigFromEnvironment loads the signing key and signature store
+// from environment variables
+func loadSigningConfigFromEnvironment() *SigningConfig {
+	signingConfig := &SigningConfig{}
+	hasValues := false
+
+	if val := os.Getenv("BERKSHELF_SIGNING_KEY"); val != "" {
+		signingConfig.Key = StringPtr(val)
+		hasValues = true
+	}
+
+	if val := os.Getenv("BERKSHELF_SIGNATURE_STORE"); val != "" {
+		signingConfig.Store = StringPtr(val)
+		hasValues = true
+	}
+
+	if val := os.Getenv("BERKSHELF_SIGNATURE_STORE_TOKEN"); val != "" {
+		signingConfig.StoreToken = StringPtr(val)
+		hasValues = true
+	}
+
+	if !hasValues {
+		return nil
+	}
+
+	return signingConfig
+}
+
 // loadChefConfigFromEnvironment loads Chef configuration from environment variables
 func loadChefConfigFromEnvironment() *ChefConfig {
 	chefConfig := &ChefConfig{}
@@ -334,6 +952,11 @@ func loadChefConfigFromEnvironment() *ChefConfig {
 		hasValues = true
 	}
 
+	if val := os.Getenv("CHEF_PROFILE"); val != "" {
+		chefConfig.Profile = StringPtr(val)
+		hasValues = true
+	}
+
 	if !hasValues {
 		return nil
 	}
@@ -375,6 +998,33 @@ func MergeConfigs(base *Config, overlay *Config) *Config {
 			merged.NoProxy = make([]string, len(base.NoProxy))
 			copy(merged.NoProxy, base.NoProxy)
 		}
+		merged.GroupSources = maps.Clone(base.GroupSources)
+		merged.SourceRoutes = slices.Clone(base.SourceRoutes)
+		merged.APIKeys = maps.Clone(base.APIKeys)
+		merged.PublishTargets = maps.Clone(base.PublishTargets)
+		merged.Owners = maps.Clone(base.Owners)
+		merged.Hooks = slices.Clone(base.Hooks)
+		if base.Licenses != nil {
+			merged.Licenses = &LicensePolicy{
+				Allow: slices.Clone(base.Licenses.Allow),
+				Deny:  slices.Clone(base.Licenses.Deny),
+			}
+		}
+		if base.Policy != nil {
+			policy := *base.Policy
+			policy.Exempt = slices.Clone(base.Policy.Exempt)
+			merged.Policy = &policy
+		}
+		if base.Metrics != nil {
+			metrics := *base.Metrics
+			merged.Metrics = &metrics
+		}
+		if base.Signing != nil {
+			signing := *base.Signing
+			signing.PublicKeys = slices.Clone(base.Signing.PublicKeys)
+			signing.Sources = slices.Clone(base.Signing.Sources)
+			merged.Signing = &signing
+		}
 		// Deep copy ChefConfig
 		if base.ChefConfig != nil {
 			merged.ChefConfig = &ChefConfig{
@@ -383,6 +1033,8 @@ func MergeConfigs(base *Config, overlay *Config) *Config {
 				ChefServerURL: base.ChefConfig.ChefServerURL,
 				Organization:  base.ChefConfig.Organization,
 				Environment:   base.ChefConfig.Environment,
+				Profile:       base.ChefConfig.Profile,
+				Profiles:      maps.Clone(base.ChefConfig.Profiles),
 			}
 		}
 	}
@@ -419,6 +1071,47 @@ func MergeConfigs(base *Config, overlay *Config) *Config {
 		merged.Concurrency = overlay.Concurrency
 	}
 
+	if overlay.MinCheckInterval != nil {
+		merged.MinCheckInterval = overlay.MinCheckInterval
+	}
+
+	if overlay.RequestsPerSecond != nil {
+		merged.RequestsPerSecond = overlay.RequestsPerSecond
+	}
+
+	if overlay.Burst != nil {
+		merged.Burst = overlay.Burst
+	}
+
+	if overlay.ResolveTimeout != nil {
+		merged.ResolveTimeout = overlay.ResolveTimeout
+	}
+
+	if overlay.DownloadTimeout != nil {
+		merged.DownloadTimeout = overlay.DownloadTimeout
+	}
+
+	if overlay.UploadTimeout != nil {
+		merged.UploadTimeout = overlay.UploadTimeout
+	}
+
+	if overlay.LockfileName != nil {
+		merged.LockfileName = overlay.LockfileName
+	}
+
+	if overlay.RubyLockfile != nil {
+		merged.RubyLockfile = overlay.RubyLockfile
+	}
+	if overlay.ChecksumAlgorithm != nil {
+		merged.ChecksumAlgorithm = overlay.ChecksumAlgorithm
+	}
+	if overlay.FIPS != nil {
+		merged.FIPS = overlay.FIPS
+	}
+	if overlay.EvalEnv != nil {
+		merged.EvalEnv = overlay.EvalEnv
+	}
+
 	// Slice fields: only override if overlay has non-empty slice
 	if len(overlay.DefaultSources) > 0 {
 		merged.DefaultSources = make([]string, len(overlay.DefaultSources))
@@ -430,6 +1123,121 @@ func MergeConfigs(base *Config, overlay *Config) *Config {
 		copy(merged.NoProxy, overlay.NoProxy)
 	}
 
+	// Map fields: overlay entries replace base entries for the same group
+	if len(overlay.GroupSources) > 0 {
+		groupSources := make(map[string]string, len(merged.GroupSources)+len(overlay.GroupSources))
+		maps.Copy(groupSources, merged.GroupSources)
+		maps.Copy(groupSources, overlay.GroupSources)
+		merged.GroupSources = groupSources
+	}
+
+	// Source routes: the overlay's are evaluated before the base's
+	if len(overlay.SourceRoutes) > 0 {
+		merged.SourceRoutes = slices.Concat(overlay.SourceRoutes, merged.SourceRoutes)
+	}
+
+	if len(overlay.APIKeys) > 0 {
+		apiKeys := make(map[string]string, len(merged.APIKeys)+len(overlay.APIKeys))
+		maps.Copy(apiKeys, merged.APIKeys)
+		maps.Copy(apiKeys, overlay.APIKeys)
+		merged.APIKeys = apiKeys
+	}
+
+	if len(overlay.PublishTargets) > 0 {
+		targets := make(map[string]PublishTarget, len(merged.PublishTargets)+len(overlay.PublishTargets))
+		maps.Copy(targets, merged.PublishTargets)
+		maps.Copy(targets, overlay.PublishTargets)
+		merged.PublishTargets = targets
+	}
+
+	// Hooks: the overlay's run after the base's
+	if len(overlay.Hooks) > 0 {
+		merged.Hooks = slices.Concat(merged.Hooks, overlay.Hooks)
+	}
+
+	if len(overlay.Owners) > 0 {
+		owners := make(map[string]string, len(merged.Owners)+len(overlay.Owners))
+		maps.Copy(owners, merged.Owners)
+		maps.Copy(owners, overlay.Owners)
+		merged.Owners = owners
+	}
+
+	// Licenses: each list is replaced when the overlay sets it
+	if overlay.Licenses != nil {
+		if merged.Licenses == nil {
+			merged.Licenses = &LicensePolicy{}
+		}
+		if len(overlay.Licenses.Allow) > 0 {
+			merged.Licenses.Allow = slices.Clone(overlay.Licenses.Allow)
+		}
+		if len(overlay.Licenses.Deny) > 0 {
+			merged.Licenses.Deny = slices.Clone(overlay.Licenses.Deny)
+		}
+	}
+
+	// Policy: merge individual rules if overlay Policy exists
+	if overlay.Policy != nil {
+		var policy DependencyPolicy
+		if merged.Policy != nil {
+			policy = *merged.Policy // copied, so the base is left as it was
+		}
+		if overlay.Policy.MaxAgeDays != nil {
+			policy.MaxAgeDays = overlay.Policy.MaxAgeDays
+		}
+		if overlay.Policy.DisallowPrerelease != nil {
+			policy.DisallowPrerelease = overlay.Policy.DisallowPrerelease
+		}
+		if overlay.Policy.RequireUpperBounds != nil {
+			policy.RequireUpperBounds = overlay.Policy.RequireUpperBounds
+		}
+		if len(overlay.Policy.Exempt) > 0 {
+			policy.Exempt = slices.Clone(overlay.Policy.Exempt)
+		}
+		merged.Policy = &policy
+	}
+
+	// Metrics: merge individual fields if overlay Metrics exists
+	if overlay.Metrics != nil {
+		var metrics MetricsConfig
+		if merged.Metrics != nil {
+			metrics = *merged.Metrics // copied, so the base is left as it was
+		}
+		if overlay.Metrics.PrometheusFile != nil {
+			metrics.PrometheusFile = overlay.Metrics.PrometheusFile
+		}
+		if overlay.Metrics.OTLP != nil {
+			metrics.OTLP = overlay.Metrics.OTLP
+		}
+		if overlay.Metrics.OTLPEndpoint != nil {
+			metrics.OTLPEndpoint = overlay.Metrics.OTLPEndpoint
+		}
+		merged.Metrics = &metrics
+	}
+
+	// Signing: merge individual fields if overlay Signing exists
+	if overlay.Signing != nil {
+		var signing SigningConfig
+		if merged.Signing != nil {
+			signing = *merged.Signing // copied, so the base is left as it was
+		}
+		if overlay.Signing.Key != nil {
+			signing.Key = overlay.Signing.Key
+		}
+		if len(overlay.Signing.PublicKeys) > 0 {
+			signing.PublicKeys = slices.Clone(overlay.Signing.PublicKeys)
+		}
+		if overlay.Signing.Store != nil {
+			signing.Store = overlay.Signing.Store
+		}
+		if overlay.Signing.StoreToken != nil {
+			signing.StoreToken = overlay.Signing.StoreToken
+		}
+		if len(overlay.Signing.Sources) > 0 {
+			signing.Sources = slices.Clone(overlay.Signing.Sources)
+		}
+		merged.Signing = &signing
+	}
+
 	// ChefConfig: merge individual fields if overlay ChefConfig exists
 	if overlay.ChefConfig != nil {
 		if merged.ChefConfig == nil {
@@ -452,6 +1260,15 @@ func MergeConfigs(base *Config, overlay *Config) *Config {
 		if overlay.ChefConfig.Environment != nil {
 			merged.ChefConfig.Environment = overlay.ChefConfig.Environment
 		}
+		if overlay.ChefConfig.Profile != nil {
+			merged.ChefConfig.Profile = overlay.ChefConfig.Profile
+		}
+		if len(overlay.ChefConfig.Profiles) > 0 {
+			profiles := make(map[string]*ChefProfile, len(merged.ChefConfig.Profiles)+len(overlay.ChefConfig.Profiles))
+			maps.Copy(profiles, merged.ChefConfig.Profiles)
+			maps.Copy(profiles, overlay.ChefConfig.Profiles)
+			merged.ChefConfig.Profiles = profiles
+		}
 	}
 
 	return merged
@@ -515,6 +1332,102 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("concurrency must be positive")
 	}
 
+	if c.GetMinCheckInterval() < 0 {
+		return fmt.Errorf("min_check_interval cannot be negative")
+	}
+
+	if c.GetRequestsPerSecond() < 0 {
+		return fmt.Errorf("requests_per_second cannot be negative")
+	}
+
+	if c.GetBurst() <= 0 {
+		return fmt.Errorf("burst must be positive")
+	}
+
+	if c.GetResolveTimeout() < 0 {
+		return fmt.Errorf("resolve_timeout cannot be negative")
+	}
+
+	if c.GetDownloadTimeout() < 0 {
+		return fmt.Errorf("download_timeout cannot be negative")
+	}
+
+	if c.GetUploadTimeout() < 0 {
+		return fmt.Errorf("upload_timeout cannot be negative")
+	}
+
+	// The lock file sits next to the Berksfile, and Berksfile.lock belongs
+	// to Ruby Berkshelf, which cannot read the JSON lock file
+	switch name := c.GetLockfileName(); {
+	case name == "" || name == "." || name == "..":
+		return fmt.Errorf("lockfile_name must be a file name")
+	case strings.ContainsAny(name, `/\`):
+		return fmt.Errorf("lockfile_name must be a file name, not a path: %q", name)
+	case name == "Berksfile" || name == "Berksfile.lock":
+		return fmt.Errorf("lockfile_name cannot be %s", name)
+	}
+
+	// MD5 is only for protocols that require it
+	if algorithm := c.GetChecksumAlgorithm(); algorithm == digest.MD5 {
+		return fmt.Errorf("checksum_algorithm cannot be md5")
+	} else if _, err := digest.Lookup(algorithm); err != nil {
+		return fmt.Errorf("checksum_algorithm: %w", err)
+	} else if c.GetFIPS() && !digest.Approved(algorithm) {
+		return fmt.Errorf("checksum_algorithm: %s is not FIPS 140-3 approved, use sha256 or sha512", algorithm)
+	}
+
+	for group, url := range c.GroupSources {
+		if strings.TrimSpace(url) == "" {
+			return fmt.Errorf("group_sources: source for group %q cannot be empty", group)
+		}
+	}
+
+	for url, key := range c.APIKeys {
+		if strings.TrimSpace(key) == "" {
+			return fmt.Errorf("api_keys: key for %q cannot be empty", url)
+		}
+	}
+
+	for pattern, owners := range c.Owners {
+		if strings.TrimSpace(owners) == "" {
+			return fmt.Errorf("owners: owners for %q cannot be empty", pattern)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("owners: invalid pattern %q: %w", pattern, err)
+		}
+	}
+
+	for name, target := range c.PublishTargets {
+		switch target.Type {
+		case PublishTargetSupermarket, PublishTargetChefServer:
+		default:
+			return fmt.Errorf("publish_targets: target %q has unknown type %q (expected %s or %s)",
+				name, target.Type, PublishTargetSupermarket, PublishTargetChefServer)
+		}
+		if strings.TrimSpace(target.URL) == "" && (target.Type != PublishTargetChefServer || target.Profile == "") {
+			return fmt.Errorf("publish_targets: target %q has no url", name)
+		}
+	}
+
+	for i, hook := range c.Hooks {
+		if err := hook.Validate(); err != nil {
+			return fmt.Errorf("hooks[%d]: %w", i, err)
+		}
+	}
+
+	if c.Policy.GetMaxAgeDays() < 0 {
+		return fmt.Errorf("policy: max_age_days cannot be negative")
+	}
+	for _, pattern := range c.Policy.GetExempt() {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("policy: invalid exempt pattern %q: %w", pattern, err)
+		}
+	}
+
+	if c.Signing.Verifies() && c.Signing.GetStore() == "" {
+		return fmt.Errorf("signing: public_keys require a signature store")
+	}
+
 	// Validate Chef config if present
 	if c.ChefConfig != nil {
 		if err := c.ChefConfig.validate(); err != nil {
@@ -551,8 +1464,24 @@ func GetDefaultConfigPath() string {
 	return filepath.Join(GetConfigDir(), "config.json")
 }
 
-// validate validates Chef configuration
+// validate validates Chef configuration. A chef section holding only
+// profiles is valid without the connection settings; each profile needs a
+// server URL, client name and key, whose file is only checked once used.
 func (c *ChefConfig) validate() error {
+	for name, profile := range c.Profiles {
+		switch {
+		case profile == nil || profile.ChefServerURL == nil || *profile.ChefServerURL == "":
+			return fmt.Errorf("profile %q has no chef_server_url", name)
+		case profile.NodeName == nil || *profile.NodeName == "":
+			return fmt.Errorf("profile %q has no node_name", name)
+		case profile.ClientKey == nil || *profile.ClientKey == "":
+			return fmt.Errorf("profile %q has no client_key", name)
+		}
+	}
+	if c.NodeName == nil && c.ClientKey == nil && c.ChefServerURL == nil && c.Organization == nil && c.Environment == nil {
+		return nil
+	}
+
 	nodeName := c.GetNodeName()
 	if nodeName == "" {
 		return fmt.Errorf("node_name cannot be empty")
@@ -576,23 +1505,6 @@ func (c *ChefConfig) validate() error {
 	return nil
 }
 
-// getConfigPaths returns possible configuration file paths in order of precedence
-func getConfigPaths() []string {
-	home, _ := os.UserHomeDir()
-
-	return []string{
-		// Local project config (highest precedence)
-		"./.berkshelf/config.json",
-		"./config.json",
-
-		// User-specific config
-		filepath.Join(home, ".berkshelf", "config.json"),
-
-		// Global config (lowest precedence)
-		"/etc/berkshelf/config.json",
-	}
-}
-
 // loadFromFile loads configuration from a JSON file
 func loadFromFile(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
@@ -0,0 +1,145 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/hooks"
+)
+
+func TestValidateLockfileName(t *testing.T) {
+	tests := []struct {
+		name  string
+		valid bool
+	}{
+		{"Berksfile.go.lock", true},
+		{"Berksfile.json.lock", true},
+		{"", false},
+		{"locks/Berksfile.go.lock", false},
+		{`locks\Berksfile.go.lock`, false},
+		{"..", false},
+		{"Berksfile", false},
+		{"Berksfile.lock", false},
+	}
+	for _, tt := range tests {
+		cfg := &Config{LockfileName: StringPtr(tt.name)}
+		if err := cfg.Validate(); (err == nil) != tt.valid {
+			t.Errorf("Validate() with lockfile_name %q error = %v, want valid %v", tt.name, err, tt.valid)
+		}
+	}
+}
+
+func TestValidateChecksumAlgorithm(t *testing.T) {
+	tests := []struct {
+		algorithm string
+		fips      bool
+		valid     bool
+	}{
+		{"sha256", false, true},
+		{"sha512", false, true},
+		{"blake3", false, true},
+		{"md5", false, false},
+		{"crc32", false, false},
+		{"sha256", true, true},
+		{"sha512", true, true},
+		{"blake3", true, false},
+	}
+	for _, tt := range tests {
+		cfg := &Config{ChecksumAlgorithm: StringPtr(tt.algorithm), FIPS: BoolPtr(tt.fips)}
+		if err := cfg.Validate(); (err == nil) != tt.valid {
+			t.Errorf("Validate() with checksum_algorithm %q and fips %v error = %v, want valid %v", tt.algorithm, tt.fips, err, tt.valid)
+		}
+	}
+}
+
+func TestValidatePolicy(t *testing.T) {
+	tests := []struct {
+		policy *DependencyPolicy
+		valid  bool
+	}{
+		{nil, true},
+		{&DependencyPolicy{MaxAgeDays: IntPtr(365), Exempt: []string{"legacy_*"}}, true},
+		{&DependencyPolicy{MaxAgeDays: IntPtr(-1)}, false},
+		{&DependencyPolicy{Exempt: []string{"legacy_["}}, false},
+	}
+	for _, tt := range tests {
+		cfg := &Config{Policy: tt.policy}
+		if err := cfg.Validate(); (err == nil) != tt.valid {
+			t.Errorf("Validate() with policy %+v error = %v, want valid %v", tt.policy, err, tt.valid)
+		}
+	}
+}
+
+func TestValidateHooks(t *testing.T) {
+	tests := []struct {
+		hooks []hooks.Hook
+		valid bool
+	}{
+		{nil, true},
+		{[]hooks.Hook{{Event: hooks.PreResolve, Command: "./check.sh"}, {Event: hooks.PreUpload, Plugin: "compliance"}}, true},
+		{[]hooks.Hook{{Event: "post-install", Command: "./check.sh"}}, false},
+		{[]hooks.Hook{{Event: hooks.PreVendor}}, false},
+	}
+	for _, tt := range tests {
+		cfg := &Config{Hooks: tt.hooks}
+		if err := cfg.Validate(); (err == nil) != tt.valid {
+			t.Errorf("Validate() with hooks %+v error = %v, want valid %v", tt.hooks, err, tt.valid)
+		}
+	}
+}
+
+func TestValidateChefProfiles(t *testing.T) {
+	profile := func(url, node, key string) *ChefProfile {
+		return &ChefProfile{ChefServerURL: StringPtr(url), NodeName: StringPtr(node), ClientKey: StringPtr(key)}
+	}
+	tests := []struct {
+		name  string
+		chef  *ChefConfig
+		valid bool
+	}{
+		{"profiles only", &ChefConfig{Profiles: map[string]*ChefProfile{"prod": profile("https://chef.example.com", "deployer", "prod.pem")}}, true},
+		{"profile selected", &ChefConfig{Profile: StringPtr("prod")}, true},
+		{"profile without url", &ChefConfig{Profiles: map[string]*ChefProfile{"prod": profile("", "deployer", "prod.pem")}}, false},
+		{"profile without key", &ChefConfig{Profiles: map[string]*ChefProfile{"prod": profile("https://chef.example.com", "deployer", "")}}, false},
+		{"incomplete connection", &ChefConfig{NodeName: StringPtr("deployer")}, false},
+	}
+	for _, tt := range tests {
+		cfg := &Config{ChefConfig: tt.chef}
+		if err := cfg.Validate(); (err == nil) != tt.valid {
+			t.Errorf("Validate() with %s error = %v, want valid %v", tt.name, err, tt.valid)
+		}
+	}
+}
+
+func TestChefConfigForProfile(t *testing.T) {
+	chef := &ChefConfig{
+		NodeName:      StringPtr("me"),
+		ClientKey:     StringPtr("me.pem"),
+		ChefServerURL: StringPtr("https://chef.example.com"),
+		Organization:  StringPtr("dev"),
+		Profiles: map[string]*ChefProfile{
+			"prod":    {ChefServerURL: StringPtr("https://chef-prod.example.com/"), Organization: StringPtr("acme"), ClientKey: StringPtr("prod.pem")},
+			"staging": {ChefServerURL: StringPtr("https://staging.example.com/organizations/qa")},
+		},
+	}
+	if got := chef.GetOrganizationURL(); got != "https://chef.example.com/organizations/dev" {
+		t.Errorf("GetOrganizationURL() = %q", got)
+	}
+
+	prod, ok := chef.ForProfile("prod")
+	if !ok {
+		t.Fatal("ForProfile(prod) found no profile")
+	}
+	if prod.GetOrganizationURL() != "https://chef-prod.example.com/organizations/acme" || prod.GetNodeName() != "me" || prod.GetClientKey() != "prod.pem" || prod.GetProfile() != "prod" {
+		t.Errorf("ForProfile(prod) = %s as %s with %s", prod.GetOrganizationURL(), prod.GetNodeName(), prod.GetClientKey())
+	}
+	// A profile URL naming its organization does not take the default one
+	if staging, _ := chef.ForProfile("staging"); staging.GetOrganizationURL() != "https://staging.example.com/organizations/qa" {
+		t.Errorf("ForProfile(staging) URL = %q", staging.GetOrganizationURL())
+	}
+	if _, ok := chef.ForProfile("missing"); ok {
+		t.Error("ForProfile(missing) found a profile")
+	}
+	if chef.GetChefServerURL() != "https://chef.example.com" {
+		t.Error("ForProfile() changed the chef section it was called on")
+	}
+}
@@ -72,6 +72,28 @@ func TestLoadFromEnvironment(t *testing.T) {
 				Concurrency: IntPtr(10),
 			},
 		},
+		{
+			name: "min check interval",
+			envVars: map[string]string{
+				"BERKSHELF_MIN_CHECK_INTERVAL": "0",
+			},
+			expected: &Config{
+				MinCheckInterval: IntPtr(0),
+			},
+		},
+		{
+			name: "phase timeouts",
+			envVars: map[string]string{
+				"BERKSHELF_RESOLVE_TIMEOUT":  "120",
+				"BERKSHELF_DOWNLOAD_TIMEOUT": "600",
+				"BERKSHELF_UPLOAD_TIMEOUT":   "0",
+			},
+			expected: &Config{
+				ResolveTimeout:  IntPtr(120),
+				DownloadTimeout: IntPtr(600),
+				UploadTimeout:   IntPtr(0),
+			},
+		},
 		{
 			name: "proxy configuration",
 			envVars: map[string]string{
@@ -113,6 +135,37 @@ func TestLoadFromEnvironment(t *testing.T) {
 				NoProxy:     []string{"localhost", "*.internal.com"},
 			},
 		},
+		{
+			name: "lock file naming",
+			envVars: map[string]string{
+				"BERKSHELF_LOCKFILE_NAME": "Berksfile.json.lock",
+				"BERKSHELF_RUBY_LOCKFILE": "false",
+			},
+			expected: &Config{
+				LockfileName: StringPtr("Berksfile.json.lock"),
+				RubyLockfile: BoolPtr(false),
+			},
+		},
+		{
+			name: "checksum algorithm",
+			envVars: map[string]string{
+				"BERKSHELF_CHECKSUM_ALGORITHM": "sha512",
+				"BERKSHELF_FIPS":               "true",
+			},
+			expected: &Config{
+				ChecksumAlgorithm: StringPtr("sha512"),
+				FIPS:              BoolPtr(true),
+			},
+		},
+		{
+			name: "conditional evaluation",
+			envVars: map[string]string{
+				"BERKSHELF_EVAL_ENV": "false",
+			},
+			expected: &Config{
+				EvalEnv: BoolPtr(false),
+			},
+		},
 		{
 			name: "chef configuration",
 			envVars: map[string]string{
@@ -180,11 +233,24 @@ func clearEnv() {
 		"BERKSHELF_RETRY_COUNT",
 		"BERKSHELF_RETRY_DELAY",
 		"BERKSHELF_CONCURRENCY",
+		"BERKSHELF_MIN_CHECK_INTERVAL",
+		"BERKSHELF_RESOLVE_TIMEOUT",
+		"BERKSHELF_DOWNLOAD_TIMEOUT",
+		"BERKSHELF_UPLOAD_TIMEOUT",
+		"BERKSHELF_LOCKFILE_NAME",
+		"BERKSHELF_RUBY_LOCKFILE",
+		"BERKSHELF_CHECKSUM_ALGORITHM",
+		"BERKSHELF_FIPS",
+		"BERKSHELF_EVAL_ENV",
+		"BERKSHELF_SIGNING_KEY",
+		"BERKSHELF_SIGNATURE_STORE",
+		"BERKSHELF_SIGNATURE_STORE_TOKEN",
 		"CHEF_NODE_NAME",
 		"CHEF_CLIENT_KEY",
 		"CHEF_SERVER_URL",
 		"CHEF_ORGANIZATION",
 		"CHEF_ENVIRONMENT",
+		"CHEF_PROFILE",
 	}
 
 	for _, envVar := range envVars {
@@ -0,0 +1,200 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Keys returns every configuration key in dotted form (e.g. "chef.node_name").
+// Map fields such as group_sources accept an additional ".<name>" segment.
+func Keys() []string {
+	return collectKeys(reflect.TypeFor[Config](), "")
+}
+
+func collectKeys(t reflect.Type, prefix string) []string {
+	var keys []string
+	for i := range t.NumField() {
+		f := t.Field(i)
+		name := jsonName(f)
+		if name == "" {
+			continue
+		}
+		if f.Type.Kind() == reflect.Pointer && f.Type.Elem().Kind() == reflect.Struct {
+			keys = append(keys, collectKeys(f.Type.Elem(), prefix+name+".")...)
+			continue
+		}
+		keys = append(keys, prefix+name)
+	}
+	return keys
+}
+
+// Get returns the value stored under key and whether it is set. Values are
+// returned dereferenced: string, bool, int, []string or map[string]string.
+func (c *Config) Get(key string) (any, bool) {
+	field, mapKey, err := c.lookup(key, false)
+	if err != nil || !field.IsValid() {
+		return nil, false
+	}
+
+	if field.Kind() == reflect.Map {
+		if field.Len() == 0 {
+			return nil, false
+		}
+		if mapKey == "" {
+			return field.Interface(), true
+		}
+		value := field.MapIndex(reflect.ValueOf(mapKey))
+		if !value.IsValid() {
+			return nil, false
+		}
+		return value.Interface(), true
+	}
+
+	switch field.Kind() {
+	case reflect.Pointer:
+		if field.IsNil() {
+			return nil, false
+		}
+		return field.Elem().Interface(), true
+	case reflect.Slice:
+		if field.Len() == 0 {
+			return nil, false
+		}
+		return field.Interface(), true
+	}
+	return nil, false
+}
+
+// Set parses value according to the type of key and stores it. Lists are
+// given comma-separated; map entries are addressed as "<key>.<name>".
+func (c *Config) Set(key, value string) error {
+	field, mapKey, err := c.lookup(key, true)
+	if err != nil {
+		return err
+	}
+
+	switch field.Kind() {
+	case reflect.Map:
+		if field.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("%s cannot be set directly; edit the config file", key)
+		}
+		if mapKey == "" {
+			return fmt.Errorf("%s is a map; set individual entries with %s.<name>", key, key)
+		}
+		if field.IsNil() {
+			field.Set(reflect.MakeMap(field.Type()))
+		}
+		field.SetMapIndex(reflect.ValueOf(mapKey), reflect.ValueOf(value))
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("%s cannot be set directly; edit the config file", key)
+		}
+		var items []string
+		for item := range strings.SplitSeq(value, ",") {
+			if trimmed := strings.TrimSpace(item); trimmed != "" {
+				items = append(items, trimmed)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	case reflect.Pointer:
+		switch field.Type().Elem().Kind() {
+		case reflect.String:
+			field.Set(reflect.ValueOf(StringPtr(value)))
+		case reflect.Bool:
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("%s must be true or false, got %q", key, value)
+			}
+			field.Set(reflect.ValueOf(BoolPtr(parsed)))
+		case reflect.Int:
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("%s must be an integer, got %q", key, value)
+			}
+			field.Set(reflect.ValueOf(IntPtr(parsed)))
+		case reflect.Float64:
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return fmt.Errorf("%s must be a number, got %q", key, value)
+			}
+			field.Set(reflect.ValueOf(Float64Ptr(parsed)))
+		default:
+			return fmt.Errorf("%s cannot be set directly", key)
+		}
+	default:
+		return fmt.Errorf("%s cannot be set directly", key)
+	}
+
+	return nil
+}
+
+// lookup resolves a dotted key to its struct field. For map fields the
+// remaining segment is returned as mapKey. With create, nil nested structs
+// are allocated along the way.
+func (c *Config) lookup(key string, create bool) (field reflect.Value, mapKey string, err error) {
+	parts := strings.Split(key, ".")
+	current := reflect.ValueOf(c).Elem()
+
+	for i, part := range parts {
+		field, sf, ok := fieldByJSONName(current, part)
+		if !ok {
+			return reflect.Value{}, "", fmt.Errorf("unknown config key %q", key)
+		}
+
+		last := i == len(parts)-1
+		switch {
+		case field.Kind() == reflect.Map:
+			// Maps keyed by URL (e.g. api_keys.https://supermarket.chef.io) take
+			// the rest of the key; other map keys are a single segment
+			if sf.Tag.Get("keys") != "url" && i < len(parts)-2 {
+				return reflect.Value{}, "", fmt.Errorf("unknown config key %q", key)
+			}
+			if !last {
+				mapKey = strings.Join(parts[i+1:], ".")
+			}
+			return field, mapKey, nil
+		case field.Kind() == reflect.Pointer && field.Type().Elem().Kind() == reflect.Struct:
+			if last {
+				return reflect.Value{}, "", fmt.Errorf("%s is a section; use one of its keys", key)
+			}
+			if field.IsNil() {
+				if !create {
+					return reflect.Value{}, "", nil
+				}
+				field.Set(reflect.New(field.Type().Elem()))
+			}
+			current = field.Elem()
+		case last:
+			return field, "", nil
+		default:
+			return reflect.Value{}, "", fmt.Errorf("unknown config key %q", key)
+		}
+	}
+
+	return reflect.Value{}, "", fmt.Errorf("unknown config key %q", key)
+}
+
+func fieldByJSONName(v reflect.Value, name string) (reflect.Value, reflect.StructField, bool) {
+	for i := range v.NumField() {
+		if f := v.Type().Field(i); jsonName(f) == name {
+			return v.Field(i), f, true
+		}
+	}
+	return reflect.Value{}, reflect.StructField{}, false
+}
+
+func jsonName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+// ValidateKey reports whether key names a settable configuration value
+func ValidateKey(key string) error {
+	_, _, err := (&Config{}).lookup(key, true)
+	return err
+}
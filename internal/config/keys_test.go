@@ -0,0 +1,89 @@
+package config
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestKeys(t *testing.T) {
+	keys := Keys()
+	for _, want := range []string{"cache_path", "default_sources", "group_sources", "chef.node_name", "concurrency"} {
+		if !slices.Contains(keys, want) {
+			t.Errorf("Keys() missing %q", want)
+		}
+	}
+	if slices.Contains(keys, "chef") {
+		t.Error("Keys() should not include the chef section itself")
+	}
+}
+
+func TestConfigSetGet(t *testing.T) {
+	tests := []struct {
+		key   string
+		value string
+		want  any
+	}{
+		{"cache_path", "/tmp/cookbooks", "/tmp/cookbooks"},
+		{"ssl_verify", "false", false},
+		{"concurrency", "10", 10},
+		{"requests_per_second", "2.5", 2.5},
+		{"default_sources", "https://a.example.com, https://b.example.com", []string{"https://a.example.com", "https://b.example.com"}},
+		{"group_sources.test", "https://test.example.com", "https://test.example.com"},
+		{"api_keys.https://supermarket.example.com", "env:SUPERMARKET_KEY", "env:SUPERMARKET_KEY"},
+		{"chef.node_name", "deployer", "deployer"},
+		{"chef.profile", "prod", "prod"},
+		{"metrics.otlp", "true", true},
+		{"metrics.prometheus_file", "/tmp/berks.prom", "/tmp/berks.prom"},
+		{"fips", "true", true},
+		{"signing.store", "/srv/signatures", "/srv/signatures"},
+		{"signing.public_keys", "a.pub, b.pub", []string{"a.pub", "b.pub"}},
+		{"owners.acme_*", "@platform @sre", "@platform @sre"},
+		{"licenses.deny", "GPL-3.0, AGPL-3.0", []string{"GPL-3.0", "AGPL-3.0"}},
+		{"policy.max_age_days", "365", 365},
+		{"policy.disallow_prerelease", "true", true},
+		{"policy.exempt", "legacy_*, internal", []string{"legacy_*", "internal"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			cfg := &Config{}
+			if _, ok := cfg.Get(tt.key); ok {
+				t.Fatalf("Get(%q) reported set on empty config", tt.key)
+			}
+			if err := cfg.Set(tt.key, tt.value); err != nil {
+				t.Fatalf("Set(%q) error = %v", tt.key, err)
+			}
+			got, ok := cfg.Get(tt.key)
+			if !ok || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Get(%q) = %#v, %v; want %#v", tt.key, got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigSetErrors(t *testing.T) {
+	tests := []struct {
+		key   string
+		value string
+	}{
+		{"nope", "x"},
+		{"ssl_verify", "maybe"},
+		{"concurrency", "many"},
+		{"requests_per_second", "fast"},
+		{"chef", "x"},
+		{"chef.nope", "x"},
+		{"group_sources", "x"},
+		{"group_sources.a.b", "x"},
+		{"cache_path.sub", "x"},
+		{"publish_targets.prod", "x"},
+		{"source_routes", "x"},
+		{"hooks", "x"},
+	}
+
+	for _, tt := range tests {
+		if err := (&Config{}).Set(tt.key, tt.value); err == nil {
+			t.Errorf("Set(%q, %q) expected error", tt.key, tt.value)
+		}
+	}
+}
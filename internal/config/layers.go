@@ -0,0 +1,200 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// Layer scopes, lowest precedence first
+const (
+	ScopeDefault     = "default"
+	ScopeWorkstation = "workstation"
+	ScopeGlobal      = "global"
+	ScopeUser        = "user"
+	ScopeProject     = "project"
+	ScopeFile        = "file"
+	ScopeEnv         = "env"
+	ScopeFlags       = "flags"
+)
+
+// Layer is one source of configuration values
+type Layer struct {
+	// Scope is one of the Scope* constants
+	Scope string
+	// Path is the config file the values were read from, if any
+	Path   string
+	Config *Config
+}
+
+// String describes the layer, e.g. "user (/home/me/.berkshelf/config.json)"
+func (l Layer) String() string {
+	if l.Path != "" {
+		return fmt.Sprintf("%s (%s)", l.Scope, l.Path)
+	}
+	return l.Scope
+}
+
+// Layers are merged in order, so later layers take precedence over earlier ones
+type Layers []Layer
+
+// Options adds layers beyond the standard locations and the environment
+type Options struct {
+	// File is an explicit config file (--config), merged above the project files
+	File string
+	// Flags holds values given on the command line and takes precedence over everything
+	Flags *Config
+}
+
+var loadOptions Options
+
+// SetOptions sets the options used by Load and LoadLayers
+func SetOptions(opts Options) {
+	loadOptions = opts
+}
+
+// LoadLayers reads every configuration layer, lowest precedence first:
+// defaults, Chef Workstation (~/.chef/credentials and config.rb),
+// /etc/berkshelf, the user's ~/.berkshelf, the project, an explicit --config
+// file, the environment, and command line flags
+func LoadLayers() (Layers, error) {
+	layers := Layers{{Scope: ScopeDefault, Config: DefaultConfig()}}
+	layers = append(layers, workstationLayers()...)
+	seen := make(map[string]bool)
+
+	for _, file := range configFiles() {
+		abs, err := filepath.Abs(file.Path)
+		if err != nil {
+			abs = file.Path
+		}
+		if seen[abs] {
+			continue // e.g. the project directory is the home directory
+		}
+		if _, err := os.Stat(file.Path); err != nil {
+			if file.Scope == ScopeFile {
+				return nil, fmt.Errorf("failed to load config from %s: %w", file.Path, err)
+			}
+			continue
+		}
+		seen[abs] = true
+
+		fileConfig, err := loadFromFile(file.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load config from %s: %w", file.Path, err)
+		}
+		file.Config = fileConfig
+		layers = append(layers, file)
+	}
+
+	if envConfig := loadFromEnvironment(); envConfig != nil {
+		layers = append(layers, Layer{Scope: ScopeEnv, Config: envConfig})
+	}
+
+	if loadOptions.Flags != nil {
+		layers = append(layers, Layer{Scope: ScopeFlags, Config: loadOptions.Flags})
+	}
+
+	return layers, nil
+}
+
+// Merge returns the effective configuration, with the selected chef profile
+// applied
+func (l Layers) Merge() *Config {
+	var merged *Config
+	for _, layer := range l {
+		merged = MergeConfigs(merged, layer.Config)
+	}
+	if merged == nil {
+		return DefaultConfig()
+	}
+	merged.ChefConfig, _ = merged.ChefConfig.ForProfile(merged.ChefConfig.GetProfile())
+	return merged
+}
+
+// Origin returns the highest-precedence layer that sets key
+func (l Layers) Origin(key string) (Layer, bool) {
+	for _, layer := range slices.Backward(l) {
+		if _, ok := layer.Config.Get(key); ok {
+			return layer, true
+		}
+	}
+	return Layer{}, false
+}
+
+// Provenance loads every layer and reports where each effective setting came from
+func Provenance() ([]Setting, error) {
+	layers, err := LoadLayers()
+	if err != nil {
+		return nil, err
+	}
+	return layers.Provenance(), nil
+}
+
+// Setting is an effective configuration value and where it came from
+type Setting struct {
+	Key   string `json:"key"`
+	Value any    `json:"value"`
+	// Origin describes the layer the effective value came from
+	Origin string `json:"origin"`
+	// Overridden describes lower layers that also set the key, highest first
+	Overridden []string `json:"overridden,omitempty"`
+}
+
+// Provenance reports every effective setting in Keys() order with the layer
+// it came from. Map entries such as group_sources.<name> are reported
+// individually, since each may come from a different layer.
+func (l Layers) Provenance() []Setting {
+	merged := l.Merge()
+
+	var settings []Setting
+	for _, key := range Keys() {
+		value, ok := merged.Get(key)
+		if !ok {
+			continue
+		}
+
+		if entries, isMap := value.(map[string]string); isMap {
+			for _, name := range slices.Sorted(maps.Keys(entries)) {
+				settings = append(settings, l.setting(key+"."+name, entries[name]))
+			}
+			continue
+		}
+		settings = append(settings, l.setting(key, value))
+	}
+	return settings
+}
+
+func (l Layers) setting(key string, value any) Setting {
+	s := Setting{Key: key, Value: value}
+	for _, layer := range slices.Backward(l) {
+		if _, ok := layer.Config.Get(key); !ok {
+			continue
+		}
+		if s.Origin == "" {
+			s.Origin = layer.String()
+		} else {
+			s.Overridden = append(s.Overridden, layer.String())
+		}
+	}
+	return s
+}
+
+// configFiles returns the config file layers in order of increasing precedence
+func configFiles() []Layer {
+	home, _ := os.UserHomeDir()
+
+	files := []Layer{
+		{Scope: ScopeGlobal, Path: "/etc/berkshelf/config.json"},
+		{Scope: ScopeUser, Path: filepath.Join(home, ".berkshelf", "config.json")},
+		{Scope: ScopeProject, Path: "./config.json"},
+		{Scope: ScopeProject, Path: "./.berkshelf/config.json"},
+	}
+
+	if loadOptions.File != "" {
+		files = append(files, Layer{Scope: ScopeFile, Path: loadOptions.File})
+	}
+
+	return files
+}
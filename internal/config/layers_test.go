@@ -0,0 +1,137 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeConfig(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadLayers(t *testing.T) {
+	clearEnv()
+	home := t.TempDir()
+	project := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Chdir(project)
+
+	writeConfig(t, filepath.Join(home, ".berkshelf", "config.json"),
+		`{"concurrency": 2, "retry_count": 7, "group_sources": {"test": "https://user.example.com"}}`)
+	writeConfig(t, filepath.Join(project, ".berkshelf", "config.json"),
+		`{"concurrency": 4, "group_sources": {"prod": "https://project.example.com"}}`)
+	explicit := filepath.Join(t.TempDir(), "ci.json")
+	writeConfig(t, explicit, `{"api_timeout": 90}`)
+
+	t.Setenv("BERKSHELF_RETRY_DELAY", "6")
+	SetOptions(Options{File: explicit, Flags: &Config{Concurrency: IntPtr(16)}})
+	t.Cleanup(func() { SetOptions(Options{}) })
+
+	layers, err := LoadLayers()
+	if err != nil {
+		t.Fatalf("LoadLayers() error = %v", err)
+	}
+
+	var scopes []string
+	for _, layer := range layers {
+		scopes = append(scopes, layer.Scope)
+	}
+	want := []string{ScopeDefault, ScopeUser, ScopeProject, ScopeFile, ScopeEnv, ScopeFlags}
+	if !reflect.DeepEqual(scopes, want) {
+		t.Fatalf("layer scopes = %v, want %v", scopes, want)
+	}
+
+	cfg := layers.Merge()
+	if cfg.GetConcurrency() != 16 || cfg.GetRetryCount() != 7 || cfg.GetRetryDelay() != 6 || cfg.GetAPITimeout() != 90 {
+		t.Errorf("merged config = concurrency %d, retry_count %d, retry_delay %d, api_timeout %d",
+			cfg.GetConcurrency(), cfg.GetRetryCount(), cfg.GetRetryDelay(), cfg.GetAPITimeout())
+	}
+	wantGroups := map[string]string{"test": "https://user.example.com", "prod": "https://project.example.com"}
+	if !reflect.DeepEqual(cfg.GetGroupSources(), wantGroups) {
+		t.Errorf("group sources = %v, want %v", cfg.GetGroupSources(), wantGroups)
+	}
+
+	origins := make(map[string]Setting)
+	for _, s := range layers.Provenance() {
+		origins[s.Key] = s
+	}
+
+	userPath := filepath.Join(home, ".berkshelf", "config.json")
+	tests := map[string]string{
+		"cache_path":         ScopeDefault,
+		"retry_count":        "user (" + userPath + ")",
+		"retry_delay":        ScopeEnv,
+		"api_timeout":        "file (" + explicit + ")",
+		"concurrency":        ScopeFlags,
+		"group_sources.test": "user (" + userPath + ")",
+		"group_sources.prod": "project (./.berkshelf/config.json)",
+	}
+	for key, want := range tests {
+		if got := origins[key].Origin; got != want {
+			t.Errorf("origin of %s = %q, want %q", key, got, want)
+		}
+	}
+
+	wantOverridden := []string{"project (./.berkshelf/config.json)", "user (" + userPath + ")", ScopeDefault}
+	if got := origins["concurrency"].Overridden; !reflect.DeepEqual(got, wantOverridden) {
+		t.Errorf("concurrency overridden = %v, want %v", got, wantOverridden)
+	}
+}
+
+func TestLoadLayers_MissingExplicitFile(t *testing.T) {
+	SetOptions(Options{File: filepath.Join(t.TempDir(), "missing.json")})
+	t.Cleanup(func() { SetOptions(Options{}) })
+
+	if _, err := LoadLayers(); err == nil {
+		t.Error("LoadLayers() expected error for a missing --config file")
+	}
+}
+
+func TestLoad_ChefProfile(t *testing.T) {
+	clearEnv()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("CHEF_HOME", "")
+	t.Chdir(t.TempDir())
+	key := filepath.Join(home, "me.pem")
+	writeConfig(t, key, "key")
+	writeConfig(t, filepath.Join(home, ".berkshelf", "config.json"), `{"chef": {
+		"node_name": "me", "client_key": "`+key+`", "chef_server_url": "https://chef.example.com/organizations/dev",
+		"profiles": {"prod": {"chef_server_url": "https://chef-prod.example.com", "organization": "acme", "node_name": "deployer", "client_key": "prod.pem"}}
+	}}`)
+	t.Cleanup(func() { SetOptions(Options{}) })
+
+	cfg, err := Load()
+	if err != nil || cfg.ChefConfig.GetNodeName() != "me" {
+		t.Fatalf("Load() without a profile = %v, %v", cfg.ChefConfig.GetNodeName(), err)
+	}
+
+	t.Setenv("CHEF_PROFILE", "prod")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got := cfg.ChefConfig.GetOrganizationURL(); got != "https://chef-prod.example.com/organizations/acme" || cfg.ChefConfig.GetNodeName() != "deployer" {
+		t.Errorf("Load() with CHEF_PROFILE=prod = %s as %s", got, cfg.ChefConfig.GetNodeName())
+	}
+
+	// The command line selection takes precedence over CHEF_PROFILE
+	SetOptions(Options{Flags: &Config{ChefConfig: &ChefConfig{Profile: StringPtr("staging")}}})
+	if _, err := Load(); err == nil {
+		t.Error("Load() with an unknown profile succeeded")
+	}
+
+	// A profile of the Chef Workstation credentials file is known too
+	writeConfig(t, filepath.Join(home, ".chef", "credentials"), "[staging]\nclient_name = \"stager\"\n")
+	if cfg, err := Load(); err != nil || cfg.ChefConfig.GetNodeName() != "me" {
+		t.Errorf("Load() with a credentials profile = %v, %v", cfg, err)
+	}
+}
@@ -3,6 +3,8 @@ package config
 import (
 	"reflect"
 	"testing"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/hooks"
 )
 
 func TestMergeConfigs(t *testing.T) {
@@ -81,6 +83,54 @@ func TestMergeConfigs(t *testing.T) {
 				Concurrency: IntPtr(10),
 			},
 		},
+		{
+			name: "overlay rate limit",
+			base: &Config{
+				RequestsPerSecond: Float64Ptr(5),
+				Burst:             IntPtr(20),
+			},
+			overlay: &Config{
+				RequestsPerSecond: Float64Ptr(0),
+			},
+			expected: &Config{
+				RequestsPerSecond: Float64Ptr(0),
+				Burst:             IntPtr(20),
+			},
+		},
+		{
+			name: "overlay metrics",
+			base: &Config{
+				Metrics: &MetricsConfig{PrometheusFile: StringPtr("/var/lib/node_exporter/berks.prom")},
+			},
+			overlay: &Config{
+				Metrics: &MetricsConfig{OTLP: BoolPtr(true)},
+			},
+			expected: &Config{
+				Metrics: &MetricsConfig{
+					PrometheusFile: StringPtr("/var/lib/node_exporter/berks.prom"),
+					OTLP:           BoolPtr(true),
+				},
+			},
+		},
+		{
+			name: "overlay signing",
+			base: &Config{
+				Signing: &SigningConfig{
+					PublicKeys: []string{"/etc/berkshelf/cosign.pub"},
+					Store:      StringPtr("https://artifactory.example.com/signatures"),
+				},
+			},
+			overlay: &Config{
+				Signing: &SigningConfig{Key: StringPtr("env:BERKS_SIGNING_KEY")},
+			},
+			expected: &Config{
+				Signing: &SigningConfig{
+					Key:        StringPtr("env:BERKS_SIGNING_KEY"),
+					PublicKeys: []string{"/etc/berkshelf/cosign.pub"},
+					Store:      StringPtr("https://artifactory.example.com/signatures"),
+				},
+			},
+		},
 		{
 			name: "overlay string slices",
 			base: &Config{
@@ -154,6 +204,152 @@ func TestMergeConfigs(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "overlay group sources per group",
+			base: &Config{
+				GroupSources: map[string]string{
+					"internal": "https://base.example.com",
+					"test":     "https://test.example.com",
+				},
+			},
+			overlay: &Config{
+				GroupSources: map[string]string{"internal": "https://overlay.example.com"},
+			},
+			expected: &Config{
+				GroupSources: map[string]string{
+					"internal": "https://overlay.example.com",
+					"test":     "https://test.example.com", // preserved from base
+				},
+			},
+		},
+		{
+			name: "overlay source routes first",
+			base: &Config{
+				SourceRoutes: []SourceRoute{{Pattern: "^acme-", Source: "https://base.example.com"}},
+			},
+			overlay: &Config{
+				SourceRoutes: []SourceRoute{{Pattern: "^acme-web", Source: "https://web.example.com"}},
+			},
+			expected: &Config{
+				SourceRoutes: []SourceRoute{
+					{Pattern: "^acme-web", Source: "https://web.example.com"},
+					{Pattern: "^acme-", Source: "https://base.example.com"},
+				},
+			},
+		},
+		{
+			name: "overlay hooks after base",
+			base: &Config{
+				Hooks: []hooks.Hook{{Event: hooks.PreUpload, Command: "./notify.sh"}},
+			},
+			overlay: &Config{
+				Hooks: []hooks.Hook{{Event: hooks.PreUpload, Plugin: "compliance"}},
+			},
+			expected: &Config{
+				Hooks: []hooks.Hook{
+					{Event: hooks.PreUpload, Command: "./notify.sh"},
+					{Event: hooks.PreUpload, Plugin: "compliance"},
+				},
+			},
+		},
+		{
+			name: "overlay owners per pattern",
+			base: &Config{
+				Owners: map[string]string{"nginx": "@web", "acme_*": "@platform"},
+			},
+			overlay: &Config{
+				Owners: map[string]string{"nginx": "@edge"},
+			},
+			expected: &Config{
+				Owners: map[string]string{"nginx": "@edge", "acme_*": "@platform"},
+			},
+		},
+		{
+			name: "overlay api keys per source",
+			base: &Config{
+				APIKeys: map[string]string{"https://a.example.com": "env:A_KEY"},
+			},
+			overlay: &Config{
+				APIKeys: map[string]string{"https://b.example.com": "keychain:b"},
+			},
+			expected: &Config{
+				APIKeys: map[string]string{
+					"https://a.example.com": "env:A_KEY",
+					"https://b.example.com": "keychain:b",
+				},
+			},
+		},
+		{
+			name: "overlay publish targets by name",
+			base: &Config{
+				PublishTargets: map[string]PublishTarget{
+					"supermarket": {Type: PublishTargetSupermarket, URL: "https://old.example.com"},
+					"chef":        {Type: PublishTargetChefServer, URL: "https://chef.example.com", Organizations: []string{"dev"}},
+				},
+			},
+			overlay: &Config{
+				PublishTargets: map[string]PublishTarget{
+					"supermarket": {Type: PublishTargetSupermarket, URL: "https://new.example.com"},
+				},
+			},
+			expected: &Config{
+				PublishTargets: map[string]PublishTarget{
+					"supermarket": {Type: PublishTargetSupermarket, URL: "https://new.example.com"},
+					"chef":        {Type: PublishTargetChefServer, URL: "https://chef.example.com", Organizations: []string{"dev"}},
+				},
+			},
+		},
+		{
+			name: "overlay license lists individually",
+			base: &Config{
+				Licenses: &LicensePolicy{Allow: []string{"Apache-2.0", "MIT"}, Deny: []string{"GPL-3.0"}},
+			},
+			overlay: &Config{
+				Licenses: &LicensePolicy{Deny: []string{"AGPL-3.0"}},
+			},
+			expected: &Config{
+				Licenses: &LicensePolicy{Allow: []string{"Apache-2.0", "MIT"}, Deny: []string{"AGPL-3.0"}},
+			},
+		},
+		{
+			name: "overlay policy rules individually",
+			base: &Config{
+				Policy: &DependencyPolicy{MaxAgeDays: IntPtr(365), Exempt: []string{"legacy_*"}},
+			},
+			overlay: &Config{
+				Policy: &DependencyPolicy{MaxAgeDays: IntPtr(180), RequireUpperBounds: BoolPtr(true)},
+			},
+			expected: &Config{
+				Policy: &DependencyPolicy{MaxAgeDays: IntPtr(180), RequireUpperBounds: BoolPtr(true), Exempt: []string{"legacy_*"}},
+			},
+		},
+		{
+			name: "overlay chef profiles by name",
+			base: &Config{
+				ChefConfig: &ChefConfig{
+					Profile: StringPtr("dev"),
+					Profiles: map[string]*ChefProfile{
+						"dev":  {ChefServerURL: StringPtr("https://chef-dev.example.com")},
+						"prod": {ChefServerURL: StringPtr("https://chef.example.com")},
+					},
+				},
+			},
+			overlay: &Config{
+				ChefConfig: &ChefConfig{
+					Profile:  StringPtr("prod"),
+					Profiles: map[string]*ChefProfile{"prod": {ChefServerURL: StringPtr("https://chef-prod.example.com")}},
+				},
+			},
+			expected: &Config{
+				ChefConfig: &ChefConfig{
+					Profile: StringPtr("prod"),
+					Profiles: map[string]*ChefProfile{
+						"dev":  {ChefServerURL: StringPtr("https://chef-dev.example.com")},
+						"prod": {ChefServerURL: StringPtr("https://chef-prod.example.com")},
+					},
+				},
+			},
+		},
 		{
 			name: "complete merge scenario",
 			base: &Config{
@@ -235,13 +431,54 @@ func configsEqual(a, b *Config) bool {
 		!intPtrEqual(a.APITimeout, b.APITimeout) ||
 		!intPtrEqual(a.RetryCount, b.RetryCount) ||
 		!intPtrEqual(a.RetryDelay, b.RetryDelay) ||
-		!intPtrEqual(a.Concurrency, b.Concurrency) {
+		!intPtrEqual(a.Concurrency, b.Concurrency) ||
+		!intPtrEqual(a.MinCheckInterval, b.MinCheckInterval) ||
+		!float64PtrEqual(a.RequestsPerSecond, b.RequestsPerSecond) ||
+		!intPtrEqual(a.Burst, b.Burst) ||
+		!intPtrEqual(a.ResolveTimeout, b.ResolveTimeout) ||
+		!intPtrEqual(a.DownloadTimeout, b.DownloadTimeout) ||
+		!intPtrEqual(a.UploadTimeout, b.UploadTimeout) ||
+		!stringPtrEqual(a.LockfileName, b.LockfileName) ||
+		!boolPtrEqual(a.RubyLockfile, b.RubyLockfile) ||
+		!stringPtrEqual(a.ChecksumAlgorithm, b.ChecksumAlgorithm) ||
+		!boolPtrEqual(a.FIPS, b.FIPS) ||
+		!boolPtrEqual(a.EvalEnv, b.EvalEnv) {
 		return false
 	}
 
 	// Compare slices
 	if !reflect.DeepEqual(a.DefaultSources, b.DefaultSources) ||
-		!reflect.DeepEqual(a.NoProxy, b.NoProxy) {
+		!reflect.DeepEqual(a.NoProxy, b.NoProxy) ||
+		!reflect.DeepEqual(a.SourceRoutes, b.SourceRoutes) ||
+		!reflect.DeepEqual(a.Hooks, b.Hooks) {
+		return false
+	}
+
+	// Compare maps
+	if len(a.GroupSources) != 0 || len(b.GroupSources) != 0 {
+		if !reflect.DeepEqual(a.GroupSources, b.GroupSources) {
+			return false
+		}
+	}
+	if len(a.APIKeys) != 0 || len(b.APIKeys) != 0 {
+		if !reflect.DeepEqual(a.APIKeys, b.APIKeys) {
+			return false
+		}
+	}
+	if len(a.PublishTargets) != 0 || len(b.PublishTargets) != 0 {
+		if !reflect.DeepEqual(a.PublishTargets, b.PublishTargets) {
+			return false
+		}
+	}
+	if len(a.Owners) != 0 || len(b.Owners) != 0 {
+		if !reflect.DeepEqual(a.Owners, b.Owners) {
+			return false
+		}
+	}
+	if !reflect.DeepEqual(a.Licenses, b.Licenses) {
+		return false
+	}
+	if !reflect.DeepEqual(a.Policy, b.Policy) {
 		return false
 	}
 
@@ -249,6 +486,12 @@ func configsEqual(a, b *Config) bool {
 	if !chefConfigEqual(a.ChefConfig, b.ChefConfig) {
 		return false
 	}
+	if !reflect.DeepEqual(a.Metrics, b.Metrics) {
+		return false
+	}
+	if !reflect.DeepEqual(a.Signing, b.Signing) {
+		return false
+	}
 
 	return true
 }
@@ -284,6 +527,16 @@ func intPtrEqual(a, b *int) bool {
 	return *a == *b
 }
 
+func float64PtrEqual(a, b *float64) bool {
+	if a == nil && b == nil {
+		return true
+	}
+	if a == nil || b == nil {
+		return false
+	}
+	return *a == *b
+}
+
 func chefConfigEqual(a, b *ChefConfig) bool {
 	if a == nil && b == nil {
 		return true
@@ -296,5 +549,7 @@ func chefConfigEqual(a, b *ChefConfig) bool {
 		stringPtrEqual(a.ClientKey, b.ClientKey) &&
 		stringPtrEqual(a.ChefServerURL, b.ChefServerURL) &&
 		stringPtrEqual(a.Organization, b.Organization) &&
-		stringPtrEqual(a.Environment, b.Environment)
+		stringPtrEqual(a.Environment, b.Environment) &&
+		stringPtrEqual(a.Profile, b.Profile) &&
+		reflect.DeepEqual(a.Profiles, b.Profiles)
 }
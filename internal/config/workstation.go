@@ -0,0 +1,326 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// workstationLayers reads Chef Workstation configuration so Chef Server
+// settings don't have to be repeated in the berkshelf config. The credentials
+// profile is read first and config.rb/knife.rb is layered over it, matching
+// the precedence knife itself uses.
+func workstationLayers() []Layer {
+	chefDir := chefConfigDir()
+	var layers []Layer
+
+	credentialsPath := filepath.Join(chefDir, "credentials")
+	if _, err := os.Stat(credentialsPath); err == nil {
+		cfg, err := loadCredentials(credentialsPath, chefProfile(chefDir))
+		if err != nil {
+			log.Debugf("Ignoring %s: %v", credentialsPath, err)
+		} else if cfg != nil {
+			layers = append(layers, Layer{Scope: ScopeWorkstation, Path: credentialsPath, Config: cfg})
+		}
+	}
+
+	for _, name := range []string{"config.rb", "knife.rb"} {
+		configPath := filepath.Join(chefDir, name)
+		if _, err := os.Stat(configPath); err != nil {
+			continue
+		}
+		cfg, err := loadConfigRb(configPath)
+		if err != nil {
+			log.Debugf("Ignoring %s: %v", configPath, err)
+		} else if cfg != nil {
+			layers = append(layers, Layer{Scope: ScopeWorkstation, Path: configPath, Config: cfg})
+		}
+		break // knife only reads the first of these it finds
+	}
+
+	return layers
+}
+
+// chefConfigDir returns ~/.chef, or $CHEF_HOME/.chef if set
+func chefConfigDir() string {
+	home := os.Getenv("CHEF_HOME")
+	if home == "" {
+		home, _ = os.UserHomeDir()
+	}
+	return filepath.Join(home, ".chef")
+}
+
+// chefProfile returns the credentials profile to use: the one selected on
+// the command line, $CHEF_PROFILE, the profile named in ~/.chef/context, or
+// "default"
+func chefProfile(chefDir string) string {
+	if loadOptions.Flags != nil {
+		if profile := loadOptions.Flags.ChefConfig.GetProfile(); profile != "" {
+			return profile
+		}
+	}
+	if profile := os.Getenv("CHEF_PROFILE"); profile != "" {
+		return profile
+	}
+	if data, err := os.ReadFile(filepath.Join(chefDir, "context")); err == nil {
+		if profile := strings.TrimSpace(string(data)); profile != "" {
+			return profile
+		}
+	}
+	return "default"
+}
+
+// credentialsProfileExists reports whether the Chef Workstation credentials
+// file defines profile
+func credentialsProfileExists(profile string) bool {
+	data, err := os.ReadFile(filepath.Join(chefConfigDir(), "credentials"))
+	if err != nil {
+		return false
+	}
+	var profiles map[string]any
+	if err := toml.Unmarshal(data, &profiles); err != nil {
+		return false
+	}
+	_, ok := profiles[profile]
+	return ok
+}
+
+// loadCredentials reads one profile of a Chef credentials file (TOML).
+// It returns nil if the profile sets nothing berks uses.
+func loadCredentials(path, profile string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var profiles map[string]map[string]any
+	if err := toml.Unmarshal(data, &profiles); err != nil {
+		return nil, fmt.Errorf("failed to parse credentials: %w", err)
+	}
+
+	settings, ok := profiles[profile]
+	if !ok {
+		return nil, fmt.Errorf("profile %q not found", profile)
+	}
+
+	values := make(map[string]string, len(settings))
+	for key, value := range settings {
+		if s, ok := value.(string); ok {
+			values[key] = s
+		}
+	}
+	return workstationConfig(values, filepath.Dir(path)), nil
+}
+
+var (
+	// fileJoinCall matches File.join(current_dir, "name") and similar
+	fileJoinCall = regexp.MustCompile(`^File\.(?:join|expand_path)\((.+)\)$`)
+	interpolated = regexp.MustCompile(`#\{\s*([^}]+?)\s*\}`)
+)
+
+// loadConfigRb extracts the settings berks uses from a config.rb or knife.rb.
+// Only literal values and the common current_dir idioms are understood;
+// anything else is skipped. It returns nil if no setting could be read.
+func loadConfigRb(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Dir(path)
+	values := make(map[string]string)
+	for line := range strings.Lines(string(data)) {
+		key, expr, ok := parseConfigRbLine(line)
+		if !ok {
+			continue
+		}
+		if value, ok := rubyValue(expr, dir); ok {
+			values[key] = value
+		}
+	}
+	return workstationConfig(values, dir), nil
+}
+
+// parseConfigRbLine splits `key value`, `key(value)` and `key = value` lines
+func parseConfigRbLine(line string) (key, expr string, ok bool) {
+	line = strings.TrimSpace(stripRubyComment(line))
+	i := strings.IndexFunc(line, func(r rune) bool { return r != '_' && (r < 'a' || r > 'z') })
+	if i <= 0 {
+		return "", "", false
+	}
+
+	key, rest := line[:i], strings.TrimSpace(line[i:])
+	switch {
+	case strings.HasPrefix(rest, "=") && !strings.HasPrefix(rest, "=="):
+		expr = rest[1:]
+	case strings.HasPrefix(rest, "(") && strings.HasSuffix(rest, ")"):
+		expr = rest[1 : len(rest)-1]
+	case line[i] == ' ' || line[i] == '\t':
+		expr = rest
+	default:
+		return "", "", false
+	}
+
+	expr = strings.TrimSpace(expr)
+	return key, expr, expr != ""
+}
+
+// stripRubyComment removes a trailing # comment that is not inside a string
+func stripRubyComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// rubyValue evaluates the small subset of Ruby expressions used for values in config.rb
+func rubyValue(expr, dir string) (string, bool) {
+	expr = strings.TrimSpace(expr)
+
+	switch {
+	case expr == "current_dir" || expr == "__dir__" || expr == "File.dirname(__FILE__)":
+		return dir, true
+	case strings.HasPrefix(expr, ":"):
+		return expr[1:], true
+	case expr == "true" || expr == "false":
+		return expr, true
+	case strings.HasPrefix(expr, "ENV["):
+		name, ok := rubyString(strings.TrimSuffix(strings.TrimPrefix(expr, "ENV["), "]"), dir)
+		if !ok {
+			return "", false
+		}
+		value, set := os.LookupEnv(name)
+		return value, set
+	}
+
+	if m := fileJoinCall.FindStringSubmatch(expr); m != nil {
+		var parts []string
+		for arg := range strings.SplitSeq(m[1], ",") {
+			part, ok := rubyValue(arg, dir)
+			if !ok {
+				return "", false
+			}
+			parts = append(parts, part)
+		}
+		if strings.HasPrefix(expr, "File.expand_path") && len(parts) == 2 {
+			// File.expand_path(name, base)
+			parts[0], parts[1] = parts[1], parts[0]
+		}
+		return filepath.Join(parts...), true
+	}
+
+	return rubyString(expr, dir)
+}
+
+// rubyString unquotes a string literal, expanding current_dir and ENV interpolation in double quotes
+func rubyString(expr, dir string) (string, bool) {
+	if len(expr) < 2 {
+		return "", false
+	}
+
+	switch {
+	case expr[0] == '\'' && expr[len(expr)-1] == '\'':
+		return expr[1 : len(expr)-1], true
+	case expr[0] == '"' && expr[len(expr)-1] == '"':
+		ok := true
+		value := interpolated.ReplaceAllStringFunc(expr[1:len(expr)-1], func(m string) string {
+			inner := interpolated.FindStringSubmatch(m)[1]
+			v, found := rubyValue(inner, dir)
+			if !found {
+				ok = false
+			}
+			return v
+		})
+		return value, ok
+	}
+	return "", false
+}
+
+// workstationConfig maps knife setting names to berks config. Relative
+// client_key paths are resolved against dir. It returns nil if nothing maps.
+func workstationConfig(values map[string]string, dir string) *Config {
+	cfg := &Config{}
+	chef := &ChefConfig{}
+	hasValues, hasChef := false, false
+
+	if v := values["chef_server_url"]; v != "" {
+		chef.ChefServerURL = StringPtr(v)
+		hasChef = true
+	}
+
+	clientName := values["client_name"]
+	if clientName == "" {
+		clientName = values["node_name"]
+	}
+	if clientName != "" {
+		chef.NodeName = StringPtr(clientName)
+		hasChef = true
+	}
+
+	// Inline keys (client_key = """-----BEGIN...""") cannot be referenced by path
+	if v := values["client_key"]; v != "" && !strings.Contains(v, "-----BEGIN") {
+		if strings.HasPrefix(v, "~/") {
+			home, _ := os.UserHomeDir()
+			v = filepath.Join(home, v[2:])
+		} else if !filepath.IsAbs(v) {
+			v = filepath.Join(dir, v)
+		}
+		chef.ClientKey = StringPtr(v)
+		hasChef = true
+	}
+
+	if v := values["environment"]; v != "" {
+		chef.Environment = StringPtr(v)
+		hasChef = true
+	}
+
+	switch values["ssl_verify_mode"] {
+	case "verify_none":
+		cfg.SSLVerify = BoolPtr(false)
+		hasValues = true
+	case "verify_peer":
+		cfg.SSLVerify = BoolPtr(true)
+		hasValues = true
+	}
+
+	if v := values["https_proxy"]; v != "" {
+		cfg.Proxy = StringPtr(v)
+		hasValues = true
+	} else if v := values["http_proxy"]; v != "" {
+		cfg.Proxy = StringPtr(v)
+		hasValues = true
+	}
+
+	if v := values["no_proxy"]; v != "" {
+		for entry := range strings.SplitSeq(v, ",") {
+			if trimmed := strings.TrimSpace(entry); trimmed != "" {
+				cfg.NoProxy = append(cfg.NoProxy, trimmed)
+			}
+		}
+		hasValues = hasValues || len(cfg.NoProxy) > 0
+	}
+
+	if hasChef {
+		cfg.ChefConfig = chef
+	}
+	if !hasValues && !hasChef {
+		return nil
+	}
+	return cfg
+}
@@ -0,0 +1,108 @@
+package config
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWorkstationLayers(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("CHEF_HOME", "")
+	t.Setenv("CHEF_PROFILE", "")
+	chefDir := filepath.Join(home, ".chef")
+
+	writeConfig(t, filepath.Join(chefDir, "credentials"), `
+[default]
+client_name = "default-user"
+client_key = "default.pem"
+chef_server_url = "https://chef.example.com/organizations/default"
+
+[staging]
+client_name = "deployer"
+client_key = "~/.chef/deployer.pem"
+chef_server_url = "https://staging.example.com/organizations/acme"
+ssl_verify_mode = "verify_none"
+`)
+	writeConfig(t, filepath.Join(chefDir, "context"), "staging\n")
+	writeConfig(t, filepath.Join(chefDir, "config.rb"), `
+current_dir = File.dirname(__FILE__)
+log_level                :info
+node_name                "rb-user" # overrides the credentials profile
+client_key               "#{current_dir}/rb-user.pem"
+https_proxy              'http://proxy.example.com:3128'
+no_proxy                 "localhost, *.internal"
+knife[:editor] = "vim"
+`)
+
+	layers := workstationLayers()
+	if len(layers) != 2 {
+		t.Fatalf("workstationLayers() returned %d layers, want 2", len(layers))
+	}
+
+	creds := layers[0].Config
+	if layers[0].Path != filepath.Join(chefDir, "credentials") {
+		t.Errorf("first layer path = %s", layers[0].Path)
+	}
+	if got := creds.ChefConfig.GetNodeName(); got != "deployer" {
+		t.Errorf("credentials client_name = %q, want deployer (profile from context file)", got)
+	}
+	if got := creds.ChefConfig.GetClientKey(); got != filepath.Join(home, ".chef", "deployer.pem") {
+		t.Errorf("credentials client_key = %q", got)
+	}
+	if creds.GetSSLVerify() {
+		t.Error("ssl_verify_mode verify_none should disable SSL verification")
+	}
+
+	merged := Layers(layers).Merge()
+	if got := merged.ChefConfig.GetNodeName(); got != "rb-user" {
+		t.Errorf("merged node_name = %q, want rb-user", got)
+	}
+	if got := merged.ChefConfig.GetClientKey(); got != filepath.Join(chefDir, "rb-user.pem") {
+		t.Errorf("merged client_key = %q", got)
+	}
+	if got := merged.ChefConfig.GetChefServerURL(); got != "https://staging.example.com/organizations/acme" {
+		t.Errorf("merged chef_server_url = %q", got)
+	}
+	if got := merged.GetProxy(); got != "http://proxy.example.com:3128" {
+		t.Errorf("merged proxy = %q", got)
+	}
+	if got := merged.GetNoProxy(); !reflect.DeepEqual(got, []string{"localhost", "*.internal"}) {
+		t.Errorf("merged no_proxy = %v", got)
+	}
+
+	// CHEF_PROFILE takes precedence over the context file
+	t.Setenv("CHEF_PROFILE", "default")
+	layers = workstationLayers()
+	if got := layers[0].Config.ChefConfig.GetNodeName(); got != "default-user" {
+		t.Errorf("CHEF_PROFILE=default client_name = %q, want default-user", got)
+	}
+	if got := layers[0].Config.ChefConfig.GetClientKey(); got != filepath.Join(chefDir, "default.pem") {
+		t.Errorf("relative client_key = %q, want it resolved against ~/.chef", got)
+	}
+}
+
+func TestParseConfigRbLine(t *testing.T) {
+	tests := []struct {
+		line string
+		key  string
+		expr string
+		ok   bool
+	}{
+		{`chef_server_url "https://chef.example.com"`, "chef_server_url", `"https://chef.example.com"`, true},
+		{`chef_server_url("https://chef.example.com")`, "chef_server_url", `"https://chef.example.com"`, true},
+		{`client_key = File.join(current_dir, "me.pem")`, "client_key", `File.join(current_dir, "me.pem")`, true},
+		{`node_name "me" # comment`, "node_name", `"me"`, true},
+		{`client_key "#{current_dir}/me.pem"`, "client_key", `"#{current_dir}/me.pem"`, true},
+		{`knife[:editor] = "vim"`, "", "", false},
+		{`# node_name "commented"`, "", "", false},
+	}
+
+	for _, tt := range tests {
+		key, expr, ok := parseConfigRbLine(tt.line)
+		if key != tt.key || expr != tt.expr || ok != tt.ok {
+			t.Errorf("parseConfigRbLine(%q) = %q, %q, %v; want %q, %q, %v", tt.line, key, expr, ok, tt.key, tt.expr, tt.ok)
+		}
+	}
+}
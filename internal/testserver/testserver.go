@@ -0,0 +1,405 @@
+// Package testserver serves a fake Supermarket, or an Artifactory Chef
+// repository, from cookbook fixtures, so that source and resolver tests run
+// offline and deterministically. It serves the endpoints the Supermarket
+// source uses: /universe, the cookbook list, cookbook and version APIs, search
+// and tarball downloads.
+package testserver
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+)
+
+//go:embed fixtures/supermarket.json
+var supermarketFixtures []byte
+
+// ArtifactoryRepository is the Chef repository an Artifactory server serves
+const ArtifactoryRepository = "chef-remote"
+
+// Flavor is the kind of server to fake
+type Flavor int
+
+const (
+	// Supermarket serves every endpoint at the root, as supermarket.chef.io
+	Supermarket Flavor = iota
+	// Artifactory serves a Chef repository below /artifactory/api/chef, with
+	// checksum headers on downloads and no search
+	Artifactory
+)
+
+// Cookbook is one version of a cookbook the server serves
+type Cookbook struct {
+	Name         string            `json:"name"`
+	Version      string            `json:"version"`
+	Description  string            `json:"description,omitempty"`
+	Maintainer   string            `json:"maintainer,omitempty"`
+	License      string            `json:"license,omitempty"`
+	PublishedAt  time.Time         `json:"published_at"`
+	Dependencies map[string]string `json:"dependencies"`
+	// Deprecated and Replacement apply to the cookbook when set on any of
+	// its versions
+	Deprecated  bool   `json:"deprecated,omitempty"`
+	Replacement string `json:"replacement,omitempty"`
+	// Files are the files of the version's tarball by relative path. A
+	// metadata.json is generated when there is no metadata.json or
+	// metadata.rb.
+	Files map[string]string `json:"files,omitempty"`
+}
+
+// Fixtures returns the recorded cookbooks: a few versions of apt, nginx and
+// their dependencies as the public Supermarket serves them
+func Fixtures() []Cookbook {
+	var fixtures struct {
+		Cookbooks []Cookbook `json:"cookbooks"`
+	}
+	if err := json.Unmarshal(supermarketFixtures, &fixtures); err != nil {
+		panic(fmt.Sprintf("testserver: invalid fixtures: %v", err))
+	}
+	return fixtures.Cookbooks
+}
+
+// Server is a fake Supermarket or Artifactory server
+type Server struct {
+	// URL is the URL to configure a Supermarket source with
+	URL string
+
+	server *httptest.Server
+	flavor Flavor
+	prefix string
+
+	mu        sync.Mutex
+	cookbooks map[string]map[string]Cookbook
+	tarballs  map[string][]byte
+	requests  []string
+}
+
+// New starts a server of flavor serving cookbooks, which is closed when the
+// test finishes
+func New(t testing.TB, flavor Flavor, cookbooks ...Cookbook) *Server {
+	t.Helper()
+	s := &Server{
+		flavor:    flavor,
+		cookbooks: make(map[string]map[string]Cookbook),
+		tarballs:  make(map[string][]byte),
+	}
+	if flavor == Artifactory {
+		s.prefix = "/artifactory/api/chef/" + ArtifactoryRepository
+	}
+	s.Add(cookbooks...)
+	s.server = httptest.NewServer(s)
+	t.Cleanup(s.server.Close)
+	s.URL = s.server.URL + s.prefix
+	return s
+}
+
+// Add serves cookbooks in addition to, or instead of, the versions served
+func (s *Server) Add(cookbooks ...Cookbook) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range cookbooks {
+		if s.cookbooks[c.Name] == nil {
+			s.cookbooks[c.Name] = make(map[string]Cookbook)
+		}
+		s.cookbooks[c.Name][c.Version] = c
+		delete(s.tarballs, c.Name+"/"+c.Version)
+	}
+}
+
+// Requests returns the requests served so far, as "METHOD /path?query" below
+// URL
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.requests)
+}
+
+// Tarball returns the tarball the server serves for a cookbook version, or
+// nil if it serves no such version
+func (s *Server) Tarball(name, version string) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.cookbooks[name][version]
+	if !ok {
+		return nil
+	}
+	return s.tarball(c)
+}
+
+// ServeHTTP serves the fake API
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path, ok := strings.CutPrefix(r.URL.Path, s.prefix)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	request := r.Method + " " + path
+	if r.URL.RawQuery != "" {
+		request += "?" + r.URL.RawQuery
+	}
+	s.requests = append(s.requests, request)
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	// The cookbook API paths are NAME, NAME/versions/VERSION and
+	// NAME/versions/VERSION/download
+	var parts []string
+	if rest, ok := strings.CutPrefix(path, "/api/v1/cookbooks/"); ok {
+		parts = strings.Split(rest, "/")
+	}
+	switch {
+	case path == "/universe":
+		s.writeJSON(w, s.universe())
+	case path == "/api/v1/cookbooks":
+		s.writeJSON(w, s.list(r))
+	case path == "/api/v1/search" && s.flavor == Supermarket:
+		s.writeJSON(w, s.search(r))
+	case len(parts) == 1:
+		if body, ok := s.cookbook(parts[0]); ok {
+			s.writeJSON(w, body)
+			return
+		}
+		http.NotFound(w, r)
+	case len(parts) == 3 && parts[1] == "versions":
+		if c, ok := s.cookbooks[parts[0]][parts[2]]; ok {
+			s.writeJSON(w, s.version(c))
+			return
+		}
+		http.NotFound(w, r)
+	case len(parts) == 4 && parts[1] == "versions" && parts[3] == "download":
+		c, ok := s.cookbooks[parts[0]][parts[2]]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		tarball := s.tarball(c)
+		if s.flavor == Artifactory {
+			sum := sha256.Sum256(tarball)
+			w.Header().Set("X-Checksum-Sha256", hex.EncodeToString(sum[:]))
+		}
+		w.Header().Set("Content-Type", "application/x-gzip")
+		http.ServeContent(w, r, c.Name+"-"+c.Version+".tar.gz", c.PublishedAt, bytes.NewReader(tarball))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// writeJSON writes v as the JSON response
+func (s *Server) writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// cookbookURL returns the API URL of a cookbook
+func (s *Server) cookbookURL(name string) string {
+	return s.URL + "/api/v1/cookbooks/" + name
+}
+
+// versionURL returns the API URL of a cookbook version
+func (s *Server) versionURL(c Cookbook) string {
+	return s.cookbookURL(c.Name) + "/versions/" + c.Version
+}
+
+// versions returns the versions of a cookbook, highest first
+func (s *Server) versions(name string) []Cookbook {
+	versions := slices.Collect(maps.Values(s.cookbooks[name]))
+	slices.SortFunc(versions, func(a, b Cookbook) int {
+		va, errA := berkshelf.NewVersion(a.Version)
+		vb, errB := berkshelf.NewVersion(b.Version)
+		if errA != nil || errB != nil {
+			return strings.Compare(b.Version, a.Version)
+		}
+		return vb.Compare(va)
+	})
+	return versions
+}
+
+// universe returns the /universe index
+func (s *Server) universe() map[string]map[string]any {
+	index := make(map[string]map[string]any, len(s.cookbooks))
+	for name, versions := range s.cookbooks {
+		index[name] = make(map[string]any, len(versions))
+		for version, c := range versions {
+			index[name][version] = map[string]any{
+				"location_type": "opscode",
+				"location_path": s.URL + "/api/v1",
+				"download_url":  s.versionURL(c) + "/download",
+				"dependencies":  dependencies(c),
+			}
+		}
+	}
+	return index
+}
+
+// listItem returns a cookbook as the cookbook list and search show it
+func (s *Server) listItem(latest Cookbook) map[string]any {
+	return map[string]any{
+		"cookbook_name":        latest.Name,
+		"cookbook_description": latest.Description,
+		"cookbook_maintainer":  latest.Maintainer,
+		"cookbook":             s.cookbookURL(latest.Name),
+		"name":                 latest.Name,
+		"description":          latest.Description,
+		"latest_version":       latest.Version,
+	}
+}
+
+// page returns the items of a list response from the start and items query
+// parameters, as Supermarket pages them
+func page(r *http.Request, items []map[string]any) map[string]any {
+	start, _ := strconv.Atoi(r.URL.Query().Get("start"))
+	count, err := strconv.Atoi(r.URL.Query().Get("items"))
+	if err != nil {
+		count = 10
+	}
+	start = min(max(start, 0), len(items))
+	end := min(start+max(count, 0), len(items))
+	return map[string]any{"start": start, "total": len(items), "items": items[start:end]}
+}
+
+// list returns the cookbook list, sorted by name
+func (s *Server) list(r *http.Request) map[string]any {
+	items := []map[string]any{}
+	for _, name := range slices.Sorted(maps.Keys(s.cookbooks)) {
+		items = append(items, s.listItem(s.versions(name)[0]))
+	}
+	return page(r, items)
+}
+
+// search returns the cookbooks whose name or description contains the q
+// query parameter
+func (s *Server) search(r *http.Request) map[string]any {
+	query := strings.ToLower(r.URL.Query().Get("q"))
+	items := []map[string]any{}
+	for _, name := range slices.Sorted(maps.Keys(s.cookbooks)) {
+		latest := s.versions(name)[0]
+		if strings.Contains(name, query) || strings.Contains(strings.ToLower(latest.Description), query) {
+			items = append(items, s.listItem(latest))
+		}
+	}
+	return page(r, items)
+}
+
+// cookbook returns the cookbook API response for name
+func (s *Server) cookbook(name string) (map[string]any, bool) {
+	versions := s.versions(name)
+	if len(versions) == 0 {
+		return nil, false
+	}
+	latest := versions[0]
+	body := map[string]any{
+		"name":           name,
+		"maintainer":     latest.Maintainer,
+		"description":    latest.Description,
+		"category":       "Other",
+		"latest_version": s.versionURL(latest),
+		"external_url":   "",
+		"source_url":     "",
+		"issues_url":     "",
+		"deprecated":     false,
+		"created_at":     versions[len(versions)-1].PublishedAt,
+		"updated_at":     latest.PublishedAt,
+	}
+	urls := make([]string, 0, len(versions))
+	for _, c := range versions {
+		urls = append(urls, s.versionURL(c))
+		if c.Deprecated {
+			body["deprecated"] = true
+			body["replacement"] = c.Replacement
+		}
+	}
+	body["versions"] = urls
+	return body, true
+}
+
+// version returns the version API response for c
+func (s *Server) version(c Cookbook) map[string]any {
+	return map[string]any{
+		"license":           c.License,
+		"tarball_file_size": len(s.tarball(c)),
+		"version":           c.Version,
+		"average_rating":    nil,
+		"cookbook":          s.cookbookURL(c.Name),
+		"file":              s.versionURL(c) + "/download",
+		"dependencies":      dependencies(c),
+		"platforms":         map[string]string{},
+		"published_at":      c.PublishedAt,
+	}
+}
+
+// dependencies returns the dependencies of c, never nil
+func dependencies(c Cookbook) map[string]string {
+	if c.Dependencies == nil {
+		return map[string]string{}
+	}
+	return c.Dependencies
+}
+
+// tarball returns the gzipped tarball of c, with its files below a
+// directory named after the cookbook. It is built once, with fixed
+// timestamps, so its checksum is stable.
+func (s *Server) tarball(c Cookbook) []byte {
+	key := c.Name + "/" + c.Version
+	if tarball, ok := s.tarballs[key]; ok {
+		return tarball
+	}
+
+	files := maps.Clone(c.Files)
+	if files == nil {
+		files = make(map[string]string)
+	}
+	_, hasJSON := files["metadata.json"]
+	_, hasRB := files["metadata.rb"]
+	if !hasJSON && !hasRB {
+		metadata, _ := json.MarshalIndent(map[string]any{
+			"name":         c.Name,
+			"version":      c.Version,
+			"description":  c.Description,
+			"maintainer":   c.Maintainer,
+			"license":      c.License,
+			"dependencies": dependencies(c),
+		}, "", "  ")
+		files["metadata.json"] = string(metadata)
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		body := files[name]
+		tw.WriteHeader(&tar.Header{
+			Name:     c.Name + "/" + name,
+			Mode:     0644,
+			Size:     int64(len(body)),
+			ModTime:  c.PublishedAt,
+			Typeflag: tar.TypeReg,
+		})
+		tw.Write([]byte(body))
+	}
+	tw.Close()
+	gz.Close()
+
+	s.tarballs[key] = buf.Bytes()
+	return s.tarballs[key]
+}
@@ -0,0 +1,113 @@
+package testserver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	"github.com/bdwyertech/go-berkshelf/pkg/fsys"
+	"github.com/bdwyertech/go-berkshelf/pkg/source"
+)
+
+func versionStrings(versions []*berkshelf.Version) []string {
+	var got []string
+	for _, v := range versions {
+		got = append(got, v.String())
+	}
+	slices.Sort(got)
+	return got
+}
+
+func TestSupermarket(t *testing.T) {
+	server := New(t, Supermarket, Fixtures()...)
+	src := source.NewSupermarketSource(server.URL)
+	src.SetDownloadDir(t.TempDir())
+	memory := fsys.Memory()
+	src.SetFS(memory)
+	ctx := context.Background()
+
+	versions, err := src.ListVersions(ctx, "apt")
+	if err != nil || !slices.Equal(versionStrings(versions), []string{"7.3.0", "7.4.0"}) {
+		t.Fatalf("ListVersions() = %v, %v, want 7.3.0 and 7.4.0", versionStrings(versions), err)
+	}
+	if _, err := src.ListVersions(ctx, "missing"); !errors.As(err, new(*source.ErrCookbookNotFound)) {
+		t.Errorf("ListVersions() of a missing cookbook error = %v", err)
+	}
+
+	v12, _ := berkshelf.NewVersion("12.0.0")
+	cookbook, err := src.FetchCookbook(ctx, "nginx", v12)
+	if err != nil {
+		t.Fatalf("FetchCookbook() error = %v", err)
+	}
+	if cookbook.Dependencies["apt"].String() != ">= 7.0.0" || cookbook.TarballURL != server.URL+"/api/v1/cookbooks/nginx/versions/12.0.0/download" {
+		t.Errorf("FetchCookbook() = %+v", cookbook)
+	}
+
+	if err := src.DownloadAndExtractCookbook(ctx, cookbook, "/vendor/nginx"); err != nil {
+		t.Fatalf("DownloadAndExtractCookbook() error = %v", err)
+	}
+	if !fsys.Exists(memory, "/vendor/nginx/metadata.json") {
+		t.Errorf("DownloadAndExtractCookbook() did not extract the generated metadata.json")
+	}
+
+	deprecation, err := src.Deprecation(ctx, "build-essential")
+	if err != nil || deprecation == nil || deprecation.Replacement != "build_essential" {
+		t.Errorf("Deprecation() = %+v, %v, want build_essential", deprecation, err)
+	}
+
+	results, err := src.Search(ctx, "nginx")
+	if err != nil || len(results) != 1 || results[0].Version.String() != "12.0.0" {
+		t.Errorf("Search() = %v, %v, want nginx 12.0.0", results, err)
+	}
+}
+
+func TestArtifactory(t *testing.T) {
+	server := New(t, Artifactory, Fixtures()...)
+	src := source.NewSupermarketSource(server.URL)
+	ctx := context.Background()
+
+	caps, err := src.ProbeCapabilities(ctx)
+	if err != nil {
+		t.Fatalf("ProbeCapabilities() error = %v", err)
+	}
+	want := source.Capabilities{CookbooksAPI: true, Universe: true, Checksums: true, RangeRequests: true}
+	if caps != want {
+		t.Errorf("ProbeCapabilities() = %+v, want %+v", caps, want)
+	}
+
+	v740, _ := berkshelf.NewVersion("7.4.0")
+	cookbook, err := src.FetchCookbook(ctx, "apt", v740)
+	if err != nil {
+		t.Fatalf("FetchCookbook() error = %v", err)
+	}
+	sum := sha256.Sum256(server.Tarball("apt", "7.4.0"))
+	if got, err := src.Checksum(ctx, cookbook); err != nil || got != hex.EncodeToString(sum[:]) {
+		t.Errorf("Checksum() = %s, %v, want %x", got, err, sum)
+	}
+}
+
+func TestServer_Tarball(t *testing.T) {
+	first := New(t, Supermarket, Fixtures()...).Tarball("apt", "7.4.0")
+	second := New(t, Supermarket, Fixtures()...).Tarball("apt", "7.4.0")
+	if first == nil || !slices.Equal(first, second) {
+		t.Errorf("Tarball() is not reproducible")
+	}
+	if New(t, Supermarket).Tarball("apt", "7.4.0") != nil {
+		t.Errorf("Tarball() of a version not served is not nil")
+	}
+}
+
+func TestServer_Requests(t *testing.T) {
+	server := New(t, Artifactory, Fixtures()...)
+	src := source.NewSupermarketSource(server.URL)
+	if _, err := src.ListVersions(context.Background(), "ohai"); err != nil {
+		t.Fatalf("ListVersions() error = %v", err)
+	}
+	if got := server.Requests(); !slices.Equal(got, []string{"GET /api/v1/cookbooks/ohai"}) {
+		t.Errorf("Requests() = %v", got)
+	}
+}
@@ -0,0 +1,139 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+)
+
+// Gem is a gem dependency declared in cookbook metadata
+type Gem struct {
+	Name         string
+	Requirements []string
+}
+
+// String returns the gem and its requirements, e.g. "nokogiri (>= 1.10)"
+func (g Gem) String() string {
+	if len(g.Requirements) == 0 {
+		return g.Name
+	}
+	return fmt.Sprintf("%s (%s)", g.Name, strings.Join(g.Requirements, ", "))
+}
+
+// Advisory is a security advisory for a gem, in the layout of the Ruby
+// Advisory Database: a version is vulnerable unless it matches one of the
+// patched or unaffected requirements.
+type Advisory struct {
+	ID                 string   `json:"id"`
+	Gem                string   `json:"gem"`
+	Title              string   `json:"title"`
+	URL                string   `json:"url,omitempty"`
+	Severity           Severity `json:"severity"`
+	PatchedVersions    []string `json:"patched_versions"`
+	UnaffectedVersions []string `json:"unaffected_versions"`
+}
+
+// Affects reports whether gem's requirements allow a vulnerable version.
+// Chef installs the newest gem matching the requirements, so only the lowest
+// allowed version is certain to be installable; a requirement is flagged
+// when that version is vulnerable.
+func (a Advisory) Affects(gem Gem) bool {
+	if !strings.EqualFold(a.Gem, gem.Name) {
+		return false
+	}
+	floor := lowestAllowed(gem.Requirements)
+	for _, safe := range append(a.PatchedVersions, a.UnaffectedVersions...) {
+		constraint, err := berkshelf.NewConstraint(safe)
+		if err != nil {
+			continue
+		}
+		if constraint.Check(floor) {
+			return false
+		}
+	}
+	return true
+}
+
+// lowestAllowed returns the highest lower bound among RubyGems requirements,
+// or 0.0.0 when they have none
+func lowestAllowed(requirements []string) *berkshelf.Version {
+	floor := berkshelf.MustVersion("0.0.0")
+	for _, requirement := range requirements {
+		for part := range strings.SplitSeq(requirement, ",") {
+			fields := strings.Fields(part)
+			var op, raw string
+			switch len(fields) {
+			case 1:
+				op, raw = "=", fields[0]
+			case 2:
+				op, raw = fields[0], fields[1]
+			default:
+				continue
+			}
+			switch op {
+			case "=", ">=", ">", "~>":
+			default:
+				continue
+			}
+			if v, err := berkshelf.NewVersion(raw); err == nil && v.GreaterThan(floor) {
+				floor = v
+			}
+		}
+	}
+	return floor
+}
+
+// feed is the advisory feed document
+type feed struct {
+	Advisories []Advisory `json:"advisories"`
+}
+
+// LoadAdvisories reads an advisory feed from a file path or an http(s) URL.
+// Advisories without a severity are treated as high.
+func LoadAdvisories(ctx context.Context, location string) ([]Advisory, error) {
+	var data []byte
+	var err error
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		data, err = fetchFeed(ctx, location)
+	} else {
+		data, err = os.ReadFile(location)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read advisories from %s: %w", location, err)
+	}
+
+	var parsed feed
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse advisories from %s: %w", location, err)
+	}
+	for i := range parsed.Advisories {
+		if parsed.Advisories[i].Severity == 0 {
+			parsed.Advisories[i].Severity = SeverityHigh
+		}
+	}
+	return parsed.Advisories, nil
+}
+
+func fetchFeed(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
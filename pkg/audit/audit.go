@@ -0,0 +1,278 @@
+// Package audit checks the cookbooks in a lock file for deprecations, yanked
+// versions, abandoned cookbooks and known-vulnerable gem dependencies.
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	"github.com/bdwyertech/go-berkshelf/pkg/lockfile"
+	"github.com/bdwyertech/go-berkshelf/pkg/logging"
+	"github.com/bdwyertech/go-berkshelf/pkg/metadata"
+	"github.com/bdwyertech/go-berkshelf/pkg/source"
+)
+
+var log = logging.For("audit")
+
+// Finding kinds
+const (
+	KindDeprecated   = "deprecated"
+	KindYanked       = "yanked"
+	KindUnmaintained = "unmaintained"
+	KindAdvisory     = "advisory"
+)
+
+// Finding is one problem with a locked cookbook
+type Finding struct {
+	Cookbook string   `json:"cookbook"`
+	Version  string   `json:"version"`
+	Kind     string   `json:"kind"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+	// Advisory is the advisory ID for gem advisories
+	Advisory string `json:"advisory,omitempty"`
+	// URL links to more information, when known
+	URL string `json:"url,omitempty"`
+	// Owners are the teams that own the cookbook, when configured
+	Owners []string `json:"owners,omitempty"`
+}
+
+// Options configures an audit
+type Options struct {
+	// MaxAge flags cookbooks whose latest release is older; zero disables the check
+	MaxAge time.Duration
+	// Advisories are checked against the gem dependencies of each cookbook.
+	// Cookbooks are only downloaded when there are advisories to check.
+	Advisories []Advisory
+	// Now is the time ages are measured from; zero means time.Now()
+	Now time.Time
+}
+
+// Auditor audits the cookbooks in a lock file
+type Auditor struct {
+	lockFile      *lockfile.LockFile
+	sourceManager *source.Manager
+	options       Options
+}
+
+// New creates an Auditor
+func New(lockFile *lockfile.LockFile, sourceManager *source.Manager, options Options) *Auditor {
+	if options.Now.IsZero() {
+		options.Now = time.Now()
+	}
+	return &Auditor{lockFile: lockFile, sourceManager: sourceManager, options: options}
+}
+
+// lockedCookbook is a cookbook from the lock file with its source
+type lockedCookbook struct {
+	name       string
+	version    string
+	sourceType string
+	sourceURL  string
+}
+
+// Audit checks each locked cookbook, or only those in cookbookNames, and
+// returns the findings sorted by severity, most severe first, then by cookbook.
+// Lookups that fail are logged and skipped rather than failing the audit.
+func (a *Auditor) Audit(ctx context.Context, cookbookNames []string) ([]Finding, error) {
+	var findings []Finding
+	for _, cookbook := range a.locked(cookbookNames) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		findings = append(findings, a.auditCookbook(ctx, cookbook)...)
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Severity != findings[j].Severity {
+			return findings[i].Severity > findings[j].Severity
+		}
+		if findings[i].Cookbook != findings[j].Cookbook {
+			return findings[i].Cookbook < findings[j].Cookbook
+		}
+		return findings[i].Kind < findings[j].Kind
+	})
+	return findings, nil
+}
+
+// locked returns the cookbooks to audit, sorted by name
+func (a *Auditor) locked(cookbookNames []string) []lockedCookbook {
+	var cookbooks []lockedCookbook
+	for key, src := range a.lockFile.Sources {
+		for name, cookbook := range src.Cookbooks {
+			if len(cookbookNames) > 0 && !slices.Contains(cookbookNames, name) {
+				continue
+			}
+			locked := lockedCookbook{name: name, version: cookbook.Version, sourceType: src.Type, sourceURL: src.URL}
+			if cookbook.Source != nil && cookbook.Source.Type != "" {
+				locked.sourceType = cookbook.Source.Type
+			}
+			if locked.sourceURL == "" {
+				locked.sourceURL = key
+			}
+			cookbooks = append(cookbooks, locked)
+		}
+	}
+	sort.Slice(cookbooks, func(i, j int) bool { return cookbooks[i].name < cookbooks[j].name })
+	return cookbooks
+}
+
+// auditCookbook runs every check against one cookbook
+func (a *Auditor) auditCookbook(ctx context.Context, cookbook lockedCookbook) []Finding {
+	logger := log.WithField(logging.CookbookField, cookbook.name)
+	finding := func(kind string, severity Severity, format string, args ...any) Finding {
+		return Finding{
+			Cookbook: cookbook.name,
+			Version:  cookbook.version,
+			Kind:     kind,
+			Severity: severity,
+			Message:  fmt.Sprintf(format, args...),
+		}
+	}
+
+	var findings []Finding
+	src := a.sourceFor(cookbook)
+	remote := src != nil && (cookbook.sourceType == "supermarket" || cookbook.sourceType == "chef_server")
+
+	if deprecations, ok := source.As[source.DeprecationSource](src); ok && remote {
+		deprecation, err := deprecations.Deprecation(ctx, cookbook.name)
+		switch {
+		case err != nil:
+			logger.Debugf("Failed to check deprecation of %s: %v", cookbook.name, err)
+		case deprecation != nil && deprecation.Replacement != "":
+			findings = append(findings, finding(KindDeprecated, SeverityMedium, "deprecated in favor of %s", deprecation.Replacement))
+		case deprecation != nil:
+			findings = append(findings, finding(KindDeprecated, SeverityMedium, "deprecated with no declared replacement"))
+		}
+	}
+
+	yanked := ""
+	if remote {
+		var err error
+		if yanked, err = a.yanked(ctx, src, cookbook); err != nil {
+			logger.Debugf("Failed to list versions of %s: %v", cookbook.name, err)
+		} else if yanked != "" {
+			findings = append(findings, finding(KindYanked, SeverityHigh, "%s", yanked))
+		}
+	}
+
+	if releases, ok := source.As[source.ReleaseSource](src); ok && remote && a.options.MaxAge > 0 {
+		released, err := releases.LastReleased(ctx, cookbook.name)
+		switch {
+		case err != nil:
+			logger.Debugf("Failed to check the last release of %s: %v", cookbook.name, err)
+		case a.options.Now.Sub(released) > a.options.MaxAge:
+			findings = append(findings, finding(KindUnmaintained, SeverityLow, "no release since %s", released.Format("2006-01-02")))
+		}
+	}
+
+	// A yanked version cannot be downloaded to read its gem dependencies
+	if len(a.options.Advisories) > 0 && src != nil && yanked == "" {
+		gems, err := a.gems(ctx, src, cookbook)
+		if err != nil {
+			logger.Warnf("Skipping gem advisories for %s: %v", cookbook.name, err)
+		}
+		for _, gem := range gems {
+			for _, advisory := range a.options.Advisories {
+				if !advisory.Affects(gem) {
+					continue
+				}
+				f := finding(KindAdvisory, advisory.Severity, "gem %s: %s", gem, advisory.Title)
+				f.Advisory = advisory.ID
+				f.URL = advisory.URL
+				findings = append(findings, f)
+			}
+		}
+	}
+
+	return findings
+}
+
+// sourceFor returns the source the cookbook was locked from, or nil if the
+// Berksfile no longer declares it. Checking another source could report a
+// version as yanked when it was never published there.
+func (a *Auditor) sourceFor(cookbook lockedCookbook) source.CookbookSource {
+	for _, src := range a.sourceManager.GetSources() {
+		if strings.TrimSuffix(src.GetSourceURL(), "/") == strings.TrimSuffix(cookbook.sourceURL, "/") {
+			return src
+		}
+	}
+	return nil
+}
+
+// yanked describes why the locked version can no longer be installed, or
+// returns "" if its source still lists it
+func (a *Auditor) yanked(ctx context.Context, src source.CookbookSource, cookbook lockedCookbook) (string, error) {
+	versions, err := src.ListVersions(ctx, cookbook.name)
+	var notFound *source.ErrCookbookNotFound
+	if errors.As(err, &notFound) {
+		return fmt.Sprintf("cookbook was removed from %s", src.GetSourceURL()), nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	locked, err := berkshelf.NewVersion(cookbook.version)
+	if err != nil {
+		return "", err
+	}
+	for _, v := range versions {
+		if v.Equal(locked) {
+			return "", nil
+		}
+	}
+	return fmt.Sprintf("version %s was yanked from %s", cookbook.version, src.GetSourceURL()), nil
+}
+
+// gems returns the gem dependencies declared in the cookbook's metadata
+func (a *Auditor) gems(ctx context.Context, src source.CookbookSource, cookbook lockedCookbook) ([]Gem, error) {
+	version, err := berkshelf.NewVersion(cookbook.version)
+	if err != nil {
+		return nil, err
+	}
+	fetched, err := src.FetchCookbook(ctx, cookbook.name, version)
+	if err != nil {
+		return nil, err
+	}
+
+	staging, err := os.MkdirTemp("", "berks-audit-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(staging)
+
+	dir := filepath.Join(staging, cookbook.name)
+	if err := src.DownloadAndExtractCookbook(ctx, fetched, dir); err != nil {
+		return nil, err
+	}
+
+	var md *metadata.Metadata
+	if data, err := os.ReadFile(filepath.Join(dir, "metadata.json")); err == nil {
+		md, err = metadata.ParseJSON(data)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		md, err = metadata.ParseFile(filepath.Join(dir, "metadata.rb"))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	gems := make([]Gem, 0, len(md.Gems))
+	for _, declaration := range md.Gems {
+		if len(declaration) == 0 {
+			continue
+		}
+		gems = append(gems, Gem{Name: declaration[0], Requirements: declaration[1:]})
+	}
+	return gems, nil
+}
@@ -0,0 +1,206 @@
+package audit
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/lockfile"
+	"github.com/bdwyertech/go-berkshelf/pkg/source"
+)
+
+// fakeCookbook is a cookbook served by newSupermarket
+type fakeCookbook struct {
+	versions    []string
+	deprecated  string // replacement; "-" for none
+	publishedAt string
+	gems        string // metadata.json gems value
+}
+
+func tarball(t *testing.T, name, metadataJSON string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	if err := tw.WriteHeader(&tar.Header{Name: name + "/metadata.json", Mode: 0644, Size: int64(len(metadataJSON)), Typeflag: tar.TypeReg}); err != nil {
+		t.Fatal(err)
+	}
+	tw.Write([]byte(metadataJSON))
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+func newSupermarket(t *testing.T, cookbooks map[string]fakeCookbook) *httptest.Server {
+	t.Helper()
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/"), "/")
+		if len(parts) < 3 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		name := parts[1] // /download/NAME/VERSION
+		if parts[0] == "api" {
+			name = parts[3] // /api/v1/cookbooks/NAME[/versions/VERSION]
+		}
+		cookbook, ok := cookbooks[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		api := server.URL + "/api/v1/cookbooks/" + name
+
+		switch {
+		case parts[0] == "download":
+			version := parts[2]
+			w.Write(tarball(t, name, fmt.Sprintf(`{"name": %q, "version": %q, "gems": %s}`, name, version, cookbook.gems)))
+		case len(parts) == 4:
+			var urls []string
+			for _, v := range cookbook.versions {
+				urls = append(urls, fmt.Sprintf("%q", api+"/versions/"+v))
+			}
+			replacement := ""
+			if cookbook.deprecated != "" && cookbook.deprecated != "-" {
+				replacement = server.URL + "/api/v1/cookbooks/" + cookbook.deprecated
+			}
+			fmt.Fprintf(w, `{"name": %q, "deprecated": %v, "replacement": %q, "latest_version": %q, "versions": [%s]}`,
+				name, cookbook.deprecated != "", replacement, api+"/versions/"+cookbook.versions[0], strings.Join(urls, ","))
+		case len(parts) == 6:
+			version := parts[5]
+			fmt.Fprintf(w, `{"version": %q, "published_at": %q, "file": "%s/download/%s/%s"}`, version, cookbook.publishedAt, server.URL, name, version)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func lockFor(url string, cookbooks map[string]string) *lockfile.LockFile {
+	lf := lockfile.NewLockFile()
+	src := &lockfile.SourceLock{Type: "supermarket", URL: url, Cookbooks: map[string]*lockfile.CookbookLock{}}
+	for name, version := range cookbooks {
+		src.Cookbooks[name] = &lockfile.CookbookLock{Version: version}
+	}
+	lf.Sources[url] = src
+	return lf
+}
+
+func TestAudit(t *testing.T) {
+	recent := "2026-01-01T00:00:00.000Z"
+	server := newSupermarket(t, map[string]fakeCookbook{
+		"apt":       {versions: []string{"7.4.0"}, publishedAt: recent, gems: "[]"},
+		"yum":       {versions: []string{"7.0.0"}, deprecated: "yum-core", publishedAt: recent, gems: "[]"},
+		"nginx":     {versions: []string{"2.0.0"}, publishedAt: recent, gems: "[]"},
+		"abandoned": {versions: []string{"1.0.0"}, publishedAt: "2015-03-01T00:00:00.000Z", gems: "[]"},
+		"xml":       {versions: []string{"3.0.0"}, publishedAt: recent, gems: `[["nokogiri", ">= 1.10"], ["builder", "~> 3.2"]]`},
+	})
+
+	lf := lockFor(server.URL, map[string]string{
+		"apt": "7.4.0", "yum": "7.0.0", "nginx": "1.0.0", "abandoned": "1.0.0", "xml": "3.0.0", "gone": "1.0.0",
+	})
+	manager := source.NewManager()
+	manager.AddSource(source.NewSupermarketSource(server.URL))
+
+	auditor := New(lf, manager, Options{
+		MaxAge: 3 * 365 * 24 * time.Hour,
+		Now:    time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC),
+		Advisories: []Advisory{{
+			ID: "CVE-2022-24836", Gem: "nokogiri", Title: "ReDoS in HTML encoding detection",
+			Severity: SeverityCritical, PatchedVersions: []string{">= 1.13.4"},
+		}},
+	})
+	findings, err := auditor.Audit(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("Audit() error = %v", err)
+	}
+
+	var got []string
+	for _, f := range findings {
+		got = append(got, fmt.Sprintf("%s %s %s", f.Severity, f.Cookbook, f.Kind))
+	}
+	want := []string{
+		"critical xml advisory",
+		"high gone yanked",
+		"high nginx yanked",
+		"medium yum deprecated",
+		"low abandoned unmaintained",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Audit() findings =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if findings[0].Advisory != "CVE-2022-24836" || !strings.Contains(findings[0].Message, "nokogiri (>= 1.10)") {
+		t.Errorf("advisory finding = %+v", findings[0])
+	}
+	if findings[3].Message != "deprecated in favor of yum-core" {
+		t.Errorf("deprecation message = %q", findings[3].Message)
+	}
+}
+
+func TestAdvisory_Affects(t *testing.T) {
+	advisory := Advisory{Gem: "nokogiri", PatchedVersions: []string{">= 1.13.4"}, UnaffectedVersions: []string{"< 1.5.0"}}
+	tests := []struct {
+		requirements []string
+		want         bool
+	}{
+		{nil, false}, // lowest allowed is 0.0.0, which is unaffected
+		{[]string{">= 1.10"}, true},
+		{[]string{"~> 1.13.4"}, false},
+		{[]string{">= 1.8", "< 2.0"}, true},
+		{[]string{"= 1.13.10"}, false},
+		{[]string{"1.12.0"}, true},
+	}
+	for _, tt := range tests {
+		gem := Gem{Name: "nokogiri", Requirements: tt.requirements}
+		if got := advisory.Affects(gem); got != tt.want {
+			t.Errorf("Affects(%s) = %v, want %v", gem, got, tt.want)
+		}
+	}
+	if advisory.Affects(Gem{Name: "builder", Requirements: []string{">= 1.10"}}) {
+		t.Error("Affects() matched a different gem")
+	}
+}
+
+func TestLoadAdvisories(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "advisories.json")
+	feed := `{"advisories": [
+		{"id": "CVE-1", "gem": "rack", "severity": "moderate", "patched_versions": [">= 2.2.6"]},
+		{"id": "CVE-2", "gem": "rexml"}
+	]}`
+	if err := os.WriteFile(path, []byte(feed), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	advisories, err := LoadAdvisories(context.Background(), path)
+	if err != nil {
+		t.Fatalf("LoadAdvisories() error = %v", err)
+	}
+	if len(advisories) != 2 || advisories[0].Severity != SeverityMedium || advisories[1].Severity != SeverityHigh {
+		t.Errorf("LoadAdvisories() = %+v", advisories)
+	}
+}
+
+func TestSeverity(t *testing.T) {
+	for _, name := range []string{"low", "medium", "high", "critical"} {
+		severity, err := ParseSeverity(strings.ToUpper(name))
+		if err != nil || severity.String() != name {
+			t.Errorf("ParseSeverity(%q) = %v, %v", name, severity, err)
+		}
+	}
+	if _, err := ParseSeverity("severe"); err == nil {
+		t.Error("ParseSeverity() accepted an unknown severity")
+	}
+	if SeverityLow.ExitCode() != 2 || SeverityCritical.ExitCode() != 5 {
+		t.Errorf("ExitCode() = %d..%d, want 2..5", SeverityLow.ExitCode(), SeverityCritical.ExitCode())
+	}
+}
@@ -0,0 +1,72 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Severity ranks findings. The zero value is below every severity.
+type Severity int
+
+const (
+	SeverityLow Severity = iota + 1
+	SeverityMedium
+	SeverityHigh
+	SeverityCritical
+)
+
+var severityNames = map[Severity]string{
+	SeverityLow:      "low",
+	SeverityMedium:   "medium",
+	SeverityHigh:     "high",
+	SeverityCritical: "critical",
+}
+
+// ParseSeverity parses "low", "medium", "high" or "critical", case-insensitively.
+// "moderate" is accepted for medium, as advisory databases use it.
+func ParseSeverity(s string) (Severity, error) {
+	normalized := strings.ToLower(strings.TrimSpace(s))
+	if normalized == "moderate" {
+		return SeverityMedium, nil
+	}
+	for severity, name := range severityNames {
+		if name == normalized {
+			return severity, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown severity %q (expected low, medium, high or critical)", s)
+}
+
+// String returns the severity name
+func (s Severity) String() string {
+	if name, ok := severityNames[s]; ok {
+		return name
+	}
+	return "none"
+}
+
+// ExitCode is the process exit code for an audit whose worst finding has
+// this severity: 2 for low up to 5 for critical, leaving 1 for other errors
+func (s Severity) ExitCode() int {
+	return int(s) + 1
+}
+
+// MarshalJSON encodes the severity as its name
+func (s Severity) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// UnmarshalJSON decodes a severity name
+func (s *Severity) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		return err
+	}
+	parsed, err := ParseSeverity(name)
+	if err != nil {
+		return err
+	}
+	*s = parsed
+	return nil
+}
@@ -0,0 +1,320 @@
+// Package berks is the library interface to go-berkshelf. A Client works on
+// the Berksfile in one directory as the berks commands do, so other Go tools
+// can install, vendor, check and upload cookbooks without shelling out.
+package berks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/bdwyertech/go-berkshelf/internal/config"
+	"github.com/bdwyertech/go-berkshelf/pkg/berksfile"
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	"github.com/bdwyertech/go-berkshelf/pkg/cache"
+	berrors "github.com/bdwyertech/go-berkshelf/pkg/errors"
+	"github.com/bdwyertech/go-berkshelf/pkg/events"
+	"github.com/bdwyertech/go-berkshelf/pkg/hooks"
+	"github.com/bdwyertech/go-berkshelf/pkg/lockfile"
+	"github.com/bdwyertech/go-berkshelf/pkg/logging"
+	"github.com/bdwyertech/go-berkshelf/pkg/resolver"
+	"github.com/bdwyertech/go-berkshelf/pkg/source"
+)
+
+var log = logging.For("berks")
+
+// Options configures a Client. The zero value resolves without API keys,
+// group sources or caching.
+type Options struct {
+	// APIKeys maps Supermarket URLs to API keys or credential references
+	APIKeys map[string]string
+	// GroupSources maps Berksfile groups to the default source of their cookbooks
+	GroupSources map[string]string
+	// SourceRoutes send cookbooks to sources by name; the first match wins
+	SourceRoutes []SourceRoute
+	// RateLimit limits the requests sent to each Supermarket host; the zero
+	// value is no limit
+	RateLimit source.RateLimit
+	// CacheDir holds the version, capability and response caches; empty disables them
+	CacheDir string
+	// MinCheckInterval is how long versions cached in CacheDir are used
+	// before a source is asked again; 0 always asks
+	MinCheckInterval time.Duration
+	// ResolveTimeout, DownloadTimeout and UploadTimeout limit each phase of
+	// a call; 0 is no limit. A phase that runs out fails with an
+	// errors.PhaseTimeoutError.
+	ResolveTimeout  time.Duration
+	DownloadTimeout time.Duration
+	UploadTimeout   time.Duration
+	// KeepGoing completes every other cookbook after one fails to resolve or
+	// download, rather than stopping at the first. Either way the call fails
+	// with an errors.PartialFailure.
+	KeepGoing bool
+	// ChefVersion, when set, skips cookbook versions whose chef_version excludes it
+	ChefVersion *berkshelf.Version
+	// Events receives resolution progress; it may be nil
+	Events events.Handler
+	// Hooks runs the lifecycle hooks around resolving, vendoring and
+	// uploading; it may be nil. A failing hook fails the call.
+	Hooks *hooks.Runner
+	// LockFile names the lock files; the zero value writes Berksfile.go.lock
+	// and Berksfile.lock
+	LockFile lockfile.Options
+}
+
+// SourceRoute resolves the cookbooks whose names match Pattern from the
+// source at URL instead of the Berksfile's default sources
+type SourceRoute struct {
+	Pattern *regexp.Regexp
+	URL     string
+}
+
+// DefaultOptions returns the options the berks commands use, read from the
+// berkshelf config
+func DefaultOptions() (Options, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return Options{}, fmt.Errorf("failed to load config: %w", err)
+	}
+	var routes []SourceRoute
+	for _, route := range cfg.GetSourceRoutes() {
+		pattern, err := regexp.Compile(route.Pattern)
+		if err != nil {
+			return Options{}, fmt.Errorf("invalid source route pattern %q: %w", route.Pattern, err)
+		}
+		routes = append(routes, SourceRoute{Pattern: pattern, URL: route.Source})
+	}
+	return Options{
+		APIKeys:          cfg.GetAPIKeys(),
+		GroupSources:     cfg.GetGroupSources(),
+		SourceRoutes:     routes,
+		RateLimit:        cfg.GetRateLimit(),
+		CacheDir:         filepath.Join(config.GetConfigDir(), "resolutions"),
+		MinCheckInterval: time.Duration(cfg.GetMinCheckInterval()) * time.Second,
+		ResolveTimeout:   time.Duration(cfg.GetResolveTimeout()) * time.Second,
+		DownloadTimeout:  time.Duration(cfg.GetDownloadTimeout()) * time.Second,
+		UploadTimeout:    time.Duration(cfg.GetUploadTimeout()) * time.Second,
+		LockFile:         lockfile.Options{Name: cfg.GetLockfileName(), SkipRuby: !cfg.GetRubyLockfile()},
+		Hooks:            hooks.NewRunner(cfg.GetHooks()),
+	}, nil
+}
+
+// Client works on the Berksfile and lock files in one directory
+type Client struct {
+	dir     string
+	options Options
+}
+
+// New returns a client for the Berksfile in dir. The Berksfile is read by
+// each call, so a long-lived client sees later edits.
+func New(dir string, options Options) (*Client, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{dir: abs, options: options}, nil
+}
+
+// lockManager returns the manager of the lock files in the client directory
+func (c *Client) lockManager() *lockfile.Manager {
+	return lockfile.NewManagerWithOptions(c.dir, c.options.LockFile)
+}
+
+// Dir returns the directory holding the Berksfile
+func (c *Client) Dir() string {
+	return c.dir
+}
+
+// berksfile parses the Berksfile. The parser is not safe for concurrent
+// use, so clients must not load Berksfiles from several goroutines at once.
+func (c *Client) berksfile() (*berksfile.Berksfile, error) {
+	path := filepath.Join(c.dir, "Berksfile")
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("no Berksfile found in %s", c.dir)
+	}
+	bf, err := berksfile.Load(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Berksfile: %w", err)
+	}
+	return bf, nil
+}
+
+// lockFile loads the lock file, with path sources made absolute so they can
+// be read from any working directory
+func (c *Client) lockFile() (*lockfile.LockFile, error) {
+	manager := c.lockManager()
+	if !manager.Exists() {
+		return nil, fmt.Errorf("no lock file found in %s; install first", c.dir)
+	}
+	lockFile, err := manager.Load()
+	if err != nil {
+		return nil, err
+	}
+	for _, cookbook := range lockFile.ListCookbooks() {
+		if cookbook.Source != nil && cookbook.Source.Type == "path" && !filepath.IsAbs(cookbook.Source.Path) {
+			cookbook.Source.Path = filepath.Join(c.dir, cookbook.Source.Path)
+		}
+	}
+	return lockFile, nil
+}
+
+// sourceFactory returns a factory using the client's API keys, rate limit,
+// and capability and response caches
+func (c *Client) sourceFactory() *source.Factory {
+	factory := source.NewFactory()
+	factory.SetAPIKeys(c.options.APIKeys)
+	factory.SetRateLimit(c.options.RateLimit)
+	if c.options.CacheDir != "" {
+		if capabilities, err := cache.NewCapabilityCache(c.options.CacheDir, cache.DefaultCapabilityTTL); err == nil {
+			factory.SetCapabilityStore(capabilities)
+		} else {
+			log.Debugf("Capability cache disabled: %v", err)
+		}
+		if responses, err := cache.NewResponseCache(c.options.CacheDir, cache.DefaultResponseTTL); err == nil {
+			factory.SetResponseStore(responses)
+		} else {
+			log.Debugf("Response cache disabled: %v", err)
+		}
+	}
+	return factory
+}
+
+// sourceManager returns the default sources of the Berksfile
+func (c *Client) sourceManager(bf *berksfile.Berksfile) (*source.Manager, error) {
+	manager, err := c.sourceFactory().CreateFromBerksfile(bf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create source manager: %w", err)
+	}
+	return manager, nil
+}
+
+// resolve resolves the cookbooks of the Berksfile in the selected groups and
+// returns the lock file, without writing it
+func (c *Client) resolve(ctx context.Context, bf *berksfile.Berksfile, only, except []string) (*lockfile.LockFile, error) {
+	cookbooks := berksfile.FilterCookbooksByGroup(bf.Cookbooks, only, except)
+	cookbooks = berksfile.ApplyGroupSources(cookbooks, c.options.GroupSources)
+
+	// The metadata directive adds the cookbook in dir and its dependencies
+	metadataCookbooks, err := bf.MetadataCookbooks(c.dir)
+	if err != nil {
+		return nil, err
+	}
+	cookbooks = slices.Concat(cookbooks, metadataCookbooks)
+
+	requirements := make([]*resolver.Requirement, 0, len(cookbooks))
+	for _, cookbook := range cookbooks {
+		req := resolver.NewRequirement(cookbook.Name, cookbook.Constraint)
+		if loc := cookbook.Source; loc != nil && loc.Type != "" && (loc.URL != "" || loc.Path != "") {
+			req.Source = c.absPath(loc)
+		}
+		req.MetadataName = cookbook.MetadataName
+		requirements = append(requirements, req)
+	}
+
+	manager, err := c.sourceManager(bf)
+	if err != nil {
+		return nil, err
+	}
+	sources := manager.GetSources()
+	if c.options.CacheDir != "" && c.options.MinCheckInterval > 0 {
+		if versions, err := cache.NewVersionCache(c.options.CacheDir, c.options.MinCheckInterval); err == nil {
+			sources = versions.WrapAll(sources)
+		} else {
+			log.Debugf("Version cache disabled: %v", err)
+		}
+	}
+
+	// Overrides in the lock file and then the Berksfile pin cookbooks, as in
+	// 'berks install'
+	lockManager := c.lockManager()
+	var overrides []*resolver.Requirement
+	if existing, err := lockManager.Load(); err == nil {
+		if overrides, err = existing.OverrideRequirements(); err != nil {
+			return nil, fmt.Errorf("invalid override in %s: %w", lockManager.GetPath(), err)
+		}
+	}
+	for _, override := range bf.Overrides {
+		req := resolver.NewRequirement(override.Name, override.Constraint)
+		if loc := override.Source; loc != nil && loc.Type != "" && (loc.URL != "" || loc.Path != "") {
+			req.Source = loc
+		}
+		overrides = append(overrides, req)
+	}
+	for _, req := range overrides {
+		if req.Source != nil {
+			req.Source = c.absPath(req.Source)
+		}
+	}
+
+	r := resolver.NewResolver(sources)
+	r.SetKeepGoing(c.options.KeepGoing)
+	r.SetEventHandler(c.options.Events)
+	r.SetChefVersion(c.options.ChefVersion)
+	r.Override(overrides...)
+	r.Ignore(bf.Ignored...)
+	for _, route := range c.options.SourceRoutes {
+		src, err := c.sourceFactory().CreateFromURL(route.URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid source route to %s: %w", route.URL, err)
+		}
+		r.Route(resolver.SourceRoute{Pattern: route.Pattern, Source: src})
+	}
+	if err := c.runHooks(ctx, hooks.Payload{Event: hooks.PreResolve, Cookbooks: hooks.Requirements(requirements)}); err != nil {
+		return nil, err
+	}
+	resolveCtx, cancel := berrors.WithPhaseTimeout(ctx, berrors.PhaseResolve, c.options.ResolveTimeout)
+	defer cancel()
+	resolution, err := r.Resolve(resolveCtx, requirements)
+	if err != nil {
+		return nil, berrors.WithType(fmt.Errorf("failed to resolve dependencies: %w", berrors.PhaseError(resolveCtx, err)), berrors.ErrorTypeResolution)
+	}
+	if resolution.HasErrors() {
+		err := fmt.Errorf("%w: %w", resolution.Failure(), errors.Join(resolution.Errors...))
+		return nil, berrors.PhaseError(resolveCtx, err)
+	}
+	if err := c.runHooks(ctx, hooks.Payload{Event: hooks.PostResolve, Cookbooks: hooks.Resolved(resolution)}); err != nil {
+		return nil, err
+	}
+
+	lockFile, err := lockManager.Generate(resolution)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate lock file: %w", err)
+	}
+
+	// Record path sources relative to the Berksfile, as 'berks install' does
+	for _, cookbook := range lockFile.ListCookbooks() {
+		if cookbook.Source == nil || cookbook.Source.Type != "path" {
+			continue
+		}
+		if rel, err := filepath.Rel(c.dir, cookbook.Source.Path); err == nil {
+			cookbook.Source.Path = rel
+		}
+	}
+	return lockFile, nil
+}
+
+// runHooks runs the hooks for an event in the client directory. A failing
+// hook is a validation error.
+func (c *Client) runHooks(ctx context.Context, payload hooks.Payload) error {
+	payload.Dir = c.dir
+	if err := c.options.Hooks.Run(ctx, payload); err != nil {
+		return berrors.WithType(err, berrors.ErrorTypeValidation)
+	}
+	return nil
+}
+
+// absPath returns a copy of a path source with a path relative to the
+// Berksfile made absolute; other sources are returned unchanged
+func (c *Client) absPath(loc *berkshelf.SourceLocation) *berkshelf.SourceLocation {
+	if loc.Type != "path" || filepath.IsAbs(loc.Path) {
+		return loc
+	}
+	abs := *loc
+	abs.Path = filepath.Join(c.dir, loc.Path)
+	return &abs
+}
@@ -0,0 +1,213 @@
+package berks
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/hooks"
+	"github.com/bdwyertech/go-berkshelf/pkg/lockfile"
+	"github.com/bdwyertech/go-berkshelf/pkg/publish"
+)
+
+// newProject writes a Berksfile depending on a cookbook beside it
+func newProject(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	files := map[string]string{
+		"app/Berksfile":              "cookbook 'base', path: '../base'\n",
+		"base/metadata.rb":           "name 'base'\nversion '1.2.0'\n",
+		"base/recipes/default.rb":    "log 'hello'\n",
+		"base/attributes/default.rb": "default['base'] = true\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return filepath.Join(root, "app")
+}
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	client, err := New(newProject(t), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lockFile, err := client.Resolve(ctx)
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	base := lockFile.ListCookbooks()["base"]
+	if base == nil || base.Version != "1.2.0" || base.Source.Path != "../base" {
+		t.Fatalf("Resolve() base = %+v, want 1.2.0 from ../base", base)
+	}
+	if _, err := os.Stat(filepath.Join(client.Dir(), lockfile.DefaultLockFileName)); !os.IsNotExist(err) {
+		t.Errorf("Resolve() wrote a lock file")
+	}
+
+	result, err := client.Install(ctx, InstallOptions{})
+	if err != nil || result.Skipped {
+		t.Fatalf("Install() = %+v, %v", result, err)
+	}
+	if result, err := client.Install(ctx, InstallOptions{}); err != nil || !result.Skipped {
+		t.Errorf("second Install() = %+v, %v, want skipped", result, err)
+	}
+
+	vendored, err := client.Vendor(ctx, "berks-cookbooks")
+	if err != nil || vendored.SuccessfulDownloads != 1 {
+		t.Fatalf("Vendor() = %+v, %v", vendored, err)
+	}
+	if _, err := os.Stat(filepath.Join(client.Dir(), "berks-cookbooks", "base", "recipes", "default.rb")); err != nil {
+		t.Errorf("Vendor() did not copy the recipe: %v", err)
+	}
+
+	uploads, err := client.Upload(ctx, UploadOptions{Server: publish.ChefServerOptions{
+		URL:    "https://chef.example.com/organizations/acme",
+		DryRun: true,
+	}})
+	if err != nil || len(uploads) != 1 || uploads[0].Upload == nil || uploads[0].Upload.Version != "1.2.0" {
+		t.Fatalf("Upload() = %+v, %v", uploads, err)
+	}
+
+	if _, err := client.Upload(ctx, UploadOptions{Cookbooks: []string{"missing"}}); err == nil {
+		t.Error("Upload() of a cookbook that is not locked succeeded")
+	}
+}
+
+var (
+	registerHooks sync.Once
+	hookEvents    []string
+)
+
+func TestClientHooks(t *testing.T) {
+	// Plugins cannot be unregistered outside their package, so they are
+	// registered once however often the test runs
+	registerHooks.Do(func() {
+		hooks.Register("berks-test-record", func(ctx context.Context, payload hooks.Payload) error {
+			for _, cookbook := range payload.Cookbooks {
+				hookEvents = append(hookEvents, string(payload.Event)+":"+cookbook.Name+"@"+cookbook.Version)
+			}
+			return nil
+		})
+		hooks.Register("berks-test-veto", func(ctx context.Context, payload hooks.Payload) error {
+			return errors.New("uploads are frozen")
+		})
+	})
+	hookEvents = nil
+
+	ctx := context.Background()
+	var configured []hooks.Hook
+	for _, event := range hooks.Events {
+		configured = append(configured, hooks.Hook{Event: event, Plugin: "berks-test-record"})
+	}
+	configured = append(configured, hooks.Hook{Event: hooks.PreUpload, Plugin: "berks-test-veto"})
+	client, err := New(newProject(t), Options{Hooks: hooks.NewRunner(configured)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.Install(ctx, InstallOptions{}); err != nil {
+		t.Fatalf("Install() error = %v", err)
+	}
+	if _, err := client.Vendor(ctx, "berks-cookbooks"); err != nil {
+		t.Fatalf("Vendor() error = %v", err)
+	}
+	_, err = client.Upload(ctx, UploadOptions{Server: publish.ChefServerOptions{
+		URL:    "https://chef.example.com/organizations/acme",
+		DryRun: true,
+	}})
+	var hookErr *hooks.Error
+	if !errors.As(err, &hookErr) || hookErr.Event != hooks.PreUpload {
+		t.Fatalf("Upload() error = %v, want the pre-upload veto", err)
+	}
+
+	// Upload vendors to a temporary directory without running the vendor hooks
+	want := []string{"pre-resolve:base@", "post-resolve:base@1.2.0", "pre-vendor:base@1.2.0", "post-vendor:base@1.2.0", "pre-upload:base@1.2.0"}
+	if !slices.Equal(hookEvents, want) {
+		t.Errorf("hook events = %v, want %v", hookEvents, want)
+	}
+}
+
+func TestClientWithoutBerksfile(t *testing.T) {
+	client, err := New(t.TempDir(), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Install(context.Background(), InstallOptions{}); err == nil {
+		t.Error("Install() without a Berksfile succeeded")
+	}
+	if _, err := client.Outdated(context.Background()); err == nil {
+		t.Error("Outdated() without a Berksfile succeeded")
+	}
+}
+
+func TestClientOverrides(t *testing.T) {
+	ctx := context.Background()
+	dir := newProject(t)
+	hotfix := filepath.Join(filepath.Dir(dir), "hotfix")
+	if err := os.MkdirAll(hotfix, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(hotfix, "metadata.rb"), []byte("name 'base'\nversion '1.2.1'\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := New(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Install(ctx, InstallOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	manager := lockfile.NewManager(dir)
+	lockFile, err := manager.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	lockFile.Overrides = map[string]*lockfile.Override{
+		"base": {Version: "1.2.1", Source: &lockfile.SourceInfo{Type: "path", Path: "../hotfix"}, Reason: "hotfix"},
+	}
+	if err := manager.Save(lockFile); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := client.Install(ctx, InstallOptions{Force: true})
+	if err != nil {
+		t.Fatalf("Install() error = %v", err)
+	}
+	base := result.LockFile.ListCookbooks()["base"]
+	if base == nil || base.Version != "1.2.1" || base.Source.Path != "../hotfix" {
+		t.Errorf("Install() base = %+v, want the 1.2.1 override from ../hotfix", base)
+	}
+	if result.LockFile.Overrides["base"] == nil {
+		t.Error("Install() dropped the override from the lock file")
+	}
+}
+
+func TestDependencyOrder(t *testing.T) {
+	locked := map[string]*lockfile.CookbookLock{
+		"app":   {Dependencies: map[string]string{"nginx": "~> 12.0"}},
+		"apt":   {},
+		"nginx": {Dependencies: map[string]string{"apt": ">= 0.0.0", "ohai": ">= 0.0.0"}},
+		"ohai":  {},
+		"yum":   {},
+	}
+	names, err := dependencyOrder([]string{"app", "apt", "nginx", "ohai", "yum"}, locked)
+	if err != nil {
+		t.Fatalf("dependencyOrder() error = %v", err)
+	}
+	if want := []string{"apt", "ohai", "nginx", "app", "yum"}; !slices.Equal(names, want) {
+		t.Errorf("dependencyOrder() = %v, want %v", names, want)
+	}
+}
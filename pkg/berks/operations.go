@@ -0,0 +1,274 @@
+package berks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	berrors "github.com/bdwyertech/go-berkshelf/pkg/errors"
+	"github.com/bdwyertech/go-berkshelf/pkg/hooks"
+	"github.com/bdwyertech/go-berkshelf/pkg/lockfile"
+	"github.com/bdwyertech/go-berkshelf/pkg/outdated"
+	"github.com/bdwyertech/go-berkshelf/pkg/publish"
+	"github.com/bdwyertech/go-berkshelf/pkg/resolver"
+	"github.com/bdwyertech/go-berkshelf/pkg/vendor"
+)
+
+// InstallOptions configures Install
+type InstallOptions struct {
+	// Only installs the cookbooks in these groups
+	Only []string
+	// Except installs all cookbooks except those in these groups
+	Except []string
+	// Force resolves even if the lock file is up to date
+	Force bool
+}
+
+// InstallResult describes an install
+type InstallResult struct {
+	// LockFile is the lock file written, or the one kept when Skipped
+	LockFile *lockfile.LockFile
+	// Path is the JSON lock file path
+	Path string
+	// Skipped is set when the lock file was already up to date
+	Skipped bool
+}
+
+// Install resolves the Berksfile and writes Berksfile.lock, as 'berks
+// install' does. An up to date lock file is kept unless opts.Force is set.
+func (c *Client) Install(ctx context.Context, opts InstallOptions) (*InstallResult, error) {
+	bf, err := c.berksfile()
+	if err != nil {
+		return nil, err
+	}
+
+	manager := c.lockManager()
+	result := &InstallResult{Path: manager.GetPath()}
+	if !opts.Force && manager.Exists() {
+		if outdated, err := manager.IsOutdated(); err == nil && !outdated {
+			result.LockFile, err = manager.Load()
+			if err != nil {
+				return nil, err
+			}
+			result.Skipped = true
+			return result, nil
+		}
+	}
+
+	result.LockFile, err = c.resolve(ctx, bf, opts.Only, opts.Except)
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []string
+	if len(opts.Only) > 0 {
+		groups = opts.Only
+	}
+	dependencies, err := bf.ExtractDirectDependencies(groups)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract direct dependencies: %w", err)
+	}
+	if err := manager.SaveBoth(result.LockFile, dependencies); err != nil {
+		return nil, fmt.Errorf("failed to update lock files: %w", err)
+	}
+	return result, nil
+}
+
+// Resolve resolves every cookbook in the Berksfile and returns the lock file
+// an install would write, without writing it
+func (c *Client) Resolve(ctx context.Context) (*lockfile.LockFile, error) {
+	bf, err := c.berksfile()
+	if err != nil {
+		return nil, err
+	}
+	return c.resolve(ctx, bf, nil, nil)
+}
+
+// Vendor downloads the locked cookbooks into dir, as 'berks vendor' does. A
+// relative dir is taken from the Berksfile directory. The lock file must
+// exist; Install first. Failed downloads are returned with the result as an
+// errors.PartialFailure.
+func (c *Client) Vendor(ctx context.Context, dir string) (*vendor.Result, error) {
+	return c.vendor(ctx, dir, true)
+}
+
+// vendor vendors the locked cookbooks into dir, running the pre-vendor and
+// post-vendor hooks when withHooks is set
+func (c *Client) vendor(ctx context.Context, dir string, withHooks bool) (*vendor.Result, error) {
+	bf, err := c.berksfile()
+	if err != nil {
+		return nil, err
+	}
+	lockFile, err := c.lockFile()
+	if err != nil {
+		return nil, err
+	}
+	manager, err := c.sourceManager(bf)
+	if err != nil {
+		return nil, err
+	}
+
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(c.dir, dir)
+	}
+	if withHooks {
+		if err := c.runHooks(ctx, hooks.Payload{Event: hooks.PreVendor, Path: dir, Cookbooks: hooks.Locked(lockFile, nil)}); err != nil {
+			return nil, err
+		}
+	}
+	downloadCtx, cancel := berrors.WithPhaseTimeout(ctx, berrors.PhaseDownload, c.options.DownloadTimeout)
+	defer cancel()
+	result, err := vendor.New(lockFile, manager, vendor.Options{TargetPath: dir, KeepGoing: c.options.KeepGoing}).Vendor(downloadCtx)
+	if err = berrors.PhaseError(downloadCtx, err); err != nil {
+		return nil, fmt.Errorf("vendor failed: %w", err)
+	}
+	if len(result.Collisions) > 0 {
+		return result, fmt.Errorf("%d vendor path collision(s) in %s", len(result.Collisions), result.TargetPath)
+	}
+	if withHooks {
+		vendored := result.Vendored()
+		payload := hooks.Payload{Event: hooks.PostVendor, Path: result.TargetPath, Cookbooks: hooks.Locked(lockFile, func(name string) bool {
+			return slices.Contains(vendored, name)
+		})}
+		if err := c.runHooks(ctx, payload); err != nil {
+			return result, err
+		}
+	}
+	return result, result.Failure()
+}
+
+// Outdated returns the locked cookbooks with newer versions on their sources
+func (c *Client) Outdated(ctx context.Context) ([]outdated.Cookbook, error) {
+	bf, err := c.berksfile()
+	if err != nil {
+		return nil, err
+	}
+	lockFile, err := c.lockFile()
+	if err != nil {
+		return nil, err
+	}
+	manager, err := c.sourceManager(bf)
+	if err != nil {
+		return nil, err
+	}
+	return outdated.New(lockFile, manager).Check(ctx, nil)
+}
+
+// UploadOptions configures Upload
+type UploadOptions struct {
+	// Server is the Chef Server organization and how to upload to it
+	Server publish.ChefServerOptions
+	// Cookbooks limits the upload to these locked cookbooks; empty uploads all
+	Cookbooks []string
+}
+
+// UploadResult describes one uploaded cookbook
+type UploadResult struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	// Skipped is set when the server already had the version, or had it
+	// with the same files when forcing
+	Skipped bool `json:"skipped,omitempty"`
+	// Upload is the upload, when not skipped
+	Upload *publish.Result `json:"upload,omitempty"`
+}
+
+// Upload uploads the locked cookbooks to a Chef Server organization, each
+// after the cookbooks it depends on, once the pre-upload hooks pass. Versions the server already has are
+// skipped unless opts.Server.Force is set, and even then when the server's
+// files are the same. Cookbooks are vendored into a temporary directory first.
+func (c *Client) Upload(ctx context.Context, opts UploadOptions) ([]UploadResult, error) {
+	lockFile, err := c.lockFile()
+	if err != nil {
+		return nil, err
+	}
+	locked := lockFile.ListCookbooks()
+	for _, name := range opts.Cookbooks {
+		if _, ok := locked[name]; !ok {
+			return nil, fmt.Errorf("cookbook %s is not in the lock file", name)
+		}
+	}
+
+	dir, err := os.MkdirTemp("", "berks-upload-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	// The temporary copies are not a vendored tree, so no vendor hooks run
+	if _, err := c.vendor(ctx, dir, false); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(locked))
+	for name := range locked {
+		if len(opts.Cookbooks) == 0 || slices.Contains(opts.Cookbooks, name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if names, err = dependencyOrder(names, locked); err != nil {
+		return nil, err
+	}
+
+	payload := hooks.Payload{Event: hooks.PreUpload, Target: opts.Server.URL, Cookbooks: hooks.Locked(lockFile, func(name string) bool {
+		return slices.Contains(names, name)
+	})}
+	if err := c.runHooks(ctx, payload); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := berrors.WithPhaseTimeout(ctx, berrors.PhaseUpload, c.options.UploadTimeout)
+	defer cancel()
+	results := make([]UploadResult, 0, len(names))
+	for _, name := range names {
+		result := UploadResult{Name: name, Version: locked[name].Version}
+		upload, err := publish.UploadChefServer(ctx, filepath.Join(dir, name), opts.Server)
+		var published *publish.ErrAlreadyPublished
+		switch {
+		case errors.As(err, &published):
+			result.Skipped = true
+		case err != nil:
+			return results, fmt.Errorf("failed to upload %s: %w", name, berrors.PhaseError(ctx, err))
+		case upload.Unchanged:
+			result.Skipped = true
+		default:
+			result.Upload = upload
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// dependencyOrder sorts names so each cookbook comes after the locked
+// cookbooks it depends on, keeping the given order otherwise
+func dependencyOrder(names []string, locked map[string]*lockfile.CookbookLock) ([]string, error) {
+	graph := resolver.NewDependencyGraph()
+	for _, name := range slices.Backward(names) {
+		graph.AddCookbook(&berkshelf.Cookbook{Name: name})
+	}
+	for _, name := range names {
+		from, _ := graph.GetCookbook(name)
+		for dependency := range locked[name].Dependencies {
+			if to, ok := graph.GetCookbook(dependency); ok && dependency != name {
+				graph.AddDependency(from, to, nil)
+			}
+		}
+	}
+
+	// The sort puts dependents first; uploads go the other way
+	sorted, err := graph.TopologicalSort()
+	if err != nil {
+		return nil, fmt.Errorf("cannot order uploads: %w", err)
+	}
+	ordered := make([]string, 0, len(sorted))
+	for _, node := range slices.Backward(sorted) {
+		ordered = append(ordered, node.Name)
+	}
+	return ordered, nil
+}
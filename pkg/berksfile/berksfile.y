@@ -5,7 +5,9 @@
 package berksfile
 
 import (
+    "fmt"
     "strings"
+    "text/scanner"
 
     "github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
 )
@@ -16,6 +18,10 @@ type CookbookDef struct {
 	Constraint *berkshelf.Constraint
 	Source     *berkshelf.SourceLocation
 	Groups     []string
+	Line       int // Line of the Berksfile the cookbook is declared on
+	// MetadataName is the name the cookbook's metadata declares when it
+	// differs from Name, e.g. for a fork; set with metadata_name:
+	MetadataName string
 }
 
 // Berksfile represents a parsed Berksfile
@@ -24,6 +30,10 @@ type Berksfile struct {
 	Cookbooks   []*CookbookDef                // All cookbook definitions
 	Groups      map[string][]*CookbookDef     // Grouped cookbooks
 	HasMetadata bool                          // Whether metadata directive is present
+	GroupIncludes map[string][]string         // Groups composed of other groups, e.g. group :ci => [:test]
+	Overrides     []*CookbookDef              // Cookbooks pinned with override, regardless of other constraints
+	Ignored       []string                    // Cookbooks provided out of band, which are not resolved
+	Warnings    []string                      // Non-fatal issues, such as converted constraints
 }
 
 var Result *Berksfile
@@ -84,6 +94,99 @@ func ParseConstraint(constraintStr string) (*berkshelf.Constraint, error) {
 	return berkshelf.NewConstraint(constraintStr)
 }
 
+// newCookbookDef creates the cookbook a cookbook or override statement
+// declares
+func newCookbookDef(l *Lexer, name string, tail cbTail) *CookbookDef {
+	constraint, _ := ParseConstraint(">= 0.0.0")
+	if tail.version != "" {
+		if expanded, ok := berkshelf.ExpandShorthand(tail.version); ok {
+			parseWarnings = append(parseWarnings, fmt.Sprintf("cookbook '%s': converted shorthand constraint %q to %q", name, tail.version, expanded))
+			tail.version = expanded
+		}
+		if c, err := ParseConstraint(tail.version); err != nil {
+			l.errorAt(tail.versionPos, "invalid version constraint: "+tail.version)
+		} else {
+			constraint = c
+		}
+	}
+
+	source := &berkshelf.SourceLocation{}
+	if tail.options != nil {
+		source.Options = make(map[string]any)
+		if gitUrl, ok := tail.options["git"]; ok {
+			source.Type = "git"
+			source.URL = gitUrl
+			if branch, ok := tail.options["branch"]; ok {
+				source.Ref = branch
+				source.Options["branch"] = branch
+			}
+			if ref, ok := tail.options["ref"]; ok {
+				source.Ref = ref
+				source.Options["ref"] = ref
+			}
+			if rel, ok := tail.options["rel"]; ok {
+				// The cookbook's directory in a monorepo
+				source.Path = rel
+			}
+			if submodules, ok := tail.options["submodules"]; ok {
+				source.Options["submodules"] = submodules
+			}
+		} else if github, ok := tail.options["github"]; ok {
+			source.Type = "git"
+			source.URL = "https://github.com/" + github + ".git"
+			if rel, ok := tail.options["rel"]; ok {
+				source.Path = rel
+			}
+			if submodules, ok := tail.options["submodules"]; ok {
+				source.Options["submodules"] = submodules
+			}
+		} else if path, ok := tail.options["path"]; ok {
+			source.Type = "path"
+			source.Path = path
+		} else if s3, ok := tail.options["s3"]; ok {
+			source.Type = "s3"
+			source.URL = s3
+			for _, k := range []string{"region", "endpoint", "profile", "layout"} {
+				if v, ok := tail.options[k]; ok {
+					source.Options[k] = v
+				}
+			}
+		} else if oci, ok := tail.options["oci"]; ok {
+			source.Type = "oci"
+			source.URL = oci
+			if v, ok := tail.options["plain_http"]; ok {
+				source.Options["plain_http"] = v
+			}
+		} else if typ, ok := tail.options["type"]; ok {
+			// A source type registered with source.Register, which
+			// gets the other options
+			source.Type = typ
+			source.URL = tail.options["url"]
+			for k, v := range tail.options {
+				if k != "type" && k != "url" {
+					source.Options[k] = v
+				}
+			}
+		}
+	}
+
+	cb := &CookbookDef{
+		Name:       name,
+		Constraint: constraint,
+		Source:     source,
+		Groups:     []string{},
+		Line:       l.cookbookLine,
+	}
+	if metadataName, ok := tail.options["metadata_name"]; ok && metadataName != name {
+		cb.MetadataName = metadataName
+		if source.Type == "path" {
+			// The path source finds the cookbook by its metadata name
+			source.Options["metadata_name"] = metadataName
+		}
+	}
+	return cb
+}
+
 func trimQuotes(s string) string {
     return strings.Trim(s, `"'`)
 }
@@ -96,8 +199,9 @@ type sourceArgs struct {
 }
 
 type cbTail struct {
-    version string
-    options map[string]string
+    version    string
+    versionPos scanner.Position
+    options    map[string]string
 }
 
 type kv struct {
@@ -115,7 +219,9 @@ type Source struct {
 // Group represents a group definition in a Berksfile
 type Group struct {
     Name      string
-    Cookbooks []*CookbookDef
+    Cookbooks []*CookbookDef // Including those of nested groups
+    Nested    []*Group
+    Includes  []string       // Set for a group composed of other groups
 }
 
 // Collections type to hold multiple items with metadata flag
@@ -123,6 +229,8 @@ type collections struct {
     sources   []*Source
     cookbooks []*CookbookDef
     groups    []*Group
+    overrides []*CookbookDef
+    ignored   []string
     metadata  bool
 }
 
@@ -131,6 +239,8 @@ type stmtResult struct {
     source   *Source
     cookbook *CookbookDef
     group    *Group
+    override *CookbookDef
+    ignored  []string
     metadata bool
 }
 
@@ -138,6 +248,7 @@ type stmtResult struct {
 
 %union {
     str        string
+    strs       []string
     source     *Source
     cookbook   *CookbookDef
     group      *Group
@@ -151,10 +262,11 @@ type stmtResult struct {
     boolVal    bool
     collections collections
     stmt       stmtResult
+    pos        scanner.Position // Where the token starts, set by the lexer
 }
 
 // Tokens
-%token <str> SOURCE METADATA COOKBOOK GROUP DO END IDENT STRING COLON COMMA LBRACE RBRACE HASHROCKET NEWLINE
+%token <str> SOURCE METADATA COOKBOOK OVERRIDE IGNORE GROUP DO END IDENT STRING COLON COMMA LBRACE RBRACE LBRACKET RBRACKET HASHROCKET NEWLINE
 
 // Type declarations for non-terminals
 %type <collections> berksfile statement_list non_empty_statement_list
@@ -162,11 +274,12 @@ type stmtResult struct {
 %type <source> source_stmt
 %type <sa> source_args
 %type <boolVal> metadata_stmt
-%type <cookbook> cookbook_stmt
+%type <cookbook> cookbook_stmt override_stmt
 %type <str> cookbook_name
+%type <strs> ignore_stmt cookbook_names
 %type <cbTail> cookbook_tail
 %type <group> group_stmt
-%type <cookbooks> group_body group_content
+%type <collections> group_body group_content
 %type <opts> hash_pairs hash_pairs_tail
 %type <kv> hash_pair
 %type <sources> group_names
@@ -197,10 +310,9 @@ berksfile:
         
         // Convert groups from []*Group to map[string][]*CookbookDef
         groups := make(map[string][]*CookbookDef)
-        for _, group := range $1.groups {
-            // Add group cookbooks to the main cookbook list
-            allCookbooks = append(allCookbooks, group.Cookbooks...)
-            
+        includes := make(map[string][]string)
+        var addGroup func(group *Group)
+        addGroup = func(group *Group) {
             // Handle group names (could be comma-separated for multiple groups)
             groupNames := strings.Split(group.Name, ",")
             
@@ -209,6 +321,9 @@ berksfile:
                 if groups[groupName] == nil {
                     groups[groupName] = []*CookbookDef{}
                 }
+                if group.Includes != nil {
+                    includes[groupName] = append(includes[groupName], group.Includes...)
+                }
                 
                 // Add cookbooks to this group
                 for _, cb := range group.Cookbooks {
@@ -225,6 +340,14 @@ berksfile:
                     }
                 }
             }
+            for _, nested := range group.Nested {
+                addGroup(nested)
+            }
+        }
+        for _, group := range $1.groups {
+            // Add group cookbooks to the main cookbook list
+            allCookbooks = append(allCookbooks, group.Cookbooks...)
+            addGroup(group)
         }
         
         Result = &Berksfile{
@@ -232,6 +355,12 @@ berksfile:
             Cookbooks:   allCookbooks,
             Groups:      groups,
             HasMetadata: $1.metadata,
+            Warnings:    parseWarnings,
+            Overrides:   $1.overrides,
+            Ignored:     $1.ignored,
+        }
+        if len(includes) > 0 {
+            Result.GroupIncludes = includes
         }
         $$ = $1
     }
@@ -254,6 +383,7 @@ non_empty_statement_list:
         $$.sources = $1.sources
         $$.cookbooks = $1.cookbooks
         $$.groups = $1.groups
+        $$.overrides = $1.overrides
         $$.metadata = $1.metadata
         
         // Add new statement
@@ -266,6 +396,10 @@ non_empty_statement_list:
         if $2.group != nil {
             $$.groups = append($$.groups, $2.group)
         }
+        if $2.override != nil {
+            $$.overrides = append($$.overrides, $2.override)
+        }
+        $$.ignored = append($1.ignored, $2.ignored...)
         if $2.metadata {
             $$.metadata = true
         }
@@ -273,6 +407,12 @@ non_empty_statement_list:
     | non_empty_statement_list NEWLINE {
         $$ = $1
     }
+    | non_empty_statement_list error NEWLINE {
+        // Skip the rest of a bad line, so errors on later lines are
+        // reported too
+        $$ = $1
+        Errflag = 0
+    }
     | statement {
         $$.sources = []*Source{}
         $$.cookbooks = []*CookbookDef{}
@@ -289,6 +429,10 @@ non_empty_statement_list:
         if $1.group != nil {
             $$.groups = append($$.groups, $1.group)
         }
+        if $1.override != nil {
+            $$.overrides = append($$.overrides, $1.override)
+        }
+        $$.ignored = $1.ignored
         if $1.metadata {
             $$.metadata = true
         }
@@ -299,6 +443,13 @@ non_empty_statement_list:
         $$.groups = []*Group{}
         $$.metadata = false
     }
+    | error NEWLINE {
+        $$.sources = []*Source{}
+        $$.cookbooks = []*CookbookDef{}
+        $$.groups = []*Group{}
+        $$.metadata = false
+        Errflag = 0
+    }
     ;
 
 statement:
@@ -326,6 +477,20 @@ statement:
         $$.group = $1
         $$.metadata = false
     }
+    | override_stmt {
+        $$.source = nil
+        $$.cookbook = nil
+        $$.group = nil
+        $$.override = $1
+        $$.metadata = false
+    }
+    | ignore_stmt {
+        $$.source = nil
+        $$.cookbook = nil
+        $$.group = nil
+        $$.ignored = $1
+        $$.metadata = false
+    }
     ;
 
 source_stmt:
@@ -364,48 +529,34 @@ metadata_stmt:
 
 cookbook_stmt:
     COOKBOOK cookbook_name cookbook_tail {
-        constraint, _ := ParseConstraint(">= 0.0.0")
-        if $3.version != "" {
-            if c, err := ParseConstraint($3.version); err != nil {
-                yylex.Error("invalid version constraint: " + $3.version)
-                return 1
-            } else {
-                constraint = c
-            }
-        }
-        
-        source := &berkshelf.SourceLocation{}
-        if $3.options != nil {
-            source.Options = make(map[string]any)
-            if gitUrl, ok := $3.options["git"]; ok {
-                source.Type = "git"
-                source.URL = gitUrl
-                if branch, ok := $3.options["branch"]; ok {
-                    source.Ref = branch
-                    source.Options["branch"] = branch
-                }
-                if ref, ok := $3.options["ref"]; ok {
-                    source.Ref = ref
-                    source.Options["ref"] = ref
-                }
-            } else if github, ok := $3.options["github"]; ok {
-                source.Type = "git"
-                source.URL = "https://github.com/" + github + ".git"
-            } else if path, ok := $3.options["path"]; ok {
-                source.Type = "path"
-                source.Path = path
-            }
-        }
-        
-        $$ = &CookbookDef{
-            Name:       $2,
-            Constraint: constraint,
-            Source:     source,
-            Groups:     []string{},
+        $$ = newCookbookDef(yylex.(*Lexer), $2, $3)
+    }
+    ;
+
+override_stmt:
+    OVERRIDE cookbook_name cookbook_tail {
+        $$ = newCookbookDef(yylex.(*Lexer), $2, $3)
+        if $3.version == "" && $$.Source.Type == "" {
+            yylex.(*Lexer).errorAt($<pos>1, "override '" + $2 + "' needs a version constraint or a source")
         }
     }
     ;
 
+ignore_stmt:
+    IGNORE cookbook_names {
+        $$ = $2
+    }
+    ;
+
+cookbook_names:
+    cookbook_name {
+        $$ = []string{$1}
+    }
+    | cookbook_names COMMA cookbook_name {
+        $$ = append($1, $3)
+    }
+    ;
+
 cookbook_name:
     STRING { $$ = trimQuotes($1) }
     | IDENT { $$ = $1 }
@@ -414,6 +565,7 @@ cookbook_name:
 cookbook_tail:
     COMMA STRING {
         $$.version = trimQuotes($2)
+        $$.versionPos = $<pos>2
         $$.options = nil
     }
     | COMMA LBRACE hash_pairs RBRACE {
@@ -422,6 +574,7 @@ cookbook_tail:
     }
     | COMMA STRING COMMA LBRACE hash_pairs RBRACE {
         $$.version = trimQuotes($2)
+        $$.versionPos = $<pos>2
         $$.options = $5
     }
     | COMMA hash_pairs {
@@ -430,6 +583,7 @@ cookbook_tail:
     }
     | COMMA STRING COMMA hash_pairs {
         $$.version = trimQuotes($2)
+        $$.versionPos = $<pos>2
         $$.options = $4
     }
     | /* empty */ {
@@ -447,8 +601,8 @@ group_stmt:
             groupNames[i] = src.URL // We're reusing Source.URL to store group names
         }
         
-        // Add group names to each cookbook
-        for _, cb := range $4 {
+        // Add group names to each cookbook, including those of nested groups
+        for _, cb := range $4.cookbooks {
             cb.Groups = append(cb.Groups, groupNames...)
         }
         
@@ -463,7 +617,23 @@ group_stmt:
         
         $$ = &Group{
             Name:      groupName,
-            Cookbooks: $4,
+            Cookbooks: $4.cookbooks,
+            Nested:    $4.groups,
+        }
+    }
+    | GROUP group_names HASHROCKET LBRACKET group_names RBRACKET {
+        // A group composed of other groups has their cookbooks
+        if len($2) > 1 {
+            yylex.(*Lexer).errorAt($<pos>1, "a composed group takes one name")
+        }
+        included := make([]string, len($5))
+        for i, src := range $5 {
+            included[i] = src.URL
+        }
+        
+        $$ = &Group{
+            Name:     $2[0].URL,
+            Includes: included,
         }
     }
     ;
@@ -494,22 +664,39 @@ group_body:
         $$ = $1
     }
     | /* empty */ {
-        $$ = []*CookbookDef{}
+        $$.cookbooks = []*CookbookDef{}
+        $$.groups = []*Group{}
     }
     ;
 
 group_content:
     group_content cookbook_stmt {
-        $$ = append($1, $2)
+        $$.cookbooks = append($1.cookbooks, $2)
+        $$.groups = $1.groups
+    }
+    | group_content group_stmt {
+        // A nested group's cookbooks belong to the enclosing group too
+        $$.cookbooks = append($1.cookbooks, $2.Cookbooks...)
+        $$.groups = append($1.groups, $2)
     }
     | group_content NEWLINE {
         $$ = $1
     }
+    | group_content error NEWLINE {
+        $$ = $1
+        Errflag = 0
+    }
     | cookbook_stmt {
-        $$ = []*CookbookDef{$1}
+        $$.cookbooks = []*CookbookDef{$1}
+        $$.groups = []*Group{}
+    }
+    | group_stmt {
+        $$.cookbooks = append([]*CookbookDef{}, $1.Cookbooks...)
+        $$.groups = []*Group{$1}
     }
     | NEWLINE {
-        $$ = []*CookbookDef{}
+        $$.cookbooks = []*CookbookDef{}
+        $$.groups = []*Group{}
     }
     ;
 
@@ -541,6 +728,11 @@ hash_pair:
         $$.key = $1
         $$.value = trimQuotes($3)
     }
+    | IDENT COLON IDENT {
+        // A bare value, as in submodules: true
+        $$.key = $1
+        $$.value = $3
+    }
     | COLON IDENT HASHROCKET STRING {
         $$.key = $2
         $$.value = trimQuotes($4)
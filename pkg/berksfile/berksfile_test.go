@@ -1,6 +1,7 @@
 package berksfile_test
 
 import (
+	"errors"
 	"sort"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -47,6 +48,18 @@ var _ = Describe("Parse basic Berksfile", func() {
 		Expect(b.Cookbooks[0].Name).To(Equal("nginx"))
 		Expect(b.Cookbooks[0].Constraint).NotTo(BeNil())
 		Expect(b.Cookbooks[0].Constraint.String()).To(Equal("~> 2.7.6"))
+		Expect(b.Warnings).To(BeEmpty())
+	})
+
+	It("should convert shorthand version constraints with a warning", func() {
+		b, err := berksfile.Parse("cookbook 'nginx', '2.x'\ncookbook 'apt', '~7.4'")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(b.GetCookbook("nginx").Constraint.String()).To(Equal("~> 2.0"))
+		Expect(b.GetCookbook("apt").Constraint.String()).To(Equal("~> 7.4.0"))
+		Expect(b.Warnings).To(ConsistOf(
+			`cookbook 'nginx': converted shorthand constraint "2.x" to "~> 2.0"`,
+			`cookbook 'apt': converted shorthand constraint "~7.4" to "~> 7.4.0"`,
+		))
 	})
 
 	It("should parse a cookbook with git source", func() {
@@ -60,6 +73,21 @@ var _ = Describe("Parse basic Berksfile", func() {
 		Expect(cb.Source.URL).To(Equal("git@github.com:user/repo.git"))
 	})
 
+	It("should parse a git cookbook with submodules", func() {
+		b, err := berksfile.Parse(`cookbook 'private', git: 'git@github.com:user/repo.git', submodules: true`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(b.Cookbooks).To(HaveLen(1))
+		Expect(b.Cookbooks[0].Source.Options).To(HaveKeyWithValue("submodules", "true"))
+	})
+
+	It("should parse a git cookbook in a monorepo directory", func() {
+		b, err := berksfile.Parse(`cookbook 'app', git: 'https://git.example.com/monorepo.git', rel: 'cookbooks/app'`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(b.Cookbooks).To(HaveLen(1))
+		Expect(b.Cookbooks[0].Source.Type).To(Equal("git"))
+		Expect(b.Cookbooks[0].Source.Path).To(Equal("cookbooks/app"))
+	})
+
 	It("should parse a cookbook with github shorthand", func() {
 		b, err := berksfile.Parse(`cookbook 'private', github: 'user/repo'`)
 		Expect(err).NotTo(HaveOccurred())
@@ -88,6 +116,42 @@ var _ = Describe("Parse basic Berksfile", func() {
 		Expect(cb.Source).NotTo(BeNil())
 		Expect(cb.Source.Options["branch"]).To(Equal("develop"))
 	})
+
+	It("should parse a cookbook with a registered source type", func() {
+		b, err := berksfile.Parse(`cookbook 'internal', type: 'artifacts', url: 'artifacts://prod', endpoint: 'https://artifacts.example.com'`)
+		Expect(err).NotTo(HaveOccurred())
+		cb := b.Cookbooks[0]
+		Expect(cb.Source.Type).To(Equal("artifacts"))
+		Expect(cb.Source.URL).To(Equal("artifacts://prod"))
+		Expect(cb.Source.Options).To(Equal(map[string]any{"endpoint": "https://artifacts.example.com"}))
+	})
+
+	It("should parse a cookbook with S3 source", func() {
+		b, err := berksfile.Parse(`cookbook 'internal', s3: 's3://cookbooks/prod', region: 'us-east-1'`)
+		Expect(err).NotTo(HaveOccurred())
+		cb := b.Cookbooks[0]
+		Expect(cb.Source.Type).To(Equal("s3"))
+		Expect(cb.Source.URL).To(Equal("s3://cookbooks/prod"))
+		Expect(cb.Source.Options).To(Equal(map[string]any{"region": "us-east-1"}))
+	})
+
+	It("should parse a cookbook with OCI source", func() {
+		b, err := berksfile.Parse(`cookbook 'internal', oci: 'oci://localhost:5000/cookbooks', plain_http: 'true'`)
+		Expect(err).NotTo(HaveOccurred())
+		cb := b.Cookbooks[0]
+		Expect(cb.Source.Type).To(Equal("oci"))
+		Expect(cb.Source.URL).To(Equal("oci://localhost:5000/cookbooks"))
+		Expect(cb.Source.Options).To(Equal(map[string]any{"plain_http": "true"}))
+	})
+
+	It("should parse an S3 source", func() {
+		b, err := berksfile.Parse(`source s3: 's3://cookbooks/prod', region: 'us-east-1'`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(b.Sources).To(HaveLen(1))
+		Expect(b.Sources[0].Type).To(Equal("s3"))
+		Expect(b.Sources[0].URL).To(Equal("s3://cookbooks/prod"))
+		Expect(b.Sources[0].Options).To(HaveKeyWithValue("region", "us-east-1"))
+	})
 })
 
 var _ = Describe("Parse groups", func() {
@@ -137,6 +201,93 @@ end
 		Expect(chefspec).NotTo(BeNil())
 		Expect(chefspec.Groups).To(HaveLen(2))
 	})
+
+	It("should put cookbooks of nested groups in the enclosing groups", func() {
+		input := `
+group :test do
+  cookbook 'minitest-handler'
+
+  group :integration, :acceptance do
+    cookbook 'test-kitchen'
+
+    group :cloud do
+      cookbook 'kitchen-ec2'
+    end
+  end
+end
+`
+		b, err := berksfile.Parse(input)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(b.Cookbooks).To(HaveLen(3))
+		Expect(b.Groups).To(HaveLen(4))
+
+		Expect(b.GetCookbooks("test")).To(HaveLen(3))
+		Expect(b.GetCookbooks("integration")).To(HaveLen(2))
+		Expect(b.GetCookbooks("acceptance")).To(HaveLen(2))
+		Expect(b.GetCookbooks("cloud")).To(HaveLen(1))
+		Expect(b.GetCookbook("kitchen-ec2").Groups).To(ConsistOf("cloud", "integration", "acceptance", "test"))
+
+		filtered := berksfile.FilterCookbooksByGroup(b.Cookbooks, nil, []string{"integration"})
+		Expect(filtered).To(HaveLen(1))
+		Expect(filtered[0].Name).To(Equal("minitest-handler"))
+	})
+
+	It("should compose groups from other groups", func() {
+		input := `
+group :ci => [:test, :integration]
+group :all => [:ci, :development]
+
+group :test do
+  cookbook 'minitest-handler'
+end
+
+group :integration do
+  cookbook 'test-kitchen'
+end
+
+group :development do
+  cookbook 'chefspec'
+end
+
+cookbook 'nginx'
+`
+		b, err := berksfile.Parse(input)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(b.Cookbooks).To(HaveLen(4))
+		Expect(b.GroupIncludes).To(HaveKeyWithValue("ci", []string{"test", "integration"}))
+		Expect(b.GetCookbooks("ci")).To(HaveLen(2))
+		Expect(b.GetCookbooks("all")).To(HaveLen(3))
+
+		names := func(cookbooks []*berksfile.CookbookDef) []string {
+			var names []string
+			for _, cb := range cookbooks {
+				names = append(names, cb.Name)
+			}
+			return names
+		}
+		Expect(names(berksfile.FilterCookbooksByGroup(b.Cookbooks, []string{"ci"}, nil))).To(ConsistOf("minitest-handler", "test-kitchen"))
+		Expect(names(berksfile.FilterCookbooksByGroup(b.Cookbooks, nil, []string{"all"}))).To(ConsistOf("nginx"))
+	})
+
+	It("should reject composing unknown groups", func() {
+		_, err := berksfile.Parse("group :ci => [:test]\n")
+		Expect(err).To(MatchError(ContainSubstring("group 'ci' includes unknown group 'test'")))
+	})
+
+	It("should tolerate composition cycles", func() {
+		input := `
+group :a => [:b]
+group :b => [:a]
+
+group :b do
+  cookbook 'nginx'
+end
+`
+		b, err := berksfile.Parse(input)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(b.GetCookbooks("a")).To(HaveLen(1))
+		Expect(b.GetCookbook("nginx").Groups).To(ConsistOf("a", "b"))
+	})
 })
 
 var _ = Describe("Parse complete Berksfile", func() {
@@ -211,6 +362,24 @@ var _ = Describe("Parse error handling", func() {
 	)
 })
 
+var _ = Describe("Parse error positions", func() {
+	It("should report every error with its line and column", func() {
+		_, err := berksfile.Parse("source 'https://supermarket.chef.io'\ncookbook 'apt', 'bogus'\ncookbook 'nginx' 'ohai'\n\tcookbook 'ntp',\n")
+
+		var parseErrors berksfile.ParseErrors
+		Expect(errors.As(err, &parseErrors)).To(BeTrue())
+		Expect(parseErrors).To(HaveLen(3))
+		Expect(*parseErrors[0]).To(Equal(berksfile.ParseError{Line: 2, Column: 17, Message: "invalid version constraint: bogus", Text: "cookbook 'apt', 'bogus'"}))
+		Expect(*parseErrors[1]).To(Equal(berksfile.ParseError{Line: 3, Column: 18, Message: "syntax error: unexpected 'ohai'", Text: "cookbook 'nginx' 'ohai'"}))
+		Expect(*parseErrors[2]).To(Equal(berksfile.ParseError{Line: 4, Column: 17, Message: "syntax error: unexpected end of line", Text: "\tcookbook 'ntp',"}))
+	})
+
+	It("should show the offending line with a caret", func() {
+		_, err := berksfile.Parse("\tcookbook 'apt', 'bogus'")
+		Expect(err).To(MatchError("parse error at line 1, column 18: invalid version constraint: bogus\n\tcookbook 'apt', 'bogus'\n\t                ^"))
+	})
+})
+
 // Tests converted from berksfileparser_test.go
 var _ = Describe("Parse Berksfile edge cases", func() {
 	It("should parse empty input", func() {
@@ -547,3 +716,119 @@ cookbook 'test', path: 'test/fixtures/cookbook'
 		Expect(deps[0]).To(Equal("test"))
 	})
 })
+
+var _ = Describe("ApplyGroupSources", func() {
+	input := `
+source 'https://supermarket.chef.io'
+
+cookbook 'nginx'
+cookbook 'pinned', git: 'https://github.com/example/pinned.git'
+
+group :internal do
+  cookbook 'company-base'
+  cookbook 'company-git', git: 'https://github.com/example/company-git.git'
+end
+
+group :test, :internal do
+  cookbook 'company-test'
+end
+`
+
+	It("should assign group sources to cookbooks without explicit sources", func() {
+		b, err := berksfile.Parse(input)
+		Expect(err).NotTo(HaveOccurred())
+
+		cookbooks := berksfile.ApplyGroupSources(b.Cookbooks, map[string]string{
+			"internal": "https://supermarket.internal.example.com",
+		})
+		Expect(cookbooks).To(HaveLen(len(b.Cookbooks)))
+
+		byName := make(map[string]*berksfile.CookbookDef)
+		for _, cb := range cookbooks {
+			byName[cb.Name] = cb
+		}
+
+		Expect(byName["company-base"].Source).NotTo(BeNil())
+		Expect(byName["company-base"].Source.Type).To(Equal("supermarket"))
+		Expect(byName["company-base"].Source.URL).To(Equal("https://supermarket.internal.example.com"))
+		Expect(byName["company-test"].Source.URL).To(Equal("https://supermarket.internal.example.com"))
+		Expect(byName["company-git"].Source.Type).To(Equal("git"))
+		Expect(byName["pinned"].Source.Type).To(Equal("git"))
+		Expect(byName["nginx"].Source == nil || byName["nginx"].Source.Type == "").To(BeTrue())
+
+		// The parsed Berksfile is left untouched
+		original := b.GetCookbook("company-base")
+		Expect(original.Source == nil || original.Source.Type == "").To(BeTrue())
+	})
+
+	It("should return cookbooks unchanged without a mapping", func() {
+		b, err := berksfile.Parse(input)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(berksfile.ApplyGroupSources(b.Cookbooks, nil)).To(Equal(b.Cookbooks))
+	})
+})
+
+var _ = Describe("ReplaceCookbook", func() {
+	It("should replace a cookbook keeping indentation, quotes and comments", func() {
+		input := "source 'https://supermarket.chef.io'\n\ngroup :test do\n  cookbook \"old_cb\", \"~> 1.0\" # pinned\nend\n"
+		output, found := berksfile.ReplaceCookbook(input, "old_cb", "new_cb", "~> 2.1")
+		Expect(found).To(BeTrue())
+		Expect(output).To(Equal("source 'https://supermarket.chef.io'\n\ngroup :test do\n  cookbook \"new_cb\", \"~> 2.1\" # pinned\nend\n"))
+
+		b, err := berksfile.Parse(output)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(b.GetCookbook("new_cb").Groups).To(ConsistOf("test"))
+	})
+
+	It("should remove the old cookbook when the replacement is already declared", func() {
+		input := "cookbook 'old_cb'\ncookbook 'new_cb', '~> 3.0'\n"
+		output, found := berksfile.ReplaceCookbook(input, "old_cb", "new_cb", "~> 3.2")
+		Expect(found).To(BeTrue())
+		Expect(output).To(Equal("cookbook 'new_cb', '~> 3.0'\n"))
+	})
+
+	It("should report cookbooks that are not declared", func() {
+		input := "cookbook 'other'\n"
+		output, found := berksfile.ReplaceCookbook(input, "old_cb", "new_cb", "")
+		Expect(found).To(BeFalse())
+		Expect(output).To(Equal(input))
+	})
+})
+
+var _ = Describe("Overrides", func() {
+	It("should parse overrides apart from the cookbooks", func() {
+		b, err := berksfile.Parse(`
+cookbook 'nginx', '~> 12.0'
+override 'apt', '= 7.4.0'
+override 'base', path: './hotfix/base'
+`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(b.Cookbooks).To(HaveLen(1))
+		Expect(b.Overrides).To(HaveLen(2))
+		Expect(b.Overrides[0].Name).To(Equal("apt"))
+		Expect(b.Overrides[0].Constraint.String()).To(Equal("= 7.4.0"))
+		Expect(b.Overrides[0].Line).To(Equal(3))
+		Expect(b.Overrides[1].Source.Type).To(Equal("path"))
+	})
+
+	It("should require a version constraint or a source", func() {
+		_, err := berksfile.Parse("override 'apt'\n")
+		Expect(err).To(MatchError(ContainSubstring("override 'apt' needs a version constraint or a source")))
+	})
+
+	It("should reject conflicting overrides", func() {
+		_, err := berksfile.Parse("override 'apt', '= 7.4.0'\noverride 'apt', '= 7.5.0'\n")
+		var duplicate *berksfile.DuplicateCookbookError
+		Expect(errors.As(err, &duplicate)).To(BeTrue())
+		Expect(duplicate.Duplicate.Line).To(Equal(2))
+	})
+})
+
+var _ = Describe("Ignored cookbooks", func() {
+	It("should parse ignore and exclude", func() {
+		b, err := berksfile.Parse("cookbook 'app'\nignore 'chef-client', 'audit'\nexclude 'users'\n")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(b.Cookbooks).To(HaveLen(1))
+		Expect(b.Ignored).To(Equal([]string{"chef-client", "audit", "users"}))
+	})
+})
@@ -0,0 +1,581 @@
+package berksfile
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"runtime"
+	"strings"
+	"unicode"
+)
+
+// This file implements a tiny interpreter for the Ruby conditionals commonly
+// found in Berksfiles shared with Ruby Berkshelf, e.g.
+//
+//	if ENV['CI']
+//	  cookbook 'ci-helper'
+//	end
+//
+//	cookbook 'windows' if RUBY_PLATFORM =~ /mingw|mswin/
+//
+// Supported expressions are string, regex, true/false/nil literals, ENV lookups
+// (ENV['X'], ENV.fetch, ENV.key?), RUBY_PLATFORM, RbConfig::CONFIG,
+// Gem.win_platform?, File.exist?, a handful of String predicates, comparisons
+// (==, !=, =~, !~) and boolean operators (!, not, &&, and, ||, or).
+
+// rubyValue is an evaluated expression: nil, bool, rubyString, a regexp
+// pattern (plain Go string) or one of the rubyObject receivers below
+type rubyValue any
+
+// rubyString distinguishes string values from regexp patterns
+type rubyString string
+
+// rubyObject identifies the built-in constants that accept method calls
+type rubyObject string
+
+const (
+	objENV      rubyObject = "ENV"
+	objRbConfig rubyObject = "RbConfig::CONFIG"
+	objGem      rubyObject = "Gem"
+	objFile     rubyObject = "File"
+)
+
+// EvalEnv controls whether conditions read environment variables. When it is
+// off, conditionals on ENV are skipped like other dynamic code, so the
+// Berksfile declares the same cookbooks wherever it is read.
+var EvalEnv = true
+
+// EvalCondition evaluates a Ruby conditional expression using Ruby truthiness
+// (only nil and false are false)
+func EvalCondition(expr string) (bool, error) {
+	return evalCondition(expr, ParseOptions{Env: true, Files: true})
+}
+
+// evalCondition is EvalCondition reading only what options allow; ENV is
+// read only when EvalEnv is on as well
+func evalCondition(expr string, options ParseOptions) (bool, error) {
+	options.Env = options.Env && EvalEnv
+	val, err := evalExpression(expr, options)
+	if err != nil {
+		return false, err
+	}
+	return truthy(val), nil
+}
+
+// evalExpression evaluates a Ruby expression, reading ENV and files only
+// when options allow
+func evalExpression(expr string, options ParseOptions) (rubyValue, error) {
+	tokens, err := tokenizeCondition(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &condParser{tokens: tokens, options: options}
+	val, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q in condition", p.tokens[p.pos].text)
+	}
+	return val, nil
+}
+
+func truthy(v rubyValue) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	default:
+		return true
+	}
+}
+
+// rubyPlatform approximates RUBY_PLATFORM for the current OS and architecture
+func rubyPlatform() string {
+	arch := map[string]string{"amd64": "x86_64", "arm64": "arm64", "386": "i386"}[runtime.GOARCH]
+	if arch == "" {
+		arch = runtime.GOARCH
+	}
+	switch runtime.GOOS {
+	case "windows":
+		if arch == "x86_64" {
+			return "x64-mingw32"
+		}
+		return arch + "-mingw32"
+	case "darwin":
+		return arch + "-darwin"
+	default:
+		return arch + "-" + runtime.GOOS
+	}
+}
+
+// rbConfig returns the subset of RbConfig::CONFIG used for platform checks
+func rbConfig() map[string]string {
+	hostOS := runtime.GOOS
+	if hostOS == "windows" {
+		hostOS = "mingw32"
+	}
+	return map[string]string{
+		"host_os":   hostOS,
+		"host_cpu":  strings.SplitN(rubyPlatform(), "-", 2)[0],
+		"target_os": hostOS,
+	}
+}
+
+// =============================================================================
+// TOKENIZER
+// =============================================================================
+
+type condTokenKind int
+
+const (
+	tokString condTokenKind = iota
+	tokRegex
+	tokIdent
+	tokOp
+)
+
+type condToken struct {
+	kind condTokenKind
+	text string
+}
+
+func tokenizeCondition(expr string) ([]condToken, error) {
+	var tokens []condToken
+	runes := []rune(expr)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '\'' || r == '"':
+			var sb strings.Builder
+			j := i + 1
+			for ; j < len(runes) && runes[j] != r; j++ {
+				if runes[j] == '\\' && j+1 < len(runes) {
+					j++
+				}
+				sb.WriteRune(runes[j])
+			}
+			if j >= len(runes) {
+				return nil, fmt.Errorf("unterminated string in condition")
+			}
+			tokens = append(tokens, condToken{tokString, sb.String()})
+			i = j + 1
+		case r == '/':
+			var sb strings.Builder
+			j := i + 1
+			for ; j < len(runes) && runes[j] != '/'; j++ {
+				if runes[j] == '\\' && j+1 < len(runes) {
+					sb.WriteRune(runes[j])
+					j++
+				}
+				sb.WriteRune(runes[j])
+			}
+			if j >= len(runes) {
+				return nil, fmt.Errorf("unterminated regexp in condition")
+			}
+			pattern := sb.String()
+			j++
+			for j < len(runes) && strings.ContainsRune("imx", runes[j]) {
+				pattern = "(?" + string(runes[j]) + ")" + pattern
+				j++
+			}
+			tokens = append(tokens, condToken{tokRegex, pattern})
+			i = j
+		case unicode.IsLetter(r) || r == '_':
+			j := i
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '_' ||
+				(runes[j] == ':' && j+1 < len(runes) && runes[j+1] == ':')) {
+				if runes[j] == ':' {
+					j++
+				}
+				j++
+			}
+			if j < len(runes) && (runes[j] == '?' || runes[j] == '!') && (j+1 >= len(runes) || runes[j+1] != '=') {
+				j++
+			}
+			tokens = append(tokens, condToken{tokIdent, string(runes[i:j])})
+			i = j
+		default:
+			if i+1 < len(runes) {
+				two := string(runes[i : i+2])
+				switch two {
+				case "==", "!=", "=~", "!~", "&&", "||":
+					tokens = append(tokens, condToken{tokOp, two})
+					i += 2
+					continue
+				}
+			}
+			if strings.ContainsRune("!()[].,", r) {
+				tokens = append(tokens, condToken{tokOp, string(r)})
+				i++
+				continue
+			}
+			return nil, fmt.Errorf("unsupported character %q in condition", r)
+		}
+	}
+
+	return tokens, nil
+}
+
+// =============================================================================
+// PARSER / EVALUATOR
+// =============================================================================
+
+type condParser struct {
+	tokens  []condToken
+	pos     int
+	options ParseOptions // whether ENV and files can be read
+}
+
+func (p *condParser) peek() (condToken, bool) {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos], true
+	}
+	return condToken{}, false
+}
+
+// accept consumes the next token if it is an operator or identifier with the given text
+func (p *condParser) accept(text string) bool {
+	if tok, ok := p.peek(); ok && tok.kind != tokString && tok.kind != tokRegex && tok.text == text {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *condParser) expect(text string) error {
+	if !p.accept(text) {
+		if tok, ok := p.peek(); ok {
+			return fmt.Errorf("expected %q but found %q in condition", text, tok.text)
+		}
+		return fmt.Errorf("expected %q at end of condition", text)
+	}
+	return nil
+}
+
+func (p *condParser) parseOr() (rubyValue, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("||") || p.accept("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		if !truthy(left) {
+			left = right
+		}
+	}
+	return left, nil
+}
+
+func (p *condParser) parseAnd() (rubyValue, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("&&") || p.accept("and") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		if truthy(left) {
+			left = right
+		}
+	}
+	return left, nil
+}
+
+func (p *condParser) parseNot() (rubyValue, error) {
+	if p.accept("!") || p.accept("not") {
+		val, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return !truthy(val), nil
+	}
+	return p.parseComparison()
+}
+
+func (p *condParser) parseComparison() (rubyValue, error) {
+	left, err := p.parsePostfix()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, op := range []string{"==", "!=", "=~", "!~"} {
+		if !p.accept(op) {
+			continue
+		}
+		right, err := p.parsePostfix()
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case "==":
+			return left == right, nil
+		case "!=":
+			return left != right, nil
+		default:
+			matched, err := regexMatch(left, right)
+			if err != nil {
+				return nil, err
+			}
+			if op == "!~" {
+				return !matched, nil
+			}
+			return matched, nil
+		}
+	}
+	return left, nil
+}
+
+func regexMatch(left, right rubyValue) (bool, error) {
+	pattern, ok := right.(string)
+	if !ok {
+		pattern, ok = left.(string)
+		left = right
+	}
+	if !ok {
+		return false, fmt.Errorf("=~ requires a regexp")
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return false, fmt.Errorf("invalid regexp /%s/: %w", pattern, err)
+	}
+	switch s := left.(type) {
+	case nil:
+		return false, nil
+	case rubyString:
+		return re.MatchString(string(s)), nil
+	default:
+		return false, fmt.Errorf("=~ requires a string operand")
+	}
+}
+
+func (p *condParser) parsePostfix() (rubyValue, error) {
+	val, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		switch {
+		case p.accept("["):
+			key, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			if val, err = index(val, key); err != nil {
+				return nil, err
+			}
+		case p.accept("."):
+			tok, ok := p.peek()
+			if !ok || tok.kind != tokIdent {
+				return nil, fmt.Errorf("expected method name after '.' in condition")
+			}
+			p.pos++
+			args, err := p.parseArgs()
+			if err != nil {
+				return nil, err
+			}
+			if val, err = call(val, tok.text, args); err != nil {
+				return nil, err
+			}
+		default:
+			return val, nil
+		}
+	}
+}
+
+func (p *condParser) parseArgs() ([]rubyValue, error) {
+	if !p.accept("(") {
+		return nil, nil
+	}
+	var args []rubyValue
+	if p.accept(")") {
+		return args, nil
+	}
+	for {
+		arg, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if p.accept(")") {
+			return args, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *condParser) parsePrimary() (rubyValue, error) {
+	tok, ok := p.peek()
+	if !ok {
+		return nil, fmt.Errorf("unexpected end of condition")
+	}
+	p.pos++
+
+	switch tok.kind {
+	case tokString:
+		return rubyString(tok.text), nil
+	case tokRegex:
+		return tok.text, nil
+	case tokOp:
+		if tok.text == "(" {
+			val, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return val, p.expect(")")
+		}
+		return nil, fmt.Errorf("unexpected %q in condition", tok.text)
+	}
+
+	switch tok.text {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "nil":
+		return nil, nil
+	case "RUBY_PLATFORM":
+		return rubyString(rubyPlatform()), nil
+	case "ENV":
+		if !p.options.Env {
+			return nil, fmt.Errorf("ENV is not evaluated")
+		}
+		return objENV, nil
+	case "File":
+		if !p.options.Files {
+			return nil, fmt.Errorf("File is not evaluated")
+		}
+		return objFile, nil
+	case "RbConfig::CONFIG", "Gem":
+		return rubyObject(tok.text), nil
+	}
+	return nil, fmt.Errorf("unsupported identifier %q in condition", tok.text)
+}
+
+func index(receiver, key rubyValue) (rubyValue, error) {
+	k, ok := key.(rubyString)
+	if !ok {
+		return nil, fmt.Errorf("index must be a string")
+	}
+	switch receiver {
+	case objENV:
+		if v, ok := os.LookupEnv(string(k)); ok {
+			return rubyString(v), nil
+		}
+		return nil, nil
+	case objRbConfig:
+		if v, ok := rbConfig()[string(k)]; ok {
+			return rubyString(v), nil
+		}
+		return nil, nil
+	}
+	return nil, fmt.Errorf("cannot index %v in condition", receiver)
+}
+
+func call(receiver rubyValue, method string, args []rubyValue) (rubyValue, error) {
+	stringArg := func(i int) (string, error) {
+		if i >= len(args) {
+			return "", fmt.Errorf("%s: missing argument", method)
+		}
+		s, ok := args[i].(rubyString)
+		if !ok {
+			return "", fmt.Errorf("%s: argument must be a string", method)
+		}
+		return string(s), nil
+	}
+
+	if method == "nil?" {
+		return receiver == nil, nil
+	}
+
+	switch recv := receiver.(type) {
+	case rubyObject:
+		switch {
+		case recv == objENV && method == "fetch":
+			key, err := stringArg(0)
+			if err != nil {
+				return nil, err
+			}
+			if v, ok := os.LookupEnv(key); ok {
+				return rubyString(v), nil
+			}
+			if len(args) > 1 {
+				return args[1], nil
+			}
+			return nil, fmt.Errorf("ENV.fetch: key not found: %q", key)
+		case recv == objENV && (method == "key?" || method == "has_key?" || method == "include?"):
+			key, err := stringArg(0)
+			if err != nil {
+				return nil, err
+			}
+			_, ok := os.LookupEnv(key)
+			return ok, nil
+		case recv == objGem && method == "win_platform?":
+			return runtime.GOOS == "windows", nil
+		case recv == objFile && (method == "exist?" || method == "exists?" || method == "file?" || method == "directory?"):
+			path, err := stringArg(0)
+			if err != nil {
+				return nil, err
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				return false, nil
+			}
+			switch method {
+			case "file?":
+				return info.Mode().IsRegular(), nil
+			case "directory?":
+				return info.IsDir(), nil
+			}
+			return true, nil
+		}
+	case rubyString:
+		s := string(recv)
+		switch method {
+		case "to_s", "strip", "downcase", "upcase":
+			return rubyString(map[string]func(string) string{
+				"to_s":     func(s string) string { return s },
+				"strip":    strings.TrimSpace,
+				"downcase": strings.ToLower,
+				"upcase":   strings.ToUpper,
+			}[method](s)), nil
+		case "empty?":
+			return s == "", nil
+		case "include?", "start_with?", "end_with?":
+			arg, err := stringArg(0)
+			if err != nil {
+				return nil, err
+			}
+			switch method {
+			case "include?":
+				return strings.Contains(s, arg), nil
+			case "start_with?":
+				return strings.HasPrefix(s, arg), nil
+			default:
+				return strings.HasSuffix(s, arg), nil
+			}
+		}
+	case nil:
+		if method == "to_s" {
+			return rubyString(""), nil
+		}
+	}
+
+	return nil, fmt.Errorf("unsupported method %q in condition", method)
+}
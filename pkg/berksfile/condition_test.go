@@ -0,0 +1,235 @@
+package berksfile_test
+
+import (
+	"os"
+	"runtime"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berksfile"
+)
+
+func setEnv(key, value string) {
+	old, had := os.LookupEnv(key)
+	Expect(os.Setenv(key, value)).To(Succeed())
+	DeferCleanup(func() {
+		if had {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+}
+
+func unsetEnv(key string) {
+	old, had := os.LookupEnv(key)
+	Expect(os.Unsetenv(key)).To(Succeed())
+	DeferCleanup(func() {
+		if had {
+			os.Setenv(key, old)
+		}
+	})
+}
+
+var _ = Describe("EvalCondition", func() {
+	BeforeEach(func() {
+		setEnv("BERKS_COND_SET", "yes")
+		unsetEnv("BERKS_COND_UNSET")
+	})
+
+	DescribeTable("should evaluate supported expressions",
+		func(expr string, expected bool) {
+			result, err := berksfile.EvalCondition(expr)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(expected))
+		},
+		Entry("set ENV var", `ENV['BERKS_COND_SET']`, true),
+		Entry("unset ENV var", `ENV["BERKS_COND_UNSET"]`, false),
+		Entry("ENV equality", `ENV['BERKS_COND_SET'] == 'yes'`, true),
+		Entry("ENV inequality", `ENV['BERKS_COND_SET'] != 'yes'`, false),
+		Entry("ENV.fetch with default", `ENV.fetch('BERKS_COND_UNSET', 'x') == 'x'`, true),
+		Entry("ENV.key?", `ENV.key?('BERKS_COND_SET')`, true),
+		Entry("negation", `!ENV['BERKS_COND_UNSET']`, true),
+		Entry("nil?", `ENV['BERKS_COND_UNSET'].nil?`, true),
+		Entry("and/or", `ENV['BERKS_COND_UNSET'] || (ENV['BERKS_COND_SET'] && true)`, true),
+		Entry("keyword operators", `not ENV['BERKS_COND_SET'] or false`, false),
+		Entry("regex match", `ENV['BERKS_COND_SET'] =~ /^Y/i`, true),
+		Entry("regex no match", `ENV['BERKS_COND_SET'] !~ /no/`, true),
+		Entry("regex on nil", `ENV['BERKS_COND_UNSET'] =~ /x/`, false),
+		Entry("string predicate", `ENV.fetch('BERKS_COND_SET', '').start_with?('y')`, true),
+		Entry("platform", `RUBY_PLATFORM =~ /darwin/`, runtime.GOOS == "darwin"),
+		Entry("host_os", `RbConfig::CONFIG['host_os'] =~ /mswin|mingw/`, runtime.GOOS == "windows"),
+		Entry("Gem.win_platform?", `Gem.win_platform?`, runtime.GOOS == "windows"),
+		Entry("File.exist?", `File.exist?('condition_test.go')`, true),
+	)
+
+	DescribeTable("should reject unsupported expressions",
+		func(expr string) {
+			_, err := berksfile.EvalCondition(expr)
+			Expect(err).To(HaveOccurred())
+		},
+		Entry("arbitrary method", `system('rm -rf /')`),
+		Entry("unterminated string", `ENV['CI`),
+		Entry("dangling operator", `ENV['CI'] ==`),
+		Entry("ENV.fetch missing key", `ENV.fetch('BERKS_COND_UNSET')`),
+	)
+})
+
+var _ = Describe("Parse Ruby conditionals", func() {
+	BeforeEach(func() {
+		setEnv("BERKS_COND_SET", "yes")
+		unsetEnv("BERKS_COND_UNSET")
+	})
+
+	names := func(b *berksfile.Berksfile) []string {
+		var result []string
+		for _, cb := range b.Cookbooks {
+			result = append(result, cb.Name)
+		}
+		return result
+	}
+
+	It("should keep only the taken branch", func() {
+		b, err := berksfile.Parse(`
+source 'https://supermarket.chef.io'
+
+if ENV['BERKS_COND_UNSET']
+  cookbook 'skipped'
+elsif ENV['BERKS_COND_SET'] == 'yes'
+  cookbook 'elsif-branch'
+else
+  cookbook 'else-branch'
+end
+
+unless ENV['BERKS_COND_SET']
+  cookbook 'unless-skipped'
+end
+`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(names(b)).To(Equal([]string{"elsif-branch"}))
+	})
+
+	It("should handle trailing modifiers", func() {
+		b, err := berksfile.Parse(`
+cookbook 'ci', '~> 1.0' if ENV['BERKS_COND_SET'] # only in CI
+cookbook 'local' unless ENV['BERKS_COND_SET']
+cookbook 'plain', git: 'https://example.com/if unless.git'
+`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(names(b)).To(Equal([]string{"ci", "plain"}))
+		Expect(b.GetCookbook("ci").Constraint.String()).To(Equal("~> 1.0"))
+	})
+
+	It("should handle groups inside and around conditionals", func() {
+		b, err := berksfile.Parse(`
+if ENV['BERKS_COND_UNSET']
+  group :skipped do
+    cookbook 'skipped'
+  end
+end
+
+group :test do
+  cookbook 'always'
+  if ENV['BERKS_COND_SET']
+    cookbook 'conditional'
+  end
+end
+`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(names(b)).To(ConsistOf("always", "conditional"))
+		Expect(b.HasGroup("skipped")).To(BeFalse())
+		Expect(b.Groups["test"]).To(HaveLen(2))
+	})
+
+	It("should skip conditionals it cannot evaluate with a warning", func() {
+		b, err := berksfile.Parse("cookbook 'a'\nif `whoami` == 'root'\n  cookbook 'b'\nelse\n  cookbook 'c'\nend\ncookbook 'd' if ENV.fetch('BERKS_COND_SET').to_i > 1\n")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(names(b)).To(Equal([]string{"a"}))
+		Expect(b.Warnings).To(HaveLen(2))
+		Expect(b.Warnings[0]).To(HavePrefix("line 2: skipped `if `whoami` == 'root'` and its branches: "))
+		Expect(b.Warnings[1]).To(HavePrefix("line 7: skipped `cookbook 'd' if ENV.fetch('BERKS_COND_SET').to_i > 1`: "))
+	})
+
+	It("should skip Ruby that is not a Berksfile directive", func() {
+		b, err := berksfile.Parse(`
+require 'json'
+chef_version = '18'
+
+def helper(name)
+  if name
+    name
+  end
+end
+
+%w(apt yum).each do |name|
+  cookbook name
+end
+
+cookbook 'nginx'
+`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(names(b)).To(Equal([]string{"nginx"}))
+		Expect(b.Warnings).To(Equal([]string{
+			"line 2: skipped `require 'json'`, which is not a Berksfile directive",
+			"line 3: skipped `chef_version = '18'`, which is not a Berksfile directive",
+			"line 5: skipped `def helper(name)` and its block, which is not a Berksfile directive",
+			"line 11: skipped `%w(apt yum).each do |name|` and its block, which is not a Berksfile directive",
+		}))
+	})
+
+	It("should leave the options continuing a statement to the parser", func() {
+		// Skipping them would drop the cookbook's source
+		_, err := berksfile.Parse("cookbook 'postgresql',\n  git: 'https://github.com/sous-chefs/postgresql.git'\n")
+		Expect(err).To(MatchError(ContainSubstring("line 1, column 23")))
+	})
+
+	It("should skip ENV conditionals when EvalEnv is off", func() {
+		berksfile.EvalEnv = false
+		DeferCleanup(func() { berksfile.EvalEnv = true })
+
+		b, err := berksfile.Parse("if ENV['BERKS_COND_SET']\n  cookbook 'ci'\nend\ncookbook 'windows' if Gem.win_platform? || true\n")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(names(b)).To(Equal([]string{"windows"}))
+		Expect(b.Warnings).To(ConsistOf("line 1: skipped `if ENV['BERKS_COND_SET']` and its branches: ENV is not evaluated"))
+	})
+
+	It("should report an unterminated conditional", func() {
+		_, err := berksfile.Parse("if ENV['BERKS_COND_SET']\n  cookbook 'a'\n")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("expected 'end'"))
+	})
+})
+
+var _ = Describe("Berksfile.Fingerprint", func() {
+	const content = `source 'https://supermarket.example.com'
+cookbook 'app', '~> 1.0'
+cookbook 'ci-helper' if ENV['BERKS_FINGERPRINT_CI']
+`
+
+	fingerprint := func() string {
+		b, err := berksfile.Parse(content)
+		Expect(err).NotTo(HaveOccurred())
+		data, err := b.Fingerprint()
+		Expect(err).NotTo(HaveOccurred())
+		return string(data)
+	}
+
+	It("should differ where conditionals declare other cookbooks", func() {
+		unsetEnv("BERKS_FINGERPRINT_CI")
+		local := fingerprint()
+		Expect(fingerprint()).To(Equal(local))
+
+		setEnv("BERKS_FINGERPRINT_CI", "true")
+		Expect(fingerprint()).NotTo(Equal(local))
+	})
+
+	It("should not depend on how the Berksfile is laid out", func() {
+		unsetEnv("BERKS_FINGERPRINT_CI")
+		b, err := berksfile.Parse("# comment\nsource \"https://supermarket.example.com\"\n\ncookbook 'app',   '~> 1.0'\n")
+		Expect(err).NotTo(HaveOccurred())
+		data, err := b.Fingerprint()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(Equal(fingerprint()))
+	})
+})
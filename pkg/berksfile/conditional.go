@@ -0,0 +1,248 @@
+package berksfile
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// blockFrame tracks an open if/unless or do block while evaluating conditionals
+type blockFrame struct {
+	conditional bool // if/unless block rather than a do block
+	active      bool // lines in the current branch are kept
+	taken       bool // a branch of this conditional has already matched
+	parent      bool // the enclosing block is active
+}
+
+// evaluateConditionals evaluates Ruby if/unless/elsif/else blocks and trailing
+// `if`/`unless` modifiers, blanking the lines of branches that are not taken.
+// Conditionals it cannot evaluate are skipped with all their branches, and so
+// is Ruby code that is not a Berksfile directive, such as a def or an .each
+// loop; a warning reports each skipped section. Line numbers are preserved so
+// parse errors still point at the original source.
+func evaluateConditionals(input string, options ParseOptions) (string, []string, error) {
+	lines := strings.Split(input, "\n")
+	var stack []*blockFrame
+	var warnings []string
+
+	active := func() bool {
+		return len(stack) == 0 || stack[len(stack)-1].active
+	}
+	warnf := func(format string, args ...any) {
+		warnings = append(warnings, fmt.Sprintf(format, args...))
+	}
+
+	continued := false
+	for i, line := range lines {
+		code := strings.TrimSpace(stripComment(line))
+		keyword, rest := splitKeyword(code)
+
+		switch keyword {
+		case "if", "unless":
+			frame := &blockFrame{conditional: true, parent: active()}
+			if frame.parent {
+				cond, err := evalLineCondition(i, rest, keyword == "unless", options)
+				if err != nil {
+					if !errors.As(err, new(*unsupportedError)) {
+						return "", nil, err
+					}
+					// None of the branches can be chosen, so none is kept
+					warnf("line %d: skipped `%s` and its branches: %v", i+1, code, err)
+					cond, frame.parent = false, false
+				}
+				frame.active, frame.taken = cond, cond
+			}
+			stack = append(stack, frame)
+			lines[i] = ""
+			continue
+		case "elsif", "else":
+			if len(stack) == 0 || !stack[len(stack)-1].conditional {
+				return "", nil, fmt.Errorf("parse error at line %d: unexpected '%s'", i+1, keyword)
+			}
+			frame := stack[len(stack)-1]
+			frame.active = false
+			if frame.parent && !frame.taken {
+				cond := true
+				if keyword == "elsif" {
+					var err error
+					if cond, err = evalLineCondition(i, rest, false, options); err != nil {
+						if !errors.As(err, new(*unsupportedError)) {
+							return "", nil, err
+						}
+						warnf("line %d: skipped `%s` and the branches after it: %v", i+1, code, err)
+						cond, frame.parent = false, false
+					}
+				}
+				frame.active, frame.taken = cond, cond
+			}
+			lines[i] = ""
+			continue
+		case "end":
+			if len(stack) > 0 {
+				frame := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				if frame.conditional || !frame.parent {
+					lines[i] = ""
+				}
+				continue
+			}
+		}
+
+		if !active() {
+			lines[i] = ""
+			if opensBlock(code) {
+				stack = append(stack, &blockFrame{})
+			}
+			continue
+		}
+
+		// Ruby that is not a directive, with any block it opens, is skipped;
+		// the lines continuing a statement are left to the parser
+		if code != "" && !continued && !isDirective(code) {
+			lines[i] = ""
+			if opensBlock(code) {
+				warnf("line %d: skipped `%s` and its block, which is not a Berksfile directive", i+1, code)
+				stack = append(stack, &blockFrame{})
+			} else {
+				warnf("line %d: skipped `%s`, which is not a Berksfile directive", i+1, code)
+			}
+			continue
+		}
+		if code != "" {
+			continued = strings.HasSuffix(code, ",") || strings.HasSuffix(code, "\\")
+		}
+
+		// Trailing modifier: `cookbook 'x' if ENV['CI']`
+		if stmt, cond, negate, ok := splitModifier(line); ok {
+			keep, err := evalLineCondition(i, cond, negate, options)
+			if err != nil {
+				if !errors.As(err, new(*unsupportedError)) {
+					return "", nil, err
+				}
+				warnf("line %d: skipped `%s`: %v", i+1, code, err)
+				keep = false
+			}
+			if keep {
+				lines[i] = stmt
+			} else {
+				lines[i] = ""
+			}
+		}
+
+		if opensBlock(code) {
+			stack = append(stack, &blockFrame{active: true, parent: true})
+		}
+	}
+
+	for _, frame := range stack {
+		if frame.conditional {
+			return "", nil, fmt.Errorf("parse error at line %d: unterminated conditional, expected 'end'", len(lines))
+		}
+	}
+
+	return strings.Join(lines, "\n"), warnings, nil
+}
+
+// unsupportedError is a condition that cannot be evaluated here
+type unsupportedError struct {
+	err error
+}
+
+func (e *unsupportedError) Error() string {
+	return e.err.Error()
+}
+
+func evalLineCondition(lineIndex int, expr string, negate bool, options ParseOptions) (bool, error) {
+	expr = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(expr), " then"))
+	if expr == "" {
+		return false, fmt.Errorf("parse error at line %d: missing condition", lineIndex+1)
+	}
+	cond, err := evalCondition(expr, options)
+	if err != nil {
+		return false, &unsupportedError{err}
+	}
+	return cond != negate, nil
+}
+
+// isDirective reports whether a line starts with a Berksfile directive
+func isDirective(code string) bool {
+	word := strings.FieldsFunc(code, func(r rune) bool {
+		return r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(word) == 0 || !strings.HasPrefix(code, word[0]) {
+		return false
+	}
+	_, ok := keywords[strings.ToLower(word[0])]
+	return ok
+}
+
+// splitKeyword returns the leading word of a line and the remainder
+func splitKeyword(code string) (string, string) {
+	word, rest, _ := strings.Cut(code, " ")
+	if word == "if" || word == "unless" || word == "elsif" {
+		return word, rest
+	}
+	if code == "else" || code == "end" {
+		return code, ""
+	}
+	return "", code
+}
+
+// opensBlock reports whether a line opens a block closed by end: a do
+// block (e.g. a group) or a def, case, begin or loop
+func opensBlock(code string) bool {
+	if strings.HasSuffix(code, " end") || strings.HasSuffix(code, ";end") {
+		return false
+	}
+	if code == "do" || strings.HasSuffix(code, " do") || strings.Contains(code, " do |") {
+		return true
+	}
+	word, _, _ := strings.Cut(code, " ")
+	switch word {
+	case "def", "case", "begin", "while", "until", "for", "class", "module":
+		return true
+	}
+	return false
+}
+
+// splitModifier splits a statement with a trailing `if`/`unless` modifier
+func splitModifier(line string) (stmt, cond string, negate, ok bool) {
+	code := stripComment(line)
+	for _, kw := range []string{" if ", " unless "} {
+		if idx := indexOutsideQuotes(code, kw); idx > 0 {
+			return code[:idx], code[idx+len(kw):], kw == " unless ", true
+		}
+	}
+	return "", "", false, false
+}
+
+// stripComment removes a trailing # comment that is not inside a string
+func stripComment(line string) string {
+	if idx := indexOutsideQuotes(line, "#"); idx >= 0 {
+		return line[:idx]
+	}
+	return line
+}
+
+// indexOutsideQuotes returns the index of the first occurrence of substr that
+// is not inside a single- or double-quoted string, or -1
+func indexOutsideQuotes(s, substr string) int {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case strings.HasPrefix(s[i:], substr):
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,125 @@
+package berksfile
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+
+	berrors "github.com/bdwyertech/go-berkshelf/pkg/errors"
+)
+
+// DuplicateCookbookError reports a cookbook declared more than once with a
+// different constraint or source
+type DuplicateCookbookError struct {
+	Name string
+	// First and Duplicate are the conflicting declarations
+	First     *CookbookDef
+	Duplicate *CookbookDef
+}
+
+// Error implements the error interface
+func (e *DuplicateCookbookError) Error() string {
+	return fmt.Sprintf("cookbook '%s' declared at line %d as %s conflicts with its declaration at line %d as %s",
+		e.Name, e.Duplicate.Line, describeDeclaration(e.Duplicate), e.First.Line, describeDeclaration(e.First))
+}
+
+// ErrorType reports duplicates as parsing errors
+func (e *DuplicateCookbookError) ErrorType() berrors.ErrorType {
+	return berrors.ErrorTypeParsing
+}
+
+// describeDeclaration renders a cookbook's constraint and source for errors
+func describeDeclaration(cb *CookbookDef) string {
+	desc := "'" + cb.Constraint.String() + "'"
+	if source := cb.Source.String(); source != "" {
+		desc += " from " + source
+	}
+	return desc
+}
+
+// mergeDuplicates folds re-declarations of a cookbook into its first
+// declaration, which then belongs to the groups of both, e.g. a cookbook
+// declared in both a test and an integration group. Re-declaring a cookbook
+// with a different constraint or source is a DuplicateCookbookError, and a
+// re-declaration in the same groups is reported as a warning.
+func (b *Berksfile) mergeDuplicates() error {
+	declared := make(map[string]*CookbookDef)
+	cookbooks := make([]*CookbookDef, 0, len(b.Cookbooks))
+	for _, cb := range b.Cookbooks {
+		first, ok := declared[cb.Name]
+		if !ok {
+			declared[cb.Name] = cb
+			cookbooks = append(cookbooks, cb)
+			continue
+		}
+
+		if first.Constraint.String() != cb.Constraint.String() || !reflect.DeepEqual(first.Source, cb.Source) {
+			return &DuplicateCookbookError{Name: cb.Name, First: first, Duplicate: cb}
+		}
+		if slices.Equal(first.Groups, cb.Groups) {
+			b.Warnings = append(b.Warnings, fmt.Sprintf("cookbook '%s' is declared again at line %d (first at line %d)", cb.Name, cb.Line, first.Line))
+			continue
+		}
+
+		// A declaration outside any group keeps the cookbook out of groups,
+		// so --except does not drop it
+		if len(first.Groups) == 0 || len(cb.Groups) == 0 {
+			first.Groups = []string{}
+			continue
+		}
+		for _, group := range cb.Groups {
+			if !slices.Contains(first.Groups, group) {
+				first.Groups = append(first.Groups, group)
+			}
+		}
+	}
+	if len(cookbooks) == len(b.Cookbooks) {
+		return nil
+	}
+
+	b.Cookbooks = cookbooks
+	for _, groupCookbooks := range b.Groups {
+		for i, cb := range groupCookbooks {
+			groupCookbooks[i] = declared[cb.Name]
+		}
+	}
+	return nil
+}
+
+// mergeOverrides drops repeated overrides of a cookbook. Overriding a
+// cookbook again with a different constraint or source is a
+// DuplicateCookbookError.
+func (b *Berksfile) mergeOverrides() error {
+	declared := make(map[string]*CookbookDef)
+	overrides := make([]*CookbookDef, 0, len(b.Overrides))
+	for _, override := range b.Overrides {
+		first, ok := declared[override.Name]
+		if !ok {
+			declared[override.Name] = override
+			overrides = append(overrides, override)
+			continue
+		}
+		if first.Constraint.String() != override.Constraint.String() || !reflect.DeepEqual(first.Source, override.Source) {
+			return &DuplicateCookbookError{Name: override.Name, First: first, Duplicate: override}
+		}
+		b.Warnings = append(b.Warnings, fmt.Sprintf("override of '%s' is declared again at line %d (first at line %d)", override.Name, override.Line, first.Line))
+	}
+	b.Overrides = overrides
+	return nil
+}
+
+// warnAliased warns about cookbooks declared under the metadata name of
+// another, which the resolver satisfies with the other cookbook
+func (b *Berksfile) warnAliased() {
+	aliases := make(map[string]*CookbookDef)
+	for _, cb := range b.Cookbooks {
+		if cb.MetadataName != "" {
+			aliases[cb.MetadataName] = cb
+		}
+	}
+	for _, cb := range b.Cookbooks {
+		if alias, ok := aliases[cb.Name]; ok {
+			b.Warnings = append(b.Warnings, fmt.Sprintf("cookbook '%s' at line %d is provided by '%s' (metadata_name '%s') at line %d", cb.Name, cb.Line, alias.Name, alias.MetadataName, alias.Line))
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package berksfile_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berksfile"
+)
+
+var _ = Describe("Duplicate cookbook declarations", func() {
+	It("should merge identical declarations in several groups", func() {
+		b, err := berksfile.Parse(`
+group :test do
+  cookbook 'nginx', '~> 12.0'
+end
+
+group :integration do
+  cookbook 'nginx', '~> 12.0'
+end
+`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(b.Cookbooks).To(HaveLen(1))
+		Expect(b.Cookbooks[0].Line).To(Equal(3))
+		Expect(b.Cookbooks[0].Groups).To(ConsistOf("test", "integration"))
+		Expect(b.Groups["test"]).To(ConsistOf(b.Cookbooks[0]))
+		Expect(b.Groups["integration"]).To(ConsistOf(b.Cookbooks[0]))
+		Expect(b.Warnings).To(BeEmpty())
+	})
+
+	It("should keep a cookbook also declared outside any group out of groups", func() {
+		b, err := berksfile.Parse("cookbook 'apt'\ngroup :test do\n  cookbook 'apt'\nend\n")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(b.Cookbooks).To(HaveLen(1))
+		Expect(b.Cookbooks[0].Groups).To(BeEmpty())
+		Expect(berksfile.FilterCookbooksByGroup(b.Cookbooks, nil, []string{"test"})).To(HaveLen(1))
+	})
+
+	It("should warn about a cookbook declared twice in the same place", func() {
+		b, err := berksfile.Parse("cookbook 'apt'\ncookbook 'apt'\n")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(b.Cookbooks).To(HaveLen(1))
+		Expect(b.Warnings).To(ConsistOf("cookbook 'apt' is declared again at line 2 (first at line 1)"))
+	})
+
+	It("should reject conflicting constraints", func() {
+		_, err := berksfile.Parse(`
+cookbook 'nginx', '~> 12.0'
+
+group :test do
+  cookbook 'nginx', '~> 11.0'
+end
+`)
+		var duplicate *berksfile.DuplicateCookbookError
+		Expect(errors.As(err, &duplicate)).To(BeTrue())
+		Expect(duplicate.Name).To(Equal("nginx"))
+		Expect(duplicate.First.Line).To(Equal(2))
+		Expect(duplicate.Duplicate.Line).To(Equal(5))
+		Expect(err).To(MatchError("cookbook 'nginx' declared at line 5 as '~> 11.0' conflicts with its declaration at line 2 as '~> 12.0'"))
+	})
+
+	It("should reject conflicting sources", func() {
+		_, err := berksfile.Parse("cookbook 'nginx', path: './nginx'\ncookbook 'nginx', git: 'https://github.com/acme/nginx.git'\n")
+		Expect(err).To(MatchError(ContainSubstring("from https://github.com/acme/nginx.git conflicts with its declaration at line 1 as '>= 0.0.0' from ./nginx")))
+	})
+
+	It("should warn about a cookbook provided by another's metadata name", func() {
+		b, err := berksfile.Parse("cookbook 'internal-nginx', path: './forks/nginx', metadata_name: 'nginx'\ncookbook 'nginx', '~> 12.0'\n")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(b.Cookbooks[0].MetadataName).To(Equal("nginx"))
+		Expect(b.Cookbooks[0].Source.Options).To(HaveKeyWithValue("metadata_name", "nginx"))
+		Expect(b.Cookbooks[1].MetadataName).To(BeEmpty())
+		Expect(b.Warnings).To(ConsistOf("cookbook 'nginx' at line 2 is provided by 'internal-nginx' (metadata_name 'nginx') at line 1"))
+	})
+})
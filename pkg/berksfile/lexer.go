@@ -7,19 +7,27 @@ import (
 	"strings"
 	"text/scanner"
 	"unicode"
+
+	berrors "github.com/bdwyertech/go-berkshelf/pkg/errors"
 )
 
 var keywords = map[string]int{
 	"source":   SOURCE,
 	"metadata": METADATA,
 	"cookbook": COOKBOOK,
+	"override": OVERRIDE,
+	"ignore":   IGNORE,
+	"exclude":  IGNORE,
 	"group":    GROUP,
 	"do":       DO,
 	"end":      END,
 }
 
-// Global variable to store parse errors
-var lastParseError error
+// parseErrors collects the errors found during the current parse
+var parseErrors ParseErrors
+
+// parseWarnings collects non-fatal issues found during the current parse
+var parseWarnings []string
 
 type Lexer struct {
 	s   scanner.Scanner
@@ -30,6 +38,14 @@ type Lexer struct {
 	}
 	sourceText string
 	tokenLog   []string
+	// cookbookLine is the line of the last cookbook keyword
+	cookbookLine int
+	// tok, lit and pos are the last token, its text and where it starts
+	tok int
+	lit string
+	pos scanner.Position
+	// options limit what interpolations read
+	options ParseOptions
 }
 
 func NewLexer(src string) *Lexer {
@@ -42,6 +58,13 @@ func NewLexer(src string) *Lexer {
 }
 
 func (l *Lexer) Lex(lval *yySymType) int {
+	l.tok = l.lex(lval)
+	l.lit = lval.str
+	lval.pos = l.pos
+	return l.tok
+}
+
+func (l *Lexer) lex(lval *yySymType) int {
 	// Use buffered token if any
 	if l.buf.n != 0 {
 		l.buf.n = 0
@@ -56,16 +79,31 @@ func (l *Lexer) Lex(lval *yySymType) int {
 
 	for {
 		r := l.s.Scan()
+		l.pos = l.s.Position
+		if !l.pos.IsValid() {
+			l.pos = l.s.Pos()
+		}
+		lval.str = ""
 		switch r {
 		case scanner.EOF:
 			return 0
 		case scanner.String, scanner.RawString:
 			lval.str = l.s.TokenText()
+			if r == scanner.String && strings.Contains(lval.str, "#{") {
+				expanded, unsupported := interpolate(lval.str, l.options)
+				for _, warning := range unsupported {
+					parseWarnings = append(parseWarnings, fmt.Sprintf("line %d: %s", l.pos.Line, warning))
+				}
+				lval.str = expanded
+			}
 			return STRING
 		case scanner.Ident:
 			ident := l.s.TokenText()
 			lower := strings.ToLower(ident)
 			if tok, isKeyword := keywords[lower]; isKeyword {
+				if tok == COOKBOOK || tok == OVERRIDE {
+					l.cookbookLine = l.s.Position.Line
+				}
 				return tok
 			}
 			lval.str = ident
@@ -86,6 +124,12 @@ func (l *Lexer) Lex(lval *yySymType) int {
 		case '}':
 			lval.str = "}"
 			return RBRACE
+		case '[':
+			lval.str = "["
+			return LBRACKET
+		case ']':
+			lval.str = "]"
+			return RBRACKET
 		case '=':
 			next := l.s.Peek()
 			if next == '>' {
@@ -150,20 +194,75 @@ func (l *Lexer) Lex(lval *yySymType) int {
 	}
 }
 
-func (l *Lexer) Error(msg string) {
-	pos := l.s.Pos()
+// ParseError is an error at a position in a Berksfile
+type ParseError struct {
+	Line    int
+	Column  int
+	Message string
+	// Text is the offending line
+	Text string
+}
 
-	// Find the line containing the error
-	lines := strings.Split(l.sourceText, "\n")
-	lineIndex := pos.Line - 1
-	line := ""
-	if lineIndex >= 0 && lineIndex < len(lines) {
-		line = lines[lineIndex]
+// Error implements the error interface, showing the offending line with a
+// caret under the column
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("parse error at line %d, column %d: %s\n%s\n%s^",
+		e.Line, e.Column, e.Message, e.Text, caretIndent(e.Text, e.Column))
+}
+
+// ErrorType reports parse errors as parsing errors
+func (e *ParseError) ErrorType() berrors.ErrorType {
+	return berrors.ErrorTypeParsing
+}
+
+// ParseErrors are the errors found in a Berksfile, in order
+type ParseErrors []*ParseError
+
+// Error implements the error interface
+func (e ParseErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+// Unwrap returns the errors, for errors.As
+func (e ParseErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
 	}
+	return errs
+}
+
+// caretIndent returns the indentation putting a caret under column of line,
+// keeping the line's tabs so the caret lines up
+func caretIndent(line string, column int) string {
+	var indent strings.Builder
+	for i, r := range []rune(line) {
+		if i >= column-1 {
+			break
+		}
+		if r == '\t' {
+			indent.WriteRune('\t')
+		} else {
+			indent.WriteRune(' ')
+		}
+	}
+	return indent.String()
+}
+
+// Error records a parse error at the current token
+func (l *Lexer) Error(msg string) {
+	pos := l.pos
+	line := l.line(pos.Line)
 
 	// Provide more specific error messages based on context
 	customMsg := msg
 	if strings.Contains(msg, "syntax error") {
+		customMsg = "syntax error: unexpected " + l.describeToken()
+
 		// Look at recent tokens to provide better context
 		if len(l.tokenLog) > 0 {
 			lastToken := l.tokenLog[len(l.tokenLog)-1]
@@ -193,22 +292,53 @@ func (l *Lexer) Error(msg string) {
 		}
 	}
 
-	lastParseError = fmt.Errorf(
-		"parse error at line %d, column %d: %s\n%s\n%s^",
-		pos.Line,
-		pos.Column,
-		customMsg,
-		line,
-		strings.Repeat(" ", pos.Column-1),
-	)
+	l.errorAt(pos, customMsg)
+}
+
+// errorAt records a parse error at pos
+func (l *Lexer) errorAt(pos scanner.Position, msg string) {
+	parseErrors = append(parseErrors, &ParseError{
+		Line:    pos.Line,
+		Column:  pos.Column,
+		Message: msg,
+		Text:    l.line(pos.Line),
+	})
+}
+
+// line returns a line of the source text, numbered from 1
+func (l *Lexer) line(n int) string {
+	lines := strings.Split(l.sourceText, "\n")
+	if n < 1 || n > len(lines) {
+		return ""
+	}
+	return lines[n-1]
+}
+
+// describeToken describes the last token for syntax errors
+func (l *Lexer) describeToken() string {
+	switch {
+	case l.tok == 0:
+		return "end of file"
+	case l.tok == NEWLINE:
+		return "end of line"
+	case l.tok == STRING:
+		return l.lit
+	case l.lit != "":
+		return "'" + l.lit + "'"
+	default:
+		return "'" + l.s.TokenText() + "'"
+	}
 }
 
 // GetLastError returns the last parse error
 func GetLastError() error {
-	return lastParseError
+	if len(parseErrors) == 0 {
+		return nil
+	}
+	return parseErrors[len(parseErrors)-1]
 }
 
-// ClearLastError clears the last parse error
+// ClearLastError clears the parse errors
 func ClearLastError() {
-	lastParseError = nil
+	parseErrors = nil
 }
@@ -0,0 +1,72 @@
+package berksfile
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	"github.com/bdwyertech/go-berkshelf/pkg/metadata"
+)
+
+// MetadataCookbooks returns the cookbooks the metadata directive adds when
+// the Berksfile is in dir: the cookbook in dir itself, sourced from that
+// path, followed by the cookbooks its metadata depends on, sorted, with
+// their constraints. Dependencies the Berksfile declares are left to its
+// declaration. It returns nil when there is no metadata directive.
+func (b *Berksfile) MetadataCookbooks(dir string) ([]*CookbookDef, error) {
+	if !b.HasMetadata {
+		return nil, nil
+	}
+
+	md, err := ReadMetadata(dir)
+	if err != nil {
+		return nil, err
+	}
+	if md.Name == "" {
+		return nil, fmt.Errorf("failed to read metadata in %s: no cookbook name", dir)
+	}
+	log.Debugf("Found cookbook %s (%s) via metadata", md.Name, md.Version)
+
+	cookbooks := []*CookbookDef{{
+		Name:   md.Name,
+		Source: &berkshelf.SourceLocation{Type: "path", Path: dir},
+	}}
+	for _, name := range slices.Sorted(maps.Keys(md.Dependencies)) {
+		if b.declares(name) {
+			continue
+		}
+		cookbooks = append(cookbooks, &CookbookDef{Name: name, Constraint: md.Dependencies[name]})
+	}
+	return cookbooks, nil
+}
+
+// declares reports whether the Berksfile declares a cookbook, in any group
+func (b *Berksfile) declares(name string) bool {
+	return slices.ContainsFunc(b.Cookbooks, func(c *CookbookDef) bool { return c.Name == name })
+}
+
+// ReadMetadata reads the metadata.json of the cookbook in dir, or else its
+// metadata.rb
+func ReadMetadata(dir string) (*berkshelf.Metadata, error) {
+	if data, err := os.ReadFile(filepath.Join(dir, "metadata.json")); err == nil {
+		md, err := metadata.ParseJSON(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read metadata in %s: %w", dir, err)
+		}
+		return md.Berkshelf(), nil
+	}
+
+	md, err := metadata.ParseFile(filepath.Join(dir, "metadata.rb"))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read metadata: no metadata.json or metadata.rb in %s", dir)
+		}
+		return nil, fmt.Errorf("failed to read metadata in %s: %w", dir, err)
+	}
+	return md.Berkshelf(), nil
+}
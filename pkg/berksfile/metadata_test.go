@@ -0,0 +1,67 @@
+package berksfile_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berksfile"
+)
+
+var _ = Describe("MetadataCookbooks", func() {
+	var dir string
+
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+		Expect(os.WriteFile(filepath.Join(dir, "metadata.rb"), []byte(`
+name 'webapp'
+version '1.2.0'
+depends 'nginx', '~> 12.0'
+depends 'apt'
+depends 'postgresql', '>= 9.0'
+`), 0644)).To(Succeed())
+	})
+
+	It("should add the cookbook from its path and its dependencies", func() {
+		b, err := berksfile.Parse("source 'https://supermarket.chef.io'\nmetadata\ncookbook 'postgresql', git: 'https://github.com/acme/postgresql.git'")
+		Expect(err).NotTo(HaveOccurred())
+
+		cookbooks, err := b.MetadataCookbooks(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cookbooks).To(HaveLen(3))
+		Expect(cookbooks[0].Name).To(Equal("webapp"))
+		Expect(cookbooks[0].Source.Type).To(Equal("path"))
+		Expect(cookbooks[0].Source.Path).To(Equal(dir))
+		// postgresql is left to the Berksfile's declaration
+		Expect(cookbooks[1].Name).To(Equal("apt"))
+		Expect(cookbooks[1].Source).To(BeNil())
+		Expect(cookbooks[2].Name).To(Equal("nginx"))
+		Expect(cookbooks[2].Constraint.String()).To(Equal("~> 12.0"))
+	})
+
+	It("should prefer metadata.json", func() {
+		Expect(os.WriteFile(filepath.Join(dir, "metadata.json"), []byte(`{"name": "webapp", "version": "1.2.0", "dependencies": {"ntp": ">= 1.0.0"}}`), 0644)).To(Succeed())
+		b, err := berksfile.Parse("metadata")
+		Expect(err).NotTo(HaveOccurred())
+
+		cookbooks, err := b.MetadataCookbooks(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cookbooks).To(HaveLen(2))
+		Expect(cookbooks[1].Name).To(Equal("ntp"))
+	})
+
+	It("should add nothing without a metadata directive", func() {
+		b, err := berksfile.Parse("cookbook 'nginx'")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(b.MetadataCookbooks(dir)).To(BeEmpty())
+	})
+
+	It("should fail without metadata", func() {
+		b, err := berksfile.Parse("metadata")
+		Expect(err).NotTo(HaveOccurred())
+		_, err = b.MetadataCookbooks(GinkgoT().TempDir())
+		Expect(err).To(MatchError(ContainSubstring("no metadata.json or metadata.rb")))
+	})
+})
@@ -2,13 +2,37 @@ package berksfile
 
 import (
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
 
 	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	"github.com/bdwyertech/go-berkshelf/pkg/logging"
 )
 
+var log = logging.For("berksfile")
+
+// ParseOptions limit what a Berksfile may read while it is parsed. A
+// Berksfile from an untrusted client is parsed with neither, so it cannot
+// learn or send on the environment or files of the host parsing it.
+type ParseOptions struct {
+	// Env lets interpolations, and conditionals when EvalEnv is on, read
+	// environment variables
+	Env bool
+	// Files lets conditionals check the local disk with File.exist? and
+	// the like
+	Files bool
+}
+
 // Parse parses the input Berksfile DSL and returns a Berksfile struct or error.
 func Parse(input string) (*Berksfile, error) {
+	return ParseWithOptions(input, ParseOptions{Env: true, Files: true})
+}
+
+// ParseWithOptions is Parse reading only the environment and files options
+// allow. Conditionals that would read anything else are skipped with all
+// their branches, and interpolations are left as they are, with warnings.
+func ParseWithOptions(input string, options ParseOptions) (*Berksfile, error) {
 	trimmed := strings.TrimSpace(input)
 	if trimmed == "" {
 		// Return empty but valid Berksfile for empty input
@@ -20,6 +44,12 @@ func Parse(input string) (*Berksfile, error) {
 		}, nil
 	}
 
+	// Evaluate Ruby conditionals before handing the DSL to the grammar
+	input, skipped, err := evaluateConditionals(input, options)
+	if err != nil {
+		return nil, err
+	}
+
 	var parsePanic any
 	defer func() {
 		if r := recover(); r != nil {
@@ -27,21 +57,73 @@ func Parse(input string) (*Berksfile, error) {
 		}
 	}()
 
-	lastParseError = nil
+	parseErrors = nil
+	parseWarnings = nil
 	lexer := NewLexer(input)
 	lexer.sourceText = input // Store source text for error reporting
+	lexer.options = options
 	Result = nil
 	yyParse(lexer)
 
 	if parsePanic != nil {
 		return nil, fmt.Errorf("panic during parse: %v", parsePanic)
 	}
-	if lastParseError != nil {
-		return nil, lastParseError
+	if len(parseErrors) > 0 {
+		return nil, parseErrors
 	}
 	if Result == nil {
 		return nil, fmt.Errorf("parse error - Result is nil")
 	}
 
+	Result.Warnings = append(skipped, Result.Warnings...)
+
+	if err := Result.mergeDuplicates(); err != nil {
+		return nil, err
+	}
+	if err := Result.mergeOverrides(); err != nil {
+		return nil, err
+	}
+	if err := Result.composeGroups(); err != nil {
+		return nil, err
+	}
+	Result.warnAliased()
+
+	for _, warning := range Result.Warnings {
+		log.Warn(warning)
+	}
+
 	return Result, nil
 }
+
+// composeGroups adds the cookbooks of the groups a composed group includes,
+// directly or through other composed groups, to the composed group, so
+// --only and --except select them by its name too
+func (b *Berksfile) composeGroups() error {
+	for _, name := range slices.Sorted(maps.Keys(b.GroupIncludes)) {
+		seen := map[string]bool{name: true}
+		queue := slices.Clone(b.GroupIncludes[name])
+		for len(queue) > 0 {
+			included := queue[0]
+			queue = queue[1:]
+			if seen[included] {
+				continue
+			}
+			seen[included] = true
+
+			cookbooks, ok := b.Groups[included]
+			if !ok {
+				return fmt.Errorf("group '%s' includes unknown group '%s'", name, included)
+			}
+			queue = append(queue, b.GroupIncludes[included]...)
+			for _, cb := range cookbooks {
+				if !slices.Contains(b.Groups[name], cb) {
+					b.Groups[name] = append(b.Groups[name], cb)
+				}
+				if !slices.Contains(cb.Groups, name) {
+					cb.Groups = append(cb.Groups, name)
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -9,7 +9,9 @@ import __yyfmt__ "fmt"
 //line berksfile.y:5
 
 import (
+	"fmt"
 	"strings"
+	"text/scanner"
 
 	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
 )
@@ -20,14 +22,22 @@ type CookbookDef struct {
 	Constraint *berkshelf.Constraint
 	Source     *berkshelf.SourceLocation
 	Groups     []string
+	Line       int // Line of the Berksfile the cookbook is declared on
+	// MetadataName is the name the cookbook's metadata declares when it
+	// differs from Name, e.g. for a fork; set with metadata_name:
+	MetadataName string
 }
 
 // Berksfile represents a parsed Berksfile
 type Berksfile struct {
-	Sources     []*berkshelf.SourceLocation // List of default sources with full configuration
-	Cookbooks   []*CookbookDef              // All cookbook definitions
-	Groups      map[string][]*CookbookDef   // Grouped cookbooks
-	HasMetadata bool                        // Whether metadata directive is present
+	Sources       []*berkshelf.SourceLocation // List of default sources with full configuration
+	Cookbooks     []*CookbookDef              // All cookbook definitions
+	Groups        map[string][]*CookbookDef   // Grouped cookbooks
+	HasMetadata   bool                        // Whether metadata directive is present
+	GroupIncludes map[string][]string         // Groups composed of other groups, e.g. group :ci => [:test]
+	Overrides     []*CookbookDef              // Cookbooks pinned with override, regardless of other constraints
+	Ignored       []string                    // Cookbooks provided out of band, which are not resolved
+	Warnings      []string                    // Non-fatal issues, such as converted constraints
 }
 
 var Result *Berksfile
@@ -88,6 +98,99 @@ func ParseConstraint(constraintStr string) (*berkshelf.Constraint, error) {
 	return berkshelf.NewConstraint(constraintStr)
 }
 
+// newCookbookDef creates the cookbook a cookbook or override statement
+// declares
+func newCookbookDef(l *Lexer, name string, tail cbTail) *CookbookDef {
+	constraint, _ := ParseConstraint(">= 0.0.0")
+	if tail.version != "" {
+		if expanded, ok := berkshelf.ExpandShorthand(tail.version); ok {
+			parseWarnings = append(parseWarnings, fmt.Sprintf("cookbook '%s': converted shorthand constraint %q to %q", name, tail.version, expanded))
+			tail.version = expanded
+		}
+		if c, err := ParseConstraint(tail.version); err != nil {
+			l.errorAt(tail.versionPos, "invalid version constraint: "+tail.version)
+		} else {
+			constraint = c
+		}
+	}
+
+	source := &berkshelf.SourceLocation{}
+	if tail.options != nil {
+		source.Options = make(map[string]any)
+		if gitUrl, ok := tail.options["git"]; ok {
+			source.Type = "git"
+			source.URL = gitUrl
+			if branch, ok := tail.options["branch"]; ok {
+				source.Ref = branch
+				source.Options["branch"] = branch
+			}
+			if ref, ok := tail.options["ref"]; ok {
+				source.Ref = ref
+				source.Options["ref"] = ref
+			}
+			if rel, ok := tail.options["rel"]; ok {
+				// The cookbook's directory in a monorepo
+				source.Path = rel
+			}
+			if submodules, ok := tail.options["submodules"]; ok {
+				source.Options["submodules"] = submodules
+			}
+		} else if github, ok := tail.options["github"]; ok {
+			source.Type = "git"
+			source.URL = "https://github.com/" + github + ".git"
+			if rel, ok := tail.options["rel"]; ok {
+				source.Path = rel
+			}
+			if submodules, ok := tail.options["submodules"]; ok {
+				source.Options["submodules"] = submodules
+			}
+		} else if path, ok := tail.options["path"]; ok {
+			source.Type = "path"
+			source.Path = path
+		} else if s3, ok := tail.options["s3"]; ok {
+			source.Type = "s3"
+			source.URL = s3
+			for _, k := range []string{"region", "endpoint", "profile", "layout"} {
+				if v, ok := tail.options[k]; ok {
+					source.Options[k] = v
+				}
+			}
+		} else if oci, ok := tail.options["oci"]; ok {
+			source.Type = "oci"
+			source.URL = oci
+			if v, ok := tail.options["plain_http"]; ok {
+				source.Options["plain_http"] = v
+			}
+		} else if typ, ok := tail.options["type"]; ok {
+			// A source type registered with source.Register, which
+			// gets the other options
+			source.Type = typ
+			source.URL = tail.options["url"]
+			for k, v := range tail.options {
+				if k != "type" && k != "url" {
+					source.Options[k] = v
+				}
+			}
+		}
+	}
+
+	cb := &CookbookDef{
+		Name:       name,
+		Constraint: constraint,
+		Source:     source,
+		Groups:     []string{},
+		Line:       l.cookbookLine,
+	}
+	if metadataName, ok := tail.options["metadata_name"]; ok && metadataName != name {
+		cb.MetadataName = metadataName
+		if source.Type == "path" {
+			// The path source finds the cookbook by its metadata name
+			source.Options["metadata_name"] = metadataName
+		}
+	}
+	return cb
+}
+
 func trimQuotes(s string) string {
 	return strings.Trim(s, `"'`)
 }
@@ -100,8 +203,9 @@ type sourceArgs struct {
 }
 
 type cbTail struct {
-	version string
-	options map[string]string
+	version    string
+	versionPos scanner.Position
+	options    map[string]string
 }
 
 type kv struct {
@@ -119,7 +223,9 @@ type Source struct {
 // Group represents a group definition in a Berksfile
 type Group struct {
 	Name      string
-	Cookbooks []*CookbookDef
+	Cookbooks []*CookbookDef // Including those of nested groups
+	Nested    []*Group
+	Includes  []string // Set for a group composed of other groups
 }
 
 // Collections type to hold multiple items with metadata flag
@@ -127,6 +233,8 @@ type collections struct {
 	sources   []*Source
 	cookbooks []*CookbookDef
 	groups    []*Group
+	overrides []*CookbookDef
+	ignored   []string
 	metadata  bool
 }
 
@@ -135,13 +243,16 @@ type stmtResult struct {
 	source   *Source
 	cookbook *CookbookDef
 	group    *Group
+	override *CookbookDef
+	ignored  []string
 	metadata bool
 }
 
-//line berksfile.y:139
+//line berksfile.y:249
 type yySymType struct {
 	yys         int
 	str         string
+	strs        []string
 	source      *Source
 	cookbook    *CookbookDef
 	group       *Group
@@ -155,22 +266,27 @@ type yySymType struct {
 	boolVal     bool
 	collections collections
 	stmt        stmtResult
+	pos         scanner.Position // Where the token starts, set by the lexer
 }
 
 const SOURCE = 57346
 const METADATA = 57347
 const COOKBOOK = 57348
-const GROUP = 57349
-const DO = 57350
-const END = 57351
-const IDENT = 57352
-const STRING = 57353
-const COLON = 57354
-const COMMA = 57355
-const LBRACE = 57356
-const RBRACE = 57357
-const HASHROCKET = 57358
-const NEWLINE = 57359
+const OVERRIDE = 57349
+const IGNORE = 57350
+const GROUP = 57351
+const DO = 57352
+const END = 57353
+const IDENT = 57354
+const STRING = 57355
+const COLON = 57356
+const COMMA = 57357
+const LBRACE = 57358
+const RBRACE = 57359
+const LBRACKET = 57360
+const RBRACKET = 57361
+const HASHROCKET = 57362
+const NEWLINE = 57363
 
 var yyToknames = [...]string{
 	"$end",
@@ -179,6 +295,8 @@ var yyToknames = [...]string{
 	"SOURCE",
 	"METADATA",
 	"COOKBOOK",
+	"OVERRIDE",
+	"IGNORE",
 	"GROUP",
 	"DO",
 	"END",
@@ -188,6 +306,8 @@ var yyToknames = [...]string{
 	"COMMA",
 	"LBRACE",
 	"RBRACE",
+	"LBRACKET",
+	"RBRACKET",
 	"HASHROCKET",
 	"NEWLINE",
 }
@@ -198,83 +318,103 @@ const yyEofCode = 1
 const yyErrCode = 2
 const yyInitialStackSize = 16
 
-//line berksfile.y:554
+//line berksfile.y:746
 
 //line yacctab:1
 var yyExca = [...]int8{
+	-1, 0,
+	1, 3,
+	-2, 0,
 	-1, 1,
 	1, -1,
 	-2, 0,
+	-1, 3,
+	1, 2,
+	-2, 0,
+	-1, 55,
+	11, 42,
+	-2, 0,
 }
 
 const yyPrivate = 57344
 
-const yyLast = 82
+const yyLast = 110
 
 var yyAct = [...]int8{
-	50, 36, 37, 8, 10, 11, 12, 13, 10, 11,
-	12, 13, 12, 12, 66, 47, 70, 15, 38, 49,
-	39, 5, 60, 56, 43, 46, 63, 51, 47, 45,
-	38, 34, 39, 42, 35, 28, 52, 48, 38, 49,
-	39, 29, 23, 24, 25, 55, 30, 59, 61, 57,
-	58, 54, 44, 26, 64, 31, 32, 21, 20, 18,
-	17, 69, 67, 65, 62, 68, 33, 53, 4, 22,
-	41, 40, 14, 9, 27, 19, 7, 16, 6, 3,
-	2, 1,
+	67, 50, 51, 29, 10, 9, 26, 38, 21, 88,
+	13, 14, 15, 17, 18, 16, 6, 36, 13, 14,
+	15, 17, 18, 16, 33, 35, 22, 20, 15, 75,
+	87, 16, 63, 15, 40, 5, 16, 64, 42, 42,
+	64, 45, 89, 58, 41, 57, 56, 59, 74, 93,
+	83, 65, 68, 61, 52, 66, 53, 69, 80, 62,
+	73, 72, 46, 76, 79, 81, 52, 48, 53, 60,
+	49, 84, 52, 66, 53, 30, 31, 32, 92, 39,
+	86, 85, 90, 77, 78, 91, 28, 27, 43, 44,
+	70, 37, 25, 24, 82, 47, 71, 4, 55, 54,
+	34, 19, 12, 11, 8, 23, 7, 3, 2, 1,
 }
 
 var yyPact = [...]int16{
-	4, -1000, -1000, 0, -1000, -1000, -1000, -1000, -1000, -1000,
-	49, -1000, 47, 32, -1000, -1000, -1000, -1000, 41, 22,
-	-1000, -1000, 33, -1000, -1000, 45, 55, -1000, 20, 7,
-	40, -1000, -1000, 16, 12, 28, -1000, 14, 24, 57,
-	42, 6, -1000, -1000, 39, 28, 8, 53, 11, -1,
-	-1000, 28, 52, -2, -1000, -1000, -1000, -1000, -1000, -1000,
-	28, -1000, -1000, -1000, 14, -1000, 50, 1, -1000, -1000,
-	-1000,
+	14, -1000, -1000, 6, -1000, -1000, 5, -1000, -1000, -1000,
+	-1000, -1000, -1000, 80, -1000, 74, 63, 74, 74, -1000,
+	-1000, -4, -1000, -1000, -1000, 77, 64, -1000, -1000, 24,
+	-1000, -1000, 76, 64, 47, -1000, -1000, 82, -1000, 54,
+	22, 29, 55, -1000, -1000, -1000, 74, 44, 17, 60,
+	-1000, 37, 43, 78, 85, 27, -1000, -1000, -1000, 63,
+	71, -1000, 60, 42, 81, 33, 20, -1000, 60, 68,
+	10, -1000, -1000, -1000, -1000, -12, 23, -1000, -1000, -1000,
+	60, -1000, -1000, -1000, 37, -1000, -1000, 65, -1000, -1000,
+	32, -1000, -1000, -1000,
 }
 
 var yyPgo = [...]int8{
-	0, 81, 80, 79, 68, 78, 77, 76, 3, 75,
-	74, 73, 71, 70, 1, 0, 2, 69,
+	0, 109, 108, 107, 97, 106, 105, 104, 5, 103,
+	6, 102, 100, 7, 4, 99, 98, 1, 0, 2,
+	3,
 }
 
 var yyR1 = [...]int8{
-	0, 1, 2, 2, 3, 3, 3, 3, 4, 4,
-	4, 4, 5, 6, 6, 6, 7, 8, 9, 9,
-	10, 10, 10, 10, 10, 10, 11, 17, 17, 17,
-	17, 17, 17, 12, 12, 13, 13, 13, 13, 14,
-	15, 15, 16, 16, 16,
+	0, 1, 2, 2, 3, 3, 3, 3, 3, 3,
+	4, 4, 4, 4, 4, 4, 5, 6, 6, 6,
+	7, 8, 9, 11, 12, 12, 10, 10, 13, 13,
+	13, 13, 13, 13, 14, 14, 20, 20, 20, 20,
+	20, 20, 15, 15, 16, 16, 16, 16, 16, 16,
+	16, 17, 18, 18, 19, 19, 19, 19,
 }
 
 var yyR2 = [...]int8{
-	0, 1, 1, 0, 2, 2, 1, 1, 1, 1,
-	1, 1, 2, 1, 3, 5, 1, 3, 1, 1,
-	2, 4, 6, 2, 4, 0, 5, 4, 4, 1,
-	1, 2, 2, 1, 0, 2, 2, 1, 1, 2,
-	3, 0, 3, 4, 3,
+	0, 1, 1, 0, 2, 2, 3, 1, 1, 2,
+	1, 1, 1, 1, 1, 1, 2, 1, 3, 5,
+	1, 3, 3, 2, 1, 3, 1, 1, 2, 4,
+	6, 2, 4, 0, 5, 6, 4, 4, 1, 1,
+	2, 2, 1, 0, 2, 2, 2, 3, 1, 1,
+	1, 2, 3, 0, 3, 3, 4, 3,
 }
 
 var yyChk = [...]int16{
-	-1000, -1, -2, -3, -4, 17, -5, -7, -8, -11,
-	4, 5, 6, 7, -4, 17, -6, 11, 10, -9,
-	11, 10, -17, 10, 11, 12, 12, -10, 13, 8,
-	13, 10, 11, 11, 11, 14, -14, -16, 10, 12,
-	-12, -13, -8, 17, 12, 13, 13, 16, -14, 11,
-	-15, 13, 12, 10, 9, -8, 17, 10, 11, -14,
-	14, -14, 11, 15, -16, 11, 16, -14, -15, 11,
-	15,
+	-1000, -1, -2, -3, -4, 21, 2, -5, -7, -8,
+	-14, -9, -11, 4, 5, 6, 9, 7, 8, -4,
+	21, 2, 21, -6, 13, 12, -10, 13, 12, -20,
+	12, 13, 14, -10, -12, -10, 21, 14, -13, 15,
+	10, 20, 15, 12, 13, -13, 15, 13, 13, 16,
+	-17, -19, 12, 14, -15, -16, -8, -14, 21, 18,
+	14, -10, 15, 15, 20, -17, 13, -18, 15, 14,
+	12, 11, -8, -14, 21, 2, -20, 12, 13, -17,
+	16, -17, 13, 17, -19, 13, 12, 20, 21, 19,
+	-17, -18, 13, 17,
 }
 
 var yyDef = [...]int8{
-	3, -2, 1, 2, 6, 7, 8, 9, 10, 11,
-	0, 16, 0, 0, 4, 5, 12, 13, 0, 25,
-	18, 19, 0, 29, 30, 0, 0, 17, 0, 34,
-	0, 31, 32, 14, 20, 0, 23, 41, 0, 0,
-	0, 33, 37, 38, 0, 0, 0, 0, 0, 0,
-	39, 0, 0, 0, 26, 35, 36, 27, 28, 15,
-	0, 24, 44, 21, 41, 42, 0, 0, 40, 43,
-	22,
+	-2, -2, 1, -2, 7, 8, 0, 10, 11, 12,
+	13, 14, 15, 0, 20, 0, 0, 0, 0, 4,
+	5, 0, 9, 16, 17, 0, 33, 26, 27, 0,
+	38, 39, 0, 33, 23, 24, 6, 0, 21, 0,
+	43, 0, 0, 40, 41, 22, 0, 18, 28, 0,
+	31, 53, 0, 0, 0, -2, 48, 49, 50, 0,
+	0, 25, 0, 0, 0, 0, 0, 51, 0, 0,
+	0, 34, 44, 45, 46, 0, 0, 36, 37, 19,
+	0, 32, 57, 29, 53, 54, 55, 0, 47, 35,
+	0, 52, 56, 30,
 }
 
 var yyTok1 = [...]int8{
@@ -283,7 +423,7 @@ var yyTok1 = [...]int8{
 
 var yyTok2 = [...]int8{
 	2, 3, 4, 5, 6, 7, 8, 9, 10, 11,
-	12, 13, 14, 15, 16, 17,
+	12, 13, 14, 15, 16, 17, 18, 19, 20, 21,
 }
 
 var yyTok3 = [...]int8{
@@ -629,7 +769,7 @@ yydefault:
 
 	case 1:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line berksfile.y:177
+//line berksfile.y:290
 		{
 			// Convert sources from []*Source to []*berkshelf.SourceLocation
 			sources := make([]*berkshelf.SourceLocation, len(yyDollar[1].collections.sources))
@@ -653,10 +793,9 @@ yydefault:
 
 			// Convert groups from []*Group to map[string][]*CookbookDef
 			groups := make(map[string][]*CookbookDef)
-			for _, group := range yyDollar[1].collections.groups {
-				// Add group cookbooks to the main cookbook list
-				allCookbooks = append(allCookbooks, group.Cookbooks...)
-
+			includes := make(map[string][]string)
+			var addGroup func(group *Group)
+			addGroup = func(group *Group) {
 				// Handle group names (could be comma-separated for multiple groups)
 				groupNames := strings.Split(group.Name, ",")
 
@@ -665,6 +804,9 @@ yydefault:
 					if groups[groupName] == nil {
 						groups[groupName] = []*CookbookDef{}
 					}
+					if group.Includes != nil {
+						includes[groupName] = append(includes[groupName], group.Includes...)
+					}
 
 					// Add cookbooks to this group
 					for _, cb := range group.Cookbooks {
@@ -681,6 +823,14 @@ yydefault:
 						}
 					}
 				}
+				for _, nested := range group.Nested {
+					addGroup(nested)
+				}
+			}
+			for _, group := range yyDollar[1].collections.groups {
+				// Add group cookbooks to the main cookbook list
+				allCookbooks = append(allCookbooks, group.Cookbooks...)
+				addGroup(group)
 			}
 
 			Result = &Berksfile{
@@ -688,18 +838,24 @@ yydefault:
 				Cookbooks:   allCookbooks,
 				Groups:      groups,
 				HasMetadata: yyDollar[1].collections.metadata,
+				Warnings:    parseWarnings,
+				Overrides:   yyDollar[1].collections.overrides,
+				Ignored:     yyDollar[1].collections.ignored,
+			}
+			if len(includes) > 0 {
+				Result.GroupIncludes = includes
 			}
 			yyVAL.collections = yyDollar[1].collections
 		}
 	case 2:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line berksfile.y:241
+//line berksfile.y:370
 		{
 			yyVAL.collections = yyDollar[1].collections
 		}
 	case 3:
 		yyDollar = yyS[yypt-0 : yypt+1]
-//line berksfile.y:244
+//line berksfile.y:373
 		{
 			yyVAL.collections.sources = []*Source{}
 			yyVAL.collections.cookbooks = []*CookbookDef{}
@@ -708,11 +864,12 @@ yydefault:
 		}
 	case 4:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line berksfile.y:253
+//line berksfile.y:382
 		{
 			yyVAL.collections.sources = yyDollar[1].collections.sources
 			yyVAL.collections.cookbooks = yyDollar[1].collections.cookbooks
 			yyVAL.collections.groups = yyDollar[1].collections.groups
+			yyVAL.collections.overrides = yyDollar[1].collections.overrides
 			yyVAL.collections.metadata = yyDollar[1].collections.metadata
 
 			// Add new statement
@@ -725,19 +882,32 @@ yydefault:
 			if yyDollar[2].stmt.group != nil {
 				yyVAL.collections.groups = append(yyVAL.collections.groups, yyDollar[2].stmt.group)
 			}
+			if yyDollar[2].stmt.override != nil {
+				yyVAL.collections.overrides = append(yyVAL.collections.overrides, yyDollar[2].stmt.override)
+			}
+			yyVAL.collections.ignored = append(yyDollar[1].collections.ignored, yyDollar[2].stmt.ignored...)
 			if yyDollar[2].stmt.metadata {
 				yyVAL.collections.metadata = true
 			}
 		}
 	case 5:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line berksfile.y:273
+//line berksfile.y:407
 		{
 			yyVAL.collections = yyDollar[1].collections
 		}
 	case 6:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line berksfile.y:410
+		{
+			// Skip the rest of a bad line, so errors on later lines are
+			// reported too
+			yyVAL.collections = yyDollar[1].collections
+			Errflag = 0
+		}
+	case 7:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line berksfile.y:276
+//line berksfile.y:416
 		{
 			yyVAL.collections.sources = []*Source{}
 			yyVAL.collections.cookbooks = []*CookbookDef{}
@@ -754,58 +924,92 @@ yydefault:
 			if yyDollar[1].stmt.group != nil {
 				yyVAL.collections.groups = append(yyVAL.collections.groups, yyDollar[1].stmt.group)
 			}
+			if yyDollar[1].stmt.override != nil {
+				yyVAL.collections.overrides = append(yyVAL.collections.overrides, yyDollar[1].stmt.override)
+			}
+			yyVAL.collections.ignored = yyDollar[1].stmt.ignored
 			if yyDollar[1].stmt.metadata {
 				yyVAL.collections.metadata = true
 			}
 		}
-	case 7:
+	case 8:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line berksfile.y:296
+//line berksfile.y:440
 		{
 			yyVAL.collections.sources = []*Source{}
 			yyVAL.collections.cookbooks = []*CookbookDef{}
 			yyVAL.collections.groups = []*Group{}
 			yyVAL.collections.metadata = false
 		}
-	case 8:
+	case 9:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line berksfile.y:446
+		{
+			yyVAL.collections.sources = []*Source{}
+			yyVAL.collections.cookbooks = []*CookbookDef{}
+			yyVAL.collections.groups = []*Group{}
+			yyVAL.collections.metadata = false
+			Errflag = 0
+		}
+	case 10:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line berksfile.y:305
+//line berksfile.y:456
 		{
 			yyVAL.stmt.source = yyDollar[1].source
 			yyVAL.stmt.cookbook = nil
 			yyVAL.stmt.group = nil
 			yyVAL.stmt.metadata = false
 		}
-	case 9:
+	case 11:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line berksfile.y:311
+//line berksfile.y:462
 		{
 			yyVAL.stmt.source = nil
 			yyVAL.stmt.cookbook = nil
 			yyVAL.stmt.group = nil
 			yyVAL.stmt.metadata = yyDollar[1].boolVal
 		}
-	case 10:
+	case 12:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line berksfile.y:317
+//line berksfile.y:468
 		{
 			yyVAL.stmt.source = nil
 			yyVAL.stmt.cookbook = yyDollar[1].cookbook
 			yyVAL.stmt.group = nil
 			yyVAL.stmt.metadata = false
 		}
-	case 11:
+	case 13:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line berksfile.y:323
+//line berksfile.y:474
 		{
 			yyVAL.stmt.source = nil
 			yyVAL.stmt.cookbook = nil
 			yyVAL.stmt.group = yyDollar[1].group
 			yyVAL.stmt.metadata = false
 		}
-	case 12:
+	case 14:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line berksfile.y:480
+		{
+			yyVAL.stmt.source = nil
+			yyVAL.stmt.cookbook = nil
+			yyVAL.stmt.group = nil
+			yyVAL.stmt.override = yyDollar[1].cookbook
+			yyVAL.stmt.metadata = false
+		}
+	case 15:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line berksfile.y:487
+		{
+			yyVAL.stmt.source = nil
+			yyVAL.stmt.cookbook = nil
+			yyVAL.stmt.group = nil
+			yyVAL.stmt.ignored = yyDollar[1].strs
+			yyVAL.stmt.metadata = false
+		}
+	case 16:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line berksfile.y:332
+//line berksfile.y:497
 		{
 			yyVAL.source = &Source{
 				Type:    yyDollar[2].sa.typ,
@@ -813,137 +1017,129 @@ yydefault:
 				Options: yyDollar[2].sa.opts,
 			}
 		}
-	case 13:
+	case 17:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line berksfile.y:342
+//line berksfile.y:507
 		{
 			yyVAL.sa.typ = "supermarket"
 			yyVAL.sa.url = trimQuotes(yyDollar[1].str)
 			yyVAL.sa.opts = nil
 		}
-	case 14:
+	case 18:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line berksfile.y:347
+//line berksfile.y:512
 		{
 			yyVAL.sa.typ = yyDollar[1].str
 			yyVAL.sa.url = trimQuotes(yyDollar[3].str)
 			yyVAL.sa.opts = nil
 		}
-	case 15:
+	case 19:
 		yyDollar = yyS[yypt-5 : yypt+1]
-//line berksfile.y:352
+//line berksfile.y:517
 		{
 			yyVAL.sa.typ = yyDollar[1].str
 			yyVAL.sa.url = trimQuotes(yyDollar[3].str)
 			yyVAL.sa.opts = yyDollar[5].opts
 		}
-	case 16:
+	case 20:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line berksfile.y:360
+//line berksfile.y:525
 		{
 			yyVAL.boolVal = true
 		}
-	case 17:
+	case 21:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line berksfile.y:366
+//line berksfile.y:531
 		{
-			constraint, _ := ParseConstraint(">= 0.0.0")
-			if yyDollar[3].cbTail.version != "" {
-				if c, err := ParseConstraint(yyDollar[3].cbTail.version); err != nil {
-					yylex.Error("invalid version constraint: " + yyDollar[3].cbTail.version)
-					return 1
-				} else {
-					constraint = c
-				}
-			}
-
-			source := &berkshelf.SourceLocation{}
-			if yyDollar[3].cbTail.options != nil {
-				source.Options = make(map[string]any)
-				if gitUrl, ok := yyDollar[3].cbTail.options["git"]; ok {
-					source.Type = "git"
-					source.URL = gitUrl
-					if branch, ok := yyDollar[3].cbTail.options["branch"]; ok {
-						source.Ref = branch
-						source.Options["branch"] = branch
-					}
-					if ref, ok := yyDollar[3].cbTail.options["ref"]; ok {
-						source.Ref = ref
-						source.Options["ref"] = ref
-					}
-				} else if github, ok := yyDollar[3].cbTail.options["github"]; ok {
-					source.Type = "git"
-					source.URL = "https://github.com/" + github + ".git"
-				} else if path, ok := yyDollar[3].cbTail.options["path"]; ok {
-					source.Type = "path"
-					source.Path = path
-				}
-			}
-
-			yyVAL.cookbook = &CookbookDef{
-				Name:       yyDollar[2].str,
-				Constraint: constraint,
-				Source:     source,
-				Groups:     []string{},
+			yyVAL.cookbook = newCookbookDef(yylex.(*Lexer), yyDollar[2].str, yyDollar[3].cbTail)
+		}
+	case 22:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line berksfile.y:537
+		{
+			yyVAL.cookbook = newCookbookDef(yylex.(*Lexer), yyDollar[2].str, yyDollar[3].cbTail)
+			if yyDollar[3].cbTail.version == "" && yyVAL.cookbook.Source.Type == "" {
+				yylex.(*Lexer).errorAt(yyDollar[1].pos, "override '"+yyDollar[2].str+"' needs a version constraint or a source")
 			}
 		}
-	case 18:
+	case 23:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line berksfile.y:546
+		{
+			yyVAL.strs = yyDollar[2].strs
+		}
+	case 24:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line berksfile.y:410
+//line berksfile.y:552
+		{
+			yyVAL.strs = []string{yyDollar[1].str}
+		}
+	case 25:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line berksfile.y:555
+		{
+			yyVAL.strs = append(yyDollar[1].strs, yyDollar[3].str)
+		}
+	case 26:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line berksfile.y:561
 		{
 			yyVAL.str = trimQuotes(yyDollar[1].str)
 		}
-	case 19:
+	case 27:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line berksfile.y:411
+//line berksfile.y:562
 		{
 			yyVAL.str = yyDollar[1].str
 		}
-	case 20:
+	case 28:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line berksfile.y:415
+//line berksfile.y:566
 		{
 			yyVAL.cbTail.version = trimQuotes(yyDollar[2].str)
+			yyVAL.cbTail.versionPos = yyDollar[2].pos
 			yyVAL.cbTail.options = nil
 		}
-	case 21:
+	case 29:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line berksfile.y:419
+//line berksfile.y:571
 		{
 			yyVAL.cbTail.version = ""
 			yyVAL.cbTail.options = yyDollar[3].opts
 		}
-	case 22:
+	case 30:
 		yyDollar = yyS[yypt-6 : yypt+1]
-//line berksfile.y:423
+//line berksfile.y:575
 		{
 			yyVAL.cbTail.version = trimQuotes(yyDollar[2].str)
+			yyVAL.cbTail.versionPos = yyDollar[2].pos
 			yyVAL.cbTail.options = yyDollar[5].opts
 		}
-	case 23:
+	case 31:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line berksfile.y:427
+//line berksfile.y:580
 		{
 			yyVAL.cbTail.version = ""
 			yyVAL.cbTail.options = yyDollar[2].opts
 		}
-	case 24:
+	case 32:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line berksfile.y:431
+//line berksfile.y:584
 		{
 			yyVAL.cbTail.version = trimQuotes(yyDollar[2].str)
+			yyVAL.cbTail.versionPos = yyDollar[2].pos
 			yyVAL.cbTail.options = yyDollar[4].opts
 		}
-	case 25:
+	case 33:
 		yyDollar = yyS[yypt-0 : yypt+1]
-//line berksfile.y:435
+//line berksfile.y:589
 		{
 			yyVAL.cbTail.version = ""
 			yyVAL.cbTail.options = nil
 		}
-	case 26:
+	case 34:
 		yyDollar = yyS[yypt-5 : yypt+1]
-//line berksfile.y:442
+//line berksfile.y:596
 		{
 			// For multiple groups, we need to create separate Group entries
 			// but the cookbooks will be shared across groups
@@ -952,8 +1148,8 @@ yydefault:
 				groupNames[i] = src.URL // We're reusing Source.URL to store group names
 			}
 
-			// Add group names to each cookbook
-			for _, cb := range yyDollar[4].cookbooks {
+			// Add group names to each cookbook, including those of nested groups
+			for _, cb := range yyDollar[4].collections.cookbooks {
 				cb.Groups = append(cb.Groups, groupNames...)
 			}
 
@@ -968,84 +1164,129 @@ yydefault:
 
 			yyVAL.group = &Group{
 				Name:      groupName,
-				Cookbooks: yyDollar[4].cookbooks,
+				Cookbooks: yyDollar[4].collections.cookbooks,
+				Nested:    yyDollar[4].collections.groups,
 			}
 		}
-	case 27:
+	case 35:
+		yyDollar = yyS[yypt-6 : yypt+1]
+//line berksfile.y:624
+		{
+			// A group composed of other groups has their cookbooks
+			if len(yyDollar[2].sources) > 1 {
+				yylex.(*Lexer).errorAt(yyDollar[1].pos, "a composed group takes one name")
+			}
+			included := make([]string, len(yyDollar[5].sources))
+			for i, src := range yyDollar[5].sources {
+				included[i] = src.URL
+			}
+
+			yyVAL.group = &Group{
+				Name:     yyDollar[2].sources[0].URL,
+				Includes: included,
+			}
+		}
+	case 36:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line berksfile.y:472
+//line berksfile.y:642
 		{
 			yyVAL.sources = append(yyDollar[1].sources, &Source{URL: yyDollar[4].str})
 		}
-	case 28:
+	case 37:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line berksfile.y:475
+//line berksfile.y:645
 		{
 			yyVAL.sources = append(yyDollar[1].sources, &Source{URL: trimQuotes(yyDollar[4].str)})
 		}
-	case 29:
+	case 38:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line berksfile.y:478
+//line berksfile.y:648
 		{
 			yyVAL.sources = []*Source{{URL: yyDollar[1].str}}
 		}
-	case 30:
+	case 39:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line berksfile.y:481
+//line berksfile.y:651
 		{
 			yyVAL.sources = []*Source{{URL: trimQuotes(yyDollar[1].str)}}
 		}
-	case 31:
+	case 40:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line berksfile.y:484
+//line berksfile.y:654
 		{
 			yyVAL.sources = []*Source{{URL: yyDollar[2].str}}
 		}
-	case 32:
+	case 41:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line berksfile.y:487
+//line berksfile.y:657
 		{
 			yyVAL.sources = []*Source{{URL: trimQuotes(yyDollar[2].str)}}
 		}
-	case 33:
+	case 42:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line berksfile.y:493
+//line berksfile.y:663
 		{
-			yyVAL.cookbooks = yyDollar[1].cookbooks
+			yyVAL.collections = yyDollar[1].collections
 		}
-	case 34:
+	case 43:
 		yyDollar = yyS[yypt-0 : yypt+1]
-//line berksfile.y:496
+//line berksfile.y:666
 		{
-			yyVAL.cookbooks = []*CookbookDef{}
+			yyVAL.collections.cookbooks = []*CookbookDef{}
+			yyVAL.collections.groups = []*Group{}
 		}
-	case 35:
+	case 44:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line berksfile.y:502
+//line berksfile.y:673
 		{
-			yyVAL.cookbooks = append(yyDollar[1].cookbooks, yyDollar[2].cookbook)
+			yyVAL.collections.cookbooks = append(yyDollar[1].collections.cookbooks, yyDollar[2].cookbook)
+			yyVAL.collections.groups = yyDollar[1].collections.groups
 		}
-	case 36:
+	case 45:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line berksfile.y:505
+//line berksfile.y:677
 		{
-			yyVAL.cookbooks = yyDollar[1].cookbooks
+			// A nested group's cookbooks belong to the enclosing group too
+			yyVAL.collections.cookbooks = append(yyDollar[1].collections.cookbooks, yyDollar[2].group.Cookbooks...)
+			yyVAL.collections.groups = append(yyDollar[1].collections.groups, yyDollar[2].group)
 		}
-	case 37:
+	case 46:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line berksfile.y:682
+		{
+			yyVAL.collections = yyDollar[1].collections
+		}
+	case 47:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line berksfile.y:685
+		{
+			yyVAL.collections = yyDollar[1].collections
+			Errflag = 0
+		}
+	case 48:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line berksfile.y:508
+//line berksfile.y:689
 		{
-			yyVAL.cookbooks = []*CookbookDef{yyDollar[1].cookbook}
+			yyVAL.collections.cookbooks = []*CookbookDef{yyDollar[1].cookbook}
+			yyVAL.collections.groups = []*Group{}
 		}
-	case 38:
+	case 49:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line berksfile.y:511
+//line berksfile.y:693
 		{
-			yyVAL.cookbooks = []*CookbookDef{}
+			yyVAL.collections.cookbooks = append([]*CookbookDef{}, yyDollar[1].group.Cookbooks...)
+			yyVAL.collections.groups = []*Group{yyDollar[1].group}
 		}
-	case 39:
+	case 50:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line berksfile.y:697
+		{
+			yyVAL.collections.cookbooks = []*CookbookDef{}
+			yyVAL.collections.groups = []*Group{}
+		}
+	case 51:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line berksfile.y:517
+//line berksfile.y:704
 		{
 			m := map[string]string{yyDollar[1].kv.key: yyDollar[1].kv.value}
 			for k, v := range yyDollar[2].opts {
@@ -1053,9 +1294,9 @@ yydefault:
 			}
 			yyVAL.opts = m
 		}
-	case 40:
+	case 52:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line berksfile.y:527
+//line berksfile.y:714
 		{
 			m := map[string]string{yyDollar[2].kv.key: yyDollar[2].kv.value}
 			for k, v := range yyDollar[3].opts {
@@ -1063,29 +1304,37 @@ yydefault:
 			}
 			yyVAL.opts = m
 		}
-	case 41:
+	case 53:
 		yyDollar = yyS[yypt-0 : yypt+1]
-//line berksfile.y:534
+//line berksfile.y:721
 		{
 			yyVAL.opts = map[string]string{}
 		}
-	case 42:
+	case 54:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line berksfile.y:540
+//line berksfile.y:727
 		{
 			yyVAL.kv.key = yyDollar[1].str
 			yyVAL.kv.value = trimQuotes(yyDollar[3].str)
 		}
-	case 43:
+	case 55:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line berksfile.y:731
+		{
+			// A bare value, as in submodules: true
+			yyVAL.kv.key = yyDollar[1].str
+			yyVAL.kv.value = yyDollar[3].str
+		}
+	case 56:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line berksfile.y:544
+//line berksfile.y:736
 		{
 			yyVAL.kv.key = yyDollar[2].str
 			yyVAL.kv.value = trimQuotes(yyDollar[4].str)
 		}
-	case 44:
+	case 57:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line berksfile.y:548
+//line berksfile.y:740
 		{
 			yyVAL.kv.key = trimQuotes(yyDollar[1].str)
 			yyVAL.kv.value = trimQuotes(yyDollar[3].str)
@@ -0,0 +1,56 @@
+package berksfile
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// cookbookStatement matches the start of a cookbook statement, capturing the
+// indentation, the quote character and the cookbook name
+var cookbookStatement = regexp.MustCompile(`^(\s*)cookbook\s*\(?\s*(['"])([^'"]+)['"]`)
+
+// ReplaceCookbook rewrites the Berksfile content so the cookbook statement for
+// oldName declares newName with constraint instead. Indentation, quoting and
+// trailing comments are kept; source options on the old statement are dropped.
+// If newName is already declared, the oldName statement is removed instead.
+// It reports whether a statement for oldName was found.
+func ReplaceCookbook(content, oldName, newName, constraint string) (string, bool) {
+	lines := strings.SplitAfter(content, "\n")
+
+	declared := false
+	for _, line := range lines {
+		if m := cookbookStatement.FindStringSubmatch(line); m != nil && m[3] == newName {
+			declared = true
+			break
+		}
+	}
+
+	found := false
+	result := make([]string, 0, len(lines))
+	for _, line := range lines {
+		m := cookbookStatement.FindStringSubmatch(line)
+		if m == nil || m[3] != oldName {
+			result = append(result, line)
+			continue
+		}
+		found = true
+		if declared {
+			continue
+		}
+
+		indent, quote := m[1], m[2]
+		statement := fmt.Sprintf("%scookbook %s%s%s", indent, quote, newName, quote)
+		if constraint != "" {
+			statement += fmt.Sprintf(", %s%s%s", quote, constraint, quote)
+		}
+
+		body := strings.TrimRight(line, "\r\n")
+		if idx := indexOutsideQuotes(body, "#"); idx >= 0 {
+			statement += " " + body[idx:]
+		}
+		result = append(result, statement+line[len(body):])
+	}
+
+	return strings.Join(result, ""), found
+}
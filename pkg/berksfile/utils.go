@@ -1,10 +1,13 @@
 package berksfile
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
 	"github.com/bdwyertech/go-berkshelf/pkg/template"
 )
 
@@ -18,6 +21,40 @@ func Load(filepath string) (*Berksfile, error) {
 	return Parse(data)
 }
 
+// Fingerprint returns what the Berksfile declares once its conditionals and
+// interpolations are evaluated, in a stable form, so a cached resolution is
+// reused only where the Berksfile declares the same cookbooks and sources
+func (b *Berksfile) Fingerprint() ([]byte, error) {
+	type cookbook struct {
+		Name         string                    `json:"name"`
+		Constraint   string                    `json:"constraint,omitempty"`
+		Source       *berkshelf.SourceLocation `json:"source,omitempty"`
+		Groups       []string                  `json:"groups,omitempty"`
+		MetadataName string                    `json:"metadata_name,omitempty"`
+	}
+	cookbooks := func(defs []*CookbookDef) []cookbook {
+		result := make([]cookbook, 0, len(defs))
+		for _, def := range defs {
+			cb := cookbook{Name: def.Name, Groups: slices.Sorted(slices.Values(def.Groups)), MetadataName: def.MetadataName}
+			if def.Constraint != nil {
+				cb.Constraint = def.Constraint.String()
+			}
+			if def.Source != nil && (def.Source.Type != "" || def.Source.URL != "" || def.Source.Path != "") {
+				cb.Source = def.Source
+			}
+			result = append(result, cb)
+		}
+		return result
+	}
+	return json.Marshal(struct {
+		Sources     []*berkshelf.SourceLocation `json:"sources"`
+		Cookbooks   []cookbook                  `json:"cookbooks"`
+		Overrides   []cookbook                  `json:"overrides"`
+		Ignored     []string                    `json:"ignored"`
+		HasMetadata bool                        `json:"metadata"`
+	}{b.Sources, cookbooks(b.Cookbooks), cookbooks(b.Overrides), slices.Sorted(slices.Values(b.Ignored)), b.HasMetadata})
+}
+
 // Find searches for a Policyfile.rb in the given directory and parent directories
 func Find(startDir string) (string, error) {
 	dir := startDir
@@ -38,6 +75,37 @@ func Find(startDir string) (string, error) {
 	return "", fmt.Errorf("Berksfile not found in %s or any parent directory", startDir)
 }
 
+// ApplyGroupSources returns cookbooks with a default supermarket source assigned to
+// those that declare no source of their own and belong to a group in groupSources.
+// When a cookbook is in several mapped groups, its first listed group wins.
+// Cookbooks are copied rather than modified in place.
+func ApplyGroupSources(cookbooks []*CookbookDef, groupSources map[string]string) []*CookbookDef {
+	if len(groupSources) == 0 {
+		return cookbooks
+	}
+
+	result := make([]*CookbookDef, 0, len(cookbooks))
+	for _, cookbook := range cookbooks {
+		if cookbook.Source != nil && cookbook.Source.Type != "" {
+			result = append(result, cookbook)
+			continue
+		}
+
+		assigned := cookbook
+		for _, group := range cookbook.Groups {
+			if url, ok := groupSources[group]; ok {
+				cb := *cookbook
+				cb.Source = &berkshelf.SourceLocation{Type: "supermarket", URL: url}
+				assigned = &cb
+				break
+			}
+		}
+		result = append(result, assigned)
+	}
+
+	return result
+}
+
 // FilterCookbooksByGroup filters cookbooks based on --only and --except flags
 func FilterCookbooksByGroup(cookbooks []*CookbookDef, only []string, except []string) []*CookbookDef {
 	if len(only) == 0 && len(except) == 0 {
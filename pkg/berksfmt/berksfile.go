@@ -0,0 +1,111 @@
+package berksfmt
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berksfile"
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+)
+
+// Berksfile formats a Berksfile: sources first, then metadata, cookbooks,
+// overrides and ignored cookbooks, and groups, each group's cookbooks before its nested groups. Conditionals are
+// formatted but their statements are not reordered. A Berksfile that does
+// not parse is not formatted.
+func Berksfile(content string) (string, error) {
+	before, err := berksfile.Parse(content)
+	if err != nil {
+		return "", err
+	}
+
+	formatted := format(content, normalizeBerksfile, berksfileRank, func(n *node) bool { return n.keyword == "group" })
+
+	// The formatted Berksfile must declare exactly what the original does
+	after, err := berksfile.Parse(formatted)
+	if err != nil {
+		return "", fmt.Errorf("formatted Berksfile does not parse: %w", err)
+	}
+	if !sameBerksfile(before, after) {
+		return "", fmt.Errorf("formatting would change what the Berksfile declares")
+	}
+	return formatted, nil
+}
+
+// berksfileRank orders sources, metadata, cookbooks, overrides and ignored
+// cookbooks, and groups
+func berksfileRank(n *node) int {
+	switch n.keyword {
+	case "source":
+		return 0
+	case "metadata":
+		return 1
+	case "override", "ignore", "exclude":
+		return 3
+	case "group":
+		return 4
+	}
+	return 2
+}
+
+// normalizeBerksfile sets the canonical form of a Berksfile statement
+func normalizeBerksfile(n *node, code string) {
+	n.head = code
+	switch n.keyword {
+	case "source", "metadata", "cookbook", "override", "ignore", "exclude":
+		if keyword, args, ok := parseCall(code, false); ok {
+			n.head, n.args = keyword, args
+		}
+	case "group":
+		header, suffix := code, ""
+		if n.block {
+			header, _ = cutBlockSuffix(code)
+			suffix = " do"
+		}
+		keyword, args, ok := parseCall(header, true)
+		if !ok {
+			return
+		}
+		for i, arg := range args {
+			// group 'test' is group :test
+			if len(arg) > 2 && arg[0] == '\'' && isIdent(unquote(arg)) {
+				args[i] = ":" + unquote(arg)
+			}
+		}
+		n.head, n.args, n.suffix = keyword, args, suffix
+	}
+}
+
+// sameBerksfile reports whether two Berksfiles declare the same sources,
+// cookbooks and groups
+func sameBerksfile(a, b *berksfile.Berksfile) bool {
+	if !reflect.DeepEqual(a.Sources, b.Sources) || a.HasMetadata != b.HasMetadata || !reflect.DeepEqual(a.GroupIncludes, b.GroupIncludes) || !slices.Equal(a.Ignored, b.Ignored) {
+		return false
+	}
+	if !slices.Equal(slices.Sorted(maps.Keys(a.Groups)), slices.Sorted(maps.Keys(b.Groups))) {
+		return false
+	}
+	return reflect.DeepEqual(declarations(a.Cookbooks), declarations(b.Cookbooks)) && reflect.DeepEqual(declarations(a.Overrides), declarations(b.Overrides))
+}
+
+// declaration is what a Berksfile declares about a cookbook
+type declaration struct {
+	constraint   string
+	source       *berkshelf.SourceLocation
+	groups       []string
+	metadataName string
+}
+
+func declarations(cookbooks []*berksfile.CookbookDef) map[string]declaration {
+	declared := make(map[string]declaration, len(cookbooks))
+	for _, cb := range cookbooks {
+		declared[cb.Name] = declaration{
+			constraint:   cb.Constraint.String(),
+			source:       cb.Source,
+			groups:       slices.Sorted(slices.Values(cb.Groups)),
+			metadataName: cb.MetadataName,
+		}
+	}
+	return declared
+}
@@ -0,0 +1,333 @@
+// Package berksfmt rewrites Berksfiles and Policyfiles in a canonical layout:
+// statements ordered by kind, two-space indentation, single-quoted strings
+// where Ruby allows, `key: value` options and aligned cookbook constraints.
+// Comments are kept with the statement they precede.
+package berksfmt
+
+import (
+	"slices"
+	"strings"
+)
+
+// rank orders statements by kind; statements of the same rank keep their order
+type rank func(*node) int
+
+// last is the rank of comments after the last statement of a block
+const last = 1 << 30
+
+// node is a statement, or a block of statements, with the comments before it
+type node struct {
+	// comments are the comment lines before the statement, with "" for a
+	// blank line separating them from each other or the statement
+	comments []string
+	// blank reports whether a blank line came before the node
+	blank bool
+	// keyword is the first word of the statement
+	keyword string
+	// head and args are the normalized statement: head alone when it could
+	// not be normalized, or the keyword and its comma separated arguments
+	head string
+	args []string
+	// suffix follows the arguments, e.g. the `do` opening a block
+	suffix string
+	// trailing is the comment after the statement
+	trailing string
+	// body and end are set for a block: its statements and closing line
+	body  []*node
+	block bool
+	end   string
+	// branch marks an elsif, else or when line of a conditional
+	branch bool
+}
+
+// text returns the statement without alignment
+func (n *node) text() string {
+	if len(n.args) == 0 {
+		return n.head + n.suffix
+	}
+	return n.head + " " + strings.Join(n.args, ", ") + n.suffix
+}
+
+// format formats content, normalizing statements with normalize and
+// ordering them with order in blocks where ordered allows
+func format(content string, normalize func(*node, string), order rank, ordered func(*node) bool) string {
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	p := &parser{lines: lines, normalize: normalize}
+	nodes := p.parseBlock()
+
+	var header []string
+	if len(nodes) > 0 {
+		// Comments at the top of the file separated from the first statement
+		// by a blank line stay at the top
+		if i := slices.Index(nodes[0].comments, ""); i >= 0 {
+			header = nodes[0].comments[:i]
+			nodes[0].comments = nodes[0].comments[i+1:]
+			nodes[0].blank = false
+		}
+	}
+
+	var b strings.Builder
+	for _, comment := range header {
+		b.WriteString(comment + "\n")
+	}
+	if len(header) > 0 && len(nodes) > 0 {
+		b.WriteString("\n")
+	}
+	render(&b, nodes, 0, order, true, ordered)
+	return b.String()
+}
+
+// parser reads statements from lines
+type parser struct {
+	lines     []string
+	pos       int
+	normalize func(*node, string)
+}
+
+// parseBlock reads statements until the end of a block or the file, leaving
+// the closing line to be read by the caller
+func (p *parser) parseBlock() []*node {
+	var nodes []*node
+	var comments []string
+	blank := false
+
+	for p.pos < len(p.lines) {
+		code, comment := splitComment(p.lines[p.pos])
+		code = strings.TrimSpace(code)
+		if code == "" {
+			p.pos++
+			if comment == "" {
+				if len(comments) == 0 {
+					blank = len(nodes) > 0 || blank
+				} else if comments[len(comments)-1] != "" {
+					comments = append(comments, "")
+				}
+				continue
+			}
+			comments = append(comments, comment)
+			continue
+		}
+		if code == "end" || strings.HasPrefix(code, "end ") || strings.HasPrefix(code, "end.") {
+			break
+		}
+
+		n := &node{comments: comments, blank: blank, keyword: firstWord(code)}
+		comments, blank = nil, false
+		if n.keyword == "elsif" || n.keyword == "else" || n.keyword == "when" || n.keyword == "in" {
+			n.branch = true
+		}
+
+		// Join the lines of a statement spanning several
+		p.pos++
+		for continues(code) && p.pos < len(p.lines) {
+			next, nextComment := splitComment(p.lines[p.pos])
+			if comment != "" {
+				n.comments = append(n.comments, comment)
+			}
+			code, comment = joinContinuation(code, strings.TrimSpace(next)), nextComment
+			p.pos++
+		}
+		n.trailing = comment
+
+		if opensBlock(code) && !n.branch {
+			n.block = true
+			n.body = p.parseBlock()
+			if p.pos < len(p.lines) {
+				end, endComment := splitComment(p.lines[p.pos])
+				n.end = strings.TrimSpace(end)
+				if endComment != "" {
+					n.end += " " + endComment
+				}
+				p.pos++
+			} else {
+				n.end = "end"
+			}
+		}
+		p.normalize(n, code)
+		nodes = append(nodes, n)
+	}
+
+	if len(comments) > 0 {
+		if comments[len(comments)-1] == "" {
+			comments = comments[:len(comments)-1]
+		}
+		nodes = append(nodes, &node{comments: comments, blank: blank})
+	}
+	return nodes
+}
+
+// render writes nodes at depth, sorted by order when sorted is set
+func render(b *strings.Builder, nodes []*node, depth int, order rank, sorted bool, ordered func(*node) bool) {
+	indent := strings.Repeat("  ", depth)
+	nodeRank := func(n *node) int {
+		if n.head == "" && !n.block {
+			return last
+		}
+		return order(n)
+	}
+	if sorted {
+		nodes = slices.Clone(nodes)
+		slices.SortStableFunc(nodes, func(a, b *node) int { return nodeRank(a) - nodeRank(b) })
+	}
+	aligned := alignments(nodes)
+
+	for i, n := range nodes {
+		if i > 0 {
+			prev := nodes[i-1]
+			separate := n.blank || prev.block || (n.block && !n.branch)
+			if sorted && nodeRank(prev) != nodeRank(n) {
+				separate = true
+			}
+			if separate && !n.branch && !prev.branch {
+				b.WriteString("\n")
+			}
+		}
+
+		for _, comment := range n.comments {
+			if comment == "" {
+				b.WriteString("\n")
+				continue
+			}
+			b.WriteString(indent + comment + "\n")
+		}
+		if n.head == "" && !n.block {
+			continue
+		}
+
+		statement := n.text()
+		if width, ok := aligned[n]; ok {
+			first := n.head + " " + n.args[0] + ","
+			statement = first + strings.Repeat(" ", width-len(first)+1) + strings.Join(n.args[1:], ", ")
+		}
+		if n.trailing != "" {
+			statement += " " + n.trailing
+		}
+		if n.branch {
+			b.WriteString(strings.Repeat("  ", max(depth-1, 0)) + statement + "\n")
+			continue
+		}
+		b.WriteString(indent + statement + "\n")
+
+		if n.block {
+			render(b, n.body, depth+1, order, ordered(n), ordered)
+			b.WriteString(indent + n.end + "\n")
+		}
+	}
+}
+
+// alignments returns the width to pad the first argument of cookbook
+// statements to, so that the arguments after it line up in each run of
+// consecutive cookbook statements
+func alignments(nodes []*node) map[*node]int {
+	aligned := make(map[*node]int)
+	var run []*node
+	flush := func() {
+		if len(run) > 1 {
+			width := 0
+			for _, n := range run {
+				width = max(width, len(n.head)+1+len(n.args[0])+1)
+			}
+			for _, n := range run {
+				aligned[n] = width
+			}
+		}
+		run = nil
+	}
+
+	for _, n := range nodes {
+		if n.keyword != "cookbook" || n.block || len(n.args) < 2 || len(n.comments) > 0 || n.blank {
+			flush()
+		}
+		if n.keyword == "cookbook" && !n.block && len(n.args) >= 2 {
+			run = append(run, n)
+		}
+	}
+	flush()
+	return aligned
+}
+
+// opensBlock reports whether a statement opens a block closed by end
+func opensBlock(code string) bool {
+	switch firstWord(code) {
+	case "if", "unless", "case", "while", "until", "begin":
+		return true
+	}
+	_, ok := cutBlockSuffix(code)
+	return ok
+}
+
+// cutBlockSuffix returns code without a trailing `do` or `do |args|`
+func cutBlockSuffix(code string) (string, bool) {
+	trimmed := code
+	if strings.HasSuffix(trimmed, "|") {
+		if i := strings.LastIndex(trimmed[:len(trimmed)-1], "|"); i >= 0 {
+			trimmed = strings.TrimSpace(trimmed[:i])
+		}
+	}
+	if trimmed == "do" {
+		return "", true
+	}
+	if before, ok := strings.CutSuffix(trimmed, " do"); ok {
+		return strings.TrimSpace(before), true
+	}
+	return code, false
+}
+
+// continues reports whether a statement continues on the next line
+func continues(code string) bool {
+	if strings.HasSuffix(code, ",") || strings.HasSuffix(code, "\\") {
+		return true
+	}
+	depth := 0
+	for _, tok := range tokenize(code) {
+		switch tok.kind {
+		case tokOpen:
+			depth++
+		case tokClose:
+			depth--
+		}
+	}
+	return depth > 0
+}
+
+// joinContinuation joins a statement to its next line
+func joinContinuation(code, next string) string {
+	code = strings.TrimSuffix(code, "\\")
+	code = strings.TrimRight(code, " ")
+	if strings.HasSuffix(code, "[") || strings.HasSuffix(code, "(") || strings.HasPrefix(next, "]") || strings.HasPrefix(next, ")") {
+		return code + next
+	}
+	return code + " " + next
+}
+
+// splitComment splits a line into its code and trailing comment
+func splitComment(line string) (string, string) {
+	var quote rune
+	escaped := false
+	for i, r := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case quote != 0:
+			if r == '\\' {
+				escaped = true
+			} else if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == '#':
+			return strings.TrimRight(line[:i], " \t"), strings.TrimRight(line[i:], " \t")
+		}
+	}
+	return strings.TrimRight(line, " \t"), ""
+}
+
+// firstWord returns the leading identifier of a statement
+func firstWord(code string) string {
+	end := strings.IndexFunc(code, func(r rune) bool { return !isIdentRune(r) })
+	if end < 0 {
+		return code
+	}
+	return code[:end]
+}
@@ -0,0 +1,149 @@
+package berksfmt
+
+import (
+	"strings"
+	"testing"
+)
+
+const messyBerksfile = `# Project Berksfile
+
+group :integration do
+    cookbook "test-helper", path: "test/fixtures/helper" # fixtures
+end
+cookbook "nginx", "~> 12.0"
+cookbook 'apt'  ,  '>= 7.0'
+# PostgreSQL from git
+cookbook 'postgresql', :git => "https://github.com/sous-chefs/postgresql.git", :branch => 'main'
+metadata
+source "https://supermarket.chef.io"
+
+group 'test' do
+  cookbook 'rspec-helper', '= 1.0.0'
+    group :unit do
+      cookbook "chefspec-helper"
+    end
+end
+group :ci => [:test]
+
+if ENV['PRIVATE']
+cookbook 'private', git: 'git@example.com:private.git'
+else
+  cookbook "public"
+end
+# trailing comment
+`
+
+const formattedBerksfile = `# Project Berksfile
+
+source 'https://supermarket.chef.io'
+
+metadata
+
+cookbook 'nginx', '~> 12.0'
+cookbook 'apt',   '>= 7.0'
+# PostgreSQL from git
+cookbook 'postgresql', git: 'https://github.com/sous-chefs/postgresql.git', branch: 'main'
+
+if ENV['PRIVATE']
+  cookbook 'private', git: 'git@example.com:private.git'
+else
+  cookbook 'public'
+end
+
+group :integration do
+  cookbook 'test-helper', path: 'test/fixtures/helper' # fixtures
+end
+
+group :test do
+  cookbook 'rspec-helper', '= 1.0.0'
+
+  group :unit do
+    cookbook 'chefspec-helper'
+  end
+end
+
+group :ci => [:test]
+
+# trailing comment
+`
+
+func TestBerksfile(t *testing.T) {
+	formatted, err := Berksfile(messyBerksfile)
+	if err != nil {
+		t.Fatalf("Berksfile() error = %v", err)
+	}
+	if formatted != formattedBerksfile {
+		t.Errorf("Berksfile() =\n%s\nwant\n%s", formatted, formattedBerksfile)
+	}
+
+	again, err := Berksfile(formatted)
+	if err != nil {
+		t.Fatalf("Berksfile() error = %v", err)
+	}
+	if again != formatted {
+		t.Errorf("formatting is not idempotent:\n%s", again)
+	}
+}
+
+func TestBerksfileQuoting(t *testing.T) {
+	formatted, err := Berksfile(`cookbook "it's", "~> 1.0"` + "\n" + `cookbook "app", path: "#{__dir__}/app"` + "\n")
+	if err != nil {
+		t.Fatalf("Berksfile() error = %v", err)
+	}
+	// Strings that need double quotes keep them
+	want := `cookbook "it's", '~> 1.0'` + "\n" + `cookbook 'app',  path: "#{__dir__}/app"` + "\n"
+	if formatted != want {
+		t.Errorf("Berksfile() =\n%s\nwant\n%s", formatted, want)
+	}
+}
+
+func TestBerksfileOverrides(t *testing.T) {
+	formatted, err := Berksfile("override \"apt\", \"= 7.4.0\"\ngroup :test do\n  cookbook 'rspec'\nend\ncookbook 'nginx'\n")
+	if err != nil {
+		t.Fatalf("Berksfile() error = %v", err)
+	}
+	want := "cookbook 'nginx'\n\noverride 'apt', '= 7.4.0'\n\ngroup :test do\n  cookbook 'rspec'\nend\n"
+	if formatted != want {
+		t.Errorf("Berksfile() =\n%s\nwant\n%s", formatted, want)
+	}
+}
+
+func TestBerksfileInvalid(t *testing.T) {
+	if _, err := Berksfile("cookbook 'nginx', 'bogus'\n"); err == nil || !strings.Contains(err.Error(), "invalid version constraint") {
+		t.Errorf("Berksfile() error = %v, want the parse error", err)
+	}
+}
+
+func TestPolicyfile(t *testing.T) {
+	formatted, err := Policyfile(`name "app"
+cookbook "nginx", "~> 12.0"
+run_list [
+  "recipe[app::default]",
+]
+default_source :supermarket
+default['app']['port'] = 80
+default_source :chef_repo, ".." do |s|
+s.preferred_for "app"
+end
+`)
+	if err != nil {
+		t.Fatalf("Policyfile() error = %v", err)
+	}
+	want := `name 'app'
+
+default_source :supermarket
+
+default_source :chef_repo, '..' do |s|
+  s.preferred_for "app"
+end
+
+run_list ['recipe[app::default]']
+
+cookbook 'nginx', '~> 12.0'
+
+default['app']['port'] = 80
+`
+	if formatted != want {
+		t.Errorf("Policyfile() =\n%s\nwant\n%s", formatted, want)
+	}
+}
@@ -0,0 +1,62 @@
+package berksfmt
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/policyfile"
+)
+
+// Policyfile formats a Policyfile.rb: its name first, then default sources,
+// run lists, cookbooks and attributes. Blocks, such as a default_source
+// block, are formatted but their statements are not reordered.
+func Policyfile(content string) (string, error) {
+	formatted := format(content, normalizePolicyfile, policyfileRank, func(*node) bool { return false })
+
+	// Policyfiles with directives beyond what the parser reads cannot be
+	// compared, but those it reads must not change
+	if before, err := policyfile.Parse(content); err == nil {
+		after, err := policyfile.Parse(formatted)
+		if err != nil {
+			return "", fmt.Errorf("formatted Policyfile does not parse: %w", err)
+		}
+		if !reflect.DeepEqual(before, after) {
+			return "", fmt.Errorf("formatting would change what the Policyfile declares")
+		}
+	}
+	return formatted, nil
+}
+
+// policyfileRank orders a Policyfile as `chef generate policyfile` does
+func policyfileRank(n *node) int {
+	switch n.keyword {
+	case "name":
+		return 0
+	case "default_source", "include_policy":
+		return 1
+	case "run_list", "named_run_list":
+		return 2
+	case "cookbook":
+		return 3
+	}
+	return 4
+}
+
+// normalizePolicyfile sets the canonical form of a Policyfile statement
+func normalizePolicyfile(n *node, code string) {
+	n.head = code
+	switch n.keyword {
+	case "name", "default_source", "include_policy", "run_list", "named_run_list", "cookbook":
+		header, suffix := code, ""
+		if n.block {
+			var ok bool
+			if header, ok = cutBlockSuffix(code); !ok {
+				return
+			}
+			suffix = code[len(header):]
+		}
+		if keyword, args, ok := parseCall(header, false); ok {
+			n.head, n.args, n.suffix = keyword, args, suffix
+		}
+	}
+}
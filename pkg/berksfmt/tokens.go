@@ -0,0 +1,263 @@
+package berksfmt
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Kinds of tokens in a statement
+const (
+	tokWord   = iota // an identifier, number or other bare word
+	tokString        // a quoted string, with its quotes
+	tokSymbol        // a :symbol
+	tokLabel         // a `key:` hash label
+	tokArrow         // =>
+	tokComma
+	tokOpen  // ( [ {
+	tokClose // ) ] }
+	tokOther
+)
+
+type token struct {
+	kind int
+	text string
+}
+
+// tokenize splits a statement into tokens
+func tokenize(code string) []token {
+	var tokens []token
+	runes := []rune(code)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '\'' || r == '"':
+			j := i + 1
+			for j < len(runes) && runes[j] != r {
+				if runes[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			j = min(j+1, len(runes))
+			tokens = append(tokens, token{tokString, string(runes[i:j])})
+			i = j
+		case r == ':' && i+1 < len(runes) && isIdentRune(runes[i+1]):
+			j := i + 1
+			for j < len(runes) && (isIdentRune(runes[j]) || runes[j] == '?' || runes[j] == '!') {
+				j++
+			}
+			tokens = append(tokens, token{tokSymbol, string(runes[i:j])})
+			i = j
+		case r == ':' && i+1 < len(runes) && (runes[i+1] == '\'' || runes[i+1] == '"'):
+			// A quoted symbol is kept as a bare word
+			j := i + 2
+			for j < len(runes) && runes[j] != runes[i+1] {
+				j++
+			}
+			j = min(j+1, len(runes))
+			tokens = append(tokens, token{tokWord, string(runes[i:j])})
+			i = j
+		case r == '=' && i+1 < len(runes) && runes[i+1] == '>':
+			tokens = append(tokens, token{tokArrow, "=>"})
+			i += 2
+		case r == ',':
+			tokens = append(tokens, token{tokComma, ","})
+			i++
+		case r == '(' || r == '[' || r == '{':
+			tokens = append(tokens, token{tokOpen, string(r)})
+			i++
+		case r == ')' || r == ']' || r == '}':
+			tokens = append(tokens, token{tokClose, string(r)})
+			i++
+		case isIdentRune(r) || r == '.' || r == '-' || r == '@' || r == '$':
+			j := i
+			for j < len(runes) && (isIdentRune(runes[j]) || runes[j] == '.' || runes[j] == '-' || runes[j] == '@' || runes[j] == '$' || runes[j] == '?' || runes[j] == '!') {
+				j++
+			}
+			if j < len(runes) && runes[j] == ':' && (j+1 == len(runes) || runes[j+1] != ':') {
+				tokens = append(tokens, token{tokLabel, string(runes[i:j])})
+				i = j + 1
+				continue
+			}
+			tokens = append(tokens, token{tokWord, string(runes[i:j])})
+			i = j
+		default:
+			tokens = append(tokens, token{tokOther, string(r)})
+			i++
+		}
+	}
+	return tokens
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// isIdent reports whether s can be written as a bare label or symbol
+func isIdent(s string) bool {
+	if s == "" || unicode.IsDigit([]rune(s)[0]) {
+		return false
+	}
+	for _, r := range s {
+		if !isIdentRune(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// quote returns a string literal single-quoted, unless it needs double
+// quotes for interpolation or escapes, or holds a single quote
+func quote(literal string) string {
+	if len(literal) < 2 {
+		return literal
+	}
+	content := literal[1 : len(literal)-1]
+	if literal[0] == '\'' {
+		return literal
+	}
+	if strings.ContainsAny(content, `\'`) || strings.Contains(content, "#{") {
+		return literal
+	}
+	return "'" + content + "'"
+}
+
+// unquote returns the content of a string literal
+func unquote(literal string) string {
+	if len(literal) < 2 {
+		return literal
+	}
+	return literal[1 : len(literal)-1]
+}
+
+// parseCall splits a statement into its keyword and arguments, rendered
+// canonically. It reports false for statements it does not understand,
+// which are left as they are. Hash options are written `key: value`, or
+// `:key => value` when rockets is set.
+func parseCall(code string, rockets bool) (string, []string, bool) {
+	tokens := tokenize(code)
+	if len(tokens) == 0 || tokens[0].kind != tokWord {
+		return "", nil, false
+	}
+	keyword, rest := tokens[0].text, tokens[1:]
+
+	// cookbook('nginx') and cookbook 'nginx', { git: '...' }
+	if len(rest) > 0 && rest[0].text == "(" && closes(rest, 0) == len(rest)-1 {
+		rest = rest[1 : len(rest)-1]
+	}
+	var args []string
+	for _, arg := range splitArgs(rest) {
+		if len(arg) > 0 && arg[0].text == "{" && closes(arg, 0) == len(arg)-1 {
+			for _, pair := range splitArgs(arg[1 : len(arg)-1]) {
+				rendered, ok := renderArg(pair, rockets)
+				if !ok {
+					return "", nil, false
+				}
+				args = append(args, rendered)
+			}
+			continue
+		}
+		rendered, ok := renderArg(arg, rockets)
+		if !ok {
+			return "", nil, false
+		}
+		args = append(args, rendered)
+	}
+	return keyword, args, true
+}
+
+// closes returns the index of the token closing the one opened at open
+func closes(tokens []token, open int) int {
+	depth := 0
+	for i := open; i < len(tokens); i++ {
+		switch tokens[i].kind {
+		case tokOpen:
+			depth++
+		case tokClose:
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// splitArgs splits tokens at top level commas
+func splitArgs(tokens []token) [][]token {
+	if len(tokens) == 0 {
+		return nil
+	}
+	var args [][]token
+	depth, start := 0, 0
+	for i, tok := range tokens {
+		switch tok.kind {
+		case tokOpen:
+			depth++
+		case tokClose:
+			depth--
+		case tokComma:
+			if depth == 0 {
+				args = append(args, tokens[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(args, tokens[start:])
+}
+
+// renderArg renders an argument: a value or a hash pair
+func renderArg(arg []token, rockets bool) (string, bool) {
+	switch {
+	case len(arg) >= 2 && arg[0].kind == tokLabel:
+		value, ok := renderValue(arg[1:])
+		if rockets {
+			return ":" + arg[0].text + " => " + value, ok
+		}
+		return arg[0].text + ": " + value, ok
+	case len(arg) >= 3 && arg[1].kind == tokArrow && (arg[0].kind == tokSymbol || arg[0].kind == tokString):
+		value, ok := renderValue(arg[2:])
+		key := strings.TrimPrefix(arg[0].text, ":")
+		if arg[0].kind == tokString {
+			key = unquote(arg[0].text)
+			if !isIdent(key) {
+				return quote(arg[0].text) + " => " + value, ok
+			}
+		}
+		if rockets {
+			return ":" + key + " => " + value, ok
+		}
+		return key + ": " + value, ok
+	}
+	return renderValue(arg)
+}
+
+// renderValue renders a string, symbol, bare word or array of them
+func renderValue(value []token) (string, bool) {
+	if len(value) == 1 {
+		switch value[0].kind {
+		case tokString:
+			return quote(value[0].text), true
+		case tokSymbol, tokWord:
+			return value[0].text, true
+		}
+		return "", false
+	}
+	if len(value) >= 2 && value[0].text == "[" && closes(value, 0) == len(value)-1 {
+		var elements []string
+		for _, element := range splitArgs(value[1 : len(value)-1]) {
+			if len(element) == 0 {
+				continue
+			}
+			rendered, ok := renderValue(element)
+			if !ok {
+				return "", false
+			}
+			elements = append(elements, rendered)
+		}
+		return "[" + strings.Join(elements, ", ") + "]", true
+	}
+	return "", false
+}
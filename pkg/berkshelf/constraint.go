@@ -42,6 +42,36 @@ func NewConstraint(c string) (*Constraint, error) {
 	}, nil
 }
 
+// NewChefVersionConstraint builds the constraint for a cookbook's chef_version
+// (or ohai_version) declarations. Each declaration is a list of requirements
+// that must all hold; Chef accepts a client matching any one declaration.
+func NewChefVersionConstraint(declarations [][]string) (*Constraint, error) {
+	var raws, converted []string
+	for _, requirements := range declarations {
+		if len(requirements) == 0 {
+			continue
+		}
+		var parts []string
+		for _, requirement := range requirements {
+			parts = append(parts, convertRubyConstraint(requirement))
+		}
+		raws = append(raws, strings.Join(requirements, ", "))
+		converted = append(converted, strings.Join(parts, ", "))
+	}
+	if len(raws) == 0 {
+		return NewConstraint("")
+	}
+
+	constraint, err := semver.NewConstraint(strings.Join(converted, " || "))
+	if err != nil {
+		return nil, fmt.Errorf("invalid chef_version %q: %w", strings.Join(raws, " || "), err)
+	}
+	return &Constraint{
+		raw:        strings.Join(raws, " || "),
+		constraint: constraint,
+	}, nil
+}
+
 // MustConstraint creates a constraint and panics on error
 func MustConstraint(c string) *Constraint {
 	constraint, err := NewConstraint(c)
@@ -136,6 +166,72 @@ func convertPessimisticConstraint(version string) string {
 	return version
 }
 
+var (
+	// wildcardShorthandRegex matches "2.x", "2.7.*" and a bare "x" or "*"
+	wildcardShorthandRegex = regexp.MustCompile(`^(?:(\d+)(?:\.(\d+))?\.)?[xX*]$`)
+	// tildeShorthandRegex matches npm-style "~2.7" (but not Ruby's "~>")
+	tildeShorthandRegex = regexp.MustCompile(`^~\s*(\d+)(?:\.(\d+))?(?:\.(\d+))?$`)
+	// caretShorthandRegex matches npm-style "^2.7"
+	caretShorthandRegex = regexp.MustCompile(`^\^\s*(\d+)(?:\.(\d+))?(?:\.(\d+))?$`)
+)
+
+// ExpandShorthand converts constraint shorthands common in other ecosystems
+// to the equivalent Chef constraint, e.g. "2.x" to "~> 2.0", "~2.7" to
+// "~> 2.7.0" and "^2.7" to "~> 2.7". It reports whether c was converted;
+// anything else, including shorthands with no single Chef equivalent such as
+// "^2.7.1", is returned unchanged.
+func ExpandShorthand(c string) (string, bool) {
+	c = strings.TrimSpace(c)
+
+	if m := wildcardShorthandRegex.FindStringSubmatch(c); m != nil {
+		switch {
+		case m[1] == "":
+			return ">= 0.0.0", true // x
+		case m[2] == "":
+			return fmt.Sprintf("~> %s.0", m[1]), true // 2.x
+		default:
+			return fmt.Sprintf("~> %s.%s.0", m[1], m[2]), true // 2.7.x
+		}
+	}
+
+	if m := tildeShorthandRegex.FindStringSubmatch(c); m != nil {
+		switch {
+		case m[2] == "":
+			return fmt.Sprintf("~> %s.0", m[1]), true // ~2 allows 2.x
+		case m[3] == "":
+			return fmt.Sprintf("~> %s.%s.0", m[1], m[2]), true // ~2.7 allows 2.7.x
+		default:
+			return fmt.Sprintf("~> %s.%s.%s", m[1], m[2], m[3]), true
+		}
+	}
+
+	if m := caretShorthandRegex.FindStringSubmatch(c); m != nil {
+		major, minor, patch := m[1], m[2], m[3]
+		if major != "0" {
+			// ^2.7 allows anything below 3.0.0, which ~> can only express from a .0 patch
+			switch {
+			case minor == "":
+				return fmt.Sprintf("~> %s.0", major), true
+			case patch == "" || patch == "0":
+				return fmt.Sprintf("~> %s.%s", major, minor), true
+			}
+			return c, false
+		}
+		// Below 1.0 the minor version is the breaking one: ^0.2.3 allows 0.2.x
+		switch {
+		case minor == "":
+			return "~> 0.0", true
+		case patch == "":
+			return fmt.Sprintf("~> 0.%s.0", minor), true
+		case minor != "0":
+			return fmt.Sprintf("~> 0.%s.%s", minor, patch), true
+		}
+		return c, false
+	}
+
+	return c, false
+}
+
 // mustParseInt parses an integer and panics on error (for internal use)
 func mustParseInt(s string) int {
 	var result int
@@ -357,4 +357,61 @@ var _ = Describe("Preservation: Non-Buggy Constraint Behavior", func() {
 			Expect(c.Check(v)).To(BeFalse())
 		})
 	})
+
+	DescribeTable("ExpandShorthand",
+		func(input, expected string, converted bool) {
+			result, ok := berkshelf.ExpandShorthand(input)
+			Expect(ok).To(Equal(converted))
+			Expect(result).To(Equal(expected))
+		},
+		Entry("major wildcard", "2.x", "~> 2.0", true),
+		Entry("minor wildcard", "2.7.*", "~> 2.7.0", true),
+		Entry("bare wildcard", "x", ">= 0.0.0", true),
+		Entry("tilde major", "~2", "~> 2.0", true),
+		Entry("tilde minor", "~2.7", "~> 2.7.0", true),
+		Entry("tilde patch", "~ 2.7.1", "~> 2.7.1", true),
+		Entry("caret major", "^2", "~> 2.0", true),
+		Entry("caret minor", "^2.7", "~> 2.7", true),
+		Entry("caret zero patch", "^2.7.0", "~> 2.7", true),
+		Entry("caret below 1.0", "^0.2", "~> 0.2.0", true),
+		Entry("caret below 1.0 with patch", "^0.2.3", "~> 0.2.3", true),
+		Entry("caret with no Chef equivalent", "^2.7.1", "^2.7.1", false),
+		Entry("pessimistic is not a shorthand", "~> 2.7", "~> 2.7", false),
+		Entry("plain version", ">= 1.0", ">= 1.0", false),
+	)
+
+	It("expanded shorthands behave like the original range", func() {
+		expanded, _ := berkshelf.ExpandShorthand("~2.7")
+		c := berkshelf.MustConstraint(expanded)
+		Expect(c.Check(berkshelf.MustVersion("2.7.9"))).To(BeTrue())
+		Expect(c.Check(berkshelf.MustVersion("2.8.0"))).To(BeFalse())
+	})
+})
+
+var _ = Describe("NewChefVersionConstraint", func() {
+	DescribeTable("Check",
+		func(declarations [][]string, version string, want bool) {
+			c, err := berkshelf.NewChefVersionConstraint(declarations)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(c.Check(berkshelf.MustVersion(version))).To(Equal(want))
+		},
+		Entry("single declaration", [][]string{{">= 15.3", "< 19"}}, "18.2.7", true),
+		Entry("single declaration - excluded", [][]string{{">= 15.3", "< 19"}}, "19.0.0", false),
+		Entry("pessimistic requirement", [][]string{{"~> 16.4"}}, "16.9.0", true),
+		Entry("pessimistic requirement - excluded", [][]string{{"~> 16.4"}}, "17.0.0", false),
+		Entry("any declaration matches", [][]string{{"~> 14.0"}, {">= 17"}}, "17.1.0", true),
+		Entry("no declaration matches", [][]string{{"~> 14.0"}, {">= 17"}}, "15.0.0", false),
+		Entry("no declarations", [][]string{}, "12.0.0", true),
+	)
+
+	It("renders declarations as Chef writes them", func() {
+		c, err := berkshelf.NewChefVersionConstraint([][]string{{">= 15.3", "< 19"}, {"~> 14.0"}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.String()).To(Equal(">= 15.3, < 19 || ~> 14.0"))
+	})
+
+	It("rejects invalid requirements", func() {
+		_, err := berkshelf.NewChefVersionConstraint([][]string{{"not a version"}})
+		Expect(err).To(HaveOccurred())
+	})
 })
@@ -14,6 +14,12 @@ type Cookbook struct {
 	Source       SourceLocation         `json:"source,omitempty"`
 	Path         string                 `json:"path,omitempty"`
 	TarballURL   string                 `json:"tarball_url,omitempty"`
+	// FileChecksums maps file paths, relative to the cookbook root, to the
+	// hex MD5 or SHA-256 the source published for them
+	FileChecksums map[string]string `json:"file_checksums,omitempty"`
+	// Digest pins the artifact the source resolved the version to, such as
+	// the manifest digest of an OCI artifact
+	Digest string `json:"digest,omitempty"`
 }
 
 // Metadata represents cookbook metadata from metadata.rb or metadata.json
@@ -36,6 +42,9 @@ type Metadata struct {
 	Recipes         map[string]string      `json:"recipes,omitempty"`
 	Issues          string                 `json:"issues_url,omitempty"`
 	Source          string                 `json:"source_url,omitempty"`
+	ExternalURL     string                 `json:"external_url,omitempty"`
+	Deprecated      bool                   `json:"deprecated,omitempty"`
+	RootFiles       []string               `json:"root_files,omitempty"`
 	ChefVersion     *Constraint            `json:"chef_version,omitempty"`
 	OhaiVersion     *Constraint            `json:"ohai_version,omitempty"`
 }
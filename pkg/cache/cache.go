@@ -2,8 +2,6 @@ package cache
 
 import (
 	"context"
-	"crypto/sha256"
-	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -12,15 +10,22 @@ import (
 	"time"
 
 	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	"github.com/bdwyertech/go-berkshelf/pkg/digest"
 	"github.com/bdwyertech/go-berkshelf/pkg/errors"
+	"github.com/bdwyertech/go-berkshelf/pkg/fsys"
+	"github.com/bdwyertech/go-berkshelf/pkg/logging"
 )
 
+var log = logging.For("cache")
+
 // Cache provides advanced caching capabilities
 type Cache struct {
 	basePath    string
 	maxAge      time.Duration
 	maxSize     int64 // Maximum cache size in bytes
 	currentSize int64
+	hasher      digest.Hasher
+	fs          fsys.FS
 	mu          sync.RWMutex
 	stats       *CacheStats
 }
@@ -48,7 +53,12 @@ type CacheEntry struct {
 
 // NewCache creates a new cache
 func NewCache(basePath string, maxAge time.Duration, maxSize int64) (*Cache, error) {
-	if err := os.MkdirAll(basePath, 0755); err != nil {
+	return NewCacheFS(fsys.OS(), basePath, maxAge, maxSize)
+}
+
+// NewCacheFS creates a new cache that stores its entries in filesystem
+func NewCacheFS(filesystem fsys.FS, basePath string, maxAge time.Duration, maxSize int64) (*Cache, error) {
+	if err := filesystem.MkdirAll(basePath, 0755); err != nil {
 		return nil, errors.NewFileSystemError("failed to create cache directory", err)
 	}
 
@@ -56,6 +66,8 @@ func NewCache(basePath string, maxAge time.Duration, maxSize int64) (*Cache, err
 		basePath: basePath,
 		maxAge:   maxAge,
 		maxSize:  maxSize,
+		hasher:   defaultHasher(),
+		fs:       filesystem,
 		stats:    &CacheStats{},
 	}
 
@@ -67,6 +79,19 @@ func NewCache(basePath string, maxAge time.Duration, maxSize int64) (*Cache, err
 	return cache, nil
 }
 
+// SetAlgorithm changes the algorithm that hashes keys and checksums entries.
+// Entries cached under another algorithm are no longer found, and age out.
+func (c *Cache) SetAlgorithm(algorithm digest.Algorithm) error {
+	h, err := digest.Lookup(algorithm)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.hasher = h
+	return nil
+}
+
 // Get retrieves an item from the cache
 func (c *Cache) Get(key string) ([]byte, bool) {
 	c.mu.RLock()
@@ -86,7 +111,7 @@ func (c *Cache) Get(key string) ([]byte, bool) {
 	}
 
 	// Read the cached data
-	data, err := os.ReadFile(entry.Path)
+	data, err := fsys.ReadFile(c.fs, entry.Path)
 	if err != nil {
 		c.stats.recordMiss()
 		go c.removeEntry(key) // Async cleanup
@@ -132,18 +157,18 @@ func (c *Cache) Put(key string, data []byte) error {
 	}
 
 	// Create directory if needed
-	if err := os.MkdirAll(filepath.Dir(entry.Path), 0755); err != nil {
+	if err := c.fs.MkdirAll(filepath.Dir(entry.Path), 0755); err != nil {
 		return errors.NewFileSystemError("failed to create cache directory", err)
 	}
 
 	// Write data to cache
-	if err := os.WriteFile(entry.Path, data, 0644); err != nil {
+	if err := fsys.WriteFile(c.fs, entry.Path, data, 0644); err != nil {
 		return errors.NewFileSystemError("failed to write cache entry", err)
 	}
 
 	// Write metadata
 	if err := c.writeEntry(entry); err != nil {
-		os.Remove(entry.Path) // Cleanup on failure
+		c.fs.Remove(entry.Path) // Cleanup on failure
 		return err
 	}
 
@@ -178,11 +203,11 @@ func (c *Cache) Clear() error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	if err := os.RemoveAll(c.basePath); err != nil {
+	if err := c.fs.RemoveAll(c.basePath); err != nil {
 		return errors.NewFileSystemError("failed to clear cache", err)
 	}
 
-	if err := os.MkdirAll(c.basePath, 0755); err != nil {
+	if err := c.fs.MkdirAll(c.basePath, 0755); err != nil {
 		return errors.NewFileSystemError("failed to recreate cache directory", err)
 	}
 
@@ -263,10 +288,17 @@ func (c *Cache) HitRate() float64 {
 
 // Private methods
 
+func defaultHasher() digest.Hasher {
+	h, err := digest.Lookup(digest.Default)
+	if err != nil {
+		panic(err)
+	}
+	return h
+}
+
 func (c *Cache) getPath(key string) string {
-	hash := sha256.Sum256([]byte(key))
-	hashStr := hex.EncodeToString(hash[:])
-	return filepath.Join(c.basePath, hashStr[:2], hashStr[2:4], hashStr)
+	d := c.sum([]byte(key))
+	return filepath.Join(c.basePath, d.Hex[:2], d.Hex[2:4], d.Hex)
 }
 
 func (c *Cache) getMetadataPath(key string) string {
@@ -277,20 +309,30 @@ func (c *Cache) getCookbookKey(name, version string) string {
 	return fmt.Sprintf("cookbook:%s:%s", name, version)
 }
 
+func (c *Cache) sum(data []byte) digest.Digest {
+	h := c.hasher.New()
+	h.Write(data)
+	return digest.Of(c.hasher.Algorithm(), h)
+}
+
 func (c *Cache) calculateChecksum(data []byte) string {
-	hash := sha256.Sum256(data)
-	return hex.EncodeToString(hash[:])
+	return c.sum(data).String()
 }
 
+// verifyChecksum checks data against the checksum recorded with it, in
+// whichever algorithm was in use when it was cached
 func (c *Cache) verifyChecksum(data []byte, expectedChecksum string) bool {
-	actualChecksum := c.calculateChecksum(data)
-	return actualChecksum == expectedChecksum
+	expected, err := digest.Parse(expectedChecksum)
+	if err != nil {
+		return false
+	}
+	return expected.Verify(data) == nil
 }
 
 func (c *Cache) getEntry(key string) (*CacheEntry, bool) {
 	metaPath := c.getMetadataPath(key)
 
-	data, err := os.ReadFile(metaPath)
+	data, err := fsys.ReadFile(c.fs, metaPath)
 	if err != nil {
 		return nil, false
 	}
@@ -311,7 +353,7 @@ func (c *Cache) writeEntry(entry *CacheEntry) error {
 		return errors.NewFileSystemError("failed to marshal cache entry", err)
 	}
 
-	if err := os.WriteFile(metaPath, data, 0644); err != nil {
+	if err := fsys.WriteFile(c.fs, metaPath, data, 0644); err != nil {
 		return errors.NewFileSystemError("failed to write cache metadata", err)
 	}
 
@@ -325,13 +367,13 @@ func (c *Cache) removeEntry(key string) error {
 	}
 
 	// Remove data file
-	if err := os.Remove(entry.Path); err != nil && !os.IsNotExist(err) {
+	if err := c.fs.Remove(entry.Path); err != nil && !os.IsNotExist(err) {
 		return errors.NewFileSystemError("failed to remove cache entry", err)
 	}
 
 	// Remove metadata file
 	metaPath := c.getMetadataPath(key)
-	if err := os.Remove(metaPath); err != nil && !os.IsNotExist(err) {
+	if err := c.fs.Remove(metaPath); err != nil && !os.IsNotExist(err) {
 		return errors.NewFileSystemError("failed to remove cache metadata", err)
 	}
 
@@ -357,7 +399,7 @@ func (c *Cache) updateAccess(entry *CacheEntry) {
 func (c *Cache) calculateSize() error {
 	var totalSize int64
 
-	err := filepath.Walk(c.basePath, func(path string, info os.FileInfo, err error) error {
+	err := fsys.Walk(c.fs, c.basePath, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
@@ -421,13 +463,13 @@ func (c *Cache) enforceSizeLimit() error {
 func (c *Cache) getAllEntries() ([]*CacheEntry, error) {
 	var entries []*CacheEntry
 
-	err := filepath.Walk(c.basePath, func(path string, info os.FileInfo, err error) error {
+	err := fsys.Walk(c.fs, c.basePath, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
 
 		if filepath.Ext(path) == ".meta" {
-			data, err := os.ReadFile(path)
+			data, err := fsys.ReadFile(c.fs, path)
 			if err != nil {
 				return nil // Skip corrupted metadata
 			}
@@ -4,10 +4,13 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	"github.com/bdwyertech/go-berkshelf/pkg/digest"
+	"github.com/bdwyertech/go-berkshelf/pkg/fsys"
 )
 
 func TestCache_Basic(t *testing.T) {
@@ -404,3 +407,79 @@ func TestCache_ChecksumValidation(t *testing.T) {
 		t.Error("Expected a cache miss due to checksum validation failure")
 	}
 }
+
+func TestCache_ChecksumAlgorithm(t *testing.T) {
+	cache, err := NewCache(t.TempDir(), time.Hour, 0)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+
+	data := []byte("test data")
+	if err := cache.Put("legacy", data); err != nil {
+		t.Fatalf("Failed to put data: %v", err)
+	}
+
+	entry, _ := cache.getEntry("legacy")
+	if !strings.HasPrefix(entry.Checksum, "sha256:") {
+		t.Errorf("Checksum = %s, want a sha256 digest", entry.Checksum)
+	}
+
+	// Entries cached before algorithms were recorded have bare SHA-256 checksums
+	entry.Checksum = strings.TrimPrefix(entry.Checksum, "sha256:")
+	if err := cache.writeEntry(entry); err != nil {
+		t.Fatal(err)
+	}
+	if got, found := cache.Get("legacy"); !found || string(got) != string(data) {
+		t.Errorf("Get() of a bare checksum entry = %q, %v", got, found)
+	}
+
+	if err := cache.SetAlgorithm("crc32"); err == nil {
+		t.Error("SetAlgorithm(crc32) succeeded")
+	}
+	if err := cache.SetAlgorithm(digest.BLAKE3); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Put("current", data); err != nil {
+		t.Fatalf("Failed to put data: %v", err)
+	}
+	entry, _ = cache.getEntry("current")
+	if !strings.HasPrefix(entry.Checksum, "blake3:") {
+		t.Errorf("Checksum = %s, want a blake3 digest", entry.Checksum)
+	}
+	if got, found := cache.Get("current"); !found || string(got) != string(data) {
+		t.Errorf("Get() = %q, %v", got, found)
+	}
+}
+
+func TestCache_MemoryFS(t *testing.T) {
+	memory := fsys.Memory()
+	cache, err := NewCacheFS(memory, "/cache", time.Hour, 1024*1024)
+	if err != nil {
+		t.Fatalf("NewCacheFS() error = %v", err)
+	}
+	if err := cache.Put("test-key", []byte("test data")); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if _, err := os.Stat("/cache"); !os.IsNotExist(err) {
+		t.Errorf("the in-memory cache wrote to disk")
+	}
+
+	// A cache reopened on the same filesystem sees the entry
+	reopened, err := NewCacheFS(memory, "/cache", time.Hour, 1024*1024)
+	if err != nil {
+		t.Fatalf("NewCacheFS() error = %v", err)
+	}
+	if data, found := reopened.Get("test-key"); !found || string(data) != "test data" {
+		t.Errorf("Get() = %q, %v, want the cached data", data, found)
+	}
+	if reopened.Size() != cache.Size() {
+		t.Errorf("Size() = %d, want %d", reopened.Size(), cache.Size())
+	}
+
+	if err := reopened.Clear(); err != nil {
+		t.Fatalf("Clear() error = %v", err)
+	}
+	if _, found := reopened.Get("test-key"); found {
+		t.Errorf("Get() found a cleared entry")
+	}
+}
@@ -0,0 +1,53 @@
+package cache
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/source"
+)
+
+// DefaultCapabilityTTL is how long probed source capabilities are trusted
+const DefaultCapabilityTTL = 24 * time.Hour
+
+// CapabilityCache remembers which optional endpoints each source serves, so
+// the probe runs once a day rather than on every command
+type CapabilityCache struct {
+	cache *Cache
+}
+
+// NewCapabilityCache creates a capability cache rooted at basePath whose
+// entries expire after ttl
+func NewCapabilityCache(basePath string, ttl time.Duration) (*CapabilityCache, error) {
+	cache, err := NewCache(basePath, ttl, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &CapabilityCache{cache: cache}, nil
+}
+
+// GetCapabilities returns the capabilities recorded for a source, if still fresh
+func (c *CapabilityCache) GetCapabilities(sourceURL string) (source.Capabilities, bool) {
+	var capabilities source.Capabilities
+	data, ok := c.cache.Get(capabilityCacheKey(sourceURL))
+	if !ok {
+		return capabilities, false
+	}
+	if err := json.Unmarshal(data, &capabilities); err != nil {
+		return capabilities, false
+	}
+	return capabilities, true
+}
+
+// PutCapabilities records the capabilities probed for a source
+func (c *CapabilityCache) PutCapabilities(sourceURL string, capabilities source.Capabilities) error {
+	data, err := json.Marshal(capabilities)
+	if err != nil {
+		return err
+	}
+	return c.cache.Put(capabilityCacheKey(sourceURL), data)
+}
+
+func capabilityCacheKey(sourceURL string) string {
+	return "capabilities:" + sourceURL
+}
@@ -0,0 +1,29 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/source"
+)
+
+func TestCapabilityCache(t *testing.T) {
+	capabilities, err := NewCapabilityCache(t.TempDir(), time.Hour)
+	if err != nil {
+		t.Fatalf("NewCapabilityCache() error = %v", err)
+	}
+
+	url := "https://supermarket.example.com"
+	if _, ok := capabilities.GetCapabilities(url); ok {
+		t.Fatal("GetCapabilities() found an entry in an empty cache")
+	}
+
+	want := source.Capabilities{Universe: true, RangeRequests: true}
+	if err := capabilities.PutCapabilities(url, want); err != nil {
+		t.Fatalf("PutCapabilities() error = %v", err)
+	}
+	got, ok := capabilities.GetCapabilities(url)
+	if !ok || got != want {
+		t.Errorf("GetCapabilities() = %+v, %v, want %+v", got, ok, want)
+	}
+}
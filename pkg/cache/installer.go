@@ -8,6 +8,7 @@ import (
 	"github.com/sourcegraph/conc/pool"
 
 	"github.com/bdwyertech/go-berkshelf/internal/config"
+	"github.com/bdwyertech/go-berkshelf/pkg/logging"
 	"github.com/bdwyertech/go-berkshelf/pkg/resolver"
 	"github.com/bdwyertech/go-berkshelf/pkg/source"
 )
@@ -109,8 +110,8 @@ func (i *Installer) DownloadAndCache(ctx context.Context, resolution *resolver.R
 
 			if err := i.downloadAndCacheCookbook(ctx, cookbook); err != nil {
 				// Log error but continue with other cookbooks
-				fmt.Printf("\nWarning: failed to cache cookbook %s@%s: %v\n",
-					cookbook.Name, cookbook.Version.String(), err)
+				log.WithField(logging.CookbookField, cookbook.Name).
+					Warnf("Failed to cache cookbook %s@%s: %v", cookbook.Name, cookbook.Version.String(), err)
 			}
 		})
 	}
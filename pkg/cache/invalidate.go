@@ -0,0 +1,117 @@
+package cache
+
+import (
+	"strings"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/fsys"
+	"github.com/bdwyertech/go-berkshelf/pkg/lockfile"
+)
+
+// Selector picks cache entries by where they came from. An entry is selected
+// when it matches every field that is set.
+type Selector struct {
+	// SourceURL selects entries fetched from this source
+	SourceURL string
+	// Cookbook selects entries for this cookbook
+	Cookbook string
+}
+
+// Invalidate removes the entries under basePath that sel selects and returns
+// how many were removed. Cached resolutions are removed when they lock a
+// selected cookbook, as they may point at an artifact that has changed.
+func Invalidate(basePath string, sel Selector) (int, error) {
+	cache, err := NewCache(basePath, 0, 0)
+	if err != nil {
+		return 0, err
+	}
+	return cache.DeleteFunc(func(entry *CacheEntry) bool {
+		return sel.matches(cache.fs, entry)
+	})
+}
+
+// DeleteFunc removes every entry for which match returns true and returns how
+// many were removed
+func (c *Cache) DeleteFunc(match func(entry *CacheEntry) bool) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entries, err := c.getAllEntries()
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, entry := range entries {
+		if !match(entry) {
+			continue
+		}
+		if err := c.removeEntry(entry.Key); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// matches reports whether sel selects entry, based on its key. Cached
+// resolutions are read from filesystem.
+func (sel Selector) matches(filesystem fsys.FS, entry *CacheEntry) bool {
+	kind, rest, _ := strings.Cut(entry.Key, ":")
+	switch kind {
+	case "versions":
+		// versions:<url>:<cookbook>, where the URL contains colons
+		i := strings.LastIndex(rest, ":")
+		if i < 0 {
+			return false
+		}
+		return sel.matchSource(rest[:i]) && sel.matchCookbook(rest[i+1:])
+	case "capabilities":
+		return sel.Cookbook == "" && sel.matchSource(rest)
+	case "cookbook":
+		name, _, _ := strings.Cut(rest, ":")
+		return sel.SourceURL == "" && sel.matchCookbook(name)
+	case "solution":
+		data, err := fsys.ReadFile(filesystem, entry.Path)
+		if err != nil {
+			return false
+		}
+		lockFile, err := lockfile.FromJSON(data)
+		if err != nil {
+			// An unreadable resolution is of no use either
+			return true
+		}
+		return sel.matchLockFile(lockFile)
+	default:
+		return false
+	}
+}
+
+// matchLockFile reports whether lockFile locks a selected cookbook
+func (sel Selector) matchLockFile(lockFile *lockfile.LockFile) bool {
+	for key, src := range lockFile.Sources {
+		for name, cookbook := range src.Cookbooks {
+			if !sel.matchCookbook(name) {
+				continue
+			}
+			if sel.SourceURL == "" || sel.matchSource(key) || sel.matchSource(src.URL) ||
+				(cookbook.Source != nil && sel.matchSource(cookbook.Source.URL)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (sel Selector) matchSource(url string) bool {
+	return sel.SourceURL == "" || SameSource(sel.SourceURL, url)
+}
+
+func (sel Selector) matchCookbook(name string) bool {
+	return sel.Cookbook == "" || sel.Cookbook == name
+}
+
+// SameSource reports whether two source URLs refer to the same source,
+// ignoring case and trailing slashes
+func SameSource(a, b string) bool {
+	return a != "" && strings.EqualFold(strings.TrimRight(a, "/"), strings.TrimRight(b, "/"))
+}
@@ -0,0 +1,82 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	"github.com/bdwyertech/go-berkshelf/pkg/lockfile"
+	"github.com/bdwyertech/go-berkshelf/pkg/source"
+)
+
+func TestInvalidate(t *testing.T) {
+	const private = "https://supermarket.example.com"
+	const public = "https://supermarket.chef.io"
+
+	populate := func(t *testing.T) string {
+		t.Helper()
+		dir := t.TempDir()
+		versions, _ := NewVersionCache(dir, time.Hour)
+		capabilities, _ := NewCapabilityCache(dir, time.Hour)
+		solutions, _ := NewSolutionCache(dir, time.Hour)
+
+		for _, url := range []string{private, public} {
+			for _, name := range []string{"nginx", "apt"} {
+				if err := versions.Put(url, name, []*berkshelf.Version{berkshelf.MustVersion("1.0.0")}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := capabilities.PutCapabilities(url, source.Capabilities{}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		lf := lockfile.NewLockFile()
+		lf.Sources[private] = &lockfile.SourceLock{
+			Cookbooks: map[string]*lockfile.CookbookLock{"nginx": {Version: "1.0.0"}},
+		}
+		if err := solutions.Put("with-private", lf); err != nil {
+			t.Fatal(err)
+		}
+		lf = lockfile.NewLockFile()
+		lf.Sources[public] = &lockfile.SourceLock{
+			Cookbooks: map[string]*lockfile.CookbookLock{"apt": {Version: "1.0.0"}},
+		}
+		if err := solutions.Put("public-only", lf); err != nil {
+			t.Fatal(err)
+		}
+		return dir
+	}
+
+	tests := []struct {
+		name    string
+		sel     Selector
+		removed int
+	}{
+		// 2 version lists, capabilities and the resolution locking nginx
+		{"source", Selector{SourceURL: private + "/"}, 4},
+		// nginx versions from both sources and the resolution locking it
+		{"cookbook", Selector{Cookbook: "nginx"}, 3},
+		{"source and cookbook", Selector{SourceURL: private, Cookbook: "apt"}, 1},
+		{"no match", Selector{SourceURL: "https://other.example.com"}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := populate(t)
+			removed, err := Invalidate(dir, tt.sel)
+			if err != nil {
+				t.Fatalf("Invalidate() error = %v", err)
+			}
+			if removed != tt.removed {
+				t.Errorf("Invalidate() = %d, want %d", removed, tt.removed)
+			}
+
+			// Entries from other sources survive
+			versions, _ := NewVersionCache(dir, time.Hour)
+			if _, ok := versions.Get(public, "apt"); !ok {
+				t.Error("Expected public apt versions to remain cached")
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package cache
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/source"
+)
+
+// DefaultResponseTTL is how long an API response is kept for revalidation
+// after it was last downloaded in full
+const DefaultResponseTTL = 30 * 24 * time.Hour
+
+// ResponseCache keeps Supermarket API responses with their ETag and
+// Last-Modified, so unchanged metadata costs a 304 instead of a full body
+type ResponseCache struct {
+	cache *Cache
+}
+
+// NewResponseCache creates a response cache rooted at basePath whose entries
+// expire after ttl
+func NewResponseCache(basePath string, ttl time.Duration) (*ResponseCache, error) {
+	cache, err := NewCache(basePath, ttl, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &ResponseCache{cache: cache}, nil
+}
+
+// GetResponse returns the response kept for url
+func (c *ResponseCache) GetResponse(url string) (source.CachedResponse, bool) {
+	var response source.CachedResponse
+	data, ok := c.cache.Get(responseCacheKey(url))
+	if !ok {
+		return response, false
+	}
+	if err := json.Unmarshal(data, &response); err != nil {
+		return response, false
+	}
+	return response, true
+}
+
+// PutResponse keeps the response of url
+func (c *ResponseCache) PutResponse(url string, response source.CachedResponse) error {
+	data, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+	return c.cache.Put(responseCacheKey(url), data)
+}
+
+func responseCacheKey(url string) string {
+	return "response:" + url
+}
@@ -0,0 +1,30 @@
+package cache
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/source"
+)
+
+func TestResponseCache(t *testing.T) {
+	responses, err := NewResponseCache(t.TempDir(), time.Hour)
+	if err != nil {
+		t.Fatalf("NewResponseCache() error = %v", err)
+	}
+
+	url := "https://supermarket.example.com/api/v1/cookbooks/nginx"
+	if _, ok := responses.GetResponse(url); ok {
+		t.Fatal("GetResponse() found an entry in an empty cache")
+	}
+
+	want := source.CachedResponse{ETag: `"abc"`, Body: []byte(`{"name":"nginx"}`)}
+	if err := responses.PutResponse(url, want); err != nil {
+		t.Fatalf("PutResponse() error = %v", err)
+	}
+	got, ok := responses.GetResponse(url)
+	if !ok || got.ETag != want.ETag || !bytes.Equal(got.Body, want.Body) {
+		t.Errorf("GetResponse() = %+v, %v, want %+v", got, ok, want)
+	}
+}
@@ -0,0 +1,110 @@
+package cache
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	"github.com/bdwyertech/go-berkshelf/pkg/lockfile"
+)
+
+// ShelvedCookbook is a cookbook version kept in the cookbook cache
+type ShelvedCookbook struct {
+	Name       string    `json:"name"`
+	Version    string    `json:"version"`
+	Path       string    `json:"path"`
+	Size       int64     `json:"size"`
+	Checksum   string    `json:"checksum,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	AccessedAt time.Time `json:"accessed_at"`
+}
+
+// Cookbooks returns the cookbook versions in the cache, sorted by name and
+// then version. With names, only those cookbooks are returned.
+func (c *Cache) Cookbooks(names ...string) ([]ShelvedCookbook, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entries, err := c.getAllEntries()
+	if err != nil {
+		return nil, err
+	}
+
+	var cookbooks []ShelvedCookbook
+	for _, entry := range entries {
+		kind, rest, _ := strings.Cut(entry.Key, ":")
+		name, version, ok := strings.Cut(rest, ":")
+		if kind != "cookbook" || !ok || (len(names) > 0 && !slices.Contains(names, name)) {
+			continue
+		}
+		cookbooks = append(cookbooks, ShelvedCookbook{
+			Name:       name,
+			Version:    version,
+			Path:       entry.Path,
+			Size:       entry.Size,
+			Checksum:   entry.Checksum,
+			CreatedAt:  entry.CreatedAt,
+			AccessedAt: entry.AccessedAt,
+		})
+	}
+
+	slices.SortFunc(cookbooks, func(a, b ShelvedCookbook) int {
+		if c := strings.Compare(a.Name, b.Name); c != 0 {
+			return c
+		}
+		return compareVersions(a.Version, b.Version)
+	})
+	return cookbooks, nil
+}
+
+// DeleteCookbook removes a cookbook version from the cache and reports
+// whether it was there
+func (c *Cache) DeleteCookbook(name, version string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := c.getCookbookKey(name, version)
+	if _, exists := c.getEntry(key); !exists {
+		return false, nil
+	}
+	if err := c.removeEntry(key); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// LockUsage reports whether lockFile locks name at version and, if so, the
+// locked cookbooks that depend on it, sorted by name. Removing such a
+// version from the cache means the next install downloads it again.
+func LockUsage(lockFile *lockfile.LockFile, name, version string) (bool, []string) {
+	if lockFile == nil {
+		return false, nil
+	}
+	locked, _, ok := lockFile.GetCookbook(name)
+	if !ok || locked.Version != version {
+		return false, nil
+	}
+
+	cookbooks := lockFile.ListCookbooks()
+	var dependents []string
+	for _, dependent := range slices.Sorted(maps.Keys(cookbooks)) {
+		if _, ok := cookbooks[dependent].Dependencies[name]; ok {
+			dependents = append(dependents, dependent)
+		}
+	}
+	return true, dependents
+}
+
+// compareVersions orders versions semantically, falling back to comparing
+// the strings when either does not parse
+func compareVersions(a, b string) int {
+	va, errA := berkshelf.NewVersion(a)
+	vb, errB := berkshelf.NewVersion(b)
+	if errA != nil || errB != nil {
+		return cmp.Compare(a, b)
+	}
+	return va.Compare(vb)
+}
@@ -0,0 +1,94 @@
+package cache
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	"github.com/bdwyertech/go-berkshelf/pkg/lockfile"
+)
+
+func TestCookbooks(t *testing.T) {
+	c, err := NewCache(t.TempDir(), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, cb := range []struct{ name, version string }{
+		{"nginx", "10.0.0"}, {"nginx", "9.2.0"}, {"apt", "7.4.0"},
+	} {
+		cookbook := &berkshelf.Cookbook{Name: cb.name, Version: berkshelf.MustVersion(cb.version)}
+		if err := c.PutCookbook(cookbook, []byte(cb.name+cb.version)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Entries other than cookbooks are not on the shelf
+	if err := c.Put("versions:https://supermarket.chef.io:nginx", []byte("[]")); err != nil {
+		t.Fatal(err)
+	}
+
+	cookbooks, err := c.Cookbooks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, cb := range cookbooks {
+		got = append(got, cb.Name+"@"+cb.Version)
+		if cb.Path == "" || cb.Size == 0 {
+			t.Errorf("%s@%s: missing path or size", cb.Name, cb.Version)
+		}
+	}
+	if want := []string{"apt@7.4.0", "nginx@9.2.0", "nginx@10.0.0"}; !slices.Equal(got, want) {
+		t.Errorf("Cookbooks() = %v, want %v", got, want)
+	}
+
+	cookbooks, err = c.Cookbooks("apt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cookbooks) != 1 || cookbooks[0].Name != "apt" {
+		t.Errorf("Cookbooks(apt) = %v, want only apt", cookbooks)
+	}
+
+	removed, err := c.DeleteCookbook("nginx", "9.2.0")
+	if err != nil || !removed {
+		t.Fatalf("DeleteCookbook(nginx, 9.2.0) = %v, %v, want true", removed, err)
+	}
+	if _, ok := c.GetCookbook("nginx", "9.2.0"); ok {
+		t.Error("nginx 9.2.0 is still cached")
+	}
+	if removed, err := c.DeleteCookbook("nginx", "9.2.0"); err != nil || removed {
+		t.Errorf("DeleteCookbook of a missing version = %v, %v, want false", removed, err)
+	}
+}
+
+func TestLockUsage(t *testing.T) {
+	lf := lockfile.NewLockFile()
+	lf.Sources["https://supermarket.chef.io"] = &lockfile.SourceLock{
+		Cookbooks: map[string]*lockfile.CookbookLock{
+			"apt":   {Version: "7.4.0"},
+			"nginx": {Version: "10.0.0", Dependencies: map[string]string{"apt": ">= 0.0.0"}},
+			"web":   {Version: "1.0.0", Dependencies: map[string]string{"apt": "~> 7.0", "nginx": "~> 10.0"}},
+		},
+	}
+
+	tests := []struct {
+		name, version string
+		locked        bool
+		dependents    []string
+	}{
+		{"apt", "7.4.0", true, []string{"nginx", "web"}},
+		{"web", "1.0.0", true, nil},
+		{"apt", "7.3.0", false, nil},
+		{"yum", "1.0.0", false, nil},
+	}
+	for _, tt := range tests {
+		locked, dependents := LockUsage(lf, tt.name, tt.version)
+		if locked != tt.locked || !slices.Equal(dependents, tt.dependents) {
+			t.Errorf("LockUsage(%s, %s) = %v, %v, want %v, %v", tt.name, tt.version, locked, dependents, tt.locked, tt.dependents)
+		}
+	}
+
+	if locked, _ := LockUsage(nil, "apt", "7.4.0"); locked {
+		t.Error("LockUsage with no lock file reported the cookbook locked")
+	}
+}
@@ -0,0 +1,137 @@
+package cache
+
+import (
+	"maps"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/digest"
+	"github.com/bdwyertech/go-berkshelf/pkg/lockfile"
+)
+
+// DefaultSolutionTTL is how long a cached resolution is considered fresh
+const DefaultSolutionTTL = 24 * time.Hour
+
+// SolutionCache stores resolved lock files keyed by a hash of their inputs so
+// repeat resolutions of an unchanged Berksfile can be skipped
+type SolutionCache struct {
+	cache *Cache
+}
+
+// SolutionKey describes the inputs that determine a resolution
+type SolutionKey struct {
+	// Berksfile is what the evaluated Berksfile declares, see
+	// berksfile.Berksfile.Fingerprint
+	Berksfile []byte
+	// Metadata is the raw metadata.rb/metadata.json content when the metadata directive is used
+	Metadata []byte
+	// PathMetadata is the raw metadata.rb/metadata.json content of the
+	// path-sourced cookbooks, by file path
+	PathMetadata map[string][]byte
+	// LockFile is the existing lock file (may be nil)
+	LockFile *lockfile.LockFile
+	// Sources lists the source URLs in effect
+	Sources []string
+	// Only and Except are the group filters
+	Only   []string
+	Except []string
+	// ChefVersion is the Chef Infra Client version chef_version constraints
+	// were checked against, empty when they were not enforced
+	ChefVersion string
+	// Constraints are the environment constraints imposed, as
+	// "name constraint"
+	Constraints []string
+	// Algorithm hashes the inputs; digest.Default when empty
+	Algorithm digest.Algorithm
+}
+
+// NewSolutionCache creates a solution cache rooted at basePath
+func NewSolutionCache(basePath string, maxAge time.Duration) (*SolutionCache, error) {
+	cache, err := NewCache(basePath, maxAge, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &SolutionCache{cache: cache}, nil
+}
+
+// Hash returns a stable digest of the resolution inputs, prefixed with the
+// algorithm that computed it
+func (k *SolutionKey) Hash() (string, error) {
+	algorithm := k.Algorithm
+	if algorithm == "" {
+		algorithm = digest.Default
+	}
+	h, err := digest.New(algorithm)
+	if err != nil {
+		return "", err
+	}
+
+	write := func(label string, data []byte) {
+		h.Write([]byte(label))
+		h.Write([]byte{0})
+		h.Write(data)
+		h.Write([]byte{0})
+	}
+	writeList := func(label string, values []string) {
+		sorted := append([]string(nil), values...)
+		sort.Strings(sorted)
+		for _, v := range sorted {
+			write(label, []byte(v))
+		}
+	}
+
+	write("berksfile", k.Berksfile)
+	write("metadata", k.Metadata)
+	for _, path := range slices.Sorted(maps.Keys(k.PathMetadata)) {
+		write("path_metadata", []byte(path))
+		write("path_metadata", k.PathMetadata[path])
+	}
+	writeList("source", k.Sources)
+	writeList("only", k.Only)
+	writeList("except", k.Except)
+	if k.ChefVersion != "" {
+		write("chef_version", []byte(k.ChefVersion))
+	}
+	writeList("constraint", k.Constraints)
+
+	// Hash only the locked cookbooks, overrides and ignored cookbooks; the
+	// generation timestamp changes on every save
+	if lf := k.LockFile; lf != nil && (len(lf.Sources) > 0 || len(lf.Overrides) > 0 || len(lf.Ignored) > 0) {
+		locked := &lockfile.LockFile{Revision: lf.Revision, Sources: lf.Sources, Overrides: lf.Overrides, Ignored: lf.Ignored}
+		data, err := locked.ToJSON()
+		if err != nil {
+			return "", err
+		}
+		write("lockfile", data)
+	}
+
+	return digest.Of(algorithm, h).String(), nil
+}
+
+// Get returns the cached lock file for a key hash
+func (s *SolutionCache) Get(hash string) (*lockfile.LockFile, bool) {
+	data, ok := s.cache.Get(solutionCacheKey(hash))
+	if !ok {
+		return nil, false
+	}
+
+	lockFile, err := lockfile.FromJSON(data)
+	if err != nil {
+		return nil, false
+	}
+	return lockFile, true
+}
+
+// Put stores a resolved lock file under a key hash
+func (s *SolutionCache) Put(hash string, lockFile *lockfile.LockFile) error {
+	data, err := lockFile.ToJSON()
+	if err != nil {
+		return err
+	}
+	return s.cache.Put(solutionCacheKey(hash), data)
+}
+
+func solutionCacheKey(hash string) string {
+	return "solution:" + hash
+}
@@ -0,0 +1,143 @@
+package cache
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/digest"
+	"github.com/bdwyertech/go-berkshelf/pkg/lockfile"
+)
+
+func TestSolutionKey_Hash(t *testing.T) {
+	base := &SolutionKey{
+		Berksfile: []byte("source 'https://supermarket.chef.io'\ncookbook 'nginx'\n"),
+		Sources:   []string{"https://supermarket.chef.io"},
+	}
+
+	hash1, err := base.Hash()
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+
+	// Same inputs produce the same hash
+	same := &SolutionKey{
+		Berksfile: []byte("source 'https://supermarket.chef.io'\ncookbook 'nginx'\n"),
+		Sources:   []string{"https://supermarket.chef.io"},
+	}
+	hash2, _ := same.Hash()
+	if hash1 != hash2 {
+		t.Errorf("Expected identical hashes, got %s and %s", hash1, hash2)
+	}
+
+	// Group filters change the hash
+	filtered := &SolutionKey{
+		Berksfile: base.Berksfile,
+		Sources:   base.Sources,
+		Only:      []string{"test"},
+	}
+	hash3, _ := filtered.Hash()
+	if hash1 == hash3 {
+		t.Error("Expected group filter to change the hash")
+	}
+
+	// The enforced Chef version changes the hash
+	chef := &SolutionKey{
+		Berksfile:   base.Berksfile,
+		Sources:     base.Sources,
+		ChefVersion: "18.2.7",
+	}
+	hash4, _ := chef.Hash()
+	if hash1 == hash4 {
+		t.Error("Expected the Chef version to change the hash")
+	}
+
+	// Environment constraints change the hash
+	constrained := &SolutionKey{
+		Berksfile:   base.Berksfile,
+		Sources:     base.Sources,
+		Constraints: []string{"nginx ~> 12.0"},
+	}
+	if hash5, _ := constrained.Hash(); hash1 == hash5 {
+		t.Error("Expected environment constraints to change the hash")
+	}
+
+	// A path cookbook's metadata changes the hash
+	pathKey := func(metadata string) string {
+		key := &SolutionKey{Berksfile: base.Berksfile, PathMetadata: map[string][]byte{"/project/app/metadata.rb": []byte(metadata)}}
+		hash, _ := key.Hash()
+		return hash
+	}
+	if pathKey("version '1.0.0'") == pathKey("version '1.1.0'") || pathKey("version '1.0.0'") == hash1 {
+		t.Error("Expected path cookbook metadata to change the hash")
+	}
+
+	// The lock file timestamp does not affect the hash
+	lf1 := lockfile.NewLockFile()
+	lf1.Sources["https://supermarket.chef.io"] = &lockfile.SourceLock{
+		Cookbooks: map[string]*lockfile.CookbookLock{"nginx": {Version: "1.0.0"}},
+	}
+	lf2 := &lockfile.LockFile{Revision: lf1.Revision, GeneratedAt: lf1.GeneratedAt.Add(time.Hour), Sources: lf1.Sources}
+
+	withLock1 := &SolutionKey{Berksfile: base.Berksfile, LockFile: lf1}
+	withLock2 := &SolutionKey{Berksfile: base.Berksfile, LockFile: lf2}
+	h1, _ := withLock1.Hash()
+	h2, _ := withLock2.Hash()
+	if h1 != h2 {
+		t.Error("Expected lock file timestamp to be ignored")
+	}
+
+	// An override added to the lock file by hand between installs changes
+	// the hash, so the cached resolution made without it is not reused
+	overridden := &lockfile.LockFile{Revision: lf1.Revision, Sources: lf1.Sources, Overrides: map[string]*lockfile.Override{
+		"nginx": {Version: "1.0.1", Reason: "CVE hotfix"},
+	}}
+	h3, _ := (&SolutionKey{Berksfile: base.Berksfile, LockFile: overridden}).Hash()
+	if h3 == h1 {
+		t.Error("Expected a lock file override to change the hash")
+	}
+	overridden.Overrides["nginx"].Version = "1.0.2"
+	if h4, _ := (&SolutionKey{Berksfile: base.Berksfile, LockFile: overridden}).Hash(); h4 == h3 {
+		t.Error("Expected a changed override to change the hash")
+	}
+	ignored := &lockfile.LockFile{Revision: lf1.Revision, Sources: lf1.Sources, Ignored: []string{"internal"}}
+	if h5, _ := (&SolutionKey{Berksfile: base.Berksfile, LockFile: ignored}).Hash(); h5 == h1 {
+		t.Error("Expected ignored cookbooks to change the hash")
+	}
+
+	// The algorithm is recorded with the hash
+	blake := &SolutionKey{Berksfile: base.Berksfile, Sources: base.Sources, Algorithm: digest.BLAKE3}
+	hash5, err := blake.Hash()
+	if err != nil || !strings.HasPrefix(hash1, "sha256:") || !strings.HasPrefix(hash5, "blake3:") {
+		t.Errorf("Hash() = %s and %s, %v, want sha256 and blake3 digests", hash1, hash5, err)
+	}
+}
+
+func TestSolutionCache_PutGet(t *testing.T) {
+	sc, err := NewSolutionCache(t.TempDir(), time.Hour)
+	if err != nil {
+		t.Fatalf("NewSolutionCache() error = %v", err)
+	}
+
+	if _, found := sc.Get("missing"); found {
+		t.Error("Expected miss for unknown hash")
+	}
+
+	lf := lockfile.NewLockFile()
+	lf.Sources["https://supermarket.chef.io"] = &lockfile.SourceLock{
+		URL:       "https://supermarket.chef.io",
+		Cookbooks: map[string]*lockfile.CookbookLock{"nginx": {Version: "2.7.6"}},
+	}
+
+	if err := sc.Put("abc", lf); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	cached, found := sc.Get("abc")
+	if !found {
+		t.Fatal("Expected cached solution")
+	}
+	if cached.Sources["https://supermarket.chef.io"].Cookbooks["nginx"].Version != "2.7.6" {
+		t.Errorf("Unexpected cached lock file: %+v", cached)
+	}
+}
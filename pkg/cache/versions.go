@@ -0,0 +1,128 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	"github.com/bdwyertech/go-berkshelf/pkg/logging"
+	"github.com/bdwyertech/go-berkshelf/pkg/metrics"
+	"github.com/bdwyertech/go-berkshelf/pkg/source"
+)
+
+// DefaultCheckInterval is the minimum time between remote version checks for a cookbook
+const DefaultCheckInterval = 15 * time.Minute
+
+// VersionCache remembers the versions each remote source reported for a
+// cookbook, so frequent local runs do not re-query the source until the
+// check interval has passed
+type VersionCache struct {
+	cache *Cache
+}
+
+// NewVersionCache creates a version cache rooted at basePath whose entries
+// are refreshed after interval
+func NewVersionCache(basePath string, interval time.Duration) (*VersionCache, error) {
+	cache, err := NewCache(basePath, interval, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &VersionCache{cache: cache}, nil
+}
+
+// Get returns the versions recorded for a cookbook on a source, if still fresh
+func (v *VersionCache) Get(sourceURL, name string) ([]*berkshelf.Version, bool) {
+	data, ok := v.cache.Get(versionCacheKey(sourceURL, name))
+	if !ok {
+		return nil, false
+	}
+
+	var raw []string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, false
+	}
+
+	versions := make([]*berkshelf.Version, 0, len(raw))
+	for _, s := range raw {
+		version, err := berkshelf.NewVersion(s)
+		if err != nil {
+			return nil, false
+		}
+		versions = append(versions, version)
+	}
+	return versions, true
+}
+
+// Put records the versions a source reported for a cookbook
+func (v *VersionCache) Put(sourceURL, name string, versions []*berkshelf.Version) error {
+	raw := make([]string, 0, len(versions))
+	for _, version := range versions {
+		raw = append(raw, version.String())
+	}
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	return v.cache.Put(versionCacheKey(sourceURL, name), data)
+}
+
+// Wrap returns src with ListVersions answered from the cache while it is
+// fresh. Only remote API sources are wrapped; git and path sources are
+// returned unchanged.
+func (v *VersionCache) Wrap(src source.CookbookSource) source.CookbookSource {
+	switch src.GetSourceType() {
+	case "supermarket", "chef_server":
+		return &cachedVersionSource{CookbookSource: src, versions: v}
+	default:
+		return src
+	}
+}
+
+// WrapAll wraps every source in sources
+func (v *VersionCache) WrapAll(sources []source.CookbookSource) []source.CookbookSource {
+	wrapped := make([]source.CookbookSource, len(sources))
+	for i, src := range sources {
+		wrapped[i] = v.Wrap(src)
+	}
+	return wrapped
+}
+
+// cachedVersionSource serves ListVersions from a VersionCache
+type cachedVersionSource struct {
+	source.CookbookSource
+	versions *VersionCache
+}
+
+// ListVersions returns the cached versions if fresh, otherwise queries the
+// wrapped source and records the answer
+func (s *cachedVersionSource) ListVersions(ctx context.Context, name string) ([]*berkshelf.Version, error) {
+	url := s.GetSourceURL()
+	versions, ok := s.versions.Get(url, name)
+	metrics.Lookup("versions", ok)
+	if ok {
+		log.WithField(logging.CookbookField, name).Debugf("Using versions checked within the last interval from %s", url)
+		return versions, nil
+	}
+
+	versions, err := s.CookbookSource.ListVersions(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.versions.Put(url, name, versions); err != nil {
+		log.WithField(logging.CookbookField, name).Debugf("Failed to cache versions: %v", err)
+	}
+	return versions, nil
+}
+
+// Unwrap returns the wrapped source, so source.As finds its optional
+// interfaces
+func (s *cachedVersionSource) Unwrap() source.CookbookSource {
+	return s.CookbookSource
+}
+
+func versionCacheKey(sourceURL, name string) string {
+	return "versions:" + sourceURL + ":" + name
+}
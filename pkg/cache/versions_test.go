@@ -0,0 +1,105 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	"github.com/bdwyertech/go-berkshelf/pkg/source"
+)
+
+// countingSource counts ListVersions calls
+type countingSource struct {
+	source.CookbookSource
+	sourceType string
+	calls      int
+}
+
+func (s *countingSource) GetSourceType() string { return s.sourceType }
+func (s *countingSource) GetSourceURL() string  { return "https://supermarket.example.com" }
+
+func (s *countingSource) ListVersions(ctx context.Context, name string) ([]*berkshelf.Version, error) {
+	s.calls++
+	return []*berkshelf.Version{berkshelf.MustVersion("2.0.0"), berkshelf.MustVersion("1.0.0")}, nil
+}
+
+func TestVersionCache_Wrap(t *testing.T) {
+	versions, err := NewVersionCache(t.TempDir(), time.Hour)
+	if err != nil {
+		t.Fatalf("NewVersionCache() error = %v", err)
+	}
+
+	remote := &countingSource{sourceType: "supermarket"}
+	wrapped := versions.Wrap(remote)
+
+	for range 3 {
+		got, err := wrapped.ListVersions(context.Background(), "nginx")
+		if err != nil {
+			t.Fatalf("ListVersions() error = %v", err)
+		}
+		if len(got) != 2 || got[0].String() != "2.0.0" {
+			t.Errorf("ListVersions() = %v, want [2.0.0 1.0.0]", got)
+		}
+	}
+	if remote.calls != 1 {
+		t.Errorf("remote queried %d times, want 1", remote.calls)
+	}
+
+	// Other cookbooks are checked separately
+	if _, err := wrapped.ListVersions(context.Background(), "apt"); err != nil {
+		t.Fatalf("ListVersions() error = %v", err)
+	}
+	if remote.calls != 2 {
+		t.Errorf("remote queried %d times, want 2", remote.calls)
+	}
+
+	// Local sources are never cached
+	local := &countingSource{sourceType: "path"}
+	if versions.Wrap(local) != source.CookbookSource(local) {
+		t.Error("Wrap() should return path sources unchanged")
+	}
+}
+
+func TestVersionCache_Expiry(t *testing.T) {
+	versions, err := NewVersionCache(t.TempDir(), time.Millisecond)
+	if err != nil {
+		t.Fatalf("NewVersionCache() error = %v", err)
+	}
+
+	remote := &countingSource{sourceType: "supermarket"}
+	wrapped := versions.Wrap(remote)
+
+	wrapped.ListVersions(context.Background(), "nginx")
+	time.Sleep(10 * time.Millisecond)
+	wrapped.ListVersions(context.Background(), "nginx")
+
+	if remote.calls != 2 {
+		t.Errorf("remote queried %d times, want 2 after the interval passed", remote.calls)
+	}
+}
+
+func TestVersionCache_WrapKeepsOptionalInterfaces(t *testing.T) {
+	versions, err := NewVersionCache(t.TempDir(), time.Hour)
+	if err != nil {
+		t.Fatalf("NewVersionCache() error = %v", err)
+	}
+
+	supermarket := source.NewSupermarketSource("https://supermarket.example.com")
+	wrapped := versions.Wrap(supermarket)
+	if wrapped == source.CookbookSource(supermarket) {
+		t.Fatal("Wrap() did not wrap a supermarket source")
+	}
+	if _, ok := source.As[source.ChecksumSource](wrapped); !ok {
+		t.Error("As() did not find the wrapped source's checksums")
+	}
+	if _, ok := source.As[source.ReleaseSource](wrapped); !ok {
+		t.Error("As() did not find the wrapped source's releases")
+	}
+	if got, ok := source.As[*source.SupermarketSource](wrapped); !ok || got != supermarket {
+		t.Errorf("As() = %v, %v, want the wrapped source", got, ok)
+	}
+	if _, ok := source.As[source.RevisionLocker](wrapped); ok {
+		t.Error("As() found a revision locker the wrapped source does not implement")
+	}
+}
@@ -0,0 +1,99 @@
+// Package changelog finds the release notes of the versions between two
+// releases of a cookbook in its CHANGELOG.md.
+package changelog
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+)
+
+// headingVersion matches the version in a heading such as "## 2.7.6",
+// "## [v2.7.6] - 2024-01-31" or "# nginx 2.7.6 (2024-01-31)"
+var headingVersion = regexp.MustCompile(`(?:^|[\s\[(v])(\d+\.\d+(?:\.\d+)?)(?:$|[\s\]):,])`)
+
+// Section is the release notes of one version
+type Section struct {
+	Version string `json:"version"`
+	// Heading is the heading line, without the leading #s
+	Heading string `json:"heading"`
+	Notes   string `json:"notes,omitempty"`
+}
+
+// Parse splits a markdown changelog into the sections under headings naming
+// a version, in the order they appear. A section ends at the next heading of
+// the same or a higher level.
+func Parse(data []byte) []Section {
+	var sections []Section
+	var current *Section
+	var level int
+	var notes []string
+
+	flush := func() {
+		if current != nil {
+			current.Notes = strings.TrimSpace(strings.Join(notes, "\n"))
+			sections = append(sections, *current)
+		}
+		current, notes = nil, nil
+	}
+
+	inFence := false
+	for _, line := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inFence = !inFence
+		}
+		headingLevel, heading := parseHeading(line)
+		if inFence || headingLevel == 0 || (current != nil && headingLevel > level) {
+			if current != nil {
+				notes = append(notes, line)
+			}
+			continue
+		}
+
+		flush()
+		if match := headingVersion.FindStringSubmatch(heading); match != nil {
+			current, level = &Section{Version: match[1], Heading: heading}, headingLevel
+		}
+	}
+	flush()
+	return sections
+}
+
+// Between returns the sections of the versions after from up to and
+// including to, in the order they appear
+func Between(sections []Section, from, to string) []Section {
+	fromVersion, err := berkshelf.NewVersion(from)
+	if err != nil {
+		return nil
+	}
+	toVersion, err := berkshelf.NewVersion(to)
+	if err != nil {
+		return nil
+	}
+
+	var between []Section
+	for _, section := range sections {
+		version, err := berkshelf.NewVersion(section.Version)
+		if err != nil {
+			continue
+		}
+		if version.GreaterThan(fromVersion) && !version.GreaterThan(toVersion) {
+			between = append(between, section)
+		}
+	}
+	return between
+}
+
+// parseHeading returns the level and text of an ATX heading, or 0 if line is
+// not one
+func parseHeading(line string) (int, string) {
+	level := 0
+	for level < len(line) && line[level] == '#' {
+		level++
+	}
+	if level == 0 || level > 6 || (level < len(line) && line[level] != ' ' && line[level] != '\t') {
+		return 0, ""
+	}
+	return level, strings.TrimSpace(strings.TrimRight(strings.TrimSpace(line[level:]), "#"))
+}
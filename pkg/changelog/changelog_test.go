@@ -0,0 +1,161 @@
+package changelog
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+const sample = `# nginx Cookbook CHANGELOG
+
+## Unreleased
+
+- Work in progress
+
+## [2.8.0] - 2024-03-01
+
+### Added
+
+- HTTP/3 support
+
+` + "```" + `
+# 1.0.0 inside a code block
+` + "```" + `
+
+## 2.7.6 (2024-01-31)
+
+- Fix the default site
+
+## v2.7.5
+
+- Older fix
+`
+
+func TestParse(t *testing.T) {
+	sections := Parse([]byte(sample))
+
+	var versions []string
+	for _, section := range sections {
+		versions = append(versions, section.Version)
+	}
+	if want := []string{"2.8.0", "2.7.6", "2.7.5"}; !reflect.DeepEqual(versions, want) {
+		t.Fatalf("versions = %v, want %v", versions, want)
+	}
+
+	if sections[0].Heading != "[2.8.0] - 2024-03-01" {
+		t.Errorf("heading = %q", sections[0].Heading)
+	}
+	wantNotes := "### Added\n\n- HTTP/3 support\n\n```\n# 1.0.0 inside a code block\n```"
+	if sections[0].Notes != wantNotes {
+		t.Errorf("notes =\n%s\nwant\n%s", sections[0].Notes, wantNotes)
+	}
+	if sections[1].Notes != "- Fix the default site" {
+		t.Errorf("notes = %q", sections[1].Notes)
+	}
+}
+
+func TestBetween(t *testing.T) {
+	sections := Parse([]byte(sample))
+
+	tests := []struct {
+		from, to string
+		want     []string
+	}{
+		{"2.7.5", "2.8.0", []string{"2.8.0", "2.7.6"}},
+		{"2.7.5", "2.7.6", []string{"2.7.6"}},
+		{"2.8.0", "2.8.0", nil},
+		{"invalid", "2.8.0", nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, section := range Between(sections, tt.from, tt.to) {
+			got = append(got, section.Version)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Between(%s, %s) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestRawURLs(t *testing.T) {
+	tests := []struct {
+		repo string
+		want string
+	}{
+		{"https://github.com/sous-chefs/nginx", "https://raw.githubusercontent.com/sous-chefs/nginx/HEAD/CHANGELOG.md"},
+		{"https://github.com/sous-chefs/nginx.git", "https://raw.githubusercontent.com/sous-chefs/nginx/HEAD/CHANGELOG.md"},
+		{"git@github.com:sous-chefs/nginx.git", "https://raw.githubusercontent.com/sous-chefs/nginx/HEAD/CHANGELOG.md"},
+		{"https://github.com/org/chef/tree/main/cookbooks/app", "https://raw.githubusercontent.com/org/chef/main/cookbooks/app/CHANGELOG.md"},
+		{"https://gitlab.com/group/sub/app", "https://gitlab.com/group/sub/app/-/raw/HEAD/CHANGELOG.md"},
+		{"https://gitlab.com/group/app/-/tree/main", "https://gitlab.com/group/app/-/raw/HEAD/CHANGELOG.md"},
+	}
+	for _, tt := range tests {
+		urls, ok := RawURLs(tt.repo)
+		if !ok || urls[0] != tt.want {
+			t.Errorf("RawURLs(%s) = %v, %v, want %s", tt.repo, urls, ok, tt.want)
+		}
+	}
+
+	for _, repo := range []string{"https://git.example.com/app.git", "https://github.com/sous-chefs", ""} {
+		if urls, ok := RawURLs(repo); ok {
+			t.Errorf("RawURLs(%s) = %v, want none", repo, urls)
+		}
+	}
+}
+
+func TestWebURL(t *testing.T) {
+	tests := map[string]string{
+		"git@github.com:sous-chefs/nginx.git":                 "https://github.com/sous-chefs/nginx/blob/HEAD/CHANGELOG.md",
+		"https://github.com/org/chef/tree/main/cookbooks/app": "https://github.com/org/chef/blob/main/cookbooks/app/CHANGELOG.md",
+		"https://gitlab.com/group/app":                        "https://gitlab.com/group/app/-/blob/HEAD/CHANGELOG.md",
+		"https://git.example.com/app.git":                     "https://git.example.com/app",
+		"ssh://git@git.example.com/app.git":                   "",
+		"":                                                    "",
+	}
+	for repo, want := range tests {
+		if got := WebURL(repo); got != want {
+			t.Errorf("WebURL(%q) = %q, want %q", repo, got, want)
+		}
+	}
+}
+
+func TestFetcher_Clone(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "CHANGELOG.md"), []byte(sample), 0644); err != nil {
+		t.Fatal(err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := worktree.Add("CHANGELOG.md"); err != nil {
+		t.Fatal(err)
+	}
+	signature := &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()}
+	if _, err := worktree.Commit("Release 2.8.0", &git.CommitOptions{Author: signature}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := NewFetcher(nil).Fetch(context.Background(), "file://"+dir)
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if string(data) != sample {
+		t.Errorf("Fetch() = %q", data)
+	}
+
+	if _, err := NewFetcher(nil).Fetch(context.Background(), ""); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Fetch(\"\") error = %v, want ErrNotFound", err)
+	}
+}
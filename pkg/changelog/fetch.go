@@ -0,0 +1,198 @@
+package changelog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/logging"
+)
+
+var log = logging.For("changelog")
+
+// Files are the changelog file names looked for at the repository root, in order
+var Files = []string{"CHANGELOG.md", "CHANGELOG", "CHANGES.md"}
+
+// ErrNotFound is returned when a repository has no changelog
+var ErrNotFound = errors.New("no changelog found")
+
+// Fetcher reads the changelog of a repository
+type Fetcher struct {
+	client *http.Client
+}
+
+// NewFetcher returns a fetcher using client, or a client with a 30 second
+// timeout if nil
+func NewFetcher(client *http.Client) *Fetcher {
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &Fetcher{client: client}
+}
+
+// Fetch returns the changelog on the default branch of repo, a repository
+// URL such as a Supermarket source_url or a git source. GitHub and GitLab
+// changelogs are downloaded as raw files; other repositories are cloned
+// into memory.
+func (f *Fetcher) Fetch(ctx context.Context, repo string) ([]byte, error) {
+	if repo == "" {
+		return nil, ErrNotFound
+	}
+	if urls, ok := RawURLs(repo); ok {
+		return f.fetchRaw(ctx, urls)
+	}
+	return f.fetchClone(ctx, repo)
+}
+
+// RawURLs returns the raw file URLs of the changelog candidates of a GitHub
+// or GitLab repository, and false for other hosts. A GitHub URL of a
+// directory, as monorepos give, looks in that directory.
+func RawURLs(repo string) ([]string, bool) {
+	u, err := parseRepository(repo)
+	if err != nil {
+		return nil, false
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) < 2 {
+		return nil, false
+	}
+
+	var base string
+	switch u.Host {
+	case "github.com", "www.github.com":
+		ref, dir := "HEAD", ""
+		if len(parts) >= 4 && (parts[2] == "tree" || parts[2] == "blob") {
+			ref, dir = parts[3], strings.Join(parts[4:], "/")
+		}
+		base = "https://raw.githubusercontent.com/" + parts[0] + "/" + parts[1] + "/" + ref + "/"
+		if dir != "" {
+			base += dir + "/"
+		}
+	case "gitlab.com":
+		project := strings.Join(parts, "/")
+		if i := strings.Index(project, "/-/"); i >= 0 {
+			project = project[:i]
+		}
+		base = "https://gitlab.com/" + project + "/-/raw/HEAD/"
+	default:
+		return nil, false
+	}
+
+	urls := make([]string, len(Files))
+	for i, file := range Files {
+		urls[i] = base + file
+	}
+	return urls, true
+}
+
+// WebURL returns a link to browse the changelog of a GitHub or GitLab
+// repository, or the repository itself for other http(s) hosts, and "" when
+// repo is not a web URL
+func WebURL(repo string) string {
+	urls, ok := RawURLs(repo)
+	switch {
+	case ok && strings.HasPrefix(urls[0], "https://raw.githubusercontent.com/"):
+		parts := strings.SplitN(strings.TrimPrefix(urls[0], "https://raw.githubusercontent.com/"), "/", 3)
+		return "https://github.com/" + parts[0] + "/" + parts[1] + "/blob/" + parts[2]
+	case ok:
+		return strings.Replace(urls[0], "/-/raw/", "/-/blob/", 1)
+	}
+	if u, err := parseRepository(repo); err == nil && (u.Scheme == "https" || u.Scheme == "http") && u.Host != "" {
+		return u.String()
+	}
+	return ""
+}
+
+// parseRepository parses a repository URL, including scp-like git URLs such
+// as git@github.com:owner/repo.git, dropping a .git suffix
+func parseRepository(repo string) (*url.URL, error) {
+	repo = strings.TrimSpace(repo)
+	if !strings.Contains(repo, "://") {
+		if at, colon := strings.Index(repo, "@"), strings.Index(repo, ":"); at >= 0 && colon > at {
+			repo = "ssh://" + repo[:colon] + "/" + repo[colon+1:]
+		}
+	}
+	u, err := url.Parse(repo)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = strings.TrimSuffix(strings.TrimSuffix(u.Path, "/"), ".git")
+	return u, nil
+}
+
+// fetchRaw downloads the first of urls that exists
+func (f *Fetcher) fetchRaw(ctx context.Context, urls []string) ([]byte, error) {
+	for _, rawURL := range urls {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("creating request: %w", err)
+		}
+		resp, err := f.client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("fetching changelog: %w", err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("reading changelog: %w", err)
+		}
+
+		switch resp.StatusCode {
+		case http.StatusOK:
+			log.Debugf("Fetched %s", rawURL)
+			return body, nil
+		case http.StatusNotFound:
+			continue
+		default:
+			return nil, fmt.Errorf("fetching %s: HTTP %d", rawURL, resp.StatusCode)
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// fetchClone clones the default branch of repo into memory, without a
+// working tree, and reads the changelog from its head commit
+func (f *Fetcher) fetchClone(ctx context.Context, repo string) ([]byte, error) {
+	r, err := git.CloneContext(ctx, memory.NewStorage(), nil, &git.CloneOptions{
+		URL:          repo,
+		Depth:        1,
+		SingleBranch: true,
+		Tags:         git.NoTags,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cloning %s: %w", repo, err)
+	}
+	head, err := r.Head()
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", repo, err)
+	}
+	commit, err := r.CommitObject(head.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", repo, err)
+	}
+
+	for _, name := range Files {
+		file, err := commit.File(name)
+		if errors.Is(err, object.ErrFileNotFound) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", name, err)
+		}
+		contents, err := file.Contents()
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", name, err)
+		}
+		log.Debugf("Read %s from %s", name, repo)
+		return []byte(contents), nil
+	}
+	return nil, ErrNotFound
+}
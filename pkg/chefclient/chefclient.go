@@ -0,0 +1,116 @@
+// Package chefclient locates a local Chef Infra Client (or Cinc Client) and
+// reads its version, so resolution can honor cookbook chef_version constraints.
+package chefclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+)
+
+// ErrNotFound is returned when no client executable can be located
+var ErrNotFound = errors.New("no chef-client or cinc-client found in PATH or the Chef/Cinc install directories")
+
+// probeTimeout bounds `chef-client --version`, which loads Ruby and can be slow
+const probeTimeout = 30 * time.Second
+
+// Client is a detected Chef Infra Client installation
+type Client struct {
+	// Path is the executable that was probed
+	Path string
+	// Version is the version it reported
+	Version *berkshelf.Version
+}
+
+// String returns the client version and path for log messages
+func (c *Client) String() string {
+	return fmt.Sprintf("%s (%s)", c.Version, c.Path)
+}
+
+// Executables are the client names looked up in PATH, in order of preference
+var Executables = []string{"chef-client", "cinc-client"}
+
+// installPaths are the omnibus install locations checked when PATH has no client
+func installPaths() []string {
+	if runtime.GOOS == "windows" {
+		return []string{
+			`C:\opscode\chef\bin\chef-client.bat`,
+			`C:\cinc-project\cinc\bin\cinc-client.bat`,
+			`C:\opscode\chef-workstation\bin\chef-client.bat`,
+		}
+	}
+	return []string{
+		"/opt/chef/bin/chef-client",
+		"/opt/cinc/bin/cinc-client",
+		"/opt/chef-workstation/bin/chef-client",
+		"/opt/cinc-workstation/bin/cinc-client",
+	}
+}
+
+// Locate returns the path of the first client executable found in PATH or in
+// the default install directories
+func Locate() (string, error) {
+	for _, name := range Executables {
+		if path, err := exec.LookPath(name); err == nil {
+			return path, nil
+		}
+	}
+	for _, path := range installPaths() {
+		if resolved, err := exec.LookPath(path); err == nil {
+			return resolved, nil
+		}
+	}
+	return "", ErrNotFound
+}
+
+// Detect locates a client and runs it with --version
+func Detect(ctx context.Context) (*Client, error) {
+	path, err := Locate()
+	if err != nil {
+		return nil, err
+	}
+	return Probe(ctx, path)
+}
+
+// Probe runs the client at path with --version and parses its output
+func Probe(ctx context.Context, path string) (*Client, error) {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	// Chef 15+ refuses to start until its license is accepted; the environment
+	// variable is ignored by older clients, unlike the --chef-license flag
+	cmd := exec.CommandContext(ctx, path, "--version")
+	cmd.Env = append(os.Environ(), "CHEF_LICENSE=accept-no-persist")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run %s --version: %w: %s", filepath.Base(path), err, strings.TrimSpace(string(out)))
+	}
+
+	version, err := ParseVersion(string(out))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &Client{Path: path, Version: version}, nil
+}
+
+// versionOutputRegex matches the version in "Chef Infra Client: 18.2.7",
+// "Cinc Client: 18.2.7" and the older "Chef: 14.15.6"
+var versionOutputRegex = regexp.MustCompile(`(?m)^(?:Chef Infra Client|Cinc Client|Chef):\s*v?(\d+\.\d+\.\d+)`)
+
+// ParseVersion extracts the client version from `chef-client --version` output
+func ParseVersion(output string) (*berkshelf.Version, error) {
+	match := versionOutputRegex.FindStringSubmatch(output)
+	if match == nil {
+		return nil, fmt.Errorf("unrecognized version output %q", strings.TrimSpace(output))
+	}
+	return berkshelf.NewVersion(match[1])
+}
@@ -0,0 +1,74 @@
+package chefclient
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		output  string
+		want    string
+		wantErr bool
+	}{
+		{output: "Chef Infra Client: 18.2.7\n", want: "18.2.7"},
+		{output: "Cinc Client: 17.10.3\n", want: "17.10.3"},
+		{output: "Chef: 14.15.6\n", want: "14.15.6"},
+		{output: "+---------------------------------------------+\n  License Acceptance\nChef Infra Client: 16.0.257\n", want: "16.0.257"},
+		{output: "command not found", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseVersion(tt.output)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseVersion(%q) expected error", tt.output)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseVersion(%q) error = %v", tt.output, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("ParseVersion(%q) = %s, want %s", tt.output, got, tt.want)
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script as the fake client")
+	}
+
+	dir := t.TempDir()
+	script := "#!/bin/sh\n[ \"$CHEF_LICENSE\" = accept-no-persist ] || exit 1\necho 'Cinc Client: 18.4.12'\n"
+	if err := os.WriteFile(filepath.Join(dir, "cinc-client"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir)
+
+	client, err := Detect(context.Background())
+	if err != nil {
+		t.Fatalf("Detect() error = %v", err)
+	}
+	if client.Version.String() != "18.4.12" || client.Path != filepath.Join(dir, "cinc-client") {
+		t.Errorf("Detect() = %s", client)
+	}
+}
+
+func TestProbe_Failure(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script as the fake client")
+	}
+
+	path := filepath.Join(t.TempDir(), "chef-client")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\necho broken >&2\nexit 3\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Probe(context.Background(), path); err == nil {
+		t.Error("Probe() expected error for a failing client")
+	}
+}
@@ -0,0 +1,295 @@
+// Package convert translates between Berksfiles and Policyfiles, to ease
+// migrating a cookbook from one workflow to the other.
+package convert
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berksfile"
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	"github.com/bdwyertech/go-berkshelf/pkg/policyfile"
+)
+
+const publicSupermarket = "https://supermarket.chef.io"
+
+// Conversion is a converted file and what could not be carried over to it
+type Conversion struct {
+	Content  string
+	Warnings []string
+}
+
+func (c *Conversion) warnf(format string, args ...any) {
+	c.Warnings = append(c.Warnings, fmt.Sprintf(format, args...))
+}
+
+// PolicyfileOptions configure the conversion of a Berksfile to a Policyfile
+type PolicyfileOptions struct {
+	// Name is the policy name. It defaults to Metadata.
+	Name string
+	// Metadata is the name of the cookbook the Berksfile's metadata
+	// directive adds, which is required when it has one
+	Metadata string
+}
+
+// ToPolicyfile converts a Berksfile to a Policyfile.rb. Sources become
+// default sources and cookbooks keep their constraints and git or path
+// sources. The run list is the metadata cookbook's default recipe, or else
+// the default recipes of the cookbooks outside any group, and each group
+// becomes a named run list of its cookbooks' default recipes.
+func ToPolicyfile(b *berksfile.Berksfile, opts PolicyfileOptions) (*Conversion, error) {
+	name := opts.Name
+	if name == "" {
+		name = opts.Metadata
+	}
+	if name == "" {
+		return nil, fmt.Errorf("a policy name is required")
+	}
+	if b.HasMetadata && opts.Metadata == "" {
+		return nil, fmt.Errorf("the Berksfile's metadata directive needs the name of its cookbook")
+	}
+
+	conv := &Conversion{}
+	var out strings.Builder
+	fmt.Fprintf(&out, "name %s\n", rubyString(name))
+
+	var sources []string
+	for _, src := range b.Sources {
+		switch src.Type {
+		case "supermarket":
+			if src.URL == "" || src.URL == publicSupermarket {
+				sources = append(sources, "default_source :supermarket")
+			} else {
+				sources = append(sources, "default_source :supermarket, "+rubyString(src.URL))
+			}
+		case "chef_server":
+			if len(src.Options) > 0 {
+				conv.warnf("source %s: client options are not carried over, the Policyfile uses the Chef configuration's credentials", src.URL)
+			}
+			sources = append(sources, "default_source :chef_server, "+rubyString(src.URL))
+		default:
+			conv.warnf("source %s (%s) has no Policyfile equivalent and is skipped", src.URL, src.Type)
+		}
+	}
+	if len(sources) > 0 {
+		out.WriteString("\n" + strings.Join(sources, "\n") + "\n")
+	}
+
+	cookbooks := slices.SortedStableFunc(slices.Values(b.Cookbooks), func(a, b *berksfile.CookbookDef) int {
+		return a.Line - b.Line
+	})
+
+	// The run list holds what the policy converges; cookbooks in groups are
+	// only needed for testing and such, so they get named run lists
+	var runList []string
+	if b.HasMetadata {
+		runList = []string{defaultRecipe(opts.Metadata)}
+	} else {
+		for _, cb := range cookbooks {
+			if len(cb.Groups) == 0 {
+				runList = append(runList, defaultRecipe(cb.Name))
+			}
+		}
+	}
+	out.WriteString("\n")
+	if len(runList) == 0 {
+		conv.warnf("the Berksfile declares no cookbooks outside a group, set the Policyfile's run_list")
+		out.WriteString("run_list []\n")
+	} else {
+		fmt.Fprintf(&out, "run_list %s\n", rubyStrings(runList))
+	}
+	for _, group := range slices.Sorted(maps.Keys(b.Groups)) {
+		var recipes []string
+		for _, cb := range b.Groups[group] {
+			recipes = append(recipes, defaultRecipe(cb.Name))
+		}
+		if len(recipes) > 0 {
+			fmt.Fprintf(&out, "named_run_list %s, %s\n", rubySymbol(group), rubyStrings(recipes))
+		}
+	}
+
+	out.WriteString("\n")
+	if b.HasMetadata {
+		fmt.Fprintf(&out, "cookbook %s, path: '.'\n", rubyString(opts.Metadata))
+	}
+	for _, cb := range cookbooks {
+		fmt.Fprintf(&out, "cookbook %s\n", policyfileCookbook(cb, conv))
+	}
+	for _, name := range b.Ignored {
+		conv.warnf("ignored cookbook %s has no Policyfile equivalent, a policy includes every dependency", name)
+	}
+	for _, override := range b.Overrides {
+		conv.warnf("override of %s to %s has no Policyfile equivalent, pin it in its cookbook statement", override.Name, override.Constraint)
+	}
+
+	conv.Content = out.String()
+	return conv, nil
+}
+
+// policyfileCookbook renders the arguments of a Policyfile cookbook
+// statement
+func policyfileCookbook(cb *berksfile.CookbookDef, conv *Conversion) string {
+	args := []string{rubyString(cb.Name)}
+	if constraint := constraintString(cb.Constraint); constraint != "" {
+		args = append(args, rubyString(constraint))
+	}
+
+	if cb.MetadataName != "" {
+		conv.warnf("cookbook %s: metadata_name %s has no Policyfile equivalent, dependencies on %s are not satisfied by it", cb.Name, cb.MetadataName, cb.MetadataName)
+	}
+
+	src := cb.Source
+	if src == nil {
+		return strings.Join(args, ", ")
+	}
+	switch src.Type {
+	case "":
+	case "path":
+		args = append(args, "path: "+rubyString(src.Path))
+	case "git":
+		args = append(args, "git: "+rubyString(src.URL))
+		args = append(args, gitRef(src)...)
+		if src.Path != "" {
+			args = append(args, "rel: "+rubyString(src.Path))
+		}
+	default:
+		conv.warnf("cookbook %s: the %s source has no Policyfile equivalent, it is taken from the default sources", cb.Name, src.Type)
+	}
+	return strings.Join(args, ", ")
+}
+
+// gitRef renders the branch or ref of a git source
+func gitRef(src *berkshelf.SourceLocation) []string {
+	if branch, ok := src.Options["branch"].(string); ok && branch == src.Ref {
+		return []string{"branch: " + rubyString(branch)}
+	}
+	if src.Ref != "" {
+		return []string{"ref: " + rubyString(src.Ref)}
+	}
+	return nil
+}
+
+// ToBerksfile converts a Policyfile.rb to a Berksfile. Default sources
+// become sources and cookbooks keep their constraints and git or path
+// sources; a cookbook sourced from the Policyfile's own directory becomes
+// the metadata directive. Cookbooks the run lists call for without a cookbook
+// statement are added, but the run lists themselves have no Berksfile
+// equivalent.
+func ToBerksfile(p *policyfile.Policyfile) (*Conversion, error) {
+	conv := &Conversion{}
+	var out strings.Builder
+
+	var sources []string
+	for _, src := range p.DefaultSources {
+		switch {
+		case src.Type == "supermarket":
+			url := src.URL
+			if url == "" {
+				url = publicSupermarket
+			}
+			sources = append(sources, "source "+rubyString(url))
+		case src.Type == "chef_server" && src.URL != "":
+			conv.warnf("default source %s: set client_name and client_key for the chef_server source", src.URL)
+			sources = append(sources, "source chef_server: "+rubyString(src.URL))
+		case src.Type == "path":
+			conv.warnf("default source :chef_repo, %s has no Berksfile equivalent, declare its cookbooks with path:", src.Path)
+		default:
+			conv.warnf("default source :%s has no Berksfile equivalent without a URL and is skipped", src.Type)
+		}
+	}
+	if len(sources) > 0 {
+		out.WriteString(strings.Join(sources, "\n") + "\n")
+	}
+
+	var metadata bool
+	var cookbooks []string
+	for _, cb := range p.Requirements() {
+		if cb.Source != nil && cb.Source.Type == "path" && (cb.Source.Path == "." || cb.Source.Path == "./") {
+			metadata = true
+			continue
+		}
+		cookbooks = append(cookbooks, "cookbook "+berksfileCookbook(cb, conv))
+	}
+	if metadata {
+		if out.Len() > 0 {
+			out.WriteString("\n")
+		}
+		out.WriteString("metadata\n")
+	}
+	if len(cookbooks) > 0 {
+		if out.Len() > 0 {
+			out.WriteString("\n")
+		}
+		out.WriteString(strings.Join(cookbooks, "\n") + "\n")
+	}
+
+	conv.Content = out.String()
+	return conv, nil
+}
+
+// berksfileCookbook renders the arguments of a Berksfile cookbook statement
+func berksfileCookbook(cb *policyfile.CookbookDef, conv *Conversion) string {
+	args := []string{rubyString(cb.Name)}
+	if constraint := constraintString(cb.Constraint); constraint != "" {
+		args = append(args, rubyString(constraint))
+	}
+
+	src := cb.Source
+	if src == nil {
+		return strings.Join(args, ", ")
+	}
+	switch src.Type {
+	case "path":
+		args = append(args, "path: "+rubyString(src.Path))
+	case "git":
+		args = append(args, "git: "+rubyString(src.URL))
+		if src.Ref != "" {
+			args = append(args, "ref: "+rubyString(src.Ref))
+		}
+		if src.Path != "" {
+			args = append(args, "rel: "+rubyString(src.Path))
+		}
+	default:
+		conv.warnf("cookbook %s: the %s source %s has no Berksfile equivalent, add it as a source", cb.Name, src.Type, src.URL)
+	}
+	return strings.Join(args, ", ")
+}
+
+// constraintString returns a constraint, or "" when it allows any version
+func constraintString(c *berkshelf.Constraint) string {
+	if c == nil || c.String() == ">= 0.0.0" {
+		return ""
+	}
+	return c.String()
+}
+
+func defaultRecipe(cookbook string) string {
+	return cookbook + "::default"
+}
+
+// rubyString returns s as a single-quoted Ruby string
+func rubyString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return "'" + strings.ReplaceAll(s, "'", `\'`) + "'"
+}
+
+// rubySymbol returns s as a Ruby symbol, quoted when it is not an identifier
+func rubySymbol(s string) string {
+	for i, r := range s {
+		if r != '_' && !unicode.IsLetter(r) && (i == 0 || !unicode.IsDigit(r)) {
+			return ":" + rubyString(s)
+		}
+	}
+	return ":" + s
+}
+
+func rubyStrings(ss []string) string {
+	quoted := make([]string, len(ss))
+	for i, s := range ss {
+		quoted[i] = rubyString(s)
+	}
+	return strings.Join(quoted, ", ")
+}
@@ -0,0 +1,139 @@
+package convert
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berksfile"
+	"github.com/bdwyertech/go-berkshelf/pkg/policyfile"
+)
+
+const berksfileContent = `source 'https://supermarket.chef.io'
+source s3: 's3://bucket/cookbooks'
+
+metadata
+
+cookbook 'nginx', '~> 12.0'
+cookbook 'postgresql', git: 'https://github.com/sous-chefs/postgresql.git', branch: 'main'
+
+group :integration do
+  cookbook 'test-helper', path: 'test/fixtures/helper'
+end
+
+group 'smoke-test' do
+  cookbook 'curl'
+end
+`
+
+func TestToPolicyfile(t *testing.T) {
+	b, err := berksfile.Parse(berksfileContent)
+	if err != nil {
+		t.Fatalf("berksfile.Parse() error = %v", err)
+	}
+
+	conv, err := ToPolicyfile(b, PolicyfileOptions{Metadata: "app"})
+	if err != nil {
+		t.Fatalf("ToPolicyfile() error = %v", err)
+	}
+	want := `name 'app'
+
+default_source :supermarket
+
+run_list 'app::default'
+named_run_list :integration, 'test-helper::default'
+named_run_list :'smoke-test', 'curl::default'
+
+cookbook 'app', path: '.'
+cookbook 'nginx', '~> 12.0'
+cookbook 'postgresql', git: 'https://github.com/sous-chefs/postgresql.git', branch: 'main'
+cookbook 'test-helper', path: 'test/fixtures/helper'
+cookbook 'curl'
+`
+	if conv.Content != want {
+		t.Errorf("ToPolicyfile() =\n%s\nwant\n%s", conv.Content, want)
+	}
+	if len(conv.Warnings) != 1 || !strings.Contains(conv.Warnings[0], "s3://bucket/cookbooks") {
+		t.Errorf("Expected a warning about the s3 source, got %v", conv.Warnings)
+	}
+
+	// The Policyfile declares the same cookbooks
+	p, err := policyfile.Parse(conv.Content)
+	if err != nil {
+		t.Fatalf("policyfile.Parse() error = %v", err)
+	}
+	if len(p.Cookbooks) != 5 {
+		t.Fatalf("Expected 5 cookbooks, got %d", len(p.Cookbooks))
+	}
+	if got := p.Cookbooks[2].Source; got.Type != "git" || got.Ref != "main" {
+		t.Errorf("Expected postgresql from git at main, got %+v", got)
+	}
+}
+
+func TestToPolicyfileRunList(t *testing.T) {
+	b, err := berksfile.Parse("source 'https://supermarket.chef.io'\ncookbook 'nginx'\ngroup :test do\n  cookbook 'curl'\nend\ncookbook 'apt'\n")
+	if err != nil {
+		t.Fatalf("berksfile.Parse() error = %v", err)
+	}
+	conv, err := ToPolicyfile(b, PolicyfileOptions{Name: "web"})
+	if err != nil {
+		t.Fatalf("ToPolicyfile() error = %v", err)
+	}
+	if !strings.Contains(conv.Content, "run_list 'nginx::default', 'apt::default'\n") {
+		t.Errorf("Expected the ungrouped cookbooks in the run list, got\n%s", conv.Content)
+	}
+
+	if _, err := ToPolicyfile(b, PolicyfileOptions{}); err == nil {
+		t.Error("Expected an error without a policy name")
+	}
+}
+
+func TestToBerksfile(t *testing.T) {
+	p, err := policyfile.Parse(`name 'app'
+
+default_source :supermarket
+default_source :chef_repo, '../cookbooks'
+
+run_list 'app::default', 'recipe[chef-client]'
+
+cookbook 'app', path: '.'
+cookbook 'nginx', '~> 12.0'
+cookbook 'mysql', github: 'sous-chefs/mysql', tag: 'v8.0.0', rel: 'cookbooks/mysql'
+`)
+	if err != nil {
+		t.Fatalf("policyfile.Parse() error = %v", err)
+	}
+
+	conv, err := ToBerksfile(p)
+	if err != nil {
+		t.Fatalf("ToBerksfile() error = %v", err)
+	}
+	want := `source 'https://supermarket.chef.io'
+
+metadata
+
+cookbook 'nginx', '~> 12.0'
+cookbook 'mysql', git: 'https://github.com/sous-chefs/mysql.git', ref: 'v8.0.0', rel: 'cookbooks/mysql'
+cookbook 'chef-client'
+`
+	if conv.Content != want {
+		t.Errorf("ToBerksfile() =\n%s\nwant\n%s", conv.Content, want)
+	}
+	if len(conv.Warnings) != 1 || !strings.Contains(conv.Warnings[0], "chef_repo") {
+		t.Errorf("Expected a warning about the chef_repo source, got %v", conv.Warnings)
+	}
+
+	b, err := berksfile.Parse(conv.Content)
+	if err != nil {
+		t.Fatalf("berksfile.Parse() error = %v", err)
+	}
+	if !b.HasMetadata || len(b.Cookbooks) != 3 {
+		t.Fatalf("Expected metadata and 3 cookbooks, got %+v", b)
+	}
+	if got := b.Cookbooks[1].Source; got.URL != p.Cookbooks[2].Source.URL || got.Ref != "v8.0.0" || got.Path != "cookbooks/mysql" {
+		t.Errorf("Expected mysql from cookbooks/mysql of git at v8.0.0, got %+v", got)
+	}
+	if !reflect.DeepEqual(b.Cookbooks[0].Constraint, p.Cookbooks[1].Constraint) {
+		t.Errorf("Expected the nginx constraint to be kept, got %v", b.Cookbooks[0].Constraint)
+	}
+}
@@ -0,0 +1,136 @@
+package cookbook
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ChefignoreFile is the name of the file listing paths excluded from a cookbook
+const ChefignoreFile = "chefignore"
+
+// vcsEntries are version control files and directories that are never packaged,
+// whatever the chefignore says. Berkshelf excludes the same set when vendoring.
+var vcsEntries = map[string]bool{
+	".arch-ids": true, "{arch}": true, ".bzr": true, ".bzrignore": true, ".bzrtags": true,
+	"CVS": true, ".cvsignore": true, "_darcs": true, ".git": true, ".hg": true,
+	".hgignore": true, ".hgtags": true, "RCS": true, "SCCS": true, ".svn": true,
+	".gitattributes": true, ".gitmodules": true,
+}
+
+// Chefignore matches cookbook-relative paths against chefignore globs.
+// Globs follow Ruby's File.fnmatch without flags, as Chef applies them:
+// "*" also matches "/", so "test/*" excludes everything below test.
+type Chefignore struct {
+	patterns []*regexp.Regexp
+	globs    []string
+}
+
+// LoadChefignore reads the chefignore for a cookbook. Like Chef, it looks in
+// the cookbook directory first and then in its parent (the cookbook path).
+// A missing chefignore yields an empty matcher.
+func LoadChefignore(cookbookDir string) (*Chefignore, error) {
+	for _, dir := range []string{cookbookDir, filepath.Dir(cookbookDir)} {
+		path := filepath.Join(dir, ChefignoreFile)
+		f, err := os.Open(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s: %w", path, err)
+		}
+		defer f.Close()
+
+		var globs []string
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			globs = append(globs, scanner.Text())
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		return NewChefignore(globs), nil
+	}
+	return NewChefignore(nil), nil
+}
+
+// NewChefignore returns a matcher for chefignore lines. Blank lines and
+// lines starting with "#" are skipped.
+func NewChefignore(lines []string) *Chefignore {
+	c := &Chefignore{}
+	for _, line := range lines {
+		glob := strings.TrimSpace(line)
+		if glob == "" || strings.HasPrefix(glob, "#") {
+			continue
+		}
+		c.globs = append(c.globs, glob)
+		c.patterns = append(c.patterns, globRegexp(glob))
+	}
+	return c
+}
+
+// Globs returns the patterns in the order they were read
+func (c *Chefignore) Globs() []string {
+	return c.globs
+}
+
+// Ignored reports whether a slash-separated cookbook-relative path is excluded
+func (c *Chefignore) Ignored(rel string) bool {
+	for _, part := range strings.Split(rel, "/") {
+		if vcsEntries[part] {
+			return true
+		}
+	}
+	for _, pattern := range c.patterns {
+		if pattern.MatchString(rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// globRegexp translates an fnmatch glob into an anchored regular expression
+func globRegexp(glob string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch ch := glob[i]; ch {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+				b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+			} else {
+				b.WriteString(`\\`)
+			}
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		default:
+			b.WriteString(regexp.QuoteMeta(string(ch)))
+		}
+	}
+	b.WriteString("$")
+
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		// An unbalanced class can only match itself literally
+		return regexp.MustCompile("^" + regexp.QuoteMeta(glob) + "$")
+	}
+	return re
+}
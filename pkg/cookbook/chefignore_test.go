@@ -0,0 +1,80 @@
+package cookbook
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestChefignore_Ignored(t *testing.T) {
+	ignore := NewChefignore([]string{
+		"# comment",
+		"",
+		"*~",
+		"  *.sw[a-z]  ",
+		"test/*",
+		"spec?helper.rb",
+		"[!a]ile.txt",
+		`literal\*.txt`,
+	})
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"README.md~", true},
+		{"recipes/default.rb~", true},
+		{"recipes/.default.rb.swp", true},
+		{"test/integration/default/a_test.rb", true},
+		{"spec_helper.rb", true},
+		{"file.txt", true},
+		{"aile.txt", false},
+		{"literal*.txt", true},
+		{"literalX.txt", false},
+		{"recipes/default.rb", false},
+		{"tests/a.rb", false},
+		{".git", true},
+		{"files/.svn/entries", true},
+		{"# comment", false},
+	}
+	for _, tt := range tests {
+		if got := ignore.Ignored(tt.path); got != tt.want {
+			t.Errorf("Ignored(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+
+	if len(ignore.Globs()) != 6 {
+		t.Errorf("Globs() = %v, want 6 patterns", ignore.Globs())
+	}
+}
+
+func TestLoadChefignore(t *testing.T) {
+	root := t.TempDir()
+	cookbookDir := filepath.Join(root, "nginx")
+	if err := os.Mkdir(cookbookDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	ignore, err := LoadChefignore(cookbookDir)
+	if err != nil || len(ignore.Globs()) != 0 {
+		t.Fatalf("LoadChefignore() without a file = %v, %v", ignore.Globs(), err)
+	}
+
+	// Falls back to the cookbook path's chefignore
+	if err := os.WriteFile(filepath.Join(root, ChefignoreFile), []byte("*.bak\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ignore, err = LoadChefignore(cookbookDir)
+	if err != nil || !ignore.Ignored("a.bak") {
+		t.Fatalf("LoadChefignore() did not read the parent chefignore: %v", err)
+	}
+
+	// The cookbook's own chefignore wins
+	if err := os.WriteFile(filepath.Join(cookbookDir, ChefignoreFile), []byte("*.tmp\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ignore, err = LoadChefignore(cookbookDir)
+	if err != nil || ignore.Ignored("a.bak") || !ignore.Ignored("a.tmp") {
+		t.Fatalf("LoadChefignore() did not prefer the cookbook chefignore: %v", err)
+	}
+}
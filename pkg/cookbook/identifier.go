@@ -0,0 +1,102 @@
+package cookbook
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Identifier is the content identifier a Policyfile.lock.json locks a
+// cookbook with, computed as chef-cli does so that chef-client and the Chef
+// Server policy APIs accept the lock
+type Identifier struct {
+	// ID is the hex SHA-1 of the cookbook's fingerprint: a "path:md5\n" line
+	// for each of its files, sorted by path
+	ID string `json:"identifier"`
+	// DottedDecimal is ID split into three integers of 14, 14 and 12 hex
+	// digits, the version cookbook artifacts are stored under
+	DottedDecimal string `json:"dotted_decimal_identifier"`
+}
+
+// ComputeIdentifier computes the identifier of the cookbook in cookbookDir
+// from the files Chef loads from it (see Files)
+func ComputeIdentifier(cookbookDir string) (*Identifier, error) {
+	files, err := Files(cookbookDir)
+	if err != nil {
+		return nil, err
+	}
+
+	checksums := make(map[string]string, len(files))
+	for _, rel := range files {
+		sum, err := md5File(filepath.Join(cookbookDir, filepath.FromSlash(rel)))
+		if err != nil {
+			return nil, err
+		}
+		checksums[rel] = sum
+	}
+	return NewIdentifier(checksums), nil
+}
+
+// Files returns the files Chef loads from the cookbook in cookbookDir, by
+// slash-separated relative path in sorted order: every regular file
+// chefignore does not exclude
+func Files(cookbookDir string) ([]string, error) {
+	ignore, err := LoadChefignore(cookbookDir)
+	if err != nil {
+		return nil, err
+	}
+	entries, _, err := collect(cookbookDir, ignore)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, 0, len(entries))
+	for _, e := range entries {
+		files = append(files, e.rel)
+	}
+	return files, nil
+}
+
+// NewIdentifier computes the identifier of a cookbook from the MD5 of each
+// of its files, by relative path
+func NewIdentifier(checksums map[string]string) *Identifier {
+	paths := make([]string, 0, len(checksums))
+	for p := range checksums {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	var fingerprint strings.Builder
+	for _, p := range paths {
+		fmt.Fprintf(&fingerprint, "%s:%s\n", p, checksums[p])
+	}
+	sum := sha1.Sum([]byte(fingerprint.String()))
+	id := hex.EncodeToString(sum[:])
+
+	parts := make([]string, 0, 3)
+	for _, hexPart := range []string{id[0:14], id[14:28], id[28:40]} {
+		n, _ := strconv.ParseUint(hexPart, 16, 64)
+		parts = append(parts, strconv.FormatUint(n, 10))
+	}
+	return &Identifier{ID: id, DottedDecimal: strings.Join(parts, ".")}
+}
+
+// md5File returns the hex MD5 of the file at path
+func md5File(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+	h := md5.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
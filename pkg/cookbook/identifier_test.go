@@ -0,0 +1,50 @@
+package cookbook
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"testing"
+)
+
+func TestComputeIdentifier(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"metadata.rb":        "name 'app'\nversion '1.0.0'\n",
+		"recipes/default.rb": "log 'hello'\n",
+		"test/unit_spec.rb":  "describe 'app'\n",
+		".git/HEAD":          "ref: refs/heads/main\n",
+		"chefignore":         "test/*\n",
+	})
+
+	id, err := ComputeIdentifier(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// chefignore itself is loaded, test/ and .git are not
+	want := NewIdentifier(map[string]string{
+		"metadata.rb":        md5Hex("name 'app'\nversion '1.0.0'\n"),
+		"recipes/default.rb": md5Hex("log 'hello'\n"),
+		"chefignore":         md5Hex("test/*\n"),
+	})
+	if *id != *want {
+		t.Errorf("ComputeIdentifier() = %+v, want %+v", id, want)
+	}
+}
+
+func TestNewIdentifier(t *testing.T) {
+	id := NewIdentifier(map[string]string{
+		"recipes/default.rb": md5Hex("log 'hello'\n"),
+		"metadata.rb":        md5Hex("name 'app'\nversion '1.0.0'\n"),
+	})
+	if want := "d365e6b2d55c97731bb4add33f91d645872755b2"; id.ID != want {
+		t.Errorf("ID = %s, want %s", id.ID, want)
+	}
+	if want := "59503261603159191.32400085146091409.235594108589490"; id.DottedDecimal != want {
+		t.Errorf("DottedDecimal = %s, want %s", id.DottedDecimal, want)
+	}
+}
+
+func md5Hex(content string) string {
+	sum := md5.Sum([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
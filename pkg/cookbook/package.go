@@ -0,0 +1,341 @@
+// Package cookbook packages cookbook directories into the .tgz artifacts
+// Chef Server, Supermarket and Artifactory accept.
+package cookbook
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/digest"
+	"github.com/bdwyertech/go-berkshelf/pkg/logging"
+	"github.com/bdwyertech/go-berkshelf/pkg/metadata"
+)
+
+var log = logging.For("cookbook")
+
+// Options configures packaging
+type Options struct {
+	// ModTime is stamped on every archive entry. The zero value uses the Unix
+	// epoch so that packaging the same tree twice yields identical bytes.
+	ModTime time.Time
+	// Algorithm computes the Digest of the artifact and its files;
+	// digest.Default when empty
+	Algorithm digest.Algorithm
+}
+
+// File is one file in a packaged cookbook
+type File struct {
+	// Path is relative to the cookbook directory, slash-separated
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+	// MD5 is the checksum Chef Server uses in cookbook manifests
+	MD5    string `json:"md5"`
+	SHA256 string `json:"sha256"`
+	// Digest is "<algorithm>:<hex>" in the packaging algorithm
+	Digest string `json:"digest"`
+}
+
+// Manifest describes a packaged cookbook
+type Manifest struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	// Files are sorted by path
+	Files []File `json:"files"`
+	// SHA256 is the checksum of the .tgz artifact
+	SHA256 string `json:"sha256"`
+	// Digest is the checksum of the .tgz artifact as "<algorithm>:<hex>"
+	Digest string `json:"digest"`
+	// Ignored are the paths excluded by chefignore
+	Ignored []string `json:"ignored,omitempty"`
+}
+
+// ArchiveName returns the conventional artifact file name for a cookbook version
+func ArchiveName(name, version string) string {
+	return fmt.Sprintf("%s-%s.tgz", name, version)
+}
+
+// entry is a file to be written into the archive
+type entry struct {
+	rel  string
+	src  string
+	data []byte
+	mode int64
+}
+
+// Package writes cookbookDir to w as a gzipped tarball with every entry below
+// a "<name>/" prefix. Files matching chefignore are skipped, entries are written
+// in sorted order with normalized owners, modes and mtimes, and metadata.json
+// is compiled from metadata.rb when the cookbook has one.
+func Package(cookbookDir string, w io.Writer, opts Options) (*Manifest, error) {
+	md, metadataJSON, err := loadMetadata(cookbookDir)
+	if err != nil {
+		return nil, err
+	}
+
+	ignore, err := LoadChefignore(cookbookDir)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, ignored, err := collect(cookbookDir, ignore)
+	if err != nil {
+		return nil, err
+	}
+	entries = withMetadataJSON(entries, metadataJSON)
+
+	manifest := &Manifest{Name: md.Name, Version: md.Version, Ignored: ignored}
+
+	modTime := opts.ModTime
+	if modTime.IsZero() {
+		modTime = time.Unix(0, 0)
+	}
+	modTime = modTime.UTC().Truncate(time.Second)
+
+	algorithm := opts.Algorithm
+	if algorithm == "" {
+		algorithm = digest.Default
+	}
+	hasher, err := digest.Lookup(algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.New()
+	artifactSum := hasher.New()
+	gz := gzip.NewWriter(io.MultiWriter(w, sum, artifactSum))
+	tw := tar.NewWriter(gz)
+
+	written := make(map[string]bool)
+	for _, e := range entries {
+		for _, dir := range parentDirs(e.rel) {
+			if written[dir] {
+				continue
+			}
+			written[dir] = true
+			if err := tw.WriteHeader(&tar.Header{
+				Typeflag: tar.TypeDir,
+				Name:     md.Name + "/" + dir + "/",
+				Mode:     0755,
+				ModTime:  modTime,
+			}); err != nil {
+				return nil, fmt.Errorf("failed to write %s: %w", dir, err)
+			}
+		}
+
+		file, err := writeFile(tw, md.Name, e, modTime, hasher)
+		if err != nil {
+			return nil, err
+		}
+		manifest.Files = append(manifest.Files, file)
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish tarball: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish gzip stream: %w", err)
+	}
+
+	manifest.SHA256 = hex.EncodeToString(sum.Sum(nil))
+	manifest.Digest = digest.Of(algorithm, artifactSum).String()
+	log.WithField(logging.CookbookField, md.Name).Debugf("Packaged %d file(s), %d ignored", len(manifest.Files), len(ignored))
+	return manifest, nil
+}
+
+// PackageFile packages cookbookDir into outDir/<name>-<version>.tgz and
+// returns the manifest and the artifact path. The artifact is written to a
+// temporary file first so a failed run never leaves a truncated .tgz behind.
+func PackageFile(cookbookDir, outDir string, opts Options) (*Manifest, string, error) {
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return nil, "", fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(outDir, ".cookbook-*.tgz")
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create artifact: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	manifest, err := Package(cookbookDir, tmp, opts)
+	if closeErr := tmp.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to write artifact: %w", closeErr)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	artifact := filepath.Join(outDir, ArchiveName(manifest.Name, manifest.Version))
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return nil, "", fmt.Errorf("failed to write artifact: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), artifact); err != nil {
+		return nil, "", fmt.Errorf("failed to write artifact: %w", err)
+	}
+	return manifest, artifact, nil
+}
+
+// loadMetadata returns the cookbook metadata and the metadata.json to package.
+// metadata.rb wins over a checked-in metadata.json, as with knife cookbook upload.
+func loadMetadata(cookbookDir string) (*metadata.Metadata, []byte, error) {
+	rbPath := filepath.Join(cookbookDir, "metadata.rb")
+	if _, err := os.Stat(rbPath); err == nil {
+		md, err := metadata.ParseFile(rbPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := md.Validate(); err != nil {
+			return nil, nil, fmt.Errorf("invalid %s: %w", rbPath, err)
+		}
+		data, err := md.JSON()
+		if err != nil {
+			return nil, nil, err
+		}
+		return md, data, nil
+	}
+
+	jsonPath := filepath.Join(cookbookDir, "metadata.json")
+	data, err := os.ReadFile(jsonPath)
+	if os.IsNotExist(err) {
+		return nil, nil, fmt.Errorf("no metadata.rb or metadata.json found in %s", cookbookDir)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read %s: %w", jsonPath, err)
+	}
+	md, err := metadata.ParseJSON(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid %s: %w", jsonPath, err)
+	}
+	if err := md.Validate(); err != nil {
+		return nil, nil, fmt.Errorf("invalid %s: %w", jsonPath, err)
+	}
+	return md, nil, nil
+}
+
+// collect returns the files to package in sorted order and the ignored paths
+func collect(cookbookDir string, ignore *Chefignore) ([]entry, []string, error) {
+	var entries []entry
+	var ignored []string
+
+	err := filepath.WalkDir(cookbookDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == cookbookDir {
+			return nil
+		}
+		rel, err := filepath.Rel(cookbookDir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if ignore.Ignored(rel) {
+			ignored = append(ignored, rel)
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		// Symlinked files are packaged with their target's content;
+		// anything else that is not a regular file is skipped.
+		info, err := os.Stat(p)
+		if err != nil {
+			return fmt.Errorf("failed to stat %s: %w", p, err)
+		}
+		if !info.Mode().IsRegular() {
+			log.Debugf("Skipping %s: not a regular file", rel)
+			return nil
+		}
+
+		mode := int64(0644)
+		if info.Mode()&0111 != 0 {
+			mode = 0755
+		}
+		entries = append(entries, entry{rel: rel, src: p, mode: mode})
+		return nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to walk %s: %w", cookbookDir, err)
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].rel < entries[j].rel })
+	sort.Strings(ignored)
+	return entries, ignored, nil
+}
+
+// withMetadataJSON replaces or adds metadata.json with the compiled content
+func withMetadataJSON(entries []entry, data []byte) []entry {
+	if data == nil {
+		return entries
+	}
+	compiled := entry{rel: "metadata.json", data: data, mode: 0644}
+	for i := range entries {
+		if entries[i].rel == compiled.rel {
+			entries[i] = compiled
+			return entries
+		}
+	}
+	entries = append(entries, compiled)
+	sort.Slice(entries, func(i, j int) bool { return entries[i].rel < entries[j].rel })
+	return entries
+}
+
+// writeFile writes one file below prefix and returns its checksums
+func writeFile(tw *tar.Writer, prefix string, e entry, modTime time.Time, hasher digest.Hasher) (File, error) {
+	data := e.data
+	if data == nil {
+		var err error
+		if data, err = os.ReadFile(e.src); err != nil {
+			return File{}, fmt.Errorf("failed to read %s: %w", e.src, err)
+		}
+	}
+
+	if err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     prefix + "/" + e.rel,
+		Mode:     e.mode,
+		Size:     int64(len(data)),
+		ModTime:  modTime,
+	}); err != nil {
+		return File{}, fmt.Errorf("failed to write %s: %w", e.rel, err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return File{}, fmt.Errorf("failed to write %s: %w", e.rel, err)
+	}
+
+	md5sum := md5.Sum(data)
+	shasum := sha256.Sum256(data)
+	h := hasher.New()
+	h.Write(data)
+	return File{
+		Path:   e.rel,
+		Size:   int64(len(data)),
+		MD5:    hex.EncodeToString(md5sum[:]),
+		SHA256: hex.EncodeToString(shasum[:]),
+		Digest: digest.Of(hasher.Algorithm(), h).String(),
+	}, nil
+}
+
+// parentDirs returns the directories above a slash-separated path, outermost first
+func parentDirs(rel string) []string {
+	var dirs []string
+	for dir := path.Dir(rel); dir != "."; dir = path.Dir(dir) {
+		dirs = append([]string{dir}, dirs...)
+	}
+	return dirs
+}
@@ -0,0 +1,218 @@
+package cookbook
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/digest"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// readTarball returns the entry headers and file contents of a .tgz
+func readTarball(t *testing.T, data []byte) ([]*tar.Header, map[string]string) {
+	t.Helper()
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+	var headers []*tar.Header
+	contents := make(map[string]string)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		headers = append(headers, header)
+		body, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents[header.Name] = string(body)
+	}
+	return headers, contents
+}
+
+func TestPackage(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "nginx")
+	writeFiles(t, dir, map[string]string{
+		"metadata.rb":           "name 'nginx'\nversion '2.7'\n",
+		"metadata.json":         `{"name": "stale"}`,
+		"recipes/default.rb":    "package 'nginx'\n",
+		"templates/nginx.erb":   "worker_processes 1;\n",
+		"test/integration/a.rb": "describe 'x'\n",
+		"README.md~":            "backup\n",
+		".git/config":           "[core]\n",
+		"chefignore":            "# editor files\n*~\n\ntest/*\n",
+	})
+	if err := os.Chmod(filepath.Join(dir, "recipes/default.rb"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	manifest, err := Package(dir, &buf, Options{})
+	if err != nil {
+		t.Fatalf("Package() error = %v", err)
+	}
+
+	if manifest.Name != "nginx" || manifest.Version != "2.7.0" {
+		t.Errorf("manifest = %s %s, want nginx 2.7.0", manifest.Name, manifest.Version)
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	if manifest.SHA256 != hex.EncodeToString(sum[:]) {
+		t.Errorf("SHA256 = %s, want checksum of the artifact", manifest.SHA256)
+	}
+	if manifest.Digest != "sha256:"+manifest.SHA256 {
+		t.Errorf("Digest = %s, want the sha256 checksum", manifest.Digest)
+	}
+
+	headers, contents := readTarball(t, buf.Bytes())
+	var names []string
+	for _, header := range headers {
+		names = append(names, header.Name)
+		if !header.ModTime.Equal(time.Unix(0, 0)) || header.Uid != 0 || header.Uname != "" {
+			t.Errorf("%s: header not normalized: %+v", header.Name, header)
+		}
+	}
+	wantNames := []string{
+		"nginx/chefignore",
+		"nginx/metadata.json",
+		"nginx/metadata.rb",
+		"nginx/recipes/",
+		"nginx/recipes/default.rb",
+		"nginx/templates/",
+		"nginx/templates/nginx.erb",
+	}
+	if !reflect.DeepEqual(names, wantNames) {
+		t.Errorf("entries = %v, want %v", names, wantNames)
+	}
+
+	for _, header := range headers {
+		if header.Name == "nginx/recipes/default.rb" && header.Mode != 0755 {
+			t.Errorf("executable mode = %o, want 755", header.Mode)
+		}
+	}
+	if got := contents["nginx/metadata.json"]; !bytes.Contains([]byte(got), []byte(`"version": "2.7.0"`)) {
+		t.Errorf("metadata.json was not compiled from metadata.rb:\n%s", got)
+	}
+
+	wantIgnored := []string{".git", "README.md~", "test/integration"}
+	if !reflect.DeepEqual(manifest.Ignored, wantIgnored) {
+		t.Errorf("Ignored = %v, want %v", manifest.Ignored, wantIgnored)
+	}
+	if len(manifest.Files) != 5 || manifest.Files[0].Path != "chefignore" || manifest.Files[0].MD5 == "" {
+		t.Errorf("Files = %+v", manifest.Files)
+	}
+}
+
+func TestPackage_Algorithm(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "apt")
+	writeFiles(t, dir, map[string]string{
+		"metadata.json": `{"name": "apt", "version": "7.4.0"}`,
+	})
+
+	var buf bytes.Buffer
+	manifest, err := Package(dir, &buf, Options{Algorithm: digest.SHA512})
+	if err != nil {
+		t.Fatalf("Package() error = %v", err)
+	}
+	d, err := digest.Parse(manifest.Digest)
+	if err != nil || d.Algorithm != digest.SHA512 || d.Verify(buf.Bytes()) != nil {
+		t.Errorf("Digest = %s, %v, want the sha512 checksum of the artifact", manifest.Digest, err)
+	}
+	if !strings.HasPrefix(manifest.Files[0].Digest, "sha512:") {
+		t.Errorf("Files[0].Digest = %s, want a sha512 digest", manifest.Files[0].Digest)
+	}
+
+	if _, err := Package(dir, &buf, Options{Algorithm: "crc32"}); err == nil {
+		t.Error("Package() with an unknown algorithm succeeded")
+	}
+}
+
+func TestPackage_Reproducible(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "apt")
+	writeFiles(t, dir, map[string]string{
+		"metadata.json":      `{"name": "apt", "version": "7.4.0"}`,
+		"recipes/default.rb": "",
+		"libraries/a.rb":     "",
+	})
+
+	var first, second bytes.Buffer
+	if _, err := Package(dir, &first, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	// Touch a file; the artifact must not change
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "libraries/a.rb"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Package(dir, &second, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(first.Bytes(), second.Bytes()) {
+		t.Error("packaging the same tree twice produced different artifacts")
+	}
+}
+
+func TestPackage_RequiresMetadata(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"recipes/default.rb": ""})
+	if _, err := Package(dir, io.Discard, Options{}); err == nil {
+		t.Error("Package() expected error without metadata")
+	}
+}
+
+func TestPackageFile(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "apt")
+	writeFiles(t, dir, map[string]string{"metadata.json": `{"name": "apt", "version": "7.4.0"}`})
+	outDir := filepath.Join(t.TempDir(), "pkg")
+
+	manifest, artifact, err := PackageFile(dir, outDir, Options{})
+	if err != nil {
+		t.Fatalf("PackageFile() error = %v", err)
+	}
+	if filepath.Base(artifact) != "apt-7.4.0.tgz" {
+		t.Errorf("artifact = %s, want apt-7.4.0.tgz", artifact)
+	}
+	data, err := os.ReadFile(artifact)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(data)
+	if manifest.SHA256 != hex.EncodeToString(sum[:]) {
+		t.Error("manifest checksum does not match the written artifact")
+	}
+
+	entries, err := os.ReadDir(outDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("output directory holds %d entries, want only the artifact", len(entries))
+	}
+}
@@ -0,0 +1,77 @@
+// Package credentials resolves secrets such as Supermarket and Artifactory
+// API keys without storing them in plaintext. A credential value is either a
+// literal secret or a reference:
+//
+//	env:VAR_NAME     read from the environment variable VAR_NAME
+//	keychain:NAME    read NAME from the OS keychain (macOS Keychain,
+//	                 Windows Credential Manager or the Secret Service)
+package credentials
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Service is the keychain service name credentials are stored under
+const Service = "berkshelf"
+
+// Reference prefixes
+const (
+	EnvPrefix      = "env:"
+	KeychainPrefix = "keychain:"
+)
+
+// ErrNotFound is returned when a keychain has no credential with the requested name
+var ErrNotFound = errors.New("credential not found")
+
+// Keyring stores named secrets
+type Keyring interface {
+	// Backend names the storage, e.g. "keychain", "wincred" or "secret-service"
+	Backend() string
+	Get(name string) (string, error)
+	Set(name, secret string) error
+	Delete(name string) error
+}
+
+// DefaultKeyring is the OS keychain used to resolve keychain: references
+var DefaultKeyring Keyring = systemKeyring()
+
+// IsReference reports whether value refers to a secret stored elsewhere
+func IsReference(value string) bool {
+	return strings.HasPrefix(value, EnvPrefix) || strings.HasPrefix(value, KeychainPrefix)
+}
+
+// Resolve returns the secret value refers to. Values that are not references
+// are returned unchanged.
+func Resolve(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, EnvPrefix):
+		name := strings.TrimPrefix(value, EnvPrefix)
+		secret, ok := os.LookupEnv(name)
+		if !ok || secret == "" {
+			return "", fmt.Errorf("credential %s: environment variable %s is not set", value, name)
+		}
+		return secret, nil
+	case strings.HasPrefix(value, KeychainPrefix):
+		name := strings.TrimPrefix(value, KeychainPrefix)
+		secret, err := DefaultKeyring.Get(name)
+		if err != nil {
+			return "", fmt.Errorf("credential %s: %w", value, err)
+		}
+		return secret, nil
+	default:
+		return value, nil
+	}
+}
+
+// unsupportedKeyring is used on platforms without a supported keychain
+type unsupportedKeyring struct{}
+
+var errUnsupported = errors.New("no OS keychain is supported on this platform; use env: references instead")
+
+func (unsupportedKeyring) Backend() string            { return "none" }
+func (unsupportedKeyring) Get(string) (string, error) { return "", errUnsupported }
+func (unsupportedKeyring) Set(string, string) error   { return errUnsupported }
+func (unsupportedKeyring) Delete(string) error        { return errUnsupported }
@@ -0,0 +1,88 @@
+package credentials
+
+import (
+	"errors"
+	"testing"
+)
+
+// memoryKeyring is an in-memory Keyring for tests
+type memoryKeyring map[string]string
+
+func (m memoryKeyring) Backend() string { return "memory" }
+
+func (m memoryKeyring) Get(name string) (string, error) {
+	secret, ok := m[name]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return secret, nil
+}
+
+func (m memoryKeyring) Set(name, secret string) error {
+	m[name] = secret
+	return nil
+}
+
+func (m memoryKeyring) Delete(name string) error {
+	if _, ok := m[name]; !ok {
+		return ErrNotFound
+	}
+	delete(m, name)
+	return nil
+}
+
+func TestResolve(t *testing.T) {
+	original := DefaultKeyring
+	t.Cleanup(func() { DefaultKeyring = original })
+	DefaultKeyring = memoryKeyring{"artifactory": "from-keychain"}
+
+	t.Setenv("BERKS_TEST_API_KEY", "from-env")
+
+	tests := []struct {
+		value   string
+		want    string
+		wantErr error
+	}{
+		{value: "plaintext", want: "plaintext"},
+		{value: "env:BERKS_TEST_API_KEY", want: "from-env"},
+		{value: "keychain:artifactory", want: "from-keychain"},
+		{value: "keychain:missing", wantErr: ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := Resolve(tt.value)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Resolve(%q) error = %v, want %v", tt.value, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Resolve(%q) error = %v", tt.value, err)
+			}
+			if got != tt.want {
+				t.Errorf("Resolve(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolve_UnsetEnv(t *testing.T) {
+	if _, err := Resolve("env:BERKS_TEST_UNSET_VARIABLE"); err == nil {
+		t.Error("Resolve() expected error for unset environment variable")
+	}
+}
+
+func TestIsReference(t *testing.T) {
+	for value, want := range map[string]bool{
+		"env:KEY":       true,
+		"keychain:name": true,
+		"plain-secret":  false,
+		"":              false,
+	} {
+		if got := IsReference(value); got != want {
+			t.Errorf("IsReference(%q) = %v, want %v", value, got, want)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package credentials
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// securityKeyring stores credentials in the macOS login keychain using the
+// security(1) tool
+type securityKeyring struct{}
+
+func systemKeyring() Keyring { return securityKeyring{} }
+
+func (securityKeyring) Backend() string { return "keychain" }
+
+func (securityKeyring) Get(name string) (string, error) {
+	out, err := security("find-generic-password", "-s", Service, "-a", name, "-w")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(out, "\n"), nil
+}
+
+func (securityKeyring) Set(name, secret string) error {
+	if strings.ContainsAny(secret, "\r\n") {
+		return errors.New("keychain secrets cannot span lines")
+	}
+	// The command is read from stdin by security -i so the secret never
+	// appears in the process list; -U updates an existing item instead of
+	// failing
+	return securityInteractive("add-generic-password", "-U", "-s", Service, "-a", name, "-w", secret)
+}
+
+func (securityKeyring) Delete(name string) error {
+	_, err := security("delete-generic-password", "-s", Service, "-a", name)
+	return err
+}
+
+func security(args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("security", args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		// errSecItemNotFound
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 44 {
+			return "", ErrNotFound
+		}
+		return "", fmt.Errorf("security %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// securityInteractive runs one security command read from stdin, quoting
+// its arguments as the interactive mode splits them. That mode exits 0
+// whatever the command does, so any output on stderr is its failure.
+func securityInteractive(args ...string) error {
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = `"` + escape.Replace(arg) + `"`
+	}
+	var stderr bytes.Buffer
+	cmd := exec.Command("security", "-i")
+	cmd.Stdin = strings.NewReader(strings.Join(quoted, " ") + "\n")
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err == nil && stderr.Len() > 0 {
+		err = errors.New("command failed")
+	}
+	if err != nil {
+		return fmt.Errorf("security %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+//go:build !darwin && !windows
+
+package credentials
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// secretServiceKeyring stores credentials through the freedesktop Secret
+// Service (GNOME Keyring, KWallet) using the secret-tool CLI from libsecret
+type secretServiceKeyring struct{}
+
+func systemKeyring() Keyring {
+	if _, err := exec.LookPath("secret-tool"); err != nil {
+		return unsupportedKeyring{}
+	}
+	return secretServiceKeyring{}
+}
+
+func (secretServiceKeyring) Backend() string { return "secret-service" }
+
+func (secretServiceKeyring) Get(name string) (string, error) {
+	out, err := secretTool("", "lookup", "service", Service, "account", name)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(out, "\n"), nil
+}
+
+func (secretServiceKeyring) Set(name, secret string) error {
+	// The secret is passed on stdin so it never appears in the process list
+	_, err := secretTool(secret, "store", "--label", Service+": "+name, "service", Service, "account", name)
+	return err
+}
+
+func (secretServiceKeyring) Delete(name string) error {
+	if _, err := secretTool("", "lookup", "service", Service, "account", name); err != nil {
+		return err
+	}
+	_, err := secretTool("", "clear", "service", Service, "account", name)
+	return err
+}
+
+func secretTool(stdin string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("secret-tool", args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		// lookup exits 1 with no output when nothing matches
+		if errors.As(err, &exitErr) && args[0] == "lookup" && stderr.Len() == 0 {
+			return "", ErrNotFound
+		}
+		return "", fmt.Errorf("secret-tool %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
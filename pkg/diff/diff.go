@@ -0,0 +1,274 @@
+// Package diff compares two versions of a cookbook: their metadata, the
+// files they contain and the changes to each file, as unified diffs.
+package diff
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	"github.com/bdwyertech/go-berkshelf/pkg/logging"
+	"github.com/bdwyertech/go-berkshelf/pkg/metadata"
+	"github.com/bdwyertech/go-berkshelf/pkg/source"
+)
+
+var log = logging.For("diff")
+
+// Status is how a file changed between the two versions
+type Status string
+
+const (
+	Added    Status = "added"
+	Removed  Status = "removed"
+	Modified Status = "modified"
+)
+
+// Options selects what is compared
+type Options struct {
+	// NamesOnly lists the changed files without their diffs
+	NamesOnly bool
+	// MetadataOnly compares only the metadata
+	MetadataOnly bool
+	// Context is the number of unchanged lines around each change
+	Context int
+}
+
+// File is a file that differs between the two versions
+type File struct {
+	Path   string `json:"path"`
+	Status Status `json:"status"`
+	// Binary files are compared by content but not diffed
+	Binary bool   `json:"binary,omitempty"`
+	Diff   string `json:"diff,omitempty"`
+}
+
+// Result is the comparison of two versions of a cookbook
+type Result struct {
+	Cookbook string `json:"cookbook"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Source   string `json:"source,omitempty"`
+	// Metadata is the unified diff of the normalized metadata
+	Metadata string `json:"metadata,omitempty"`
+	Files    []File `json:"files,omitempty"`
+}
+
+// Empty reports whether the versions are the same
+func (r *Result) Empty() bool {
+	return r.Metadata == "" && len(r.Files) == 0
+}
+
+// Compare downloads two versions of a cookbook from the first of sources
+// that has the from version, and compares them
+func Compare(ctx context.Context, sources []source.CookbookSource, name, from, to string, opts Options) (*Result, error) {
+	fromVersion, err := berkshelf.NewVersion(from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid version %q: %w", from, err)
+	}
+	toVersion, err := berkshelf.NewVersion(to)
+	if err != nil {
+		return nil, fmt.Errorf("invalid version %q: %w", to, err)
+	}
+
+	var src source.CookbookSource
+	var fromCookbook *berkshelf.Cookbook
+	var lastErr error
+	for _, candidate := range sources {
+		if fromCookbook, lastErr = candidate.FetchCookbook(ctx, name, fromVersion); lastErr == nil {
+			src = candidate
+			break
+		}
+		log.Debugf("%s %s not found in %s: %v", name, from, candidate.Name(), lastErr)
+	}
+	if src == nil {
+		if lastErr == nil {
+			lastErr = fmt.Errorf("no sources configured")
+		}
+		return nil, fmt.Errorf("failed to find %s %s: %w", name, from, lastErr)
+	}
+	toCookbook, err := src.FetchCookbook(ctx, name, toVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find %s %s in %s: %w", name, to, src.Name(), err)
+	}
+
+	dir, err := os.MkdirTemp("", "berks-diff-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	fromDir, toDir := filepath.Join(dir, "from"), filepath.Join(dir, "to")
+	if err := src.DownloadAndExtractCookbook(ctx, fromCookbook, fromDir); err != nil {
+		return nil, fmt.Errorf("failed to download %s %s: %w", name, from, err)
+	}
+	if err := src.DownloadAndExtractCookbook(ctx, toCookbook, toDir); err != nil {
+		return nil, fmt.Errorf("failed to download %s %s: %w", name, to, err)
+	}
+
+	result, err := Dirs(fromDir, toDir, opts)
+	if err != nil {
+		return nil, err
+	}
+	result.Cookbook, result.From, result.To, result.Source = name, from, to, src.GetSourceURL()
+	return result, nil
+}
+
+// Dirs compares two cookbook directories
+func Dirs(fromDir, toDir string, opts Options) (*Result, error) {
+	result := &Result{}
+
+	fromMetadata, err := normalizedMetadata(fromDir)
+	if err != nil {
+		return nil, err
+	}
+	toMetadata, err := normalizedMetadata(toDir)
+	if err != nil {
+		return nil, err
+	}
+	if !opts.NamesOnly {
+		result.Metadata = Unified("a/metadata", "b/metadata", fromMetadata, toMetadata, opts.Context)
+	}
+	if opts.MetadataOnly {
+		return result, nil
+	}
+
+	fromFiles, err := listFiles(fromDir)
+	if err != nil {
+		return nil, err
+	}
+	toFiles, err := listFiles(toDir)
+	if err != nil {
+		return nil, err
+	}
+	paths := make(map[string]bool, len(fromFiles)+len(toFiles))
+	for path := range fromFiles {
+		paths[path] = true
+	}
+	for path := range toFiles {
+		paths[path] = true
+	}
+	sorted := make([]string, 0, len(paths))
+	for path := range paths {
+		sorted = append(sorted, path)
+	}
+	sort.Strings(sorted)
+
+	for _, path := range sorted {
+		_, inFrom := fromFiles[path]
+		_, inTo := toFiles[path]
+		var a, b []byte
+		if inFrom {
+			if a, err = os.ReadFile(filepath.Join(fromDir, filepath.FromSlash(path))); err != nil {
+				return nil, err
+			}
+		}
+		if inTo {
+			if b, err = os.ReadFile(filepath.Join(toDir, filepath.FromSlash(path))); err != nil {
+				return nil, err
+			}
+		}
+
+		file := File{Path: path, Status: Modified}
+		switch {
+		case !inFrom:
+			file.Status = Added
+		case !inTo:
+			file.Status = Removed
+		case bytes.Equal(a, b):
+			continue
+		}
+		file.Binary = binary(a) || binary(b)
+		if !opts.NamesOnly && !file.Binary {
+			fromName, toName := "a/"+path, "b/"+path
+			if !inFrom {
+				fromName = "/dev/null"
+			}
+			if !inTo {
+				toName = "/dev/null"
+			}
+			file.Diff = Unified(fromName, toName, string(a), string(b), opts.Context)
+		}
+		result.Files = append(result.Files, file)
+	}
+	return result, nil
+}
+
+// normalizedMetadata returns the cookbook's metadata as indented JSON, read
+// from metadata.json or else metadata.rb, so that versions are compared
+// field by field whichever file they ship
+func normalizedMetadata(dir string) (string, error) {
+	var m *metadata.Metadata
+	data, err := os.ReadFile(filepath.Join(dir, "metadata.json"))
+	switch {
+	case err == nil:
+		if m, err = metadata.ParseJSON(data); err != nil {
+			return "", fmt.Errorf("parsing %s: %w", filepath.Join(dir, "metadata.json"), err)
+		}
+	case errors.Is(err, fs.ErrNotExist):
+		m, err = metadata.ParseFile(filepath.Join(dir, "metadata.rb"))
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", nil
+		} else if err != nil {
+			return "", fmt.Errorf("parsing %s: %w", filepath.Join(dir, "metadata.rb"), err)
+		}
+	default:
+		return "", err
+	}
+	encoded, err := m.JSON()
+	if err != nil {
+		return "", err
+	}
+	return string(encoded) + "\n", nil
+}
+
+// listFiles returns the regular files below dir by slash-separated path
+func listFiles(dir string) (map[string]struct{}, error) {
+	files := make(map[string]struct{})
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = struct{}{}
+		return nil
+	})
+	return files, err
+}
+
+// binary reports whether data looks like a binary file: one with a NUL byte
+// in its first 8000 bytes, as git decides
+func binary(data []byte) bool {
+	return bytes.IndexByte(data[:min(len(data), 8000)], 0) >= 0
+}
+
+// Write prints the result as a unified diff, or as a list of changed files
+// with their status when names is set
+func (r *Result) Write(w io.Writer, names bool) error {
+	var out strings.Builder
+	if r.Metadata != "" {
+		out.WriteString(r.Metadata)
+	}
+	for _, file := range r.Files {
+		switch {
+		case names:
+			fmt.Fprintf(&out, "%s\t%s\n", strings.ToUpper(string(file.Status[:1])), file.Path)
+		case file.Binary:
+			fmt.Fprintf(&out, "Binary files a/%s and b/%s differ\n", file.Path, file.Path)
+		default:
+			out.WriteString(file.Diff)
+		}
+	}
+	_, err := io.WriteString(w, out.String())
+	return err
+}
@@ -0,0 +1,165 @@
+package diff
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUnified(t *testing.T) {
+	a := "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\n"
+	b := "one\ntwo\nTHREE\nfour\nfive\nsix\nseven\neight\nnine\nten\neleven"
+
+	want := `--- a/file
++++ b/file
+@@ -1,6 +1,6 @@
+ one
+ two
+-three
++THREE
+ four
+ five
+ six
+@@ -8,3 +8,4 @@
+ eight
+ nine
+ ten
++eleven
+\ No newline at end of file
+`
+	if got := Unified("a/file", "b/file", a, b, DefaultContext); got != want {
+		t.Errorf("Unified() =\n%s\nwant\n%s", got, want)
+	}
+
+	if got := Unified("a/file", "b/file", a, a, DefaultContext); got != "" {
+		t.Errorf("Unified() of equal texts = %q, want empty", got)
+	}
+}
+
+func TestUnified_Merged(t *testing.T) {
+	// Changes within twice the context of each other share a hunk
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n"
+	b := "1\nX\n3\n4\n5\n6\nY\n8\n"
+	got := Unified("a", "b", a, b, DefaultContext)
+	if strings.Count(got, "@@ ") != 1 || !strings.Contains(got, "@@ -1,8 +1,8 @@") {
+		t.Errorf("Unified() =\n%s\nwant a single hunk", got)
+	}
+}
+
+func TestUnified_AddedFile(t *testing.T) {
+	got := Unified("/dev/null", "b/new.rb", "", "puts 1\n", DefaultContext)
+	want := "--- /dev/null\n+++ b/new.rb\n@@ -0,0 +1 @@\n+puts 1\n"
+	if got != want {
+		t.Errorf("Unified() = %q, want %q", got, want)
+	}
+}
+
+func writeCookbook(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestDirs(t *testing.T) {
+	from := writeCookbook(t, map[string]string{
+		"metadata.rb":          "name 'nginx'\nversion '2.7.5'\ndepends 'apt'\n",
+		"recipes/default.rb":   "package 'nginx'\n",
+		"attributes/old.rb":    "default['nginx']['old'] = true\n",
+		"files/default/blob":   "\x00\x01",
+		"templates/nginx.conf": "worker_processes 1;\n",
+	})
+	to := writeCookbook(t, map[string]string{
+		"metadata.json":        `{"name":"nginx","version":"2.7.6","dependencies":{"apt":">= 0.0.0"}}`,
+		"recipes/default.rb":   "package 'nginx'\nservice 'nginx'\n",
+		"attributes/new.rb":    "default['nginx']['new'] = true\n",
+		"files/default/blob":   "\x00\x02",
+		"templates/nginx.conf": "worker_processes 1;\n",
+	})
+
+	result, err := Dirs(from, to, Options{Context: DefaultContext})
+	if err != nil {
+		t.Fatalf("Dirs() error = %v", err)
+	}
+
+	// metadata.rb and metadata.json are compared by their fields
+	if !strings.Contains(result.Metadata, `-  "version": "2.7.5"`) || !strings.Contains(result.Metadata, `+  "version": "2.7.6"`) {
+		t.Errorf("Metadata =\n%s\nwant the version change", result.Metadata)
+	}
+	if strings.Contains(result.Metadata, "apt") {
+		t.Errorf("Metadata =\n%s\nwant the unchanged dependency left out", result.Metadata)
+	}
+
+	got := make(map[string]File)
+	for _, file := range result.Files {
+		got[file.Path] = file
+	}
+	want := map[string]Status{
+		"attributes/new.rb":  Added,
+		"attributes/old.rb":  Removed,
+		"files/default/blob": Modified,
+		"metadata.json":      Added,
+		"metadata.rb":        Removed,
+		"recipes/default.rb": Modified,
+	}
+	if len(got) != len(want) {
+		t.Errorf("Files = %+v, want %v", result.Files, want)
+	}
+	for path, status := range want {
+		if got[path].Status != status {
+			t.Errorf("%s status = %q, want %q", path, got[path].Status, status)
+		}
+	}
+	if !got["files/default/blob"].Binary || got["files/default/blob"].Diff != "" {
+		t.Errorf("blob = %+v, want a binary file without a diff", got["files/default/blob"])
+	}
+	if !strings.Contains(got["recipes/default.rb"].Diff, "+service 'nginx'") {
+		t.Errorf("recipes/default.rb diff =\n%s", got["recipes/default.rb"].Diff)
+	}
+	if !strings.HasPrefix(got["attributes/new.rb"].Diff, "--- /dev/null\n+++ b/attributes/new.rb\n") {
+		t.Errorf("attributes/new.rb diff =\n%s", got["attributes/new.rb"].Diff)
+	}
+}
+
+func TestDirs_Modes(t *testing.T) {
+	from := writeCookbook(t, map[string]string{"metadata.rb": "name 'apt'\nversion '1.0.0'\n", "recipes/default.rb": "a\n"})
+	to := writeCookbook(t, map[string]string{"metadata.rb": "name 'apt'\nversion '1.1.0'\n", "recipes/default.rb": "b\n"})
+
+	names, err := Dirs(from, to, Options{NamesOnly: true})
+	if err != nil {
+		t.Fatalf("Dirs() error = %v", err)
+	}
+	var out bytes.Buffer
+	if err := names.Write(&out, true); err != nil {
+		t.Fatal(err)
+	}
+	if want := "M\tmetadata.rb\nM\trecipes/default.rb\n"; out.String() != want {
+		t.Errorf("names only = %q, want %q", out.String(), want)
+	}
+
+	meta, err := Dirs(from, to, Options{MetadataOnly: true})
+	if err != nil {
+		t.Fatalf("Dirs() error = %v", err)
+	}
+	if meta.Metadata == "" || len(meta.Files) != 0 {
+		t.Errorf("metadata only = %+v, want only the metadata diff", meta)
+	}
+
+	same, err := Dirs(from, from, Options{})
+	if err != nil {
+		t.Fatalf("Dirs() error = %v", err)
+	}
+	if !same.Empty() {
+		t.Errorf("Dirs() of one directory = %+v, want empty", same)
+	}
+}
@@ -0,0 +1,129 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// DefaultContext is the number of unchanged lines shown around each change
+const DefaultContext = 3
+
+// diffLine is one line of a line-oriented diff: ' ' unchanged, '-' removed
+// or '+' added
+type diffLine struct {
+	op   byte
+	text string
+}
+
+// Unified returns the unified diff that turns a into b, with context
+// unchanged lines around each change, or "" if they are equal. fromName and
+// toName label the two sides.
+func Unified(fromName, toName, a, b string, context int) string {
+	if a == b {
+		return ""
+	}
+	if context < 0 {
+		context = 0
+	}
+	lines := lineDiff(a, b)
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+
+	// aLine and bLine count the lines of a and b before lines[i]
+	aLine, bLine := 0, 0
+	for i := 0; i < len(lines); {
+		if lines[i].op == ' ' {
+			aLine++
+			bLine++
+			i++
+			continue
+		}
+
+		// A hunk starts context lines before the change, and runs until a
+		// stretch of more than 2*context unchanged lines
+		start := max(i-context, 0)
+		for j := start; j < i; j++ {
+			aLine--
+			bLine--
+		}
+		end := i
+		for end < len(lines) {
+			if lines[end].op != ' ' {
+				end++
+				continue
+			}
+			run := end
+			for run < len(lines) && lines[run].op == ' ' {
+				run++
+			}
+			if run == len(lines) || run-end > 2*context {
+				end = min(end+context, len(lines))
+				break
+			}
+			end = run
+		}
+
+		var aCount, bCount int
+		for _, l := range lines[start:end] {
+			if l.op != '+' {
+				aCount++
+			}
+			if l.op != '-' {
+				bCount++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(aLine, aCount), hunkRange(bLine, bCount))
+		for _, l := range lines[start:end] {
+			out.WriteByte(l.op)
+			out.WriteString(l.text)
+			if !strings.HasSuffix(l.text, "\n") {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		aLine += aCount
+		bLine += bCount
+		i = end
+	}
+	return out.String()
+}
+
+// hunkRange formats the start and length of one side of a hunk. Lines are
+// numbered from 1; an empty range starts at the line before it.
+func hunkRange(before, count int) string {
+	start := before + 1
+	if count == 0 {
+		start = before
+	}
+	if count == 1 {
+		return fmt.Sprint(start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+// lineDiff returns the lines of a and b, marked as unchanged, removed or added
+func lineDiff(a, b string) []diffLine {
+	dmp := diffmatchpatch.New()
+	dmp.DiffTimeout = 0
+	runesA, runesB, lineArray := dmp.DiffLinesToRunes(a, b)
+	diffs := dmp.DiffCharsToLines(dmp.DiffMainRunes(runesA, runesB, false), lineArray)
+
+	var lines []diffLine
+	for _, d := range diffs {
+		op := byte(' ')
+		switch d.Type {
+		case diffmatchpatch.DiffDelete:
+			op = '-'
+		case diffmatchpatch.DiffInsert:
+			op = '+'
+		}
+		for _, text := range strings.SplitAfter(d.Text, "\n") {
+			if text != "" {
+				lines = append(lines, diffLine{op: op, text: text})
+			}
+		}
+	}
+	return lines
+}
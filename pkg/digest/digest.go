@@ -0,0 +1,235 @@
+// Package digest computes the checksums berkshelf records: cache keys,
+// lock file checksums and package manifest entries. A digest is written with
+// its algorithm,
+//
+//	sha512:cf83e1357eefb8bdf1542850d66d8007...
+//
+// so that records made with one algorithm stay readable after another is
+// chosen. Digests written before algorithms were recorded are bare hex, and
+// are read as MD5 or SHA-256 by their length.
+//
+// In FIPS mode, when Go's FIPS 140-3 mode is enabled (GODEBUG=fips140=on) or
+// RequireFIPS is called, only the approved algorithms SHA-256 and SHA-512 can
+// be looked up. Digests recorded with another algorithm then fail to parse,
+// and are treated as unverifiable rather than trusted.
+package digest
+
+import (
+	"crypto/fips140"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"lukechampine.com/blake3"
+
+	berrors "github.com/bdwyertech/go-berkshelf/pkg/errors"
+)
+
+// Algorithm identifies a hash algorithm
+type Algorithm string
+
+// Algorithms berkshelf provides. MD5 is only read and written where a
+// protocol requires it, such as Chef Server cookbook manifests.
+const (
+	MD5    Algorithm = "md5"
+	SHA256 Algorithm = "sha256"
+	SHA512 Algorithm = "sha512"
+	BLAKE3 Algorithm = "blake3"
+)
+
+// Default is the algorithm used unless another is configured
+const Default = SHA256
+
+// ErrNotApproved is returned in FIPS mode for algorithms FIPS 140-3 does
+// not approve
+var ErrNotApproved = errors.New("not a FIPS 140-3 approved algorithm")
+
+// approved are the algorithms FIPS 140-3 approves for hashing
+var approved = map[Algorithm]bool{SHA256: true, SHA512: true}
+
+var requireFIPS atomic.Bool
+
+// Approved reports whether FIPS 140-3 approves the algorithm
+func Approved(algorithm Algorithm) bool {
+	return approved[algorithm]
+}
+
+// RequireFIPS restricts lookups to approved algorithms even when Go's FIPS
+// 140-3 mode is off
+func RequireFIPS(on bool) {
+	requireFIPS.Store(on)
+}
+
+// FIPS reports whether only approved algorithms can be used
+func FIPS() bool {
+	return requireFIPS.Load() || fips140.Enabled()
+}
+
+// Hasher creates the hashes of one algorithm
+type Hasher interface {
+	Algorithm() Algorithm
+	New() hash.Hash
+}
+
+type hasher struct {
+	algorithm Algorithm
+	new       func() hash.Hash
+}
+
+func (h hasher) Algorithm() Algorithm { return h.algorithm }
+func (h hasher) New() hash.Hash       { return h.new() }
+
+var (
+	mu      sync.RWMutex
+	hashers = map[Algorithm]Hasher{
+		MD5:    hasher{MD5, md5.New},
+		SHA256: hasher{SHA256, sha256.New},
+		SHA512: hasher{SHA512, sha512.New},
+		BLAKE3: hasher{BLAKE3, func() hash.Hash { return blake3.New(32, nil) }},
+	}
+)
+
+// Register adds an algorithm. It panics if the algorithm is already
+// registered or its name contains a colon, which separates it from the
+// digest.
+func Register(h Hasher) {
+	mu.Lock()
+	defer mu.Unlock()
+	algorithm := h.Algorithm()
+	if algorithm == "" || strings.Contains(string(algorithm), ":") {
+		panic(fmt.Sprintf("digest: invalid algorithm name %q", algorithm))
+	}
+	if _, exists := hashers[algorithm]; exists {
+		panic("digest: Register called twice for " + string(algorithm))
+	}
+	hashers[algorithm] = h
+}
+
+// Lookup returns the hasher of an algorithm. In FIPS mode only approved
+// algorithms are found.
+func Lookup(algorithm Algorithm) (Hasher, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	h, ok := hashers[algorithm]
+	if !ok {
+		return nil, fmt.Errorf("unknown checksum algorithm %q (supported: %s)", algorithm, strings.Join(names(), ", "))
+	}
+	if FIPS() && !Approved(algorithm) {
+		return nil, fmt.Errorf("checksum algorithm %s: %w", algorithm, ErrNotApproved)
+	}
+	return h, nil
+}
+
+// Algorithms returns the registered algorithms, sorted
+func Algorithms() []Algorithm {
+	mu.RLock()
+	defer mu.RUnlock()
+	algorithms := make([]Algorithm, 0, len(hashers))
+	for algorithm := range hashers {
+		algorithms = append(algorithms, algorithm)
+	}
+	sort.Slice(algorithms, func(i, j int) bool { return algorithms[i] < algorithms[j] })
+	return algorithms
+}
+
+// names returns the registered algorithm names, sorted; mu must be held
+func names() []string {
+	names := make([]string, 0, len(hashers))
+	for algorithm := range hashers {
+		names = append(names, string(algorithm))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New returns a hash of the algorithm
+func New(algorithm Algorithm) (hash.Hash, error) {
+	h, err := Lookup(algorithm)
+	if err != nil {
+		return nil, err
+	}
+	return h.New(), nil
+}
+
+// Digest is a checksum and the algorithm that computed it
+type Digest struct {
+	Algorithm Algorithm
+	// Hex is the lowercase hex encoded checksum
+	Hex string
+}
+
+// Sum returns the digest of data
+func Sum(algorithm Algorithm, data []byte) (Digest, error) {
+	h, err := New(algorithm)
+	if err != nil {
+		return Digest{}, err
+	}
+	h.Write(data)
+	return Of(algorithm, h), nil
+}
+
+// Of returns the digest of what was written to h, a hash of algorithm
+func Of(algorithm Algorithm, h hash.Hash) Digest {
+	return Digest{Algorithm: algorithm, Hex: hex.EncodeToString(h.Sum(nil))}
+}
+
+// Parse reads a digest written by String, or a bare hex MD5 or SHA-256
+func Parse(s string) (Digest, error) {
+	s = strings.TrimSpace(s)
+	algorithm, value, ok := strings.Cut(s, ":")
+	if !ok {
+		value = s
+		switch len(s) {
+		case 2 * md5.Size:
+			algorithm = string(MD5)
+		case 2 * sha256.Size:
+			algorithm = string(SHA256)
+		default:
+			return Digest{}, fmt.Errorf("checksum %q has no algorithm and is neither MD5 nor SHA-256", s)
+		}
+	}
+	if _, err := Lookup(Algorithm(algorithm)); err != nil {
+		return Digest{}, err
+	}
+	if _, err := hex.DecodeString(value); err != nil || value == "" {
+		return Digest{}, fmt.Errorf("checksum %q is not hex encoded", s)
+	}
+	return Digest{Algorithm: Algorithm(algorithm), Hex: strings.ToLower(value)}, nil
+}
+
+// String returns the digest as "<algorithm>:<hex>"
+func (d Digest) String() string {
+	return string(d.Algorithm) + ":" + d.Hex
+}
+
+// New returns a hash of the digest's algorithm, to check data against it
+func (d Digest) New() (hash.Hash, error) {
+	return New(d.Algorithm)
+}
+
+// Matches reports whether what was written to h, a hash returned by d.New,
+// has the digest d
+func (d Digest) Matches(h hash.Hash) bool {
+	return hex.EncodeToString(h.Sum(nil)) == d.Hex
+}
+
+// Verify checks that data has the digest d
+func (d Digest) Verify(data []byte) error {
+	h, err := d.New()
+	if err != nil {
+		return err
+	}
+	h.Write(data)
+	if !d.Matches(h) {
+		return berrors.WithType(fmt.Errorf("checksum mismatch: expected %s, got %s", d, Of(d.Algorithm, h)), berrors.ErrorTypeIntegrity)
+	}
+	return nil
+}
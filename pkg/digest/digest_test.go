@@ -0,0 +1,121 @@
+package digest
+
+import (
+	"crypto/sha1"
+	"errors"
+	"hash"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSum(t *testing.T) {
+	tests := []struct {
+		algorithm Algorithm
+		want      string
+	}{
+		{MD5, "900150983cd24fb0d6963f7d28e17f72"},
+		{SHA256, "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{SHA512, "ddaf35a193617abacc417349ae20413112e6fa4e89a97ea20a9eeee64b55d39a2192992a274fc1a836ba3c23a3feebbd454d4423643ce80e2a9ac94fa54ca49f"},
+		{BLAKE3, "6437b3ac38465133ffb63b75273a8db548c558465d79db03fd359c6cd5bd9d85"},
+	}
+	for _, tt := range tests {
+		d, err := Sum(tt.algorithm, []byte("abc"))
+		if err != nil {
+			t.Fatalf("Sum(%s) error = %v", tt.algorithm, err)
+		}
+		if d.String() != string(tt.algorithm)+":"+tt.want {
+			t.Errorf("Sum(%s) = %s, want %s", tt.algorithm, d, tt.want)
+		}
+		if err := d.Verify([]byte("abc")); err != nil {
+			t.Errorf("Verify() error = %v", err)
+		}
+		if err := d.Verify([]byte("abd")); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+			t.Errorf("Verify() of other data error = %v, want a mismatch", err)
+		}
+	}
+
+	if _, err := Sum("crc32", nil); err == nil || !strings.Contains(err.Error(), "blake3, md5, sha256, sha512") {
+		t.Errorf("Sum(crc32) error = %v, want the supported algorithms", err)
+	}
+}
+
+func TestParse(t *testing.T) {
+	md5 := "900150983cd24fb0d6963f7d28e17f72"
+	sha256 := "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+	tests := []struct {
+		in   string
+		want Digest
+	}{
+		// Bare digests recorded before algorithms were
+		{md5, Digest{MD5, md5}},
+		{strings.ToUpper(sha256), Digest{SHA256, sha256}},
+		{"sha256:" + sha256, Digest{SHA256, sha256}},
+		{"blake3:" + sha256, Digest{BLAKE3, sha256}},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("Parse(%q) = %+v, %v, want %+v", tt.in, got, err, tt.want)
+		}
+	}
+
+	for _, in := range []string{"", "abc", "crc32:00ff", "sha256:xyz", "sha256:"} {
+		if _, err := Parse(in); err == nil {
+			t.Errorf("Parse(%q) succeeded", in)
+		}
+	}
+}
+
+type sha1Hasher struct{}
+
+func (sha1Hasher) Algorithm() Algorithm { return "sha1" }
+func (sha1Hasher) New() hash.Hash       { return sha1.New() }
+
+func TestRegister(t *testing.T) {
+	Register(sha1Hasher{})
+	t.Cleanup(func() {
+		mu.Lock()
+		delete(hashers, "sha1")
+		mu.Unlock()
+	})
+
+	if !slices.Contains(Algorithms(), "sha1") {
+		t.Errorf("Algorithms() = %v, want sha1", Algorithms())
+	}
+	d, err := Parse("sha1:a9993e364706816aba3e25717850c26c9cd0d89d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Verify([]byte("abc")); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Register() of a registered algorithm did not panic")
+		}
+	}()
+	Register(sha1Hasher{})
+}
+
+func TestRequireFIPS(t *testing.T) {
+	RequireFIPS(true)
+	defer RequireFIPS(false)
+
+	for _, algorithm := range []Algorithm{SHA256, SHA512} {
+		if _, err := Lookup(algorithm); err != nil {
+			t.Errorf("Lookup(%s) in FIPS mode error = %v", algorithm, err)
+		}
+	}
+	for _, algorithm := range []Algorithm{MD5, BLAKE3} {
+		if _, err := Lookup(algorithm); !errors.Is(err, ErrNotApproved) {
+			t.Errorf("Lookup(%s) in FIPS mode error = %v, want ErrNotApproved", algorithm, err)
+		}
+	}
+
+	// Recorded digests of other algorithms cannot be verified
+	if _, err := Parse("900150983cd24fb0d6963f7d28e17f72"); !errors.Is(err, ErrNotApproved) {
+		t.Errorf("Parse() of an MD5 in FIPS mode error = %v, want ErrNotApproved", err)
+	}
+}
@@ -0,0 +1,96 @@
+// Package doctor checks that berkshelf can work in the current environment:
+// that key files exist, sources answer and accept the configured
+// credentials, caches are writable, SSH authentication is available for git
+// sources, and a cookbook resolves end to end.
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/logging"
+)
+
+var log = logging.For("doctor")
+
+// DefaultTimeout limits each check
+const DefaultTimeout = 30 * time.Second
+
+// Status is the outcome of a check
+type Status string
+
+// Check outcomes. A warning does not fail the diagnosis.
+const (
+	StatusPass Status = "pass"
+	StatusWarn Status = "warn"
+	StatusFail Status = "fail"
+	StatusSkip Status = "skip"
+)
+
+// Check is one diagnostic
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) Result
+}
+
+// Result is the outcome of a check. Hint says how to fix a check that did
+// not pass.
+type Result struct {
+	Name       string `json:"name"`
+	Status     Status `json:"status"`
+	Detail     string `json:"detail,omitempty"`
+	Hint       string `json:"hint,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// Run runs the checks in order, each limited to timeout
+func Run(ctx context.Context, checks []Check, timeout time.Duration) []Result {
+	results := make([]Result, 0, len(checks))
+	for _, check := range checks {
+		start := time.Now()
+		checkCtx, cancel := context.WithTimeout(ctx, timeout)
+		result := check.Run(checkCtx)
+		cancel()
+
+		result.Name = check.Name
+		result.DurationMS = time.Since(start).Milliseconds()
+		if result.Status == StatusFail && checkCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+			result.Detail = fmt.Sprintf("timed out after %s: %s", timeout, result.Detail)
+		}
+		log.Debugf("%s: %s (%s)", check.Name, result.Status, result.Detail)
+		results = append(results, result)
+	}
+	return results
+}
+
+// Failed returns the number of failed results
+func Failed(results []Result) int {
+	failed := 0
+	for _, result := range results {
+		if result.Status == StatusFail {
+			failed++
+		}
+	}
+	return failed
+}
+
+// Pass returns a passing result
+func Pass(format string, args ...any) Result {
+	return Result{Status: StatusPass, Detail: fmt.Sprintf(format, args...)}
+}
+
+// Skip returns a result for a check that does not apply
+func Skip(format string, args ...any) Result {
+	return Result{Status: StatusSkip, Detail: fmt.Sprintf(format, args...)}
+}
+
+// Warn returns a warning with a hint
+func Warn(hint, format string, args ...any) Result {
+	return Result{Status: StatusWarn, Detail: fmt.Sprintf(format, args...), Hint: hint}
+}
+
+// Fail returns a failure with a hint
+func Fail(hint, format string, args ...any) Result {
+	return Result{Status: StatusFail, Detail: fmt.Sprintf(format, args...), Hint: hint}
+}
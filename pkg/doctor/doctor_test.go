@@ -0,0 +1,205 @@
+package doctor
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chef/chef"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/source"
+)
+
+// newSupermarket serves nginx 1.0.0, which depends on apt, and apt 2.0.0.
+// Requests without the API key are rejected when key is set.
+func newSupermarket(t *testing.T, key string) *httptest.Server {
+	t.Helper()
+	dependencies := map[string]string{"nginx": `{"apt": ">= 1.0"}`, "apt": `{}`}
+	versions := map[string]string{"nginx": "1.0.0", "apt": "2.0.0"}
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if key != "" && r.Header.Get("X-Ops-Userid") != key {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/v1/cookbooks/"), "/")
+		version, ok := versions[parts[0]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		api := server.URL + "/api/v1/cookbooks/" + parts[0]
+		if len(parts) == 1 {
+			fmt.Fprintf(w, `{"name": %q, "latest_version": "%s/versions/%s", "versions": ["%s/versions/%s"]}`, parts[0], api, version, api, version)
+			return
+		}
+		fmt.Fprintf(w, `{"version": %q, "dependencies": %s, "file": "%s/download"}`, version, dependencies[parts[0]], api)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func run(t *testing.T, check Check) Result {
+	t.Helper()
+	return Run(context.Background(), []Check{check}, 5*time.Second)[0]
+}
+
+func TestKeyFile(t *testing.T) {
+	dir := t.TempDir()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := filepath.Join(dir, "client.pem")
+	os.WriteFile(valid, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}), 0600)
+	invalid := filepath.Join(dir, "client.pub")
+	os.WriteFile(invalid, []byte("ssh-ed25519 AAAA"), 0644)
+
+	tests := []struct {
+		path string
+		want Status
+	}{
+		{valid, StatusPass},
+		{invalid, StatusFail},
+		{filepath.Join(dir, "missing.pem"), StatusFail},
+		{"", StatusFail},
+	}
+	for _, tt := range tests {
+		result := run(t, KeyFile("key", tt.path, "chef.client_key"))
+		if result.Status != tt.want {
+			t.Errorf("KeyFile(%q) = %+v, want %s", tt.path, result, tt.want)
+		}
+		if result.Status == StatusFail && !strings.Contains(result.Hint, "chef.client_key") {
+			t.Errorf("KeyFile(%q) hint = %q, want it to name the setting", tt.path, result.Hint)
+		}
+	}
+}
+
+func TestWritable(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "cache")
+	if result := run(t, Writable("cache", dir, "fix it")); result.Status != StatusPass {
+		t.Errorf("Writable() = %+v", result)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("Writable() left %d files behind", len(entries))
+	}
+
+	// A file where the directory should be
+	file := filepath.Join(t.TempDir(), "file")
+	os.WriteFile(file, nil, 0644)
+	if result := run(t, Writable("cache", file, "fix it")); result.Status != StatusFail || result.Hint != "fix it" {
+		t.Errorf("Writable(file) = %+v, want a failure", result)
+	}
+}
+
+func TestReachable(t *testing.T) {
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+
+	if result := run(t, Reachable("server", server.URL, server.Client())); result.Status != StatusPass {
+		t.Errorf("Reachable() = %+v", result)
+	}
+	status = http.StatusServiceUnavailable
+	if result := run(t, Reachable("server", server.URL, server.Client())); result.Status != StatusWarn {
+		t.Errorf("Reachable() of a failing server = %+v, want a warning", result)
+	}
+	server.Close()
+	if result := run(t, Reachable("server", server.URL, server.Client())); result.Status != StatusFail || result.Hint == "" {
+		t.Errorf("Reachable() of a closed server = %+v, want a failure with a hint", result)
+	}
+}
+
+func TestSource(t *testing.T) {
+	server := newSupermarket(t, "")
+	src := source.NewSupermarketSource(server.URL)
+	if result := run(t, Source(src, "nginx")); result.Status != StatusPass {
+		t.Errorf("Source() = %+v", result)
+	}
+	if result := run(t, Source(src, "missing")); result.Status != StatusPass {
+		t.Errorf("Source() without the cookbook = %+v, want a pass", result)
+	}
+
+	// The source requires an API key it was not given
+	src = source.NewSupermarketSource(newSupermarket(t, "secret").URL)
+	result := run(t, Source(src, "nginx"))
+	if result.Status != StatusFail || !strings.Contains(result.Detail, "credentials rejected") || !strings.Contains(result.Hint, "api_keys") {
+		t.Errorf("Source() without the API key = %+v", result)
+	}
+}
+
+func TestAuthFailure(t *testing.T) {
+	rejected := &source.ErrSourceUnavailable{Err: &chef.ErrorResponse{Response: &http.Response{StatusCode: http.StatusUnauthorized}}}
+	if !authFailure(rejected) {
+		t.Error("authFailure() = false for a Chef Server 401")
+	}
+	failed := &source.ErrSourceUnavailable{Err: &chef.ErrorResponse{Response: &http.Response{StatusCode: http.StatusBadGateway}}}
+	if authFailure(failed) {
+		t.Error("authFailure() = true for a Chef Server 502")
+	}
+}
+
+func TestResolve(t *testing.T) {
+	sources := []source.CookbookSource{source.NewSupermarketSource(newSupermarket(t, "").URL)}
+	result := run(t, Resolve(sources, "nginx"))
+	if result.Status != StatusPass || result.Detail != "nginx 1.0.0 with 1 dependencies" {
+		t.Errorf("Resolve() = %+v", result)
+	}
+	if result := run(t, Resolve(sources, "missing")); result.Status != StatusFail || result.Hint == "" {
+		t.Errorf("Resolve(missing) = %+v, want a failure with a hint", result)
+	}
+}
+
+func TestSSHAuth(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("SSH_AUTH_SOCK", "")
+	if result := run(t, SSHAuth()); result.Status != StatusFail {
+		t.Errorf("SSHAuth() without an agent or key = %+v, want a failure", result)
+	}
+
+	os.MkdirAll(filepath.Join(home, ".ssh"), 0700)
+	os.WriteFile(filepath.Join(home, ".ssh", "id_ed25519"), []byte("key"), 0600)
+	if result := run(t, SSHAuth()); result.Status != StatusPass {
+		t.Errorf("SSHAuth() with a key file = %+v", result)
+	}
+
+	// An agent that cannot be reached is not silently passed over
+	t.Setenv("SSH_AUTH_SOCK", filepath.Join(home, "agent.sock"))
+	if result := run(t, SSHAuth()); result.Status != StatusFail {
+		t.Errorf("SSHAuth() with a dead agent = %+v, want a failure", result)
+	}
+}
+
+func TestRun(t *testing.T) {
+	checks := []Check{
+		{Name: "slow", Run: func(ctx context.Context) Result {
+			<-ctx.Done()
+			return Fail("wait less", "%v", ctx.Err())
+		}},
+		{Name: "warn", Run: func(ctx context.Context) Result { return Warn("hint", "odd") }},
+		{Name: "skip", Run: func(ctx context.Context) Result { return Skip("n/a") }},
+	}
+	results := Run(context.Background(), checks, 10*time.Millisecond)
+	if len(results) != 3 || results[0].Name != "slow" || results[2].Name != "skip" {
+		t.Fatalf("Run() = %+v", results)
+	}
+	if !strings.HasPrefix(results[0].Detail, "timed out after 10ms") {
+		t.Errorf("timed out check detail = %q", results[0].Detail)
+	}
+	if failed := Failed(results); failed != 1 {
+		t.Errorf("Failed() = %d, want 1: warnings and skips do not fail", failed)
+	}
+}
@@ -0,0 +1,105 @@
+package errors
+
+import (
+	stderrors "errors"
+	"io/fs"
+	"net"
+	"os"
+)
+
+// Typed is implemented by errors that belong to a category
+type Typed interface {
+	error
+	ErrorType() ErrorType
+}
+
+// exitCodes are the process exit codes of each error type. 1 is left for
+// errors of no type, and 2 to 5 for the findings of `berks audit`.
+var exitCodes = map[ErrorType]int{
+	ErrorTypeParsing:        10,
+	ErrorTypeResolution:     11,
+	ErrorTypeNetwork:        12,
+	ErrorTypeAuthentication: 13,
+	ErrorTypeFileSystem:     14,
+	ErrorTypeIntegrity:      15,
+	ErrorTypeConfiguration:  16,
+	ErrorTypeValidation:     17,
+}
+
+// ExitCode returns the process exit code of errors of this type, or 1 if
+// the type has none
+func (t ErrorType) ExitCode() int {
+	if code, ok := exitCodes[t]; ok {
+		return code
+	}
+	return 1
+}
+
+// typedError puts an error in a category without changing its message
+type typedError struct {
+	err error
+	t   ErrorType
+}
+
+func (e *typedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *typedError) Unwrap() error {
+	return e.err
+}
+
+// ErrorType returns the category of the error
+func (e *typedError) ErrorType() ErrorType {
+	return e.t
+}
+
+// Is matches a BerkshelfError target of the same type, like BerkshelfError
+func (e *typedError) Is(target error) bool {
+	if t, ok := target.(*BerkshelfError); ok {
+		return e.t == t.Type
+	}
+	return false
+}
+
+// WithType returns err in category t, unless it already belongs to one:
+// the category found deeper in the chain is the more specific. A nil err
+// stays nil.
+func WithType(err error, t ErrorType) error {
+	if err == nil || TypeOf(err) != "" {
+		return err
+	}
+	return &typedError{err: err, t: t}
+}
+
+// TypeOf returns the category of err: that of the first Typed error in its
+// chain, or else filesystem for path errors and network for net errors. It
+// returns "" if err has no category.
+func TypeOf(err error) ErrorType {
+	if err == nil {
+		return ""
+	}
+	var typed Typed
+	if stderrors.As(err, &typed) {
+		return typed.ErrorType()
+	}
+	var pathErr *fs.PathError
+	var linkErr *os.LinkError
+	if stderrors.As(err, &pathErr) || stderrors.As(err, &linkErr) {
+		return ErrorTypeFileSystem
+	}
+	var netErr net.Error
+	if stderrors.As(err, &netErr) {
+		return ErrorTypeNetwork
+	}
+	return ""
+}
+
+// ExitCode returns the process exit code for err: 0 if it is nil, the code
+// of its category, or 1
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	return TypeOf(err).ExitCode()
+}
@@ -0,0 +1,107 @@
+package errors
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"testing"
+)
+
+func TestTypeOf(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want ErrorType
+	}{
+		{"nil", nil, ""},
+		{"plain", errors.New("boom"), ""},
+		{"berkshelf error", NewParsingError("bad", nil), ErrorTypeParsing},
+		{"wrapped", fmt.Errorf("install: %w", NewNetworkError("down", nil)), ErrorTypeNetwork},
+		{"with type", WithType(errors.New("mismatch"), ErrorTypeIntegrity), ErrorTypeIntegrity},
+		{"deeper type wins", WithType(NewAuthenticationError("denied", nil), ErrorTypeResolution), ErrorTypeAuthentication},
+		{"joined", errors.Join(errors.New("a"), NewFileSystemError("b", nil)), ErrorTypeFileSystem},
+		{"path error", fmt.Errorf("reading: %w", &fs.PathError{Op: "open", Path: "x", Err: fs.ErrNotExist}), ErrorTypeFileSystem},
+		{"net error", &net.OpError{Op: "dial", Err: errors.New("refused")}, ErrorTypeNetwork},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TypeOf(tt.err); got != tt.want {
+				t.Errorf("TypeOf() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithType(t *testing.T) {
+	if WithType(nil, ErrorTypeNetwork) != nil {
+		t.Error("WithType(nil) should be nil")
+	}
+
+	cause := errors.New("checksum mismatch")
+	err := WithType(cause, ErrorTypeIntegrity)
+	if err.Error() != cause.Error() {
+		t.Errorf("Error() = %q, want the message unchanged", err.Error())
+	}
+	if !errors.Is(err, cause) {
+		t.Error("errors.Is should find the cause")
+	}
+	if !errors.Is(fmt.Errorf("verify: %w", err), &BerkshelfError{Type: ErrorTypeIntegrity}) {
+		t.Error("errors.Is should match a BerkshelfError of the same type")
+	}
+	if errors.Is(err, &BerkshelfError{Type: ErrorTypeNetwork}) {
+		t.Error("errors.Is should not match a BerkshelfError of another type")
+	}
+	var typed Typed
+	if !errors.As(err, &typed) || typed.ErrorType() != ErrorTypeIntegrity {
+		t.Error("errors.As should find the Typed error")
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	if got := ExitCode(nil); got != 0 {
+		t.Errorf("ExitCode(nil) = %d, want 0", got)
+	}
+	if got := ExitCode(errors.New("boom")); got != 1 {
+		t.Errorf("ExitCode(untyped) = %d, want 1", got)
+	}
+
+	seen := map[int]ErrorType{}
+	for _, typ := range []ErrorType{
+		ErrorTypeParsing, ErrorTypeResolution, ErrorTypeNetwork, ErrorTypeAuthentication,
+		ErrorTypeFileSystem, ErrorTypeIntegrity, ErrorTypeConfiguration, ErrorTypeValidation,
+	} {
+		code := ExitCode(WithType(errors.New("x"), typ))
+		if code <= 5 {
+			t.Errorf("%s exit code %d collides with generic or audit codes", typ, code)
+		}
+		if other, ok := seen[code]; ok {
+			t.Errorf("%s and %s share exit code %d", typ, other, code)
+		}
+		seen[code] = typ
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	err := fmt.Errorf("install failed: %w", NewNetworkError("supermarket unreachable", errors.New("connection refused")))
+	summary := Summarize(err)
+
+	if summary.Type != ErrorTypeNetwork || summary.ExitCode != 12 {
+		t.Errorf("Summarize() = %+v, want network with exit code 12", summary)
+	}
+	if summary.Message != err.Error() {
+		t.Errorf("Message = %q, want %q", summary.Message, err.Error())
+	}
+	if summary.Cause != "connection refused" {
+		t.Errorf("Cause = %q, want the innermost error", summary.Cause)
+	}
+	if len(summary.Suggestions) == 0 {
+		t.Error("Suggestions should come from the BerkshelfError")
+	}
+
+	plain := Summarize(errors.New("boom"))
+	if plain.Type != "" || plain.ExitCode != 1 || plain.Cause != "" {
+		t.Errorf("Summarize(plain) = %+v", plain)
+	}
+}
@@ -16,6 +16,7 @@ const (
 	ErrorTypeFileSystem     ErrorType = "filesystem"
 	ErrorTypeAuthentication ErrorType = "authentication"
 	ErrorTypeConfiguration  ErrorType = "configuration"
+	ErrorTypeIntegrity      ErrorType = "integrity"
 )
 
 // BerkshelfError represents a structured error with context
@@ -57,6 +58,11 @@ func (e *BerkshelfError) Unwrap() error {
 	return e.Cause
 }
 
+// ErrorType returns the category of the error
+func (e *BerkshelfError) ErrorType() ErrorType {
+	return e.Type
+}
+
 // Is checks if the error matches a target error type
 func (e *BerkshelfError) Is(target error) bool {
 	if t, ok := target.(*BerkshelfError); ok {
@@ -169,6 +175,20 @@ func NewConfigurationError(message string, cause error) *BerkshelfError {
 	}
 }
 
+// NewIntegrityError creates an integrity error
+func NewIntegrityError(message string, cause error) *BerkshelfError {
+	return &BerkshelfError{
+		Type:    ErrorTypeIntegrity,
+		Message: message,
+		Cause:   cause,
+		Context: make(map[string]interface{}),
+		Suggestions: []string{
+			"Clear the cookbook from the cache and install again",
+			"Check whether the cookbook was republished at the same version",
+		},
+	}
+}
+
 // WithContext adds context to an error
 func (e *BerkshelfError) WithContext(key string, value interface{}) *BerkshelfError {
 	e.Context[key] = value
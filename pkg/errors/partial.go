@@ -0,0 +1,103 @@
+package errors
+
+import (
+	stderrors "errors"
+	"slices"
+	"strings"
+)
+
+// CookbookError is an error about one cookbook
+type CookbookError struct {
+	Cookbook string
+	Err      error
+}
+
+// Error returns the message of Err, which names the cookbook already
+func (e *CookbookError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *CookbookError) Unwrap() error {
+	return e.Err
+}
+
+// Cookbook statuses in a PartialFailure
+const (
+	StatusOK      = "ok"
+	StatusFailed  = "failed"
+	StatusSkipped = "skipped"
+)
+
+// CookbookStatus is the outcome of an operation for one cookbook
+type CookbookStatus struct {
+	Cookbook string `json:"cookbook"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+}
+
+// PartialFailure reports an operation over many cookbooks that failed for
+// some of them. Run to completion (keep-going) it lists every failure; when
+// it stopped at the first (fail-fast), the cookbooks it did not reach are
+// Skipped.
+type PartialFailure struct {
+	// Message summarizes the failure
+	Message string
+	// Type is the category of the failure when its errors do not share one
+	Type ErrorType
+	// Errors are the failures, as CookbookErrors for those of one cookbook
+	Errors []error
+	// Succeeded are the cookbooks the operation completed
+	Succeeded []string
+	// Skipped are the cookbooks the operation did not attempt
+	Skipped []string
+}
+
+// Error implements the error interface
+func (e *PartialFailure) Error() string {
+	return e.Message
+}
+
+// Unwrap returns the failures
+func (e *PartialFailure) Unwrap() []error {
+	return e.Errors
+}
+
+// ErrorType returns the category all the failures share, e.g. network when
+// no source could be reached, or else Type
+func (e *PartialFailure) ErrorType() ErrorType {
+	var shared ErrorType
+	for _, err := range e.Errors {
+		t := TypeOf(err)
+		if t == "" || shared != "" && t != shared {
+			return e.Type
+		}
+		shared = t
+	}
+	if shared == "" {
+		return e.Type
+	}
+	return shared
+}
+
+// Statuses returns the outcome for each cookbook, by name. Failures not
+// about a single cookbook are left out.
+func (e *PartialFailure) Statuses() []CookbookStatus {
+	var statuses []CookbookStatus
+	for _, name := range e.Succeeded {
+		statuses = append(statuses, CookbookStatus{Cookbook: name, Status: StatusOK})
+	}
+	for _, err := range e.Errors {
+		var cookbookErr *CookbookError
+		if stderrors.As(err, &cookbookErr) {
+			statuses = append(statuses, CookbookStatus{Cookbook: cookbookErr.Cookbook, Status: StatusFailed, Error: err.Error()})
+		}
+	}
+	for _, name := range e.Skipped {
+		statuses = append(statuses, CookbookStatus{Cookbook: name, Status: StatusSkipped})
+	}
+	slices.SortStableFunc(statuses, func(a, b CookbookStatus) int {
+		return strings.Compare(a.Cookbook, b.Cookbook)
+	})
+	return statuses
+}
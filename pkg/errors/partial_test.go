@@ -0,0 +1,58 @@
+package errors
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestPartialFailure_ErrorType(t *testing.T) {
+	network := func(name string) error {
+		return &CookbookError{Cookbook: name, Err: NewNetworkError("unreachable", nil)}
+	}
+	tests := []struct {
+		name   string
+		errors []error
+		want   ErrorType
+	}{
+		{"shared", []error{network("a"), network("b")}, ErrorTypeNetwork},
+		{"mixed", []error{network("a"), WithType(errors.New("mismatch"), ErrorTypeIntegrity)}, ErrorTypeResolution},
+		{"untyped", []error{network("a"), errors.New("conflict")}, ErrorTypeResolution},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := fmt.Errorf("install: %w", &PartialFailure{Message: "failed", Type: ErrorTypeResolution, Errors: tt.errors})
+			if got := TypeOf(err); got != tt.want {
+				t.Errorf("TypeOf() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPartialFailure_Summary(t *testing.T) {
+	failure := &PartialFailure{
+		Message:   "failed to vendor 1 of 3 cookbook(s)",
+		Errors:    []error{&CookbookError{Cookbook: "nginx", Err: errors.New("failed to vendor nginx: timeout")}},
+		Succeeded: []string{"apt"},
+		Skipped:   []string{"yum"},
+	}
+
+	summary := Summarize(failure)
+	if summary.Message != failure.Message {
+		t.Errorf("Message = %q", summary.Message)
+	}
+	want := []CookbookStatus{
+		{Cookbook: "apt", Status: StatusOK},
+		{Cookbook: "nginx", Status: StatusFailed, Error: "failed to vendor nginx: timeout"},
+		{Cookbook: "yum", Status: StatusSkipped},
+	}
+	if len(summary.Cookbooks) != len(want) {
+		t.Fatalf("Cookbooks = %+v, want %+v", summary.Cookbooks, want)
+	}
+	for i := range want {
+		if summary.Cookbooks[i] != want[i] {
+			t.Errorf("Cookbooks[%d] = %+v, want %+v", i, summary.Cookbooks[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package errors
+
+import (
+	stderrors "errors"
+)
+
+// Summary is a machine-readable description of an error, for tools that
+// wrap the CLI
+type Summary struct {
+	Type     ErrorType `json:"type,omitempty"`
+	ExitCode int       `json:"exit_code"`
+	Message  string    `json:"message"`
+	// Cause is the message of the innermost error, when err wraps one
+	Cause       string   `json:"cause,omitempty"`
+	Suggestions []string `json:"suggestions,omitempty"`
+	// Cookbooks are the per-cookbook outcomes of a PartialFailure
+	Cookbooks []CookbookStatus `json:"cookbooks,omitempty"`
+}
+
+// Summarize describes err, which must not be nil
+func Summarize(err error) Summary {
+	summary := Summary{
+		Type:     TypeOf(err),
+		ExitCode: ExitCode(err),
+		Message:  err.Error(),
+	}
+
+	root := err
+	for {
+		next := stderrors.Unwrap(root)
+		if next == nil {
+			break
+		}
+		root = next
+	}
+	if root != err {
+		summary.Cause = root.Error()
+	}
+
+	var berr *BerkshelfError
+	if stderrors.As(err, &berr) {
+		summary.Suggestions = berr.Suggestions
+	}
+	var partial *PartialFailure
+	if stderrors.As(err, &partial) {
+		summary.Cookbooks = partial.Statuses()
+	}
+	return summary
+}
@@ -0,0 +1,65 @@
+package errors
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"time"
+)
+
+// Phases with their own timeout
+const (
+	PhaseResolve  = "resolve"
+	PhaseDownload = "download"
+	PhaseUpload   = "upload"
+)
+
+// PhaseTimeoutError reports that a phase ran out of its configured time
+type PhaseTimeoutError struct {
+	Phase   string
+	Timeout time.Duration
+	// Err is the error the phase failed with
+	Err error
+}
+
+// Error implements the error interface
+func (e *PhaseTimeoutError) Error() string {
+	msg := fmt.Sprintf("%s timed out after %s (%s_timeout)", e.Phase, e.Timeout, e.Phase)
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Unwrap returns the error the phase failed with
+func (e *PhaseTimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports a phase timeout as context.DeadlineExceeded
+func (e *PhaseTimeoutError) Is(target error) bool {
+	return target == context.DeadlineExceeded
+}
+
+// WithPhaseTimeout returns a context that expires after timeout, or is only
+// cancellable if timeout is not positive. Pass the phase's error to
+// PhaseError to report the timeout.
+func WithPhaseTimeout(ctx context.Context, phase string, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeoutCause(ctx, timeout, &PhaseTimeoutError{Phase: phase, Timeout: timeout})
+}
+
+// PhaseError returns err as a PhaseTimeoutError if the phase timeout of ctx
+// expired, and err unchanged otherwise
+func PhaseError(ctx context.Context, err error) error {
+	if err == nil || ctx.Err() == nil {
+		return err
+	}
+	var timeout *PhaseTimeoutError
+	if stderrors.As(err, &timeout) || !stderrors.As(context.Cause(ctx), &timeout) {
+		return err
+	}
+	return &PhaseTimeoutError{Phase: timeout.Phase, Timeout: timeout.Timeout, Err: err}
+}
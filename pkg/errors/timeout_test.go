@@ -0,0 +1,64 @@
+package errors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestPhaseError(t *testing.T) {
+	ctx, cancel := WithPhaseTimeout(context.Background(), PhaseResolve, time.Millisecond)
+	defer cancel()
+	<-ctx.Done()
+
+	err := PhaseError(ctx, fmt.Errorf("fetching nginx: %w", ctx.Err()))
+	var timeout *PhaseTimeoutError
+	if !errors.As(err, &timeout) {
+		t.Fatalf("PhaseError() = %v, want a PhaseTimeoutError", err)
+	}
+	if timeout.Phase != PhaseResolve || timeout.Timeout != time.Millisecond {
+		t.Errorf("PhaseTimeoutError = %+v", timeout)
+	}
+	if want := "resolve timed out after 1ms (resolve_timeout): fetching nginx: context deadline exceeded"; err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err, want)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("PhaseTimeoutError is not context.DeadlineExceeded")
+	}
+
+	// A phase error is not wrapped twice
+	if again := PhaseError(ctx, fmt.Errorf("install: %w", err)); !errors.As(again, &timeout) || timeout.Err != err.(*PhaseTimeoutError).Err {
+		t.Errorf("PhaseError() wrapped twice: %v", again)
+	}
+}
+
+func TestPhaseError_NotTimedOut(t *testing.T) {
+	failed := errors.New("cookbook not found")
+
+	ctx, cancel := WithPhaseTimeout(context.Background(), PhaseDownload, time.Hour)
+	defer cancel()
+	if err := PhaseError(ctx, failed); err != failed {
+		t.Errorf("PhaseError() before the timeout = %v, want %v", err, failed)
+	}
+	if err := PhaseError(ctx, nil); err != nil {
+		t.Errorf("PhaseError(nil) = %v", err)
+	}
+
+	// Cancelling the parent is not a phase timeout
+	parent, cancelParent := context.WithCancel(context.Background())
+	ctx, cancel = WithPhaseTimeout(parent, PhaseUpload, time.Hour)
+	defer cancel()
+	cancelParent()
+	if err := PhaseError(ctx, failed); err != failed {
+		t.Errorf("PhaseError() after cancellation = %v, want %v", err, failed)
+	}
+
+	// No timeout configured
+	ctx, cancel = WithPhaseTimeout(context.Background(), PhaseUpload, 0)
+	defer cancel()
+	if _, ok := ctx.Deadline(); ok {
+		t.Error("WithPhaseTimeout(0) set a deadline")
+	}
+}
@@ -0,0 +1,74 @@
+// Package events defines progress events emitted during cookbook operations.
+package events
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-json"
+)
+
+// Type identifies the kind of event
+type Type string
+
+const (
+	ResolutionStarted   Type = "resolution_started"
+	CookbookResolved    Type = "cookbook_resolved"
+	ResolutionCompleted Type = "resolution_completed"
+	DownloadStarted     Type = "download_started"
+	DownloadCompleted   Type = "download_completed"
+	DownloadFailed      Type = "download_failed"
+	Completed           Type = "completed"
+	Failed              Type = "failed"
+)
+
+// Event is a single progress event
+type Event struct {
+	Type     Type      `json:"type"`
+	Time     time.Time `json:"time"`
+	Cookbook string    `json:"cookbook,omitempty"`
+	Version  string    `json:"version,omitempty"`
+	Source   string    `json:"source,omitempty"`
+	Count    int       `json:"count,omitempty"`
+	Total    int       `json:"total,omitempty"`
+	Message  string    `json:"message,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// Handler receives events. Handlers must be safe for concurrent use.
+type Handler func(Event)
+
+// Emit sends an event to the handler, stamping the time if unset. A nil handler is a no-op.
+func (h Handler) Emit(event Event) {
+	if h == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	h(event)
+}
+
+// NDJSONWriter writes events as newline-delimited JSON
+type NDJSONWriter struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
+
+// NewNDJSONWriter creates a writer that emits one JSON object per line to w
+func NewNDJSONWriter(w io.Writer) *NDJSONWriter {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	return &NDJSONWriter{encoder: encoder}
+}
+
+// Handler returns a Handler that writes to this writer
+func (n *NDJSONWriter) Handler() Handler {
+	return func(event Event) {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		// Encoding errors are not actionable mid-stream; drop the event
+		_ = n.encoder.Encode(event)
+	}
+}
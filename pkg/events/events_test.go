@@ -0,0 +1,38 @@
+package events
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/goccy/go-json"
+)
+
+func TestNDJSONWriter(t *testing.T) {
+	var buf bytes.Buffer
+	handler := NewNDJSONWriter(&buf).Handler()
+
+	handler.Emit(Event{Type: ResolutionStarted, Total: 2})
+	handler.Emit(Event{Type: CookbookResolved, Cookbook: "nginx", Version: "2.7.6"})
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines, got %d: %q", len(lines), buf.String())
+	}
+
+	var event Event
+	if err := json.Unmarshal([]byte(lines[1]), &event); err != nil {
+		t.Fatalf("Failed to decode event: %v", err)
+	}
+	if event.Type != CookbookResolved || event.Cookbook != "nginx" || event.Version != "2.7.6" {
+		t.Errorf("Unexpected event: %+v", event)
+	}
+	if event.Time.IsZero() {
+		t.Error("Expected event time to be set")
+	}
+}
+
+func TestHandler_NilIsNoop(t *testing.T) {
+	var handler Handler
+	handler.Emit(Event{Type: Completed})
+}
@@ -0,0 +1,80 @@
+// Package fsys is the filesystem the cache, sources and vendoring read and
+// write cookbooks through. It is the operating system's filesystem unless
+// another is injected, such as an in-memory one in tests, so that they run
+// without touching disk.
+package fsys
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/afero"
+)
+
+// FS is a writable filesystem
+type FS = afero.Fs
+
+// OS returns the operating system's filesystem
+func OS() FS {
+	return afero.NewOsFs()
+}
+
+// Memory returns an empty in-memory filesystem
+func Memory() FS {
+	return afero.NewMemMapFs()
+}
+
+// Or returns fsys, or the operating system's filesystem when it is nil
+func Or(fsys FS) FS {
+	if fsys == nil {
+		return OS()
+	}
+	return fsys
+}
+
+// Sub returns the tree of fsys below dir as a read-only io/fs.FS
+func Sub(fsys FS, dir string) fs.FS {
+	return afero.NewIOFS(afero.NewBasePathFs(fsys, dir))
+}
+
+// ReadFile reads the file at path
+func ReadFile(fsys FS, path string) ([]byte, error) {
+	return afero.ReadFile(fsys, path)
+}
+
+// WriteFile writes data to the file at path, creating it with perm if needed
+func WriteFile(fsys FS, path string, data []byte, perm fs.FileMode) error {
+	return afero.WriteFile(fsys, path, data, perm)
+}
+
+// ReadDir reads the directory at path, sorted by file name
+func ReadDir(fsys FS, path string) ([]fs.FileInfo, error) {
+	return afero.ReadDir(fsys, path)
+}
+
+// Walk walks the tree rooted at root, as filepath.Walk does
+func Walk(fsys FS, root string, fn filepath.WalkFunc) error {
+	return afero.Walk(fsys, root, fn)
+}
+
+// Exists reports whether path exists
+func Exists(fsys FS, path string) bool {
+	ok, err := afero.Exists(fsys, path)
+	return ok && err == nil
+}
+
+// Symlink creates newname as a symbolic link to oldname, on filesystems
+// that support links
+func Symlink(fsys FS, oldname, newname string) error {
+	if linker, ok := fsys.(afero.Linker); ok {
+		return linker.SymlinkIfPossible(oldname, newname)
+	}
+	return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: afero.ErrNoSymlink}
+}
+
+// MkdirTemp creates a new directory in dir whose name begins with pattern,
+// as os.MkdirTemp does, and returns its path
+func MkdirTemp(fsys FS, dir, pattern string) (string, error) {
+	return afero.TempDir(fsys, dir, pattern)
+}
@@ -0,0 +1,67 @@
+package fsys
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMemory(t *testing.T) {
+	memory := Memory()
+	dir, err := MkdirTemp(memory, "/cache", "entry-")
+	if err != nil {
+		t.Fatalf("MkdirTemp() error = %v", err)
+	}
+	if err := WriteFile(memory, filepath.Join(dir, "data"), []byte("cookbook"), 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if !Exists(memory, filepath.Join(dir, "data")) {
+		t.Errorf("Exists() = false for a written file")
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("the in-memory filesystem wrote %s to disk", dir)
+	}
+
+	data, err := fs.ReadFile(Sub(memory, dir), "data")
+	if err != nil || string(data) != "cookbook" {
+		t.Errorf("fs.ReadFile(Sub()) = %q, %v, want cookbook", data, err)
+	}
+
+	var walked []string
+	err = Walk(memory, "/cache", func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			walked = append(walked, path)
+		}
+		return nil
+	})
+	if err != nil || len(walked) != 1 || walked[0] != filepath.Join(dir, "data") {
+		t.Errorf("Walk() = %v, %v, want the written file", walked, err)
+	}
+}
+
+func TestOr(t *testing.T) {
+	if Or(nil).Name() != OS().Name() {
+		t.Errorf("Or(nil) = %s, want the operating system's filesystem", Or(nil).Name())
+	}
+	memory := Memory()
+	if Or(memory) != memory {
+		t.Errorf("Or() did not return the given filesystem")
+	}
+}
+
+func TestSymlink(t *testing.T) {
+	dir := t.TempDir()
+	if err := Symlink(OS(), "target", filepath.Join(dir, "link")); err != nil {
+		t.Fatalf("Symlink() error = %v", err)
+	}
+	if target, err := os.Readlink(filepath.Join(dir, "link")); err != nil || target != "target" {
+		t.Errorf("os.Readlink() = %q, %v, want target", target, err)
+	}
+	if err := Symlink(Memory(), "target", "/link"); err == nil {
+		t.Errorf("Symlink() on a filesystem without links did not fail")
+	}
+}
@@ -0,0 +1,62 @@
+package hooks
+
+import (
+	"maps"
+	"slices"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/lockfile"
+	"github.com/bdwyertech/go-berkshelf/pkg/resolver"
+)
+
+// Requirements returns the payload cookbooks of the requirements about to
+// be resolved
+func Requirements(requirements []*resolver.Requirement) []Cookbook {
+	cookbooks := make([]Cookbook, 0, len(requirements))
+	for _, req := range requirements {
+		cookbook := Cookbook{Name: req.Name, Source: req.Source.String()}
+		if req.Constraint != nil {
+			cookbook.Constraint = req.Constraint.String()
+		}
+		cookbooks = append(cookbooks, cookbook)
+	}
+	return cookbooks
+}
+
+// Resolved returns the payload cookbooks of a resolution, sorted by name
+func Resolved(resolution *resolver.Resolution) []Cookbook {
+	if resolution == nil {
+		return nil
+	}
+	resolved := resolution.AllCookbooks()
+	cookbooks := make([]Cookbook, 0, len(resolved))
+	for _, cookbook := range resolved {
+		cookbooks = append(cookbooks, Cookbook{
+			Name:    cookbook.Name,
+			Version: cookbook.Version.String(),
+			Source:  cookbook.Source.String(),
+		})
+	}
+	return cookbooks
+}
+
+// Locked returns the payload cookbooks of a lock file for which keep
+// returns true, or all of them when keep is nil, sorted by name
+func Locked(lockFile *lockfile.LockFile, keep func(name string) bool) []Cookbook {
+	if lockFile == nil {
+		return nil
+	}
+	locked := lockFile.ListCookbooks()
+	cookbooks := make([]Cookbook, 0, len(locked))
+	for _, name := range slices.Sorted(maps.Keys(locked)) {
+		if keep != nil && !keep(name) {
+			continue
+		}
+		cookbook, sourceKey, _ := lockFile.GetCookbook(name)
+		source := sourceKey
+		if src := lockFile.Sources[sourceKey]; src != nil && src.URL != "" {
+			source = src.URL
+		}
+		cookbooks = append(cookbooks, Cookbook{Name: name, Version: cookbook.Version, Source: source})
+	}
+	return cookbooks
+}
@@ -0,0 +1,223 @@
+// Package hooks runs shell commands and registered Go plugins at lifecycle
+// events, such as before dependencies are resolved or cookbooks are
+// uploaded, so compliance checks and notifications can be added without
+// forking berks.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/logging"
+)
+
+var log = logging.For("hooks")
+
+// Event is a point in a command's lifecycle hooks run at
+type Event string
+
+const (
+	PreResolve  Event = "pre-resolve"
+	PostResolve Event = "post-resolve"
+	PreVendor   Event = "pre-vendor"
+	PostVendor  Event = "post-vendor"
+	PreUpload   Event = "pre-upload"
+)
+
+// Events are the lifecycle events, in the order a command reaches them
+var Events = []Event{PreResolve, PostResolve, PreVendor, PostVendor, PreUpload}
+
+// Hook runs a shell command or a registered plugin at an event
+type Hook struct {
+	Event Event `json:"event"`
+	// Command is run by the shell, sh -c or cmd /C on Windows, with the
+	// payload as JSON on stdin and BERKS_HOOK_EVENT set to the event
+	Command string `json:"command,omitempty"`
+	// Plugin names a plugin registered with Register
+	Plugin string `json:"plugin,omitempty"`
+	// Timeout is the seconds the hook may run; 0 is no limit
+	Timeout int `json:"timeout,omitempty"`
+	// IgnoreFailure logs a failing hook instead of stopping the command
+	IgnoreFailure bool `json:"ignore_failure,omitempty"`
+}
+
+// String names the hook by its plugin or command
+func (h Hook) String() string {
+	if h.Plugin != "" {
+		return "plugin " + h.Plugin
+	}
+	return fmt.Sprintf("%q", h.Command)
+}
+
+// Validate checks that the hook names a known event and exactly one of a
+// command and a plugin. Plugins are looked up when the hook runs, as they
+// may be registered after the config is read.
+func (h Hook) Validate() error {
+	if !slices.Contains(Events, h.Event) {
+		return fmt.Errorf("unknown event %q (expected one of %s)", h.Event, eventList())
+	}
+	if (h.Command == "") == (h.Plugin == "") {
+		return fmt.Errorf("%s hook needs either a command or a plugin", h.Event)
+	}
+	if h.Timeout < 0 {
+		return fmt.Errorf("%s hook timeout cannot be negative", h.Event)
+	}
+	return nil
+}
+
+// Payload describes the event a hook runs at. Commands receive it as JSON
+// on stdin.
+type Payload struct {
+	Event Event     `json:"event"`
+	Time  time.Time `json:"time"`
+	// Command is the berks command running, such as install
+	Command string `json:"command,omitempty"`
+	// Dir is the project directory; commands run in it
+	Dir string `json:"dir,omitempty"`
+	// Cookbooks are the requirements before resolving, the resolved or
+	// vendored cookbooks after, and the cookbooks about to be uploaded
+	Cookbooks []Cookbook `json:"cookbooks"`
+	// Path is the directory cookbooks are vendored to
+	Path string `json:"path,omitempty"`
+	// Target is where cookbooks are about to be uploaded to
+	Target string `json:"target,omitempty"`
+}
+
+// Cookbook is a cookbook an event concerns
+type Cookbook struct {
+	Name       string `json:"name"`
+	Version    string `json:"version,omitempty"`
+	Constraint string `json:"constraint,omitempty"`
+	Source     string `json:"source,omitempty"`
+}
+
+// Error is a hook that failed, stopping the command
+type Error struct {
+	Event Event
+	Hook  string
+	Err   error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s hook %s failed: %v", e.Event, e.Hook, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Runner runs the hooks configured for each event. A nil Runner runs none.
+type Runner struct {
+	hooks  []Hook
+	output io.Writer
+}
+
+// NewRunner returns a runner for hooks, which run in the order given. The
+// output of commands goes to stderr, keeping stdout for berks' own output.
+func NewRunner(hooks []Hook) *Runner {
+	return &Runner{hooks: hooks, output: os.Stderr}
+}
+
+// SetOutput sets where the output of hook commands is written
+func (r *Runner) SetOutput(w io.Writer) {
+	r.output = w
+}
+
+// Has reports whether any hook runs at event
+func (r *Runner) Has(event Event) bool {
+	return r != nil && slices.ContainsFunc(r.hooks, func(h Hook) bool { return h.Event == event })
+}
+
+// Run runs the hooks for payload.Event in order, stamping the payload time
+// if unset. The first hook to fail stops the rest and its *Error is
+// returned, unless it ignores failures, in which case it is logged.
+func (r *Runner) Run(ctx context.Context, payload Payload) error {
+	if !r.Has(payload.Event) {
+		return nil
+	}
+	if payload.Time.IsZero() {
+		payload.Time = time.Now().UTC()
+	}
+	if payload.Cookbooks == nil {
+		payload.Cookbooks = []Cookbook{}
+	}
+
+	for _, hook := range r.hooks {
+		if hook.Event != payload.Event {
+			continue
+		}
+		log.Debugf("Running %s hook %s", hook.Event, hook)
+		if err := r.run(ctx, hook, payload); err != nil {
+			if hook.IgnoreFailure {
+				log.Warnf("Ignoring failed %s hook %s: %v", hook.Event, hook, err)
+				continue
+			}
+			return &Error{Event: hook.Event, Hook: hook.String(), Err: err}
+		}
+	}
+	return nil
+}
+
+// run runs one hook within its timeout
+func (r *Runner) run(ctx context.Context, hook Hook, payload Payload) error {
+	if hook.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(hook.Timeout)*time.Second)
+		defer cancel()
+	}
+
+	var err error
+	if hook.Plugin != "" {
+		plugin, ok := Lookup(hook.Plugin)
+		if !ok {
+			return fmt.Errorf("no plugin named %q is registered", hook.Plugin)
+		}
+		err = plugin(ctx, payload)
+	} else {
+		err = r.runCommand(ctx, hook.Command, payload)
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) && hook.Timeout > 0 {
+		return fmt.Errorf("timed out after %ds", hook.Timeout)
+	}
+	return err
+}
+
+// runCommand runs command in the shell with payload as JSON on stdin
+func (r *Runner) runCommand(ctx context.Context, command string, payload Payload) error {
+	input, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("encoding payload: %w", err)
+	}
+
+	shell, flag := "sh", "-c"
+	if runtime.GOOS == "windows" {
+		shell, flag = "cmd", "/C"
+	}
+	cmd := exec.CommandContext(ctx, shell, flag, command)
+	cmd.Dir = payload.Dir
+	cmd.Env = append(os.Environ(), "BERKS_HOOK_EVENT="+string(payload.Event))
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout, cmd.Stderr = r.output, r.output
+	// Do not wait on children of a killed shell still holding the output open
+	cmd.WaitDelay = time.Second
+	return cmd.Run()
+}
+
+// eventList lists the events for error messages
+func eventList() string {
+	names := make([]string, len(Events))
+	for i, event := range Events {
+		names[i] = string(event)
+	}
+	return strings.Join(names, ", ")
+}
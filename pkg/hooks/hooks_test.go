@@ -0,0 +1,213 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	"github.com/bdwyertech/go-berkshelf/pkg/lockfile"
+	"github.com/bdwyertech/go-berkshelf/pkg/resolver"
+)
+
+// registerTest registers a plugin for the duration of the test
+func registerTest(t *testing.T, name string, plugin Plugin) {
+	t.Helper()
+	Register(name, plugin)
+	t.Cleanup(func() { unregister(name) })
+}
+
+func TestHookValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		hook Hook
+		want string
+	}{
+		{"command", Hook{Event: PreResolve, Command: "true"}, ""},
+		{"plugin", Hook{Event: PreUpload, Plugin: "compliance", Timeout: 30}, ""},
+		{"unknown event", Hook{Event: "post-upload", Command: "true"}, `unknown event "post-upload"`},
+		{"neither", Hook{Event: PreVendor}, "needs either a command or a plugin"},
+		{"both", Hook{Event: PreVendor, Command: "true", Plugin: "compliance"}, "needs either a command or a plugin"},
+		{"negative timeout", Hook{Event: PreVendor, Command: "true", Timeout: -1}, "timeout cannot be negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.hook.Validate()
+			if tt.want == "" && err != nil {
+				t.Errorf("Validate() error = %v", err)
+			} else if tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunnerPlugins(t *testing.T) {
+	var calls []string
+	registerTest(t, "record", func(ctx context.Context, payload Payload) error {
+		calls = append(calls, string(payload.Event)+":"+payload.Cookbooks[0].Name)
+		return nil
+	})
+	registerTest(t, "veto", func(ctx context.Context, payload Payload) error {
+		calls = append(calls, "veto")
+		return errors.New("nginx is not approved")
+	})
+	if !slices.Contains(Registered(), "veto") {
+		t.Errorf("Registered() = %v, want veto", Registered())
+	}
+
+	runner := NewRunner([]Hook{
+		{Event: PreResolve, Plugin: "record"},
+		{Event: PreUpload, Plugin: "record"},
+		{Event: PreUpload, Plugin: "veto"},
+		{Event: PreUpload, Plugin: "record"},
+	})
+	payload := Payload{Event: PreResolve, Cookbooks: []Cookbook{{Name: "nginx"}}}
+	if err := runner.Run(context.Background(), payload); err != nil {
+		t.Fatalf("Run(pre-resolve) error = %v", err)
+	}
+
+	// The first failure stops the remaining hooks
+	payload.Event = PreUpload
+	err := runner.Run(context.Background(), payload)
+	var hookErr *Error
+	if !errors.As(err, &hookErr) || hookErr.Event != PreUpload || hookErr.Hook != "plugin veto" {
+		t.Fatalf("Run(pre-upload) error = %v, want a veto hook error", err)
+	}
+	if want := "pre-upload hook plugin veto failed: nginx is not approved"; err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err, want)
+	}
+	if want := []string{"pre-resolve:nginx", "pre-upload:nginx", "veto"}; !slices.Equal(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+
+	// Events without hooks, and nil runners, run nothing
+	if err := runner.Run(context.Background(), Payload{Event: PostVendor}); err != nil {
+		t.Errorf("Run(post-vendor) error = %v", err)
+	}
+	var none *Runner
+	if err := none.Run(context.Background(), payload); err != nil {
+		t.Errorf("nil Runner.Run() error = %v", err)
+	}
+}
+
+func TestRunnerIgnoreFailure(t *testing.T) {
+	ran := false
+	registerTest(t, "notify", func(ctx context.Context, payload Payload) error {
+		return errors.New("webhook unreachable")
+	})
+	registerTest(t, "after", func(ctx context.Context, payload Payload) error {
+		ran = true
+		return nil
+	})
+
+	runner := NewRunner([]Hook{
+		{Event: PostResolve, Plugin: "notify", IgnoreFailure: true},
+		{Event: PostResolve, Plugin: "after"},
+	})
+	if err := runner.Run(context.Background(), Payload{Event: PostResolve}); err != nil || !ran {
+		t.Errorf("Run() error = %v, later hook ran = %v, want no error and the later hook run", err, ran)
+	}
+}
+
+func TestRunnerUnknownPlugin(t *testing.T) {
+	runner := NewRunner([]Hook{{Event: PreVendor, Plugin: "missing"}})
+	err := runner.Run(context.Background(), Payload{Event: PreVendor})
+	if err == nil || !strings.Contains(err.Error(), `no plugin named "missing"`) {
+		t.Errorf("Run() error = %v, want an unregistered plugin", err)
+	}
+}
+
+func TestRunnerTimeout(t *testing.T) {
+	registerTest(t, "slow", func(ctx context.Context, payload Payload) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	runner := NewRunner([]Hook{{Event: PreVendor, Plugin: "slow", Timeout: 1}})
+	err := runner.Run(context.Background(), Payload{Event: PreVendor})
+	if err == nil || !strings.Contains(err.Error(), "timed out after 1s") {
+		t.Errorf("Run() error = %v, want a timeout", err)
+	}
+}
+
+func TestRunnerCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the hook commands use sh")
+	}
+	dir := t.TempDir()
+
+	var output bytes.Buffer
+	runner := NewRunner([]Hook{
+		{Event: PostVendor, Command: `cat > payload.json; echo "ran $BERKS_HOOK_EVENT"`},
+		{Event: PreUpload, Command: "echo rejected >&2; exit 3"},
+	})
+	runner.SetOutput(&output)
+
+	payload := Payload{Event: PostVendor, Dir: dir, Path: "berks-cookbooks", Cookbooks: []Cookbook{{Name: "apt", Version: "7.4.0"}}}
+	if err := runner.Run(context.Background(), payload); err != nil {
+		t.Fatalf("Run(post-vendor) error = %v", err)
+	}
+	if got := output.String(); got != "ran post-vendor\n" {
+		t.Errorf("output = %q, want the hook's output", got)
+	}
+
+	// The command runs in the project directory with the payload on stdin
+	data, err := os.ReadFile(filepath.Join(dir, "payload.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Payload
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("payload %s: %v", data, err)
+	}
+	if got.Event != PostVendor || got.Path != "berks-cookbooks" || len(got.Cookbooks) != 1 || got.Cookbooks[0].Version != "7.4.0" || got.Time.IsZero() {
+		t.Errorf("payload = %+v", got)
+	}
+
+	output.Reset()
+	err = runner.Run(context.Background(), Payload{Event: PreUpload, Dir: dir})
+	if err == nil || !strings.Contains(err.Error(), "exit status 3") || output.String() != "rejected\n" {
+		t.Errorf("Run(pre-upload) error = %v, output %q, want exit status 3", err, output.String())
+	}
+}
+
+func TestPayloadCookbooks(t *testing.T) {
+	lf := lockfile.NewLockFile()
+	lf.Sources["supermarket"] = &lockfile.SourceLock{
+		URL: "https://supermarket.chef.io",
+		Cookbooks: map[string]*lockfile.CookbookLock{
+			"nginx": {Version: "12.0.0"},
+			"apt":   {Version: "7.4.0"},
+		},
+	}
+	got := Locked(lf, nil)
+	want := []Cookbook{
+		{Name: "apt", Version: "7.4.0", Source: "https://supermarket.chef.io"},
+		{Name: "nginx", Version: "12.0.0", Source: "https://supermarket.chef.io"},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Locked() = %v, want %v", got, want)
+	}
+	if got := Locked(lf, func(name string) bool { return name == "nginx" }); len(got) != 1 || got[0].Name != "nginx" {
+		t.Errorf("Locked(nginx) = %v, want only nginx", got)
+	}
+
+	requirements := []*resolver.Requirement{
+		resolver.NewRequirement("nginx", berkshelf.MustConstraint("~> 12.0")),
+		{Name: "base", Source: &berkshelf.SourceLocation{Type: "path", Path: "../base"}},
+	}
+	wantRequirements := []Cookbook{
+		{Name: "nginx", Constraint: "~> 12.0"},
+		{Name: "base", Source: "../base"},
+	}
+	if got := Requirements(requirements); !slices.Equal(got, wantRequirements) {
+		t.Errorf("Requirements() = %v, want %v", got, wantRequirements)
+	}
+}
@@ -0,0 +1,65 @@
+package hooks
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// Plugin is a hook compiled into the binary. Returning an error fails the
+// hook like a command exiting non-zero.
+type Plugin func(ctx context.Context, payload Payload) error
+
+var (
+	registryMu sync.RWMutex
+	plugins    = make(map[string]Plugin)
+)
+
+// Register makes a plugin available to hooks naming it, as in
+//
+//	{"event": "pre-upload", "plugin": "compliance"}
+//
+// Register is meant to be called from the init function of the package
+// providing the plugin, and panics if name is empty or already registered,
+// or if plugin is nil.
+func Register(name string, plugin Plugin) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	switch {
+	case name == "":
+		panic("hooks: Register with an empty name")
+	case plugin == nil:
+		panic("hooks: Register of " + name + " with a nil plugin")
+	}
+	if _, exists := plugins[name]; exists {
+		panic("hooks: Register called twice for plugin " + name)
+	}
+	plugins[name] = plugin
+}
+
+// Registered returns the names of the registered plugins, sorted
+func Registered() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(plugins))
+	for name := range plugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Lookup returns the plugin registered as name
+func Lookup(name string) (Plugin, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	plugin, ok := plugins[name]
+	return plugin, ok
+}
+
+// unregister removes a plugin, for tests
+func unregister(name string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	delete(plugins, name)
+}
@@ -16,6 +16,13 @@ type CookbookInfo struct {
 	Maintainer   string            `json:"maintainer,omitempty"`
 	License      string            `json:"license,omitempty"`
 	Source       string            `json:"source"`
+	SourceURL    string            `json:"source_url,omitempty"`
+	IssuesURL    string            `json:"issues_url,omitempty"`
+	ExternalURL  string            `json:"external_url,omitempty"`
+	Deprecated   bool              `json:"deprecated,omitempty"`
+	Platforms    map[string]string `json:"platforms,omitempty"`
+	Recipes      map[string]string `json:"recipes,omitempty"`
+	RootFiles    []string          `json:"root_files,omitempty"`
 	Dependencies map[string]string `json:"dependencies,omitempty"`
 	Versions     []string          `json:"available_versions,omitempty"`
 }
@@ -89,6 +96,19 @@ func (p *Provider) GetInfo(ctx context.Context, cookbookName string, requestedVe
 				info.Description = cookbook.Metadata.Description
 				info.Maintainer = cookbook.Metadata.Maintainer
 				info.License = cookbook.Metadata.License
+				info.SourceURL = cookbook.Metadata.Source
+				info.IssuesURL = cookbook.Metadata.Issues
+				info.ExternalURL = cookbook.Metadata.ExternalURL
+				info.Deprecated = cookbook.Metadata.Deprecated
+				info.Recipes = cookbook.Metadata.Recipes
+				info.RootFiles = cookbook.Metadata.RootFiles
+
+				if len(cookbook.Metadata.Platforms) > 0 {
+					info.Platforms = make(map[string]string)
+					for platform, constraint := range cookbook.Metadata.Platforms {
+						info.Platforms[platform] = constraint.String()
+					}
+				}
 
 				// Convert dependencies
 				if len(cookbook.Metadata.Dependencies) > 0 {
@@ -0,0 +1,170 @@
+// Package kitchen reads Test Kitchen configuration and relates its suites to
+// the Berksfile groups and cookbooks they converge.
+package kitchen
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berksfile"
+)
+
+// FileNames are the Test Kitchen configuration files, in the order Test
+// Kitchen looks for them
+var FileNames = []string{"kitchen.yml", ".kitchen.yml"}
+
+// FixturesDir is where test cookbooks conventionally live
+const FixturesDir = "test/fixtures/cookbooks"
+
+// Suite is a Test Kitchen suite
+type Suite struct {
+	Name    string   `yaml:"name"`
+	RunList []string `yaml:"run_list"`
+}
+
+// Config is the part of a Test Kitchen configuration berks reads
+type Config struct {
+	// Path is the file the configuration was read from
+	Path   string  `yaml:"-"`
+	Suites []Suite `yaml:"suites"`
+}
+
+// erbTag matches ERB tags, which are removed before the YAML is parsed
+var erbTag = regexp.MustCompile(`<%.*?%>`)
+
+// Find returns the Test Kitchen configuration file in dir. KITCHEN_YAML
+// overrides the search, as it does for Test Kitchen.
+func Find(dir string) (string, error) {
+	if path := os.Getenv("KITCHEN_YAML"); path != "" {
+		return path, nil
+	}
+	for _, name := range FileNames {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("no %s found in %s", strings.Join(FileNames, " or "), dir)
+}
+
+// Load reads the suites from a Test Kitchen configuration file. ERB tags are
+// dropped rather than evaluated, so suites generated by ERB loops are not seen.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &Config{Path: path}
+	if err := yaml.Unmarshal(erbTag.ReplaceAll(data, nil), config); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// RunListCookbooks returns the cookbooks named by the suite's recipes, sorted.
+// Roles are skipped, as their run lists are not available.
+func (s Suite) RunListCookbooks() []string {
+	var cookbooks []string
+	for _, item := range s.RunList {
+		item = strings.TrimSpace(item)
+		if strings.HasPrefix(item, "role[") {
+			continue
+		}
+		if inner, ok := strings.CutPrefix(item, "recipe["); ok {
+			item = strings.TrimSuffix(inner, "]")
+		}
+		name, _, _ := strings.Cut(item, "::")
+		name, _, _ = strings.Cut(name, "@")
+		if name != "" && !slices.Contains(cookbooks, name) {
+			cookbooks = append(cookbooks, name)
+		}
+	}
+	sort.Strings(cookbooks)
+	return cookbooks
+}
+
+// Mapping relates a suite to a Berksfile
+type Mapping struct {
+	Suite string `json:"suite"`
+	// Group is the Berksfile group named after the suite, if there is one
+	Group string `json:"group,omitempty"`
+	// Cookbooks are the group's cookbooks and the run list's, sorted
+	Cookbooks []string `json:"cookbooks"`
+	// Undeclared are run list cookbooks the Berksfile does not declare
+	Undeclared []string `json:"undeclared,omitempty"`
+}
+
+// Map relates each suite to the Berksfile. A suite maps to the group of the
+// same name, if any, plus the cookbooks in its run list. metadataName is the
+// cookbook the Berksfile's metadata directive declares, if any.
+func Map(config *Config, bf *berksfile.Berksfile, metadataName string) []Mapping {
+	declared := make(map[string]bool, len(bf.Cookbooks)+1)
+	for _, cookbook := range bf.Cookbooks {
+		declared[cookbook.Name] = true
+	}
+	if metadataName != "" {
+		declared[metadataName] = true
+	}
+
+	mappings := make([]Mapping, 0, len(config.Suites))
+	for _, suite := range config.Suites {
+		mapping := Mapping{Suite: suite.Name}
+		if group, ok := bf.Groups[suite.Name]; ok {
+			mapping.Group = suite.Name
+			for _, cookbook := range group {
+				mapping.Cookbooks = append(mapping.Cookbooks, cookbook.Name)
+			}
+		}
+		for _, name := range suite.RunListCookbooks() {
+			if !slices.Contains(mapping.Cookbooks, name) {
+				mapping.Cookbooks = append(mapping.Cookbooks, name)
+			}
+			if !declared[name] {
+				mapping.Undeclared = append(mapping.Undeclared, name)
+			}
+		}
+		sort.Strings(mapping.Cookbooks)
+		mappings = append(mappings, mapping)
+	}
+	return mappings
+}
+
+// Stub returns a Berksfile group for the suite declaring its undeclared
+// cookbooks, or "" if there are none. Cookbooks found below FixturesDir in
+// dir are declared with their path.
+func (m Mapping) Stub(dir string) string {
+	if len(m.Undeclared) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "group %s do\n", symbol(m.Suite))
+	for _, name := range m.Undeclared {
+		fixture := filepath.ToSlash(filepath.Join(FixturesDir, name))
+		if info, err := os.Stat(filepath.Join(dir, fixture)); err == nil && info.IsDir() {
+			fmt.Fprintf(&b, "  cookbook %q, path: %q\n", name, "./"+fixture)
+			continue
+		}
+		fmt.Fprintf(&b, "  cookbook %q\n", name)
+	}
+	b.WriteString("end\n")
+	return b.String()
+}
+
+var identifier = regexp.MustCompile(`^[a-z_][a-zA-Z0-9_]*$`)
+
+// symbol renders a group name as a Ruby symbol
+func symbol(name string) string {
+	if identifier.MatchString(name) {
+		return ":" + name
+	}
+	return fmt.Sprintf(":%q", name)
+}
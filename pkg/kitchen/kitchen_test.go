@@ -0,0 +1,109 @@
+package kitchen
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berksfile"
+)
+
+const kitchenYAML = `driver:
+  name: dokken
+  chef_version: <%= ENV['CHEF_VERSION'] || 'latest' %>
+<% if ENV['CI'] %>
+<% end %>
+suites:
+  - name: default
+    run_list:
+      - recipe[apt::default]
+      - recipe[nginx]
+  - name: integration
+    run_list:
+      - recipe[apt_test::default]
+      - role[base]
+      - nginx::repo@2.0.0
+  - name: smoke-test
+    run_list: ["apt::upgrade", "sysctl"]
+`
+
+func writeKitchen(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ".kitchen.yml"), []byte(kitchenYAML), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, FixturesDir, "apt_test"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestLoad(t *testing.T) {
+	t.Setenv("KITCHEN_YAML", "")
+	dir := writeKitchen(t)
+	path, err := Find(dir)
+	if err != nil || filepath.Base(path) != ".kitchen.yml" {
+		t.Fatalf("Find() = %q, %v", path, err)
+	}
+
+	config, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(config.Suites) != 3 {
+		t.Fatalf("Load() suites = %+v", config.Suites)
+	}
+	if got := config.Suites[1].RunListCookbooks(); !reflect.DeepEqual(got, []string{"apt_test", "nginx"}) {
+		t.Errorf("RunListCookbooks() = %v", got)
+	}
+
+	if _, err := Find(t.TempDir()); err == nil {
+		t.Error("Find() found a kitchen file in an empty directory")
+	}
+}
+
+func TestMap(t *testing.T) {
+	dir := writeKitchen(t)
+	config, err := Load(filepath.Join(dir, ".kitchen.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bf, err := berksfile.Parse(`
+source "https://supermarket.chef.io"
+metadata
+cookbook "nginx"
+group :integration do
+  cookbook "docker"
+end
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mappings := Map(config, bf, "apt")
+	want := []Mapping{
+		{Suite: "default", Cookbooks: []string{"apt", "nginx"}},
+		{Suite: "integration", Group: "integration", Cookbooks: []string{"apt_test", "docker", "nginx"}, Undeclared: []string{"apt_test"}},
+		{Suite: "smoke-test", Cookbooks: []string{"apt", "sysctl"}, Undeclared: []string{"sysctl"}},
+	}
+	if !reflect.DeepEqual(mappings, want) {
+		t.Errorf("Map() =\n%+v\nwant\n%+v", mappings, want)
+	}
+
+	if stub := mappings[0].Stub(dir); stub != "" {
+		t.Errorf("Stub() for a fully declared suite = %q", stub)
+	}
+	if stub := mappings[1].Stub(dir); !strings.Contains(stub, `group :integration do`) ||
+		!strings.Contains(stub, `cookbook "apt_test", path: "./test/fixtures/cookbooks/apt_test"`) {
+		t.Errorf("Stub() = %q", stub)
+	}
+	if stub := mappings[2].Stub(dir); stub != "group :\"smoke-test\" do\n  cookbook \"sysctl\"\nend\n" {
+		t.Errorf("Stub() = %q", stub)
+	}
+	if _, err := berksfile.Parse(mappings[1].Stub(dir) + mappings[2].Stub(dir)); err != nil {
+		t.Errorf("stubs do not parse as a Berksfile: %v", err)
+	}
+}
@@ -0,0 +1,78 @@
+package kitchen
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+// Where `berks kitchen-prepare` writes, relative to the kitchen root
+const (
+	// ManifestFile lists what was prepared for each suite
+	ManifestFile = ".kitchen/berkshelf.json"
+	// CookbooksDir holds a directory per suite with its vendored cookbooks,
+	// one directory per cookbook as Test Kitchen's berkshelf provisioner
+	// lays them out in the sandbox
+	CookbooksDir = ".kitchen/berkshelf"
+)
+
+// Manifest records the cookbooks prepared for each suite, so that a kitchen
+// configuration or plugin can point a suite's provisioner at them without
+// the berkshelf gem
+type Manifest struct {
+	GeneratedAt time.Time `json:"generated_at"`
+	// LockFile is the lock file the cookbooks were vendored from
+	LockFile string                     `json:"lockfile"`
+	Suites   map[string]*SuiteCookbooks `json:"suites"`
+}
+
+// SuiteCookbooks are the cookbooks prepared for one suite
+type SuiteCookbooks struct {
+	// Path is the suite's cookbook directory, relative to the kitchen root
+	Path      string                      `json:"path"`
+	Cookbooks map[string]PreparedCookbook `json:"cookbooks"`
+}
+
+// PreparedCookbook is a cookbook vendored for a suite
+type PreparedCookbook struct {
+	Version string `json:"version"`
+	// Source is the lock file source the cookbook came from
+	Source string `json:"source,omitempty"`
+}
+
+// SuiteDir returns the cookbook directory of a suite, relative to the kitchen
+// root and slash separated, as it is recorded in the manifest
+func SuiteDir(suite string) string {
+	return path.Join(CookbooksDir, suite)
+}
+
+// LoadManifest reads a manifest written by Save
+func LoadManifest(file string) (*Manifest, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+	return &manifest, nil
+}
+
+// Save writes the manifest to file, creating its directory
+func (m *Manifest) Save(file string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return fmt.Errorf("failed to write %s: %w", file, err)
+	}
+	if err := os.WriteFile(file, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", file, err)
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package kitchen
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestManifest(t *testing.T) {
+	manifest := &Manifest{
+		GeneratedAt: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		LockFile:    "Berksfile.go.lock",
+		Suites: map[string]*SuiteCookbooks{
+			"default": {
+				Path:      SuiteDir("default"),
+				Cookbooks: map[string]PreparedCookbook{"nginx": {Version: "12.0.1", Source: "https://supermarket.chef.io"}},
+			},
+		},
+	}
+	if manifest.Suites["default"].Path != ".kitchen/berkshelf/default" {
+		t.Errorf("SuiteDir() = %s", manifest.Suites["default"].Path)
+	}
+
+	// The .kitchen directory is created if needed
+	path := filepath.Join(t.TempDir(), ManifestFile)
+	if err := manifest.Save(path); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	loaded, err := LoadManifest(path)
+	if err != nil {
+		t.Fatalf("LoadManifest() error = %v", err)
+	}
+	if !reflect.DeepEqual(loaded, manifest) {
+		t.Errorf("LoadManifest() = %+v, want %+v", loaded, manifest)
+	}
+}
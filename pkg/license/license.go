@@ -0,0 +1,133 @@
+// Package license collects the licenses of locked cookbooks and checks them
+// against an allow/deny policy.
+package license
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	"github.com/bdwyertech/go-berkshelf/pkg/lockfile"
+	"github.com/bdwyertech/go-berkshelf/pkg/logging"
+	"github.com/bdwyertech/go-berkshelf/pkg/source"
+)
+
+var log = logging.For("license")
+
+// Entry is the license of one locked cookbook
+type Entry struct {
+	Cookbook string `json:"cookbook"`
+	Version  string `json:"version"`
+	// License is the value declared in metadata
+	License string `json:"license"`
+	// SPDX is the SPDX identifier for License, NoAssertion when unknown
+	SPDX string `json:"spdx"`
+	// Location is where the cookbook was locked from
+	Location string `json:"location,omitempty"`
+	// Forbidden is set by Policy.Apply, with the reason
+	Forbidden bool   `json:"forbidden,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// Collect reads the license of each locked cookbook, or only those in
+// cookbookNames, sorted by name. Supermarket cookbooks are looked up in
+// sourceManager so that configured credentials apply; git and path cookbooks
+// are read from where they were locked. Cookbooks whose metadata cannot be
+// read are logged and reported with no assertion.
+func Collect(ctx context.Context, lockFile *lockfile.LockFile, sourceManager *source.Manager, cookbookNames []string) ([]Entry, error) {
+	var entries []Entry
+	for key, src := range lockFile.Sources {
+		for name, cookbook := range src.Cookbooks {
+			if len(cookbookNames) > 0 && !slices.Contains(cookbookNames, name) {
+				continue
+			}
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+
+			info := cookbook.Source
+			if info == nil {
+				info = &lockfile.SourceInfo{Type: src.Type, URL: cmp.Or(src.URL, key)}
+			}
+			entry := Entry{Cookbook: name, Version: cookbook.Version, Location: location(info)}
+			declared, err := declaredLicense(ctx, sourceManager, info, name, cookbook.Version)
+			if err != nil {
+				log.WithField(logging.CookbookField, name).Warnf("Failed to read the license of %s: %v", name, err)
+			}
+			entry.License = declared
+			entry.SPDX = Normalize(declared)
+			entries = append(entries, entry)
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Cookbook < entries[j].Cookbook })
+	return entries, nil
+}
+
+// declaredLicense fetches the license field from the cookbook's metadata
+func declaredLicense(ctx context.Context, sourceManager *source.Manager, info *lockfile.SourceInfo, name, version string) (string, error) {
+	v, err := berkshelf.NewVersion(version)
+	if err != nil {
+		return "", err
+	}
+	src, err := sourceFor(sourceManager, info)
+	if err != nil {
+		return "", err
+	}
+	cookbook, err := src.FetchCookbook(ctx, name, v)
+	if err != nil {
+		return "", err
+	}
+	if cookbook.Metadata == nil {
+		return "", fmt.Errorf("no metadata")
+	}
+	return strings.TrimSpace(cookbook.Metadata.License), nil
+}
+
+// sourceFor returns the configured source for a Supermarket or Chef Server
+// URL, and otherwise a source for the locked location
+func sourceFor(sourceManager *source.Manager, info *lockfile.SourceInfo) (source.CookbookSource, error) {
+	if info.Type != "git" && info.Type != "path" && sourceManager != nil {
+		for _, src := range sourceManager.GetSources() {
+			if strings.TrimSuffix(src.GetSourceURL(), "/") == strings.TrimSuffix(info.URL, "/") {
+				return src, nil
+			}
+		}
+	}
+
+	location := &berkshelf.SourceLocation{
+		Type:    info.Type,
+		URL:     info.URL,
+		Path:    info.Path,
+		Ref:     info.Ref,
+		Options: make(map[string]any),
+	}
+	if info.Branch != "" {
+		location.Options["branch"] = info.Branch
+	}
+	if info.Tag != "" {
+		location.Options["tag"] = info.Tag
+	}
+	if info.Ref != "" {
+		location.Options["ref"] = info.Ref
+	}
+	if info.Revision != "" {
+		location.Options["revision"] = info.Revision
+	}
+	if info.Submodules {
+		location.Options["submodules"] = true
+	}
+	return source.NewFactory().CreateFromLocation(location)
+}
+
+// location describes where a cookbook was locked from
+func location(info *lockfile.SourceInfo) string {
+	if info.Type == "path" {
+		return info.Path
+	}
+	return info.URL
+}
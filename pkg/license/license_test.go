@@ -0,0 +1,103 @@
+package license
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		declared string
+		want     string
+	}{
+		{"", NoAssertion},
+		{"Apache 2.0", "Apache-2.0"},
+		{"Apache License, Version 2.0", "Apache-2.0"},
+		{"apachev2", "Apache-2.0"},
+		{"MIT", "MIT"},
+		{"GPLv3", "GPL-3.0-only"},
+		{"All rights reserved", "LicenseRef-Proprietary"},
+		{"BSD-3-Clause", "BSD-3-Clause"},
+		{"Acme Internal Use", "LicenseRef-Acme-Internal-Use"},
+		{"MIT or apache2", "MIT OR Apache-2.0"},
+		{"(MIT OR GPLv2) and Apache-2.0", "(MIT OR GPL-2.0-only) AND Apache-2.0"},
+	}
+
+	for _, tt := range tests {
+		if got := Normalize(tt.declared); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.declared, got, tt.want)
+		}
+	}
+}
+
+func TestPolicyApply(t *testing.T) {
+	policy := Policy{
+		Allow: []string{"Apache 2.0", "mit", "GPL-2.0-only"},
+		Deny:  []string{"GPL-2.0-only"},
+	}
+	entries := []Entry{
+		{Cookbook: "apt", SPDX: "Apache-2.0"},
+		{Cookbook: "nginx", SPDX: "GPL-2.0-only"},
+		{Cookbook: "mysql", SPDX: "GPL-3.0-only"},
+		{Cookbook: "dual", SPDX: "GPL-3.0-only OR MIT"},
+		{Cookbook: "both", SPDX: "(MIT OR GPL-3.0-only) AND GPL-2.0-only"},
+		{Cookbook: "exception", SPDX: "Apache-2.0 WITH LLVM-exception"},
+		{Cookbook: "unknown", SPDX: NoAssertion},
+	}
+
+	if got := policy.Apply(entries); got != 4 {
+		t.Errorf("Apply() = %d, want 4", got)
+	}
+	want := map[string]string{
+		"nginx":   "GPL-2.0-only is denied",
+		"mysql":   "GPL-3.0-only is not allowed",
+		"both":    "GPL-2.0-only is denied",
+		"unknown": "no license declared",
+	}
+	for _, entry := range entries {
+		if entry.Reason != want[entry.Cookbook] || entry.Forbidden != (want[entry.Cookbook] != "") {
+			t.Errorf("%s: Forbidden = %v, Reason = %q, want %q", entry.Cookbook, entry.Forbidden, entry.Reason, want[entry.Cookbook])
+		}
+	}
+
+	// Without an allowlist only denied licenses are forbidden
+	entries = []Entry{{Cookbook: "unknown", SPDX: NoAssertion}, {Cookbook: "nginx", SPDX: "GPL-2.0-only"}}
+	if got := (Policy{Deny: policy.Deny}).Apply(entries); got != 1 || entries[0].Forbidden {
+		t.Errorf("Apply() = %d, entries = %+v", got, entries)
+	}
+}
+
+func TestWriteSPDX(t *testing.T) {
+	entries := []Entry{
+		{Cookbook: "apt", Version: "7.4.0", License: "Apache 2.0", SPDX: "Apache-2.0", Location: "https://supermarket.chef.io"},
+		{Cookbook: "my_app", Version: "1.0.0", SPDX: NoAssertion, Location: "../my_app"},
+	}
+
+	var buf bytes.Buffer
+	err := WriteSPDX(&buf, Document{
+		Name:      "example",
+		Namespace: "https://spdx.org/spdxdocs/berks-example",
+		Created:   time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	}, entries)
+	if err != nil {
+		t.Fatalf("WriteSPDX() error = %v", err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{
+		"SPDXVersion: SPDX-2.3\n",
+		"DocumentName: example\n",
+		"Created: 2024-01-02T03:04:05Z\n",
+		"PackageName: apt\nSPDXID: SPDXRef-Package-apt\nPackageVersion: 7.4.0\nPackageDownloadLocation: https://supermarket.chef.io\n",
+		"PackageLicenseDeclared: Apache-2.0\nPackageLicenseComments: <text>Declared in metadata as \"Apache 2.0\"</text>\n",
+		"SPDXID: SPDXRef-Package-my-app\n",
+		"PackageDownloadLocation: NOASSERTION\n",
+		"Relationship: SPDXRef-DOCUMENT DESCRIBES SPDXRef-Package-my-app\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("WriteSPDX() missing %q in:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package license
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Policy decides which licenses are acceptable. Entries are SPDX identifiers
+// or aliases Normalize understands, compared case-insensitively.
+type Policy struct {
+	// Allow, when set, is the only licenses accepted
+	Allow []string
+	// Deny are licenses never accepted
+	Deny []string
+}
+
+// Apply marks the entries the policy forbids and returns how many there are.
+// An expression is accepted when any of its OR alternatives has every license
+// accepted; WITH exceptions are judged by their license alone.
+func (p Policy) Apply(entries []Entry) int {
+	forbidden := 0
+	for i := range entries {
+		if reason := p.check(entries[i].SPDX); reason != "" {
+			entries[i].Forbidden = true
+			entries[i].Reason = reason
+			forbidden++
+		}
+	}
+	return forbidden
+}
+
+// check returns why expression is forbidden, or "" if it is accepted
+func (p Policy) check(expression string) string {
+	var reasons []string
+	for _, alternative := range splitOperator(expression, "OR") {
+		reason := ""
+		for _, term := range splitOperator(alternative, "AND") {
+			if strings.HasPrefix(term, "(") {
+				reason = p.check(term)
+			} else {
+				reason = p.checkID(term)
+			}
+			if reason != "" {
+				break
+			}
+		}
+		if reason == "" {
+			return ""
+		}
+		reasons = append(reasons, reason)
+	}
+	return strings.Join(reasons, "; ")
+}
+
+func (p Policy) checkID(id string) string {
+	id, _, _ = strings.Cut(id, " WITH ")
+	if contains(p.Deny, id) {
+		return fmt.Sprintf("%s is denied", id)
+	}
+	if len(p.Allow) > 0 && !contains(p.Allow, id) {
+		if id == NoAssertion {
+			return "no license declared"
+		}
+		return fmt.Sprintf("%s is not allowed", id)
+	}
+	return ""
+}
+
+// splitOperator splits an expression on an operator outside parentheses,
+// first removing parentheses that enclose the whole expression
+func splitOperator(expression, op string) []string {
+	tokens := strings.Fields(strings.NewReplacer("(", " ( ", ")", " ) ").Replace(expression))
+	for len(tokens) >= 2 && tokens[0] == "(" && closing(tokens) == len(tokens)-1 {
+		tokens = tokens[1 : len(tokens)-1]
+	}
+
+	var parts []string
+	depth, start := 0, 0
+	for i, token := range tokens {
+		switch {
+		case token == "(":
+			depth++
+		case token == ")":
+			depth--
+		case depth == 0 && token == op:
+			parts = append(parts, strings.Join(tokens[start:i], " "))
+			start = i + 1
+		}
+	}
+	return append(parts, strings.Join(tokens[start:], " "))
+}
+
+// closing returns the index of the parenthesis closing tokens[0]
+func closing(tokens []string) int {
+	depth := 0
+	for i, token := range tokens {
+		switch token {
+		case "(":
+			depth++
+		case ")":
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// contains reports whether licenses holds id, normalizing each entry
+func contains(licenses []string, id string) bool {
+	for _, license := range licenses {
+		if strings.EqualFold(Normalize(license), id) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,150 @@
+package license
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// NoAssertion is the SPDX value for an unknown license
+const NoAssertion = "NOASSERTION"
+
+// aliases maps the license strings commonly found in cookbook metadata,
+// lowercased with punctuation collapsed to spaces, to SPDX identifiers
+var aliases = map[string]string{
+	"apache 2 0":                      "Apache-2.0",
+	"apache v2 0":                     "Apache-2.0",
+	"apache 2":                        "Apache-2.0",
+	"apache2":                         "Apache-2.0",
+	"apachev2":                        "Apache-2.0",
+	"apache license 2 0":              "Apache-2.0",
+	"apache license version 2 0":      "Apache-2.0",
+	"mit":                             "MIT",
+	"mit license":                     "MIT",
+	"bsd":                             "BSD-3-Clause",
+	"bsd 2 clause":                    "BSD-2-Clause",
+	"bsd 3 clause":                    "BSD-3-Clause",
+	"gplv2":                           "GPL-2.0-only",
+	"gpl v2":                          "GPL-2.0-only",
+	"gpl 2 0":                         "GPL-2.0-only",
+	"gnu public license 2 0":          "GPL-2.0-only",
+	"gplv3":                           "GPL-3.0-only",
+	"gpl v3":                          "GPL-3.0-only",
+	"gpl 3 0":                         "GPL-3.0-only",
+	"gnu public license 3 0":          "GPL-3.0-only",
+	"lgplv2":                          "LGPL-2.1-only",
+	"lgpl 2 1":                        "LGPL-2.1-only",
+	"lgplv3":                          "LGPL-3.0-only",
+	"lgpl 3 0":                        "LGPL-3.0-only",
+	"agplv3":                          "AGPL-3.0-only",
+	"agpl 3 0":                        "AGPL-3.0-only",
+	"mpl 2 0":                         "MPL-2.0",
+	"mpl v2":                          "MPL-2.0",
+	"mozilla public license 2 0":      "MPL-2.0",
+	"all rights reserved":             "LicenseRef-Proprietary",
+	"proprietary":                     "LicenseRef-Proprietary",
+	"proprietary all rights reserved": "LicenseRef-Proprietary",
+}
+
+var (
+	punctuation = regexp.MustCompile(`[^a-z0-9]+`)
+	spdxID      = regexp.MustCompile(`^[A-Za-z0-9.+-]+$`)
+	nonIDChars  = regexp.MustCompile(`[^A-Za-z0-9.]+`)
+	operator    = regexp.MustCompile(`(?i)\s+(AND|OR|WITH)\s+`)
+)
+
+// Normalize returns the SPDX identifier or expression for a metadata license.
+// Known aliases such as "Apache 2.0" map to their identifier, values that
+// already look like identifiers are kept, and anything else becomes a
+// LicenseRef. An empty license is NoAssertion.
+func Normalize(declared string) string {
+	declared = strings.TrimSpace(declared)
+	if declared == "" {
+		return NoAssertion
+	}
+	if operator.MatchString(declared) {
+		return normalizeExpression(declared)
+	}
+	return normalizeID(declared)
+}
+
+func normalizeID(declared string) string {
+	key := strings.TrimSpace(punctuation.ReplaceAllString(strings.ToLower(declared), " "))
+	if id, ok := aliases[key]; ok {
+		return id
+	}
+	if spdxID.MatchString(declared) {
+		return declared
+	}
+	return "LicenseRef-" + strings.Trim(nonIDChars.ReplaceAllString(declared, "-"), "-")
+}
+
+// normalizeExpression normalizes each license in an SPDX expression, keeping
+// the operators and parentheses
+func normalizeExpression(expression string) string {
+	var out []string
+	for _, token := range strings.Fields(strings.NewReplacer("(", " ( ", ")", " ) ").Replace(expression)) {
+		switch upper := strings.ToUpper(token); upper {
+		case "AND", "OR", "WITH":
+			out = append(out, upper)
+		case "(", ")":
+			out = append(out, token)
+		default:
+			out = append(out, normalizeID(token))
+		}
+	}
+	return strings.NewReplacer("( ", "(", " )", ")").Replace(strings.Join(out, " "))
+}
+
+// Document describes the SPDX document WriteSPDX produces
+type Document struct {
+	// Name is the document name, usually the project directory
+	Name string
+	// Namespace is a URI unique to this document
+	Namespace string
+	Created   time.Time
+}
+
+// WriteSPDX writes entries as an SPDX 2.3 tag-value document, one package per
+// cookbook with its declared license. Licenses are not concluded, as berks
+// does not inspect cookbook files.
+func WriteSPDX(w io.Writer, doc Document, entries []Entry) error {
+	created := doc.Created
+	if created.IsZero() {
+		created = time.Now()
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "SPDXVersion: SPDX-2.3\n")
+	fmt.Fprintf(&b, "DataLicense: CC0-1.0\n")
+	fmt.Fprintf(&b, "SPDXID: SPDXRef-DOCUMENT\n")
+	fmt.Fprintf(&b, "DocumentName: %s\n", doc.Name)
+	fmt.Fprintf(&b, "DocumentNamespace: %s\n", doc.Namespace)
+	fmt.Fprintf(&b, "Creator: Tool: go-berkshelf\n")
+	fmt.Fprintf(&b, "Created: %s\n", created.UTC().Format(time.RFC3339))
+
+	for _, entry := range entries {
+		download := NoAssertion
+		if strings.HasPrefix(entry.Location, "http://") || strings.HasPrefix(entry.Location, "https://") {
+			download = entry.Location
+		}
+		fmt.Fprintf(&b, "\n")
+		fmt.Fprintf(&b, "PackageName: %s\n", entry.Cookbook)
+		fmt.Fprintf(&b, "SPDXID: SPDXRef-Package-%s\n", punctuation.ReplaceAllString(strings.ToLower(entry.Cookbook), "-"))
+		fmt.Fprintf(&b, "PackageVersion: %s\n", entry.Version)
+		fmt.Fprintf(&b, "PackageDownloadLocation: %s\n", download)
+		fmt.Fprintf(&b, "FilesAnalyzed: false\n")
+		fmt.Fprintf(&b, "PackageLicenseConcluded: %s\n", NoAssertion)
+		fmt.Fprintf(&b, "PackageLicenseDeclared: %s\n", entry.SPDX)
+		if entry.License != "" && entry.License != entry.SPDX {
+			fmt.Fprintf(&b, "PackageLicenseComments: <text>Declared in metadata as %q</text>\n", entry.License)
+		}
+		fmt.Fprintf(&b, "PackageCopyrightText: %s\n", NoAssertion)
+		fmt.Fprintf(&b, "Relationship: SPDXRef-DOCUMENT DESCRIBES SPDXRef-Package-%s\n", punctuation.ReplaceAllString(strings.ToLower(entry.Cookbook), "-"))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,127 @@
+package lockfile
+
+import (
+	"maps"
+	"slices"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+)
+
+// ChangeType is the kind of change a cookbook went through between two lock files
+type ChangeType string
+
+const (
+	ChangeAdded         ChangeType = "added"
+	ChangeRemoved       ChangeType = "removed"
+	ChangeUpgraded      ChangeType = "upgraded"
+	ChangeDowngraded    ChangeType = "downgraded"
+	ChangeSourceChanged ChangeType = "source_changed"
+)
+
+// Change is a cookbook that differs between two lock files. A cookbook whose
+// version and source both changed is upgraded or downgraded, with both
+// sources set.
+type Change struct {
+	Cookbook   string     `json:"cookbook"`
+	Type       ChangeType `json:"type"`
+	From       string     `json:"from,omitempty"`
+	To         string     `json:"to,omitempty"`
+	FromSource string     `json:"from_source,omitempty"`
+	ToSource   string     `json:"to_source,omitempty"`
+}
+
+// Diff returns the cookbooks that changed from the base lock file to head,
+// sorted by name. Either lock file may be nil, which is the same as an empty one.
+func Diff(base, head *LockFile) []Change {
+	oldCookbooks, newCookbooks := lockedCookbooks(base), lockedCookbooks(head)
+
+	names := slices.Collect(maps.Keys(oldCookbooks))
+	for name := range newCookbooks {
+		if _, exists := oldCookbooks[name]; !exists {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	var changes []Change
+	for _, name := range names {
+		before, hadBefore := oldCookbooks[name]
+		after, hasAfter := newCookbooks[name]
+		switch {
+		case !hasAfter:
+			changes = append(changes, Change{Cookbook: name, Type: ChangeRemoved, From: before.version, FromSource: before.source})
+		case !hadBefore:
+			changes = append(changes, Change{Cookbook: name, Type: ChangeAdded, To: after.version, ToSource: after.source})
+		default:
+			change := Change{Cookbook: name, From: before.version, To: after.version}
+			if before.source != after.source {
+				change.Type = ChangeSourceChanged
+				change.FromSource, change.ToSource = before.source, after.source
+			}
+			if before.version != after.version {
+				change.Type = versionChange(before.version, after.version)
+			}
+			if change.Type != "" {
+				changes = append(changes, change)
+			}
+		}
+	}
+	return changes
+}
+
+// lockedCookbook is the version and source a cookbook is locked to
+type lockedCookbook struct {
+	version string
+	source  string
+}
+
+// lockedCookbooks returns the cookbooks of a lock file by name
+func lockedCookbooks(lf *LockFile) map[string]lockedCookbook {
+	cookbooks := make(map[string]lockedCookbook)
+	if lf == nil {
+		return cookbooks
+	}
+	for name := range lf.ListCookbooks() {
+		cookbook, sourceKey, _ := lf.GetCookbook(name)
+		source := sourceKey
+		if cookbook.Source != nil {
+			source = cookbook.Source.describe()
+		}
+		cookbooks[name] = lockedCookbook{version: cookbook.Version, source: source}
+	}
+	return cookbooks
+}
+
+// versionChange tells an upgrade from a downgrade, treating versions that do
+// not parse as an upgrade
+func versionChange(from, to string) ChangeType {
+	fromVersion, err := berkshelf.NewVersion(from)
+	if err != nil {
+		return ChangeUpgraded
+	}
+	toVersion, err := berkshelf.NewVersion(to)
+	if err != nil {
+		return ChangeUpgraded
+	}
+	if toVersion.LessThan(fromVersion) {
+		return ChangeDowngraded
+	}
+	return ChangeUpgraded
+}
+
+// describe returns where the source info points, including the git revision
+func (si *SourceInfo) describe() string {
+	location := si.URL
+	if location == "" {
+		location = si.Path
+	}
+	if location == "" {
+		location = si.Type
+	}
+	for _, revision := range []string{si.Revision, si.Ref, si.Tag, si.Branch} {
+		if revision != "" {
+			return location + "@" + revision
+		}
+	}
+	return location
+}
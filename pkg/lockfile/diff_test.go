@@ -0,0 +1,86 @@
+package lockfile_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/lockfile"
+)
+
+var _ = Describe("Diff", func() {
+	const supermarket = "https://supermarket.chef.io"
+
+	lockWith := func(cookbooks map[string]*lockfile.CookbookLock) *lockfile.LockFile {
+		lf := lockfile.NewLockFile()
+		lf.Sources[supermarket] = &lockfile.SourceLock{Type: "supermarket", URL: supermarket, Cookbooks: cookbooks}
+		return lf
+	}
+
+	It("should report added, removed, upgraded and downgraded cookbooks", func() {
+		base := lockWith(map[string]*lockfile.CookbookLock{
+			"apt":   {Version: "7.4.0"},
+			"nginx": {Version: "12.0.0"},
+			"yum":   {Version: "7.0.0"},
+			"ntp":   {Version: "5.0.0"},
+		})
+		head := lockWith(map[string]*lockfile.CookbookLock{
+			"apt":    {Version: "7.5.0"},
+			"nginx":  {Version: "11.9.0"},
+			"yum":    {Version: "7.0.0"},
+			"chrony": {Version: "1.0.0"},
+		})
+
+		Expect(lockfile.Diff(base, head)).To(Equal([]lockfile.Change{
+			{Cookbook: "apt", Type: lockfile.ChangeUpgraded, From: "7.4.0", To: "7.5.0"},
+			{Cookbook: "chrony", Type: lockfile.ChangeAdded, To: "1.0.0", ToSource: supermarket},
+			{Cookbook: "nginx", Type: lockfile.ChangeDowngraded, From: "12.0.0", To: "11.9.0"},
+			{Cookbook: "ntp", Type: lockfile.ChangeRemoved, From: "5.0.0", FromSource: supermarket},
+		}))
+	})
+
+	It("should compare versions semantically", func() {
+		base := lockWith(map[string]*lockfile.CookbookLock{"apt": {Version: "7.9.0"}})
+		head := lockWith(map[string]*lockfile.CookbookLock{"apt": {Version: "7.10.0"}})
+
+		changes := lockfile.Diff(base, head)
+		Expect(changes).To(HaveLen(1))
+		Expect(changes[0].Type).To(Equal(lockfile.ChangeUpgraded))
+	})
+
+	It("should report source changes, including git revisions", func() {
+		git := func(ref string) *lockfile.SourceInfo {
+			return &lockfile.SourceInfo{Type: "git", URL: "https://github.com/org/app.git", Ref: ref}
+		}
+		base := lockWith(map[string]*lockfile.CookbookLock{
+			"app": {Version: "1.0.0", Source: git("abc123")},
+			"db":  {Version: "2.0.0", Source: git("abc123")},
+		})
+		head := lockWith(map[string]*lockfile.CookbookLock{
+			"app": {Version: "1.0.0", Source: git("def456")},
+			"db":  {Version: "2.1.0", Source: &lockfile.SourceInfo{Type: "path", Path: "../db"}},
+		})
+
+		Expect(lockfile.Diff(base, head)).To(Equal([]lockfile.Change{
+			{
+				Cookbook: "app", Type: lockfile.ChangeSourceChanged, From: "1.0.0", To: "1.0.0",
+				FromSource: "https://github.com/org/app.git@abc123", ToSource: "https://github.com/org/app.git@def456",
+			},
+			{
+				Cookbook: "db", Type: lockfile.ChangeUpgraded, From: "2.0.0", To: "2.1.0",
+				FromSource: "https://github.com/org/app.git@abc123", ToSource: "../db",
+			},
+		}))
+	})
+
+	It("should report nothing for identical lock files", func() {
+		lf := lockWith(map[string]*lockfile.CookbookLock{"apt": {Version: "7.5.0"}})
+		Expect(lockfile.Diff(lf, lf)).To(BeEmpty())
+	})
+
+	It("should treat a nil lock file as empty", func() {
+		lf := lockWith(map[string]*lockfile.CookbookLock{"apt": {Version: "7.5.0"}})
+		Expect(lockfile.Diff(nil, lf)).To(Equal([]lockfile.Change{
+			{Cookbook: "apt", Type: lockfile.ChangeAdded, To: "7.5.0", ToSource: supermarket},
+		}))
+	})
+})
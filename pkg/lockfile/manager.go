@@ -1,9 +1,12 @@
 package lockfile
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"time"
 
 	"github.com/bdwyertech/go-berkshelf/pkg/berksfile"
 	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
@@ -22,14 +25,55 @@ const (
 type Manager struct {
 	lockFilePath     string
 	rubyLockFilePath string
+	// berksfilePath is the Berksfile the lock files are for
+	berksfilePath string
+	// skipRuby stops SaveBoth writing the Ruby lock file
+	skipRuby bool
+	// explicit is set when the lock file path was given rather than named
+	// by lockfile_name, so there is no legacy lock file to migrate
+	explicit bool
+}
+
+// Options names the lock files of a Manager
+type Options struct {
+	// Name is the JSON lock file name; DefaultLockFileName when empty
+	Name string
+	// SkipRuby writes only the JSON lock file, without the Ruby Berkshelf
+	// compatible Berksfile.lock
+	SkipRuby bool
+	// Path is the JSON lock file, overriding Name; the Ruby lock file stays
+	// in the work directory, beside the Berksfile
+	Path string
+	// Berksfile is the Berksfile the lock files are for; the Berksfile in
+	// the work directory when empty
+	Berksfile string
 }
 
 // NewManager creates a new lock file manager
 func NewManager(workDir string) *Manager {
-	return &Manager{
-		lockFilePath:     filepath.Join(workDir, DefaultLockFileName),
+	return NewManagerWithOptions(workDir, Options{})
+}
+
+// NewManagerWithOptions creates a lock file manager for the lock files in
+// workDir named by opts
+func NewManagerWithOptions(workDir string, opts Options) *Manager {
+	name := opts.Name
+	if name == "" {
+		name = DefaultLockFileName
+	}
+	m := &Manager{
+		lockFilePath:     filepath.Join(workDir, name),
 		rubyLockFilePath: filepath.Join(workDir, RubyLockFileName),
+		berksfilePath:    opts.Berksfile,
+		skipRuby:         opts.SkipRuby,
+	}
+	if opts.Path != "" {
+		m.lockFilePath, m.explicit = opts.Path, true
+	}
+	if m.berksfilePath == "" {
+		m.berksfilePath = filepath.Join(workDir, "Berksfile")
 	}
+	return m
 }
 
 // NewManagerWithPath creates a new lock file manager with custom path
@@ -37,6 +81,8 @@ func NewManagerWithPath(lockFilePath string) *Manager {
 	return &Manager{
 		lockFilePath:     lockFilePath,
 		rubyLockFilePath: filepath.Join(filepath.Dir(lockFilePath), RubyLockFileName),
+		berksfilePath:    filepath.Join(filepath.Dir(lockFilePath), "Berksfile"),
+		explicit:         true,
 	}
 }
 
@@ -72,8 +118,13 @@ func (m *Manager) Load() (*LockFile, error) {
 
 // Save writes the lock file to disk in JSON format
 func (m *Manager) Save(lockFile *LockFile) error {
-	// Update generation time
-	lockFile.UpdateGeneratedAt()
+	// Update generation time, unless nothing else changed so that identical
+	// resolutions write byte-identical lock files
+	if generatedAt, unchanged := m.unchangedSince(lockFile); unchanged {
+		lockFile.GeneratedAt = generatedAt
+	} else {
+		lockFile.UpdateGeneratedAt()
+	}
 
 	data, err := lockFile.ToJSON()
 	if err != nil {
@@ -94,11 +145,29 @@ func (m *Manager) Save(lockFile *LockFile) error {
 	return nil
 }
 
+// unchangedSince reports whether the lock file on disk matches lockFile but
+// for its generation time, and returns that time
+func (m *Manager) unchangedSince(lockFile *LockFile) (time.Time, bool) {
+	existing, err := os.ReadFile(m.lockFilePath)
+	if err != nil {
+		return time.Time{}, false
+	}
+	previous, err := FromJSON(existing)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	candidate := *lockFile
+	candidate.GeneratedAt = previous.GeneratedAt
+	data, err := candidate.ToJSON()
+	if err != nil || !bytes.Equal(data, existing) {
+		return time.Time{}, false
+	}
+	return previous.GeneratedAt, true
+}
+
 // SaveRuby writes the lock file in Ruby format to disk
 func (m *Manager) SaveRuby(lockFile *LockFile, dependencies []string) error {
-	// Update generation time
-	lockFile.UpdateGeneratedAt()
-
 	data, err := lockFile.ToRubyFormat(dependencies)
 	if err != nil {
 		return fmt.Errorf("failed to serialize lock file to Ruby format: %w", err)
@@ -118,12 +187,16 @@ func (m *Manager) SaveRuby(lockFile *LockFile, dependencies []string) error {
 	return nil
 }
 
-// SaveBoth writes both JSON and Ruby format lock files
+// SaveBoth writes both JSON and Ruby format lock files, or only the JSON
+// one when the manager skips the Ruby lock file
 func (m *Manager) SaveBoth(lockFile *LockFile, dependencies []string) error {
 	// Save JSON format
 	if err := m.Save(lockFile); err != nil {
 		return err
 	}
+	if m.skipRuby {
+		return nil
+	}
 
 	// Save Ruby format
 	if err := m.SaveRuby(lockFile, dependencies); err != nil {
@@ -133,9 +206,22 @@ func (m *Manager) SaveBoth(lockFile *LockFile, dependencies []string) error {
 	return nil
 }
 
-// Generate creates a lock file from a resolution result
+// Generate creates a lock file from a resolution result, recording the
+// cookbooks it ignored. Overrides in the existing lock file, if it can be
+// read, are carried over.
 func (m *Manager) Generate(resolution *resolver.Resolution) (*LockFile, error) {
+	lockFile := FromResolution(resolution)
+	if existing, err := m.Load(); err == nil {
+		lockFile.Overrides = existing.Overrides
+	}
+	return lockFile, nil
+}
+
+// FromResolution creates a lock file from a resolution result, recording the
+// cookbooks it ignored
+func FromResolution(resolution *resolver.Resolution) *LockFile {
 	lockFile := NewLockFile()
+	lockFile.Ignored = resolution.Ignored
 
 	// Process each resolved cookbook
 	for _, resolvedCookbook := range resolution.Cookbooks {
@@ -153,9 +239,16 @@ func (m *Manager) Generate(resolution *resolver.Resolution) (*LockFile, error) {
 
 		// Add to lock file
 		lockFile.AddCookbook(sourceKey, resolvedCookbook.Cookbook, sourceInfo)
+		if len(resolvedCookbook.Dependencies) > 0 {
+			versions := make(map[string]string, len(resolvedCookbook.Dependencies))
+			for name, version := range resolvedCookbook.Dependencies {
+				versions[name] = version.String()
+			}
+			lockFile.Sources[sourceKey].Cookbooks[resolvedCookbook.Cookbook.Name].DependencyVersions = versions
+		}
 	}
 
-	return lockFile, nil
+	return lockFile
 }
 
 // GenerateBoth creates and saves both JSON and Ruby format lock files
@@ -223,8 +316,7 @@ func (m *Manager) IsOutdated() (bool, error) {
 	}
 
 	// Check if Berksfile is newer than lock file
-	berksfilePath := filepath.Join(filepath.Dir(m.lockFilePath), "Berksfile")
-	berksfileInfo, err := os.Stat(berksfilePath)
+	berksfileInfo, err := os.Stat(m.berksfilePath)
 	if err != nil {
 		if os.IsNotExist(err) {
 			// No Berksfile, so lock file is not outdated
@@ -275,6 +367,15 @@ func (m *Manager) Validate() error {
 		}
 	}
 
+	for name, override := range lockFile.Overrides {
+		if override == nil || override.Version == "" {
+			return fmt.Errorf("empty version for override of cookbook %s", name)
+		}
+		if _, err := berkshelf.NewVersion(override.Version); err != nil {
+			return fmt.Errorf("invalid version %s for override of cookbook %s: %w", override.Version, name, err)
+		}
+	}
+
 	return nil
 }
 
@@ -288,6 +389,11 @@ func (m *Manager) GetRubyPath() string {
 	return m.rubyLockFilePath
 }
 
+// WritesRuby reports whether SaveBoth writes the Ruby lock file
+func (m *Manager) WritesRuby() bool {
+	return !m.skipRuby
+}
+
 // Remove deletes the JSON lock file
 func (m *Manager) Remove() error {
 	if !m.Exists() {
@@ -387,8 +493,11 @@ func createSourceInfoFromLocation(loc *berkshelf.SourceLocation) *SourceInfo {
 		if tag, ok := loc.Options["tag"].(string); ok {
 			sourceInfo.Tag = tag
 		}
-		if revision, ok := loc.Options["revision"].(string); ok && sourceInfo.Ref == "" {
-			sourceInfo.Ref = revision
+		if revision, ok := loc.Options["revision"].(string); ok {
+			sourceInfo.Revision = revision
+		}
+		if submodules, ok := loc.Options["submodules"].(bool); ok {
+			sourceInfo.Submodules = submodules
 		}
 	}
 
@@ -440,7 +549,13 @@ func ExtractDirectDependencies(berksfilePath string, groups []string) ([]string,
 	} else {
 		cookbooks = parsedBerksfile.GetCookbooks()
 	}
+	return DirectDependencies(cookbooks), nil
+}
 
+// DirectDependencies returns the DEPENDENCIES section of Berksfile.lock for
+// the cookbooks of a Berksfile: their names, with their constraints unless
+// they accept any version, sorted
+func DirectDependencies(cookbooks []*berksfile.CookbookDef) []string {
 	// Extract cookbook names with optional constraint annotations
 	var dependencies []string
 	for _, cookbook := range cookbooks {
@@ -452,13 +567,7 @@ func ExtractDirectDependencies(berksfilePath string, groups []string) ([]string,
 	}
 
 	// Sort dependencies for consistent output
-	for i := 0; i < len(dependencies); i++ {
-		for j := i + 1; j < len(dependencies); j++ {
-			if dependencies[i] > dependencies[j] {
-				dependencies[i], dependencies[j] = dependencies[j], dependencies[i]
-			}
-		}
-	}
+	slices.Sort(dependencies)
 
-	return dependencies, nil
+	return dependencies
 }
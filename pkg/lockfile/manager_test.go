@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -48,6 +49,37 @@ var _ = Describe("Manager", func() {
 		})
 	})
 
+	Describe("NewManagerWithOptions", func() {
+		It("should use the given lock file path and Berksfile", func() {
+			lockPath := filepath.Join(tmpDir, "locks", "app.lock")
+			berksfilePath := filepath.Join(tmpDir, "app", "Berksfile")
+			m := lockfile.NewManagerWithOptions(filepath.Join(tmpDir, "app"), lockfile.Options{Path: lockPath, Berksfile: berksfilePath})
+			Expect(m.GetPath()).To(Equal(lockPath))
+			Expect(m.GetRubyPath()).To(Equal(filepath.Join(tmpDir, "app", lockfile.RubyLockFileName)))
+
+			Expect(os.MkdirAll(filepath.Dir(lockPath), 0o755)).To(Succeed())
+			Expect(m.Save(lockfile.NewLockFile())).To(Succeed())
+			outdated, err := m.IsOutdated()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(outdated).To(BeFalse())
+
+			// A Berksfile edited after locking outdates the lock file
+			Expect(os.MkdirAll(filepath.Dir(berksfilePath), 0o755)).To(Succeed())
+			Expect(os.WriteFile(berksfilePath, []byte("cookbook 'nginx'\n"), 0o644)).To(Succeed())
+			future := time.Now().Add(time.Hour)
+			Expect(os.Chtimes(berksfilePath, future, future)).To(Succeed())
+			outdated, err = m.IsOutdated()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(outdated).To(BeTrue())
+		})
+
+		It("should not migrate a legacy lock file to a given path", func() {
+			Expect(manager.Save(lockfile.NewLockFile())).To(Succeed())
+			m := lockfile.NewManagerWithOptions(tmpDir, lockfile.Options{Path: filepath.Join(tmpDir, "app.lock")})
+			Expect(m.LegacyPath()).To(BeEmpty())
+		})
+	})
+
 	Describe("Exists", func() {
 		It("should not exist initially", func() {
 			Expect(manager.Exists()).To(BeFalse())
@@ -100,6 +132,39 @@ var _ = Describe("Manager", func() {
 		})
 	})
 
+	Describe("Save", func() {
+		newLockFile := func(version string) *lockfile.LockFile {
+			lf := lockfile.NewLockFile()
+			for _, name := range []string{"nginx", "apt", "build-essential"} {
+				lf.AddCookbook(source.PUBLIC_SUPERMARKET, &berkshelf.Cookbook{
+					Name:    name,
+					Version: berkshelf.MustVersion(version),
+				}, nil)
+			}
+			return lf
+		}
+
+		It("should write identical lock files byte for byte", func() {
+			Expect(manager.Save(newLockFile("1.0.0"))).To(Succeed())
+			first, err := os.ReadFile(manager.GetPath())
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(manager.Save(newLockFile("1.0.0"))).To(Succeed())
+			Expect(os.ReadFile(manager.GetPath())).To(Equal(first))
+		})
+
+		It("should update the generation time when the lock file changes", func() {
+			Expect(manager.Save(newLockFile("1.0.0"))).To(Succeed())
+			first, err := manager.Load()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(manager.Save(newLockFile("1.0.1"))).To(Succeed())
+			second, err := manager.Load()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(second.GeneratedAt).To(BeTemporally(">", first.GeneratedAt))
+		})
+	})
+
 	Describe("Load", func() {
 		It("should return empty lock file for non-existent path", func() {
 			lf, err := manager.Load()
@@ -136,6 +201,68 @@ var _ = Describe("Manager", func() {
 			Expect(err).NotTo(HaveOccurred())
 			Expect(lf.HasCookbook("nginx")).To(BeTrue())
 		})
+
+		It("should record the versions dependencies resolved to", func() {
+			resolution := resolver.NewResolution()
+			cookbook := &berkshelf.Cookbook{
+				Name:         "nginx",
+				Version:      berkshelf.MustVersion("1.2.3"),
+				Dependencies: map[string]*berkshelf.Constraint{"apt": berkshelf.MustConstraint("~> 2.2")},
+			}
+			resolution.AddCookbook(&resolver.ResolvedCookbook{
+				Name:         "nginx",
+				Version:      cookbook.Version,
+				Dependencies: map[string]*berkshelf.Version{"apt": berkshelf.MustVersion("2.9.2")},
+				Cookbook:     cookbook,
+			})
+
+			lf, err := manager.Generate(resolution)
+			Expect(err).NotTo(HaveOccurred())
+			nginx, _, ok := lf.GetCookbook("nginx")
+			Expect(ok).To(BeTrue())
+			Expect(nginx.Dependencies).To(Equal(map[string]string{"apt": "~> 2.2"}))
+			Expect(nginx.DependencyVersions).To(Equal(map[string]string{"apt": "2.9.2"}))
+		})
+
+		It("should carry over overrides from the existing lock file", func() {
+			existing := lockfile.NewLockFile()
+			existing.Overrides = map[string]*lockfile.Override{
+				"nginx": {Version: "1.2.4", Reason: "CVE hotfix"},
+			}
+			Expect(manager.Save(existing)).To(Succeed())
+
+			lf, err := manager.Generate(resolver.NewResolution())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(lf.Overrides).To(HaveKey("nginx"))
+			Expect(lf.Overrides["nginx"].Reason).To(Equal("CVE hotfix"))
+		})
+	})
+
+	Describe("OverrideRequirements", func() {
+		It("should pin each override to its exact version and source", func() {
+			lf := lockfile.NewLockFile()
+			lf.Overrides = map[string]*lockfile.Override{
+				"nginx": {Version: "1.2.4", Source: &lockfile.SourceInfo{Type: "git", URL: "https://git.example.com/nginx.git", Branch: "hotfix", Revision: "0123abcd"}},
+				"apt":   {Version: "7.5.0"},
+			}
+
+			requirements, err := lf.OverrideRequirements()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(requirements).To(HaveLen(2))
+			Expect(requirements[0].Name).To(Equal("apt"))
+			Expect(requirements[0].Source).To(BeNil())
+			Expect(requirements[1].Constraint.String()).To(Equal("= 1.2.4"))
+			Expect(requirements[1].Source.URL).To(Equal("https://git.example.com/nginx.git"))
+			Expect(requirements[1].Source.Options).To(HaveKeyWithValue("branch", "hotfix"))
+			Expect(requirements[1].Source.Options).To(HaveKeyWithValue("revision", "0123abcd"))
+		})
+
+		It("should reject an override without a valid version", func() {
+			lf := lockfile.NewLockFile()
+			lf.Overrides = map[string]*lockfile.Override{"nginx": {Version: "latest"}}
+			_, err := lf.OverrideRequirements()
+			Expect(err).To(HaveOccurred())
+		})
 	})
 
 	Describe("Update", func() {
@@ -0,0 +1,116 @@
+package lockfile
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// LegacyPath returns the lock file left under DefaultLockFileName after the
+// lock file was given another name, or "" when there is none
+func (m *Manager) LegacyPath() string {
+	if m.explicit {
+		return ""
+	}
+	legacy := filepath.Join(filepath.Dir(m.lockFilePath), DefaultLockFileName)
+	if legacy == m.lockFilePath {
+		return ""
+	}
+	if _, err := os.Stat(legacy); err != nil {
+		return ""
+	}
+	return legacy
+}
+
+// StaleRubyPath returns the Ruby lock file when the manager no longer writes
+// it, or "" when there is none
+func (m *Manager) StaleRubyPath() string {
+	if !m.skipRuby || !m.RubyExists() {
+		return ""
+	}
+	return m.rubyLockFilePath
+}
+
+// Migrate moves the legacy lock file to the configured name. When a lock
+// file already exists under the configured name it is the one in use, and
+// the legacy lock file is removed instead.
+func (m *Manager) Migrate() error {
+	legacy := m.LegacyPath()
+	if legacy == "" {
+		return nil
+	}
+	if m.Exists() {
+		if err := os.Remove(legacy); err != nil {
+			return fmt.Errorf("failed to remove legacy lock file %s: %w", legacy, err)
+		}
+		return nil
+	}
+	if err := os.Rename(legacy, m.lockFilePath); err != nil {
+		return fmt.Errorf("failed to move legacy lock file %s to %s: %w", legacy, m.lockFilePath, err)
+	}
+	return nil
+}
+
+// RubyDivergence compares the Ruby lock file with lockFile and describes each
+// cookbook they lock differently, sorted by name. Ruby Berkshelf rewrites
+// Berksfile.lock without touching the JSON lock file, so the two can drift
+// apart. A Ruby lock file that is missing or no longer written is not
+// compared.
+func (m *Manager) RubyDivergence(lockFile *LockFile) ([]string, error) {
+	if m.skipRuby || !m.RubyExists() {
+		return nil, nil
+	}
+	data, err := os.ReadFile(m.rubyLockFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Ruby lock file %s: %w", m.rubyLockFilePath, err)
+	}
+	ruby := ParseRubyGraph(data)
+
+	name, rubyName := filepath.Base(m.lockFilePath), filepath.Base(m.rubyLockFilePath)
+	var differences []string
+	locked := lockFile.ListCookbooks()
+	for cookbook, lock := range locked {
+		version, ok := ruby[cookbook]
+		switch {
+		case !ok:
+			differences = append(differences, fmt.Sprintf("%s: not in %s", cookbook, rubyName))
+		case version != lock.Version:
+			differences = append(differences, fmt.Sprintf("%s: %s in %s, %s in %s", cookbook, lock.Version, name, version, rubyName))
+		}
+	}
+	for cookbook := range ruby {
+		if _, ok := locked[cookbook]; !ok {
+			differences = append(differences, fmt.Sprintf("%s: only in %s", cookbook, rubyName))
+		}
+	}
+	sort.Strings(differences)
+	return differences, nil
+}
+
+// ParseRubyGraph returns the version of each cookbook in the GRAPH section of
+// a Ruby lock file
+func ParseRubyGraph(data []byte) map[string]string {
+	versions := make(map[string]string)
+	inGraph := false
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, " ") {
+			inGraph = strings.TrimSpace(line) == "GRAPH"
+			continue
+		}
+		// Cookbooks are indented by two spaces, their dependencies by four
+		if !inGraph || strings.HasPrefix(line, "   ") {
+			continue
+		}
+		name, version, ok := strings.Cut(strings.TrimSpace(line), " (")
+		if ok {
+			versions[name] = strings.TrimSuffix(version, ")")
+		}
+	}
+	return versions
+}
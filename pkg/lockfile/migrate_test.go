@@ -0,0 +1,122 @@
+package lockfile_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	"github.com/bdwyertech/go-berkshelf/pkg/lockfile"
+	"github.com/bdwyertech/go-berkshelf/pkg/source"
+)
+
+var _ = Describe("Lock file naming", func() {
+	var tmpDir string
+
+	lockWith := func(versions map[string]string) *lockfile.LockFile {
+		lf := lockfile.NewLockFile()
+		for name, v := range versions {
+			version, err := berkshelf.NewVersion(v)
+			Expect(err).NotTo(HaveOccurred())
+			lf.AddCookbook(source.PUBLIC_SUPERMARKET, &berkshelf.Cookbook{Name: name, Version: version}, nil)
+		}
+		return lf
+	}
+
+	BeforeEach(func() {
+		tmpDir = GinkgoT().TempDir()
+	})
+
+	Describe("NewManagerWithOptions", func() {
+		It("names the JSON lock file", func() {
+			m := lockfile.NewManagerWithOptions(tmpDir, lockfile.Options{Name: "Berksfile.json.lock"})
+			Expect(m.GetPath()).To(Equal(filepath.Join(tmpDir, "Berksfile.json.lock")))
+			Expect(m.GetRubyPath()).To(Equal(filepath.Join(tmpDir, lockfile.RubyLockFileName)))
+		})
+
+		It("skips the Ruby lock file", func() {
+			m := lockfile.NewManagerWithOptions(tmpDir, lockfile.Options{SkipRuby: true})
+			Expect(m.WritesRuby()).To(BeFalse())
+			Expect(m.SaveBoth(lockWith(map[string]string{"nginx": "1.0.0"}), []string{"nginx"})).To(Succeed())
+			Expect(m.Exists()).To(BeTrue())
+			Expect(m.RubyExists()).To(BeFalse())
+		})
+	})
+
+	Describe("Migrate", func() {
+		var legacy *lockfile.Manager
+
+		BeforeEach(func() {
+			legacy = lockfile.NewManager(tmpDir)
+			Expect(legacy.Save(lockWith(map[string]string{"nginx": "1.0.0"}))).To(Succeed())
+		})
+
+		It("finds no legacy lock file under the default name", func() {
+			Expect(legacy.LegacyPath()).To(BeEmpty())
+		})
+
+		It("moves the legacy lock file to the configured name", func() {
+			m := lockfile.NewManagerWithOptions(tmpDir, lockfile.Options{Name: "Berksfile.json.lock"})
+			Expect(m.LegacyPath()).To(Equal(legacy.GetPath()))
+			Expect(m.Migrate()).To(Succeed())
+
+			Expect(m.LegacyPath()).To(BeEmpty())
+			lf, err := m.Load()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(lf.HasCookbook("nginx")).To(BeTrue())
+		})
+
+		It("removes the legacy lock file when the configured one exists", func() {
+			m := lockfile.NewManagerWithOptions(tmpDir, lockfile.Options{Name: "Berksfile.json.lock"})
+			Expect(m.Save(lockWith(map[string]string{"apt": "2.0.0"}))).To(Succeed())
+			Expect(m.Migrate()).To(Succeed())
+
+			Expect(legacy.Exists()).To(BeFalse())
+			lf, err := m.Load()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(lf.HasCookbook("apt")).To(BeTrue())
+		})
+	})
+
+	Describe("StaleRubyPath", func() {
+		It("reports a Ruby lock file that is no longer written", func() {
+			Expect(lockfile.NewManager(tmpDir).SaveBoth(lockWith(nil), nil)).To(Succeed())
+			Expect(lockfile.NewManager(tmpDir).StaleRubyPath()).To(BeEmpty())
+
+			m := lockfile.NewManagerWithOptions(tmpDir, lockfile.Options{SkipRuby: true})
+			Expect(m.StaleRubyPath()).To(Equal(m.GetRubyPath()))
+		})
+	})
+
+	Describe("RubyDivergence", func() {
+		It("describes cookbooks the lock files disagree on", func() {
+			m := lockfile.NewManager(tmpDir)
+			lf := lockWith(map[string]string{"nginx": "1.0.0", "apt": "2.0.0", "yum": "3.0.0"})
+			Expect(m.SaveBoth(lf, []string{"nginx"})).To(Succeed())
+
+			differences, err := m.RubyDivergence(lf)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(differences).To(BeEmpty())
+
+			// Ruby Berkshelf updated Berksfile.lock on its own
+			ruby := "DEPENDENCIES\n  nginx\n\nGRAPH\n  apt (2.1.0)\n  build-essential (8.0.0)\n  nginx (1.0.0)\n    apt (>= 0.0.0)\n"
+			Expect(os.WriteFile(m.GetRubyPath(), []byte(ruby), 0644)).To(Succeed())
+			differences, err = m.RubyDivergence(lf)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(differences).To(Equal([]string{
+				"apt: 2.0.0 in Berksfile.go.lock, 2.1.0 in Berksfile.lock",
+				"build-essential: only in Berksfile.lock",
+				"yum: not in Berksfile.lock",
+			}))
+		})
+	})
+
+	Describe("ParseRubyGraph", func() {
+		It("reads cookbook versions, not dependencies", func() {
+			data := []byte("DEPENDENCIES\n  nginx (~> 1.0)\n    path: ../nginx\n\nGRAPH\n  nginx (1.2.3)\n    apt (>= 2.0)\n")
+			Expect(lockfile.ParseRubyGraph(data)).To(Equal(map[string]string{"nginx": "1.2.3"}))
+		})
+	})
+})
@@ -2,12 +2,15 @@ package lockfile
 
 import (
 	"bytes"
+	"fmt"
 	"maps"
+	"slices"
 	"time"
 
 	"github.com/goccy/go-json"
 
 	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	"github.com/bdwyertech/go-berkshelf/pkg/resolver"
 )
 
 // LockFile represents a Berksfile.lock file structure
@@ -15,6 +18,22 @@ type LockFile struct {
 	Revision    int                    `json:"revision"`
 	GeneratedAt time.Time              `json:"generated_at"`
 	Sources     map[string]*SourceLock `json:"sources"`
+	// Overrides pin cookbooks outside the Berksfile, keyed by cookbook name
+	Overrides map[string]*Override `json:"overrides,omitempty"`
+	// Ignored are the cookbooks the Berksfile ignores that resolution
+	// skipped, which are provided out of band
+	Ignored []string `json:"ignored,omitempty"`
+}
+
+// Override pins a cookbook to an exact version, and optionally a source,
+// regardless of the Berksfile and cookbook dependencies, e.g. to ship an
+// emergency hotfix. Overrides are added to the lock file by hand and kept
+// across installs until removed.
+type Override struct {
+	Version string      `json:"version"`
+	Source  *SourceInfo `json:"source,omitempty"`
+	// Reason says why the cookbook is pinned, shown when it is flagged
+	Reason string `json:"reason,omitempty"`
 }
 
 // SourceLock represents a cookbook source in the lock file
@@ -28,7 +47,15 @@ type SourceLock struct {
 type CookbookLock struct {
 	Version      string            `json:"version"`
 	Dependencies map[string]string `json:"dependencies,omitempty"`
-	Source       *SourceInfo       `json:"source,omitempty"`
+	// DependencyVersions are the versions the dependencies resolved to
+	DependencyVersions map[string]string `json:"dependency_versions,omitempty"`
+	Source             *SourceInfo       `json:"source,omitempty"`
+	// Checksums are the file checksums the source published when the
+	// cookbook was locked, verified when it is downloaded again
+	Checksums map[string]string `json:"checksums,omitempty"`
+	// Digest pins the artifact the cookbook was locked to, for sources that
+	// address artifacts by digest
+	Digest string `json:"digest,omitempty"`
 }
 
 // SourceInfo contains additional source information for the cookbook
@@ -39,6 +66,12 @@ type SourceInfo struct {
 	Branch string `json:"branch,omitempty"`
 	Tag    string `json:"tag,omitempty"`
 	Ref    string `json:"ref,omitempty"`
+	// Revision is the commit a git source was locked to, which locked
+	// installs check out exactly
+	Revision string `json:"revision,omitempty"`
+	// Submodules is set for git sources whose submodules are installed
+	// with the cookbook
+	Submodules bool `json:"submodules,omitempty"`
 }
 
 // NewLockFile creates a new lock file with current revision
@@ -80,19 +113,75 @@ func (lf *LockFile) AddCookbook(sourceKey string, cookbook *berkshelf.Cookbook,
 		Version:      cookbook.Version.String(),
 		Dependencies: deps,
 		Source:       sourceInfo,
+		Checksums:    cookbook.FileChecksums,
+		Digest:       cookbook.Digest,
 	}
 }
 
-// GetCookbook retrieves a cookbook from the lock file
+// GetCookbook retrieves a cookbook from the lock file, from the first
+// source (by key) that has it
 func (lf *LockFile) GetCookbook(name string) (*CookbookLock, string, bool) {
-	for sourceURL, source := range lf.Sources {
-		if cookbook, exists := source.Cookbooks[name]; exists {
+	for _, sourceURL := range slices.Sorted(maps.Keys(lf.Sources)) {
+		if cookbook, exists := lf.Sources[sourceURL].Cookbooks[name]; exists {
 			return cookbook, sourceURL, true
 		}
 	}
 	return nil, "", false
 }
 
+// OverrideRequirements returns the overrides as resolver requirements, sorted
+// by cookbook name
+func (lf *LockFile) OverrideRequirements() ([]*resolver.Requirement, error) {
+	names := slices.Sorted(maps.Keys(lf.Overrides))
+	requirements := make([]*resolver.Requirement, 0, len(names))
+	for _, name := range names {
+		override := lf.Overrides[name]
+		if override == nil || override.Version == "" {
+			return nil, fmt.Errorf("override of %s has no version", name)
+		}
+		version, err := berkshelf.NewVersion(override.Version)
+		if err != nil {
+			return nil, fmt.Errorf("override of %s: %w", name, err)
+		}
+		constraint, err := berkshelf.NewConstraint("= " + version.String())
+		if err != nil {
+			return nil, fmt.Errorf("override of %s: %w", name, err)
+		}
+		if override.Source != nil {
+			requirements = append(requirements, resolver.NewRequirementWithSource(name, constraint, override.Source.Location()))
+		} else {
+			requirements = append(requirements, resolver.NewRequirement(name, constraint))
+		}
+	}
+	return requirements, nil
+}
+
+// Location returns the source location the source info was created from
+func (si *SourceInfo) Location() *berkshelf.SourceLocation {
+	location := &berkshelf.SourceLocation{
+		Type: si.Type,
+		URL:  si.URL,
+		Path: si.Path,
+		Ref:  si.Ref,
+	}
+	if si.Branch != "" || si.Tag != "" || si.Revision != "" || si.Submodules {
+		location.Options = make(map[string]any)
+		if si.Branch != "" {
+			location.Options["branch"] = si.Branch
+		}
+		if si.Tag != "" {
+			location.Options["tag"] = si.Tag
+		}
+		if si.Revision != "" {
+			location.Options["revision"] = si.Revision
+		}
+		if si.Submodules {
+			location.Options["submodules"] = true
+		}
+	}
+	return location
+}
+
 // HasCookbook checks if a cookbook exists in the lock file
 func (lf *LockFile) HasCookbook(name string) bool {
 	_, _, exists := lf.GetCookbook(name)
@@ -102,8 +191,12 @@ func (lf *LockFile) HasCookbook(name string) bool {
 // ListCookbooks returns all cookbooks in the lock file
 func (lf *LockFile) ListCookbooks() map[string]*CookbookLock {
 	cookbooks := make(map[string]*CookbookLock)
-	for _, source := range lf.Sources {
-		maps.Copy(cookbooks, source.Cookbooks)
+	for _, sourceURL := range slices.Sorted(maps.Keys(lf.Sources)) {
+		for name, cookbook := range lf.Sources[sourceURL].Cookbooks {
+			if _, exists := cookbooks[name]; !exists {
+				cookbooks[name] = cookbook
+			}
+		}
 	}
 	return cookbooks
 }
@@ -137,54 +230,13 @@ func (lf *LockFile) ToRubyFormat(dependencies []string) ([]byte, error) {
 	buffer.WriteString("GRAPH\n")
 
 	// Collect all cookbooks and sort them alphabetically
-	allCookbooks := make(map[string]*CookbookLock)
-	for _, source := range lf.Sources {
-		for name, cookbook := range source.Cookbooks {
-			allCookbooks[name] = cookbook
-		}
-	}
-
-	// Sort cookbook names for consistent output
-	var sortedNames []string
-	for name := range allCookbooks {
-		sortedNames = append(sortedNames, name)
-	}
-
-	// Simple sort implementation
-	for i := 0; i < len(sortedNames); i++ {
-		for j := i + 1; j < len(sortedNames); j++ {
-			if sortedNames[i] > sortedNames[j] {
-				sortedNames[i], sortedNames[j] = sortedNames[j], sortedNames[i]
-			}
-		}
-	}
-
-	// Write each cookbook with its dependencies
-	for _, name := range sortedNames {
+	allCookbooks := lf.ListCookbooks()
+	// Write each cookbook with its dependencies, sorted for consistent output
+	for _, name := range slices.Sorted(maps.Keys(allCookbooks)) {
 		cookbook := allCookbooks[name]
 		buffer.WriteString("  " + name + " (" + cookbook.Version + ")\n")
-
-		// Sort dependencies for consistent output
-		if len(cookbook.Dependencies) > 0 {
-			var depNames []string
-			for depName := range cookbook.Dependencies {
-				depNames = append(depNames, depName)
-			}
-
-			// Simple sort implementation for dependencies
-			for i := 0; i < len(depNames); i++ {
-				for j := i + 1; j < len(depNames); j++ {
-					if depNames[i] > depNames[j] {
-						depNames[i], depNames[j] = depNames[j], depNames[i]
-					}
-				}
-			}
-
-			// Write dependencies with indentation
-			for _, depName := range depNames {
-				constraint := cookbook.Dependencies[depName]
-				buffer.WriteString("    " + depName + " (" + constraint + ")\n")
-			}
+		for _, depName := range slices.Sorted(maps.Keys(cookbook.Dependencies)) {
+			buffer.WriteString("    " + depName + " (" + cookbook.Dependencies[depName] + ")\n")
 		}
 	}
 
@@ -2,6 +2,7 @@ package lockfile_test
 
 import (
 	"encoding/json"
+	"strings"
 	"time"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -63,6 +64,43 @@ var _ = Describe("LockFile Types", func() {
 			aptConstraint, exists := cookbookLock.Dependencies["apt"]
 			Expect(exists).To(BeTrue())
 			Expect(aptConstraint).To(Equal("~> 1.0"))
+			Expect(cookbookLock.Checksums).To(BeNil())
+		})
+
+		It("should record the file checksums the source published", func() {
+			lf := lockfile.NewLockFile()
+			cookbook := &berkshelf.Cookbook{
+				Name:          "nginx",
+				Version:       berkshelf.MustVersion("1.2.3"),
+				FileChecksums: map[string]string{"metadata.json": "0cc175b9c0f1b6a831c399e269772661"},
+			}
+			lf.AddCookbook("https://supermarket.chef.io", cookbook, nil)
+
+			data, err := lf.ToJSON()
+			Expect(err).NotTo(HaveOccurred())
+			loaded, err := lockfile.FromJSON(data)
+			Expect(err).NotTo(HaveOccurred())
+			cookbookLock, _, ok := loaded.GetCookbook("nginx")
+			Expect(ok).To(BeTrue())
+			Expect(cookbookLock.Checksums).To(Equal(cookbook.FileChecksums))
+		})
+
+		It("should record the digest the cookbook is pinned to", func() {
+			lf := lockfile.NewLockFile()
+			cookbook := &berkshelf.Cookbook{
+				Name:    "nginx",
+				Version: berkshelf.MustVersion("1.2.3"),
+				Digest:  "sha256:" + strings.Repeat("a", 64),
+			}
+			lf.AddCookbook("oci://ghcr.io/acme/cookbooks", cookbook, nil)
+
+			data, err := lf.ToJSON()
+			Expect(err).NotTo(HaveOccurred())
+			loaded, err := lockfile.FromJSON(data)
+			Expect(err).NotTo(HaveOccurred())
+			cookbookLock, _, ok := loaded.GetCookbook("nginx")
+			Expect(ok).To(BeTrue())
+			Expect(cookbookLock.Digest).To(Equal(cookbook.Digest))
 		})
 	})
 
@@ -0,0 +1,111 @@
+// Package logging provides per-subsystem loggers with a shared output format.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Field names used to correlate log lines.
+const (
+	SubsystemField = "subsystem"
+	CookbookField  = "cookbook"
+)
+
+// Options configures log output.
+type Options struct {
+	// Format is either "text" (default) or "json".
+	Format string
+	// Level is the default level for all loggers.
+	Level logrus.Level
+	// Levels overrides Level for individual subsystems (resolver, source, cache).
+	Levels map[string]logrus.Level
+	// Output defaults to stderr.
+	Output io.Writer
+	// ReportCaller adds the calling function to each entry.
+	ReportCaller bool
+}
+
+var (
+	mu      sync.Mutex
+	loggers = make(map[string]*logrus.Logger)
+	current = Options{Level: logrus.InfoLevel}
+)
+
+// For returns the logger for a subsystem. Entries carry the subsystem name and
+// pick up later calls to Configure, so it is safe to call at package init.
+func For(subsystem string) *logrus.Entry {
+	mu.Lock()
+	defer mu.Unlock()
+
+	logger, ok := loggers[subsystem]
+	if !ok {
+		logger = logrus.New()
+		apply(logger, subsystem)
+		loggers[subsystem] = logger
+	}
+	return logger.WithField(SubsystemField, subsystem)
+}
+
+// Configure applies opts to the standard logger and every subsystem logger.
+func Configure(opts Options) error {
+	switch strings.ToLower(opts.Format) {
+	case "", "text", "json":
+	default:
+		return fmt.Errorf("unsupported log format: %s (supported: text, json)", opts.Format)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	current = opts
+	apply(logrus.StandardLogger(), "")
+	for subsystem, logger := range loggers {
+		apply(logger, subsystem)
+	}
+	return nil
+}
+
+// ParseLevels parses subsystem=level pairs such as "resolver=debug".
+func ParseLevels(specs []string) (map[string]logrus.Level, error) {
+	levels := make(map[string]logrus.Level, len(specs))
+	for _, spec := range specs {
+		subsystem, value, ok := strings.Cut(spec, "=")
+		if !ok || subsystem == "" {
+			return nil, fmt.Errorf("invalid log level %q: expected subsystem=level", spec)
+		}
+		level, err := logrus.ParseLevel(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid log level %q: %w", spec, err)
+		}
+		levels[strings.TrimSpace(subsystem)] = level
+	}
+	return levels, nil
+}
+
+// apply configures a single logger from the current options. Callers hold mu.
+func apply(logger *logrus.Logger, subsystem string) {
+	if strings.EqualFold(current.Format, "json") {
+		logger.SetFormatter(&logrus.JSONFormatter{})
+	} else {
+		logger.SetFormatter(&logrus.TextFormatter{})
+	}
+
+	out := current.Output
+	if out == nil {
+		out = os.Stderr
+	}
+	logger.SetOutput(out)
+	logger.SetReportCaller(current.ReportCaller)
+
+	level := current.Level
+	if l, ok := current.Levels[subsystem]; ok && subsystem != "" {
+		level = l
+	}
+	logger.SetLevel(level)
+}
@@ -0,0 +1,66 @@
+package logging
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/goccy/go-json"
+	"github.com/sirupsen/logrus"
+)
+
+func TestParseLevels(t *testing.T) {
+	levels, err := ParseLevels([]string{"resolver=debug", "cache=warn"})
+	if err != nil {
+		t.Fatalf("ParseLevels() error = %v", err)
+	}
+	if levels["resolver"] != logrus.DebugLevel || levels["cache"] != logrus.WarnLevel {
+		t.Errorf("ParseLevels() = %v", levels)
+	}
+
+	for _, spec := range []string{"resolver", "=debug", "resolver=loud"} {
+		if _, err := ParseLevels([]string{spec}); err == nil {
+			t.Errorf("ParseLevels(%q) expected error", spec)
+		}
+	}
+}
+
+func TestConfigure_JSONWithSubsystemLevels(t *testing.T) {
+	var buf bytes.Buffer
+	defer Configure(Options{Level: logrus.InfoLevel})
+
+	resolverLog := For("test-resolver")
+	cacheLog := For("test-cache")
+
+	err := Configure(Options{
+		Format: "json",
+		Level:  logrus.InfoLevel,
+		Levels: map[string]logrus.Level{"test-resolver": logrus.DebugLevel},
+		Output: &buf,
+	})
+	if err != nil {
+		t.Fatalf("Configure() error = %v", err)
+	}
+
+	resolverLog.WithField(CookbookField, "nginx").Debug("resolving")
+	cacheLog.Debug("suppressed")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected 1 log line, got %d: %q", len(lines), buf.String())
+	}
+
+	var entry map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("log line is not JSON: %v", err)
+	}
+	if entry[SubsystemField] != "test-resolver" || entry[CookbookField] != "nginx" || entry["msg"] != "resolving" {
+		t.Errorf("unexpected entry: %v", entry)
+	}
+}
+
+func TestConfigure_InvalidFormat(t *testing.T) {
+	if err := Configure(Options{Format: "xml"}); err == nil {
+		t.Error("Configure() expected error for unsupported format")
+	}
+}
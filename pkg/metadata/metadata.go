@@ -0,0 +1,401 @@
+// Package metadata reads cookbook metadata.rb files and compiles them into
+// the metadata.json format Chef Server expects, as `knife cookbook metadata` does.
+package metadata
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	"github.com/bdwyertech/go-berkshelf/pkg/logging"
+)
+
+var log = logging.For("metadata")
+
+// DefaultLicense is the license Chef assumes when metadata.rb sets none
+const DefaultLicense = "All rights reserved"
+
+// Metadata is a cookbook's metadata.json, with fields in the order Chef writes them
+type Metadata struct {
+	Name               string            `json:"name"`
+	Description        string            `json:"description"`
+	LongDescription    string            `json:"long_description"`
+	Maintainer         *string           `json:"maintainer"`
+	MaintainerEmail    *string           `json:"maintainer_email"`
+	License            string            `json:"license"`
+	Platforms          map[string]string `json:"platforms"`
+	Dependencies       map[string]string `json:"dependencies"`
+	Providing          map[string]string `json:"providing"`
+	Recipes            map[string]string `json:"recipes"`
+	Version            string            `json:"version"`
+	SourceURL          string            `json:"source_url"`
+	IssuesURL          string            `json:"issues_url"`
+	Privacy            bool              `json:"privacy"`
+	ChefVersions       [][]string        `json:"chef_versions"`
+	OhaiVersions       [][]string        `json:"ohai_versions"`
+	Gems               [][]string        `json:"gems"`
+	EagerLoadLibraries any               `json:"eager_load_libraries"`
+
+	// Warnings lists metadata.rb statements that were skipped
+	Warnings []string `json:"-"`
+}
+
+// New returns metadata with Chef's defaults
+func New() *Metadata {
+	return &Metadata{
+		License:            DefaultLicense,
+		Platforms:          map[string]string{},
+		Dependencies:       map[string]string{},
+		Providing:          map[string]string{},
+		Recipes:            map[string]string{},
+		Version:            "0.0.0",
+		ChefVersions:       [][]string{},
+		OhaiVersions:       [][]string{},
+		Gems:               [][]string{},
+		EagerLoadLibraries: true,
+	}
+}
+
+// ParseFile reads a metadata.rb file. Statements that cannot be evaluated and
+// unknown methods are skipped and recorded in Warnings; invalid values for
+// known fields are errors.
+func ParseFile(path string) (*Metadata, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+	return Parse(string(data), abs)
+}
+
+// Parse evaluates metadata.rb source. path is used for __FILE__ and to
+// resolve files read with File.read/IO.read.
+func Parse(src, path string) (*Metadata, error) {
+	calls, warnings := parseRuby(src, path)
+
+	m := New()
+	m.Warnings = warnings
+	for _, c := range calls {
+		if err := m.apply(c); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", filepath.Base(path), c.Line, err)
+		}
+	}
+	return m, nil
+}
+
+// ParseJSON reads a compiled metadata.json. Fields it omits keep Chef's defaults.
+func ParseJSON(data []byte) (*Metadata, error) {
+	m := New()
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("failed to parse metadata.json: %w", err)
+	}
+	return m, nil
+}
+
+// apply sets the field for one metadata.rb method call
+func (m *Metadata) apply(c call) error {
+	switch c.Name {
+	case "name":
+		return setString(c, &m.Name)
+	case "description":
+		return setString(c, &m.Description)
+	case "long_description":
+		return setString(c, &m.LongDescription)
+	case "license":
+		return setString(c, &m.License)
+	case "source_url":
+		return setString(c, &m.SourceURL)
+	case "issues_url":
+		return setString(c, &m.IssuesURL)
+	case "maintainer", "maintainer_email":
+		var s string
+		if err := setString(c, &s); err != nil {
+			return err
+		}
+		if c.Name == "maintainer" {
+			m.Maintainer = &s
+		} else {
+			m.MaintainerEmail = &s
+		}
+	case "version":
+		var s string
+		if err := setString(c, &s); err != nil {
+			return err
+		}
+		version, err := normalizeVersion(s)
+		if err != nil {
+			return err
+		}
+		m.Version = version
+	case "depends", "supports", "provides":
+		name, constraint, err := nameAndConstraint(c)
+		if err != nil {
+			return err
+		}
+		switch c.Name {
+		case "depends":
+			m.Dependencies[name] = constraint
+		case "supports":
+			m.Platforms[name] = constraint
+		default:
+			m.Providing[name] = constraint
+		}
+	case "recipe":
+		args := stringArgs(c.Args)
+		if len(args) != 2 {
+			return fmt.Errorf("recipe expects a name and a description")
+		}
+		m.Recipes[args[0]] = args[1]
+	case "chef_version", "ohai_version":
+		requirements, err := gemRequirements(stringArgs(c.Args))
+		if err != nil {
+			return fmt.Errorf("%s: %w", c.Name, err)
+		}
+		if c.Name == "chef_version" {
+			m.ChefVersions = append(m.ChefVersions, requirements)
+		} else {
+			m.OhaiVersions = append(m.OhaiVersions, requirements)
+		}
+	case "gem":
+		args := stringArgs(c.Args)
+		if len(args) == 0 {
+			return fmt.Errorf("gem expects a gem name")
+		}
+		m.Gems = append(m.Gems, args)
+	case "privacy":
+		if len(c.Args) != 1 {
+			return fmt.Errorf("privacy expects true or false")
+		}
+		b, ok := c.Args[0].(bool)
+		if !ok {
+			return fmt.Errorf("privacy expects true or false")
+		}
+		m.Privacy = b
+	case "eager_load_libraries":
+		if len(c.Args) != 1 {
+			return fmt.Errorf("eager_load_libraries expects true, false or a list of globs")
+		}
+		switch v := c.Args[0].(type) {
+		case bool, string:
+			m.EagerLoadLibraries = v
+		case []any:
+			m.EagerLoadLibraries = stringArgs(v)
+		default:
+			return fmt.Errorf("eager_load_libraries expects true, false or a list of globs")
+		}
+	case "attribute", "grouping", "recommends", "suggests", "conflicts", "replaces":
+		// Removed from Chef's metadata; knife ignores them with a deprecation
+		m.Warnings = append(m.Warnings, fmt.Sprintf("line %d: ignored: %s is no longer supported by Chef", c.Line, c.Name))
+	default:
+		m.Warnings = append(m.Warnings, fmt.Sprintf("line %d: ignored: unknown metadata method %s", c.Line, c.Name))
+	}
+	return nil
+}
+
+// setString stores the single string argument of c in dst
+func setString(c call, dst *string) error {
+	if len(c.Args) != 1 {
+		return fmt.Errorf("%s expects one argument, got %d", c.Name, len(c.Args))
+	}
+	s, ok := c.Args[0].(string)
+	if !ok {
+		return fmt.Errorf("%s expects a string", c.Name)
+	}
+	*dst = s
+	return nil
+}
+
+// nameAndConstraint parses the arguments of depends, supports and provides
+func nameAndConstraint(c call) (string, string, error) {
+	args := stringArgs(c.Args)
+	switch len(args) {
+	case 1:
+		return args[0], ">= 0.0.0", nil
+	case 2:
+		constraint, err := normalizeConstraint(args[1])
+		if err != nil {
+			return "", "", fmt.Errorf("%s %s: %w", c.Name, args[0], err)
+		}
+		return args[0], constraint, nil
+	case 0:
+		return "", "", fmt.Errorf("%s expects a name", c.Name)
+	default:
+		return "", "", fmt.Errorf("%s %s accepts only one version constraint", c.Name, args[0])
+	}
+}
+
+// stringArgs returns the string arguments, rendering others with to_s semantics
+func stringArgs(args []any) []string {
+	out := make([]string, 0, len(args))
+	for _, arg := range args {
+		if _, isMap := arg.(map[string]any); isMap {
+			continue // options hashes are not positional arguments
+		}
+		out = append(out, toString(arg))
+	}
+	return out
+}
+
+var (
+	versionPattern    = regexp.MustCompile(`^(\d+)\.(\d+)(?:\.(\d+))?$`)
+	constraintPattern = regexp.MustCompile(`^(~>|>=|<=|=|>|<)?\s*(\d+(?:\.\d+){0,2})$`)
+	gemRequirement    = regexp.MustCompile(`^(~>|>=|<=|!=|=|>|<)?\s*(\S+)$`)
+)
+
+// normalizeVersion validates a cookbook version the way Chef::Version does,
+// expanding x.y to x.y.0
+func normalizeVersion(s string) (string, error) {
+	m := versionPattern.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return "", fmt.Errorf("invalid cookbook version %q: must be x.y.z", s)
+	}
+	if m[3] == "" {
+		m[3] = "0"
+	}
+	return m[1] + "." + m[2] + "." + m[3], nil
+}
+
+// normalizeConstraint formats a constraint like Chef::VersionConstraint:
+// an operator (= by default), a space, then the version as written
+func normalizeConstraint(s string) (string, error) {
+	m := constraintPattern.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return "", fmt.Errorf("invalid version constraint %q", s)
+	}
+	op := m[1]
+	if op == "" {
+		op = "="
+	}
+	return op + " " + m[2], nil
+}
+
+// gemRequirements formats RubyGems requirements as "op version", sorted as Chef does
+func gemRequirements(args []string) ([]string, error) {
+	if len(args) == 0 {
+		return []string{">= 0"}, nil
+	}
+
+	requirements := make([]string, 0, len(args))
+	for _, arg := range args {
+		m := gemRequirement.FindStringSubmatch(strings.TrimSpace(arg))
+		if m == nil {
+			return nil, fmt.Errorf("invalid requirement %q", arg)
+		}
+		op := m[1]
+		if op == "" {
+			op = "="
+		}
+		requirements = append(requirements, op+" "+m[2])
+	}
+	slices.Sort(requirements)
+	return requirements, nil
+}
+
+// Validate reports problems that would make Chef Server reject the metadata
+func (m *Metadata) Validate() error {
+	if m.Name == "" {
+		return fmt.Errorf("metadata.rb does not set the cookbook name")
+	}
+	if _, err := normalizeVersion(m.Version); err != nil {
+		return err
+	}
+	return nil
+}
+
+// JSON renders the metadata as knife does: pretty-printed with two spaces
+// and without HTML escaping of constraint operators
+func (m *Metadata) JSON() ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(m); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// Compile reads metadata.rb in cookbookDir and writes metadata.json next to
+// it, returning the compiled metadata. Skipped statements are logged.
+func Compile(cookbookDir string) (*Metadata, error) {
+	m, err := ParseFile(filepath.Join(cookbookDir, "metadata.rb"))
+	if err != nil {
+		return nil, err
+	}
+	for _, warning := range m.Warnings {
+		log.Warnf("%s: %s", filepath.Join(cookbookDir, "metadata.rb"), warning)
+	}
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+
+	data, err := m.JSON()
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode metadata.json: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(cookbookDir, "metadata.json"), data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write metadata.json: %w", err)
+	}
+	return m, nil
+}
+
+// Berkshelf converts the metadata to the resolver's representation.
+// Constraints that the resolver cannot parse are skipped.
+func (m *Metadata) Berkshelf() *berkshelf.Metadata {
+	result := &berkshelf.Metadata{
+		Name:            m.Name,
+		Description:     m.Description,
+		LongDescription: m.LongDescription,
+		License:         m.License,
+		Dependencies:    constraintMap(m.Dependencies),
+		Recipes:         m.Recipes,
+		Issues:          m.IssuesURL,
+		Source:          m.SourceURL,
+	}
+	if m.Maintainer != nil {
+		result.Maintainer = *m.Maintainer
+	}
+	if m.MaintainerEmail != nil {
+		result.MaintainerEmail = *m.MaintainerEmail
+	}
+	if len(m.Platforms) > 0 {
+		result.Platforms = constraintMap(m.Platforms)
+	}
+	if len(m.Providing) > 0 {
+		result.Provides = constraintMap(m.Providing)
+	}
+	if version, err := berkshelf.NewVersion(m.Version); err == nil {
+		result.Version = version
+	}
+	if len(m.ChefVersions) > 0 {
+		if c, err := berkshelf.NewChefVersionConstraint(m.ChefVersions); err == nil {
+			result.ChefVersion = c
+		}
+	}
+	if len(m.OhaiVersions) > 0 {
+		if c, err := berkshelf.NewChefVersionConstraint(m.OhaiVersions); err == nil {
+			result.OhaiVersion = c
+		}
+	}
+	return result
+}
+
+func constraintMap(raw map[string]string) map[string]*berkshelf.Constraint {
+	constraints := make(map[string]*berkshelf.Constraint, len(raw))
+	for name, s := range raw {
+		if c, err := berkshelf.NewConstraint(s); err == nil {
+			constraints[name] = c
+		}
+	}
+	return constraints
+}
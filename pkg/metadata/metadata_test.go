@@ -0,0 +1,181 @@
+package metadata
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const sampleMetadata = `# Sample cookbook
+name              'sample'
+maintainer        'Sous Chefs'
+maintainer_email  "help@#{'sous-chefs'}.org"
+license           'Apache-2.0'
+description       'Installs sample'
+long_description  IO.read(File.join(File.dirname(__FILE__), 'README.md'))
+version           '1.2'
+source_url        'https://github.com/sous-chefs/sample' if respond_to?(:source_url)
+issues_url        'https://github.com/sous-chefs/sample/issues'
+chef_version      '>= 15.3', '< 19'
+ohai_version      '>=16'
+gem               'aws-sdk-s3', '~> 1.0'
+privacy           false
+
+depends 'apt', '~> 7.4'
+depends 'yum'
+depends('build-essential', '>=8.0')
+
+%w(ubuntu debian).each do |os|
+  supports os
+end
+
+supports 'centos', '>= 7.0'
+recipe 'sample::default', 'Installs sample'
+attribute 'sample/dir', display_name: 'Directory'
+`
+
+func writeCookbook(t *testing.T, metadataRB string) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "metadata.rb"), []byte(metadataRB), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("# sample\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestParseFile(t *testing.T) {
+	dir := writeCookbook(t, sampleMetadata)
+
+	m, err := ParseFile(filepath.Join(dir, "metadata.rb"))
+	if err != nil {
+		t.Fatalf("ParseFile() error = %v", err)
+	}
+
+	checks := []struct {
+		field string
+		got   any
+		want  any
+	}{
+		{"name", m.Name, "sample"},
+		{"maintainer", *m.Maintainer, "Sous Chefs"},
+		{"maintainer_email", *m.MaintainerEmail, "help@sous-chefs.org"},
+		{"long_description", m.LongDescription, "# sample\n"},
+		{"version", m.Version, "1.2.0"},
+		{"source_url", m.SourceURL, "https://github.com/sous-chefs/sample"},
+		{"dependencies", m.Dependencies, map[string]string{"apt": "~> 7.4", "yum": ">= 0.0.0", "build-essential": ">= 8.0"}},
+		{"platforms", m.Platforms, map[string]string{"ubuntu": ">= 0.0.0", "debian": ">= 0.0.0", "centos": ">= 7.0"}},
+		{"recipes", m.Recipes, map[string]string{"sample::default": "Installs sample"}},
+		{"chef_versions", m.ChefVersions, [][]string{{"< 19", ">= 15.3"}}},
+		{"ohai_versions", m.OhaiVersions, [][]string{{">= 16"}}},
+		{"gems", m.Gems, [][]string{{"aws-sdk-s3", "~> 1.0"}}},
+	}
+	for _, c := range checks {
+		if !reflect.DeepEqual(c.got, c.want) {
+			t.Errorf("%s = %#v, want %#v", c.field, c.got, c.want)
+		}
+	}
+
+	if len(m.Warnings) != 1 || !strings.Contains(m.Warnings[0], "attribute") {
+		t.Errorf("Warnings = %v, want one for attribute", m.Warnings)
+	}
+}
+
+func TestParse_SkipsUnsupportedStatements(t *testing.T) {
+	m, err := Parse("name 'skip'\nif true\nversion '2.0.0'\nend\nlong_description <<~EOH\n  Multi\n  line\nEOH\ndepends 'apt'\n", "/tmp/metadata.rb")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	if m.Name != "skip" || m.LongDescription != "Multi\nline\n" {
+		t.Errorf("Name = %q, LongDescription = %q", m.Name, m.LongDescription)
+	}
+	if _, ok := m.Dependencies["apt"]; !ok {
+		t.Errorf("Dependencies = %v, want apt after the heredoc", m.Dependencies)
+	}
+	if len(m.Warnings) == 0 || !strings.HasPrefix(m.Warnings[0], "line 2:") {
+		t.Errorf("Warnings = %v, want the if statement on line 2 skipped", m.Warnings)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := map[string]string{
+		"invalid version":     "name 'x'\nversion 'one'",
+		"invalid constraint":  "depends 'apt', 'latest'",
+		"multiple constraint": "depends 'apt', '>= 1.0', '< 2.0'",
+	}
+	for name, src := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Parse(src, "/tmp/metadata.rb"); err == nil {
+				t.Error("Parse() expected error")
+			}
+		})
+	}
+}
+
+func TestCompile(t *testing.T) {
+	dir := writeCookbook(t, "name 'minimal'\nversion '0.1.0'\ndepends 'apt', '>= 2.0'\n")
+
+	if _, err := Compile(dir); err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "metadata.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{
+  "name": "minimal",
+  "description": "",
+  "long_description": "",
+  "maintainer": null,
+  "maintainer_email": null,
+  "license": "All rights reserved",
+  "platforms": {},
+  "dependencies": {
+    "apt": ">= 2.0"
+  },
+  "providing": {},
+  "recipes": {},
+  "version": "0.1.0",
+  "source_url": "",
+  "issues_url": "",
+  "privacy": false,
+  "chef_versions": [],
+  "ohai_versions": [],
+  "gems": [],
+  "eager_load_libraries": true
+}`
+	if string(data) != want {
+		t.Errorf("metadata.json =\n%s\nwant\n%s", data, want)
+	}
+}
+
+func TestCompile_RequiresName(t *testing.T) {
+	dir := writeCookbook(t, "version '1.0.0'\n")
+	if _, err := Compile(dir); err == nil {
+		t.Error("Compile() expected error without a name")
+	}
+}
+
+func TestParseJSON(t *testing.T) {
+	m, err := ParseJSON([]byte(`{"name": "apt", "version": "7.4.0", "dependencies": {"compat": ">= 1.0"}}`))
+	if err != nil {
+		t.Fatalf("ParseJSON() error = %v", err)
+	}
+	if m.Name != "apt" || m.Version != "7.4.0" || m.Dependencies["compat"] != ">= 1.0" {
+		t.Errorf("ParseJSON() = %+v", m)
+	}
+	if m.License != DefaultLicense {
+		t.Errorf("License = %q, want default", m.License)
+	}
+
+	if _, err := ParseJSON([]byte("{")); err == nil {
+		t.Error("ParseJSON() expected error for invalid JSON")
+	}
+}
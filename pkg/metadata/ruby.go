@@ -0,0 +1,757 @@
+package metadata
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// call is one evaluated metadata.rb statement, e.g. depends 'apt', '>= 2.0'
+type call struct {
+	Name string
+	Args []any
+	Line int
+}
+
+// rubyParser evaluates the subset of Ruby used in metadata.rb files: method
+// calls with literal arguments, string interpolation, %w arrays, heredocs,
+// reading files relative to the cookbook, `respond_to?` modifiers and
+// `.each` loops over literal arrays. Values are string, bool, nil, []any or
+// map[string]any. Statements it cannot evaluate are skipped with a warning.
+type rubyParser struct {
+	src  string
+	pos  int
+	line int
+	// file is the metadata.rb path, used for __FILE__ and relative reads
+	file string
+	vars map[string]any
+
+	calls    []call
+	warnings []string
+}
+
+// parseRuby evaluates metadata.rb source
+func parseRuby(src, file string) ([]call, []string) {
+	p := &rubyParser{src: src, line: 1, file: file, vars: make(map[string]any)}
+	p.statements(false)
+	return p.calls, p.warnings
+}
+
+// parseError aborts the current statement
+type parseError struct {
+	msg string
+}
+
+func (p *rubyParser) fail(format string, args ...any) {
+	panic(parseError{fmt.Sprintf(format, args...)})
+}
+
+// statements evaluates statements until EOF, or until a closing "end" or "}"
+// when inBlock is set. The closing token is not consumed.
+func (p *rubyParser) statements(inBlock bool) {
+	for {
+		p.skipBlank()
+		if p.eof() {
+			return
+		}
+		if inBlock && (p.peekWord() == "end" || p.peek() == '}') {
+			return
+		}
+		p.statement()
+	}
+}
+
+// statement evaluates one statement, skipping the rest of the line on error
+func (p *rubyParser) statement() {
+	startLine := p.line
+	defer func() {
+		if r := recover(); r != nil {
+			perr, ok := r.(parseError)
+			if !ok {
+				panic(r)
+			}
+			p.warnings = append(p.warnings, fmt.Sprintf("line %d: skipped: %s", startLine, perr.msg))
+			p.skipLine()
+		}
+	}()
+
+	// Loops: %w(ubuntu debian).each do |platform| ... end
+	if c := p.peek(); c == '[' || c == '%' {
+		p.each(p.value())
+		return
+	}
+
+	name := p.ident()
+	switch name {
+	case "":
+		p.fail("unexpected %q", string(p.peek()))
+	case "if", "unless", "case", "begin", "while", "until", "def", "class", "module", "require":
+		p.fail("%s is not supported in metadata.rb", name)
+	}
+
+	if v, ok := p.vars[name]; ok && p.peek() == '.' {
+		p.each(v)
+		return
+	}
+
+	// Local variables: platforms = %w(ubuntu debian)
+	p.skipSpace()
+	if p.peek() == '=' && p.peekAt(1) != '=' && p.peekAt(1) != '>' {
+		p.pos++
+		p.skipSpace()
+		p.vars[name] = p.value()
+		p.endStatement()
+		return
+	}
+
+	var args []any
+	if p.peek() == '(' {
+		p.pos++
+		args = p.args(')')
+		p.expect(')')
+	} else if !p.atStatementEnd() && !p.atModifier() {
+		args = p.args(0)
+	}
+
+	p.skipSpace()
+	if p.atModifier() {
+		keyword := p.ident()
+		p.skipSpace()
+		cond := p.condition()
+		if (keyword == "if") != cond {
+			p.endStatement()
+			return
+		}
+	}
+	p.endStatement()
+
+	p.calls = append(p.calls, call{Name: name, Args: args, Line: startLine})
+}
+
+// each evaluates `.each do |var| ... end` or `.each { |var| ... }` over items
+func (p *rubyParser) each(items any) {
+	list, ok := items.([]any)
+	if !ok {
+		p.fail("only arrays can be iterated")
+	}
+	p.expect('.')
+	if p.ident() != "each" {
+		p.fail("only .each is supported on arrays")
+	}
+	p.skipSpace()
+
+	closing := "}"
+	if p.peek() == '{' {
+		p.pos++
+	} else if p.ident() == "do" {
+		closing = "end"
+	} else {
+		p.fail("expected a block after .each")
+	}
+
+	p.skipSpace()
+	p.expect('|')
+	p.skipSpace()
+	variable := p.ident()
+	p.skipSpace()
+	p.expect('|')
+
+	bodyPos, bodyLine := p.pos, p.line
+	previous, shadowed := p.vars[variable]
+	for _, item := range list {
+		p.pos, p.line = bodyPos, bodyLine
+		p.vars[variable] = item
+		p.statements(true)
+	}
+	if len(list) == 0 {
+		p.statements(true) // still find the end of the block
+	}
+	if shadowed {
+		p.vars[variable] = previous
+	} else {
+		delete(p.vars, variable)
+	}
+
+	p.skipBlank()
+	if closing == "end" {
+		if p.ident() != "end" {
+			p.fail("expected end")
+		}
+	} else {
+		p.expect('}')
+	}
+	p.endStatement()
+}
+
+// args parses comma-separated arguments until close (0 for end of statement).
+// Trailing key: value or key => value pairs are collected into a map.
+func (p *rubyParser) args(close byte) []any {
+	var args []any
+	var options map[string]any
+
+	for {
+		if close != 0 {
+			p.skipBlank()
+			if p.peek() == close {
+				break
+			}
+		} else {
+			p.skipSpace()
+		}
+
+		if key, ok := p.label(); ok {
+			if options == nil {
+				options = make(map[string]any)
+			}
+			p.skipBlank()
+			options[key] = p.value()
+		} else {
+			value := p.value()
+			p.skipSpace()
+			if strings.HasPrefix(p.src[p.pos:], "=>") {
+				p.pos += 2
+				p.skipBlank()
+				if options == nil {
+					options = make(map[string]any)
+				}
+				options[toString(value)] = p.value()
+			} else {
+				args = append(args, value)
+			}
+		}
+
+		p.skipSpace()
+		if p.peek() != ',' {
+			break
+		}
+		p.pos++
+		p.skipBlank() // arguments may continue on the next line
+	}
+
+	if options != nil {
+		args = append(args, options)
+	}
+	return args
+}
+
+// label consumes a `key:` hash label if one is next
+func (p *rubyParser) label() (string, bool) {
+	start, line := p.pos, p.line
+	name := p.ident()
+	if name != "" && p.peek() == ':' && p.peekAt(1) != ':' {
+		p.pos++
+		return name, true
+	}
+	p.pos, p.line = start, line
+	return "", false
+}
+
+// value parses a literal or supported expression, followed by any method chain
+func (p *rubyParser) value() any {
+	v := p.primary()
+	for p.peek() == '.' && p.peekAt(1) != '.' {
+		start := p.pos
+		p.pos++
+		method := p.ident()
+		switch method {
+		case "strip", "chomp", "to_s", "freeze", "chomp!", "strip!":
+			if s, ok := v.(string); ok {
+				switch strings.TrimSuffix(method, "!") {
+				case "strip":
+					v = strings.TrimSpace(s)
+				case "chomp":
+					v = strings.TrimSuffix(strings.TrimSuffix(s, "\n"), "\r")
+				}
+				continue
+			}
+			p.fail("%s is only supported on strings", method)
+		case "each":
+			p.pos = start // handled by the caller
+			return v
+		default:
+			p.fail("method %s is not supported", method)
+		}
+	}
+	return v
+}
+
+func (p *rubyParser) primary() any {
+	c := p.peek()
+	switch {
+	case c == '\'':
+		return p.singleQuoted()
+	case c == '"':
+		return p.doubleQuoted()
+	case c == ':' && p.peekAt(1) == '"':
+		p.pos++
+		return p.doubleQuoted()
+	case c == ':':
+		p.pos++
+		name := p.ident()
+		if name == "" {
+			p.fail("invalid symbol")
+		}
+		return name
+	case c == '%':
+		return p.percentArray()
+	case c == '[':
+		p.pos++
+		items := p.args(']')
+		p.expect(']')
+		return items
+	case c == '{':
+		p.pos++
+		args := p.args('}')
+		p.expect('}')
+		if len(args) == 0 {
+			return map[string]any{}
+		}
+		if m, ok := args[len(args)-1].(map[string]any); ok && len(args) == 1 {
+			return m
+		}
+		p.fail("invalid hash literal")
+	case c == '<' && p.peekAt(1) == '<':
+		return p.heredoc()
+	case c == '-' || (c >= '0' && c <= '9'):
+		start := p.pos
+		p.pos++
+		for !p.eof() && (isDigit(p.peek()) || p.peek() == '.' || p.peek() == '_') {
+			p.pos++
+		}
+		return p.src[start:p.pos]
+	}
+
+	name := p.ident()
+	switch name {
+	case "":
+		p.fail("unexpected %q", string(c))
+	case "true":
+		return true
+	case "false":
+		return false
+	case "nil":
+		return nil
+	case "__FILE__":
+		return p.file
+	case "__dir__":
+		return filepath.Dir(p.file)
+	case "File", "IO":
+		return p.fileCall(name)
+	case "ENV":
+		return p.env()
+	}
+
+	if v, ok := p.vars[name]; ok {
+		return v
+	}
+	p.fail("unsupported expression %s", name)
+	return nil
+}
+
+// fileCall evaluates File.join, File.dirname, File.expand_path and File/IO.read
+func (p *rubyParser) fileCall(receiver string) any {
+	p.expect('.')
+	method := p.ident()
+	p.expect('(')
+	args := p.args(')')
+	p.expect(')')
+
+	strs := make([]string, len(args))
+	for i, arg := range args {
+		s, ok := arg.(string)
+		if !ok {
+			p.fail("%s.%s expects string arguments", receiver, method)
+		}
+		strs[i] = s
+	}
+
+	switch {
+	case method == "join" && receiver == "File":
+		return filepath.Join(strs...)
+	case method == "dirname" && receiver == "File" && len(strs) == 1:
+		return filepath.Dir(strs[0])
+	case method == "expand_path" && receiver == "File" && len(strs) >= 1:
+		path := strs[0]
+		if len(strs) == 2 && !filepath.IsAbs(path) {
+			path = filepath.Join(strs[1], path)
+		}
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			p.fail("%v", err)
+		}
+		return abs
+	case method == "read" && len(strs) >= 1:
+		path := strs[0]
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(p.file), path)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			p.fail("%s.read: %v", receiver, err)
+		}
+		return string(data)
+	}
+	p.fail("%s.%s is not supported", receiver, method)
+	return nil
+}
+
+// env evaluates ENV['NAME'] and ENV.fetch('NAME', default)
+func (p *rubyParser) env() any {
+	var args []any
+	switch p.peek() {
+	case '[':
+		p.pos++
+		args = p.args(']')
+		p.expect(']')
+	case '.':
+		p.pos++
+		if p.ident() != "fetch" {
+			p.fail("only ENV[] and ENV.fetch are supported")
+		}
+		p.expect('(')
+		args = p.args(')')
+		p.expect(')')
+	default:
+		p.fail("only ENV[] and ENV.fetch are supported")
+	}
+
+	if len(args) == 0 {
+		p.fail("missing environment variable name")
+	}
+	if value, ok := os.LookupEnv(toString(args[0])); ok {
+		return value
+	}
+	if len(args) > 1 {
+		return args[1]
+	}
+	return nil
+}
+
+// condition evaluates the condition of an if/unless modifier. Only
+// respond_to?(:method), which guards newer metadata fields, is understood.
+func (p *rubyParser) condition() bool {
+	name := p.ident()
+	if name != "respond_to?" {
+		p.fail("unsupported condition %s", name)
+	}
+	if p.peek() == '(' {
+		p.pos++
+		p.args(')')
+		p.expect(')')
+	} else {
+		p.args(0)
+	}
+	return true
+}
+
+func (p *rubyParser) singleQuoted() string {
+	p.pos++ // opening quote
+	var b strings.Builder
+	for !p.eof() {
+		c := p.next()
+		switch {
+		case c == '\\' && (p.peek() == '\'' || p.peek() == '\\'):
+			b.WriteByte(p.next())
+		case c == '\'':
+			return b.String()
+		default:
+			b.WriteByte(c)
+		}
+	}
+	p.fail("unterminated string")
+	return ""
+}
+
+func (p *rubyParser) doubleQuoted() string {
+	p.pos++ // opening quote
+	var b strings.Builder
+	for !p.eof() {
+		c := p.next()
+		switch {
+		case c == '\\':
+			switch e := p.next(); e {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			default:
+				b.WriteByte(e)
+			}
+		case c == '#' && p.peek() == '{':
+			p.pos++
+			p.skipBlank()
+			b.WriteString(toString(p.value()))
+			p.skipBlank()
+			p.expect('}')
+		case c == '"':
+			return b.String()
+		default:
+			b.WriteByte(c)
+		}
+	}
+	p.fail("unterminated string")
+	return ""
+}
+
+// percentArray parses %w() and %i() word arrays with any bracket pair
+func (p *rubyParser) percentArray() []any {
+	p.pos++ // %
+	kind := p.next()
+	if kind != 'w' && kind != 'W' && kind != 'i' && kind != 'I' {
+		p.fail("unsupported %%%c literal", kind)
+	}
+
+	open := p.next()
+	closers := map[byte]byte{'(': ')', '[': ']', '{': '}', '<': '>'}
+	close, ok := closers[open]
+	if !ok {
+		close = open
+	}
+
+	end := strings.IndexByte(p.src[p.pos:], close)
+	if end < 0 {
+		p.fail("unterminated %%%c literal", kind)
+	}
+	body := p.src[p.pos : p.pos+end]
+	p.line += strings.Count(body, "\n")
+	p.pos += end + 1
+
+	var items []any
+	for _, word := range strings.Fields(body) {
+		items = append(items, word)
+	}
+	return items
+}
+
+// heredoc parses <<ID, <<-ID and <<~ID. The body starts on the next line, so
+// it is cut out of the source and the rest of the current line continues.
+func (p *rubyParser) heredoc() string {
+	p.pos += 2
+	squiggly := false
+	switch p.peek() {
+	case '~':
+		squiggly = true
+		p.pos++
+	case '-':
+		p.pos++
+	}
+
+	quote := p.peek()
+	if quote == '\'' || quote == '"' {
+		p.pos++
+	}
+	id := p.ident()
+	if id == "" {
+		p.fail("invalid heredoc")
+	}
+	if quote == '\'' || quote == '"' {
+		p.expect(quote)
+	}
+
+	lineEnd := strings.IndexByte(p.src[p.pos:], '\n')
+	if lineEnd < 0 {
+		p.fail("unterminated heredoc")
+	}
+	bodyStart := p.pos + lineEnd + 1
+
+	var lines []string
+	cursor := bodyStart
+	for {
+		if cursor >= len(p.src) {
+			p.fail("unterminated heredoc %s", id)
+		}
+		next := strings.IndexByte(p.src[cursor:], '\n')
+		lineText := p.src[cursor:]
+		if next >= 0 {
+			lineText = p.src[cursor : cursor+next]
+		}
+		cursor += len(lineText) + 1
+		if strings.TrimSpace(lineText) == id {
+			break
+		}
+		lines = append(lines, lineText)
+	}
+
+	if squiggly {
+		lines = dedent(lines)
+	}
+	body := strings.Join(lines, "\n")
+	if len(lines) > 0 {
+		body += "\n"
+	}
+
+	// Blank out the body so parsing continues after the heredoc marker with
+	// line numbers intact
+	cut := min(cursor, len(p.src))
+	p.src = p.src[:bodyStart] + strings.Repeat("\n", strings.Count(p.src[bodyStart:cut], "\n")) + p.src[cut:]
+	return body
+}
+
+// dedent removes the indentation common to all non-blank lines
+func dedent(lines []string) []string {
+	indent := -1
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		n := len(line) - len(strings.TrimLeft(line, " \t"))
+		if indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	if indent <= 0 {
+		return lines
+	}
+
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		if len(line) >= indent {
+			out[i] = line[indent:]
+		} else {
+			out[i] = strings.TrimLeft(line, " \t")
+		}
+	}
+	return out
+}
+
+// ident consumes an identifier, including a trailing ? or !
+func (p *rubyParser) ident() string {
+	start := p.pos
+	for !p.eof() {
+		c := rune(p.peek())
+		if c == '_' || unicode.IsLetter(c) || (p.pos > start && unicode.IsDigit(c)) {
+			p.pos++
+			continue
+		}
+		break
+	}
+	if p.pos > start && (p.peek() == '?' || p.peek() == '!') {
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+func (p *rubyParser) peekWord() string {
+	start, line := p.pos, p.line
+	word := p.ident()
+	p.pos, p.line = start, line
+	return word
+}
+
+func (p *rubyParser) atModifier() bool {
+	word := p.peekWord()
+	return word == "if" || word == "unless"
+}
+
+func (p *rubyParser) atStatementEnd() bool {
+	switch p.peek() {
+	case 0, '\n', ';', '#', '}':
+		return true
+	}
+	return p.peekWord() == "end"
+}
+
+// endStatement requires the statement to end here
+func (p *rubyParser) endStatement() {
+	p.skipSpace()
+	if !p.atStatementEnd() {
+		p.fail("unexpected %q", p.restOfLine())
+	}
+}
+
+func (p *rubyParser) restOfLine() string {
+	rest := p.src[p.pos:]
+	if i := strings.IndexByte(rest, '\n'); i >= 0 {
+		rest = rest[:i]
+	}
+	return strings.TrimSpace(rest)
+}
+
+func (p *rubyParser) expect(c byte) {
+	if p.peek() != c {
+		p.fail("expected %q", string(c))
+	}
+	p.pos++
+}
+
+// skipSpace skips spaces, tabs and escaped newlines
+func (p *rubyParser) skipSpace() {
+	for !p.eof() {
+		switch {
+		case p.peek() == ' ' || p.peek() == '\t' || p.peek() == '\r':
+			p.pos++
+		case p.peek() == '\\' && p.peekAt(1) == '\n':
+			p.pos += 2
+			p.line++
+		default:
+			return
+		}
+	}
+}
+
+// skipBlank skips whitespace, newlines, semicolons and comments
+func (p *rubyParser) skipBlank() {
+	for !p.eof() {
+		p.skipSpace()
+		switch p.peek() {
+		case '\n':
+			p.pos++
+			p.line++
+		case ';':
+			p.pos++
+		case '#':
+			p.skipComment()
+		default:
+			return
+		}
+	}
+}
+
+func (p *rubyParser) skipComment() {
+	for !p.eof() && p.peek() != '\n' {
+		p.pos++
+	}
+}
+
+// skipLine skips to the start of the next line
+func (p *rubyParser) skipLine() {
+	for !p.eof() && p.peek() != '\n' {
+		p.pos++
+	}
+}
+
+func (p *rubyParser) eof() bool { return p.pos >= len(p.src) }
+
+func (p *rubyParser) peek() byte { return p.peekAt(0) }
+
+func (p *rubyParser) peekAt(offset int) byte {
+	if p.pos+offset >= len(p.src) {
+		return 0
+	}
+	return p.src[p.pos+offset]
+}
+
+func (p *rubyParser) next() byte {
+	c := p.peek()
+	p.pos++
+	if c == '\n' {
+		p.line++
+	}
+	return c
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+// toString renders a value the way Ruby's to_s would for literals
+func toString(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,97 @@
+// Package metrics records counters and histograms on the health of cookbook
+// resolution, for export to Prometheus or an OpenTelemetry collector. Nothing
+// is recorded until a Recorder is set.
+package metrics
+
+import "sync"
+
+// Metric names
+const (
+	// VersionsFetched counts the cookbook versions listed by sources
+	VersionsFetched = "berks_versions_fetched_total"
+	// CacheHits and CacheMisses count lookups in each cache
+	CacheHits   = "berks_cache_hits_total"
+	CacheMisses = "berks_cache_misses_total"
+	// ResolutionDuration is how long each dependency resolution took
+	ResolutionDuration = "berks_resolution_duration_seconds"
+	// DownloadBytes counts the bytes of cookbook tarballs downloaded
+	DownloadBytes = "berks_download_bytes_total"
+	// Retries counts the requests sent again after a failure
+	Retries = "berks_retries_total"
+	// CandidatesTruncated counts the versions satisfying a cookbook's
+	// constraints that resolution left out because of the candidate cap
+	CandidatesTruncated = "berks_candidates_truncated_total"
+)
+
+// descriptions are the help text and unit of each metric
+var descriptions = map[string]struct{ help, unit string }{
+	VersionsFetched:     {"Cookbook versions listed by sources", "1"},
+	CacheHits:           {"Lookups answered by a cache", "1"},
+	CacheMisses:         {"Lookups a cache could not answer", "1"},
+	ResolutionDuration:  {"Duration of dependency resolutions", "s"},
+	DownloadBytes:       {"Bytes of cookbook tarballs downloaded", "By"},
+	Retries:             {"Requests retried after a failure", "1"},
+	CandidatesTruncated: {"Satisfying cookbook versions left out by the candidate cap", "1"},
+}
+
+// Label is a dimension of a measurement
+type Label struct {
+	Key   string
+	Value string
+}
+
+// L returns a label
+func L(key, value string) Label {
+	return Label{Key: key, Value: value}
+}
+
+// Recorder receives measurements. Implementations must be safe for
+// concurrent use.
+type Recorder interface {
+	// Add increases a counter
+	Add(name string, value float64, labels ...Label)
+	// Observe records a value in a histogram
+	Observe(name string, value float64, labels ...Label)
+}
+
+var (
+	mu       sync.RWMutex
+	recorder Recorder
+)
+
+// Set makes r receive the measurements of the process; nil stops recording
+func Set(r Recorder) {
+	mu.Lock()
+	defer mu.Unlock()
+	recorder = r
+}
+
+// current returns the recorder, if any
+func current() Recorder {
+	mu.RLock()
+	defer mu.RUnlock()
+	return recorder
+}
+
+// Add increases a counter of the recorder, if any
+func Add(name string, value float64, labels ...Label) {
+	if r := current(); r != nil {
+		r.Add(name, value, labels...)
+	}
+}
+
+// Observe records a value in a histogram of the recorder, if any
+func Observe(name string, value float64, labels ...Label) {
+	if r := current(); r != nil {
+		r.Observe(name, value, labels...)
+	}
+}
+
+// Lookup counts a cache lookup as a hit or a miss of cache
+func Lookup(cache string, hit bool) {
+	if hit {
+		Add(CacheHits, 1, L("cache", cache))
+	} else {
+		Add(CacheMisses, 1, L("cache", cache))
+	}
+}
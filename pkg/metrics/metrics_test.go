@@ -0,0 +1,137 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goccy/go-json"
+)
+
+func recorded() *Registry {
+	r := NewRegistry()
+	r.Add(CacheHits, 1, L("cache", "versions"))
+	r.Add(CacheHits, 2, L("cache", "versions"))
+	r.Add(CacheMisses, 1, L("cache", "versions"))
+	r.Observe(ResolutionDuration, 0.2, L("result", "success"))
+	r.Observe(ResolutionDuration, 3, L("result", "success"))
+	return r
+}
+
+func TestGlobalRecorder(t *testing.T) {
+	Set(nil)
+	Add(CacheHits, 1) // must not panic
+
+	r := NewRegistry()
+	Set(r)
+	defer Set(nil)
+	Lookup("versions", true)
+	Lookup("versions", false)
+	Lookup("versions", true)
+
+	var out bytes.Buffer
+	if err := r.WritePrometheus(&out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`berks_cache_hits_total{cache="versions"} 2`,
+		`berks_cache_misses_total{cache="versions"} 1`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestWritePrometheus(t *testing.T) {
+	var out bytes.Buffer
+	if err := recorded().WritePrometheus(&out); err != nil {
+		t.Fatal(err)
+	}
+	got := out.String()
+	for _, want := range []string{
+		"# TYPE berks_cache_hits_total counter\n",
+		`berks_cache_hits_total{cache="versions"} 3` + "\n",
+		"# TYPE berks_resolution_duration_seconds histogram\n",
+		`berks_resolution_duration_seconds_bucket{result="success",le="0.1"} 0` + "\n",
+		`berks_resolution_duration_seconds_bucket{result="success",le="0.25"} 1` + "\n",
+		`berks_resolution_duration_seconds_bucket{result="success",le="5"} 2` + "\n",
+		`berks_resolution_duration_seconds_bucket{result="success",le="+Inf"} 2` + "\n",
+		`berks_resolution_duration_seconds_sum{result="success"} 3.2` + "\n",
+		`berks_resolution_duration_seconds_count{result="success"} 2` + "\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Count(got, "# TYPE berks_cache_hits_total") != 1 {
+		t.Errorf("metric described more than once:\n%s", got)
+	}
+}
+
+func TestLabelOrder(t *testing.T) {
+	r := NewRegistry()
+	r.Add(Retries, 1, L("operation", "download"), L("host", "a"))
+	r.Add(Retries, 1, L("host", "a"), L("operation", "download"))
+	if s := r.snapshot(); len(s) != 1 || s[0].value != 2 {
+		t.Errorf("snapshot = %+v; want one series of 2", s)
+	}
+}
+
+func TestExportOTLP(t *testing.T) {
+	var body []byte
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		header = r.Header
+	}))
+	defer server.Close()
+
+	err := recorded().ExportOTLP(context.Background(), server.URL, map[string]string{"Authorization": "Bearer token"})
+	if err != nil {
+		t.Fatalf("ExportOTLP() error = %v", err)
+	}
+	if header.Get("Content-Type") != "application/json" || header.Get("Authorization") != "Bearer token" {
+		t.Errorf("headers = %v", header)
+	}
+
+	var request otlpRequest
+	if err := json.Unmarshal(body, &request); err != nil {
+		t.Fatalf("invalid request body: %v", err)
+	}
+	metrics := request.ResourceMetrics[0].ScopeMetrics[0].Metrics
+	if len(metrics) != 3 {
+		t.Fatalf("got %d metrics, want 3", len(metrics))
+	}
+	hits := metrics[0]
+	if hits.Name != CacheHits || hits.Sum == nil || hits.Sum.DataPoints[0].AsDouble != 3 || !hits.Sum.IsMonotonic {
+		t.Errorf("hits = %+v", hits)
+	}
+	duration := metrics[2]
+	if duration.Name != ResolutionDuration || duration.Histogram == nil {
+		t.Fatalf("duration = %+v", duration)
+	}
+	point := duration.Histogram.DataPoints[0]
+	if point.Count != "2" || point.Sum != 3.2 || len(point.BucketCounts) != len(point.ExplicitBounds)+1 {
+		t.Errorf("duration point = %+v", point)
+	}
+}
+
+func TestExportOTLP_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad payload", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := recorded().ExportOTLP(ctx, server.URL, nil)
+	if err == nil || !strings.Contains(err.Error(), "HTTP 400 bad payload") {
+		t.Errorf("ExportOTLP() error = %v; want HTTP 400", err)
+	}
+}
@@ -0,0 +1,171 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/goccy/go-json"
+)
+
+// DefaultOTLPEndpoint is where an OpenTelemetry collector receives metrics
+// over OTLP/HTTP by default
+const DefaultOTLPEndpoint = "http://localhost:4318/v1/metrics"
+
+// OTLP payloads, in the JSON encoding of the OTLP/HTTP protocol. 64-bit
+// integers are encoded as strings.
+type (
+	otlpRequest struct {
+		ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+	}
+	otlpResourceMetrics struct {
+		Resource     otlpResource       `json:"resource"`
+		ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+	}
+	otlpResource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	}
+	otlpScopeMetrics struct {
+		Scope   otlpScope    `json:"scope"`
+		Metrics []otlpMetric `json:"metrics"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpMetric struct {
+		Name        string         `json:"name"`
+		Description string         `json:"description,omitempty"`
+		Unit        string         `json:"unit,omitempty"`
+		Sum         *otlpSum       `json:"sum,omitempty"`
+		Histogram   *otlpHistogram `json:"histogram,omitempty"`
+	}
+	otlpSum struct {
+		DataPoints             []otlpNumberPoint `json:"dataPoints"`
+		AggregationTemporality int               `json:"aggregationTemporality"`
+		IsMonotonic            bool              `json:"isMonotonic"`
+	}
+	otlpNumberPoint struct {
+		Attributes        []otlpAttribute `json:"attributes,omitempty"`
+		StartTimeUnixNano string          `json:"startTimeUnixNano"`
+		TimeUnixNano      string          `json:"timeUnixNano"`
+		AsDouble          float64         `json:"asDouble"`
+	}
+	otlpHistogram struct {
+		DataPoints             []otlpHistogramPoint `json:"dataPoints"`
+		AggregationTemporality int                  `json:"aggregationTemporality"`
+	}
+	otlpHistogramPoint struct {
+		Attributes        []otlpAttribute `json:"attributes,omitempty"`
+		StartTimeUnixNano string          `json:"startTimeUnixNano"`
+		TimeUnixNano      string          `json:"timeUnixNano"`
+		Count             string          `json:"count"`
+		Sum               float64         `json:"sum"`
+		BucketCounts      []string        `json:"bucketCounts"`
+		ExplicitBounds    []float64       `json:"explicitBounds"`
+	}
+	otlpAttribute struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+	otlpValue struct {
+		StringValue string `json:"stringValue"`
+	}
+)
+
+// cumulative is the OTLP aggregation temporality of totals since the start
+const cumulative = 2
+
+// ExportOTLP sends the metrics to an OpenTelemetry collector at endpoint, a
+// full OTLP/HTTP metrics URL such as DefaultOTLPEndpoint. headers are added
+// to the request, e.g. for authentication.
+func (r *Registry) ExportOTLP(ctx context.Context, endpoint string, headers map[string]string) error {
+	body, err := json.Marshal(r.otlpRequest(time.Now()))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating OTLP request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("exporting metrics: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("exporting metrics: HTTP %d %s", resp.StatusCode, bytes.TrimSpace(message))
+	}
+	return nil
+}
+
+// otlpRequest returns the metrics as an OTLP export request at now
+func (r *Registry) otlpRequest(now time.Time) otlpRequest {
+	start, end := strconv.FormatInt(r.start.UnixNano(), 10), strconv.FormatInt(now.UnixNano(), 10)
+	var metrics []otlpMetric
+	for _, s := range r.snapshot() {
+		if len(metrics) == 0 || metrics[len(metrics)-1].Name != s.name {
+			metrics = append(metrics, otlpMetric{
+				Name:        s.name,
+				Description: descriptions[s.name].help,
+				Unit:        descriptions[s.name].unit,
+			})
+		}
+		metric := &metrics[len(metrics)-1]
+
+		attributes := make([]otlpAttribute, len(s.labels))
+		for i, l := range s.labels {
+			attributes[i] = otlpAttribute{Key: l.Key, Value: otlpValue{StringValue: l.Value}}
+		}
+
+		if s.counts == nil {
+			if metric.Sum == nil {
+				metric.Sum = &otlpSum{AggregationTemporality: cumulative, IsMonotonic: true}
+			}
+			metric.Sum.DataPoints = append(metric.Sum.DataPoints, otlpNumberPoint{
+				Attributes:        attributes,
+				StartTimeUnixNano: start,
+				TimeUnixNano:      end,
+				AsDouble:          s.value,
+			})
+			continue
+		}
+
+		if metric.Histogram == nil {
+			metric.Histogram = &otlpHistogram{AggregationTemporality: cumulative}
+		}
+		buckets := make([]string, len(s.counts))
+		for i, count := range s.counts {
+			buckets[i] = strconv.FormatUint(count, 10)
+		}
+		metric.Histogram.DataPoints = append(metric.Histogram.DataPoints, otlpHistogramPoint{
+			Attributes:        attributes,
+			StartTimeUnixNano: start,
+			TimeUnixNano:      end,
+			Count:             strconv.FormatUint(s.count, 10),
+			Sum:               s.sum,
+			BucketCounts:      buckets,
+			ExplicitBounds:    DefaultBuckets,
+		})
+	}
+
+	return otlpRequest{ResourceMetrics: []otlpResourceMetrics{{
+		Resource: otlpResource{Attributes: []otlpAttribute{
+			{Key: "service.name", Value: otlpValue{StringValue: "berks"}},
+		}},
+		ScopeMetrics: []otlpScopeMetrics{{
+			Scope:   otlpScope{Name: "github.com/bdwyertech/go-berkshelf"},
+			Metrics: metrics,
+		}},
+	}}}
+}
@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// WritePrometheus writes the metrics in the Prometheus text exposition
+// format, e.g. for the node_exporter textfile collector
+func (r *Registry) WritePrometheus(w io.Writer) error {
+	out := bufio.NewWriter(w)
+	var last string
+	for _, s := range r.snapshot() {
+		histogram := s.counts != nil
+		if s.name != last {
+			last = s.name
+			kind := "counter"
+			if histogram {
+				kind = "histogram"
+			}
+			fmt.Fprintf(out, "# HELP %s %s\n# TYPE %s %s\n", s.name, descriptions[s.name].help, s.name, kind)
+		}
+
+		if !histogram {
+			fmt.Fprintf(out, "%s%s %s\n", s.name, promLabels(s.labels), promValue(s.value))
+			continue
+		}
+		var cumulative uint64
+		for i, count := range s.counts {
+			cumulative += count
+			le := "+Inf"
+			if i < len(DefaultBuckets) {
+				le = promValue(DefaultBuckets[i])
+			}
+			fmt.Fprintf(out, "%s_bucket%s %d\n", s.name, promLabels(s.labels, L("le", le)), cumulative)
+		}
+		fmt.Fprintf(out, "%s_sum%s %s\n", s.name, promLabels(s.labels), promValue(s.sum))
+		fmt.Fprintf(out, "%s_count%s %d\n", s.name, promLabels(s.labels), s.count)
+	}
+	return out.Flush()
+}
+
+// promLabels renders labels as {key="value",...}
+func promLabels(labels []Label, extra ...Label) string {
+	all := append(labels[:len(labels):len(labels)], extra...)
+	if len(all) == 0 {
+		return ""
+	}
+	parts := make([]string, len(all))
+	for i, l := range all {
+		value := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(l.Value)
+		parts[i] = fmt.Sprintf(`%s="%s"`, l.Key, value)
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func promValue(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
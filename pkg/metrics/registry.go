@@ -0,0 +1,94 @@
+package metrics
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets are the upper bounds of histogram buckets, suited to
+// durations in seconds
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120}
+
+// Registry is a Recorder that aggregates measurements in memory until they
+// are exported
+type Registry struct {
+	mu     sync.Mutex
+	start  time.Time
+	series map[string]*series
+}
+
+// series is the aggregate of one metric with one set of labels
+type series struct {
+	name   string
+	labels []Label
+	// value is the total of a counter
+	value float64
+	// counts holds a histogram's observations per bucket of DefaultBuckets,
+	// with the last for those above every bound
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// NewRegistry returns an empty registry
+func NewRegistry() *Registry {
+	return &Registry{start: time.Now(), series: make(map[string]*series)}
+}
+
+// Add implements Recorder
+func (r *Registry) Add(name string, value float64, labels ...Label) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.get(name, labels).value += value
+}
+
+// Observe implements Recorder
+func (r *Registry) Observe(name string, value float64, labels ...Label) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.get(name, labels)
+	if s.counts == nil {
+		s.counts = make([]uint64, len(DefaultBuckets)+1)
+	}
+	i, _ := slices.BinarySearch(DefaultBuckets, value)
+	s.counts[i]++
+	s.sum += value
+	s.count++
+}
+
+// get returns the series of name and labels, creating it. Callers hold mu.
+func (r *Registry) get(name string, labels []Label) *series {
+	labels = slices.SortedFunc(slices.Values(labels), func(a, b Label) int { return cmp.Compare(a.Key, b.Key) })
+	var key strings.Builder
+	key.WriteString(name)
+	for _, l := range labels {
+		key.WriteString("\xff" + l.Key + "=" + l.Value)
+	}
+	s, ok := r.series[key.String()]
+	if !ok {
+		s = &series{name: name, labels: labels}
+		r.series[key.String()] = s
+	}
+	return s
+}
+
+// snapshot returns copies of the series, by name and then labels
+func (r *Registry) snapshot() []series {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	all := make([]series, 0, len(r.series))
+	for _, s := range r.series {
+		copied := *s
+		copied.counts = slices.Clone(s.counts)
+		all = append(all, copied)
+	}
+	slices.SortFunc(all, func(a, b series) int {
+		return cmp.Or(cmp.Compare(a.name, b.name), slices.CompareFunc(a.labels, b.labels, func(x, y Label) int {
+			return cmp.Or(cmp.Compare(x.Key, y.Key), cmp.Compare(x.Value, y.Value))
+		}))
+	})
+	return all
+}
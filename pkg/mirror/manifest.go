@@ -0,0 +1,123 @@
+package mirror
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// DefaultManifestFile is the manifest written when no path is given
+const DefaultManifestFile = "berks-mirror.json"
+
+// Entry statuses
+const (
+	// StatusPublished means the cookbook version was uploaded to the target
+	StatusPublished = "published"
+	// StatusPresent means the target already had the cookbook version
+	StatusPresent = "present"
+)
+
+// Entry records one synced cookbook version
+type Entry struct {
+	Name     string    `json:"name"`
+	Version  string    `json:"version"`
+	Status   string    `json:"status"`
+	SHA256   string    `json:"sha256,omitempty"`
+	Digest   string    `json:"digest,omitempty"`
+	Size     int       `json:"size,omitempty"`
+	SyncedAt time.Time `json:"synced_at"`
+}
+
+// Manifest lists the cookbook versions synced from one source to another.
+// It is saved after every cookbook, so an interrupted mirror resumes where
+// it stopped.
+type Manifest struct {
+	From    string  `json:"from"`
+	To      string  `json:"to"`
+	Entries []Entry `json:"entries"`
+
+	path string
+}
+
+// LoadManifest reads the manifest at path for a mirror from one source to
+// another. A missing file, or a manifest for a different pair of sources,
+// starts an empty manifest.
+func LoadManifest(path, from, to string) (*Manifest, error) {
+	fresh := &Manifest{From: from, To: to, Entries: []Entry{}, path: path}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return fresh, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest %s: %w", path, err)
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest %s: %w", path, err)
+	}
+	if manifest.From != from || manifest.To != to {
+		log.Warnf("Manifest %s is for %s -> %s; starting a new one", path, manifest.From, manifest.To)
+		return fresh, nil
+	}
+	manifest.path = path
+	return &manifest, nil
+}
+
+// Has reports whether a cookbook version has already been synced
+func (m *Manifest) Has(name, version string) bool {
+	for _, entry := range m.Entries {
+		if entry.Name == name && entry.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+// Record adds an entry and saves the manifest
+func (m *Manifest) Record(entry Entry) error {
+	m.Entries = append(m.Entries, entry)
+	return m.Save()
+}
+
+// Save writes the manifest, sorted by cookbook and version, replacing the
+// previous file atomically
+func (m *Manifest) Save() error {
+	sort.SliceStable(m.Entries, func(i, j int) bool {
+		if m.Entries[i].Name != m.Entries[j].Name {
+			return m.Entries[i].Name < m.Entries[j].Name
+		}
+		return m.Entries[i].Version < m.Entries[j].Version
+	})
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(m.path), ".berks-mirror-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), m.path); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return nil
+}
+
+// Path returns the manifest file path
+func (m *Manifest) Path() string {
+	return m.path
+}
@@ -0,0 +1,226 @@
+// Package mirror replicates pinned cookbook versions from one
+// Supermarket-compatible source to another, for seeding private or
+// air-gapped Supermarkets and Artifactory repositories.
+package mirror
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	"github.com/bdwyertech/go-berkshelf/pkg/lockfile"
+	"github.com/bdwyertech/go-berkshelf/pkg/logging"
+	"github.com/bdwyertech/go-berkshelf/pkg/publish"
+	"github.com/bdwyertech/go-berkshelf/pkg/source"
+)
+
+var log = logging.For("mirror")
+
+// Item is a cookbook to mirror. An empty Version mirrors the latest version
+// on the origin.
+type Item struct {
+	Name    string
+	Version string
+}
+
+// String returns "name version", or just the name when unpinned
+func (i Item) String() string {
+	if i.Version == "" {
+		return i.Name
+	}
+	return i.Name + " " + i.Version
+}
+
+// ParseList reads one cookbook per line as "name" or "name version".
+// Blank lines and lines starting with # are ignored.
+func ParseList(r io.Reader) ([]Item, error) {
+	var items []Item
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		switch len(fields) {
+		case 1:
+			items = append(items, Item{Name: fields[0]})
+		case 2:
+			if _, err := berkshelf.NewVersion(fields[1]); err != nil {
+				return nil, fmt.Errorf("line %d: invalid version %q", line, fields[1])
+			}
+			items = append(items, Item{Name: fields[0], Version: fields[1]})
+		default:
+			return nil, fmt.Errorf("line %d: expected \"name [version]\", got %q", line, text)
+		}
+	}
+	return items, scanner.Err()
+}
+
+// ItemsFromLockFile returns the cookbooks pinned in a lock file, sorted by
+// name. Git and path cookbooks are not published anywhere and are returned
+// separately as skipped.
+func ItemsFromLockFile(lf *lockfile.LockFile) (items []Item, skipped []string) {
+	for _, src := range lf.Sources {
+		for name, cookbook := range src.Cookbooks {
+			sourceType := src.Type
+			if cookbook.Source != nil && cookbook.Source.Type != "" {
+				sourceType = cookbook.Source.Type
+			}
+			switch sourceType {
+			case "git", "path":
+				skipped = append(skipped, name)
+			default:
+				items = append(items, Item{Name: name, Version: cookbook.Version})
+			}
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Name < items[j].Name })
+	sort.Strings(skipped)
+	return items, skipped
+}
+
+// Options configures a mirror
+type Options struct {
+	// Publish configures the upload to the target source
+	Publish publish.Options
+	// Manifest records synced versions; versions already in it are skipped
+	Manifest *Manifest
+}
+
+// Failure is a cookbook that could not be mirrored
+type Failure struct {
+	Item  Item
+	Error error
+}
+
+// Result summarizes a mirror run
+type Result struct {
+	// Synced are the versions published or found on the target in this run
+	Synced []Entry
+	// Skipped are the versions already recorded in the manifest
+	Skipped []Item
+	// Failed are the versions that could not be mirrored
+	Failed []Failure
+}
+
+// Mirrorer copies cookbooks from an origin source to a publish target
+type Mirrorer struct {
+	origin  source.CookbookSource
+	options Options
+}
+
+// New creates a Mirrorer reading from origin
+func New(origin source.CookbookSource, options Options) *Mirrorer {
+	return &Mirrorer{origin: origin, options: options}
+}
+
+// Mirror syncs each item in turn. A failed item is recorded and the rest are
+// still attempted; an error is only returned if the manifest cannot be saved
+// or ctx is cancelled.
+func (m *Mirrorer) Mirror(ctx context.Context, items []Item) (*Result, error) {
+	result := &Result{}
+	for _, item := range items {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		version, err := m.pin(ctx, item)
+		if err != nil {
+			result.Failed = append(result.Failed, Failure{Item: item, Error: err})
+			continue
+		}
+		pinned := Item{Name: item.Name, Version: version.String()}
+
+		if m.options.Manifest != nil && m.options.Manifest.Has(pinned.Name, pinned.Version) {
+			log.WithField(logging.CookbookField, pinned.Name).Debugf("Skipping %s, already in the manifest", pinned)
+			result.Skipped = append(result.Skipped, pinned)
+			continue
+		}
+
+		entry, err := m.mirrorOne(ctx, pinned.Name, version)
+		if err != nil {
+			result.Failed = append(result.Failed, Failure{Item: pinned, Error: err})
+			continue
+		}
+		result.Synced = append(result.Synced, *entry)
+
+		if m.options.Manifest != nil && !m.options.Publish.DryRun {
+			if err := m.options.Manifest.Record(*entry); err != nil {
+				return result, err
+			}
+		}
+	}
+	return result, nil
+}
+
+// pin returns the version to mirror for item, looking up the latest version
+// on the origin when the item is unpinned
+func (m *Mirrorer) pin(ctx context.Context, item Item) (*berkshelf.Version, error) {
+	if item.Version != "" {
+		return berkshelf.NewVersion(item.Version)
+	}
+
+	versions, err := m.origin.ListVersions(ctx, item.Name)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, &source.ErrCookbookNotFound{Name: item.Name}
+	}
+	latest := versions[0]
+	for _, v := range versions[1:] {
+		if v.GreaterThan(latest) {
+			latest = v
+		}
+	}
+	return latest, nil
+}
+
+// mirrorOne downloads a cookbook version from the origin and publishes it
+func (m *Mirrorer) mirrorOne(ctx context.Context, name string, version *berkshelf.Version) (*Entry, error) {
+	cookbook, err := m.origin.FetchCookbook(ctx, name, version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch from %s: %w", m.origin.GetSourceURL(), err)
+	}
+
+	staging, err := os.MkdirTemp("", "berks-mirror-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(staging)
+
+	dir := filepath.Join(staging, name)
+	if err := m.origin.DownloadAndExtractCookbook(ctx, cookbook, dir); err != nil {
+		return nil, fmt.Errorf("failed to download from %s: %w", m.origin.GetSourceURL(), err)
+	}
+
+	entry := &Entry{Name: name, Version: version.String(), SyncedAt: time.Now().UTC()}
+	published, err := publish.Publish(ctx, dir, m.options.Publish)
+	var already *publish.ErrAlreadyPublished
+	switch {
+	case errors.As(err, &already):
+		log.WithField(logging.CookbookField, name).Debugf("%s %s is already on the target", name, version)
+		entry.Status = StatusPresent
+		return entry, nil
+	case err != nil:
+		return nil, err
+	}
+
+	entry.Status = StatusPublished
+	entry.SHA256 = published.SHA256
+	entry.Digest = published.Digest
+	entry.Size = published.Size
+	return entry, nil
+}
@@ -0,0 +1,207 @@
+package mirror
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/lockfile"
+	"github.com/bdwyertech/go-berkshelf/pkg/publish"
+	"github.com/bdwyertech/go-berkshelf/pkg/source"
+)
+
+// nopAuth satisfies publish.Authenticator without credentials
+type nopAuth struct{}
+
+func (nopAuth) Authenticate(req *http.Request, body []byte) error { return nil }
+
+func cookbookTarball(t *testing.T, name, version string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	files := map[string]string{
+		name + "/metadata.json":      fmt.Sprintf(`{"name": %q, "version": %q}`, name, version),
+		name + "/recipes/default.rb": "log 'hello'\n",
+	}
+	for path, body := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: path, Mode: 0644, Size: int64(len(body)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(body))
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+// newOrigin serves apt 7.3.0 and 7.4.0 and nginx 2.7.6 through the Supermarket API
+func newOrigin(t *testing.T) *httptest.Server {
+	t.Helper()
+	versions := map[string][]string{"apt": {"7.3.0", "7.4.0"}, "nginx": {"2.7.6"}}
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/"), "/")
+		switch {
+		case len(parts) == 4 && parts[0] == "api" && parts[2] == "cookbooks":
+			name := parts[3]
+			var urls []string
+			for _, v := range versions[name] {
+				urls = append(urls, fmt.Sprintf("%q", server.URL+"/api/v1/cookbooks/"+name+"/versions/"+v))
+			}
+			fmt.Fprintf(w, `{"name": %q, "versions": [%s]}`, name, strings.Join(urls, ","))
+		case len(parts) == 6 && parts[4] == "versions":
+			fmt.Fprintf(w, `{"version": %q, "file": "%s/download/%s/%s"}`, parts[5], server.URL, parts[3], parts[5])
+		case len(parts) == 3 && parts[0] == "download":
+			w.Write(cookbookTarball(t, parts[1], parts[2]))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// target records uploads; cookbooks in existing are answered with 409
+type target struct {
+	mu       sync.Mutex
+	uploads  []string
+	existing map[string]bool
+	fail     map[string]bool
+}
+
+func (tg *target) serve(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("ParseMultipartForm() error = %v", err)
+		}
+		_, header, _ := r.FormFile("tarball")
+		name := strings.TrimSuffix(header.Filename, ".tgz")
+
+		tg.mu.Lock()
+		defer tg.mu.Unlock()
+		switch {
+		case tg.existing[name]:
+			w.WriteHeader(http.StatusConflict)
+		case tg.fail[name]:
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			tg.uploads = append(tg.uploads, name)
+			w.WriteHeader(http.StatusCreated)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestParseList(t *testing.T) {
+	items, err := ParseList(strings.NewReader("# cookbooks\napt 7.4.0\n\nnginx\n"))
+	if err != nil {
+		t.Fatalf("ParseList() error = %v", err)
+	}
+	want := []Item{{Name: "apt", Version: "7.4.0"}, {Name: "nginx"}}
+	if fmt.Sprint(items) != fmt.Sprint(want) {
+		t.Errorf("ParseList() = %v, want %v", items, want)
+	}
+
+	if _, err := ParseList(strings.NewReader("apt 7.4.0 extra\n")); err == nil {
+		t.Error("ParseList() accepted a line with three fields")
+	}
+	if _, err := ParseList(strings.NewReader("apt latest\n")); err == nil {
+		t.Error("ParseList() accepted an invalid version")
+	}
+}
+
+func TestItemsFromLockFile(t *testing.T) {
+	lf := lockfile.NewLockFile()
+	lf.Sources["https://supermarket.chef.io"] = &lockfile.SourceLock{
+		Type:      "supermarket",
+		Cookbooks: map[string]*lockfile.CookbookLock{"nginx": {Version: "2.7.6"}, "apt": {Version: "7.4.0"}},
+	}
+	lf.Sources["git:https://github.com/example/app.git"] = &lockfile.SourceLock{
+		Type:      "git",
+		Cookbooks: map[string]*lockfile.CookbookLock{"app": {Version: "1.0.0"}},
+	}
+
+	items, skipped := ItemsFromLockFile(lf)
+	if fmt.Sprint(items) != "[apt 7.4.0 nginx 2.7.6]" {
+		t.Errorf("items = %v", items)
+	}
+	if fmt.Sprint(skipped) != "[app]" {
+		t.Errorf("skipped = %v", skipped)
+	}
+}
+
+func TestMirror_Resume(t *testing.T) {
+	origin := source.NewSupermarketSource(newOrigin(t).URL)
+	tg := &target{existing: map[string]bool{"nginx": true}, fail: map[string]bool{"apt": true}}
+	targetURL := tg.serve(t).URL
+	manifestPath := filepath.Join(t.TempDir(), DefaultManifestFile)
+	items := []Item{{Name: "apt"}, {Name: "nginx", Version: "2.7.6"}}
+
+	run := func() *Result {
+		t.Helper()
+		manifest, err := LoadManifest(manifestPath, origin.GetSourceURL(), targetURL)
+		if err != nil {
+			t.Fatalf("LoadManifest() error = %v", err)
+		}
+		m := New(origin, Options{Publish: publish.Options{URL: targetURL, Auth: nopAuth{}}, Manifest: manifest})
+		result, err := m.Mirror(context.Background(), items)
+		if err != nil {
+			t.Fatalf("Mirror() error = %v", err)
+		}
+		return result
+	}
+
+	// First run: apt fails on the target, nginx is already there
+	first := run()
+	if len(first.Failed) != 1 || first.Failed[0].Item.String() != "apt 7.4.0" {
+		t.Errorf("Failed = %v, want apt 7.4.0 (the latest version)", first.Failed)
+	}
+	if len(first.Synced) != 1 || first.Synced[0].Status != StatusPresent {
+		t.Errorf("Synced = %+v, want nginx present", first.Synced)
+	}
+
+	// Second run resumes: nginx is skipped from the manifest and apt is published
+	tg.fail = nil
+	second := run()
+	if len(second.Skipped) != 1 || second.Skipped[0].Name != "nginx" {
+		t.Errorf("Skipped = %v, want nginx", second.Skipped)
+	}
+	if len(second.Synced) != 1 || second.Synced[0].Status != StatusPublished || second.Synced[0].SHA256 == "" {
+		t.Errorf("Synced = %+v, want apt published", second.Synced)
+	}
+	if fmt.Sprint(tg.uploads) != "[apt]" {
+		t.Errorf("uploads = %v, want [apt]", tg.uploads)
+	}
+
+	manifest, _ := LoadManifest(manifestPath, origin.GetSourceURL(), targetURL)
+	if !manifest.Has("apt", "7.4.0") || !manifest.Has("nginx", "2.7.6") {
+		t.Errorf("manifest entries = %+v", manifest.Entries)
+	}
+}
+
+func TestMirror_DryRun(t *testing.T) {
+	origin := source.NewSupermarketSource(newOrigin(t).URL)
+	tg := &target{}
+	targetURL := tg.serve(t).URL
+	manifestPath := filepath.Join(t.TempDir(), DefaultManifestFile)
+	manifest, _ := LoadManifest(manifestPath, origin.GetSourceURL(), targetURL)
+
+	m := New(origin, Options{Publish: publish.Options{URL: targetURL, DryRun: true}, Manifest: manifest})
+	result, err := m.Mirror(context.Background(), []Item{{Name: "apt", Version: "7.3.0"}})
+	if err != nil || len(result.Synced) != 1 {
+		t.Fatalf("Mirror() = %+v, %v", result, err)
+	}
+	if len(tg.uploads) != 0 || len(manifest.Entries) != 0 {
+		t.Errorf("dry run uploaded %v and recorded %v", tg.uploads, manifest.Entries)
+	}
+}
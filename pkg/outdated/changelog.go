@@ -0,0 +1,64 @@
+package outdated
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/changelog"
+	"github.com/bdwyertech/go-berkshelf/pkg/source"
+)
+
+// Changelog is the release notes of the versions an outdated cookbook would
+// upgrade through
+type Changelog struct {
+	Repository string              `json:"repository"`
+	Sections   []changelog.Section `json:"sections"`
+}
+
+// Changelog fetches the changelog of an outdated cookbook and returns the
+// sections after its current version up to the latest
+func (c *Checker) Changelog(ctx context.Context, cookbook Cookbook, fetcher *changelog.Fetcher) (*Changelog, error) {
+	repository, err := c.Repository(ctx, cookbook.Name)
+	if err != nil {
+		return nil, err
+	}
+	if repository == "" {
+		return nil, fmt.Errorf("%s declares no source repository", cookbook.Name)
+	}
+
+	data, err := fetcher.Fetch(ctx, repository)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", repository, err)
+	}
+	sections := changelog.Between(changelog.Parse(data), cookbook.CurrentVersion, cookbook.LatestVersion)
+	return &Changelog{Repository: repository, Sections: sections}, nil
+}
+
+// Repository returns where the source code of a cookbook is hosted: the
+// repository it is locked to for git cookbooks, otherwise the source_url the
+// first source that knows declares
+func (c *Checker) Repository(ctx context.Context, name string) (string, error) {
+	if locked, _, ok := c.lockFile.GetCookbook(name); ok && locked.Source != nil && locked.Source.Type == "git" {
+		return locked.Source.URL, nil
+	}
+
+	var lastErr error
+	for _, src := range c.sourceManager.GetSources() {
+		repositories, ok := source.As[source.RepositorySource](src)
+		if !ok {
+			continue
+		}
+		repository, err := repositories.Repository(ctx, name)
+		if err != nil {
+			lastErr = err
+			continue // Try next source
+		}
+		if repository != "" {
+			return repository, nil
+		}
+	}
+	if lastErr != nil {
+		return "", fmt.Errorf("failed to find the repository of %s: %w", name, lastErr)
+	}
+	return "", nil
+}
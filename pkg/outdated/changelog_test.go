@@ -0,0 +1,85 @@
+package outdated
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/changelog"
+	"github.com/bdwyertech/go-berkshelf/pkg/lockfile"
+	"github.com/bdwyertech/go-berkshelf/pkg/source"
+)
+
+// repositorySource declares source repositories from a map
+type repositorySource struct {
+	source.CookbookSource
+	repositories map[string]string
+}
+
+func (s *repositorySource) Repository(_ context.Context, name string) (string, error) {
+	return s.repositories[name], nil
+}
+
+// rawTransport answers every request with body
+type rawTransport struct {
+	body string
+}
+
+func (t rawTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader(t.body)),
+		Request:    req,
+	}, nil
+}
+
+func TestRepository(t *testing.T) {
+	lf := &lockfile.LockFile{Sources: map[string]*lockfile.SourceLock{
+		"https://supermarket.chef.io": {Cookbooks: map[string]*lockfile.CookbookLock{
+			"nginx": {Version: "2.7.5"},
+			"app": {Version: "1.0.0", Source: &lockfile.SourceInfo{
+				Type: "git", URL: "https://git.example.com/app.git", Ref: "abc123",
+			}},
+		}},
+	}}
+	manager := source.NewManager()
+	manager.AddSource(&repositorySource{repositories: map[string]string{
+		"nginx": "https://github.com/sous-chefs/nginx",
+		"app":   "https://github.com/example/app",
+	}})
+	checker := New(lf, manager)
+
+	tests := map[string]string{
+		"nginx":   "https://github.com/sous-chefs/nginx",
+		"app":     "https://git.example.com/app.git",
+		"unknown": "",
+	}
+	for name, want := range tests {
+		got, err := checker.Repository(context.Background(), name)
+		if err != nil || got != want {
+			t.Errorf("Repository(%s) = %q, %v; want %q", name, got, err, want)
+		}
+	}
+
+	fetcher := changelog.NewFetcher(&http.Client{Transport: rawTransport{
+		body: "# Changelog\n\n## 2.8.0\n\n- New\n\n## 2.7.6\n\n- Fix\n\n## 2.7.5\n\n- Old\n",
+	}})
+	notes, err := checker.Changelog(context.Background(), Cookbook{Name: "nginx", CurrentVersion: "2.7.5", LatestVersion: "2.8.0"}, fetcher)
+	if err != nil {
+		t.Fatalf("Changelog(nginx) error = %v", err)
+	}
+	var versions []string
+	for _, section := range notes.Sections {
+		versions = append(versions, section.Version)
+	}
+	if notes.Repository != "https://github.com/sous-chefs/nginx" || !reflect.DeepEqual(versions, []string{"2.8.0", "2.7.6"}) {
+		t.Errorf("Changelog(nginx) = %s %v", notes.Repository, versions)
+	}
+
+	if _, err := checker.Changelog(context.Background(), Cookbook{Name: "unknown"}, fetcher); err == nil {
+		t.Error("Changelog(unknown) error = nil, want an error")
+	}
+}
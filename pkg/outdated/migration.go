@@ -0,0 +1,136 @@
+package outdated
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	"github.com/bdwyertech/go-berkshelf/pkg/source"
+)
+
+// maxReplacementChain bounds how many replacements are followed for one cookbook
+const maxReplacementChain = 10
+
+// Migration suggests replacing a deprecated cookbook
+type Migration struct {
+	Name           string `json:"name"`
+	CurrentVersion string `json:"current_version"`
+	// Replacement is the final cookbook in the replacement chain; empty if
+	// the maintainers declared none
+	Replacement string `json:"replacement,omitempty"`
+	// Via lists deprecated replacements skipped on the way to Replacement
+	Via []string `json:"via,omitempty"`
+	// Constraint is a suggested pessimistic constraint on the replacement's latest version
+	Constraint string `json:"constraint,omitempty"`
+	// Locked reports whether the replacement is already in the lock file
+	Locked bool `json:"locked,omitempty"`
+}
+
+// String describes the migration, e.g. "old → new (~> 2.1)"
+func (m Migration) String() string {
+	if m.Replacement == "" {
+		return m.Name + " is deprecated with no declared replacement"
+	}
+	s := m.Name
+	for _, name := range m.Via {
+		s += " → " + name
+	}
+	s += " → " + m.Replacement
+	if m.Constraint != "" {
+		s += " (" + m.Constraint + ")"
+	}
+	return s
+}
+
+// Migrations returns a migration for each deprecated cookbook in the lock file.
+// Replacements that are themselves deprecated are followed to the end of the chain.
+// If cookbookNames is empty, all cookbooks from the lock file are checked.
+func (c *Checker) Migrations(ctx context.Context, cookbookNames []string) ([]Migration, error) {
+	locked := make(map[string]string)
+	for _, src := range c.lockFile.Sources {
+		for name, cookbook := range src.Cookbooks {
+			locked[name] = cookbook.Version
+		}
+	}
+
+	names := cookbookNames
+	if len(names) == 0 {
+		for name := range locked {
+			names = append(names, name)
+		}
+	}
+
+	var migrations []Migration
+	for _, name := range names {
+		version, ok := locked[name]
+		if !ok {
+			continue
+		}
+
+		deprecation, err := c.deprecation(ctx, name)
+		if err != nil || deprecation == nil {
+			continue // Not deprecated, or no source knows
+		}
+
+		migration := Migration{Name: name, CurrentVersion: version}
+		seen := map[string]bool{name: true}
+		for next := deprecation.Replacement; next != "" && !seen[next] && len(seen) <= maxReplacementChain; {
+			seen[next] = true
+			if migration.Replacement != "" {
+				migration.Via = append(migration.Via, migration.Replacement)
+			}
+			migration.Replacement = next
+
+			following, err := c.deprecation(ctx, next)
+			if err != nil || following == nil {
+				break
+			}
+			next = following.Replacement
+		}
+
+		if migration.Replacement != "" {
+			_, migration.Locked = locked[migration.Replacement]
+			if latest, err := c.getLatestVersion(ctx, migration.Replacement); err == nil {
+				migration.Constraint = SuggestConstraint(latest)
+			}
+		}
+		migrations = append(migrations, migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Name < migrations[j].Name
+	})
+	return migrations, nil
+}
+
+// deprecation asks each source that tracks deprecations about a cookbook
+func (c *Checker) deprecation(ctx context.Context, name string) (*source.Deprecation, error) {
+	var lastErr error
+	for _, src := range c.sourceManager.GetSources() {
+		deprecations, ok := source.As[source.DeprecationSource](src)
+		if !ok {
+			continue
+		}
+		deprecation, err := deprecations.Deprecation(ctx, name)
+		if err != nil {
+			lastErr = err
+			continue // Try next source
+		}
+		return deprecation, nil
+	}
+	if lastErr != nil {
+		return nil, fmt.Errorf("failed to check deprecation for %s: %w", name, lastErr)
+	}
+	return nil, nil
+}
+
+// SuggestConstraint returns a pessimistic constraint allowing minor and patch
+// updates of version, e.g. "~> 2.1" for 2.1.4
+func SuggestConstraint(version string) string {
+	v, err := berkshelf.NewVersion(version)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("~> %d.%d", v.Major(), v.Minor())
+}
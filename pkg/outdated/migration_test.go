@@ -0,0 +1,78 @@
+package outdated
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	"github.com/bdwyertech/go-berkshelf/pkg/lockfile"
+	"github.com/bdwyertech/go-berkshelf/pkg/source"
+)
+
+// deprecatingSource serves versions and deprecations from maps
+type deprecatingSource struct {
+	source.CookbookSource
+	versions     map[string]string
+	replacements map[string]string // deprecated cookbook -> replacement ("" for none)
+}
+
+func (s *deprecatingSource) ListVersions(_ context.Context, name string) ([]*berkshelf.Version, error) {
+	if v, ok := s.versions[name]; ok {
+		return []*berkshelf.Version{berkshelf.MustVersion(v)}, nil
+	}
+	return nil, &source.ErrCookbookNotFound{Name: name}
+}
+
+func (s *deprecatingSource) Deprecation(_ context.Context, name string) (*source.Deprecation, error) {
+	if replacement, ok := s.replacements[name]; ok {
+		return &source.Deprecation{Replacement: replacement}, nil
+	}
+	return nil, nil
+}
+
+func TestMigrations(t *testing.T) {
+	lf := &lockfile.LockFile{Sources: map[string]*lockfile.SourceLock{
+		"https://supermarket.chef.io": {Cookbooks: map[string]*lockfile.CookbookLock{
+			"old":     {Version: "1.0.0"},
+			"chained": {Version: "0.5.0"},
+			"orphan":  {Version: "2.0.0"},
+			"current": {Version: "3.0.0"},
+			"newer":   {Version: "4.1.0"},
+			"loop_a":  {Version: "1.0.0"},
+		}},
+	}}
+
+	src := &deprecatingSource{
+		versions: map[string]string{"newer": "4.2.3", "final": "7.1.0"},
+		replacements: map[string]string{
+			"old":     "newer",
+			"chained": "middle",
+			"middle":  "final",
+			"orphan":  "",
+			"loop_a":  "loop_b",
+			"loop_b":  "loop_a",
+		},
+	}
+	manager := source.NewManager()
+	manager.AddSource(src)
+
+	migrations, err := New(lf, manager).Migrations(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("Migrations() error = %v", err)
+	}
+
+	want := []Migration{
+		{Name: "chained", CurrentVersion: "0.5.0", Replacement: "final", Via: []string{"middle"}, Constraint: "~> 7.1"},
+		{Name: "loop_a", CurrentVersion: "1.0.0", Replacement: "loop_b"},
+		{Name: "old", CurrentVersion: "1.0.0", Replacement: "newer", Constraint: "~> 4.2", Locked: true},
+		{Name: "orphan", CurrentVersion: "2.0.0"},
+	}
+	if !reflect.DeepEqual(migrations, want) {
+		t.Errorf("Migrations() = %+v\nwant %+v", migrations, want)
+	}
+
+	if got := migrations[0].String(); got != "chained → middle → final (~> 7.1)" {
+		t.Errorf("String() = %q", got)
+	}
+}
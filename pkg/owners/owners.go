@@ -0,0 +1,126 @@
+// Package owners maps cookbooks to the teams that own them, in the manner of
+// a CODEOWNERS file.
+package owners
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Rule assigns owners to the cookbooks matching Pattern, a cookbook name or
+// a glob such as "acme_*"
+type Rule struct {
+	Pattern string
+	Owners  []string
+}
+
+// Owners resolves the owners of a cookbook. Rules are ordered from least to
+// most significant; the last matching rule wins.
+type Owners struct {
+	rules []Rule
+}
+
+var (
+	// ownersComment is a standalone "# owners: PATTERN OWNER..." line
+	ownersComment = regexp.MustCompile(`^\s*#\s*owners?:\s*(.+)$`)
+	// cookbookComment is a cookbook declaration ending in "# owner: OWNER..."
+	cookbookComment = regexp.MustCompile(`^\s*cookbook\s*\(?\s*['"]([^'"]+)['"].*#\s*owners?:\s*(.+)$`)
+)
+
+// New returns Owners for the rules in order
+func New(rules ...Rule) *Owners {
+	return &Owners{rules: rules}
+}
+
+// FromConfig returns the rules of the owners config section, which maps
+// patterns to space or comma separated owners. Globs come before exact
+// names, and less specific globs before more specific ones, so that exact
+// names win.
+func FromConfig(config map[string]string) []Rule {
+	var rules []Rule
+	for pattern, owners := range config {
+		rules = append(rules, Rule{Pattern: pattern, Owners: splitOwners(owners)})
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		gi, gj := isGlob(rules[i].Pattern), isGlob(rules[j].Pattern)
+		if gi != gj {
+			return gi
+		}
+		if len(rules[i].Pattern) != len(rules[j].Pattern) {
+			return len(rules[i].Pattern) < len(rules[j].Pattern)
+		}
+		return rules[i].Pattern < rules[j].Pattern
+	})
+	return rules
+}
+
+// ParseBerksfile returns the rules declared in Berksfile comments, in file
+// order. A comment on its own line gives a pattern and its owners:
+//
+//	# owners: acme_* @platform
+//
+// and a comment ending a cookbook declaration gives the owners of that cookbook:
+//
+//	cookbook 'nginx', '~> 12.0' # owner: @web-team
+func ParseBerksfile(content string) []Rule {
+	var rules []Rule
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if m := cookbookComment.FindStringSubmatch(line); m != nil {
+			if owners := splitOwners(m[2]); len(owners) > 0 {
+				rules = append(rules, Rule{Pattern: m[1], Owners: owners})
+			}
+			continue
+		}
+		if m := ownersComment.FindStringSubmatch(line); m != nil {
+			if fields := splitOwners(m[1]); len(fields) > 1 {
+				rules = append(rules, Rule{Pattern: fields[0], Owners: fields[1:]})
+			}
+		}
+	}
+	return rules
+}
+
+// Load returns the owners from the config section followed by the comments
+// in the Berksfile at berksfilePath, so that the Berksfile wins. A missing
+// Berksfile contributes no rules.
+func Load(berksfilePath string, config map[string]string) (*Owners, error) {
+	rules := FromConfig(config)
+	data, err := os.ReadFile(berksfilePath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	rules = append(rules, ParseBerksfile(string(data))...)
+	return New(rules...), nil
+}
+
+// For returns the owners of a cookbook, or nil if it has none
+func (o *Owners) For(cookbook string) []string {
+	if o == nil {
+		return nil
+	}
+	for i := len(o.rules) - 1; i >= 0; i-- {
+		if matched, _ := path.Match(o.rules[i].Pattern, cookbook); matched {
+			return o.rules[i].Owners
+		}
+	}
+	return nil
+}
+
+// Empty reports whether there are no rules, so reports can omit ownership
+func (o *Owners) Empty() bool {
+	return o == nil || len(o.rules) == 0
+}
+
+func splitOwners(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' })
+}
+
+func isGlob(pattern string) bool {
+	return strings.ContainsAny(pattern, `*?[\`)
+}
@@ -0,0 +1,84 @@
+package owners
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const berksfile = `source 'https://supermarket.chef.io'
+
+# owners: acme_* @platform
+# A regular comment
+cookbook 'nginx', '~> 12.0' # owner: @web-team
+cookbook "acme_db", git: 'https://github.com/acme/db.git' # owners: @dba, @platform
+cookbook 'apt'
+`
+
+func TestParseBerksfile(t *testing.T) {
+	want := []Rule{
+		{Pattern: "acme_*", Owners: []string{"@platform"}},
+		{Pattern: "nginx", Owners: []string{"@web-team"}},
+		{Pattern: "acme_db", Owners: []string{"@dba", "@platform"}},
+	}
+	if got := ParseBerksfile(berksfile); !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseBerksfile() = %+v, want %+v", got, want)
+	}
+}
+
+func TestFromConfig(t *testing.T) {
+	rules := FromConfig(map[string]string{
+		"nginx":   "@web",
+		"acme_*":  "@platform",
+		"acme_d*": "@data",
+		"*":       "@sre",
+	})
+	var patterns []string
+	for _, rule := range rules {
+		patterns = append(patterns, rule.Pattern)
+	}
+	if want := []string{"*", "acme_*", "acme_d*", "nginx"}; !reflect.DeepEqual(patterns, want) {
+		t.Errorf("FromConfig() order = %v, want %v", patterns, want)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Berksfile")
+	if err := os.WriteFile(path, []byte(berksfile), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	o, err := Load(path, map[string]string{
+		"nginx":   "@web",
+		"acme_*":  "@infra",
+		"mysql":   "@dba @sre",
+		"apache*": "@web",
+	})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	tests := []struct {
+		cookbook string
+		want     []string
+	}{
+		{"nginx", []string{"@web-team"}},           // Berksfile wins over config
+		{"acme_app", []string{"@platform"}},        // Berksfile pattern
+		{"acme_db", []string{"@dba", "@platform"}}, // later Berksfile rule wins
+		{"mysql", []string{"@dba", "@sre"}},        // config only
+		{"apache2", []string{"@web"}},              // config pattern
+		{"apt", nil},                               // unowned
+	}
+	for _, tt := range tests {
+		if got := o.For(tt.cookbook); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("For(%q) = %v, want %v", tt.cookbook, got, tt.want)
+		}
+	}
+
+	// A missing Berksfile leaves only the config
+	o, err = Load(filepath.Join(t.TempDir(), "Berksfile"), nil)
+	if err != nil || !o.Empty() {
+		t.Errorf("Load() without a Berksfile = %+v, %v", o, err)
+	}
+}
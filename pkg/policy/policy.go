@@ -0,0 +1,195 @@
+// Package policy checks the cookbooks in a lock file against organizational
+// dependency rules: how old a locked version may be, whether 0.x versions
+// are allowed and whether Berksfile constraints must cap the major version.
+package policy
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berksfile"
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	"github.com/bdwyertech/go-berkshelf/pkg/lockfile"
+	"github.com/bdwyertech/go-berkshelf/pkg/logging"
+	"github.com/bdwyertech/go-berkshelf/pkg/source"
+)
+
+var log = logging.For("policy")
+
+// Rules a cookbook can violate
+const (
+	RuleMaxAge     = "max_age"
+	RulePrerelease = "prerelease"
+	RuleUpperBound = "upper_bound"
+)
+
+// unbounded is a version no constraint with an upper bound admits
+var unbounded = berkshelf.MustVersion("999999.0.0")
+
+// Violation is a cookbook breaking a rule
+type Violation struct {
+	Cookbook string `json:"cookbook"`
+	// Version is the locked version, or the Berksfile constraint for
+	// upper_bound violations
+	Version string `json:"version"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Policy is the set of rules to enforce. The zero value enforces nothing.
+type Policy struct {
+	// MaxAge flags locked versions released longer ago; zero disables the rule
+	MaxAge time.Duration
+	// DisallowPrerelease flags locked versions below 1.0.0
+	DisallowPrerelease bool
+	// RequireUpperBounds flags Berksfile constraints without an upper bound.
+	// Cookbooks from git or path sources are pinned by their source instead.
+	RequireUpperBounds bool
+	// Exempt are cookbook names, or glob patterns, no rule applies to
+	Exempt []string
+	// Now is the time ages are measured from; zero means time.Now()
+	Now time.Time
+}
+
+// Empty reports whether the policy enforces no rule
+func (p Policy) Empty() bool {
+	return p.MaxAge <= 0 && !p.DisallowPrerelease && !p.RequireUpperBounds
+}
+
+// exempt reports whether the rules skip a cookbook
+func (p Policy) exempt(name string) bool {
+	return slices.ContainsFunc(p.Exempt, func(pattern string) bool {
+		matched, _ := path.Match(pattern, name)
+		return matched
+	})
+}
+
+// Check returns the violations of the policy by the cookbooks in the lock
+// file and the Berksfile constraints, sorted by cookbook. The sources are
+// asked when each locked version was released; a cookbook whose release
+// date no source knows is skipped by the max_age rule.
+func (p Policy) Check(ctx context.Context, bf *berksfile.Berksfile, lockFile *lockfile.LockFile, sourceManager *source.Manager) []Violation {
+	if p.Now.IsZero() {
+		p.Now = time.Now()
+	}
+
+	var violations []Violation
+	if p.RequireUpperBounds && bf != nil {
+		for _, cookbook := range bf.Cookbooks {
+			if p.exempt(cookbook.Name) || pinnedBySource(cookbook) {
+				continue
+			}
+			if cookbook.Constraint == nil || cookbook.Constraint.Check(unbounded) {
+				constraint := ">= 0.0.0"
+				if cookbook.Constraint != nil {
+					constraint = cookbook.Constraint.String()
+				}
+				violations = append(violations, Violation{
+					Cookbook: cookbook.Name,
+					Version:  constraint,
+					Rule:     RuleUpperBound,
+					Message:  fmt.Sprintf("constraint %s has no upper bound, use e.g. ~> to cap the major version", constraint),
+				})
+			}
+		}
+	}
+
+	for name, locked := range lockedCookbooks(lockFile) {
+		if p.exempt(name) {
+			continue
+		}
+		version, err := berkshelf.NewVersion(locked.Version)
+		if err != nil {
+			log.Debugf("Skipping %s: %v", name, err)
+			continue
+		}
+
+		if p.DisallowPrerelease && version.Major() == 0 {
+			violations = append(violations, Violation{
+				Cookbook: name,
+				Version:  locked.Version,
+				Rule:     RulePrerelease,
+				Message:  fmt.Sprintf("%s is a 0.x version, which is not yet stable", locked.Version),
+			})
+		}
+
+		if p.MaxAge > 0 {
+			released, ok := p.released(ctx, sourceManager, name, locked, version)
+			if ok && p.Now.Sub(released) > p.MaxAge {
+				violations = append(violations, Violation{
+					Cookbook: name,
+					Version:  locked.Version,
+					Rule:     RuleMaxAge,
+					Message: fmt.Sprintf("released %s, more than %d days ago",
+						released.Format("2006-01-02"), int(p.MaxAge.Hours()/24)),
+				})
+			}
+		}
+	}
+
+	slices.SortStableFunc(violations, func(a, b Violation) int {
+		if c := strings.Compare(a.Cookbook, b.Cookbook); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Rule, b.Rule)
+	})
+	return violations
+}
+
+// released returns when the locked version was published, asking the
+// source it was locked from
+func (p Policy) released(ctx context.Context, sourceManager *source.Manager, name string, locked lockedCookbook, version *berkshelf.Version) (time.Time, bool) {
+	if sourceManager == nil {
+		return time.Time{}, false
+	}
+	for _, src := range sourceManager.GetSources() {
+		if strings.TrimSuffix(src.GetSourceURL(), "/") != strings.TrimSuffix(locked.SourceURL, "/") {
+			continue
+		}
+		releases, ok := source.As[source.ReleaseSource](src)
+		if !ok {
+			break
+		}
+		released, err := releases.Released(ctx, name, version)
+		if err != nil {
+			log.Debugf("Failed to check when %s %s was released: %v", name, locked.Version, err)
+			break
+		}
+		return released, true
+	}
+	log.Debugf("Skipping the age of %s: its source does not tell when it was released", name)
+	return time.Time{}, false
+}
+
+// lockedCookbook is a locked version and the source it was locked from
+type lockedCookbook struct {
+	Version   string
+	SourceURL string
+}
+
+// lockedCookbooks returns the cookbooks of the lock file by name
+func lockedCookbooks(lockFile *lockfile.LockFile) map[string]lockedCookbook {
+	cookbooks := make(map[string]lockedCookbook)
+	if lockFile == nil {
+		return cookbooks
+	}
+	for name := range lockFile.ListCookbooks() {
+		cookbook, sourceKey, _ := lockFile.GetCookbook(name)
+		sourceURL := sourceKey
+		if src := lockFile.Sources[sourceKey]; src.URL != "" {
+			sourceURL = src.URL
+		}
+		cookbooks[name] = lockedCookbook{Version: cookbook.Version, SourceURL: sourceURL}
+	}
+	return cookbooks
+}
+
+// pinnedBySource reports whether a cookbook comes from a git or path
+// source, which pins it without a version constraint
+func pinnedBySource(cookbook *berksfile.CookbookDef) bool {
+	return cookbook.Source != nil && (cookbook.Source.Type == "git" || cookbook.Source.Type == "path")
+}
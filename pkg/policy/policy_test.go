@@ -0,0 +1,107 @@
+package policy
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berksfile"
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	"github.com/bdwyertech/go-berkshelf/pkg/lockfile"
+	"github.com/bdwyertech/go-berkshelf/pkg/source"
+)
+
+const supermarketURL = "https://supermarket.example.com"
+
+// releasingSource tells when cookbook versions were released from a map
+type releasingSource struct {
+	source.CookbookSource
+	released map[string]string // name@version -> date
+}
+
+func (s *releasingSource) GetSourceURL() string { return supermarketURL }
+
+func (s *releasingSource) LastReleased(context.Context, string) (time.Time, error) {
+	return time.Time{}, source.ErrNotImplemented
+}
+
+func (s *releasingSource) Released(_ context.Context, name string, version *berkshelf.Version) (time.Time, error) {
+	date, ok := s.released[name+"@"+version.String()]
+	if !ok {
+		return time.Time{}, source.ErrNotImplemented
+	}
+	return time.Parse("2006-01-02", date)
+}
+
+func lockWith(cookbooks map[string]*lockfile.CookbookLock) *lockfile.LockFile {
+	lf := lockfile.NewLockFile()
+	lf.Sources[supermarketURL] = &lockfile.SourceLock{Type: "supermarket", URL: supermarketURL, Cookbooks: cookbooks}
+	return lf
+}
+
+func rules(violations []Violation) []string {
+	var got []string
+	for _, v := range violations {
+		got = append(got, v.Cookbook+":"+v.Rule)
+	}
+	return got
+}
+
+func TestCheck(t *testing.T) {
+	bf, err := berksfile.Parse(`
+source 'https://supermarket.example.com'
+cookbook 'nginx', '~> 2.7'
+cookbook 'apt', '>= 7.0'
+cookbook 'yum'
+cookbook 'app', git: 'https://git.example.com/app.git'
+cookbook 'legacy_db', '>= 1.0'
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lf := lockWith(map[string]*lockfile.CookbookLock{
+		"nginx":     {Version: "2.7.6"},
+		"apt":       {Version: "7.5.0"},
+		"yum":       {Version: "0.9.0"},
+		"legacy_db": {Version: "0.1.0"},
+	})
+	manager := source.NewManager()
+	manager.AddSource(&releasingSource{released: map[string]string{
+		"nginx@2.7.6":     "2026-01-15",
+		"apt@7.5.0":       "2023-06-01",
+		"legacy_db@0.1.0": "2015-01-01",
+	}})
+
+	policy := Policy{
+		MaxAge:             365 * 24 * time.Hour,
+		DisallowPrerelease: true,
+		RequireUpperBounds: true,
+		Exempt:             []string{"legacy_*"},
+		Now:                time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC),
+	}
+	violations := policy.Check(context.Background(), bf, lf, manager)
+
+	want := []string{"apt:max_age", "apt:upper_bound", "yum:prerelease", "yum:upper_bound"}
+	if got := rules(violations); !reflect.DeepEqual(got, want) {
+		t.Fatalf("violations = %v, want %v", got, want)
+	}
+	if violations[0].Message != "released 2023-06-01, more than 365 days ago" {
+		t.Errorf("message = %q", violations[0].Message)
+	}
+	if violations[3].Version != ">= 0.0.0" {
+		t.Errorf("version = %q, want >= 0.0.0", violations[3].Version)
+	}
+}
+
+func TestCheck_Empty(t *testing.T) {
+	lf := lockWith(map[string]*lockfile.CookbookLock{"yum": {Version: "0.9.0"}})
+
+	var policy Policy
+	if !policy.Empty() {
+		t.Error("Empty() = false for the zero policy")
+	}
+	if violations := policy.Check(context.Background(), nil, lf, nil); len(violations) != 0 {
+		t.Errorf("violations = %v, want none", violations)
+	}
+}
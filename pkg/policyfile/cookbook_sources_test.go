@@ -99,6 +99,22 @@ func TestParsePolicyfile_CookbookWithGitBranch(t *testing.T) {
 	}
 }
 
+func TestParsePolicyfile_CookbookWithGitRel(t *testing.T) {
+	input := `cookbook "app", git: "https://git.example.com/monorepo.git", rel: "cookbooks/app"`
+	policyfile, err := Parse(input)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if len(policyfile.Cookbooks) != 1 || policyfile.Cookbooks[0].Source == nil {
+		t.Fatalf("Expected 1 cookbook with a source, got %+v", policyfile.Cookbooks)
+	}
+
+	if path := policyfile.Cookbooks[0].Source.Path; path != "cookbooks/app" {
+		t.Errorf("Expected rel 'cookbooks/app', got %s", path)
+	}
+}
+
 func TestParsePolicyfile_CookbookWithGithub(t *testing.T) {
 	input := `cookbook "mysql", github: "opscode-cookbooks/mysql", branch: "master"`
 	policyfile, err := Parse(input)
@@ -0,0 +1,153 @@
+package policyfile
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/cookbook"
+)
+
+// ExportGroup is the policy group an export puts its policy in
+const ExportGroup = "local"
+
+// exportConfig is the .chef/config.rb of an export, as 'chef export' writes
+// it, with the policy name to fill in
+const exportConfig = `### Chef Infra Client Configuration ###
+# The settings in this file will configure chef to apply the exported policy in
+# this directory. To use it, run:
+#
+# chef-client -z
+#
+
+policy_name '%s'
+policy_group '%s'
+
+use_policyfile true
+policy_document_native_api true
+
+# In order to use this repo, you need a version of Chef Infra Client and Chef Zero
+# that supports policyfile "native mode" APIs:
+current_version = Gem::Version.new(Chef::VERSION)
+unless Gem::Requirement.new(">= 12.7").satisfied_by?(current_version)
+  puts("!" * 80)
+  puts(<<-MESSAGE)
+This Chef Repo requires features introduced in Chef Infra Client 12.7, but you are using
+Chef #{Chef::VERSION}. Please upgrade to Chef Infra Client 12.7 or later.
+MESSAGE
+  puts("!" * 80)
+  exit!(1)
+end
+`
+
+const exportReadme = `# Exported Chef Policy
+
+This directory holds the %s policy, exported with its cookbooks so that
+chef-client can apply it in local mode, without a Chef Server:
+
+    chef-client -z
+
+.chef/config.rb selects the policy and the local policy group. The
+cookbooks are under cookbook_artifacts, the policy under policies and the
+policy group under policy_groups. Policyfile.lock.json is the lock the
+policy was exported from.
+`
+
+// Export writes the policy locked by lock to dest in the layout of 'chef
+// export', for chef-client local mode: each cookbook under
+// cookbook_artifacts as NAME-IDENTIFIER, the lock under policies as
+// NAME-REVISION.json and as Policyfile.lock.json, the local policy group
+// under policy_groups, and a .chef/config.rb that applies it. cookbookDirs
+// maps each locked cookbook to the directory its files were extracted to;
+// only the files Chef loads are exported.
+func Export(lock *Lock, cookbookDirs map[string]string, dest string) error {
+	data, err := lock.JSON()
+	if err != nil {
+		return err
+	}
+
+	for name, cookbookLock := range lock.CookbookLocks {
+		src, ok := cookbookDirs[name]
+		if !ok {
+			return fmt.Errorf("no files for cookbook %s", name)
+		}
+		target := filepath.Join(dest, "cookbook_artifacts", name+"-"+cookbookLock.Identifier)
+		if err := copyCookbook(src, target); err != nil {
+			return fmt.Errorf("failed to export %s: %w", name, err)
+		}
+	}
+
+	group, err := json.MarshalIndent(map[string]any{
+		"policies": map[string]any{
+			lock.Name: map[string]string{"revision_id": lock.RevisionID},
+		},
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	rubyName := strings.ReplaceAll(strings.ReplaceAll(lock.Name, `\`, `\\`), `'`, `\'`)
+	files := map[string][]byte{
+		filepath.Join("policies", lock.Name+"-"+lock.RevisionID+".json"): data,
+		filepath.Join("policy_groups", ExportGroup+".json"):              append(group, '\n'),
+		filepath.Join(".chef", "config.rb"):                              fmt.Appendf(nil, exportConfig, rubyName, ExportGroup),
+		"Policyfile.lock.json":                                           data,
+		"README.md":                                                      fmt.Appendf(nil, exportReadme, lock.Name),
+	}
+	for rel, content := range files {
+		path := filepath.Join(dest, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, content, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyCookbook copies the files Chef loads from the cookbook in src to dst
+func copyCookbook(src, dst string) error {
+	files, err := cookbook.Files(src)
+	if err != nil {
+		return err
+	}
+	for _, rel := range files {
+		if err := copyFile(filepath.Join(src, filepath.FromSlash(rel)), filepath.Join(dst, filepath.FromSlash(rel))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyFile copies src to dst, keeping whether it is executable
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	mode := os.FileMode(0o644)
+	if info.Mode()&0o111 != 0 {
+		mode = 0o755
+	}
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
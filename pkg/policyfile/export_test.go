@@ -0,0 +1,71 @@
+package policyfile
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExport(t *testing.T) {
+	lock := testLock(t)
+	src := t.TempDir()
+	dirs := map[string]string{
+		"app":         filepath.Join(src, "app"),
+		"chef-client": filepath.Join(src, "chef-client"),
+	}
+	for _, file := range []string{"app/metadata.rb", "app/recipes/default.rb", "app/.git/HEAD", "chef-client/metadata.json"} {
+		path := filepath.Join(src, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(file), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dest := t.TempDir()
+	if err := Export(lock, dirs, dest); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+
+	for _, file := range []string{
+		"cookbook_artifacts/app-d365e6b2d55c97731bb4add33f91d645872755b2/recipes/default.rb",
+		"cookbook_artifacts/chef-client-0123456789abcdef0123456789abcdef01234567/metadata.json",
+		"policies/app-" + lock.RevisionID + ".json",
+		"Policyfile.lock.json",
+		"README.md",
+	} {
+		if _, err := os.Stat(filepath.Join(dest, file)); err != nil {
+			t.Errorf("%s was not exported: %v", file, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dest, "cookbook_artifacts/app-d365e6b2d55c97731bb4add33f91d645872755b2/.git")); err == nil {
+		t.Error(".git was exported")
+	}
+
+	data, err := os.ReadFile(filepath.Join(dest, "policy_groups", "local.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var group struct {
+		Policies map[string]struct {
+			RevisionID string `json:"revision_id"`
+		} `json:"policies"`
+	}
+	if err := json.Unmarshal(data, &group); err != nil {
+		t.Fatal(err)
+	}
+	if group.Policies["app"].RevisionID != lock.RevisionID {
+		t.Errorf("policy group = %s", data)
+	}
+
+	config, err := os.ReadFile(filepath.Join(dest, ".chef", "config.rb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(config), "policy_name 'app'\npolicy_group 'local'\n") {
+		t.Errorf("config.rb does not select the policy:\n%s", config)
+	}
+}
@@ -19,10 +19,22 @@ type Lexer struct {
 	buf struct {
 		tok int
 		lit string
+		pos scanner.Position
 		n   int
 	}
 	sourceText string
 	tokenLog   []string
+	// tok, lit and pos are the last token, its text and where it starts
+	tok int
+	lit string
+	pos scanner.Position
+	// lineStart is set when the next token starts a statement
+	lineStart bool
+	// name is the policy name, and runList and namedRunLists the run lists,
+	// read so far
+	name          string
+	runList       []string
+	namedRunLists map[string][]string
 }
 
 func NewLexer(src string) *Lexer {
@@ -31,19 +43,34 @@ func NewLexer(src string) *Lexer {
 	l.s.Whitespace ^= 1 << '\n' // Don't skip newlines
 	l.s.Mode = scanner.ScanIdents | scanner.ScanStrings | scanner.ScanRawStrings | scanner.ScanComments
 	l.sourceText = src
+	l.lineStart = true
 	return &l
 }
 
 func (l *Lexer) Lex(lval *yySymType) int {
+	lval.str = ""
+	l.tok = l.lex(lval)
+	l.lit = lval.str
+	lval.pos = l.pos
+	l.lineStart = l.tok == NEWLINE
+	return l.tok
+}
+
+func (l *Lexer) lex(lval *yySymType) int {
 	// Use buffered token if any
 	if l.buf.n != 0 {
 		l.buf.n = 0
+		l.pos = l.buf.pos
 		lval.str = l.buf.lit
 		return l.buf.tok
 	}
 
 	tok := l.s.Scan()
 	lit := l.s.TokenText()
+	l.pos = l.s.Position
+	if !l.pos.IsValid() {
+		l.pos = l.s.Pos()
+	}
 
 	// Log token for debugging
 	l.tokenLog = append(l.tokenLog, fmt.Sprintf("%s:%s", scanner.TokenString(tok), lit))
@@ -57,11 +84,28 @@ func (l *Lexer) Lex(lval *yySymType) int {
 			lval.str = lit
 			return keywordTok
 		}
+		if l.lineStart {
+			// name, run_list and the other directives that are not
+			// Berkshelf-equivalent are skipped, keeping the name and
+			// the run lists
+			pos := l.pos
+			tok, text := l.skipStatement()
+			switch lit {
+			case "name":
+				l.nameStatement(text, pos)
+			case "run_list", "named_run_list":
+				l.runListStatement(lit, text, pos)
+			}
+			return tok
+		}
 		lval.str = lit
 		return IDENTIFIER
 	case scanner.String, scanner.RawString:
 		lval.str = lit
 		return STRING
+	case '\'':
+		lval.str = l.singleQuoted()
+		return STRING
 	case '\n':
 		return NEWLINE
 	case ',':
@@ -75,14 +119,18 @@ func (l *Lexer) Lex(lval *yySymType) int {
 			return SYMBOL
 		} else {
 			// Buffer the next token and return standalone colon
+			l.buf.lit = l.s.TokenText()
 			if nextTok == scanner.String || nextTok == scanner.RawString {
 				l.buf.tok = STRING
+			} else if nextTok == '\'' {
+				l.buf.tok = STRING
+				l.buf.lit = l.singleQuoted()
 			} else if nextTok == scanner.Ident {
 				l.buf.tok = IDENTIFIER
 			} else {
 				l.buf.tok = int(nextTok)
 			}
-			l.buf.lit = l.s.TokenText()
+			l.buf.pos = l.s.Position
 			l.buf.n = 1
 			lval.str = lit
 			return COLON
@@ -101,9 +149,70 @@ func (l *Lexer) Lex(lval *yySymType) int {
 	default:
 		if unicode.IsSpace(rune(tok)) {
 			// Skip other whitespace and continue
-			return l.Lex(lval)
+			return l.lex(lval)
 		}
 		lval.str = lit
 		return int(tok)
 	}
 }
+
+// singleQuoted reads the rest of a single-quoted string, which is not a Go
+// string, and returns it with its quotes
+func (l *Lexer) singleQuoted() string {
+	var str strings.Builder
+	str.WriteRune('\'')
+	for {
+		next := l.s.Next()
+		if next == scanner.EOF {
+			break
+		}
+		str.WriteRune(next)
+		if next == '\'' {
+			break
+		}
+		if next == '\\' {
+			if escaped := l.s.Next(); escaped != scanner.EOF {
+				str.WriteRune(escaped)
+			}
+		}
+	}
+	return str.String()
+}
+
+// skipStatement skips the rest of a statement, including lines continued
+// inside brackets, such as a multi-line run_list, and returns the NEWLINE
+// ending it with the text skipped
+func (l *Lexer) skipStatement() (int, string) {
+	var text strings.Builder
+	depth := 0
+	var quote rune
+	for {
+		ch := l.s.Next()
+		if ch == scanner.EOF {
+			return 0, text.String()
+		}
+		text.WriteRune(ch)
+		switch {
+		case quote != 0:
+			if ch == '\\' {
+				if escaped := l.s.Next(); escaped != scanner.EOF {
+					text.WriteRune(escaped)
+				}
+			} else if ch == quote {
+				quote = 0
+			}
+		case ch == '\'' || ch == '"':
+			quote = ch
+		case ch == '#':
+			for l.s.Peek() != '\n' && l.s.Peek() != scanner.EOF {
+				l.s.Next()
+			}
+		case ch == '(' || ch == '[' || ch == '{':
+			depth++
+		case ch == ')' || ch == ']' || ch == '}':
+			depth--
+		case ch == '\n' && depth <= 0:
+			return NEWLINE, text.String()
+		}
+	}
+}
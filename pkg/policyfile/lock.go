@@ -0,0 +1,133 @@
+package policyfile
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Lock is a Policyfile.lock.json, as chef-cli writes it
+type Lock struct {
+	RevisionID           string                   `json:"revision_id"`
+	Name                 string                   `json:"name"`
+	RunList              []string                 `json:"run_list"`
+	NamedRunLists        map[string][]string      `json:"named_run_lists,omitempty"`
+	IncludedPolicyLocks  []any                    `json:"included_policy_locks"`
+	CookbookLocks        map[string]*CookbookLock `json:"cookbook_locks"`
+	DefaultAttributes    map[string]any           `json:"default_attributes"`
+	OverrideAttributes   map[string]any           `json:"override_attributes"`
+	SolutionDependencies SolutionDependencies     `json:"solution_dependencies"`
+}
+
+// CookbookLock locks a cookbook of a policy to a version and the
+// identifier of its content
+type CookbookLock struct {
+	Version                 string `json:"version"`
+	Identifier              string `json:"identifier"`
+	DottedDecimalIdentifier string `json:"dotted_decimal_identifier"`
+	// Source is the path of a cookbook from a path source, relative to the
+	// Policyfile
+	Source string `json:"source,omitempty"`
+	// CacheKey names the cookbook in chef-cli's cache; nil for path sources
+	CacheKey *string `json:"cache_key"`
+	// Origin is where the cookbook was downloaded from
+	Origin        string         `json:"origin,omitempty"`
+	SourceOptions map[string]any `json:"source_options"`
+}
+
+// SolutionDependencies records the constraints a policy was solved with
+type SolutionDependencies struct {
+	// Policyfile holds a [name, constraint] pair for each cookbook the
+	// Policyfile requires
+	Policyfile [][2]string `json:"Policyfile"`
+	// Dependencies holds the [name, constraint] dependencies of each locked
+	// cookbook, by "name (version)"
+	Dependencies map[string][][2]string `json:"dependencies"`
+}
+
+// NewLock returns the lock of the policy compiled to cookbooks, with its
+// revision ID. The run list items are expanded to recipe[cookbook::recipe]
+// as chef-cli writes them.
+func (p *Policyfile) NewLock(cookbooks map[string]*CookbookLock, solution SolutionDependencies) (*Lock, error) {
+	if p.Name == "" {
+		return nil, fmt.Errorf("the Policyfile has no name")
+	}
+	lock := &Lock{
+		Name:                 p.Name,
+		RunList:              []string{},
+		IncludedPolicyLocks:  []any{},
+		CookbookLocks:        cookbooks,
+		DefaultAttributes:    map[string]any{},
+		OverrideAttributes:   map[string]any{},
+		SolutionDependencies: solution,
+	}
+	expand := func(items []string) ([]string, error) {
+		expanded := make([]string, 0, len(items))
+		for _, item := range items {
+			cookbook, recipe, err := RunListRecipe(item)
+			if err != nil {
+				return nil, err
+			}
+			expanded = append(expanded, fmt.Sprintf("recipe[%s::%s]", cookbook, recipe))
+		}
+		return expanded, nil
+	}
+	var err error
+	if lock.RunList, err = expand(p.RunList); err != nil {
+		return nil, err
+	}
+	for name, items := range p.NamedRunLists {
+		if lock.NamedRunLists == nil {
+			lock.NamedRunLists = make(map[string][]string)
+		}
+		if lock.NamedRunLists[name], err = expand(items); err != nil {
+			return nil, err
+		}
+	}
+	lock.RevisionID = lock.computeRevisionID()
+	return lock, nil
+}
+
+// computeRevisionID returns the hex SHA-256 of the lock's canonical text, as
+// chef-cli computes it: the name, run lists, cookbook identifiers and
+// attributes, one per line
+func (l *Lock) computeRevisionID() string {
+	var text strings.Builder
+	fmt.Fprintf(&text, "name:%s\n", l.Name)
+	for _, item := range l.RunList {
+		fmt.Fprintf(&text, "run-list-item:%s\n", item)
+	}
+	for _, name := range slices.Sorted(maps.Keys(l.NamedRunLists)) {
+		for _, item := range l.NamedRunLists[name] {
+			fmt.Fprintf(&text, "named-run-list:%s;run-list-item:%s\n", name, item)
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(l.CookbookLocks)) {
+		fmt.Fprintf(&text, "cookbook:%s;id:%s\n", name, l.CookbookLocks[name].Identifier)
+	}
+	// encoding/json sorts map keys, which canonicalizes the attributes
+	defaults, _ := json.Marshal(l.DefaultAttributes)
+	overrides, _ := json.Marshal(l.OverrideAttributes)
+	fmt.Fprintf(&text, "default_attributes:%s\n", defaults)
+	fmt.Fprintf(&text, "override_attributes:%s\n", overrides)
+
+	sum := sha256.Sum256([]byte(text.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+// JSON returns the lock as chef-cli writes it, indented with two spaces
+func (l *Lock) JSON() ([]byte, error) {
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(l); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
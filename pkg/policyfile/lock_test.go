@@ -0,0 +1,89 @@
+package policyfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func testLock(t *testing.T) *Lock {
+	t.Helper()
+	p, err := Parse(`name 'app'
+run_list 'app', 'chef-client::config'
+named_run_list :test, 'recipe[app::test]'
+cookbook 'app', path: '.'
+`)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if p.Name != "app" {
+		t.Fatalf("Name = %q, want app", p.Name)
+	}
+	lock, err := p.NewLock(map[string]*CookbookLock{
+		"app": {
+			Version:       "1.0.0",
+			Identifier:    "d365e6b2d55c97731bb4add33f91d645872755b2",
+			Source:        ".",
+			SourceOptions: map[string]any{"path": "."},
+		},
+		"chef-client": {
+			Version:       "13.0.1",
+			Identifier:    "0123456789abcdef0123456789abcdef01234567",
+			SourceOptions: map[string]any{"artifactserver": "https://supermarket.chef.io/api/v1/cookbooks/chef-client/versions/13.0.1/download", "version": "13.0.1"},
+		},
+	}, SolutionDependencies{
+		Policyfile:   [][2]string{{"app", ">= 0.0.0"}, {"chef-client", ">= 0.0.0"}},
+		Dependencies: map[string][][2]string{"app (1.0.0)": {}, "chef-client (13.0.1)": {}},
+	})
+	if err != nil {
+		t.Fatalf("NewLock() error = %v", err)
+	}
+	return lock
+}
+
+func TestNewLock(t *testing.T) {
+	lock := testLock(t)
+
+	if want := []string{"recipe[app::default]", "recipe[chef-client::config]"}; !reflect.DeepEqual(lock.RunList, want) {
+		t.Errorf("RunList = %v, want %v", lock.RunList, want)
+	}
+	if want := map[string][]string{"test": {"recipe[app::test]"}}; !reflect.DeepEqual(lock.NamedRunLists, want) {
+		t.Errorf("NamedRunLists = %v, want %v", lock.NamedRunLists, want)
+	}
+	// As chef-cli computes it for the same lock
+	if want := "87e8c1d6b5c64df2eaa6a3b3d9ac21be77fb0b7b3dcb10c3b63ae35460bb2ee0"; lock.RevisionID != want {
+		t.Errorf("RevisionID = %s, want %s", lock.RevisionID, want)
+	}
+
+	data, err := lock.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte(`">= 0.0.0"`)) {
+		t.Errorf("constraints are escaped:\n%s", data)
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	app := decoded["cookbook_locks"].(map[string]any)["app"].(map[string]any)
+	if cacheKey, ok := app["cache_key"]; !ok || cacheKey != nil {
+		t.Errorf("cache_key = %v, want null", cacheKey)
+	}
+	for _, key := range []string{"included_policy_locks", "default_attributes", "override_attributes", "solution_dependencies"} {
+		if _, ok := decoded[key]; !ok {
+			t.Errorf("lock has no %s", key)
+		}
+	}
+}
+
+func TestNewLockWithoutName(t *testing.T) {
+	p, err := Parse("run_list 'app'\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.NewLock(nil, SolutionDependencies{}); err == nil {
+		t.Error("NewLock() succeeded without a policy name")
+	}
+}
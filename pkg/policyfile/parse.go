@@ -3,15 +3,19 @@ package policyfile
 import (
 	"fmt"
 	"strings"
+	"text/scanner"
 
 	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	berrors "github.com/bdwyertech/go-berkshelf/pkg/errors"
 )
 
-// Global variable to store parse errors
-var lastParseError error
+// parseErrors collects the errors found during the current parse
+var parseErrors ParseErrors
 
 // Parse parses the input Policyfile.rb DSL and returns a Policyfile struct or error.
-// Only parses Berkshelf-equivalent directives: default_source and cookbook
+// Only parses Berkshelf-equivalent directives, default_source and cookbook,
+// the policy name, and the run lists that call for cookbooks: run_list and
+// named_run_list
 func Parse(input string) (*Policyfile, error) {
 	trimmed := strings.TrimSpace(input)
 	if trimmed == "" {
@@ -29,7 +33,7 @@ func Parse(input string) (*Policyfile, error) {
 		}
 	}()
 
-	lastParseError = nil
+	parseErrors = nil
 	lexer := NewLexer(input)
 	lexer.sourceText = input // Store source text for error reporting
 	Result = nil
@@ -38,17 +42,93 @@ func Parse(input string) (*Policyfile, error) {
 	if parsePanic != nil {
 		return nil, fmt.Errorf("panic during parse: %v", parsePanic)
 	}
-	if lastParseError != nil {
-		return nil, lastParseError
+	if len(parseErrors) > 0 {
+		return nil, parseErrors
 	}
 	if Result == nil {
 		return nil, fmt.Errorf("parse error - Result is nil")
 	}
+	Result.Name = lexer.name
+	Result.RunList = lexer.runList
+	Result.NamedRunLists = lexer.namedRunLists
 
 	return Result, nil
 }
 
-// Error handling for the lexer
+// ParseError is an error at a position in a Policyfile
+type ParseError struct {
+	Line    int
+	Column  int
+	Message string
+	// Text is the offending line
+	Text string
+}
+
+// Error implements the error interface, showing the offending line with a
+// caret under the column
+func (e *ParseError) Error() string {
+	var indent strings.Builder
+	for i, r := range []rune(e.Text) {
+		if i >= e.Column-1 {
+			break
+		}
+		if r == '\t' {
+			indent.WriteRune('\t')
+		} else {
+			indent.WriteRune(' ')
+		}
+	}
+	return fmt.Sprintf("parse error at line %d, column %d: %s\n%s\n%s^", e.Line, e.Column, e.Message, e.Text, indent.String())
+}
+
+// ErrorType reports parse errors as parsing errors
+func (e *ParseError) ErrorType() berrors.ErrorType {
+	return berrors.ErrorTypeParsing
+}
+
+// ParseErrors are the errors found in a Policyfile, in order
+type ParseErrors []*ParseError
+
+// Error implements the error interface
+func (e ParseErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+// Unwrap returns the errors, for errors.As
+func (e ParseErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
+
+// Error records a parse error at the current token
 func (l *Lexer) Error(s string) {
-	lastParseError = fmt.Errorf("parse error: %s at position %v", s, l.s.Pos())
+	if s == "syntax error" {
+		switch {
+		case l.tok == 0:
+			s += ": unexpected end of file"
+		case l.tok == NEWLINE:
+			s += ": unexpected end of line"
+		case l.tok == STRING:
+			s += ": unexpected " + l.lit
+		default:
+			s += ": unexpected '" + l.s.TokenText() + "'"
+		}
+	}
+	l.errorAt(l.pos, s)
+}
+
+// errorAt records a parse error at pos
+func (l *Lexer) errorAt(pos scanner.Position, msg string) {
+	var text string
+	if lines := strings.Split(l.sourceText, "\n"); pos.Line >= 1 && pos.Line <= len(lines) {
+		text = lines[pos.Line-1]
+	}
+	parseErrors = append(parseErrors, &ParseError{Line: pos.Line, Column: pos.Column, Message: msg, Text: text})
 }
@@ -0,0 +1,69 @@
+package policyfile
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseErrorPositions(t *testing.T) {
+	input := "default_source :supermarket\ndefault_source :bogus\ncookbook \"apt\", \"~> nope\"\ncookbook \"nginx\" \"ohai\"\n"
+	_, err := Parse(input)
+
+	var parseErrors ParseErrors
+	if !errors.As(err, &parseErrors) {
+		t.Fatalf("Expected ParseErrors, got %v", err)
+	}
+	expected := []ParseError{
+		{Line: 2, Column: 16, Message: "unsupported source type: bogus", Text: "default_source :bogus"},
+		{Line: 3, Column: 17, Message: "invalid version constraint: ~> nope", Text: "cookbook \"apt\", \"~> nope\""},
+		{Line: 4, Column: 18, Message: "syntax error: unexpected \"ohai\"", Text: "cookbook \"nginx\" \"ohai\""},
+	}
+	if len(parseErrors) != len(expected) {
+		t.Fatalf("Expected %d errors, got %d: %v", len(expected), len(parseErrors), err)
+	}
+	for i, want := range expected {
+		if *parseErrors[i] != want {
+			t.Errorf("Error %d: expected %+v, got %+v", i, want, *parseErrors[i])
+		}
+	}
+}
+
+func TestParseErrorCaret(t *testing.T) {
+	_, err := Parse(`cookbook "apt", "~> nope"`)
+	want := "parse error at line 1, column 17: invalid version constraint: ~> nope\ncookbook \"apt\", \"~> nope\"\n                ^"
+	if err == nil || err.Error() != want {
+		t.Errorf("Expected error:\n%s\ngot:\n%v", want, err)
+	}
+}
+
+func TestParseSkipsOtherDirectives(t *testing.T) {
+	input := `name 'app'
+
+default_source :supermarket
+
+run_list [
+  'recipe[app::default]', # the app
+]
+named_run_list :test, 'app::test'
+default['app']['port'] = 80
+
+cookbook 'app', path: '.'
+cookbook 'nginx', '~> 12.0'
+`
+	p, err := Parse(input)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(p.DefaultSources) != 1 || p.DefaultSources[0].Type != "supermarket" {
+		t.Errorf("Expected the supermarket default source, got %+v", p.DefaultSources)
+	}
+	if len(p.Cookbooks) != 2 {
+		t.Fatalf("Expected 2 cookbooks, got %d", len(p.Cookbooks))
+	}
+	if p.Cookbooks[0].Name != "app" || p.Cookbooks[0].Source.Path != "." {
+		t.Errorf("Expected app from '.', got %+v", p.Cookbooks[0])
+	}
+	if p.Cookbooks[1].Name != "nginx" || p.Cookbooks[1].Constraint.String() != "~> 12.0" {
+		t.Errorf("Expected nginx ~> 12.0, got %+v", p.Cookbooks[1])
+	}
+}
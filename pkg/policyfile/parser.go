@@ -10,6 +10,7 @@ import __yyfmt__ "fmt"
 
 import (
 	"strings"
+	"text/scanner"
 
 	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
 )
@@ -23,8 +24,11 @@ type CookbookDef struct {
 
 // Policyfile represents a parsed Policyfile.rb (Berkshelf-equivalent parts only)
 type Policyfile struct {
+	Name           string                      // The policy name
 	DefaultSources []*berkshelf.SourceLocation // List of default sources
 	Cookbooks      []*CookbookDef              // All cookbook definitions
+	RunList        []string                    // Items of the run_list
+	NamedRunLists  map[string][]string         // Items of each named_run_list
 }
 
 var Result *Policyfile
@@ -34,7 +38,7 @@ func (p *Policyfile) GetCookbooks() []*CookbookDef {
 	return p.Cookbooks
 }
 
-//line policyfile.y:35
+//line policyfile.y:39
 type yySymType struct {
 	yys        int
 	str        string
@@ -42,6 +46,7 @@ type yySymType struct {
 	source     *berkshelf.SourceLocation
 	cookbook   *CookbookDef
 	options    map[string]string
+	pos        scanner.Position // Where the token starts, set by the lexer
 }
 
 const IDENTIFIER = 57346
@@ -73,7 +78,7 @@ const yyEofCode = 1
 const yyErrCode = 2
 const yyInitialStackSize = 16
 
-//line policyfile.y:249
+//line policyfile.y:258
 
 // createSourceFromOptions creates a SourceLocation from cookbook options
 func createSourceFromOptions(options map[string]string) *berkshelf.SourceLocation {
@@ -105,6 +110,9 @@ func createSourceFromOptions(options map[string]string) *berkshelf.SourceLocatio
 		if ref, ok := options["ref"]; ok {
 			source.Ref = ref
 		}
+		if rel, ok := options["rel"]; ok {
+			source.Path = rel
+		}
 
 		return source
 	}
@@ -125,6 +133,9 @@ func createSourceFromOptions(options map[string]string) *berkshelf.SourceLocatio
 		if ref, ok := options["ref"]; ok {
 			source.Ref = ref
 		}
+		if rel, ok := options["rel"]; ok {
+			source.Path = rel
+		}
 
 		return source
 	}
@@ -612,7 +623,7 @@ yydefault:
 
 	case 1:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line policyfile.y:59
+//line policyfile.y:64
 		{
 			if Result == nil {
 				Result = &Policyfile{
@@ -623,7 +634,7 @@ yydefault:
 		}
 	case 4:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line policyfile.y:74
+//line policyfile.y:79
 		{
 			if Result == nil {
 				Result = &Policyfile{
@@ -637,7 +648,7 @@ yydefault:
 		}
 	case 5:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line policyfile.y:86
+//line policyfile.y:91
 		{
 			if Result == nil {
 				Result = &Policyfile{
@@ -649,15 +660,22 @@ yydefault:
 				Result.Cookbooks = append(Result.Cookbooks, yyDollar[1].cookbook)
 			}
 		}
+	case 7:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line policyfile.y:104
+		{
+			// Report errors on later lines too
+			Errflag = 0
+		}
 	case 8:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line policyfile.y:102
+//line policyfile.y:111
 		{
 			yyVAL.source = yyDollar[2].source
 		}
 	case 9:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line policyfile.y:108
+//line policyfile.y:117
 		{
 			sourceType := strings.TrimPrefix(yyDollar[1].str, ":")
 			switch sourceType {
@@ -679,13 +697,13 @@ yydefault:
 					Type: "supermarket", // Treat as supermarket-like
 				}
 			default:
-				yylex.Error("unsupported source type: " + sourceType)
+				yylex.(*Lexer).errorAt(yyDollar[1].pos, "unsupported source type: "+sourceType)
 				yyVAL.source = nil
 			}
 		}
 	case 10:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line policyfile.y:134
+//line policyfile.y:143
 		{
 			sourceType := strings.TrimPrefix(yyDollar[1].str, ":")
 			uri := strings.Trim(yyDollar[3].str, "\"'")
@@ -712,19 +730,19 @@ yydefault:
 					URL:  uri,
 				}
 			default:
-				yylex.Error("unsupported source type: " + sourceType)
+				yylex.(*Lexer).errorAt(yyDollar[1].pos, "unsupported source type: "+sourceType)
 				yyVAL.source = nil
 			}
 		}
 	case 11:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line policyfile.y:167
+//line policyfile.y:176
 		{
 			yyVAL.str = yyDollar[1].str
 		}
 	case 12:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line policyfile.y:173
+//line policyfile.y:182
 		{
 			name := strings.Trim(yyDollar[2].str, "\"'")
 			yyVAL.cookbook = &CookbookDef{
@@ -733,7 +751,7 @@ yydefault:
 		}
 	case 13:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line policyfile.y:180
+//line policyfile.y:189
 		{
 			name := strings.Trim(yyDollar[2].str, "\"'")
 			yyVAL.cookbook = &CookbookDef{
@@ -743,7 +761,7 @@ yydefault:
 		}
 	case 14:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line policyfile.y:188
+//line policyfile.y:197
 		{
 			name := strings.Trim(yyDollar[2].str, "\"'")
 			source := createSourceFromOptions(yyDollar[4].options)
@@ -754,7 +772,7 @@ yydefault:
 		}
 	case 15:
 		yyDollar = yyS[yypt-6 : yypt+1]
-//line policyfile.y:197
+//line policyfile.y:206
 		{
 			name := strings.Trim(yyDollar[2].str, "\"'")
 			source := createSourceFromOptions(yyDollar[6].options)
@@ -766,43 +784,43 @@ yydefault:
 		}
 	case 16:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line policyfile.y:209
+//line policyfile.y:218
 		{
 			yyVAL.options = yyDollar[1].options
 		}
 	case 17:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line policyfile.y:215
+//line policyfile.y:224
 		{
 			yyVAL.options = map[string]string{yyDollar[1].str: yyDollar[3].str}
 		}
 	case 18:
 		yyDollar = yyS[yypt-5 : yypt+1]
-//line policyfile.y:219
+//line policyfile.y:228
 		{
 			yyDollar[1].options[yyDollar[3].str] = yyDollar[5].str
 			yyVAL.options = yyDollar[1].options
 		}
 	case 19:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line policyfile.y:226
+//line policyfile.y:235
 		{
 			yyVAL.str = yyDollar[1].str
 		}
 	case 20:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line policyfile.y:232
+//line policyfile.y:241
 		{
 			yyVAL.str = strings.Trim(yyDollar[1].str, "\"'")
 		}
 	case 21:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line policyfile.y:238
+//line policyfile.y:247
 		{
 			constraintStr := strings.Trim(yyDollar[1].str, "\"'")
 			constraint, err := berkshelf.NewConstraint(constraintStr)
 			if err != nil {
-				yylex.Error("invalid version constraint: " + constraintStr)
+				yylex.(*Lexer).errorAt(yyDollar[1].pos, "invalid version constraint: "+constraintStr)
 				yyVAL.constraint = nil
 			} else {
 				yyVAL.constraint = constraint
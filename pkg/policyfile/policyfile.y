@@ -6,6 +6,7 @@ package policyfile
 
 import (
     "strings"
+    "text/scanner"
 
     "github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
 )
@@ -19,8 +20,11 @@ type CookbookDef struct {
 
 // Policyfile represents a parsed Policyfile.rb (Berkshelf-equivalent parts only)
 type Policyfile struct {
+	Name           string                        // The policy name
 	DefaultSources []*berkshelf.SourceLocation   // List of default sources
 	Cookbooks      []*CookbookDef                // All cookbook definitions
+	RunList        []string                      // Items of the run_list
+	NamedRunLists  map[string][]string           // Items of each named_run_list
 }
 
 var Result *Policyfile
@@ -38,6 +42,7 @@ func (p *Policyfile) GetCookbooks() []*CookbookDef {
     source *berkshelf.SourceLocation
     cookbook *CookbookDef
     options map[string]string
+    pos scanner.Position // Where the token starts, set by the lexer
 }
 
 %token <str> IDENTIFIER STRING SYMBOL NEWLINE COMMA COLON
@@ -96,6 +101,10 @@ statement:
     }
     | NEWLINE
     | error NEWLINE
+    {
+        // Report errors on later lines too
+        Errflag = 0
+    }
 
 default_source_stmt:
     DEFAULT_SOURCE source_spec
@@ -126,7 +135,7 @@ source_spec:
                 Type: "supermarket", // Treat as supermarket-like
             }
         default:
-            yylex.Error("unsupported source type: " + sourceType)
+            yylex.(*Lexer).errorAt($<pos>1, "unsupported source type: " + sourceType)
             $$ = nil
         }
     }
@@ -157,7 +166,7 @@ source_spec:
                 URL:  uri,
             }
         default:
-            yylex.Error("unsupported source type: " + sourceType)
+            yylex.(*Lexer).errorAt($<pos>1, "unsupported source type: " + sourceType)
             $$ = nil
         }
     }
@@ -239,7 +248,7 @@ version_constraint:
         constraintStr := strings.Trim($1, "\"'")
         constraint, err := berkshelf.NewConstraint(constraintStr)
         if err != nil {
-            yylex.Error("invalid version constraint: " + constraintStr)
+            yylex.(*Lexer).errorAt($<pos>1, "invalid version constraint: " + constraintStr)
             $$ = nil
         } else {
             $$ = constraint
@@ -278,6 +287,9 @@ func createSourceFromOptions(options map[string]string) *berkshelf.SourceLocatio
         if ref, ok := options["ref"]; ok {
             source.Ref = ref
         }
+        if rel, ok := options["rel"]; ok {
+            source.Path = rel
+        }
         
         return source
     }
@@ -298,6 +310,9 @@ func createSourceFromOptions(options map[string]string) *berkshelf.SourceLocatio
         if ref, ok := options["ref"]; ok {
             source.Ref = ref
         }
+        if rel, ok := options["rel"]; ok {
+            source.Path = rel
+        }
         
         return source
     }
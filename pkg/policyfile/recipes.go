@@ -0,0 +1,76 @@
+package policyfile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// CompiledCookbook is the version of a cookbook a policy compiled to, with
+// the directory its files were extracted to
+type CompiledCookbook struct {
+	Version string
+	Path    string
+}
+
+// RecipeError reports a run list item calling for a recipe that the
+// cookbook it compiled to does not have
+type RecipeError struct {
+	// RunList is "run_list", or the name of the named run list
+	RunList  string
+	Item     string
+	Cookbook string
+	Recipe   string
+	// Version is the version of the cookbook inspected, empty when the
+	// cookbook was not compiled at all
+	Version string
+}
+
+func (e *RecipeError) Error() string {
+	where := "run_list"
+	if e.RunList != "run_list" {
+		where = fmt.Sprintf("named_run_list %s", e.RunList)
+	}
+	if e.Version == "" {
+		return fmt.Sprintf("%s: %s calls for %s, which was not compiled", where, e.Item, e.Cookbook)
+	}
+	return fmt.Sprintf("%s: recipe %s::%s not found in %s %s (no recipes/%s.rb)", where, e.Cookbook, e.Recipe, e.Cookbook, e.Version, e.Recipe)
+}
+
+// CheckRecipes checks that every recipe the run lists call for exists in
+// the cookbook the policy compiled to, as recipes/NAME.rb, returning an
+// error for each that does not: the run_list first, then the named run
+// lists by name
+func (p *Policyfile) CheckRecipes(cookbooks map[string]CompiledCookbook) []*RecipeError {
+	var errs []*RecipeError
+	check := func(runList string, items []string) {
+		for _, item := range items {
+			cookbook, recipe, err := RunListRecipe(item)
+			if err != nil {
+				// The parser rejects such items
+				continue
+			}
+			recipeErr := &RecipeError{RunList: runList, Item: item, Cookbook: cookbook, Recipe: recipe}
+			compiled, ok := cookbooks[cookbook]
+			if !ok {
+				errs = append(errs, recipeErr)
+				continue
+			}
+			if _, err := os.Stat(filepath.Join(compiled.Path, "recipes", recipe+".rb")); err != nil {
+				recipeErr.Version = compiled.Version
+				errs = append(errs, recipeErr)
+			}
+		}
+	}
+	check("run_list", p.RunList)
+	names := make([]string, 0, len(p.NamedRunLists))
+	for name := range p.NamedRunLists {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		check(name, p.NamedRunLists[name])
+	}
+	return errs
+}
@@ -0,0 +1,46 @@
+package policyfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckRecipes(t *testing.T) {
+	dir := t.TempDir()
+	for _, file := range []string{"app/recipes/default.rb", "app/recipes/test.rb", "chef-client/recipes/default.rb"} {
+		path := filepath.Join(dir, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p, err := Parse(`run_list 'app', 'recipe[chef-client::config]'
+named_run_list :test, 'app::test', 'helpers'
+named_run_list :deploy, 'app::deploy'
+`)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	errs := p.CheckRecipes(map[string]CompiledCookbook{
+		"app":         {Version: "1.2.0", Path: filepath.Join(dir, "app")},
+		"chef-client": {Version: "13.0.1", Path: filepath.Join(dir, "chef-client")},
+	})
+
+	want := []string{
+		"run_list: recipe chef-client::config not found in chef-client 13.0.1 (no recipes/config.rb)",
+		"named_run_list deploy: recipe app::deploy not found in app 1.2.0 (no recipes/deploy.rb)",
+		"named_run_list test: helpers calls for helpers, which was not compiled",
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("CheckRecipes() = %v, want %d errors", errs, len(want))
+	}
+	for i, err := range errs {
+		if err.Error() != want[i] {
+			t.Errorf("error %d = %q, want %q", i, err, want[i])
+		}
+	}
+}
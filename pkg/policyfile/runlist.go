@@ -0,0 +1,145 @@
+package policyfile
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"text/scanner"
+	"unicode"
+)
+
+// RunListCookbook returns the cookbook a run list item calls for:
+// recipe[nginx::server], nginx::server and nginx all call for nginx. Roles
+// cannot be used in a Policyfile.
+func RunListCookbook(item string) (string, error) {
+	cookbook, _, err := RunListRecipe(item)
+	return cookbook, err
+}
+
+// RunListRecipe returns the cookbook and recipe a run list item calls for,
+// the recipe being default when the item names only the cookbook
+func RunListRecipe(item string) (cookbook, recipe string, err error) {
+	name := item
+	if strings.HasPrefix(item, "role[") {
+		return "", "", fmt.Errorf("roles are not supported in Policyfile run lists: %s", item)
+	}
+	if strings.HasPrefix(item, "recipe[") {
+		if !strings.HasSuffix(item, "]") {
+			return "", "", fmt.Errorf("invalid run list item: %s", item)
+		}
+		name = strings.TrimSuffix(strings.TrimPrefix(item, "recipe["), "]")
+	}
+	cookbook, recipe, found := strings.Cut(name, "::")
+	if cookbook == "" || strings.ContainsAny(cookbook, "[] ") {
+		return "", "", fmt.Errorf("invalid run list item: %s", item)
+	}
+	if !found {
+		recipe = "default"
+	}
+	if recipe == "" || strings.ContainsAny(recipe, "[] /") {
+		return "", "", fmt.Errorf("invalid run list item: %s", item)
+	}
+	return cookbook, recipe, nil
+}
+
+// RunListCookbooks returns the cookbooks the run_list and the named run
+// lists call for, in the order they first appear, named run lists by name.
+// Like chef-cli, every named run list is locked with the run_list, as a
+// node may be converged with any of them.
+func (p *Policyfile) RunListCookbooks() []string {
+	var cookbooks []string
+	add := func(items []string) {
+		for _, item := range items {
+			if cookbook, err := RunListCookbook(item); err == nil && !slices.Contains(cookbooks, cookbook) {
+				cookbooks = append(cookbooks, cookbook)
+			}
+		}
+	}
+	add(p.RunList)
+	names := make([]string, 0, len(p.NamedRunLists))
+	for name := range p.NamedRunLists {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		add(p.NamedRunLists[name])
+	}
+	return cookbooks
+}
+
+// nameStatement records the policy name of a name statement starting at
+// pos, text being the statement after its keyword
+func (l *Lexer) nameStatement(text string, pos scanner.Position) {
+	args := runListArgs(text)
+	if len(args) != 1 || strings.HasPrefix(args[0], ":") || args[0] == "" {
+		l.errorAt(pos, "name requires a string")
+		return
+	}
+	l.name = args[0]
+}
+
+// runListStatement records the run list of a run_list or named_run_list
+// statement starting at pos, text being the statement after its keyword
+func (l *Lexer) runListStatement(keyword, text string, pos scanner.Position) {
+	args := runListArgs(text)
+	if keyword == "named_run_list" {
+		if len(args) == 0 {
+			l.errorAt(pos, "named_run_list requires a name")
+			return
+		}
+		name := strings.TrimPrefix(args[0], ":")
+		args = args[1:]
+		if l.namedRunLists == nil {
+			l.namedRunLists = make(map[string][]string)
+		}
+		l.namedRunLists[name] = append(l.namedRunLists[name], l.runListItems(args, pos)...)
+		return
+	}
+	l.runList = append(l.runList, l.runListItems(args, pos)...)
+}
+
+// runListItems checks the items of a run list statement at pos
+func (l *Lexer) runListItems(args []string, pos scanner.Position) []string {
+	items := make([]string, 0, len(args))
+	for _, item := range args {
+		if strings.HasPrefix(item, ":") {
+			l.errorAt(pos, "invalid run list item: "+item)
+			continue
+		}
+		if _, err := RunListCookbook(item); err != nil {
+			l.errorAt(pos, err.Error())
+			continue
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// runListArgs returns the string and symbol literals of a run list
+// statement in order, symbols with their colon. Brackets, parentheses and
+// commas around them are skipped.
+func runListArgs(text string) []string {
+	var args []string
+	runes := []rune(text)
+	for i := 0; i < len(runes); i++ {
+		switch ch := runes[i]; {
+		case ch == '\'' || ch == '"':
+			var str strings.Builder
+			for i++; i < len(runes) && runes[i] != ch; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				str.WriteRune(runes[i])
+			}
+			args = append(args, str.String())
+		case ch == ':' && i+1 < len(runes) && (unicode.IsLetter(runes[i+1]) || runes[i+1] == '_'):
+			start := i
+			for i++; i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_'); i++ {
+			}
+			args = append(args, string(runes[start:i]))
+			i--
+		}
+	}
+	return args
+}
@@ -0,0 +1,119 @@
+package policyfile
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRunListCookbook(t *testing.T) {
+	tests := map[string]string{
+		"recipe[nginx::server]": "nginx",
+		"recipe[nginx]":         "nginx",
+		"nginx::server":         "nginx",
+		"nginx":                 "nginx",
+		"chef-client::default":  "chef-client",
+	}
+	for item, want := range tests {
+		if got, err := RunListCookbook(item); err != nil || got != want {
+			t.Errorf("RunListCookbook(%q) = %q, %v, want %q", item, got, err, want)
+		}
+	}
+	for _, item := range []string{"role[base]", "recipe[nginx", "", "::server"} {
+		if _, err := RunListCookbook(item); err == nil {
+			t.Errorf("RunListCookbook(%q) succeeded", item)
+		}
+	}
+}
+
+func TestRunListRecipe(t *testing.T) {
+	tests := map[string][2]string{
+		"recipe[nginx::server]": {"nginx", "server"},
+		"recipe[nginx]":         {"nginx", "default"},
+		"nginx::server":         {"nginx", "server"},
+		"nginx":                 {"nginx", "default"},
+	}
+	for item, want := range tests {
+		cookbook, recipe, err := RunListRecipe(item)
+		if err != nil || cookbook != want[0] || recipe != want[1] {
+			t.Errorf("RunListRecipe(%q) = %q, %q, %v, want %v", item, cookbook, recipe, err, want)
+		}
+	}
+	for _, item := range []string{"nginx::", "recipe[nginx::]", "nginx::../server"} {
+		if _, _, err := RunListRecipe(item); err == nil {
+			t.Errorf("RunListRecipe(%q) succeeded", item)
+		}
+	}
+}
+
+func TestParseRunLists(t *testing.T) {
+	p, err := Parse(`name 'app'
+
+default_source :supermarket
+
+run_list [
+  'recipe[app::default]', # the app
+  "chef-client",
+]
+named_run_list :test, 'app::test', 'recipe[test-helpers]'
+named_run_list "update", ['recipe[chef-client::config]', 'updater']
+
+cookbook 'app', path: '.'
+cookbook 'test-helpers', '~> 1.0'
+`)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if want := []string{"recipe[app::default]", "chef-client"}; !reflect.DeepEqual(p.RunList, want) {
+		t.Errorf("RunList = %v, want %v", p.RunList, want)
+	}
+	wantNamed := map[string][]string{
+		"test":   {"app::test", "recipe[test-helpers]"},
+		"update": {"recipe[chef-client::config]", "updater"},
+	}
+	if !reflect.DeepEqual(p.NamedRunLists, wantNamed) {
+		t.Errorf("NamedRunLists = %v, want %v", p.NamedRunLists, wantNamed)
+	}
+
+	// Every named run list is locked with the run_list
+	if got, want := p.RunListCookbooks(), []string{"app", "chef-client", "test-helpers", "updater"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("RunListCookbooks() = %v, want %v", got, want)
+	}
+
+	// Declared cookbooks keep their constraints and sources
+	equivalent, err := p.ToBerksfileEquivalent()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, cb := range equivalent.Cookbooks {
+		names = append(names, cb.Name)
+	}
+	if want := []string{"app", "test-helpers", "chef-client", "updater"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("cookbooks = %v, want %v", names, want)
+	}
+	if c := equivalent.Cookbooks[1].Constraint; c == nil || c.String() != "~> 1.0" {
+		t.Errorf("test-helpers constraint = %v, want ~> 1.0", c)
+	}
+	if equivalent.Cookbooks[2].Constraint != nil || equivalent.Cookbooks[2].Source != nil {
+		t.Errorf("chef-client = %+v, want any version from the default sources", equivalent.Cookbooks[2])
+	}
+}
+
+func TestParseRunListErrors(t *testing.T) {
+	_, err := Parse(`run_list 'recipe[app]', 'role[base]'
+named_run_list
+cookbook 'app'
+`)
+	if err == nil {
+		t.Fatal("Parse() succeeded")
+	}
+	for _, want := range []string{
+		"line 1, column 1: roles are not supported in Policyfile run lists: role[base]",
+		"line 2, column 1: named_run_list requires a name",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Parse() error = %v, want %q", err, want)
+		}
+	}
+}
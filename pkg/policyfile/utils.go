@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 
 	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
 	"github.com/bdwyertech/go-berkshelf/pkg/template"
@@ -40,14 +41,32 @@ func Find(startDir string) (string, error) {
 }
 
 // ToBerksfileEquivalent converts a Policyfile to a structure that can be used
-// with the existing Berkshelf resolver and source systems
+// with the existing Berkshelf resolver and source systems. As chef-cli
+// compiles a Policyfile, the cookbooks its run lists call for are required
+// along with its cookbook statements, which only need to be declared to
+// constrain a cookbook or give its source.
 func (p *Policyfile) ToBerksfileEquivalent() (*BerksfileEquivalent, error) {
 	return &BerksfileEquivalent{
 		Sources:   p.DefaultSources,
-		Cookbooks: p.Cookbooks,
+		Cookbooks: p.Requirements(),
 	}, nil
 }
 
+// Requirements returns the cookbook statements followed by the cookbooks
+// the run lists call for that no statement declares
+func (p *Policyfile) Requirements() []*CookbookDef {
+	cookbooks := slices.Clone(p.Cookbooks)
+	for _, name := range p.RunListCookbooks() {
+		declared := slices.ContainsFunc(p.Cookbooks, func(cb *CookbookDef) bool {
+			return cb.Name == name
+		})
+		if !declared {
+			cookbooks = append(cookbooks, &CookbookDef{Name: name})
+		}
+	}
+	return cookbooks
+}
+
 // BerksfileEquivalent represents the Berkshelf-compatible parts of a Policyfile
 type BerksfileEquivalent struct {
 	Sources   []*berkshelf.SourceLocation
@@ -0,0 +1,323 @@
+// Package proxy serves the Supermarket API as a read-through cache of one or
+// more upstream Supermarkets. Responses are kept on disk and served again
+// when the upstreams cannot be reached, so an air-gapped network can point
+// its Berksfiles at the proxy as a drop-in private mirror.
+//
+// A cookbook is served by the first upstream that has it, both from the
+// per-cookbook API and from /universe, which lists the cookbooks of every
+// upstream. URLs of the upstreams in responses, such as download links, are
+// rewritten to the proxy's, so tarballs are fetched and cached through it
+// too. An upstream may itself be a proxy.
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/logging"
+)
+
+var log = logging.For("proxy")
+
+// DefaultTTL is how long cached API responses are served before the
+// upstreams are asked again
+const DefaultTTL = 15 * time.Minute
+
+// checksumHeader carries the SHA-256 of tarball downloads, as Artifactory
+// sends it, so clients can verify them
+const checksumHeader = "X-Checksum-Sha256"
+
+// Cache statuses reported in the X-Cache header
+const (
+	cacheHit   = "HIT"
+	cacheMiss  = "MISS"
+	cacheStale = "STALE"
+)
+
+// Options configures a Proxy
+type Options struct {
+	// Upstreams are the Supermarket URLs proxied, in order of precedence
+	Upstreams []string
+	// APIKeys maps upstream URLs to the API keys sent to them
+	APIKeys map[string]string
+	// CacheDir holds the cached responses and tarballs
+	CacheDir string
+	// TTL is how long cached API responses are served before the upstreams
+	// are asked again; 0 is DefaultTTL. Tarballs are kept for good, as a
+	// published cookbook version does not change.
+	TTL time.Duration
+	// Offline serves only from the cache, never asking the upstreams
+	Offline bool
+	// BaseURL is the URL clients reach the proxy at, written into
+	// responses; empty uses the scheme and Host of each request
+	BaseURL string
+	// Client sends the upstream requests; nil uses a client with a 60
+	// second timeout
+	Client *http.Client
+}
+
+// Proxy is an http.Handler serving the Supermarket API from its cache and
+// upstreams
+type Proxy struct {
+	options Options
+	store   *store
+	client  *http.Client
+}
+
+// errNotFound is returned when no upstream, nor the cache, has a response
+var errNotFound = errors.New("not found")
+
+// New returns a proxy of options.Upstreams caching in options.CacheDir
+func New(options Options) (*Proxy, error) {
+	if len(options.Upstreams) == 0 {
+		return nil, errors.New("at least one upstream is required")
+	}
+	for i, upstream := range options.Upstreams {
+		options.Upstreams[i] = strings.TrimSuffix(upstream, "/")
+	}
+	if options.TTL <= 0 {
+		options.TTL = DefaultTTL
+	}
+	store, err := newStore(options.CacheDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the proxy cache: %w", err)
+	}
+	client := options.Client
+	if client == nil {
+		client = &http.Client{Timeout: 60 * time.Second}
+	}
+	return &Proxy{options: options, store: store, client: client}, nil
+}
+
+// ServeHTTP serves GET and HEAD requests for /universe and the API
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeError(w, http.StatusMethodNotAllowed, "the proxy is read-only")
+		return
+	}
+	key := r.URL.Path
+	if r.URL.RawQuery != "" {
+		key += "?" + r.URL.RawQuery
+	}
+
+	var (
+		e      *entry
+		body   []byte
+		status string
+		err    error
+	)
+	if r.URL.Path == "/universe" {
+		e, body, status, err = p.universe(r.Context(), p.baseURL(r))
+	} else {
+		e, body, status, err = p.fetch(r.Context(), key, key, p.options.Upstreams, isTarball(r.URL.Path))
+		if err == nil {
+			body = p.rewrite(e, body, p.baseURL(r))
+		}
+	}
+	switch {
+	case errors.Is(err, errNotFound):
+		writeError(w, http.StatusNotFound, fmt.Sprintf("%s was not found upstream or in the cache", r.URL.Path))
+		log.Debugf("%s %s: not found", r.Method, key)
+		return
+	case err != nil:
+		writeError(w, http.StatusBadGateway, err.Error())
+		log.Warnf("%s %s: %v", r.Method, key, err)
+		return
+	}
+
+	log.Debugf("%s %s: %s", r.Method, key, status)
+	w.Header().Set("X-Cache", status)
+	if e.ContentType != "" {
+		w.Header().Set("Content-Type", e.ContentType)
+	}
+	if e.Checksum != "" {
+		w.Header().Set(checksumHeader, e.Checksum)
+	}
+	http.ServeContent(w, r, "", e.Fetched, bytes.NewReader(body))
+}
+
+// isTarball reports whether path is a cookbook download, which is cached
+// for good
+func isTarball(path string) bool {
+	return strings.HasSuffix(path, "/download")
+}
+
+// baseURL returns the URL the client reached the proxy at
+func (p *Proxy) baseURL(r *http.Request) string {
+	if p.options.BaseURL != "" {
+		return strings.TrimSuffix(p.options.BaseURL, "/")
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// rewrite replaces the upstream's URLs in a JSON body with base
+func (p *Proxy) rewrite(e *entry, body []byte, base string) []byte {
+	if e.Upstream == "" || !strings.Contains(e.ContentType, "json") {
+		return body
+	}
+	return bytes.ReplaceAll(body, []byte(e.Upstream), []byte(base))
+}
+
+// fetch returns the response to path cached as key, or else from the first
+// of upstreams that has it, caching it. Tarballs are served from the cache
+// however old, and API responses for the TTL. A cached response is served
+// however old when no upstream has a fresh one, reporting it as stale.
+func (p *Proxy) fetch(ctx context.Context, key, path string, upstreams []string, immutable bool) (*entry, []byte, string, error) {
+	cached, cachedBody, ok := p.store.get(key)
+	if ok && (immutable || p.options.Offline || time.Since(cached.Fetched) < p.options.TTL) {
+		return cached, cachedBody, cacheHit, nil
+	}
+	if p.options.Offline {
+		return nil, nil, "", errNotFound
+	}
+
+	var failures []error
+	for _, upstream := range upstreams {
+		e, body, err := p.get(ctx, upstream, path, immutable)
+		if errors.Is(err, errNotFound) {
+			continue
+		}
+		if err != nil {
+			failures = append(failures, err)
+			continue
+		}
+		if err := p.store.put(key, e, body); err != nil {
+			log.Warnf("Failed to cache %s: %v", key, err)
+		}
+		return e, body, cacheMiss, nil
+	}
+
+	if ok {
+		if len(failures) > 0 {
+			log.Warnf("Serving %s cached %s ago: %v", key, time.Since(cached.Fetched).Round(time.Second), errors.Join(failures...))
+		}
+		return cached, cachedBody, cacheStale, nil
+	}
+	if len(failures) > 0 {
+		return nil, nil, "", errors.Join(failures...)
+	}
+	return nil, nil, "", errNotFound
+}
+
+// get requests path from upstream
+func (p *Proxy) get(ctx context.Context, upstream, path string, tarball bool) (*entry, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, upstream+path, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if apiKey := p.options.APIKeys[upstream]; apiKey != "" {
+		req.Header.Set("X-Ops-Userid", apiKey)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s is unavailable: %w", upstream, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, nil, errNotFound
+	case resp.StatusCode != http.StatusOK:
+		return nil, nil, fmt.Errorf("%s answered %s with HTTP %d", upstream, path, resp.StatusCode)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading %s from %s: %w", path, upstream, err)
+	}
+	e := &entry{Upstream: upstream, ContentType: resp.Header.Get("Content-Type"), Fetched: time.Now().UTC()}
+	if tarball {
+		sum := sha256.Sum256(body)
+		e.Checksum = hex.EncodeToString(sum[:])
+	}
+	return e, body, nil
+}
+
+// universe merges the /universe of every upstream, rewritten to base. A
+// cookbook is listed with the versions of the first upstream that has it.
+func (p *Proxy) universe(ctx context.Context, base string) (*entry, []byte, string, error) {
+	if len(p.options.Upstreams) == 1 {
+		upstream := p.options.Upstreams[0]
+		e, body, status, err := p.fetch(ctx, universeKey(upstream), "/universe", p.options.Upstreams, false)
+		if err != nil {
+			return nil, nil, "", err
+		}
+		return e, p.rewrite(e, body, base), status, nil
+	}
+
+	merged := make(map[string]json.RawMessage)
+	merge := &entry{ContentType: "application/json"}
+	status := cacheHit
+	var failures []error
+	found := false
+	for _, upstream := range p.options.Upstreams {
+		e, body, fetched, err := p.fetch(ctx, universeKey(upstream), "/universe", []string{upstream}, false)
+		if errors.Is(err, errNotFound) {
+			continue
+		}
+		if err != nil {
+			failures = append(failures, err)
+			continue
+		}
+		found = true
+		if fetched != cacheHit {
+			status = fetched
+		}
+		if e.Fetched.After(merge.Fetched) {
+			merge.Fetched = e.Fetched
+		}
+
+		var cookbooks map[string]json.RawMessage
+		if err := json.Unmarshal(p.rewrite(e, body, base), &cookbooks); err != nil {
+			failures = append(failures, fmt.Errorf("invalid universe from %s: %w", upstream, err))
+			continue
+		}
+		for name, versions := range cookbooks {
+			if _, ok := merged[name]; !ok {
+				merged[name] = versions
+			}
+		}
+	}
+	if !found {
+		if len(failures) > 0 {
+			return nil, nil, "", errors.Join(failures...)
+		}
+		return nil, nil, "", errNotFound
+	}
+	if len(failures) > 0 {
+		log.Warnf("Universe is missing upstreams: %v", errors.Join(failures...))
+	}
+	body, err := json.Marshal(merged)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return merge, body, status, nil
+}
+
+// universeKey caches the universe of each upstream apart, to be merged
+func universeKey(upstream string) string {
+	return "/universe " + upstream
+}
+
+// writeError writes an error in the form the Supermarket API uses
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{
+		"error_code":     strings.ToUpper(strings.ReplaceAll(http.StatusText(status), " ", "_")),
+		"error_messages": []string{message},
+	})
+}
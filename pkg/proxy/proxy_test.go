@@ -0,0 +1,211 @@
+package proxy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	"github.com/bdwyertech/go-berkshelf/pkg/source"
+)
+
+// upstream is a fake Supermarket whose responses name its own URL, as a
+// real one's download links do
+type upstream struct {
+	*httptest.Server
+	hits atomic.Int32
+	down atomic.Bool
+}
+
+// newUpstream serves the given path → body responses, with {{url}} replaced
+// by the upstream URL
+func newUpstream(t *testing.T, responses map[string]string) *upstream {
+	t.Helper()
+	u := &upstream{}
+	u.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u.hits.Add(1)
+		if u.down.Load() {
+			http.Error(w, "maintenance", http.StatusServiceUnavailable)
+			return
+		}
+		body, ok := responses[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if strings.HasSuffix(r.URL.Path, "/download") {
+			w.Header().Set("Content-Type", "application/x-gzip")
+		} else {
+			w.Header().Set("Content-Type", "application/json")
+		}
+		io.WriteString(w, strings.ReplaceAll(body, "{{url}}", u.URL))
+	}))
+	t.Cleanup(u.Close)
+	return u
+}
+
+func newProxy(t *testing.T, options Options) *httptest.Server {
+	t.Helper()
+	if options.CacheDir == "" {
+		options.CacheDir = t.TempDir()
+	}
+	p, err := New(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(p)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// get requests path from server, returning the X-Cache status and body
+func get(t *testing.T, server *httptest.Server, path string) (int, http.Header, string) {
+	t.Helper()
+	resp, err := http.Get(server.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, resp.Header, string(body)
+}
+
+var nginx = map[string]string{
+	"/universe": `{"nginx": {"12.0.0": {"location_type": "opscode", "location_path": "{{url}}/api/v1",
+		"download_url": "{{url}}/api/v1/cookbooks/nginx/versions/12.0.0/download", "dependencies": {}}}}`,
+	"/api/v1/cookbooks/nginx": `{"name": "nginx", "latest_version": "{{url}}/api/v1/cookbooks/nginx/versions/12.0.0",
+		"versions": ["{{url}}/api/v1/cookbooks/nginx/versions/12.0.0"]}`,
+	"/api/v1/cookbooks/nginx/versions/12.0.0": `{"version": "12.0.0", "file": "{{url}}/api/v1/cookbooks/nginx/versions/12.0.0/download",
+		"dependencies": {}}`,
+	"/api/v1/cookbooks/nginx/versions/12.0.0/download": "nginx tarball",
+}
+
+func TestProxyCaches(t *testing.T) {
+	origin := newUpstream(t, nginx)
+	server := newProxy(t, Options{Upstreams: []string{origin.URL}})
+
+	status, header, body := get(t, server, "/api/v1/cookbooks/nginx")
+	if status != http.StatusOK || header.Get("X-Cache") != cacheMiss {
+		t.Fatalf("status = %d, X-Cache %q, want a 200 miss", status, header.Get("X-Cache"))
+	}
+	if strings.Contains(body, origin.URL) || !strings.Contains(body, server.URL+"/api/v1/cookbooks/nginx/versions/12.0.0") {
+		t.Errorf("body = %s, want the upstream URLs rewritten to the proxy", body)
+	}
+
+	hits := origin.hits.Load()
+	if _, header, _ := get(t, server, "/api/v1/cookbooks/nginx"); header.Get("X-Cache") != cacheHit || origin.hits.Load() != hits {
+		t.Errorf("X-Cache = %q after %d upstream requests, want a hit", header.Get("X-Cache"), origin.hits.Load()-hits)
+	}
+
+	// Tarballs pass through unchanged, with their checksum
+	status, header, body = get(t, server, "/api/v1/cookbooks/nginx/versions/12.0.0/download")
+	sum := sha256.Sum256([]byte("nginx tarball"))
+	if status != http.StatusOK || body != "nginx tarball" || header.Get(checksumHeader) != hex.EncodeToString(sum[:]) {
+		t.Errorf("download = %d %q, checksum %q", status, body, header.Get(checksumHeader))
+	}
+
+	if status, _, _ := get(t, server, "/api/v1/cookbooks/missing"); status != http.StatusNotFound {
+		t.Errorf("missing cookbook status = %d, want 404", status)
+	}
+}
+
+func TestProxyStale(t *testing.T) {
+	origin := newUpstream(t, nginx)
+	server := newProxy(t, Options{Upstreams: []string{origin.URL}, TTL: time.Nanosecond})
+
+	if status, _, _ := get(t, server, "/api/v1/cookbooks/nginx"); status != http.StatusOK {
+		t.Fatalf("status = %d", status)
+	}
+
+	// An unreachable upstream falls back to the cached response
+	origin.down.Store(true)
+	status, header, _ := get(t, server, "/api/v1/cookbooks/nginx")
+	if status != http.StatusOK || header.Get("X-Cache") != cacheStale {
+		t.Errorf("status = %d, X-Cache %q, want a stale 200", status, header.Get("X-Cache"))
+	}
+	if status, _, _ := get(t, server, "/api/v1/cookbooks/nginx/versions/12.0.0"); status != http.StatusBadGateway {
+		t.Errorf("uncached status = %d, want 502", status)
+	}
+}
+
+func TestProxyOffline(t *testing.T) {
+	origin := newUpstream(t, nginx)
+	dir := t.TempDir()
+	online := newProxy(t, Options{Upstreams: []string{origin.URL}, CacheDir: dir})
+	get(t, online, "/api/v1/cookbooks/nginx")
+
+	hits := origin.hits.Load()
+	offline := newProxy(t, Options{Upstreams: []string{origin.URL}, CacheDir: dir, Offline: true})
+	if status, header, _ := get(t, offline, "/api/v1/cookbooks/nginx"); status != http.StatusOK || header.Get("X-Cache") != cacheHit {
+		t.Errorf("cached status = %d, X-Cache %q", status, header.Get("X-Cache"))
+	}
+	if status, _, _ := get(t, offline, "/universe"); status != http.StatusNotFound {
+		t.Errorf("uncached status = %d, want 404", status)
+	}
+	if origin.hits.Load() != hits {
+		t.Error("the offline proxy asked the upstream")
+	}
+}
+
+func TestProxyUniverse(t *testing.T) {
+	first := newUpstream(t, nginx)
+	second := newUpstream(t, map[string]string{
+		"/universe": `{"nginx": {"1.0.0": {"download_url": "{{url}}/nginx-1.0.0"}},
+			"apt": {"7.4.0": {"download_url": "{{url}}/api/v1/cookbooks/apt/versions/7.4.0/download"}}}`,
+	})
+	server := newProxy(t, Options{Upstreams: []string{first.URL, second.URL}})
+
+	status, _, body := get(t, server, "/universe")
+	if status != http.StatusOK {
+		t.Fatalf("status = %d: %s", status, body)
+	}
+	var universe map[string]map[string]struct {
+		DownloadURL string `json:"download_url"`
+	}
+	if err := json.Unmarshal([]byte(body), &universe); err != nil {
+		t.Fatal(err)
+	}
+	// The first upstream with a cookbook provides all of its versions
+	if _, ok := universe["nginx"]["1.0.0"]; ok || len(universe["nginx"]) != 1 {
+		t.Errorf("nginx = %v, want only the first upstream's versions", universe["nginx"])
+	}
+	if got := universe["apt"]["7.4.0"].DownloadURL; got != server.URL+"/api/v1/cookbooks/apt/versions/7.4.0/download" {
+		t.Errorf("apt download_url = %q, want it through the proxy", got)
+	}
+}
+
+func TestProxySource(t *testing.T) {
+	origin := newUpstream(t, nginx)
+	server := newProxy(t, Options{Upstreams: []string{origin.URL}})
+
+	// The proxy is a Supermarket to the berks sources
+	src := source.NewSupermarketSource(server.URL)
+	versions, err := src.ListVersions(context.Background(), "nginx")
+	if err != nil || len(versions) != 1 || versions[0].String() != "12.0.0" {
+		t.Fatalf("ListVersions() = %v, %v", versions, err)
+	}
+	cookbook, err := src.FetchCookbook(context.Background(), "nginx", berkshelf.MustVersion("12.0.0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(cookbook.TarballURL, server.URL) {
+		t.Errorf("tarball URL = %s, want it through the proxy", cookbook.TarballURL)
+	}
+}
+
+func TestNewRequiresUpstream(t *testing.T) {
+	if _, err := New(Options{CacheDir: t.TempDir()}); err == nil {
+		t.Error("New() without upstreams succeeded")
+	}
+}
@@ -0,0 +1,95 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// entry describes a cached upstream response
+type entry struct {
+	// Upstream is the Supermarket the response came from, whose URLs in
+	// the body are rewritten to the proxy's
+	Upstream    string    `json:"upstream"`
+	ContentType string    `json:"content_type,omitempty"`
+	Fetched     time.Time `json:"fetched"`
+	// Checksum is the SHA-256 of a tarball
+	Checksum string `json:"checksum,omitempty"`
+}
+
+// store keeps upstream responses on disk, each as a body file and a JSON
+// file describing it, named for a hash of the request
+type store struct {
+	dir string
+}
+
+func newStore(dir string) (*store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &store{dir: dir}, nil
+}
+
+// paths returns the files holding the response cached as key
+func (s *store) paths(key string) (meta, body string) {
+	sum := sha256.Sum256([]byte(key))
+	name := hex.EncodeToString(sum[:])
+	base := filepath.Join(s.dir, name[:2], name)
+	return base + ".json", base + ".body"
+}
+
+// get returns the response cached as key
+func (s *store) get(key string) (*entry, []byte, bool) {
+	metaPath, bodyPath := s.paths(key)
+	data, err := os.ReadFile(metaPath)
+	if err != nil {
+		return nil, nil, false
+	}
+	var e entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		log.Debugf("Ignoring unreadable cache entry %s: %v", metaPath, err)
+		return nil, nil, false
+	}
+	body, err := os.ReadFile(bodyPath)
+	if err != nil {
+		return nil, nil, false
+	}
+	return &e, body, true
+}
+
+// put caches a response as key. The body is written before its description,
+// and each file is renamed into place, so readers never see a partial entry.
+func (s *store) put(key string, e *entry, body []byte) error {
+	metaPath, bodyPath := s.paths(key)
+	if err := os.MkdirAll(filepath.Dir(metaPath), 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if err := writeFile(bodyPath, body); err != nil {
+		return err
+	}
+	return writeFile(metaPath, data)
+}
+
+// writeFile replaces path with data through a temporary file
+func writeFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
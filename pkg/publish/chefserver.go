@@ -0,0 +1,456 @@
+package publish
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/cookbook"
+	"github.com/bdwyertech/go-berkshelf/pkg/digest"
+	berrors "github.com/bdwyertech/go-berkshelf/pkg/errors"
+	"github.com/bdwyertech/go-berkshelf/pkg/logging"
+)
+
+// ChefServerOptions configures an upload to a Chef Server organization
+type ChefServerOptions struct {
+	// URL is the organization URL, e.g. https://chef.example.com/organizations/acme
+	URL string
+	// Auth signs requests as a Chef user or client; it may be nil for a dry run
+	Auth Authenticator
+	// Freeze marks the uploaded version frozen so it cannot be overwritten
+	Freeze bool
+	// Force replaces a version the server already has, unless it is frozen
+	Force bool
+	// DryRun packages the cookbook and reports what would be uploaded
+	DryRun bool
+	// Algorithm computes the Digest of the packaged cookbook
+	Algorithm digest.Algorithm
+	// HTTPClient defaults to a client with a 5 minute timeout
+	HTTPClient *http.Client
+}
+
+// segments are the cookbook manifest sections for files below a directory of
+// the same name; other top-level files are root_files, and files in any other
+// directory are not part of a cookbook version
+var segments = []string{"attributes", "definitions", "files", "libraries", "providers", "recipes", "resources", "templates"}
+
+// manifestFile is one file in a cookbook version manifest
+type manifestFile struct {
+	Name        string `json:"name"`
+	Path        string `json:"path"`
+	Checksum    string `json:"checksum"`
+	Specificity string `json:"specificity"`
+}
+
+// sandbox is the Chef Server response to creating a sandbox
+type sandbox struct {
+	ID        string `json:"sandbox_id"`
+	Checksums map[string]struct {
+		URL         string `json:"url"`
+		NeedsUpload bool   `json:"needs_upload"`
+	} `json:"checksums"`
+}
+
+// UploadChefServer packages cookbookDir and uploads it to a Chef Server
+// organization as `knife cookbook upload` does: files the server does not
+// have go into a sandbox, and the cookbook version manifest is saved once
+// the sandbox is committed. A version the server already has with the same
+// files is skipped, returning a Result with Unchanged set. One whose files
+// differ is reported as ErrAlreadyPublished unless opts.Force is set, and a
+// frozen one, which cannot be replaced, as ErrFrozen when it is.
+func UploadChefServer(ctx context.Context, cookbookDir string, opts ChefServerOptions) (*Result, error) {
+	if opts.URL == "" {
+		return nil, fmt.Errorf("a Chef Server organization URL is required")
+	}
+
+	var tarball bytes.Buffer
+	manifest, err := cookbook.Package(cookbookDir, &tarball, cookbook.Options{Algorithm: opts.Algorithm})
+	if err != nil {
+		return nil, fmt.Errorf("failed to package %s: %w", cookbookDir, err)
+	}
+
+	orgURL := strings.TrimSuffix(opts.URL, "/")
+	result := &Result{
+		Name:     manifest.Name,
+		Version:  manifest.Version,
+		Endpoint: fmt.Sprintf("%s/cookbooks/%s/%s", orgURL, manifest.Name, manifest.Version),
+		Size:     tarball.Len(),
+		SHA256:   manifest.SHA256,
+		Digest:   manifest.Digest,
+		DryRun:   opts.DryRun,
+	}
+	if opts.DryRun {
+		return result, nil
+	}
+	if opts.Auth == nil {
+		return nil, fmt.Errorf("no credentials configured for %s", opts.URL)
+	}
+
+	contents, err := unpack(tarball.Bytes(), manifest.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read packaged %s: %w", manifest.Name, err)
+	}
+	metadataJSON, ok := contents["metadata.json"]
+	if !ok {
+		return nil, fmt.Errorf("%s has no metadata", cookbookDir)
+	}
+
+	client := opts.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Minute}
+	}
+	server := &chefServer{client: client, auth: opts.Auth}
+	logger := log.WithField(logging.CookbookField, manifest.Name)
+
+	status, body, err := server.do(ctx, http.MethodGet, result.Endpoint, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	switch status {
+	case http.StatusOK:
+		var existing serverVersion
+		if err := json.Unmarshal(body, &existing); err != nil {
+			return nil, fmt.Errorf("reading %s: %w", result.Endpoint, err)
+		}
+		// Freezing an unfrozen version takes an upload
+		if existing.sameFiles(manifest) && (existing.Frozen || !opts.Freeze) {
+			logger.Debugf("Skipping %s, the server has the same files", manifest.Version)
+			result.Unchanged = true
+			return result, nil
+		}
+		if !opts.Force {
+			return nil, &ErrAlreadyPublished{Name: manifest.Name, Version: manifest.Version}
+		}
+		if existing.Frozen {
+			return nil, &ErrFrozen{Name: manifest.Name, Version: manifest.Version}
+		}
+		logger.Debugf("Replacing %s on the server", manifest.Version)
+	case http.StatusNotFound:
+	default:
+		return nil, chefServerError(http.MethodGet, result.Endpoint, status, body)
+	}
+
+	checksums := make(map[string]any, len(manifest.Files))
+	for _, file := range manifest.Files {
+		checksums[file.MD5] = nil
+	}
+	var box sandbox
+	if err := server.json(ctx, http.MethodPost, orgURL+"/sandboxes", map[string]any{"checksums": checksums}, &box); err != nil {
+		return nil, fmt.Errorf("creating sandbox: %w", err)
+	}
+
+	for _, file := range manifest.Files {
+		item, ok := box.Checksums[file.MD5]
+		if !ok || !item.NeedsUpload {
+			continue
+		}
+		logger.Debugf("Uploading %s (%d bytes)", file.Path, file.Size)
+		if err := server.uploadFile(ctx, item.URL, file.MD5, contents[file.Path]); err != nil {
+			return nil, fmt.Errorf("uploading %s: %w", file.Path, err)
+		}
+	}
+
+	if err := server.json(ctx, http.MethodPut, orgURL+"/sandboxes/"+box.ID, map[string]bool{"is_completed": true}, nil); err != nil {
+		return nil, fmt.Errorf("committing sandbox: %w", err)
+	}
+
+	version := cookbookVersion(manifest, metadataJSON, opts.Freeze)
+	endpoint := result.Endpoint
+	if opts.Force {
+		endpoint += "?force=true"
+	}
+	if err := server.json(ctx, http.MethodPut, endpoint, version, nil); err != nil {
+		var statusErr *chefServerStatusError
+		if errors.As(err, &statusErr) && statusErr.status == http.StatusConflict {
+			// Chef Server refuses to replace a frozen version even when forced
+			if opts.Force {
+				return nil, &ErrFrozen{Name: manifest.Name, Version: manifest.Version}
+			}
+			return nil, &ErrAlreadyPublished{Name: manifest.Name, Version: manifest.Version}
+		}
+		return nil, fmt.Errorf("saving %s %s: %w", manifest.Name, manifest.Version, err)
+	}
+
+	result.URI = result.Endpoint
+	return result, nil
+}
+
+// serverVersion is the part of a cookbook version on the server compared
+// with a local cookbook
+type serverVersion struct {
+	Frozen bool `json:"frozen?"`
+	// AllFiles lists every file under API version 1; servers on version 0
+	// group the files into segments instead
+	AllFiles []manifestFile             `json:"all_files"`
+	Segments map[string]json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON keeps the segment lists alongside the known fields
+func (v *serverVersion) UnmarshalJSON(data []byte) error {
+	type plain serverVersion
+	if err := json.Unmarshal(data, (*plain)(v)); err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &v.Segments)
+}
+
+// checksums returns the checksum of each file of the version by path
+func (v *serverVersion) checksums() map[string]string {
+	files := v.AllFiles
+	if len(files) == 0 {
+		for _, segment := range append([]string{"root_files"}, segments...) {
+			var segmentFiles []manifestFile
+			if json.Unmarshal(v.Segments[segment], &segmentFiles) == nil {
+				files = append(files, segmentFiles...)
+			}
+		}
+	}
+	checksums := make(map[string]string, len(files))
+	for _, file := range files {
+		checksums[file.Path] = file.Checksum
+	}
+	return checksums
+}
+
+// sameFiles reports whether the version has exactly the files of the
+// manifest that a cookbook version holds
+func (v *serverVersion) sameFiles(manifest *cookbook.Manifest) bool {
+	remote := v.checksums()
+	local := make(map[string]string, len(manifest.Files))
+	for _, file := range manifest.Files {
+		if _, _, ok := segmentFor(file.Path); ok {
+			local[file.Path] = file.MD5
+		}
+	}
+	return len(local) > 0 && maps.Equal(local, remote)
+}
+
+// cookbookVersion builds the cookbook version document, with files grouped
+// into segments as Chef Server API version 1 expects
+func cookbookVersion(manifest *cookbook.Manifest, metadataJSON []byte, frozen bool) map[string]any {
+	version := map[string]any{
+		"name":          manifest.Name + "-" + manifest.Version,
+		"cookbook_name": manifest.Name,
+		"version":       manifest.Version,
+		"chef_type":     "cookbook_version",
+		"json_class":    "Chef::CookbookVersion",
+		"frozen?":       frozen,
+		"metadata":      json.RawMessage(metadataJSON),
+	}
+
+	grouped := map[string][]manifestFile{"root_files": {}}
+	for _, segment := range segments {
+		grouped[segment] = []manifestFile{}
+	}
+	for _, file := range manifest.Files {
+		segment, specificity, ok := segmentFor(file.Path)
+		if !ok {
+			log.WithField(logging.CookbookField, manifest.Name).Debugf("Skipping %s, which is outside the cookbook segments", file.Path)
+			continue
+		}
+		grouped[segment] = append(grouped[segment], manifestFile{
+			Name:        path.Base(file.Path),
+			Path:        file.Path,
+			Checksum:    file.MD5,
+			Specificity: specificity,
+		})
+	}
+	for segment, files := range grouped {
+		version[segment] = files
+	}
+	return version
+}
+
+// segmentFor returns the manifest segment and specificity of a cookbook file.
+// Files and templates take their specificity from a host or platform
+// directory, e.g. templates/ubuntu/foo.erb.
+func segmentFor(rel string) (segment, specificity string, ok bool) {
+	dir, rest, nested := strings.Cut(rel, "/")
+	if !nested {
+		return "root_files", "default", true
+	}
+	for _, s := range segments {
+		if s != dir {
+			continue
+		}
+		specificity = "default"
+		if s == "files" || s == "templates" {
+			if sub, _, deeper := strings.Cut(rest, "/"); deeper {
+				specificity = sub
+			}
+		}
+		return s, specificity, true
+	}
+	return "", "", false
+}
+
+// unpack reads the files of a packaged cookbook, keyed by path below the
+// cookbook directory
+func unpack(tarball []byte, name string) (map[string][]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(tarball))
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	contents := make(map[string][]byte)
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return contents, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		contents[strings.TrimPrefix(header.Name, name+"/")] = data
+	}
+}
+
+// ErrFrozen is returned when a forced upload would replace a version the
+// Chef Server has frozen
+type ErrFrozen struct {
+	Name    string
+	Version string
+}
+
+func (e *ErrFrozen) Error() string {
+	return fmt.Sprintf("%s %s is frozen on the Chef Server and cannot be replaced, even with force; bump the version in metadata.rb", e.Name, e.Version)
+}
+
+// chefServer makes signed Chef Server API requests
+type chefServer struct {
+	client *http.Client
+	auth   Authenticator
+}
+
+// chefServerStatusError is an unexpected Chef Server response
+type chefServerStatusError struct {
+	status  int
+	message string
+}
+
+func (e *chefServerStatusError) Error() string {
+	switch e.status {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return fmt.Sprintf("request rejected (HTTP %d), check the client name and key: %s", e.status, e.message)
+	}
+	return fmt.Sprintf("HTTP %d: %s", e.status, e.message)
+}
+
+// ErrorType reports rejected credentials as authentication errors
+func (e *chefServerStatusError) ErrorType() berrors.ErrorType {
+	switch e.status {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return berrors.ErrorTypeAuthentication
+	}
+	return ""
+}
+
+func chefServerError(method, url string, status int, body []byte) error {
+	var parsed struct {
+		Error []string `json:"error"`
+	}
+	_ = json.Unmarshal(body, &parsed)
+	message := strings.Join(parsed.Error, "; ")
+	if message == "" {
+		message = strings.TrimSpace(string(body))
+	}
+	if message == "" {
+		message = fmt.Sprintf("%s %s", method, url)
+	}
+	return &chefServerStatusError{status: status, message: message}
+}
+
+// do sends a signed request and returns the response status and body
+func (s *chefServer) do(ctx context.Context, method, url string, body []byte, contentType string) (int, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("X-Ops-Server-API-Version", "1")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if err := s.auth.Authenticate(req, body); err != nil {
+		return 0, nil, fmt.Errorf("failed to authenticate request: %w", err)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%s %s: %w", method, url, err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%s %s: %w", method, url, err)
+	}
+	return resp.StatusCode, respBody, nil
+}
+
+// json sends in as a JSON body and decodes the response into out, if given
+func (s *chefServer) json(ctx context.Context, method, url string, in, out any) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	status, respBody, err := s.do(ctx, method, url, body, "application/json")
+	if err != nil {
+		return err
+	}
+	if status < 200 || status > 299 {
+		return chefServerError(method, url, status, respBody)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(respBody, out)
+}
+
+// uploadFile puts one file into a sandbox
+func (s *chefServer) uploadFile(ctx context.Context, url, md5Hex string, data []byte) error {
+	md5sum, err := hex.DecodeString(md5Hex)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-binary")
+	req.Header.Set("Content-MD5", base64.StdEncoding.EncodeToString(md5sum))
+	if err := s.auth.Authenticate(req, data); err != nil {
+		return fmt.Errorf("failed to authenticate request: %w", err)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return chefServerError(http.MethodPut, url, resp.StatusCode, body)
+	}
+	return nil
+}
@@ -0,0 +1,353 @@
+package publish
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/cookbook"
+)
+
+// fakeChefServer is an organization that already has the checksums in known
+type fakeChefServer struct {
+	mu        sync.Mutex
+	known     map[string]bool
+	uploaded  map[string][]byte
+	committed bool
+	versions  map[string]map[string]any
+	unsigned  []string
+	paths     []string
+	// hideFrozen leaves frozen? out of GET responses, as if the version was
+	// frozen after it was read
+	hideFrozen bool
+}
+
+func newFakeChefServer(t *testing.T, known ...string) (*fakeChefServer, *httptest.Server) {
+	t.Helper()
+	fake := &fakeChefServer{known: map[string]bool{}, uploaded: map[string][]byte{}, versions: map[string]map[string]any{}}
+	for _, sum := range known {
+		fake.known[sum] = true
+	}
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+		if r.Header.Get("X-Ops-Authorization-1") == "" {
+			fake.unsigned = append(fake.unsigned, r.Method+" "+r.URL.Path)
+		}
+		fake.paths = append(fake.paths, r.URL.Path)
+		// The organization may be below the path prefix of a reverse proxy
+		path := r.URL.Path
+		if _, rest, ok := strings.Cut(path, "/organizations/acme/"); ok {
+			path = rest
+		}
+		body, _ := io.ReadAll(r.Body)
+
+		switch {
+		case r.Method == http.MethodPost && path == "sandboxes":
+			var req struct {
+				Checksums map[string]any `json:"checksums"`
+			}
+			json.Unmarshal(body, &req)
+			checksums := map[string]any{}
+			for sum := range req.Checksums {
+				checksums[sum] = map[string]any{
+					"url":          fmt.Sprintf("%s/bookshelf/%s", server.URL, sum),
+					"needs_upload": !fake.known[sum],
+				}
+			}
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(map[string]any{"sandbox_id": "abc123", "checksums": checksums})
+		case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/bookshelf/"):
+			if r.Header.Get("Content-MD5") == "" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			fake.uploaded[strings.TrimPrefix(r.URL.Path, "/bookshelf/")] = body
+		case r.Method == http.MethodPut && path == "sandboxes/abc123":
+			fake.committed = true
+		case strings.HasPrefix(path, "cookbooks/"):
+			key := strings.TrimPrefix(path, "cookbooks/")
+			existing, exists := fake.versions[key]
+			switch r.Method {
+			case http.MethodGet:
+				if !exists {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				if fake.hideFrozen {
+					existing = maps.Clone(existing)
+					delete(existing, "frozen?")
+				}
+				json.NewEncoder(w).Encode(existing)
+			case http.MethodPut:
+				if exists && existing["frozen?"] == true {
+					w.WriteHeader(http.StatusConflict)
+					w.Write([]byte(`{"error": ["The cookbook apt at version 7.4.0 is frozen."]}`))
+					return
+				}
+				var version map[string]any
+				json.Unmarshal(body, &version)
+				fake.versions[key] = version
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return fake, server
+}
+
+func TestUploadChefServer(t *testing.T) {
+	fake, server := newFakeChefServer(t)
+	auth, err := NewChefKeyAuth("jdoe", writeKey(t))
+	if err != nil {
+		t.Fatalf("NewChefKeyAuth() error = %v", err)
+	}
+	opts := ChefServerOptions{URL: server.URL + "/organizations/acme/", Auth: auth, Freeze: true}
+
+	result, err := UploadChefServer(context.Background(), writeCookbook(t), opts)
+	if err != nil {
+		t.Fatalf("UploadChefServer() error = %v", err)
+	}
+
+	if len(fake.unsigned) > 0 {
+		t.Errorf("unsigned requests: %v", fake.unsigned)
+	}
+	if !fake.committed || len(fake.uploaded) != 3 {
+		t.Errorf("sandbox committed %v with %d file(s), want 3", fake.committed, len(fake.uploaded))
+	}
+	version := fake.versions["apt/7.4.0"]
+	if version == nil {
+		t.Fatal("cookbook version was not saved")
+	}
+	recipes, _ := version["recipes"].([]any)
+	if len(recipes) != 1 || recipes[0].(map[string]any)["path"] != "recipes/default.rb" {
+		t.Errorf("recipes = %v", version["recipes"])
+	}
+	if rootFiles, _ := version["root_files"].([]any); len(rootFiles) != 2 {
+		t.Errorf("root_files = %v, want metadata.json and metadata.rb", version["root_files"])
+	}
+	if version["frozen?"] != true || version["cookbook_name"] != "apt" {
+		t.Errorf("cookbook version = %v", version)
+	}
+	if result.Endpoint != server.URL+"/organizations/acme/cookbooks/apt/7.4.0" {
+		t.Errorf("Endpoint = %q", result.Endpoint)
+	}
+
+	// Unchanged versions are skipped without uploading
+	fake.committed = false
+	unchanged, err := UploadChefServer(context.Background(), writeCookbook(t), opts)
+	if err != nil || !unchanged.Unchanged || fake.committed {
+		t.Errorf("second upload = %+v, %v, want unchanged without a sandbox", unchanged, err)
+	}
+
+	// Changed versions are not replaced, and frozen ones cannot be forced
+	changed := writeChangedCookbook(t)
+	var already *ErrAlreadyPublished
+	if _, err := UploadChefServer(context.Background(), changed, opts); !errors.As(err, &already) {
+		t.Errorf("upload of a changed version error = %v, want ErrAlreadyPublished", err)
+	}
+	opts.Force = true
+	var frozen *ErrFrozen
+	if _, err := UploadChefServer(context.Background(), changed, opts); !errors.As(err, &frozen) {
+		t.Errorf("forced upload of a frozen version error = %v, want ErrFrozen", err)
+	}
+}
+
+// writeChangedCookbook writes the cookbook of writeCookbook with another recipe
+func writeChangedCookbook(t *testing.T) string {
+	t.Helper()
+	dir := writeCookbook(t)
+	if err := os.WriteFile(filepath.Join(dir, "recipes", "default.rb"), []byte("apt_update 'daily'\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestUploadChefServer_ForceReplacesUnfrozen(t *testing.T) {
+	fake, server := newFakeChefServer(t)
+	auth, err := NewChefKeyAuth("jdoe", writeKey(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := ChefServerOptions{URL: server.URL + "/organizations/acme", Auth: auth}
+	if _, err := UploadChefServer(context.Background(), writeCookbook(t), opts); err != nil {
+		t.Fatalf("UploadChefServer() error = %v", err)
+	}
+
+	opts.Force = true
+	changed := writeChangedCookbook(t)
+	replaced, err := UploadChefServer(context.Background(), changed, opts)
+	if err != nil || replaced.Unchanged {
+		t.Fatalf("forced UploadChefServer() = %+v, %v", replaced, err)
+	}
+	recipes, _ := fake.versions["apt/7.4.0"]["recipes"].([]any)
+	if len(recipes) != 1 || recipes[0].(map[string]any)["checksum"] == "88eaceabaf03df6cff608f80c2d2fe8d" {
+		t.Errorf("forced upload did not replace the recipe: %v", recipes)
+	}
+
+	// A version frozen between the check and the save is refused with a 409
+	fake.versions["apt/7.4.0"]["frozen?"] = true
+	fake.hideFrozen = true
+	var frozen *ErrFrozen
+	if _, err := UploadChefServer(context.Background(), writeCookbook(t), opts); !errors.As(err, &frozen) {
+		t.Errorf("forced upload error = %v, want ErrFrozen", err)
+	}
+}
+
+func TestServerVersionSameFiles(t *testing.T) {
+	var tarball bytes.Buffer
+	manifest, err := cookbook.Package(writeCookbook(t), &tarball, cookbook.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var allFiles []map[string]string
+	for _, file := range manifest.Files {
+		allFiles = append(allFiles, map[string]string{"name": file.Path, "path": file.Path, "checksum": file.MD5})
+	}
+
+	// API version 1 lists all_files
+	data, _ := json.Marshal(map[string]any{"all_files": allFiles})
+	var version serverVersion
+	if err := json.Unmarshal(data, &version); err != nil {
+		t.Fatal(err)
+	}
+	if !version.sameFiles(manifest) {
+		t.Errorf("sameFiles() = false for all_files %v", allFiles)
+	}
+	allFiles[0]["checksum"] = "0123456789abcdef0123456789abcdef"
+	data, _ = json.Marshal(map[string]any{"all_files": allFiles})
+	version = serverVersion{}
+	if err := json.Unmarshal(data, &version); err != nil {
+		t.Fatal(err)
+	}
+	if version.sameFiles(manifest) {
+		t.Error("sameFiles() = true with a changed checksum")
+	}
+}
+
+func TestUploadChefServer_SkipsKnownFiles(t *testing.T) {
+	dir := writeCookbook(t)
+	dryRun, err := UploadChefServer(context.Background(), dir, ChefServerOptions{URL: "https://chef.example.com/organizations/acme", DryRun: true})
+	if err != nil || !dryRun.DryRun {
+		t.Fatalf("dry run = %+v, %v", dryRun, err)
+	}
+
+	// The recipe's MD5 is already on the server
+	fake, server := newFakeChefServer(t, "88eaceabaf03df6cff608f80c2d2fe8d")
+	auth, err := NewChefKeyAuth("jdoe", writeKey(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := UploadChefServer(context.Background(), dir, ChefServerOptions{URL: server.URL + "/organizations/acme", Auth: auth}); err != nil {
+		t.Fatalf("UploadChefServer() error = %v", err)
+	}
+	if _, ok := fake.uploaded["88eaceabaf03df6cff608f80c2d2fe8d"]; ok || len(fake.uploaded) != 2 {
+		t.Errorf("uploaded %d file(s); the known file should be skipped", len(fake.uploaded))
+	}
+}
+
+// newAuthProxy returns the URL, with credentials, of a forward proxy that
+// turns away requests without them, and a count of the requests it forwarded
+func newAuthProxy(t *testing.T) (*url.URL, *atomic.Int32) {
+	t.Helper()
+	var forwarded atomic.Int32
+	want := "Basic " + base64.StdEncoding.EncodeToString([]byte("proxyuser:s3cret"))
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Proxy-Authorization") != want {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+		out := r.Clone(r.Context())
+		out.RequestURI = ""
+		out.Header.Del("Proxy-Authorization")
+		resp, err := http.DefaultTransport.RoundTrip(out)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+		forwarded.Add(1)
+		maps.Copy(w.Header(), resp.Header)
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+	}))
+	t.Cleanup(proxy.Close)
+
+	proxyURL, err := url.Parse(proxy.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxyURL.User = url.UserPassword("proxyuser", "s3cret")
+	return proxyURL, &forwarded
+}
+
+func TestUploadChefServer_ReverseProxyPrefix(t *testing.T) {
+	fake, server := newFakeChefServer(t)
+	auth, err := NewChefKeyAuth("jdoe", writeKey(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxyURL, forwarded := newAuthProxy(t)
+	opts := ChefServerOptions{
+		// httptest servers listen on a nonstandard port
+		URL:        server.URL + "/chef/organizations/acme",
+		Auth:       auth,
+		HTTPClient: &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}},
+	}
+
+	result, err := UploadChefServer(context.Background(), writeCookbook(t), opts)
+	if err != nil {
+		t.Fatalf("UploadChefServer() error = %v", err)
+	}
+	if result.URI != server.URL+"/chef/organizations/acme/cookbooks/apt/7.4.0" {
+		t.Errorf("URI = %q", result.URI)
+	}
+	if fake.versions["apt/7.4.0"] == nil || !fake.committed {
+		t.Error("cookbook version was not saved")
+	}
+	for _, path := range fake.paths {
+		if !strings.HasPrefix(path, "/chef/organizations/acme/") && !strings.HasPrefix(path, "/bookshelf/") {
+			t.Errorf("request to %s lost the path prefix", path)
+		}
+	}
+	if int(forwarded.Load()) != len(fake.paths) {
+		t.Errorf("proxy forwarded %d of %d requests", forwarded.Load(), len(fake.paths))
+	}
+}
+
+func TestSegmentFor(t *testing.T) {
+	tests := []struct {
+		path, segment, specificity string
+		ok                         bool
+	}{
+		{"metadata.rb", "root_files", "default", true},
+		{"recipes/default.rb", "recipes", "default", true},
+		{"templates/ubuntu/app.conf.erb", "templates", "ubuntu", true},
+		{"templates/app.conf.erb", "templates", "default", true},
+		{"files/default/sub/script.sh", "files", "default", true},
+		{"spec/default_spec.rb", "", "", false},
+	}
+	for _, tt := range tests {
+		segment, specificity, ok := segmentFor(tt.path)
+		if segment != tt.segment || specificity != tt.specificity || ok != tt.ok {
+			t.Errorf("segmentFor(%q) = %q, %q, %v", tt.path, segment, specificity, ok)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+// Package publish uploads packaged cookbooks to a Supermarket-compatible API,
+// as `knife supermarket share` does. Artifactory's Chef repositories expose
+// the same API below /api/chef/<repo>.
+package publish
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-chef/chef"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/cookbook"
+	"github.com/bdwyertech/go-berkshelf/pkg/digest"
+	berrors "github.com/bdwyertech/go-berkshelf/pkg/errors"
+	"github.com/bdwyertech/go-berkshelf/pkg/logging"
+)
+
+var log = logging.For("publish")
+
+// DefaultCategory is the Supermarket category used when none is given
+const DefaultCategory = "Other"
+
+// Authenticator adds credentials to an upload request. body is the exact
+// request body, for schemes that sign it.
+type Authenticator interface {
+	Authenticate(req *http.Request, body []byte) error
+}
+
+// ChefKeyAuth signs requests with a Chef user or client key, which is what
+// a Supermarket expects
+type ChefKeyAuth struct {
+	auth chef.AuthConfig
+}
+
+// NewChefKeyAuth reads the PEM private key at keyPath for user
+func NewChefKeyAuth(user, keyPath string) (*ChefKeyAuth, error) {
+	if strings.HasPrefix(keyPath, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("getting home directory: %w", err)
+		}
+		keyPath = filepath.Join(home, keyPath[2:])
+	}
+
+	data, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading client key file %s: %w", keyPath, err)
+	}
+	key, err := chef.PrivateKeyFromString(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing client key file %s: %w", keyPath, err)
+	}
+
+	return &ChefKeyAuth{auth: chef.AuthConfig{
+		PrivateKey:            key,
+		ClientName:            user,
+		AuthenticationVersion: chef.AuthVersion10,
+	}}, nil
+}
+
+// Authenticate signs req using the Chef authentication protocol 1.0
+func (a *ChefKeyAuth) Authenticate(req *http.Request, body []byte) error {
+	sum := sha1.Sum(body)
+	req.Header.Set("X-Ops-Content-Hash", base64.StdEncoding.EncodeToString(sum[:]))
+	return a.auth.SignRequest(req)
+}
+
+// ArtifactoryAuth authenticates to Artifactory with an API key or identity token
+type ArtifactoryAuth struct {
+	APIKey string
+}
+
+// Authenticate sets the Artifactory API key header
+func (a ArtifactoryAuth) Authenticate(req *http.Request, body []byte) error {
+	req.Header.Set("X-JFrog-Art-Api", a.APIKey)
+	return nil
+}
+
+// IsArtifactory reports whether url is an Artifactory Chef repository
+func IsArtifactory(url string) bool {
+	return strings.Contains(url, "/api/chef/")
+}
+
+// Options configures a publish
+type Options struct {
+	// URL is the Supermarket or Artifactory repository base URL
+	URL string
+	// Category is the Supermarket category; DefaultCategory when empty
+	Category string
+	// Auth authenticates the upload; it may be nil for a dry run
+	Auth Authenticator
+	// DryRun packages the cookbook and reports what would be uploaded
+	DryRun bool
+	// Algorithm computes the Digest of the packaged cookbook
+	Algorithm digest.Algorithm
+	// HTTPClient defaults to a client with a 5 minute timeout
+	HTTPClient *http.Client
+}
+
+// Result describes a published cookbook
+type Result struct {
+	Name     string `json:"name"`
+	Version  string `json:"version"`
+	Endpoint string `json:"endpoint"`
+	Category string `json:"category"`
+	Size     int    `json:"size"`
+	SHA256   string `json:"sha256"`
+	Digest   string `json:"digest"`
+	DryRun   bool   `json:"dry_run,omitempty"`
+	// URI is the cookbook version URI returned by the server
+	URI string `json:"uri,omitempty"`
+	// Unchanged is set when a Chef Server already had the version with the
+	// same files, so nothing was uploaded
+	Unchanged bool `json:"unchanged,omitempty"`
+}
+
+// ErrAlreadyPublished is returned when the server already has the cookbook version
+type ErrAlreadyPublished struct {
+	Name    string
+	Version string
+}
+
+func (e *ErrAlreadyPublished) Error() string {
+	return fmt.Sprintf("%s %s is already published; bump the version in metadata.rb", e.Name, e.Version)
+}
+
+// errorResponse is the Supermarket error body
+type errorResponse struct {
+	ErrorCode     string   `json:"error_code"`
+	ErrorMessages []string `json:"error_messages"`
+}
+
+// Publish packages cookbookDir and uploads it to opts.URL
+func Publish(ctx context.Context, cookbookDir string, opts Options) (*Result, error) {
+	if opts.URL == "" {
+		return nil, fmt.Errorf("a Supermarket or Artifactory URL is required")
+	}
+	category := opts.Category
+	if category == "" {
+		category = DefaultCategory
+	}
+
+	var tarball bytes.Buffer
+	manifest, err := cookbook.Package(cookbookDir, &tarball, cookbook.Options{Algorithm: opts.Algorithm})
+	if err != nil {
+		return nil, fmt.Errorf("failed to package %s: %w", cookbookDir, err)
+	}
+
+	result := &Result{
+		Name:     manifest.Name,
+		Version:  manifest.Version,
+		Endpoint: strings.TrimSuffix(opts.URL, "/") + "/api/v1/cookbooks",
+		Category: category,
+		Size:     tarball.Len(),
+		SHA256:   manifest.SHA256,
+		Digest:   manifest.Digest,
+		DryRun:   opts.DryRun,
+	}
+	if opts.DryRun {
+		return result, nil
+	}
+	if opts.Auth == nil {
+		return nil, fmt.Errorf("no credentials configured for %s", opts.URL)
+	}
+
+	body, contentType, err := multipartBody(manifest.Name, category, tarball.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, result.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Accept", "application/json")
+	if err := opts.Auth.Authenticate(req, body); err != nil {
+		return nil, fmt.Errorf("failed to authenticate request: %w", err)
+	}
+
+	client := opts.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Minute}
+	}
+
+	log.WithField(logging.CookbookField, manifest.Name).Debugf("Uploading %s %s (%d bytes) to %s", manifest.Name, manifest.Version, len(body), result.Endpoint)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("uploading to %s: %w", result.Endpoint, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return nil, uploadError(resp.StatusCode, respBody, manifest)
+	}
+
+	var created struct {
+		URI string `json:"uri"`
+	}
+	if err := json.Unmarshal(respBody, &created); err == nil {
+		result.URI = created.URI
+	}
+	return result, nil
+}
+
+// multipartBody builds the share request: a "cookbook" field holding the
+// category as JSON and the tarball as "tarball"
+func multipartBody(name, category string, tarball []byte) ([]byte, string, error) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+
+	fields, err := json.Marshal(map[string]string{"category": category})
+	if err != nil {
+		return nil, "", err
+	}
+	if err := w.WriteField("cookbook", string(fields)); err != nil {
+		return nil, "", fmt.Errorf("building request: %w", err)
+	}
+
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="tarball"; filename="%s.tgz"`, name))
+	header.Set("Content-Type", "application/x-gzip")
+	part, err := w.CreatePart(header)
+	if err != nil {
+		return nil, "", fmt.Errorf("building request: %w", err)
+	}
+	if _, err := part.Write(tarball); err != nil {
+		return nil, "", fmt.Errorf("building request: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", fmt.Errorf("building request: %w", err)
+	}
+	return buf.Bytes(), w.FormDataContentType(), nil
+}
+
+// uploadError converts a failed share response into an error
+func uploadError(status int, body []byte, manifest *cookbook.Manifest) error {
+	var parsed errorResponse
+	_ = json.Unmarshal(body, &parsed)
+	message := strings.Join(parsed.ErrorMessages, "; ")
+	if message == "" {
+		message = strings.TrimSpace(string(body))
+	}
+
+	if status == http.StatusConflict || strings.Contains(strings.ToLower(message), "already exists") {
+		return &ErrAlreadyPublished{Name: manifest.Name, Version: manifest.Version}
+	}
+	switch status {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return berrors.WithType(fmt.Errorf("upload rejected (HTTP %d), check the user, key or API key: %s", status, message), berrors.ErrorTypeAuthentication)
+	}
+	return fmt.Errorf("upload failed (HTTP %d): %s", status, message)
+}
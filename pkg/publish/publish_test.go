@@ -0,0 +1,180 @@
+package publish
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeCookbook(t *testing.T) string {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "apt")
+	if err := os.MkdirAll(filepath.Join(dir, "recipes"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"metadata.rb":        "name 'apt'\nversion '7.4.0'\n",
+		"recipes/default.rb": "apt_update\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func writeKey(t *testing.T) string {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "client.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestPublish_Supermarket(t *testing.T) {
+	var gotCategory, gotFilename, gotUser string
+	var gotSigned bool
+	var gotSize int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v1/cookbooks" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		gotUser = r.Header.Get("X-Ops-Userid")
+		gotSigned = r.Header.Get("X-Ops-Authorization-1") != "" && r.Header.Get("X-Ops-Content-Hash") != ""
+
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("ParseMultipartForm() error = %v", err)
+		}
+		var fields map[string]string
+		json.Unmarshal([]byte(r.FormValue("cookbook")), &fields)
+		gotCategory = fields["category"]
+		file, header, err := r.FormFile("tarball")
+		if err == nil {
+			gotFilename = header.Filename
+			data, _ := io.ReadAll(file)
+			gotSize = len(data)
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"uri": "https://supermarket.example.com/api/v1/cookbooks/apt"}`))
+	}))
+	defer server.Close()
+
+	auth, err := NewChefKeyAuth("jdoe", writeKey(t))
+	if err != nil {
+		t.Fatalf("NewChefKeyAuth() error = %v", err)
+	}
+
+	result, err := Publish(context.Background(), writeCookbook(t), Options{
+		URL:      server.URL + "/",
+		Category: "Utilities",
+		Auth:     auth,
+	})
+	if err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+
+	if gotUser != "jdoe" || !gotSigned {
+		t.Errorf("request was not signed for jdoe (user %q, signed %v)", gotUser, gotSigned)
+	}
+	if gotCategory != "Utilities" || gotFilename != "apt.tgz" || gotSize != result.Size {
+		t.Errorf("multipart fields = category %q, file %q (%d bytes)", gotCategory, gotFilename, gotSize)
+	}
+	if result.Name != "apt" || result.Version != "7.4.0" || result.URI == "" {
+		t.Errorf("Publish() = %+v", result)
+	}
+}
+
+func TestPublish_Artifactory(t *testing.T) {
+	var gotKey string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotKey = r.Header.Get("X-JFrog-Art-Api")
+		if r.URL.Path != "/artifactory/api/chef/chef-local/api/v1/cookbooks" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	url := server.URL + "/artifactory/api/chef/chef-local"
+	if !IsArtifactory(url) {
+		t.Fatalf("IsArtifactory(%q) = false", url)
+	}
+	if _, err := Publish(context.Background(), writeCookbook(t), Options{URL: url, Auth: ArtifactoryAuth{APIKey: "s3cret"}}); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if gotKey != "s3cret" {
+		t.Errorf("X-JFrog-Art-Api = %q, want s3cret", gotKey)
+	}
+}
+
+func TestPublish_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		already  bool
+		contains string
+	}{
+		{name: "conflict", status: http.StatusConflict, already: true},
+		{name: "already exists message", status: http.StatusBadRequest, body: `{"error_code": "INVALID_DATA", "error_messages": ["Version already exists"]}`, already: true},
+		{name: "unauthorized", status: http.StatusUnauthorized, body: `{"error_messages": ["bad signature"]}`, contains: "bad signature"},
+		{name: "server error", status: http.StatusInternalServerError, body: "boom", contains: "HTTP 500"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			_, err := Publish(context.Background(), writeCookbook(t), Options{URL: server.URL, Auth: ArtifactoryAuth{APIKey: "k"}})
+			if err == nil {
+				t.Fatal("Publish() expected error")
+			}
+			var already *ErrAlreadyPublished
+			if errors.As(err, &already) != tt.already {
+				t.Errorf("Publish() error = %v, already published = %v", err, tt.already)
+			}
+			if tt.contains != "" && !strings.Contains(err.Error(), tt.contains) {
+				t.Errorf("Publish() error = %q, want it to contain %q", err, tt.contains)
+			}
+		})
+	}
+}
+
+func TestPublish_DryRun(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("dry run sent a request")
+	}))
+	defer server.Close()
+
+	result, err := Publish(context.Background(), writeCookbook(t), Options{URL: server.URL, DryRun: true})
+	if err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if !result.DryRun || result.Category != DefaultCategory || result.Endpoint != server.URL+"/api/v1/cookbooks" || result.SHA256 == "" {
+		t.Errorf("Publish() = %+v", result)
+	}
+}
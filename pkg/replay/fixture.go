@@ -0,0 +1,177 @@
+// Package replay records what cookbook sources answer during a resolution
+// and replays it without the network, so a resolution can be kept as a
+// fixture and the resolver checked to still choose the same versions.
+package replay
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/goccy/go-json"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	"github.com/bdwyertech/go-berkshelf/pkg/resolver"
+	"github.com/bdwyertech/go-berkshelf/pkg/source"
+)
+
+// Fixture is a recorded resolution: what was asked for, what each source
+// answered, and the versions the resolver chose
+type Fixture struct {
+	Requirements []Requirement `json:"requirements"`
+	// ChefVersion is the Chef Infra Client version resolved for, if any
+	ChefVersion string `json:"chef_version,omitempty"`
+	// Sources are the sources queried; the default sources come first, in order
+	Sources []*Source `json:"sources"`
+	// Resolved maps each cookbook to the version chosen
+	Resolved map[string]string `json:"resolved"`
+}
+
+// Requirement is a recorded resolver requirement
+type Requirement struct {
+	Name       string                    `json:"name"`
+	Constraint string                    `json:"constraint,omitempty"`
+	Source     *berkshelf.SourceLocation `json:"source,omitempty"`
+}
+
+// Source is what one source answered
+type Source struct {
+	Name     string                    `json:"name"`
+	Priority int                       `json:"priority"`
+	Location *berkshelf.SourceLocation `json:"location"`
+	// Default is set for the Berksfile sources, as opposed to the source of
+	// a single requirement
+	Default   bool                 `json:"default,omitempty"`
+	Cookbooks map[string]*Cookbook `json:"cookbooks"`
+}
+
+// Cookbook is what a source answered about one cookbook
+type Cookbook struct {
+	Versions []string `json:"versions"`
+	// Releases holds the fetched versions, by version
+	Releases map[string]*Release `json:"releases,omitempty"`
+}
+
+// Release is the part of a cookbook version's metadata the resolver reads
+type Release struct {
+	Dependencies map[string]string `json:"dependencies,omitempty"`
+	ChefVersion  string            `json:"chef_version,omitempty"`
+}
+
+// Load reads a fixture
+func Load(path string) (*Fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var fixture Fixture
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return nil, fmt.Errorf("failed to parse fixture %s: %w", path, err)
+	}
+	return &fixture, nil
+}
+
+// Save writes the fixture to path
+func (f *Fixture) Save(path string) error {
+	var data bytes.Buffer
+	encoder := json.NewEncoder(&data)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(f); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data.Bytes(), 0644)
+}
+
+// Resolve resolves the recorded requirements against the recorded sources,
+// and returns the version chosen for each cookbook
+func (f *Fixture) Resolve(ctx context.Context) (map[string]string, error) {
+	requirements := make([]*resolver.Requirement, 0, len(f.Requirements))
+	for _, req := range f.Requirements {
+		var constraint *berkshelf.Constraint
+		if req.Constraint != "" {
+			var err error
+			if constraint, err = berkshelf.NewConstraint(req.Constraint); err != nil {
+				return nil, fmt.Errorf("requirement %s: %w", req.Name, err)
+			}
+		}
+		if req.Source != nil {
+			requirements = append(requirements, resolver.NewRequirementWithSource(req.Name, constraint, req.Source))
+		} else {
+			requirements = append(requirements, resolver.NewRequirement(req.Name, constraint))
+		}
+	}
+
+	var defaults []source.CookbookSource
+	for _, src := range f.Sources {
+		if src.Default {
+			defaults = append(defaults, &replaySource{src})
+		}
+	}
+
+	r := resolver.NewResolver(defaults)
+	r.SetSourceFactory(f)
+	if f.ChefVersion != "" {
+		version, err := berkshelf.NewVersion(f.ChefVersion)
+		if err != nil {
+			return nil, fmt.Errorf("chef_version: %w", err)
+		}
+		r.SetChefVersion(version)
+	}
+
+	resolution, err := r.Resolve(ctx, requirements)
+	if err != nil {
+		return nil, err
+	}
+	if resolution.HasErrors() {
+		return nil, fmt.Errorf("resolution failed with %d errors: %v", len(resolution.Errors), resolution.Errors[0])
+	}
+
+	resolved := make(map[string]string, resolution.CookbookCount())
+	for _, cookbook := range resolution.AllCookbooks() {
+		resolved[cookbook.Name] = cookbook.Version.String()
+	}
+	return resolved, nil
+}
+
+// CreateSource returns the recorded source for location, so requirements
+// naming their own source are replayed too
+func (f *Fixture) CreateSource(location *berkshelf.SourceLocation) (source.CookbookSource, error) {
+	key := locationKey(location)
+	for _, src := range f.Sources {
+		if !src.Default && locationKey(src.Location) == key {
+			return &replaySource{src}, nil
+		}
+	}
+	return nil, fmt.Errorf("no recorded source for %s", location)
+}
+
+// Diff returns the differences between the recorded versions and resolved,
+// one line per cookbook, sorted by cookbook name
+func (f *Fixture) Diff(resolved map[string]string) []string {
+	var diff []string
+	for name, want := range f.Resolved {
+		if got, ok := resolved[name]; !ok {
+			diff = append(diff, fmt.Sprintf("%s: recorded %s, no longer resolved", name, want))
+		} else if got != want {
+			diff = append(diff, fmt.Sprintf("%s: recorded %s, resolved %s", name, want, got))
+		}
+	}
+	for name, got := range resolved {
+		if _, ok := f.Resolved[name]; !ok {
+			diff = append(diff, fmt.Sprintf("%s: not recorded, resolved %s", name, got))
+		}
+	}
+	sort.Strings(diff)
+	return diff
+}
+
+// locationKey identifies a source location across recording and replay
+func locationKey(location *berkshelf.SourceLocation) string {
+	if location == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s|%s|%s|%s", location.Type, location.URL, location.Path, location.Ref)
+}
@@ -0,0 +1,148 @@
+package replay
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	"github.com/bdwyertech/go-berkshelf/pkg/resolver"
+	"github.com/bdwyertech/go-berkshelf/pkg/source"
+)
+
+// TestFixtures replays every recorded resolution in testdata and fails if
+// the resolver no longer chooses the recorded versions. Record new fixtures
+// with `berks install --record`.
+func TestFixtures(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no fixtures in testdata")
+	}
+
+	for _, path := range paths {
+		t.Run(strings.TrimSuffix(filepath.Base(path), ".json"), func(t *testing.T) {
+			fixture, err := Load(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resolved, err := fixture.Resolve(context.Background())
+			if err != nil {
+				t.Fatalf("Resolve() error = %v", err)
+			}
+			for _, line := range fixture.Diff(resolved) {
+				t.Error(line)
+			}
+		})
+	}
+}
+
+// fakeSource serves fixed versions and dependencies
+type fakeSource struct {
+	source.CookbookSource
+	location *berkshelf.SourceLocation
+	versions map[string][]string
+	deps     map[string]map[string]string
+}
+
+func (s *fakeSource) Name() string                                 { return "fake " + s.location.URL }
+func (s *fakeSource) Priority() int                                { return 100 }
+func (s *fakeSource) GetSourceLocation() *berkshelf.SourceLocation { return s.location }
+
+func (s *fakeSource) ListVersions(ctx context.Context, name string) ([]*berkshelf.Version, error) {
+	var versions []*berkshelf.Version
+	for _, v := range s.versions[name] {
+		versions = append(versions, berkshelf.MustVersion(v))
+	}
+	if versions == nil {
+		return nil, fmt.Errorf("cookbook %s not found", name)
+	}
+	return versions, nil
+}
+
+func (s *fakeSource) FetchCookbook(ctx context.Context, name string, version *berkshelf.Version) (*berkshelf.Cookbook, error) {
+	metadata := &berkshelf.Metadata{Name: name, Version: version, Dependencies: map[string]*berkshelf.Constraint{}}
+	for dep, c := range s.deps[name+"@"+version.String()] {
+		metadata.Dependencies[dep] = berkshelf.MustConstraint(c)
+	}
+	return &berkshelf.Cookbook{Name: name, Version: version, Metadata: metadata}, nil
+}
+
+// fakeFactory creates the source of a requirement's own location
+type fakeFactory map[string]*fakeSource
+
+func (f fakeFactory) CreateSource(location *berkshelf.SourceLocation) (source.CookbookSource, error) {
+	if src, ok := f[location.URL]; ok {
+		return src, nil
+	}
+	return nil, fmt.Errorf("unknown source %s", location.URL)
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	ctx := context.Background()
+	supermarket := &fakeSource{
+		location: &berkshelf.SourceLocation{Type: "supermarket", URL: "https://supermarket.example.com"},
+		versions: map[string][]string{"nginx": {"1.0.0", "2.0.0"}, "apt": {"1.0.0", "1.1.0"}},
+		deps:     map[string]map[string]string{"nginx@1.0.0": {"apt": "~> 1.0"}},
+	}
+	git := &fakeSource{
+		location: &berkshelf.SourceLocation{Type: "git", URL: "https://git.example.com/app.git"},
+		versions: map[string][]string{"app": {"0.1.0"}},
+		deps:     map[string]map[string]string{"app@0.1.0": {"nginx": "< 2.0"}},
+	}
+
+	requirements := []*resolver.Requirement{
+		resolver.NewRequirementWithSource("app", nil, git.location),
+	}
+
+	recorder := NewRecorder(fakeFactory{git.location.URL: git})
+	r := resolver.NewResolver(recorder.WrapAll([]source.CookbookSource{supermarket}))
+	r.SetSourceFactory(recorder)
+	resolution, err := r.Resolve(ctx, requirements)
+	if err != nil || resolution.HasErrors() {
+		t.Fatalf("Resolve() = %v, %v", err, resolution.Errors)
+	}
+
+	path := filepath.Join(t.TempDir(), "fixture.json")
+	if err := recorder.Fixture(requirements, nil, resolution).Save(path); err != nil {
+		t.Fatal(err)
+	}
+	fixture, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{"app": "0.1.0", "nginx": "1.0.0", "apt": "1.1.0"}
+	if !reflect.DeepEqual(fixture.Resolved, want) {
+		t.Errorf("recorded %v, want %v", fixture.Resolved, want)
+	}
+	if len(fixture.Sources) != 2 || !fixture.Sources[0].Default || fixture.Sources[1].Default {
+		t.Errorf("recorded sources = %+v, want the default source first", fixture.Sources)
+	}
+
+	resolved, err := fixture.Resolve(ctx)
+	if err != nil {
+		t.Fatalf("replay Resolve() error = %v", err)
+	}
+	if diff := fixture.Diff(resolved); len(diff) > 0 {
+		t.Errorf("replay differs: %v", diff)
+	}
+
+	// A changed resolution is reported per cookbook
+	resolved["apt"] = "1.0.0"
+	delete(resolved, "app")
+	resolved["yum"] = "3.0.0"
+	want2 := []string{
+		"app: recorded 0.1.0, no longer resolved",
+		"apt: recorded 1.1.0, resolved 1.0.0",
+		"yum: not recorded, resolved 3.0.0",
+	}
+	if diff := fixture.Diff(resolved); !reflect.DeepEqual(diff, want2) {
+		t.Errorf("Diff() = %v, want %v", diff, want2)
+	}
+}
@@ -0,0 +1,253 @@
+package replay
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	"github.com/bdwyertech/go-berkshelf/pkg/resolver"
+	"github.com/bdwyertech/go-berkshelf/pkg/source"
+)
+
+// Recorder captures what sources answer during a resolution
+type Recorder struct {
+	mu      sync.Mutex
+	sources []*Source
+	factory source.SourceFactory
+}
+
+// NewRecorder returns a recorder. Sources of requirements that name their
+// own source are created by factory.
+func NewRecorder(factory source.SourceFactory) *Recorder {
+	return &Recorder{factory: factory}
+}
+
+// WrapAll records the answers of the default sources
+func (r *Recorder) WrapAll(sources []source.CookbookSource) []source.CookbookSource {
+	wrapped := make([]source.CookbookSource, len(sources))
+	for i, src := range sources {
+		location := src.GetSourceLocation()
+		if location == nil {
+			location = &berkshelf.SourceLocation{Type: src.GetSourceType(), URL: src.GetSourceURL()}
+		}
+		wrapped[i] = r.wrap(src, location, true)
+	}
+	return wrapped
+}
+
+// CreateSource creates the source for location with the recorder's factory,
+// and records its answers. Pass the recorder to the resolver's
+// SetSourceFactory.
+func (r *Recorder) CreateSource(location *berkshelf.SourceLocation) (source.CookbookSource, error) {
+	src, err := r.factory.CreateSource(location)
+	if err != nil {
+		return nil, err
+	}
+	return r.wrap(src, location, false), nil
+}
+
+func (r *Recorder) wrap(src source.CookbookSource, location *berkshelf.SourceLocation, isDefault bool) source.CookbookSource {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// A location named by several requirements is recorded once
+	key := locationKey(location)
+	for _, recorded := range r.sources {
+		if recorded.Default == isDefault && locationKey(recorded.Location) == key {
+			return &recordingSource{CookbookSource: src, recorder: r, recorded: recorded}
+		}
+	}
+
+	recorded := &Source{
+		Name:      src.Name(),
+		Priority:  src.Priority(),
+		Location:  location,
+		Default:   isDefault,
+		Cookbooks: make(map[string]*Cookbook),
+	}
+	r.sources = append(r.sources, recorded)
+	return &recordingSource{CookbookSource: src, recorder: r, recorded: recorded}
+}
+
+// Fixture returns what was recorded with the requirements it was recorded
+// for and the resolution's chosen versions
+func (r *Recorder) Fixture(requirements []*resolver.Requirement, chefVersion *berkshelf.Version, resolution *resolver.Resolution) *Fixture {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	fixture := &Fixture{Resolved: make(map[string]string)}
+	for _, req := range requirements {
+		recorded := Requirement{Name: req.Name, Source: req.Source}
+		if req.Constraint != nil {
+			recorded.Constraint = req.Constraint.String()
+		}
+		fixture.Requirements = append(fixture.Requirements, recorded)
+	}
+	if chefVersion != nil {
+		fixture.ChefVersion = chefVersion.String()
+	}
+
+	// Default sources first, in the order the resolver was given them
+	for _, src := range r.sources {
+		if src.Default {
+			fixture.Sources = append(fixture.Sources, src)
+		}
+	}
+	for _, src := range r.sources {
+		if !src.Default {
+			fixture.Sources = append(fixture.Sources, src)
+		}
+	}
+
+	if resolution != nil {
+		for _, cookbook := range resolution.AllCookbooks() {
+			fixture.Resolved[cookbook.Name] = cookbook.Version.String()
+		}
+	}
+	return fixture
+}
+
+// recordingSource records the answers of the source it wraps
+type recordingSource struct {
+	source.CookbookSource
+	recorder *Recorder
+	recorded *Source
+}
+
+// cookbook returns the recorded cookbook, creating it. The recorder's lock must be held.
+func (s *recordingSource) cookbook(name string) *Cookbook {
+	cookbook := s.recorded.Cookbooks[name]
+	if cookbook == nil {
+		cookbook = &Cookbook{Versions: []string{}, Releases: make(map[string]*Release)}
+		s.recorded.Cookbooks[name] = cookbook
+	}
+	return cookbook
+}
+
+// ListVersions records the versions the source reports
+func (s *recordingSource) ListVersions(ctx context.Context, name string) ([]*berkshelf.Version, error) {
+	versions, err := s.CookbookSource.ListVersions(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	s.recorder.mu.Lock()
+	defer s.recorder.mu.Unlock()
+	recorded := s.cookbook(name)
+	recorded.Versions = recorded.Versions[:0]
+	for _, version := range versions {
+		recorded.Versions = append(recorded.Versions, version.String())
+	}
+	return versions, nil
+}
+
+// FetchCookbook records the dependencies and chef_version of the version fetched
+func (s *recordingSource) FetchCookbook(ctx context.Context, name string, version *berkshelf.Version) (*berkshelf.Cookbook, error) {
+	cookbook, err := s.CookbookSource.FetchCookbook(ctx, name, version)
+	if err != nil {
+		return nil, err
+	}
+
+	release := &Release{}
+	if cookbook.Metadata != nil {
+		if len(cookbook.Metadata.Dependencies) > 0 {
+			release.Dependencies = make(map[string]string, len(cookbook.Metadata.Dependencies))
+			for dep, constraint := range cookbook.Metadata.Dependencies {
+				release.Dependencies[dep] = constraint.String()
+			}
+		}
+		if cookbook.Metadata.ChefVersion != nil {
+			release.ChefVersion = cookbook.Metadata.ChefVersion.String()
+		}
+	}
+
+	s.recorder.mu.Lock()
+	defer s.recorder.mu.Unlock()
+	s.cookbook(name).Releases[version.String()] = release
+	return cookbook, nil
+}
+
+// replaySource answers from a recorded source
+type replaySource struct {
+	recorded *Source
+}
+
+func (s *replaySource) Name() string  { return s.recorded.Name }
+func (s *replaySource) Priority() int { return s.recorded.Priority }
+
+func (s *replaySource) GetSourceLocation() *berkshelf.SourceLocation { return s.recorded.Location }
+func (s *replaySource) GetSourceType() string                        { return s.recorded.Location.Type }
+func (s *replaySource) GetSourceURL() string                         { return s.recorded.Location.URL }
+
+// ListVersions returns the recorded versions
+func (s *replaySource) ListVersions(ctx context.Context, name string) ([]*berkshelf.Version, error) {
+	cookbook, ok := s.recorded.Cookbooks[name]
+	if !ok {
+		return nil, &source.ErrCookbookNotFound{Name: name}
+	}
+	versions := make([]*berkshelf.Version, 0, len(cookbook.Versions))
+	for _, v := range cookbook.Versions {
+		version, err := berkshelf.NewVersion(v)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, version)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].GreaterThan(versions[j]) })
+	return versions, nil
+}
+
+// FetchCookbook returns a cookbook with the recorded dependencies and chef_version
+func (s *replaySource) FetchCookbook(ctx context.Context, name string, version *berkshelf.Version) (*berkshelf.Cookbook, error) {
+	metadata, err := s.FetchMetadata(ctx, name, version)
+	if err != nil {
+		return nil, err
+	}
+	return &berkshelf.Cookbook{
+		Name:         name,
+		Version:      version,
+		Dependencies: metadata.Dependencies,
+		Metadata:     metadata,
+		Source:       *s.recorded.Location,
+	}, nil
+}
+
+// FetchMetadata returns the recorded dependencies and chef_version
+func (s *replaySource) FetchMetadata(ctx context.Context, name string, version *berkshelf.Version) (*berkshelf.Metadata, error) {
+	cookbook, ok := s.recorded.Cookbooks[name]
+	if !ok {
+		return nil, &source.ErrCookbookNotFound{Name: name}
+	}
+	release, ok := cookbook.Releases[version.String()]
+	if !ok {
+		return nil, &source.ErrVersionNotFound{Name: name, Version: version.String()}
+	}
+
+	metadata := &berkshelf.Metadata{Name: name, Version: version, Dependencies: make(map[string]*berkshelf.Constraint)}
+	for dep, c := range release.Dependencies {
+		constraint, err := berkshelf.NewConstraint(c)
+		if err != nil {
+			return nil, err
+		}
+		metadata.Dependencies[dep] = constraint
+	}
+	if release.ChefVersion != "" {
+		constraint, err := berkshelf.NewConstraint(release.ChefVersion)
+		if err != nil {
+			return nil, err
+		}
+		metadata.ChefVersion = constraint
+	}
+	return metadata, nil
+}
+
+// DownloadAndExtractCookbook is not recorded
+func (s *replaySource) DownloadAndExtractCookbook(ctx context.Context, cookbook *berkshelf.Cookbook, targetDir string) error {
+	return source.ErrNotImplemented
+}
+
+// Search is not recorded
+func (s *replaySource) Search(ctx context.Context, query string) ([]*berkshelf.Cookbook, error) {
+	return nil, source.ErrNotImplemented
+}
@@ -0,0 +1,209 @@
+// Package report renders what an update changed in the lock file as markdown,
+// for a pull request description or a CI bot comment.
+package report
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	"github.com/bdwyertech/go-berkshelf/pkg/lockfile"
+)
+
+// BerksfileRequirer names the Berksfile as the requirer of a constraint
+const BerksfileRequirer = "Berksfile"
+
+// Constraint is a constraint on a cookbook and whether its new version meets it
+type Constraint struct {
+	Constraint string `json:"constraint"`
+	// RequiredBy is the cookbook declaring the dependency, or BerksfileRequirer
+	RequiredBy string `json:"required_by"`
+	Satisfied  bool   `json:"satisfied"`
+}
+
+// Entry is a cookbook the update changed
+type Entry struct {
+	lockfile.Change
+	// Constraints are the constraints on the new version, for cookbooks
+	// still locked
+	Constraints []Constraint `json:"constraints,omitempty"`
+	// Changelog links to the changelog of the cookbook, when known
+	Changelog string `json:"changelog,omitempty"`
+}
+
+// Satisfied reports whether the new version meets every constraint on it
+func (e Entry) Satisfied() bool {
+	for _, c := range e.Constraints {
+		if !c.Satisfied {
+			return false
+		}
+	}
+	return true
+}
+
+// Update is the report of one update run
+type Update struct {
+	Entries []Entry `json:"entries"`
+}
+
+// NewUpdate compares the lock file before the update with the one after it.
+// declared holds the Berksfile constraints by cookbook name, which the
+// update may have lifted for the cookbooks it updated.
+func NewUpdate(before, after *lockfile.LockFile, declared map[string]string) *Update {
+	changes := lockfile.Diff(before, after)
+	update := &Update{Entries: make([]Entry, 0, len(changes))}
+	for _, change := range changes {
+		entry := Entry{Change: change}
+		if change.Type != lockfile.ChangeRemoved {
+			entry.Constraints = constraintsOn(change.Cookbook, change.To, after, declared)
+		}
+		update.Entries = append(update.Entries, entry)
+	}
+	return update
+}
+
+// constraintsOn checks version of name against the Berksfile constraint and
+// the dependencies the locked cookbooks declare on it
+func constraintsOn(name, version string, after *lockfile.LockFile, declared map[string]string) []Constraint {
+	v, err := berkshelf.NewVersion(version)
+	if err != nil {
+		return nil
+	}
+	check := func(constraint, requiredBy string) Constraint {
+		c := Constraint{Constraint: constraint, RequiredBy: requiredBy}
+		if parsed, err := berkshelf.NewConstraint(constraint); err == nil {
+			c.Satisfied = parsed.Check(v)
+		}
+		return c
+	}
+
+	var constraints []Constraint
+	if constraint := declared[name]; constraint != "" {
+		constraints = append(constraints, check(constraint, BerksfileRequirer))
+	}
+	cookbooks := after.ListCookbooks()
+	for _, dependent := range slices.Sorted(maps.Keys(cookbooks)) {
+		if constraint, ok := cookbooks[dependent].Dependencies[name]; ok {
+			constraints = append(constraints, check(constraint, dependent))
+		}
+	}
+	return constraints
+}
+
+// WriteMarkdown writes the report as a markdown section
+func (u *Update) WriteMarkdown(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("## Cookbook updates\n\n")
+	if len(u.Entries) == 0 {
+		b.WriteString("No cookbooks changed.\n")
+		_, err := io.WriteString(w, b.String())
+		return err
+	}
+
+	b.WriteString(u.summary() + "\n\n")
+	b.WriteString("| Cookbook | Change | From | To | Constraints | Changelog |\n")
+	b.WriteString("|---|---|---|---|---|---|\n")
+	for _, entry := range u.Entries {
+		changelog := ""
+		if entry.Changelog != "" {
+			changelog = fmt.Sprintf("[changelog](%s)", entry.Changelog)
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s | %s | %s | %s |\n",
+			escape(entry.Cookbook), changeLabel(entry.Type), code(entry.From), code(entry.To),
+			constraintCell(entry.Constraints), changelog)
+	}
+
+	var sources []Entry
+	for _, entry := range u.Entries {
+		if entry.FromSource != "" && entry.ToSource != "" {
+			sources = append(sources, entry)
+		}
+	}
+	if len(sources) > 0 {
+		b.WriteString("\n### Source changes\n\n")
+		for _, entry := range sources {
+			fmt.Fprintf(&b, "- **%s**: %s → %s\n", escape(entry.Cookbook), code(entry.FromSource), code(entry.ToSource))
+		}
+	}
+
+	var violations []string
+	for _, entry := range u.Entries {
+		for _, c := range entry.Constraints {
+			if !c.Satisfied {
+				violations = append(violations, fmt.Sprintf("- **%s** %s does not satisfy %s required by %s",
+					escape(entry.Cookbook), code(entry.To), code(c.Constraint), escape(c.RequiredBy)))
+			}
+		}
+	}
+	if len(violations) > 0 {
+		b.WriteString("\n### Unsatisfied constraints\n\n")
+		b.WriteString(strings.Join(violations, "\n") + "\n")
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// summary counts the entries by type, e.g. "3 cookbooks changed: 2 upgraded, 1 added."
+func (u *Update) summary() string {
+	counts := make(map[lockfile.ChangeType]int)
+	for _, entry := range u.Entries {
+		counts[entry.Type]++
+	}
+	var parts []string
+	for _, t := range []lockfile.ChangeType{lockfile.ChangeUpgraded, lockfile.ChangeDowngraded, lockfile.ChangeAdded, lockfile.ChangeRemoved, lockfile.ChangeSourceChanged} {
+		if counts[t] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[t], strings.ReplaceAll(string(t), "_", " ")))
+		}
+	}
+	noun := "cookbooks"
+	if len(u.Entries) == 1 {
+		noun = "cookbook"
+	}
+	return fmt.Sprintf("%d %s changed: %s.", len(u.Entries), noun, strings.Join(parts, ", "))
+}
+
+// changeLabel returns the table label of a change type
+func changeLabel(t lockfile.ChangeType) string {
+	switch t {
+	case lockfile.ChangeUpgraded:
+		return "⬆️ upgraded"
+	case lockfile.ChangeDowngraded:
+		return "⬇️ downgraded"
+	case lockfile.ChangeAdded:
+		return "➕ added"
+	case lockfile.ChangeRemoved:
+		return "➖ removed"
+	}
+	return strings.ReplaceAll(string(t), "_", " ")
+}
+
+// constraintCell lists the constraints on a cookbook, marking those its new
+// version does not satisfy
+func constraintCell(constraints []Constraint) string {
+	cells := make([]string, len(constraints))
+	for i, c := range constraints {
+		mark := "✅"
+		if !c.Satisfied {
+			mark = "❌"
+		}
+		cells[i] = fmt.Sprintf("%s %s (%s)", mark, code(c.Constraint), escape(c.RequiredBy))
+	}
+	return strings.Join(cells, "<br>")
+}
+
+// code formats s as inline code, or nothing when empty
+func code(s string) string {
+	if s == "" {
+		return ""
+	}
+	return "`" + strings.ReplaceAll(s, "`", "'") + "`"
+}
+
+// escape keeps s from breaking a table row or being read as markup
+func escape(s string) string {
+	return strings.NewReplacer("|", `\|`, "*", `\*`, "_", `\_`, "<", "&lt;").Replace(s)
+}
@@ -0,0 +1,91 @@
+package report
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/lockfile"
+)
+
+func lockWith(cookbooks map[string]*lockfile.CookbookLock) *lockfile.LockFile {
+	lf := lockfile.NewLockFile()
+	lf.Sources["https://supermarket.chef.io"] = &lockfile.SourceLock{Type: "supermarket", Cookbooks: cookbooks}
+	return lf
+}
+
+func TestNewUpdate(t *testing.T) {
+	before := lockWith(map[string]*lockfile.CookbookLock{
+		"app":   {Version: "1.0.0", Dependencies: map[string]string{"nginx": "~> 2.7"}},
+		"nginx": {Version: "2.7.5"},
+		"old":   {Version: "1.0.0"},
+	})
+	after := lockWith(map[string]*lockfile.CookbookLock{
+		"app":   {Version: "1.0.0", Dependencies: map[string]string{"nginx": "~> 2.7"}},
+		"nginx": {Version: "3.0.0"},
+		"yum":   {Version: "7.0.0"},
+	})
+
+	update := NewUpdate(before, after, map[string]string{"nginx": ">= 2.0"})
+	if len(update.Entries) != 3 {
+		t.Fatalf("entries = %+v, want 3", update.Entries)
+	}
+
+	nginx := update.Entries[0]
+	if nginx.Cookbook != "nginx" || nginx.Type != lockfile.ChangeUpgraded {
+		t.Fatalf("entry = %+v, want nginx upgraded", nginx)
+	}
+	want := []Constraint{
+		{Constraint: ">= 2.0", RequiredBy: BerksfileRequirer, Satisfied: true},
+		{Constraint: "~> 2.7", RequiredBy: "app", Satisfied: false},
+	}
+	if len(nginx.Constraints) != len(want) || nginx.Constraints[0] != want[0] || nginx.Constraints[1] != want[1] {
+		t.Errorf("constraints = %+v, want %+v", nginx.Constraints, want)
+	}
+	if nginx.Satisfied() {
+		t.Error("Satisfied() = true, want false")
+	}
+
+	if removed := update.Entries[1]; removed.Cookbook != "old" || removed.Constraints != nil {
+		t.Errorf("entry = %+v, want old removed without constraints", removed)
+	}
+}
+
+func TestUpdate_WriteMarkdown(t *testing.T) {
+	update := &Update{Entries: []Entry{
+		{
+			Change:      lockfile.Change{Cookbook: "nginx", Type: lockfile.ChangeUpgraded, From: "2.7.5", To: "3.0.0"},
+			Constraints: []Constraint{{Constraint: "~> 2.7", RequiredBy: "app"}},
+			Changelog:   "https://github.com/sous-chefs/nginx/blob/HEAD/CHANGELOG.md",
+		},
+		{
+			Change: lockfile.Change{
+				Cookbook: "my_app", Type: lockfile.ChangeSourceChanged, From: "1.0.0", To: "1.0.0",
+				FromSource: "https://git.example.com/app@abc", ToSource: "https://git.example.com/app@def",
+			},
+		},
+	}}
+
+	var b strings.Builder
+	if err := update.WriteMarkdown(&b); err != nil {
+		t.Fatal(err)
+	}
+	got := b.String()
+	for _, want := range []string{
+		"2 cookbooks changed: 1 upgraded, 1 source changed.",
+		"| nginx | ⬆️ upgraded | `2.7.5` | `3.0.0` | ❌ `~> 2.7` (app) | [changelog](https://github.com/sous-chefs/nginx/blob/HEAD/CHANGELOG.md) |",
+		"- **my\\_app**: `https://git.example.com/app@abc` → `https://git.example.com/app@def`",
+		"- **nginx** `3.0.0` does not satisfy `~> 2.7` required by app",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("markdown missing %q:\n%s", want, got)
+		}
+	}
+
+	b.Reset()
+	if err := (&Update{}).WriteMarkdown(&b); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "No cookbooks changed.") {
+		t.Errorf("markdown = %q", b.String())
+	}
+}
@@ -0,0 +1,59 @@
+package resolver
+
+import (
+	"container/heap"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	"github.com/bdwyertech/go-berkshelf/pkg/source"
+)
+
+// candidate is a version of a cookbook and the source offering it
+type candidate struct {
+	version *berkshelf.Version
+	source  source.CookbookSource
+}
+
+// candidateHeap is a min-heap of candidates by version, so the oldest of the
+// newest candidates kept so far is the one dropped
+type candidateHeap []candidate
+
+func (h candidateHeap) Len() int           { return len(h) }
+func (h candidateHeap) Less(i, j int) bool { return h[i].version.LessThan(h[j].version) }
+func (h candidateHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *candidateHeap) Push(x any)        { *h = append(*h, x.(candidate)) }
+func (h *candidateHeap) Pop() any {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+// newestCandidates returns the newest versions that accept admits, newest
+// first, from the sources in order. A version offered by several sources
+// comes from the first. At most limit candidates are kept, or all when limit
+// is 0; truncated counts the admitted versions left out.
+func newestCandidates(order []source.CookbookSource, sourceVersions map[source.CookbookSource][]*berkshelf.Version, limit int, accept func(*berkshelf.Version) bool) (candidates []candidate, truncated int) {
+	var h candidateHeap
+	seen := make(map[string]bool)
+	for _, src := range order {
+		for _, v := range sourceVersions[src] {
+			if seen[v.String()] || !accept(v) {
+				continue
+			}
+			seen[v.String()] = true
+			if limit > 0 && h.Len() == limit {
+				if !v.GreaterThan(h[0].version) {
+					continue
+				}
+				heap.Pop(&h)
+			}
+			heap.Push(&h, candidate{version: v, source: src})
+		}
+	}
+
+	candidates = make([]candidate, h.Len())
+	for i := len(candidates) - 1; i >= 0; i-- {
+		candidates[i] = heap.Pop(&h).(candidate)
+	}
+	return candidates, len(seen) - len(candidates)
+}
@@ -188,7 +188,7 @@ func (cs *ConstraintSolver) buildResolution(ctx context.Context, solution map[st
 	for name, version := range solution {
 		cookbook, err := cs.fetchCookbook(ctx, name, version)
 		if err != nil {
-			resolution.AddError(err)
+			resolution.AddCookbookError(name, err)
 			continue
 		}
 
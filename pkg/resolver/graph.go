@@ -141,10 +141,12 @@ func (g *DependencyGraph) GetDependents(node *CookbookNode) []*CookbookNode {
 	return dependents
 }
 
-// TopologicalSort returns cookbooks in dependency order
+// TopologicalSort returns cookbooks in dependency order: each cookbook comes
+// before the cookbooks it depends on. Cookbooks the dependencies leave
+// unordered keep the order they were added in.
 func (g *DependencyGraph) TopologicalSort() ([]*CookbookNode, error) {
-	// Use gonum's topological sort
-	sorted, err := topo.Sort(g.graph)
+	// Use gonum's topological sort, breaking ties by node ID
+	sorted, err := topo.SortStabilized(g.graph, nil)
 	if err != nil {
 		return nil, fmt.Errorf("dependency cycle detected: %w", err)
 	}
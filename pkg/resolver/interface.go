@@ -2,8 +2,12 @@ package resolver
 
 import (
 	"context"
+	"fmt"
+	"maps"
+	"slices"
 
 	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	berrors "github.com/bdwyertech/go-berkshelf/pkg/errors"
 	"github.com/bdwyertech/go-berkshelf/pkg/source"
 )
 
@@ -17,6 +21,16 @@ type Requirement struct {
 	Name       string
 	Constraint *berkshelf.Constraint
 	Source     *berkshelf.SourceLocation
+	// Origin labels a requirement injected through DefaultResolver.InjectRequirements;
+	// it is empty for Berksfile requirements and cookbook dependencies
+	Origin string
+	// Optional injected requirements only constrain a cookbook that is
+	// otherwise part of the resolution instead of adding it
+	Optional bool
+	// MetadataName is the name the cookbook's metadata declares when it
+	// differs from Name, e.g. for a fork published under another name.
+	// Dependencies on MetadataName are resolved to this cookbook.
+	MetadataName string
 }
 
 // Resolution represents a resolved dependency graph
@@ -24,16 +38,32 @@ type Resolution struct {
 	Graph     *DependencyGraph
 	Cookbooks map[string]*ResolvedCookbook
 	Errors    []error
+	// Warnings are non-fatal issues, such as overrides that violate the
+	// constraints they replace
+	Warnings []string
+	// Ignored lists the ignored cookbooks the resolution skipped, sorted by
+	// name (see DefaultResolver.Ignore)
+	Ignored []string
+	// Truncated counts, per cookbook, the versions satisfying its
+	// constraints that were not considered because of the candidate cap
+	// (see DefaultResolver.SetMaxCandidates); Resolve adds them to the
+	// metrics.CandidatesTruncated counter
+	Truncated map[string]int
 }
 
 // ResolvedCookbook represents a cookbook that has been resolved
 type ResolvedCookbook struct {
-	Name         string
-	Version      *berkshelf.Version
-	Source       *berkshelf.SourceLocation
-	SourceRef    source.CookbookSource // Reference to the actual source object
+	Name      string
+	Version   *berkshelf.Version
+	Source    *berkshelf.SourceLocation
+	SourceRef source.CookbookSource // Reference to the actual source object
+	// Dependencies maps each dependency to the version it resolved to
 	Dependencies map[string]*berkshelf.Version
 	Cookbook     *berkshelf.Cookbook
+	// Injected lists the injected requirements that constrained this cookbook
+	Injected []*Requirement
+	// Overridden is set when the version was pinned through DefaultResolver.Override
+	Overridden bool
 }
 
 // NewRequirement creates a new requirement
@@ -55,10 +85,14 @@ func NewRequirementWithSource(name string, constraint *berkshelf.Constraint, sou
 
 // String returns a string representation of the requirement
 func (r *Requirement) String() string {
+	s := r.Name
 	if r.Constraint != nil {
-		return r.Name + " " + r.Constraint.String()
+		s += " " + r.Constraint.String()
 	}
-	return r.Name
+	if r.Origin != "" {
+		s += " (injected by " + r.Origin + ")"
+	}
+	return s
 }
 
 // NewResolution creates a new resolution
@@ -70,6 +104,14 @@ func NewResolution() *Resolution {
 	}
 }
 
+// truncate records that versions of a cookbook were left out of its candidates
+func (r *Resolution) truncate(name string, versions int) {
+	if r.Truncated == nil {
+		r.Truncated = make(map[string]int)
+	}
+	r.Truncated[name] = versions
+}
+
 // AddCookbook adds a resolved cookbook to the resolution
 func (r *Resolution) AddCookbook(cookbook *ResolvedCookbook) {
 	r.Cookbooks[cookbook.Name] = cookbook
@@ -92,6 +134,30 @@ func (r *Resolution) AddError(err error) {
 	r.Errors = append(r.Errors, err)
 }
 
+// AddCookbookError adds an error about the named cookbook to the resolution
+func (r *Resolution) AddCookbookError(name string, err error) {
+	r.AddError(&berrors.CookbookError{Cookbook: name, Err: err})
+}
+
+// Failure returns a PartialFailure listing the errors of the resolution and
+// the cookbooks it resolved, or nil if it has no errors
+func (r *Resolution) Failure() error {
+	if !r.HasErrors() {
+		return nil
+	}
+	return &berrors.PartialFailure{
+		Message:   fmt.Sprintf("dependency resolution failed with %d error(s)", len(r.Errors)),
+		Type:      berrors.ErrorTypeResolution,
+		Errors:    r.Errors,
+		Succeeded: slices.Sorted(maps.Keys(r.Cookbooks)),
+	}
+}
+
+// AddWarning adds a warning to the resolution
+func (r *Resolution) AddWarning(warning string) {
+	r.Warnings = append(r.Warnings, warning)
+}
+
 // HasErrors returns true if the resolution has any errors
 func (r *Resolution) HasErrors() bool {
 	return len(r.Errors) > 0
@@ -102,11 +168,11 @@ func (r *Resolution) CookbookCount() int {
 	return len(r.Cookbooks)
 }
 
-// AllCookbooks returns all resolved cookbooks
+// AllCookbooks returns all resolved cookbooks, sorted by name
 func (r *Resolution) AllCookbooks() []*ResolvedCookbook {
 	cookbooks := make([]*ResolvedCookbook, 0, len(r.Cookbooks))
-	for _, cookbook := range r.Cookbooks {
-		cookbooks = append(cookbooks, cookbook)
+	for _, name := range slices.Sorted(maps.Keys(r.Cookbooks)) {
+		cookbooks = append(cookbooks, r.Cookbooks[name])
 	}
 	return cookbooks
 }
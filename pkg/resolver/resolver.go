@@ -2,24 +2,60 @@ package resolver
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"maps"
+	"regexp"
 	"runtime"
-	"sort"
+	"slices"
+	"strings"
 	"sync"
+	"time"
 
-	log "github.com/sirupsen/logrus"
 	"github.com/sourcegraph/conc/pool"
 
 	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	berrors "github.com/bdwyertech/go-berkshelf/pkg/errors"
+	"github.com/bdwyertech/go-berkshelf/pkg/events"
+	"github.com/bdwyertech/go-berkshelf/pkg/logging"
+	"github.com/bdwyertech/go-berkshelf/pkg/metrics"
 	"github.com/bdwyertech/go-berkshelf/pkg/source"
 )
 
+var log = logging.For("resolver")
+
 // DefaultResolver implements the Resolver interface
 type DefaultResolver struct {
 	sources       []source.CookbookSource
 	cache         *ResolutionCache
 	maxCandidates int
 	workerCount   int
+	keepGoing     bool
+	events        events.Handler
+	injected      []*Requirement
+	chefVersion   *berkshelf.Version
+	incompatible  map[string]bool // cookbook@version excluded by chef_version
+	factory       source.SourceFactory
+	overrides     map[string]*Requirement
+	aliases       map[string]string // metadata name -> requirement name
+	replaced      []replacedConstraint
+	ignored       map[string]bool
+	routes        []SourceRoute
+}
+
+// SourceRoute resolves the cookbooks whose name matches Pattern from Source
+// alone, e.g. a company's namespaced cookbooks from its private Supermarket
+type SourceRoute struct {
+	Pattern *regexp.Regexp
+	Source  source.CookbookSource
+}
+
+// replacedConstraint is a constraint on an overridden cookbook, which the
+// override replaced
+type replacedConstraint struct {
+	name       string
+	constraint *berkshelf.Constraint
+	from       string // What declared the constraint
 }
 
 // ResolutionCache caches cookbook metadata and available versions
@@ -29,12 +65,16 @@ type ResolutionCache struct {
 	mu       sync.RWMutex
 }
 
+// DefaultMaxCandidates is how many of the newest versions satisfying its
+// constraints are considered per cookbook
+const DefaultMaxCandidates = 100
+
 // NewResolver creates a new resolver with the given sources
 func NewResolver(sources []source.CookbookSource) *DefaultResolver {
 	return &DefaultResolver{
 		sources:       sources,
 		cache:         NewResolutionCache(),
-		maxCandidates: 100,                  // Maximum versions to consider per cookbook
+		maxCandidates: DefaultMaxCandidates,
 		workerCount:   runtime.NumCPU() * 2, // Good for I/O bound operations
 	}
 }
@@ -47,14 +87,116 @@ func NewResolutionCache() *ResolutionCache {
 	}
 }
 
+// InjectRequirements adds requirements that are resolved alongside those
+// passed to Resolve, e.g. a platform team enforcing a minimum version of a
+// base cookbook in every project. An injected constraint must hold in
+// addition to any constraint from the Berksfile or cookbook dependencies.
+// origin labels the requirements in logs, provenance and conflict messages.
+func (r *DefaultResolver) InjectRequirements(origin string, requirements ...*Requirement) {
+	for _, req := range requirements {
+		injected := *req
+		injected.Origin = origin
+		r.injected = append(r.injected, &injected)
+	}
+}
+
+// Override pins cookbooks, e.g. to a hotfix release. An override replaces
+// every other constraint on its cookbook, from the Berksfile, cookbook
+// dependencies and injected requirements alike, and when it names a source
+// only that source is queried. Overrides do not add cookbooks that are not
+// otherwise part of the resolution.
+func (r *DefaultResolver) Override(requirements ...*Requirement) {
+	if r.overrides == nil {
+		r.overrides = make(map[string]*Requirement)
+	}
+	for _, req := range requirements {
+		r.overrides[req.Name] = req
+	}
+}
+
+// Ignore skips cookbooks that are provided out of band, e.g. through a Chef
+// Server run list. Ignored cookbooks are not resolved and their
+// dependencies are not traversed; the resolution lists those it skipped.
+func (r *DefaultResolver) Ignore(names ...string) {
+	if r.ignored == nil {
+		r.ignored = make(map[string]bool)
+	}
+	for _, name := range names {
+		r.ignored[name] = true
+	}
+}
+
+// Route adds source routes. A cookbook is resolved from the source of the
+// first route matching its name, instead of the default sources, unless a
+// requirement names its own source.
+func (r *DefaultResolver) Route(routes ...SourceRoute) {
+	r.routes = append(r.routes, routes...)
+}
+
+// sourcesFor returns the sources to resolve a cookbook without a source of
+// its own from: the source its name is routed to, or the default sources
+func (r *DefaultResolver) sourcesFor(name string) []source.CookbookSource {
+	for _, route := range r.routes {
+		if route.Pattern.MatchString(name) {
+			return []source.CookbookSource{route.Source}
+		}
+	}
+	return r.sources
+}
+
 // Resolve implements concurrent I/O operations for dependency resolution
 func (r *DefaultResolver) Resolve(ctx context.Context, requirements []*Requirement) (*Resolution, error) {
+	start := time.Now()
+	resolution, err := r.resolve(ctx, requirements)
+	outcome := "success"
+	if err != nil || resolution.HasErrors() {
+		outcome = "failure"
+	}
+	metrics.Observe(metrics.ResolutionDuration, time.Since(start).Seconds(), metrics.L("result", outcome))
+	if resolution != nil {
+		for _, truncated := range resolution.Truncated {
+			metrics.Add(metrics.CandidatesTruncated, float64(truncated))
+		}
+	}
+	return resolution, err
+}
+
+// resolve resolves requirements, see Resolve
+func (r *DefaultResolver) resolve(ctx context.Context, requirements []*Requirement) (*Resolution, error) {
 	log.Debugf("Starting concurrent dependency resolution with %d workers...", r.workerCount)
 
+	// Non-optional injected requirements are resolved like top-level requirements
+	for _, req := range r.injected {
+		log.WithField(logging.CookbookField, req.Name).Debugf("Adding requirement %s", req)
+		if !req.Optional {
+			requirements = append(requirements, req)
+		}
+	}
+
 	resolution := NewResolution()
+	requirements = slices.DeleteFunc(slices.Clone(requirements), func(req *Requirement) bool {
+		return r.ignore(resolution, req.Name)
+	})
+
+	r.aliases = aliases(requirements)
+	r.replaced = nil
+	for _, req := range slices.Concat(requirements, r.injected) {
+		from := req.Origin
+		if from == "" {
+			from = "the Berksfile"
+		}
+		r.replaceConstraint(req.Name, req.Constraint, from)
+	}
+
+	// Overridden requirements are replaced by their override. Versions of
+	// overridden dependencies are fetched up front from the override's source.
+	requirements, fetch := r.applyOverrides(requirements)
+
+	r.incompatible = make(map[string]bool)
+	r.events.Emit(events.Event{Type: events.ResolutionStarted, Total: len(requirements)})
 
 	// Phase 1: Parallel version fetching for all requirements
-	versionMap, err := r.fetchAllVersionsConcurrently(ctx, requirements)
+	versionMap, err := r.fetchAllVersionsConcurrently(ctx, fetch)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch versions: %w", err)
 	}
@@ -65,11 +207,16 @@ func (r *DefaultResolver) Resolve(ctx context.Context, requirements []*Requireme
 		return nil, fmt.Errorf("failed to resolve dependencies: %w", err)
 	}
 
-	// Phase 3: Parallel cookbook downloading
-	err = r.downloadCookbooksConcurrently(ctx, resolvedCookbooks, resolution)
-	if err != nil {
-		return nil, fmt.Errorf("failed to download cookbooks: %w", err)
+	// Phase 3: Parallel cookbook downloading, unless failing fast
+	if !r.failingFast(resolution) {
+		err = r.downloadCookbooksConcurrently(ctx, resolvedCookbooks, resolution)
+		if err != nil {
+			return nil, fmt.Errorf("failed to download cookbooks: %w", err)
+		}
 	}
+	r.checkOverrides(resolution)
+
+	r.events.Emit(events.Event{Type: events.ResolutionCompleted, Count: resolution.CookbookCount()})
 
 	return resolution, nil
 }
@@ -86,10 +233,9 @@ func (r *DefaultResolver) fetchAllVersionsConcurrently(ctx context.Context, requ
 	for _, req := range requirements {
 		if req.Source != nil {
 			// Use specific source
-			factory := source.NewFactory()
-			specificSource, err := factory.CreateFromLocation(req.Source)
+			specificSource, err := r.sourceFactory().CreateSource(req.Source)
 			if err != nil {
-				log.Warnf("Failed to create specific source for %s: %v", req.Name, err)
+				log.WithField(logging.CookbookField, req.Name).Warnf("Failed to create specific source for %s: %v", req.Name, err)
 				continue
 			}
 
@@ -100,7 +246,7 @@ func (r *DefaultResolver) fetchAllVersionsConcurrently(ctx context.Context, requ
 			p.Go(func(ctx context.Context) error {
 				versions, err := r.getVersions(ctx, src, reqName)
 				if err != nil {
-					log.Debugf("Failed to fetch versions for %s from %s: %v", reqName, src.Name(), err)
+					log.WithField(logging.CookbookField, reqName).Debugf("Failed to fetch versions for %s from %s: %v", reqName, src.Name(), err)
 					return nil // Don't fail the entire operation for individual source failures
 				}
 
@@ -114,8 +260,8 @@ func (r *DefaultResolver) fetchAllVersionsConcurrently(ctx context.Context, requ
 				return nil
 			})
 		} else {
-			// Use all global sources
-			for _, src := range r.sources {
+			// Use all global sources, or the one the cookbook is routed to
+			for _, src := range r.sourcesFor(req.Name) {
 				// Capture variables for closure
 				reqName := req.Name
 				currentSrc := src
@@ -123,7 +269,7 @@ func (r *DefaultResolver) fetchAllVersionsConcurrently(ctx context.Context, requ
 				p.Go(func(ctx context.Context) error {
 					versions, err := r.getVersions(ctx, currentSrc, reqName)
 					if err != nil {
-						log.Debugf("Failed to fetch versions for %s from %s: %v", reqName, currentSrc.Name(), err)
+						log.WithField(logging.CookbookField, reqName).Debugf("Failed to fetch versions for %s from %s: %v", reqName, currentSrc.Name(), err)
 						return nil // Don't fail the entire operation for individual source failures
 					}
 
@@ -158,9 +304,16 @@ func (r *DefaultResolver) resolveSequentially(ctx context.Context, requirements
 	dependencyChain := make([]string, 0) // Track current dependency chain for cycle detection
 
 	for len(queue) > 0 {
-		req := queue[0]
+		if r.failingFast(resolution) {
+			break
+		}
+		req := r.aliased(queue[0])
 		queue = queue[1:]
 
+		if r.ignore(resolution, req.Name) {
+			continue
+		}
+
 		if processed[req.Name] {
 			continue
 		}
@@ -170,28 +323,34 @@ func (r *DefaultResolver) resolveSequentially(ctx context.Context, requirements
 			cycleError := fmt.Errorf("circular dependency detected involving cookbook '%s' in chain: %v -> %s",
 				req.Name, dependencyChain, req.Name)
 			resolution.AddError(cycleError)
-			log.Warnf("Circular dependency detected: %s in chain %v", req.Name, dependencyChain)
+			log.WithField(logging.CookbookField, req.Name).Warnf("Circular dependency detected: %s in chain %v", req.Name, dependencyChain)
 			continue
 		}
 
+		if override, ok := r.overrides[req.Name]; ok {
+			req = override
+		}
+
 		resolving[req.Name] = true
 		dependencyChain = append(dependencyChain, req.Name)
 
 		// Find best version using pre-fetched data
-		version, cookbookSource, err := r.findBestVersionFromCache(req, versionMap)
+		constraints := r.constraintsFor(req)
+		version, cookbookSource, err := r.findBestVersionFromCache(req.Name, constraints, versionMap, resolution)
 		if err != nil {
 			// Try to fetch versions for this cookbook if not in cache
 			// Use first available source as fallback
-			if len(r.sources) == 0 {
-				resolution.AddError(fmt.Errorf("failed to resolve %s: no sources available", req.Name))
+			sources := r.sourcesFor(req.Name)
+			if len(sources) == 0 {
+				resolution.AddCookbookError(req.Name, fmt.Errorf("failed to resolve %s: no sources available", req.Name))
 				resolving[req.Name] = false
 				dependencyChain = dependencyChain[:len(dependencyChain)-1]
 				continue
 			}
 
-			newVersions, fetchErr := r.getVersions(ctx, r.sources[0], req.Name)
+			newVersions, fetchErr := r.getVersions(ctx, sources[0], req.Name)
 			if fetchErr != nil {
-				resolution.AddError(fmt.Errorf("failed to resolve %s: %w", req.Name, err))
+				resolution.AddCookbookError(req.Name, fmt.Errorf("failed to resolve %s: %w", req.Name, err))
 				resolving[req.Name] = false
 				dependencyChain = dependencyChain[:len(dependencyChain)-1]
 				continue
@@ -201,29 +360,47 @@ func (r *DefaultResolver) resolveSequentially(ctx context.Context, requirements
 			if versionMap[req.Name] == nil {
 				versionMap[req.Name] = make(map[source.CookbookSource][]*berkshelf.Version)
 			}
-			versionMap[req.Name][r.sources[0]] = newVersions
+			versionMap[req.Name][sources[0]] = newVersions
 
 			// Try again
-			version, cookbookSource, err = r.findBestVersionFromCache(req, versionMap)
+			version, cookbookSource, err = r.findBestVersionFromCache(req.Name, constraints, versionMap, resolution)
 			if err != nil {
-				resolution.AddError(fmt.Errorf("failed to resolve %s: %w", req.Name, err))
+				resolution.AddCookbookError(req.Name, fmt.Errorf("failed to resolve %s: %w", req.Name, err))
 				resolving[req.Name] = false
 				dependencyChain = dependencyChain[:len(dependencyChain)-1]
 				continue
 			}
 		}
 
-		log.Infof("Using %s (%s) from %s", req.Name, version.String(), cookbookSource.Name())
-
 		// Fetch cookbook metadata to get dependencies
 		cookbook, err := r.fetchCookbook(ctx, req.Name, version, cookbookSource)
 		if err != nil {
-			resolution.AddError(fmt.Errorf("failed to fetch cookbook %s@%s: %w", req.Name, version.String(), err))
+			resolution.AddCookbookError(req.Name, fmt.Errorf("failed to fetch cookbook %s@%s: %w", req.Name, version.String(), err))
 			resolving[req.Name] = false
 			dependencyChain = dependencyChain[:len(dependencyChain)-1]
 			continue
 		}
 
+		// Versions that do not support the target Chef are excluded and the
+		// requirement is retried with the next best version
+		if !r.supportsChef(cookbook) {
+			log.WithField(logging.CookbookField, req.Name).Debugf("Skipping %s (%s): chef_version %s excludes Chef %s",
+				req.Name, version, cookbook.Metadata.ChefVersion, r.chefVersion)
+			r.incompatible[req.Name+"@"+version.String()] = true
+			queue = append([]*Requirement{req}, queue...)
+			resolving[req.Name] = false
+			dependencyChain = dependencyChain[:len(dependencyChain)-1]
+			continue
+		}
+
+		log.WithField(logging.CookbookField, req.Name).Infof("Using %s (%s) from %s", req.Name, version.String(), cookbookSource.Name())
+		r.events.Emit(events.Event{
+			Type:     events.CookbookResolved,
+			Cookbook: req.Name,
+			Version:  version.String(),
+			Source:   cookbookSource.Name(),
+		})
+
 		// Create resolved cookbook
 		resolved := &ResolvedCookbook{
 			Name:         req.Name,
@@ -232,6 +409,13 @@ func (r *DefaultResolver) resolveSequentially(ctx context.Context, requirements
 			SourceRef:    cookbookSource,
 			Dependencies: make(map[string]*berkshelf.Version),
 			Cookbook:     cookbook,
+			Overridden:   r.overrides[req.Name] != nil,
+		}
+		for _, c := range constraints {
+			if c.Origin != "" {
+				resolved.Injected = append(resolved.Injected, c)
+				log.WithField(logging.CookbookField, req.Name).Infof("Applied requirement %s", c)
+			}
 		}
 
 		resolvedCookbooks = append(resolvedCookbooks, resolved)
@@ -240,9 +424,16 @@ func (r *DefaultResolver) resolveSequentially(ctx context.Context, requirements
 		node := resolution.Graph.AddCookbook(cookbook)
 		node.Resolved = true
 
-		// Add dependencies to queue and build dependency graph
+		// Add dependencies to queue and build dependency graph, in name
+		// order so that the resolution does not depend on map iteration
 		if cookbook.Metadata != nil && cookbook.Metadata.Dependencies != nil {
-			for depName, constraint := range cookbook.Metadata.Dependencies {
+			for _, depName := range slices.Sorted(maps.Keys(cookbook.Metadata.Dependencies)) {
+				constraint := cookbook.Metadata.Dependencies[depName]
+				depName = r.alias(depName)
+				if r.ignore(resolution, depName) {
+					continue
+				}
+				r.replaceConstraint(depName, constraint, req.Name)
 				// Add dependency to queue if not processed
 				if !processed[depName] {
 					depReq := &Requirement{
@@ -250,7 +441,6 @@ func (r *DefaultResolver) resolveSequentially(ctx context.Context, requirements
 						Constraint: constraint,
 					}
 					queue = append(queue, depReq)
-					resolved.Dependencies[depName] = nil // Will be filled later
 				}
 
 				// Create or get dependency node for graph building
@@ -273,7 +463,7 @@ func (r *DefaultResolver) resolveSequentially(ctx context.Context, requirements
 				if resolution.Graph.HasCycles() {
 					cycleError := fmt.Errorf("circular dependency detected: %s depends on %s, creating a cycle", req.Name, depName)
 					resolution.AddError(cycleError)
-					log.Warnf("Circular dependency detected: %s -> %s creates cycle", req.Name, depName)
+					log.WithField(logging.CookbookField, req.Name).Warnf("Circular dependency detected: %s -> %s creates cycle", req.Name, depName)
 				}
 			}
 		}
@@ -293,39 +483,223 @@ func (r *DefaultResolver) resolveSequentially(ctx context.Context, requirements
 		}
 	}
 
+	recordDependencyVersions(resolvedCookbooks, r.aliases)
 	return resolvedCookbooks, nil
 }
 
-// findBestVersionFromCache finds the best version using cached version data
-func (r *DefaultResolver) findBestVersionFromCache(req *Requirement, versionMap map[string]map[source.CookbookSource][]*berkshelf.Version) (*berkshelf.Version, source.CookbookSource, error) {
-	sourceVersions, exists := versionMap[req.Name]
-	if !exists {
-		return nil, nil, fmt.Errorf("no versions found for cookbook %s", req.Name)
+// recordDependencyVersions fills in the version each cookbook's dependencies
+// resolved to, which is only known once every cookbook is resolved
+func recordDependencyVersions(cookbooks []*ResolvedCookbook, aliases map[string]string) {
+	versions := make(map[string]*berkshelf.Version, len(cookbooks))
+	for _, cookbook := range cookbooks {
+		versions[cookbook.Name] = cookbook.Version
+	}
+	for _, cookbook := range cookbooks {
+		if cookbook.Cookbook == nil || cookbook.Cookbook.Metadata == nil {
+			continue
+		}
+		for depName := range cookbook.Cookbook.Metadata.Dependencies {
+			if alias, ok := aliases[depName]; ok {
+				depName = alias
+			}
+			if version, ok := versions[depName]; ok {
+				cookbook.Dependencies[depName] = version
+			}
+		}
 	}
+}
 
-	var bestVersion *berkshelf.Version
-	var bestSource source.CookbookSource
+// aliases maps the metadata name of each requirement that declares a
+// different one to the requirement's name
+func aliases(requirements []*Requirement) map[string]string {
+	aliases := make(map[string]string)
+	for _, req := range requirements {
+		if req.MetadataName != "" && req.MetadataName != req.Name {
+			aliases[req.MetadataName] = req.Name
+		}
+	}
+	return aliases
+}
 
-	for src, versions := range sourceVersions {
-		for _, v := range versions {
-			// Skip if doesn't satisfy constraint
-			if req.Constraint != nil && !req.Constraint.Check(v) {
-				continue
-			}
+// alias returns the name of the cookbook that satisfies dependencies on name
+func (r *DefaultResolver) alias(name string) string {
+	if alias, ok := r.aliases[name]; ok {
+		log.WithField(logging.CookbookField, alias).Debugf("Resolving %s as %s", name, alias)
+		return alias
+	}
+	return name
+}
+
+// aliased returns req for the cookbook that satisfies it, which is req
+// itself unless another cookbook declares its name as metadata name
+func (r *DefaultResolver) aliased(req *Requirement) *Requirement {
+	name := r.alias(req.Name)
+	if name == req.Name {
+		return req
+	}
+	aliased := *req
+	aliased.Name = name
+	aliased.Source = nil
+	return &aliased
+}
 
-			// Use the highest version that satisfies
-			if bestVersion == nil || v.GreaterThan(bestVersion) {
-				bestVersion = v
-				bestSource = src
+// applyOverrides returns requirements with overridden ones replaced, and the
+// requirements to fetch versions for, which add the remaining overrides
+func (r *DefaultResolver) applyOverrides(requirements []*Requirement) ([]*Requirement, []*Requirement) {
+	if len(r.overrides) == 0 {
+		return requirements, requirements
+	}
+
+	resolve := make([]*Requirement, 0, len(requirements))
+	overridden := make(map[string]bool)
+	for _, req := range requirements {
+		if override, ok := r.overrides[req.Name]; ok {
+			req = override
+			overridden[req.Name] = true
+		}
+		resolve = append(resolve, req)
+	}
+
+	fetch := slices.Clone(resolve)
+	for name, override := range r.overrides {
+		if !overridden[name] {
+			fetch = append(fetch, override)
+		}
+	}
+	return resolve, fetch
+}
+
+// ignore reports whether a cookbook is ignored, adding it to the
+// resolution's ignored cookbooks
+func (r *DefaultResolver) ignore(resolution *Resolution, name string) bool {
+	if !r.ignored[name] {
+		return false
+	}
+	if !slices.Contains(resolution.Ignored, name) {
+		log.WithField(logging.CookbookField, name).Infof("Skipping ignored cookbook %s", name)
+		resolution.Ignored = append(resolution.Ignored, name)
+		slices.Sort(resolution.Ignored)
+	}
+	return true
+}
+
+// replaceConstraint records a constraint on name when an override replaces it
+func (r *DefaultResolver) replaceConstraint(name string, constraint *berkshelf.Constraint, from string) {
+	if _, ok := r.overrides[name]; !ok || constraint == nil {
+		return
+	}
+	r.replaced = append(r.replaced, replacedConstraint{name: name, constraint: constraint, from: from})
+}
+
+// checkOverrides warns about every replaced constraint the version an
+// override pinned does not satisfy
+func (r *DefaultResolver) checkOverrides(resolution *Resolution) {
+	for _, replaced := range r.replaced {
+		cookbook, ok := resolution.GetCookbook(replaced.name)
+		if !ok || !cookbook.Overridden || replaced.constraint.Check(cookbook.Version) {
+			continue
+		}
+		warning := fmt.Sprintf("override of %s to %s violates %s required by %s", replaced.name, cookbook.Version, replaced.constraint, replaced.from)
+		log.WithField(logging.CookbookField, replaced.name).Warn(warning)
+		resolution.AddWarning(warning)
+	}
+}
+
+// constraintsFor returns req together with every injected requirement for
+// the same cookbook, or only the override when the cookbook is overridden
+func (r *DefaultResolver) constraintsFor(req *Requirement) []*Requirement {
+	if override, ok := r.overrides[req.Name]; ok {
+		return []*Requirement{override}
+	}
+	constraints := []*Requirement{req}
+	for _, injected := range r.injected {
+		if injected.Name == req.Name && injected != req {
+			constraints = append(constraints, injected)
+		}
+	}
+	return constraints
+}
+
+// findBestVersionFromCache finds the highest cached version of a cookbook
+// satisfying every constraint, among its newest candidates (see
+// SetMaxCandidates). Satisfying versions left out by the cap are counted in
+// the resolution's Truncated.
+func (r *DefaultResolver) findBestVersionFromCache(name string, constraints []*Requirement, versionMap map[string]map[source.CookbookSource][]*berkshelf.Version, resolution *Resolution) (*berkshelf.Version, source.CookbookSource, error) {
+	sourceVersions, exists := versionMap[name]
+	if !exists {
+		return nil, nil, fmt.Errorf("no versions found for cookbook %s", name)
+	}
+
+	candidates, truncated := newestCandidates(r.sourceOrder(sourceVersions), sourceVersions, r.maxCandidates, func(v *berkshelf.Version) bool {
+		for _, c := range constraints {
+			if c.Constraint != nil && !c.Constraint.Check(v) {
+				return false
 			}
 		}
+		return true
+	})
+	if truncated > 0 {
+		log.WithField(logging.CookbookField, name).Debugf("Considering the newest %d of %d versions of %s", len(candidates), len(candidates)+truncated, name)
+		resolution.truncate(name, truncated)
 	}
 
-	if bestVersion == nil {
-		return nil, nil, fmt.Errorf("no version found that satisfies constraint %s", req.Constraint)
+	// Use the highest version that satisfies, skipping versions
+	// excluded by chef_version
+	skipped := 0
+	for _, c := range candidates {
+		if r.incompatible[name+"@"+c.version.String()] {
+			skipped++
+			continue
+		}
+		return c.version, c.source, nil
 	}
 
-	return bestVersion, bestSource, nil
+	if skipped > 0 {
+		if truncated > 0 {
+			return nil, nil, fmt.Errorf("no version found that satisfies constraint %s and supports Chef %s among the newest %d candidates (%d older version(s) not considered)",
+				describeConstraints(constraints), r.chefVersion, len(candidates), truncated)
+		}
+		return nil, nil, fmt.Errorf("no version found that satisfies constraint %s and supports Chef %s (%d version(s) excluded by chef_version)",
+			describeConstraints(constraints), r.chefVersion, skipped)
+	}
+	return nil, nil, fmt.Errorf("no version found that satisfies constraint %s", describeConstraints(constraints))
+}
+
+// sourceOrder returns the sources of sourceVersions in the order the
+// resolver was given them, followed by any other (requirement specific)
+// sources by name, so that a version offered by several sources always
+// resolves to the same one
+func (r *DefaultResolver) sourceOrder(sourceVersions map[source.CookbookSource][]*berkshelf.Version) []source.CookbookSource {
+	ordered := make([]source.CookbookSource, 0, len(sourceVersions))
+	for _, src := range r.sources {
+		if _, ok := sourceVersions[src]; ok && !slices.Contains(ordered, src) {
+			ordered = append(ordered, src)
+		}
+	}
+	var others []source.CookbookSource
+	for src := range sourceVersions {
+		if !slices.Contains(ordered, src) {
+			others = append(others, src)
+		}
+	}
+	slices.SortFunc(others, func(a, b source.CookbookSource) int { return strings.Compare(a.Name(), b.Name()) })
+	return append(ordered, others...)
+}
+
+// describeConstraints renders constraints for error messages, labelling injected ones
+func describeConstraints(constraints []*Requirement) string {
+	var parts []string
+	for _, c := range constraints {
+		part := "<none>"
+		if c.Constraint != nil {
+			part = c.Constraint.String()
+		}
+		if c.Origin != "" {
+			part += " (injected by " + c.Origin + ")"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ", ")
 }
 
 // getVersions gets available versions from cache or source
@@ -333,26 +707,19 @@ func (r *DefaultResolver) getVersions(ctx context.Context, src source.CookbookSo
 	// Check cache first
 	cacheKey := fmt.Sprintf("%s:%s", src.Name(), name)
 	if versions := r.cache.GetVersions(cacheKey); versions != nil {
+		metrics.Lookup("resolver_versions", true)
 		return versions, nil
 	}
+	metrics.Lookup("resolver_versions", false)
 
 	// Fetch from source
 	versions, err := src.ListVersions(ctx, name)
 	if err != nil {
 		return nil, err
 	}
+	metrics.Add(metrics.VersionsFetched, float64(len(versions)), metrics.L("source", src.Name()))
 
-	// Sort versions in descending order
-	sort.Slice(versions, func(i, j int) bool {
-		return versions[i].GreaterThan(versions[j])
-	})
-
-	// Limit the number of versions to consider
-	if len(versions) > r.maxCandidates {
-		versions = versions[:r.maxCandidates]
-	}
-
-	// Cache the result
+	// Cache the result; candidates are chosen per constraint
 	r.cache.SetVersions(cacheKey, versions)
 
 	return versions, nil
@@ -363,8 +730,10 @@ func (r *DefaultResolver) fetchCookbook(ctx context.Context, name string, versio
 	// Check cache first
 	cacheKey := fmt.Sprintf("%s@%s", name, version.String())
 	if cookbook := r.cache.GetMetadata(cacheKey); cookbook != nil {
+		metrics.Lookup("resolver_metadata", true)
 		return cookbook, nil
 	}
+	metrics.Lookup("resolver_metadata", false)
 
 	// Fetch from source
 	cookbook, err := src.FetchCookbook(ctx, name, version)
@@ -378,6 +747,64 @@ func (r *DefaultResolver) fetchCookbook(ctx context.Context, name string, versio
 	return cookbook, nil
 }
 
+// SetSourceFactory sets the factory creating the sources of requirements
+// that name their own source, such as git and path cookbooks. By default a
+// plain source.Factory is used.
+func (r *DefaultResolver) SetSourceFactory(factory source.SourceFactory) {
+	r.factory = factory
+}
+
+func (r *DefaultResolver) sourceFactory() source.SourceFactory {
+	if r.factory == nil {
+		return source.NewFactory()
+	}
+	return r.factory
+}
+
+// SetChefVersion restricts resolution to cookbook versions whose chef_version
+// accepts v. Cookbooks that declare no chef_version are always accepted.
+// A nil v disables the check.
+func (r *DefaultResolver) SetChefVersion(v *berkshelf.Version) {
+	r.chefVersion = v
+}
+
+// supportsChef reports whether a cookbook's chef_version accepts the target Chef version
+func (r *DefaultResolver) supportsChef(cookbook *berkshelf.Cookbook) bool {
+	if r.chefVersion == nil || cookbook.Metadata == nil || cookbook.Metadata.ChefVersion == nil {
+		return true
+	}
+	return cookbook.Metadata.ChefVersion.Check(r.chefVersion)
+}
+
+// SetMaxCandidates caps how many of the newest versions satisfying its
+// constraints are considered per cookbook, e.g. when versions are skipped
+// for their chef_version. 0 considers every version.
+func (r *DefaultResolver) SetMaxCandidates(n int) {
+	if n >= 0 {
+		r.maxCandidates = n
+	}
+}
+
+// SetKeepGoing sets the policy for cookbooks that cannot be resolved or
+// downloaded. By default resolution stops at the first; with keepGoing every
+// other cookbook is still resolved, and Resolution.Errors holds all the
+// failures.
+func (r *DefaultResolver) SetKeepGoing(keepGoing bool) {
+	r.keepGoing = keepGoing
+}
+
+// failingFast reports whether resolution is to stop, having failed for a
+// cookbook without keepGoing set. Cycles are reported but do not stop it.
+func (r *DefaultResolver) failingFast(resolution *Resolution) bool {
+	if r.keepGoing {
+		return false
+	}
+	return slices.ContainsFunc(resolution.Errors, func(err error) bool {
+		var cookbookErr *berrors.CookbookError
+		return errors.As(err, &cookbookErr)
+	})
+}
+
 // SetMaxWorkers configures the number of concurrent workers for I/O operations
 func (r *DefaultResolver) SetMaxWorkers(workers int) {
 	if workers > 0 {
@@ -386,6 +813,11 @@ func (r *DefaultResolver) SetMaxWorkers(workers int) {
 	}
 }
 
+// SetEventHandler configures a handler that receives progress events during resolution
+func (r *DefaultResolver) SetEventHandler(handler events.Handler) {
+	r.events = handler
+}
+
 // Cache methods
 
 // GetVersions retrieves versions from cache
@@ -406,6 +838,12 @@ func (c *ResolutionCache) GetVersions(key string) []*berkshelf.Version {
 // downloadCookbooksConcurrently downloads cookbook metadata in parallel using conc/pool
 func (r *DefaultResolver) downloadCookbooksConcurrently(ctx context.Context, resolvedCookbooks []*ResolvedCookbook, resolution *Resolution) error {
 	var mu sync.Mutex
+	var failed bool
+
+	// The remaining downloads are cancelled at the first failure, unless
+	// keeping going
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
 	// Create a result pool with context support
 	p := pool.New().WithContext(ctx).WithMaxGoroutines(r.workerCount)
@@ -414,7 +852,7 @@ func (r *DefaultResolver) downloadCookbooksConcurrently(ctx context.Context, res
 	for _, resolved := range resolvedCookbooks {
 		// Use the stored source reference
 		if resolved.SourceRef == nil {
-			log.Warnf("No source reference for %s@%s", resolved.Name, resolved.Version.String())
+			log.WithField(logging.CookbookField, resolved.Name).Warnf("No source reference for %s@%s", resolved.Name, resolved.Version.String())
 			continue
 		}
 
@@ -424,14 +862,27 @@ func (r *DefaultResolver) downloadCookbooksConcurrently(ctx context.Context, res
 		sourceRef := resolved.SourceRef
 
 		p.Go(func(ctx context.Context) error {
+			r.events.Emit(events.Event{Type: events.DownloadStarted, Cookbook: name, Version: version.String(), Source: sourceRef.Name()})
+
 			cookbook, err := r.fetchCookbook(ctx, name, version, sourceRef)
 			if err != nil {
 				mu.Lock()
-				resolution.AddError(fmt.Errorf("failed to fetch %s@%s: %w", name, version.String(), err))
-				mu.Unlock()
-				return nil // Don't fail the entire operation for individual cookbook failures
+				defer mu.Unlock()
+				// Downloads cancelled by the first failure are not failures of their own
+				if failed && !r.keepGoing {
+					return nil
+				}
+				failed = true
+				r.events.Emit(events.Event{Type: events.DownloadFailed, Cookbook: name, Version: version.String(), Error: err.Error()})
+				resolution.AddCookbookError(name, fmt.Errorf("failed to fetch %s@%s: %w", name, version.String(), err))
+				if !r.keepGoing {
+					cancel()
+				}
+				return nil // Reported in the resolution rather than failing it
 			}
 
+			r.events.Emit(events.Event{Type: events.DownloadCompleted, Cookbook: name, Version: version.String()})
+
 			// Find the resolved cookbook and update it
 			mu.Lock()
 			for _, res := range resolvedCookbooks {
@@ -0,0 +1,78 @@
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	"github.com/bdwyertech/go-berkshelf/pkg/source"
+)
+
+// syntheticUniverse returns a source of cookbooks names cookbook-0 to
+// cookbook-<cookbooks-1>, each with versions 1.0.0 to 1.<versions-1>.0.
+// Every version of a cookbook depends on the next two cookbooks, so
+// resolving cookbook-0 walks a dependency chain as deep as the universe.
+func syntheticUniverse(cookbooks, versions int) *mockSource {
+	src := newMockSource("synthetic", 100)
+	for i := range cookbooks {
+		dependencies := map[string]string{}
+		for next := i + 1; next <= i+2 && next < cookbooks; next++ {
+			dependencies[fmt.Sprintf("cookbook-%d", next)] = ">= 1.0"
+		}
+		for v := range versions {
+			src.addCookbook(fmt.Sprintf("cookbook-%d", i), fmt.Sprintf("1.%d.0", v), dependencies)
+		}
+	}
+	return src
+}
+
+// quietResolver silences the per-cookbook resolver logs for the benchmark
+func quietResolver(b *testing.B) {
+	level := log.Logger.GetLevel()
+	log.Logger.SetLevel(logrus.WarnLevel)
+	b.Cleanup(func() { log.Logger.SetLevel(level) })
+}
+
+func BenchmarkResolve(b *testing.B) {
+	quietResolver(b)
+	for _, size := range []struct{ cookbooks, versions int }{
+		{100, 10},  // 1k versions
+		{500, 20},  // 10k versions
+		{1000, 50}, // 50k versions
+		{2000, 25}, // 50k versions, 2000 deep
+		{50, 1000}, // 50k versions, few cookbooks with long histories
+	} {
+		src := syntheticUniverse(size.cookbooks, size.versions)
+		b.Run(fmt.Sprintf("cookbooks=%d/versions=%d", size.cookbooks, size.versions), func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				resolution, err := NewResolver([]source.CookbookSource{src}).Resolve(context.Background(), []*Requirement{
+					NewRequirement("cookbook-0", nil),
+				})
+				if err != nil {
+					b.Fatal(err)
+				}
+				if resolution.HasErrors() || resolution.CookbookCount() != size.cookbooks {
+					b.Fatalf("resolved %d of %d cookbooks: %v", resolution.CookbookCount(), size.cookbooks, resolution.Errors)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkNewestCandidates(b *testing.B) {
+	src := syntheticUniverse(1, 50000)
+	sourceVersions := map[source.CookbookSource][]*berkshelf.Version{src: src.cookbooks["cookbook-0"]}
+	constraint := berkshelf.MustConstraint(">= 1.100.0")
+
+	b.ReportAllocs()
+	for b.Loop() {
+		candidates, _ := newestCandidates([]source.CookbookSource{src}, sourceVersions, DefaultMaxCandidates, constraint.Check)
+		if len(candidates) != DefaultMaxCandidates {
+			b.Fatalf("got %d candidates, want %d", len(candidates), DefaultMaxCandidates)
+		}
+	}
+}
@@ -2,11 +2,20 @@ package resolver
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
 	"strings"
+	"sync"
 	"testing"
 
+	"github.com/bdwyertech/go-berkshelf/internal/testserver"
 	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	berrors "github.com/bdwyertech/go-berkshelf/pkg/errors"
+	"github.com/bdwyertech/go-berkshelf/pkg/events"
+	"github.com/bdwyertech/go-berkshelf/pkg/metrics"
 	"github.com/bdwyertech/go-berkshelf/pkg/source"
 )
 
@@ -165,6 +174,11 @@ func TestBasicResolution(t *testing.T) {
 	if apt.Version.String() != "2.9.2" {
 		t.Errorf("Expected apt version 2.9.2 (highest version satisfying ~> 2.2), got %s", apt.Version.String())
 	}
+
+	// Dependencies record the versions they resolved to
+	if len(nginx.Dependencies) != 2 || nginx.Dependencies["apt"].String() != "2.9.2" || nginx.Dependencies["build-essential"].String() != "2.4.0" {
+		t.Errorf("nginx dependencies = %v, want apt 2.9.2 and build-essential 2.4.0", nginx.Dependencies)
+	}
 }
 
 func TestConflictingConstraints(t *testing.T) {
@@ -373,6 +387,32 @@ func TestMultipleSources(t *testing.T) {
 	}
 }
 
+func TestSourceTieBreak(t *testing.T) {
+	first := newMockSource("first", 50)
+	second := newMockSource("second", 50)
+	first.addCookbook("nginx", "2.7.6", map[string]string{"apt": ">= 0.0.0", "ohai": ">= 0.0.0"})
+	second.addCookbook("nginx", "2.7.6", map[string]string{"apt": ">= 0.0.0", "ohai": ">= 0.0.0"})
+	for _, src := range []*mockSource{first, second} {
+		src.addCookbook("apt", "2.9.2", map[string]string{})
+		src.addCookbook("ohai", "5.0.0", map[string]string{})
+	}
+
+	// A version offered by several sources comes from the first, every time
+	for range 20 {
+		resolution, err := NewResolver(createSources(first, second)).Resolve(context.Background(), []*Requirement{
+			NewRequirement("nginx", berkshelf.MustConstraint(">= 0.0.0")),
+		})
+		if err != nil {
+			t.Fatalf("Resolution failed: %v", err)
+		}
+		for _, cookbook := range resolution.AllCookbooks() {
+			if cookbook.SourceRef.Name() != "first" {
+				t.Fatalf("%s resolved from %s, want first", cookbook.Name, cookbook.SourceRef.Name())
+			}
+		}
+	}
+}
+
 func TestCacheEffectiveness(t *testing.T) {
 	// Create mock source that tracks calls
 	mockSrc := newMockSource("test", 100) //lint:ignore SA4006 this value of mockSrc is never used
@@ -406,3 +446,498 @@ func TestCacheEffectiveness(t *testing.T) {
 	}
 
 }
+
+func TestResolverEvents(t *testing.T) {
+	mockSrc := newMockSource("test", 100)
+	mockSrc.addCookbook("nginx", "2.7.6", map[string]string{"apt": ">= 0.0.0"})
+	mockSrc.addCookbook("apt", "2.9.2", map[string]string{})
+
+	resolver := NewResolver(createSources(mockSrc))
+
+	var mu sync.Mutex
+	counts := make(map[events.Type]int)
+	resolver.SetEventHandler(func(event events.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		counts[event.Type]++
+	})
+
+	_, err := resolver.Resolve(context.Background(), []*Requirement{NewRequirement("nginx", nil)})
+	if err != nil {
+		t.Fatalf("Resolution failed: %v", err)
+	}
+
+	expected := map[events.Type]int{
+		events.ResolutionStarted:   1,
+		events.CookbookResolved:    2,
+		events.DownloadStarted:     2,
+		events.DownloadCompleted:   2,
+		events.ResolutionCompleted: 1,
+	}
+	for eventType, want := range expected {
+		if counts[eventType] != want {
+			t.Errorf("Expected %d %s events, got %d", want, eventType, counts[eventType])
+		}
+	}
+}
+
+func TestInjectedRequirements(t *testing.T) {
+	mockSrc := newMockSource("test", 100)
+	mockSrc.addCookbook("app", "1.0.0", map[string]string{"base": ">= 1.0"})
+	mockSrc.addCookbook("base", "1.5.0", map[string]string{})
+	mockSrc.addCookbook("base", "2.3.0", map[string]string{})
+	mockSrc.addCookbook("base", "3.0.0", map[string]string{})
+	mockSrc.addCookbook("monitoring", "0.4.0", map[string]string{})
+	mockSrc.addCookbook("unused", "1.0.0", map[string]string{})
+
+	r := NewResolver(createSources(mockSrc))
+	r.InjectRequirements("platform-team",
+		NewRequirement("base", berkshelf.MustConstraint("~> 2.0")),
+		NewRequirement("monitoring", nil),
+	)
+	r.InjectRequirements("optional-policy", &Requirement{Name: "unused", Optional: true})
+
+	resolution, err := r.Resolve(context.Background(), []*Requirement{NewRequirement("app", nil)})
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if resolution.HasErrors() {
+		t.Fatalf("Resolution has errors: %v", resolution.Errors)
+	}
+
+	base, ok := resolution.GetCookbook("base")
+	if !ok || base.Version.String() != "2.3.0" {
+		t.Fatalf("Expected base 2.3.0 (>= 1.0 and ~> 2.0), got %v", base)
+	}
+	if len(base.Injected) != 1 || base.Injected[0].Origin != "platform-team" {
+		t.Errorf("Expected base to record the platform-team requirement, got %v", base.Injected)
+	}
+
+	if !resolution.HasCookbook("monitoring") {
+		t.Error("Expected injected requirement monitoring to be resolved")
+	}
+	if resolution.HasCookbook("unused") {
+		t.Error("Optional injected requirement should not add unused")
+	}
+}
+
+func TestInjectedRequirementConflict(t *testing.T) {
+	mockSrc := newMockSource("test", 100)
+	mockSrc.addCookbook("base", "1.5.0", map[string]string{})
+
+	r := NewResolver(createSources(mockSrc))
+	r.InjectRequirements("platform-team", NewRequirement("base", berkshelf.MustConstraint(">= 2.0")))
+
+	resolution, err := r.Resolve(context.Background(), []*Requirement{NewRequirement("base", berkshelf.MustConstraint("~> 1.0"))})
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if !resolution.HasErrors() {
+		t.Fatal("Expected a conflict error")
+	}
+
+	msg := resolution.Errors[0].Error()
+	if !strings.Contains(msg, "~> 1.0") || !strings.Contains(msg, ">= 2.0.0 (injected by platform-team)") {
+		t.Errorf("Conflict message should label the injected constraint, got %q", msg)
+	}
+}
+
+// mockFactory creates the source of a requirement's own location
+type mockFactory map[string]source.CookbookSource
+
+func (f mockFactory) CreateSource(location *berkshelf.SourceLocation) (source.CookbookSource, error) {
+	if src, ok := f[location.URL]; ok {
+		return src, nil
+	}
+	return nil, fmt.Errorf("unknown source %s", location.URL)
+}
+
+func TestOverrides(t *testing.T) {
+	mockSrc := newMockSource("test", 100)
+	mockSrc.addCookbook("app", "1.0.0", map[string]string{"base": "~> 1.0"})
+	mockSrc.addCookbook("base", "1.5.0", map[string]string{})
+	mockSrc.addCookbook("apt", "3.0.0", map[string]string{})
+	mockSrc.addCookbook("apt", "4.0.0", map[string]string{})
+	hotfix := newMockSource("hotfix", 100)
+	hotfix.addCookbook("base", "2.0.1", map[string]string{})
+	hotfixLocation := &berkshelf.SourceLocation{Type: "supermarket", URL: "https://hotfix.example.com"}
+
+	r := NewResolver(createSources(mockSrc))
+	r.SetSourceFactory(mockFactory{hotfixLocation.URL: hotfix})
+	r.InjectRequirements("platform-team", NewRequirement("base", berkshelf.MustConstraint("< 2.0")))
+	r.Override(
+		NewRequirementWithSource("base", berkshelf.MustConstraint("= 2.0.1"), hotfixLocation),
+		NewRequirement("apt", berkshelf.MustConstraint("= 3.0.0")),
+		NewRequirement("unused", berkshelf.MustConstraint("= 1.0.0")),
+	)
+
+	requirements := []*Requirement{NewRequirement("app", nil), NewRequirement("apt", nil)}
+	resolution, err := r.Resolve(context.Background(), requirements)
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if resolution.HasErrors() {
+		t.Fatalf("Resolution has errors: %v", resolution.Errors)
+	}
+
+	base, ok := resolution.GetCookbook("base")
+	if !ok || base.Version.String() != "2.0.1" || !base.Overridden || len(base.Injected) != 0 {
+		t.Fatalf("Expected the base 2.0.1 override to replace ~> 1.0 and < 2.0, got %+v", base)
+	}
+	if apt, ok := resolution.GetCookbook("apt"); !ok || apt.Version.String() != "3.0.0" || !apt.Overridden {
+		t.Errorf("Expected the apt 3.0.0 override, got %+v", apt)
+	}
+	if app, ok := resolution.GetCookbook("app"); !ok || app.Overridden {
+		t.Errorf("Expected app without an override, got %+v", app)
+	}
+	if resolution.HasCookbook("unused") {
+		t.Error("An override should not add a cookbook")
+	}
+	if requirements[1].Constraint != nil {
+		t.Error("Resolve() modified the requirements")
+	}
+}
+
+func TestOverrideViolations(t *testing.T) {
+	mockSrc := newMockSource("test", 100)
+	mockSrc.addCookbook("app", "1.0.0", map[string]string{"nginx": "~> 3.0"})
+	mockSrc.addCookbook("nginx", "2.7.6", map[string]string{})
+	mockSrc.addCookbook("nginx", "3.1.0", map[string]string{})
+
+	r := NewResolver(createSources(mockSrc))
+	r.Override(NewRequirement("nginx", berkshelf.MustConstraint("= 2.7.6")))
+	resolution, err := r.Resolve(context.Background(), []*Requirement{
+		NewRequirement("app", nil),
+		NewRequirement("nginx", berkshelf.MustConstraint(">= 2.0")),
+	})
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if resolution.HasErrors() {
+		t.Fatalf("Resolution has errors: %v", resolution.Errors)
+	}
+
+	if nginx, ok := resolution.GetCookbook("nginx"); !ok || nginx.Version.String() != "2.7.6" {
+		t.Fatalf("Expected the nginx 2.7.6 override, got %+v", nginx)
+	}
+	want := []string{"override of nginx to 2.7.6 violates ~> 3.0 required by app"}
+	if !slices.Equal(resolution.Warnings, want) {
+		t.Errorf("Warnings = %v, want %v", resolution.Warnings, want)
+	}
+}
+
+func TestIgnore(t *testing.T) {
+	mockSrc := newMockSource("test", 100)
+	mockSrc.addCookbook("app", "1.0.0", map[string]string{"chef-client": ">= 0.0.0", "apt": ">= 0.0.0"})
+	mockSrc.addCookbook("chef-client", "12.0.0", map[string]string{"cron": ">= 0.0.0"})
+	mockSrc.addCookbook("cron", "6.0.0", map[string]string{})
+	mockSrc.addCookbook("apt", "7.0.0", map[string]string{})
+
+	r := NewResolver(createSources(mockSrc))
+	r.Ignore("chef-client", "users")
+	resolution, err := r.Resolve(context.Background(), []*Requirement{NewRequirement("app", nil), NewRequirement("users", nil)})
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if resolution.HasErrors() {
+		t.Fatalf("Resolution has errors: %v", resolution.Errors)
+	}
+
+	for _, name := range []string{"chef-client", "cron", "users"} {
+		if resolution.HasCookbook(name) {
+			t.Errorf("Expected %s not to be resolved", name)
+		}
+	}
+	if !resolution.HasCookbook("apt") {
+		t.Error("Expected apt to be resolved")
+	}
+	if !slices.Equal(resolution.Ignored, []string{"chef-client", "users"}) {
+		t.Errorf("Ignored = %v, want [chef-client users]", resolution.Ignored)
+	}
+}
+
+func TestSourceRoutes(t *testing.T) {
+	public := newMockSource("public", 100)
+	private := newMockSource("private", 100)
+	public.addCookbook("app", "1.0.0", map[string]string{"mycorp-base": ">= 0.0.0", "apt": ">= 0.0.0"})
+	public.addCookbook("apt", "7.0.0", map[string]string{})
+	public.addCookbook("mycorp-base", "9.9.9", map[string]string{})
+	private.addCookbook("mycorp-base", "1.2.0", map[string]string{"mycorp-users": ">= 0.0.0"})
+	private.addCookbook("mycorp-users", "2.0.0", map[string]string{})
+
+	r := NewResolver(createSources(public))
+	r.Route(SourceRoute{Pattern: regexp.MustCompile("^mycorp-"), Source: private})
+	resolution, err := r.Resolve(context.Background(), []*Requirement{NewRequirement("app", nil)})
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if resolution.HasErrors() {
+		t.Fatalf("Resolution has errors: %v", resolution.Errors)
+	}
+
+	want := map[string]string{"app": "public", "apt": "public", "mycorp-base": "private", "mycorp-users": "private"}
+	for name, sourceName := range want {
+		cookbook, ok := resolution.Cookbooks[name]
+		if !ok {
+			t.Errorf("Expected %s to be resolved", name)
+			continue
+		}
+		if cookbook.SourceRef.Name() != sourceName {
+			t.Errorf("%s resolved from %s, want %s", name, cookbook.SourceRef.Name(), sourceName)
+		}
+	}
+	if base := resolution.Cookbooks["mycorp-base"]; base != nil && base.Version.String() != "1.2.0" {
+		t.Errorf("mycorp-base = %s, want 1.2.0", base.Version)
+	}
+}
+
+func TestMetadataNameAliases(t *testing.T) {
+	mockSrc := newMockSource("test", 100)
+	mockSrc.addCookbook("app", "1.0.0", map[string]string{"nginx": ">= 12.0"})
+	mockSrc.addCookbook("nginx", "12.0.0", map[string]string{})
+	mockSrc.addCookbook("internal-nginx", "12.1.0", map[string]string{})
+
+	fork := NewRequirement("internal-nginx", nil)
+	fork.MetadataName = "nginx"
+	resolution, err := NewResolver(createSources(mockSrc)).Resolve(context.Background(), []*Requirement{NewRequirement("app", nil), fork})
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if resolution.HasErrors() {
+		t.Fatalf("Resolution has errors: %v", resolution.Errors)
+	}
+
+	if resolution.HasCookbook("nginx") {
+		t.Error("Expected the fork to satisfy the dependency on nginx")
+	}
+	app, ok := resolution.GetCookbook("app")
+	if !ok {
+		t.Fatal("Expected app to be resolved")
+	}
+	if v := app.Dependencies["internal-nginx"]; v == nil || v.String() != "12.1.0" {
+		t.Errorf("Expected app to depend on internal-nginx 12.1.0, got %v", app.Dependencies)
+	}
+	if _, ok := resolution.Graph.GetCookbook("nginx"); ok {
+		t.Error("Expected no graph node for the aliased name")
+	}
+}
+
+func TestMaxCandidates(t *testing.T) {
+	mockSrc := newMockSource("test", 100)
+	for i := range 500 {
+		mockSrc.addCookbook("base", fmt.Sprintf("1.%d.0", i), map[string]string{})
+	}
+	mockSrc.addCookbook("app", "1.0.0", map[string]string{"base": "~> 1.0"})
+
+	// A pin on an old version is found however many newer versions exist
+	r := NewResolver(createSources(mockSrc))
+	resolution, err := r.Resolve(context.Background(), []*Requirement{NewRequirement("base", berkshelf.MustConstraint("= 1.3.0"))})
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if base, ok := resolution.GetCookbook("base"); !ok || base.Version.String() != "1.3.0" {
+		t.Fatalf("expected base 1.3.0, got %v (errors: %v)", base, resolution.Errors)
+	}
+
+	// Only the newest candidates are explored when versions are skipped
+	for i := 490; i < 500; i++ {
+		mockSrc.metadata[fmt.Sprintf("base@1.%d.0", i)].Metadata.ChefVersion = berkshelf.MustConstraint(">= 99.0")
+	}
+	registry := metrics.NewRegistry()
+	metrics.Set(registry)
+	defer metrics.Set(nil)
+	r = NewResolver(createSources(mockSrc))
+	r.SetChefVersion(berkshelf.MustVersion("18.0.0"))
+	r.SetMaxCandidates(5)
+	resolution, err = r.Resolve(context.Background(), []*Requirement{NewRequirement("app", nil)})
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if !resolution.HasErrors() || !strings.Contains(resolution.Errors[0].Error(), "among the newest 5 candidates") {
+		t.Errorf("expected the candidate cap to fail resolution, got %v", resolution.Errors)
+	}
+	if resolution.Truncated["base"] != 495 {
+		t.Errorf("Truncated[base] = %d, want 495", resolution.Truncated["base"])
+	}
+	var out strings.Builder
+	if err := registry.WritePrometheus(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), metrics.CandidatesTruncated+" 495\n") {
+		t.Errorf("metrics missing %s 495:\n%s", metrics.CandidatesTruncated, out.String())
+	}
+
+	r = NewResolver(createSources(mockSrc))
+	r.SetChefVersion(berkshelf.MustVersion("18.0.0"))
+	resolution, err = r.Resolve(context.Background(), []*Requirement{NewRequirement("app", nil)})
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if base, ok := resolution.GetCookbook("base"); !ok || base.Version.String() != "1.489.0" {
+		t.Errorf("expected base 1.489.0, got %v (errors: %v)", base, resolution.Errors)
+	}
+}
+
+func TestChefVersionEnforcement(t *testing.T) {
+	mockSrc := newMockSource("test", 100)
+	mockSrc.addCookbook("app", "1.0.0", map[string]string{"base": ">= 1.0"})
+	mockSrc.addCookbook("base", "1.5.0", map[string]string{})
+	mockSrc.addCookbook("base", "2.3.0", map[string]string{})
+	mockSrc.addCookbook("base", "3.0.0", map[string]string{})
+	mockSrc.metadata["base@2.3.0"].Metadata.ChefVersion = berkshelf.MustConstraint(">= 16.0")
+	mockSrc.metadata["base@3.0.0"].Metadata.ChefVersion = berkshelf.MustConstraint(">= 18.0")
+
+	tests := []struct {
+		chef string
+		want string
+	}{
+		{"", "3.0.0"},
+		{"18.2.7", "3.0.0"},
+		{"17.10.3", "2.3.0"},
+		{"15.17.4", "1.5.0"},
+	}
+	for _, tt := range tests {
+		r := NewResolver(createSources(mockSrc))
+		if tt.chef != "" {
+			r.SetChefVersion(berkshelf.MustVersion(tt.chef))
+		}
+
+		resolution, err := r.Resolve(context.Background(), []*Requirement{NewRequirement("app", nil)})
+		if err != nil {
+			t.Fatalf("Resolve() error = %v", err)
+		}
+		if resolution.HasErrors() {
+			t.Fatalf("Chef %q: resolution has errors: %v", tt.chef, resolution.Errors)
+		}
+		base, ok := resolution.GetCookbook("base")
+		if !ok || base.Version.String() != tt.want {
+			t.Errorf("Chef %q: expected base %s, got %v", tt.chef, tt.want, base)
+		}
+	}
+}
+
+func TestChefVersionEnforcementConflict(t *testing.T) {
+	mockSrc := newMockSource("test", 100)
+	mockSrc.addCookbook("base", "3.0.0", map[string]string{})
+	mockSrc.metadata["base@3.0.0"].Metadata.ChefVersion = berkshelf.MustConstraint(">= 18.0")
+
+	r := NewResolver(createSources(mockSrc))
+	r.SetChefVersion(berkshelf.MustVersion("17.0.0"))
+
+	resolution, err := r.Resolve(context.Background(), []*Requirement{NewRequirement("base", nil)})
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if !resolution.HasErrors() {
+		t.Fatal("Expected resolution to fail when no version supports the Chef version")
+	}
+	if msg := resolution.Errors[0].Error(); !strings.Contains(msg, "supports Chef 17.0.0") {
+		t.Errorf("Expected error to mention the Chef version, got %q", msg)
+	}
+}
+
+func TestKeepGoing(t *testing.T) {
+	mockSrc := newMockSource("test", 100)
+	mockSrc.addCookbook("app", "1.0.0", map[string]string{})
+	mockSrc.addCookbook("web", "1.0.0", map[string]string{})
+	requirements := func() []*Requirement {
+		return []*Requirement{
+			NewRequirement("app", nil),
+			NewRequirement("missing", berkshelf.MustConstraint("~> 1.0")),
+			NewRequirement("absent", berkshelf.MustConstraint("~> 2.0")),
+			NewRequirement("web", nil),
+		}
+	}
+
+	// Resolution stops at the first cookbook that fails
+	resolution, err := NewResolver(createSources(mockSrc)).Resolve(context.Background(), requirements())
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if len(resolution.Errors) != 1 {
+		t.Errorf("expected 1 error failing fast, got %v", resolution.Errors)
+	}
+	if resolution.HasCookbook("web") {
+		t.Error("expected web not to be resolved after the first failure")
+	}
+
+	r := NewResolver(createSources(mockSrc))
+	r.SetKeepGoing(true)
+	resolution, err = r.Resolve(context.Background(), requirements())
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if len(resolution.Errors) != 2 {
+		t.Errorf("expected 2 errors keeping going, got %v", resolution.Errors)
+	}
+	if !resolution.HasCookbook("app") || !resolution.HasCookbook("web") {
+		t.Errorf("expected app and web to be resolved, got %v", resolution.Cookbooks)
+	}
+
+	var failure *berrors.PartialFailure
+	if !errors.As(resolution.Failure(), &failure) {
+		t.Fatalf("Failure() = %v, want a PartialFailure", resolution.Failure())
+	}
+	want := []berrors.CookbookStatus{
+		{Cookbook: "absent", Status: berrors.StatusFailed, Error: resolution.Errors[1].Error()},
+		{Cookbook: "app", Status: berrors.StatusOK},
+		{Cookbook: "missing", Status: berrors.StatusFailed, Error: resolution.Errors[0].Error()},
+		{Cookbook: "web", Status: berrors.StatusOK},
+	}
+	if got := failure.Statuses(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Statuses() = %+v, want %+v", got, want)
+	}
+}
+
+func TestTopologicalSort(t *testing.T) {
+	g := NewDependencyGraph()
+	nodes := make(map[string]*CookbookNode)
+	for _, name := range []string{"app", "apt", "nginx", "ohai", "yum"} {
+		nodes[name] = g.AddCookbook(&berkshelf.Cookbook{Name: name})
+	}
+	g.AddDependency(nodes["app"], nodes["nginx"], nil)
+	g.AddDependency(nodes["nginx"], nodes["apt"], nil)
+	g.AddDependency(nodes["nginx"], nodes["ohai"], nil)
+
+	for range 10 {
+		sorted, err := g.TopologicalSort()
+		if err != nil {
+			t.Fatalf("TopologicalSort() error = %v", err)
+		}
+		var names []string
+		for _, node := range sorted {
+			names = append(names, node.Name)
+		}
+		if want := []string{"app", "nginx", "apt", "ohai", "yum"}; !slices.Equal(names, want) {
+			t.Fatalf("TopologicalSort() = %v, want %v", names, want)
+		}
+	}
+
+	g.AddDependency(nodes["apt"], nodes["app"], nil)
+	if _, err := g.TopologicalSort(); err == nil {
+		t.Error("TopologicalSort() of a cycle succeeded")
+	}
+}
+
+func TestResolution_FakeSupermarket(t *testing.T) {
+	server := testserver.New(t, testserver.Supermarket, testserver.Fixtures()...)
+	resolver := NewResolver(createSources(source.NewSupermarketSource(server.URL)))
+
+	resolution, err := resolver.Resolve(context.Background(), []*Requirement{
+		NewRequirement("nginx", nil),
+		NewRequirement("ohai", nil),
+	})
+	if err != nil || resolution.HasErrors() {
+		t.Fatalf("Resolve() = %v, %v", resolution, err)
+	}
+	want := map[string]string{"nginx": "12.0.0", "apt": "7.4.0", "ohai": "5.3.0"}
+	for name, version := range want {
+		cookbook, found := resolution.GetCookbook(name)
+		if !found || cookbook.Version.String() != version {
+			t.Errorf("resolved %s = %v, want %s", name, cookbook, version)
+		}
+	}
+	if len(resolution.Cookbooks) != len(want) {
+		t.Errorf("resolved %d cookbooks, want %d", len(resolution.Cookbooks), len(want))
+	}
+}
@@ -0,0 +1,144 @@
+package sbom
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/license"
+)
+
+// rootRef is the bom-ref of the project component
+const rootRef = "root"
+
+type cdxDocument struct {
+	BOMFormat    string          `json:"bomFormat"`
+	SpecVersion  string          `json:"specVersion"`
+	SerialNumber string          `json:"serialNumber"`
+	Version      int             `json:"version"`
+	Metadata     cdxMetadata     `json:"metadata"`
+	Components   []cdxComponent  `json:"components"`
+	Dependencies []cdxDependency `json:"dependencies"`
+}
+
+type cdxMetadata struct {
+	Timestamp string       `json:"timestamp"`
+	Tools     cdxTools     `json:"tools"`
+	Component cdxComponent `json:"component"`
+}
+
+type cdxTools struct {
+	Components []cdxComponent `json:"components"`
+}
+
+type cdxComponent struct {
+	Type               string        `json:"type"`
+	BOMRef             string        `json:"bom-ref,omitempty"`
+	Name               string        `json:"name"`
+	Version            string        `json:"version,omitempty"`
+	PURL               string        `json:"purl,omitempty"`
+	Hashes             []cdxHash     `json:"hashes,omitempty"`
+	Licenses           []cdxLicense  `json:"licenses,omitempty"`
+	ExternalReferences []cdxExternal `json:"externalReferences,omitempty"`
+	Properties         []cdxProperty `json:"properties,omitempty"`
+}
+
+type cdxHash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+type cdxLicense struct {
+	License    *cdxLicenseID `json:"license,omitempty"`
+	Expression string        `json:"expression,omitempty"`
+}
+
+type cdxLicenseID struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
+}
+
+type cdxExternal struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+type cdxProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type cdxDependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn"`
+}
+
+// WriteCycloneDX writes the BOM as a CycloneDX 1.5 JSON document. The project
+// is the metadata component and depends on the direct cookbooks.
+func WriteCycloneDX(w io.Writer, bom *BOM) error {
+	doc := cdxDocument{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  "1.5",
+		SerialNumber: "urn:uuid:" + uuid.NewString(),
+		Version:      1,
+		Metadata: cdxMetadata{
+			Timestamp: bom.Created.UTC().Format(time.RFC3339),
+			Tools:     cdxTools{Components: []cdxComponent{{Type: "application", Name: "go-berkshelf"}}},
+			Component: cdxComponent{Type: "application", BOMRef: rootRef, Name: bom.Name},
+		},
+		Components:   []cdxComponent{},
+		Dependencies: []cdxDependency{{Ref: rootRef, DependsOn: append([]string{}, bom.Direct...)}},
+	}
+
+	for _, c := range bom.Components {
+		component := cdxComponent{
+			Type:       "library",
+			BOMRef:     c.Ref(),
+			Name:       c.Name,
+			Version:    c.Version,
+			PURL:       c.PURL,
+			Licenses:   cdxLicenses(c.License),
+			Properties: []cdxProperty{{Name: "berkshelf:source_type", Value: c.SourceType}},
+		}
+		if c.SHA256 != "" {
+			component.Hashes = []cdxHash{{Alg: "SHA-256", Content: c.SHA256}}
+		}
+		switch c.SourceType {
+		case "git":
+			component.ExternalReferences = []cdxExternal{{Type: "vcs", URL: c.SourceURL}}
+			if c.Revision != "" {
+				component.Properties = append(component.Properties, cdxProperty{Name: "berkshelf:git_revision", Value: c.Revision})
+			}
+		case "path":
+			component.Properties = append(component.Properties, cdxProperty{Name: "berkshelf:path", Value: c.Path})
+		default:
+			if location := c.DownloadLocation(); location != "" {
+				component.ExternalReferences = []cdxExternal{{Type: "distribution", URL: location}}
+			}
+		}
+		doc.Components = append(doc.Components, component)
+		doc.Dependencies = append(doc.Dependencies, cdxDependency{Ref: c.Ref(), DependsOn: append([]string{}, c.DependsOn...)})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(doc)
+}
+
+// cdxLicenses expresses an SPDX license as CycloneDX licenses. Expressions
+// are kept whole; LicenseRefs are not SPDX identifiers, so become names.
+func cdxLicenses(spdx string) []cdxLicense {
+	switch {
+	case spdx == "" || spdx == license.NoAssertion:
+		return nil
+	case strings.Contains(spdx, " "):
+		return []cdxLicense{{Expression: spdx}}
+	case strings.HasPrefix(spdx, "LicenseRef-"):
+		return []cdxLicense{{License: &cdxLicenseID{Name: strings.TrimPrefix(spdx, "LicenseRef-")}}}
+	default:
+		return []cdxLicense{{License: &cdxLicenseID{ID: spdx}}}
+	}
+}
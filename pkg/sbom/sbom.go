@@ -0,0 +1,223 @@
+// Package sbom converts a lock file into a software bill of materials in
+// CycloneDX or SPDX format.
+package sbom
+
+import (
+	"cmp"
+	"context"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	"github.com/bdwyertech/go-berkshelf/pkg/license"
+	"github.com/bdwyertech/go-berkshelf/pkg/lockfile"
+	"github.com/bdwyertech/go-berkshelf/pkg/logging"
+	"github.com/bdwyertech/go-berkshelf/pkg/source"
+)
+
+var log = logging.For("sbom")
+
+// BOM is the format-neutral bill of materials for a project
+type BOM struct {
+	// Name is the project the cookbooks were resolved for
+	Name string
+	// Created is when the BOM was generated
+	Created time.Time
+	// Direct are the refs of the cookbooks the Berksfile depends on, if known
+	Direct []string
+	// Components are the locked cookbooks, sorted by name
+	Components []*Component
+}
+
+// Component is one locked cookbook
+type Component struct {
+	Name    string
+	Version string
+	// SourceType is supermarket, chef_server, git or path
+	SourceType string
+	// SourceURL is the Supermarket, Chef Server or git repository URL
+	SourceURL string
+	// Path is the location of a path cookbook
+	Path string
+	// Revision is the git commit a git cookbook was locked to
+	Revision string
+	// PURL is the package URL identifying the cookbook
+	PURL string
+	// License is the SPDX license expression, empty when not collected
+	License string
+	// SHA256 is the hex digest of the cookbook artifact, empty when not collected
+	SHA256 string
+	// DependsOn are the refs of the components this cookbook depends on
+	DependsOn []string
+}
+
+// Ref returns the identifier other components refer to this one by
+func (c *Component) Ref() string {
+	return c.Name + "@" + c.Version
+}
+
+// DownloadLocation returns where the cookbook can be fetched from, or "" when
+// that cannot be told from the lock file
+func (c *Component) DownloadLocation() string {
+	switch c.SourceType {
+	case "supermarket":
+		return strings.TrimRight(c.SourceURL, "/") + "/api/v1/cookbooks/" + url.PathEscape(c.Name) +
+			"/versions/" + url.PathEscape(c.Version) + "/download"
+	case "git":
+		return vcsURL(c.SourceURL, c.Revision)
+	default:
+		return ""
+	}
+}
+
+// New builds a BOM from the cookbooks in lockFile
+func New(name string, lockFile *lockfile.LockFile) *BOM {
+	bom := &BOM{Name: name, Created: time.Now()}
+
+	byName := make(map[string]*Component)
+	locks := make(map[string]*lockfile.CookbookLock)
+	for key, src := range lockFile.Sources {
+		for cookbookName, cookbook := range src.Cookbooks {
+			info := cookbook.Source
+			if info == nil {
+				info = &lockfile.SourceInfo{Type: src.Type, URL: cmp.Or(src.URL, key)}
+			}
+			component := &Component{
+				Name:       cookbookName,
+				Version:    cookbook.Version,
+				SourceType: cmp.Or(info.Type, "supermarket"),
+				SourceURL:  info.URL,
+				Path:       info.Path,
+				Revision:   cmp.Or(info.Revision, info.Ref),
+			}
+			component.PURL = purl(component)
+			byName[cookbookName] = component
+			locks[cookbookName] = cookbook
+			bom.Components = append(bom.Components, component)
+		}
+	}
+
+	for name, component := range byName {
+		for dep := range locks[name].Dependencies {
+			if locked, ok := byName[dep]; ok {
+				component.DependsOn = append(component.DependsOn, locked.Ref())
+			}
+		}
+		sort.Strings(component.DependsOn)
+	}
+	sort.Slice(bom.Components, func(i, j int) bool { return bom.Components[i].Name < bom.Components[j].Name })
+	return bom
+}
+
+// SetDirect records which components the Berksfile depends on directly
+func (b *BOM) SetDirect(names []string) {
+	b.Direct = nil
+	for _, component := range b.Components {
+		for _, name := range names {
+			if component.Name == name {
+				b.Direct = append(b.Direct, component.Ref())
+			}
+		}
+	}
+}
+
+// SetLicenses copies the SPDX license of each entry onto its component
+func (b *BOM) SetLicenses(entries []license.Entry) {
+	for _, entry := range entries {
+		for _, component := range b.Components {
+			if component.Name == entry.Cookbook {
+				component.License = entry.SPDX
+			}
+		}
+	}
+}
+
+// AddChecksums fetches the artifact checksum of each Supermarket cookbook
+// from the matching source in sourceManager. Cookbooks whose checksum cannot
+// be fetched are logged and left without one.
+func (b *BOM) AddChecksums(ctx context.Context, sourceManager *source.Manager) error {
+	for _, component := range b.Components {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		src := checksumSource(sourceManager, component)
+		if src == nil {
+			continue
+		}
+
+		sum, err := componentChecksum(ctx, src, component)
+		if err != nil {
+			log.WithField(logging.CookbookField, component.Name).Warnf("Failed to fetch the checksum of %s: %v", component.Name, err)
+			continue
+		}
+		component.SHA256 = sum
+	}
+	return nil
+}
+
+func componentChecksum(ctx context.Context, src source.CookbookSource, component *Component) (string, error) {
+	version, err := berkshelf.NewVersion(component.Version)
+	if err != nil {
+		return "", err
+	}
+	cookbook, err := src.FetchCookbook(ctx, component.Name, version)
+	if err != nil {
+		return "", err
+	}
+	checksums, _ := source.As[source.ChecksumSource](src)
+	return checksums.Checksum(ctx, cookbook)
+}
+
+// checksumSource returns the configured source the component was locked
+// from, if it serves artifacts
+func checksumSource(sourceManager *source.Manager, component *Component) source.CookbookSource {
+	if sourceManager == nil || component.SourceType != "supermarket" {
+		return nil
+	}
+	for _, src := range sourceManager.GetSources() {
+		if _, ok := source.As[source.ChecksumSource](src); !ok {
+			continue
+		}
+		if strings.TrimRight(src.GetSourceURL(), "/") == strings.TrimRight(component.SourceURL, "/") {
+			return src
+		}
+	}
+	return nil
+}
+
+// purl returns the package URL for a component. Supermarket cookbooks use the
+// supermarket type; others are generic, qualified by where they came from.
+func purl(c *Component) string {
+	qualifiers := url.Values{}
+	kind := "generic"
+	switch c.SourceType {
+	case "supermarket":
+		kind = "supermarket"
+		if strings.TrimRight(c.SourceURL, "/") != source.PUBLIC_SUPERMARKET {
+			qualifiers.Set("repository_url", c.SourceURL)
+		}
+	case "chef_server":
+		qualifiers.Set("repository_url", c.SourceURL)
+	case "git":
+		qualifiers.Set("vcs_url", vcsURL(c.SourceURL, c.Revision))
+	}
+
+	p := "pkg:" + kind + "/" + url.PathEscape(c.Name) + "@" + url.PathEscape(c.Version)
+	if len(qualifiers) > 0 {
+		p += "?" + qualifiers.Encode()
+	}
+	return p
+}
+
+// vcsURL returns a git URL in the form git+<url>@<revision>
+func vcsURL(repository, revision string) string {
+	if !strings.HasPrefix(repository, "git+") && !strings.HasPrefix(repository, "git://") && !strings.HasPrefix(repository, "git@") {
+		repository = "git+" + repository
+	}
+	if revision != "" {
+		repository += "@" + revision
+	}
+	return repository
+}
@@ -0,0 +1,176 @@
+package sbom
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/license"
+	"github.com/bdwyertech/go-berkshelf/pkg/lockfile"
+)
+
+func testLockFile() *lockfile.LockFile {
+	lf := lockfile.NewLockFile()
+	lf.Sources["https://supermarket.chef.io"] = &lockfile.SourceLock{
+		Type: "supermarket",
+		URL:  "https://supermarket.chef.io",
+		Cookbooks: map[string]*lockfile.CookbookLock{
+			"nginx": {Version: "12.0.0", Dependencies: map[string]string{"apt": ">= 0.0.0", "missing": ">= 1.0"}},
+		},
+	}
+	lf.Sources["https://supermarket.example.com"] = &lockfile.SourceLock{
+		Type: "supermarket",
+		URL:  "https://supermarket.example.com",
+		Cookbooks: map[string]*lockfile.CookbookLock{
+			"apt": {Version: "7.4.0"},
+		},
+	}
+	lf.Sources["https://github.com/acme/app.git"] = &lockfile.SourceLock{
+		Cookbooks: map[string]*lockfile.CookbookLock{
+			"app": {
+				Version:      "1.2.0",
+				Dependencies: map[string]string{"nginx": "~> 12.0"},
+				Source:       &lockfile.SourceInfo{Type: "git", URL: "https://github.com/acme/app.git", Ref: "abc123"},
+			},
+		},
+	}
+	return lf
+}
+
+func TestNew(t *testing.T) {
+	bom := New("project", testLockFile())
+	bom.SetDirect([]string{"app"})
+	bom.SetLicenses([]license.Entry{{Cookbook: "nginx", SPDX: "Apache-2.0"}})
+
+	var names []string
+	for _, c := range bom.Components {
+		names = append(names, c.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"app", "apt", "nginx"}) {
+		t.Fatalf("Components = %v", names)
+	}
+	if !reflect.DeepEqual(bom.Direct, []string{"app@1.2.0"}) {
+		t.Errorf("Direct = %v", bom.Direct)
+	}
+
+	app, apt, nginx := bom.Components[0], bom.Components[1], bom.Components[2]
+	tests := []struct {
+		component *Component
+		purl      string
+		location  string
+	}{
+		{app, "pkg:generic/app@1.2.0?vcs_url=git%2Bhttps%3A%2F%2Fgithub.com%2Facme%2Fapp.git%40abc123", "git+https://github.com/acme/app.git@abc123"},
+		{apt, "pkg:supermarket/apt@7.4.0?repository_url=https%3A%2F%2Fsupermarket.example.com", "https://supermarket.example.com/api/v1/cookbooks/apt/versions/7.4.0/download"},
+		{nginx, "pkg:supermarket/nginx@12.0.0", "https://supermarket.chef.io/api/v1/cookbooks/nginx/versions/12.0.0/download"},
+	}
+	for _, tt := range tests {
+		if tt.component.PURL != tt.purl {
+			t.Errorf("%s PURL = %q, want %q", tt.component.Name, tt.component.PURL, tt.purl)
+		}
+		if got := tt.component.DownloadLocation(); got != tt.location {
+			t.Errorf("%s DownloadLocation() = %q, want %q", tt.component.Name, got, tt.location)
+		}
+	}
+
+	// Dependencies that are not locked are dropped
+	if !reflect.DeepEqual(nginx.DependsOn, []string{"apt@7.4.0"}) {
+		t.Errorf("nginx DependsOn = %v", nginx.DependsOn)
+	}
+	if nginx.License != "Apache-2.0" || apt.License != "" {
+		t.Errorf("Licenses = %q, %q", nginx.License, apt.License)
+	}
+}
+
+func TestWriteCycloneDX(t *testing.T) {
+	bom := New("project", testLockFile())
+	bom.Created = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	bom.SetDirect([]string{"app"})
+	bom.Components[2].SHA256 = "deadbeef"
+	bom.Components[2].License = "Apache-2.0"
+	bom.Components[1].License = "MIT OR Apache-2.0"
+
+	var buf bytes.Buffer
+	if err := WriteCycloneDX(&buf, bom); err != nil {
+		t.Fatalf("WriteCycloneDX() error = %v", err)
+	}
+
+	var doc cdxDocument
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if doc.BOMFormat != "CycloneDX" || doc.SpecVersion != "1.5" || doc.Metadata.Timestamp != "2024-01-02T03:04:05Z" {
+		t.Errorf("header = %+v", doc)
+	}
+	if len(doc.Components) != 3 {
+		t.Fatalf("Components = %+v", doc.Components)
+	}
+
+	nginx := doc.Components[2]
+	if !reflect.DeepEqual(nginx.Hashes, []cdxHash{{Alg: "SHA-256", Content: "deadbeef"}}) {
+		t.Errorf("nginx Hashes = %+v", nginx.Hashes)
+	}
+	if len(nginx.Licenses) != 1 || nginx.Licenses[0].License.ID != "Apache-2.0" {
+		t.Errorf("nginx Licenses = %+v", nginx.Licenses)
+	}
+	if apt := doc.Components[1]; len(apt.Licenses) != 1 || apt.Licenses[0].Expression != "MIT OR Apache-2.0" {
+		t.Errorf("apt Licenses = %+v", apt.Licenses)
+	}
+	if app := doc.Components[0]; !reflect.DeepEqual(app.ExternalReferences, []cdxExternal{{Type: "vcs", URL: "https://github.com/acme/app.git"}}) {
+		t.Errorf("app ExternalReferences = %+v", app.ExternalReferences)
+	}
+
+	wantDeps := []cdxDependency{
+		{Ref: "root", DependsOn: []string{"app@1.2.0"}},
+		{Ref: "app@1.2.0", DependsOn: []string{"nginx@12.0.0"}},
+		{Ref: "apt@7.4.0", DependsOn: []string{}},
+		{Ref: "nginx@12.0.0", DependsOn: []string{"apt@7.4.0"}},
+	}
+	if !reflect.DeepEqual(doc.Dependencies, wantDeps) {
+		t.Errorf("Dependencies = %+v", doc.Dependencies)
+	}
+}
+
+func TestWriteSPDX(t *testing.T) {
+	bom := New("project", testLockFile())
+	bom.Components[2].SHA256 = "deadbeef"
+
+	var buf bytes.Buffer
+	if err := WriteSPDX(&buf, bom); err != nil {
+		t.Fatalf("WriteSPDX() error = %v", err)
+	}
+
+	var doc spdxDocument
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if doc.SPDXVersion != "SPDX-2.3" || len(doc.Packages) != 3 {
+		t.Fatalf("document = %+v", doc)
+	}
+
+	nginx := doc.Packages[2]
+	if nginx.SPDXID != "SPDXRef-Package-nginx-12.0.0" || nginx.LicenseDeclared != license.NoAssertion {
+		t.Errorf("nginx = %+v", nginx)
+	}
+	if !reflect.DeepEqual(nginx.Checksums, []spdxChecksum{{Algorithm: "SHA256", ChecksumValue: "deadbeef"}}) {
+		t.Errorf("nginx Checksums = %+v", nginx.Checksums)
+	}
+	if nginx.ExternalRefs[0].ReferenceLocator != "pkg:supermarket/nginx@12.0.0" {
+		t.Errorf("nginx ExternalRefs = %+v", nginx.ExternalRefs)
+	}
+
+	// Without direct dependencies every package is described
+	described, dependsOn := 0, 0
+	for _, r := range doc.Relationships {
+		switch r.RelationshipType {
+		case "DESCRIBES":
+			described++
+		case "DEPENDS_ON":
+			dependsOn++
+		}
+	}
+	if described != 3 || dependsOn != 2 {
+		t.Errorf("Relationships = %+v", doc.Relationships)
+	}
+}
@@ -0,0 +1,139 @@
+package sbom
+
+import (
+	"encoding/json"
+	"io"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/license"
+)
+
+type spdxDocument struct {
+	SPDXVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SPDXID            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+	Packages          []spdxPackage      `json:"packages"`
+	Relationships     []spdxRelationship `json:"relationships"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxPackage struct {
+	Name             string         `json:"name"`
+	SPDXID           string         `json:"SPDXID"`
+	VersionInfo      string         `json:"versionInfo"`
+	DownloadLocation string         `json:"downloadLocation"`
+	FilesAnalyzed    bool           `json:"filesAnalyzed"`
+	LicenseConcluded string         `json:"licenseConcluded"`
+	LicenseDeclared  string         `json:"licenseDeclared"`
+	CopyrightText    string         `json:"copyrightText"`
+	Checksums        []spdxChecksum `json:"checksums,omitempty"`
+	ExternalRefs     []spdxRef      `json:"externalRefs,omitempty"`
+}
+
+type spdxChecksum struct {
+	Algorithm     string `json:"algorithm"`
+	ChecksumValue string `json:"checksumValue"`
+}
+
+type spdxRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+type spdxRelationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+}
+
+// spdxIDChars are the characters not allowed in an SPDX identifier
+var spdxIDChars = regexp.MustCompile(`[^A-Za-z0-9.-]+`)
+
+// WriteSPDX writes the BOM as an SPDX 2.3 JSON document. The document
+// describes the direct cookbooks, or every cookbook when they are not known.
+func WriteSPDX(w io.Writer, bom *BOM) error {
+	doc := spdxDocument{
+		SPDXVersion:       "SPDX-2.3",
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              bom.Name,
+		DocumentNamespace: "https://spdx.org/spdxdocs/berks-" + spdxIDChars.ReplaceAllString(bom.Name, "-") + "-" + uuid.NewString(),
+		CreationInfo: spdxCreationInfo{
+			Created:  bom.Created.UTC().Format(time.RFC3339),
+			Creators: []string{"Tool: go-berkshelf"},
+		},
+		Packages:      []spdxPackage{},
+		Relationships: []spdxRelationship{},
+	}
+
+	ids := make(map[string]string, len(bom.Components))
+	for _, c := range bom.Components {
+		ids[c.Ref()] = "SPDXRef-Package-" + spdxIDChars.ReplaceAllString(c.Name+"-"+c.Version, "-")
+	}
+
+	for _, c := range bom.Components {
+		pkg := spdxPackage{
+			Name:             c.Name,
+			SPDXID:           ids[c.Ref()],
+			VersionInfo:      c.Version,
+			DownloadLocation: orNoAssertion(c.DownloadLocation()),
+			LicenseConcluded: license.NoAssertion,
+			LicenseDeclared:  orNoAssertion(c.License),
+			CopyrightText:    license.NoAssertion,
+			ExternalRefs: []spdxRef{{
+				ReferenceCategory: "PACKAGE-MANAGER",
+				ReferenceType:     "purl",
+				ReferenceLocator:  c.PURL,
+			}},
+		}
+		if c.SHA256 != "" {
+			pkg.Checksums = []spdxChecksum{{Algorithm: "SHA256", ChecksumValue: c.SHA256}}
+		}
+		doc.Packages = append(doc.Packages, pkg)
+	}
+
+	described := bom.Direct
+	if len(described) == 0 {
+		for _, c := range bom.Components {
+			described = append(described, c.Ref())
+		}
+	}
+	for _, ref := range described {
+		doc.Relationships = append(doc.Relationships, spdxRelationship{
+			SPDXElementID:      doc.SPDXID,
+			RelationshipType:   "DESCRIBES",
+			RelatedSPDXElement: ids[ref],
+		})
+	}
+	for _, c := range bom.Components {
+		for _, dep := range c.DependsOn {
+			doc.Relationships = append(doc.Relationships, spdxRelationship{
+				SPDXElementID:      ids[c.Ref()],
+				RelationshipType:   "DEPENDS_ON",
+				RelatedSPDXElement: ids[dep],
+			})
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(doc)
+}
+
+func orNoAssertion(value string) string {
+	if value == "" {
+		return license.NoAssertion
+	}
+	return value
+}
@@ -0,0 +1,257 @@
+// Package server provides maintenance operations against a Chef Server.
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/go-chef/chef"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	"github.com/bdwyertech/go-berkshelf/pkg/logging"
+)
+
+var log = logging.For("server")
+
+// CleanOptions configures a cleanup run
+type CleanOptions struct {
+	// Keep is the number of newest versions to retain for each cookbook
+	Keep int
+	// DryRun reports what would be deleted without deleting anything
+	DryRun bool
+	// Confirm, if set, is called with the planned deletions before anything is
+	// deleted; returning false deletes nothing
+	Confirm func(planned []Deletion) (bool, error)
+	// Cookbooks limits the cleanup to the named cookbooks (if empty, all cookbooks are cleaned)
+	Cookbooks []string
+}
+
+// Deletion identifies a single cookbook version selected for removal
+type Deletion struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// CleanResult contains the result of a cleanup run
+type CleanResult struct {
+	// Deleted lists the versions that were deleted (or would be, in dry-run mode)
+	Deleted []Deletion `json:"deleted"`
+	// Failed maps "name@version" to the error returned by the Chef Server
+	Failed map[string]string `json:"failed,omitempty"`
+}
+
+// Pins maps cookbook names to the constraints that environments and policies place on them
+type Pins map[string][]*berkshelf.Constraint
+
+// Add records a constraint for a cookbook
+func (p Pins) Add(name string, constraint *berkshelf.Constraint) {
+	p[name] = append(p[name], constraint)
+}
+
+// Cleaner removes old cookbook versions from a Chef Server
+type Cleaner struct {
+	client  *chef.Client
+	options CleanOptions
+}
+
+// NewCleaner creates a new Cleaner
+func NewCleaner(client *chef.Client, options CleanOptions) *Cleaner {
+	return &Cleaner{
+		client:  client,
+		options: options,
+	}
+}
+
+// Clean deletes every cookbook version beyond the newest Keep versions that is
+// not pinned by an environment or a policy revision
+func (c *Cleaner) Clean(ctx context.Context) (*CleanResult, error) {
+	if c.options.Keep < 1 {
+		return nil, fmt.Errorf("keep must be at least 1, got %d", c.options.Keep)
+	}
+
+	versions, err := c.listVersions()
+	if err != nil {
+		return nil, err
+	}
+
+	pins, err := c.collectPins()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &CleanResult{
+		Deleted: PlanCleanup(versions, pins, c.options.Keep),
+		Failed:  make(map[string]string),
+	}
+
+	if c.options.DryRun || len(result.Deleted) == 0 {
+		return result, nil
+	}
+
+	if c.options.Confirm != nil {
+		ok, err := c.options.Confirm(result.Deleted)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			result.Deleted = nil
+			return result, nil
+		}
+	}
+
+	deleted := make([]Deletion, 0, len(result.Deleted))
+	for _, d := range result.Deleted {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+
+		log.Infof("Deleting %s (%s)", d.Name, d.Version)
+		if err := c.client.Cookbooks.Delete(d.Name, d.Version); err != nil {
+			result.Failed[d.Name+"@"+d.Version] = err.Error()
+			continue
+		}
+		deleted = append(deleted, d)
+	}
+	result.Deleted = deleted
+
+	return result, nil
+}
+
+// listVersions returns every version of every cookbook on the server
+func (c *Cleaner) listVersions() (map[string][]*berkshelf.Version, error) {
+	listing, err := c.client.Cookbooks.ListAvailableVersions("all")
+	if err != nil {
+		return nil, fmt.Errorf("listing cookbooks: %w", err)
+	}
+
+	wanted := make(map[string]bool)
+	for _, name := range c.options.Cookbooks {
+		wanted[name] = true
+	}
+
+	versions := make(map[string][]*berkshelf.Version)
+	for name, cookbook := range listing {
+		if len(wanted) > 0 && !wanted[name] {
+			continue
+		}
+		for _, info := range cookbook.Versions {
+			v, err := berkshelf.NewVersion(info.Version)
+			if err != nil {
+				log.Debugf("Skipping invalid version %s of %s: %v", info.Version, name, err)
+				continue
+			}
+			versions[name] = append(versions[name], v)
+		}
+	}
+
+	return versions, nil
+}
+
+// collectPins gathers cookbook constraints from all environments and exact
+// versions from all policy revisions
+func (c *Cleaner) collectPins() (Pins, error) {
+	pins := make(Pins)
+
+	environments, err := c.client.Environments.List()
+	if err != nil {
+		return nil, fmt.Errorf("listing environments: %w", err)
+	}
+	if environments != nil {
+		for envName := range *environments {
+			env, err := c.client.Environments.Get(envName)
+			if err != nil {
+				return nil, fmt.Errorf("reading environment %s: %w", envName, err)
+			}
+			for name, constraintStr := range env.CookbookVersions {
+				constraint, err := berkshelf.NewConstraint(constraintStr)
+				if err != nil {
+					log.Warnf("Ignoring invalid constraint %q for %s in environment %s", constraintStr, name, envName)
+					continue
+				}
+				pins.Add(name, constraint)
+			}
+		}
+	}
+
+	policies, err := c.client.Policies.List()
+	if isNotFound(err) {
+		// Servers without policyfile support return 404 for /policies
+		log.Debugf("The server has no policies, ignoring policy pins: %v", err)
+		return pins, nil
+	}
+	if err != nil {
+		// Without the policy pins, versions policies use would be deleted
+		return nil, fmt.Errorf("listing policies: %w", err)
+	}
+	for policyName, policy := range policies {
+		for revisionID := range policy.Revisions {
+			revision, err := c.client.Policies.GetRevisionDetails(policyName, revisionID)
+			if err != nil {
+				return nil, fmt.Errorf("reading policy %s revision %s: %w", policyName, revisionID, err)
+			}
+			for name, lock := range revision.CookbookLocks {
+				constraint, err := berkshelf.NewConstraint("= " + lock.Version)
+				if err != nil {
+					continue
+				}
+				pins.Add(name, constraint)
+			}
+		}
+	}
+
+	return pins, nil
+}
+
+// isNotFound reports whether err is a Chef Server 404
+func isNotFound(err error) bool {
+	var resp *chef.ErrorResponse
+	return errors.As(err, &resp) && resp.Response != nil && resp.Response.StatusCode == http.StatusNotFound
+}
+
+// PlanCleanup selects the versions to delete. For each cookbook the newest keep
+// versions are retained, along with the highest version satisfying each pin.
+func PlanCleanup(versions map[string][]*berkshelf.Version, pins Pins, keep int) []Deletion {
+	var deletions []Deletion
+
+	names := make([]string, 0, len(versions))
+	for name := range versions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		sorted := make([]*berkshelf.Version, len(versions[name]))
+		copy(sorted, versions[name])
+		sort.Slice(sorted, func(i, j int) bool {
+			return sorted[i].GreaterThan(sorted[j])
+		})
+
+		retained := make(map[string]bool)
+		for i := 0; i < len(sorted) && i < keep; i++ {
+			retained[sorted[i].String()] = true
+		}
+
+		// sorted is newest first, so the first match is what the pin resolves to
+		for _, constraint := range pins[name] {
+			for _, v := range sorted {
+				if constraint.Check(v) {
+					retained[v.String()] = true
+					break
+				}
+			}
+		}
+
+		for _, v := range sorted {
+			if !retained[v.String()] {
+				deletions = append(deletions, Deletion{Name: name, Version: v.String()})
+			}
+		}
+	}
+
+	return deletions
+}
@@ -0,0 +1,161 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-chef/chef"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+)
+
+func versions(vs ...string) []*berkshelf.Version {
+	result := make([]*berkshelf.Version, 0, len(vs))
+	for _, v := range vs {
+		result = append(result, berkshelf.MustVersion(v))
+	}
+	return result
+}
+
+func TestPlanCleanup(t *testing.T) {
+	tests := []struct {
+		name     string
+		versions map[string][]*berkshelf.Version
+		pins     Pins
+		keep     int
+		expected []Deletion
+	}{
+		{
+			name: "keeps newest versions",
+			versions: map[string][]*berkshelf.Version{
+				"nginx": versions("1.0.0", "3.0.0", "2.0.0", "1.5.0"),
+			},
+			pins: Pins{},
+			keep: 2,
+			expected: []Deletion{
+				{Name: "nginx", Version: "1.5.0"},
+				{Name: "nginx", Version: "1.0.0"},
+			},
+		},
+		{
+			name: "nothing to delete",
+			versions: map[string][]*berkshelf.Version{
+				"apt": versions("1.0.0"),
+			},
+			pins:     Pins{},
+			keep:     3,
+			expected: nil,
+		},
+		{
+			name: "respects exact pins",
+			versions: map[string][]*berkshelf.Version{
+				"nginx": versions("1.0.0", "2.0.0", "3.0.0"),
+			},
+			pins: Pins{
+				"nginx": {berkshelf.MustConstraint("= 1.0.0")},
+			},
+			keep: 1,
+			expected: []Deletion{
+				{Name: "nginx", Version: "2.0.0"},
+			},
+		},
+		{
+			name: "range pins keep the highest satisfying version",
+			versions: map[string][]*berkshelf.Version{
+				"nginx": versions("1.0.0", "1.1.0", "2.0.0", "3.0.0"),
+			},
+			pins: Pins{
+				"nginx": {berkshelf.MustConstraint("~> 1.0")},
+			},
+			keep: 1,
+			expected: []Deletion{
+				{Name: "nginx", Version: "2.0.0"},
+				{Name: "nginx", Version: "1.0.0"},
+			},
+		},
+		{
+			name: "multiple cookbooks are ordered by name",
+			versions: map[string][]*berkshelf.Version{
+				"zlib": versions("1.0.0", "2.0.0"),
+				"apt":  versions("1.0.0", "2.0.0"),
+			},
+			pins: Pins{},
+			keep: 1,
+			expected: []Deletion{
+				{Name: "apt", Version: "1.0.0"},
+				{Name: "zlib", Version: "1.0.0"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := PlanCleanup(tt.versions, tt.pins, tt.keep)
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("PlanCleanup() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+// chefServer returns a client of a Chef Server with the environment
+// _default pinning nginx, whose policies endpoint answers with status
+func chefServer(t *testing.T, policiesStatus int) *chef.Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/environments":
+			json.NewEncoder(w).Encode(map[string]string{"_default": "http://chef/environments/_default"})
+		case "/environments/_default":
+			json.NewEncoder(w).Encode(map[string]any{"name": "_default", "cookbook_versions": map[string]string{"nginx": "= 1.0.0"}})
+		case "/policies":
+			w.WriteHeader(policiesStatus)
+			json.NewEncoder(w).Encode(map[string]any{})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := chef.NewClient(&chef.Config{
+		Name:    "test",
+		Key:     string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})),
+		BaseURL: server.URL + "/",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestCollectPins_NoPolicies(t *testing.T) {
+	// Servers without policyfile support answer 404
+	pins, err := NewCleaner(chefServer(t, http.StatusNotFound), CleanOptions{Keep: 1}).collectPins()
+	if err != nil {
+		t.Fatalf("collectPins() error = %v", err)
+	}
+	if len(pins["nginx"]) != 1 {
+		t.Errorf("collectPins() = %v, want the environment's nginx pin", pins)
+	}
+}
+
+func TestCollectPins_PolicyError(t *testing.T) {
+	for _, status := range []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusInternalServerError} {
+		_, err := NewCleaner(chefServer(t, status), CleanOptions{Keep: 1}).collectPins()
+		if err == nil || !strings.Contains(err.Error(), "listing policies") {
+			t.Errorf("collectPins() with policies answering %d error = %v, want it to fail", status, err)
+		}
+	}
+}
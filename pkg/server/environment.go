@@ -0,0 +1,106 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/go-chef/chef"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	"github.com/bdwyertech/go-berkshelf/pkg/resolver"
+)
+
+// Environment holds the cookbook constraints of a Chef environment
+type Environment struct {
+	Name        string
+	Constraints map[string]*berkshelf.Constraint
+}
+
+// LoadEnvironment reads a Chef environment from a JSON file, as exported by
+// 'knife environment show -F json'
+func LoadEnvironment(path string) (*Environment, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading environment: %w", err)
+	}
+	var env chef.Environment
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("parsing environment %s: %w", path, err)
+	}
+	if env.Name == "" {
+		env.Name = path
+	}
+	return newEnvironment(&env)
+}
+
+// FetchEnvironment reads a Chef environment from the Chef Server
+func FetchEnvironment(client *chef.Client, name string) (*Environment, error) {
+	env, err := client.Environments.Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("reading environment %s: %w", name, err)
+	}
+	return newEnvironment(env)
+}
+
+func newEnvironment(env *chef.Environment) (*Environment, error) {
+	result := &Environment{Name: env.Name, Constraints: make(map[string]*berkshelf.Constraint)}
+	for name, constraintStr := range env.CookbookVersions {
+		constraint, err := berkshelf.NewConstraint(constraintStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid constraint %q for %s in environment %s: %w", constraintStr, name, env.Name, err)
+		}
+		result.Constraints[name] = constraint
+	}
+	return result, nil
+}
+
+// Origin labels the environment's constraints in resolution messages
+func (e *Environment) Origin() string {
+	return "environment " + e.Name
+}
+
+// Requirements returns the environment's constraints as requirements to
+// inject into a resolution. They only constrain cookbooks that are
+// otherwise resolved, as an environment constrains cookbooks it may not run.
+func (e *Environment) Requirements() []*resolver.Requirement {
+	requirements := make([]*resolver.Requirement, 0, len(e.Constraints))
+	for _, name := range e.names() {
+		req := resolver.NewRequirement(name, e.Constraints[name])
+		req.Optional = true
+		requirements = append(requirements, req)
+	}
+	return requirements
+}
+
+// Keys returns the constraints as "name constraint" strings, sorted by name
+func (e *Environment) Keys() []string {
+	keys := make([]string, 0, len(e.Constraints))
+	for _, name := range e.names() {
+		keys = append(keys, name+" "+e.Constraints[name].String())
+	}
+	return keys
+}
+
+// Violations describes each of versions the environment's constraints do
+// not allow
+func (e *Environment) Violations(versions map[string]*berkshelf.Version) []string {
+	var violations []string
+	for _, name := range e.names() {
+		version, ok := versions[name]
+		if ok && !e.Constraints[name].Check(version) {
+			violations = append(violations, fmt.Sprintf("%s %s does not satisfy %s required by %s", name, version, e.Constraints[name], e.Origin()))
+		}
+	}
+	return violations
+}
+
+func (e *Environment) names() []string {
+	names := make([]string, 0, len(e.Constraints))
+	for name := range e.Constraints {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+)
+
+func writeEnvironment(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "production.json")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadEnvironment(t *testing.T) {
+	env, err := LoadEnvironment(writeEnvironment(t, `{
+		"name": "production",
+		"json_class": "Chef::Environment",
+		"cookbook_versions": {"nginx": "~> 12.0", "apt": "= 7.4.0"}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env.Origin() != "environment production" {
+		t.Errorf("Origin() = %q", env.Origin())
+	}
+	if keys := env.Keys(); !reflect.DeepEqual(keys, []string{"apt = 7.4.0", "nginx ~> 12.0"}) {
+		t.Errorf("Keys() = %v", keys)
+	}
+
+	requirements := env.Requirements()
+	if len(requirements) != 2 || requirements[0].Name != "apt" || !requirements[0].Optional {
+		t.Errorf("Requirements() = %v, want optional requirements by name", requirements)
+	}
+
+	violations := env.Violations(map[string]*berkshelf.Version{
+		"nginx": berkshelf.MustVersion("13.0.0"),
+		"apt":   berkshelf.MustVersion("7.4.0"),
+		"other": berkshelf.MustVersion("1.0.0"),
+	})
+	expected := []string{"nginx 13.0.0 does not satisfy ~> 12.0 required by environment production"}
+	if !reflect.DeepEqual(violations, expected) {
+		t.Errorf("Violations() = %v, want %v", violations, expected)
+	}
+}
+
+func TestLoadEnvironmentErrors(t *testing.T) {
+	if _, err := LoadEnvironment(writeEnvironment(t, `{"name": "bad", "cookbook_versions": {"nginx": "about 12"}}`)); err == nil {
+		t.Error("LoadEnvironment() accepted an invalid constraint")
+	}
+	if _, err := LoadEnvironment(writeEnvironment(t, `not json`)); err == nil {
+		t.Error("LoadEnvironment() accepted invalid JSON")
+	}
+	if _, err := LoadEnvironment(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("LoadEnvironment() accepted a missing file")
+	}
+}
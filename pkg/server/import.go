@@ -0,0 +1,118 @@
+package server
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-chef/chef"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+)
+
+// ImportOptions configures an import
+type ImportOptions struct {
+	// Environment, if set, imports the version of each cookbook the
+	// environment's constraints select instead of the newest uploaded
+	Environment string
+	// Cookbooks limits the import to the named cookbooks (if empty, all
+	// cookbooks are imported)
+	Cookbooks []string
+}
+
+// Pin is a cookbook version an import locks
+type Pin struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// Import returns the cookbook versions a Chef Server currently serves: the
+// newest of each cookbook, or the newest an environment allows
+func Import(client *chef.Client, options ImportOptions) ([]Pin, error) {
+	var listing map[string]chef.CookbookVersions
+	if options.Environment != "" {
+		cookbooks, err := client.Environments.ListCookbooks(options.Environment, "1")
+		if err != nil {
+			return nil, fmt.Errorf("listing cookbooks of environment %s: %w", options.Environment, err)
+		}
+		listing = cookbooks
+	} else {
+		cookbooks, err := client.Cookbooks.ListAvailableVersions("1")
+		if err != nil {
+			return nil, fmt.Errorf("listing cookbooks: %w", err)
+		}
+		listing = cookbooks
+	}
+	return SelectPins(listing, options.Cookbooks)
+}
+
+// SelectPins picks the newest version of each cookbook in a listing of the
+// Chef Server, limited to cookbooks when it is not empty. A named cookbook
+// that is missing, or has no version in the listing, is an error.
+func SelectPins(listing map[string]chef.CookbookVersions, cookbooks []string) ([]Pin, error) {
+	wanted := make(map[string]bool)
+	for _, name := range cookbooks {
+		wanted[name] = true
+	}
+
+	var pins []Pin
+	found := make(map[string]bool)
+	for name, cookbook := range listing {
+		if len(wanted) > 0 && !wanted[name] {
+			continue
+		}
+		var newest *berkshelf.Version
+		for _, info := range cookbook.Versions {
+			v, err := berkshelf.NewVersion(info.Version)
+			if err != nil {
+				log.Debugf("Skipping invalid version %s of %s: %v", info.Version, name, err)
+				continue
+			}
+			if newest == nil || v.GreaterThan(newest) {
+				newest = v
+			}
+		}
+		if newest == nil {
+			// Environments list cookbooks their constraints exclude entirely
+			log.Debugf("Skipping %s: no version is available", name)
+			continue
+		}
+		pins = append(pins, Pin{Name: name, Version: newest.String()})
+		found[name] = true
+	}
+	var missing []string
+	for name := range wanted {
+		if !found[name] {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("no version of %s is available on the Chef Server", strings.Join(missing, ", "))
+	}
+
+	sort.Slice(pins, func(i, j int) bool {
+		return pins[i].Name < pins[j].Name
+	})
+	return pins, nil
+}
+
+// Berksfile renders a Berksfile resolving from the Chef Server at serverURL
+// that pins each cookbook to its imported version. Credentials are left to
+// the config of whoever installs it.
+func Berksfile(serverURL string, options ImportOptions, pins []Pin) string {
+	var out strings.Builder
+	if options.Environment != "" {
+		fmt.Fprintf(&out, "# Imported from the %s environment of %s\n", options.Environment, serverURL)
+	} else {
+		fmt.Fprintf(&out, "# Imported from %s\n", serverURL)
+	}
+	fmt.Fprintf(&out, "source chef_server: %q\n", serverURL)
+	if len(pins) > 0 {
+		out.WriteString("\n")
+	}
+	for _, pin := range pins {
+		fmt.Fprintf(&out, "cookbook %q, %q\n", pin.Name, "= "+pin.Version)
+	}
+	return out.String()
+}
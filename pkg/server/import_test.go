@@ -0,0 +1,68 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-chef/chef"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berksfile"
+)
+
+func listing(cookbooks map[string][]string) map[string]chef.CookbookVersions {
+	result := make(map[string]chef.CookbookVersions)
+	for name, versions := range cookbooks {
+		var cookbook chef.CookbookVersions
+		for _, v := range versions {
+			cookbook.Versions = append(cookbook.Versions, chef.CookbookVersion{Version: v})
+		}
+		result[name] = cookbook
+	}
+	return result
+}
+
+func TestSelectPins(t *testing.T) {
+	server := listing(map[string][]string{
+		"nginx":   {"2.0.0", "12.1.0", "9.0.0"},
+		"apt":     {"7.4.0"},
+		"retired": nil,
+	})
+
+	pins, err := SelectPins(server, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Pin{{Name: "apt", Version: "7.4.0"}, {Name: "nginx", Version: "12.1.0"}}
+	if !reflect.DeepEqual(pins, expected) {
+		t.Errorf("SelectPins() = %v, want %v", pins, expected)
+	}
+
+	pins, err = SelectPins(server, []string{"nginx"})
+	if err != nil || !reflect.DeepEqual(pins, expected[1:]) {
+		t.Errorf("SelectPins(nginx) = %v, %v", pins, err)
+	}
+
+	if _, err := SelectPins(server, []string{"nginx", "retired", "missing"}); err == nil ||
+		err.Error() != "no version of missing, retired is available on the Chef Server" {
+		t.Errorf("SelectPins() error = %v", err)
+	}
+}
+
+func TestBerksfile(t *testing.T) {
+	const url = "https://chef.example.com/organizations/acme"
+	content := Berksfile(url, ImportOptions{Environment: "production"}, []Pin{
+		{Name: "apt", Version: "7.4.0"},
+		{Name: "nginx", Version: "12.1.0"},
+	})
+
+	bf, err := berksfile.Parse(content)
+	if err != nil {
+		t.Fatalf("Parse() error = %v\n%s", err, content)
+	}
+	if len(bf.Sources) != 1 || bf.Sources[0].Type != "chef_server" || bf.Sources[0].URL != url {
+		t.Errorf("sources = %v, want the Chef Server", bf.Sources)
+	}
+	if len(bf.Cookbooks) != 2 || bf.Cookbooks[1].Name != "nginx" || bf.Cookbooks[1].Constraint.String() != "= 12.1.0" {
+		t.Errorf("cookbooks = %v, want pinned versions\n%s", bf.Cookbooks, content)
+	}
+}
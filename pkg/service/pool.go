@@ -0,0 +1,119 @@
+package service
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	"github.com/bdwyertech/go-berkshelf/pkg/source"
+)
+
+// maxPooledSources is how many sources a pool keeps before it forgets the
+// least recently used
+const maxPooledSources = 256
+
+// pool keeps the sources created for requests, so later requests naming the
+// same source reuse the versions and metadata it has already fetched
+type pool struct {
+	factory *source.Factory
+	// refresh is how long a source is reused; 0 is forever
+	refresh time.Duration
+	// max is how many sources are kept
+	max int
+
+	mu      sync.Mutex
+	sources map[string]pooledSource
+	// uses counts the sources returned, ordering them by when last used
+	uses uint64
+}
+
+// pooledSource is a source, when it was created and the use it was last
+// returned for
+type pooledSource struct {
+	source  source.CookbookSource
+	created time.Time
+	used    uint64
+}
+
+func newPool(factory *source.Factory, refresh time.Duration) *pool {
+	return &pool{factory: factory, refresh: refresh, max: maxPooledSources, sources: make(map[string]pooledSource)}
+}
+
+// get returns the source kept as key, creating it if there is none or it is
+// due to be refreshed. Requests still using a replaced source finish with it.
+func (p *pool) get(key string, create func() (source.CookbookSource, error)) (source.CookbookSource, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	p.uses++
+	if pooled, ok := p.sources[key]; ok && !p.expired(pooled, now) {
+		pooled.used = p.uses
+		p.sources[key] = pooled
+		return pooled.source, nil
+	}
+	src, err := create()
+	if err != nil {
+		return nil, err
+	}
+	delete(p.sources, key)
+	p.evict(now)
+	p.sources[key] = pooledSource{source: src, created: now, used: p.uses}
+	return src, nil
+}
+
+// expired reports whether a pooled source is due to be refreshed
+func (p *pool) expired(pooled pooledSource, now time.Time) bool {
+	return p.refresh > 0 && now.Sub(pooled.created) >= p.refresh
+}
+
+// evict makes room for a source: it forgets the sources due to be
+// refreshed, then the least recently used if the pool is still full
+func (p *pool) evict(now time.Time) {
+	if len(p.sources) < p.max {
+		return
+	}
+	var oldest string
+	for key, pooled := range p.sources {
+		if p.expired(pooled, now) {
+			delete(p.sources, key)
+		} else if oldest == "" || pooled.used < p.sources[oldest].used {
+			oldest = key
+		}
+	}
+	if len(p.sources) >= p.max {
+		delete(p.sources, oldest)
+	}
+}
+
+// CreateSource returns the source of a location, implementing
+// source.SourceFactory for the sources requirements name themselves
+func (p *pool) CreateSource(location *berkshelf.SourceLocation) (source.CookbookSource, error) {
+	if location == nil {
+		return nil, fmt.Errorf("location cannot be nil")
+	}
+	if location.Type == "git" || location.Type == "github" {
+		// A git source records the commit it checked out for the lock
+		// file, so each request gets its own; the clone is kept on disk
+		return p.factory.CreateFromLocation(location)
+	}
+	// fmt prints maps sorted by key, so equal options make equal keys
+	key := fmt.Sprintf("%s %s %s %s %v", location.Type, location.URL, location.Ref, location.Path, location.Options)
+	return p.get(key, func() (source.CookbookSource, error) {
+		return p.factory.CreateFromLocation(location)
+	})
+}
+
+// CreateFromURL returns the source at url
+func (p *pool) CreateFromURL(url string) (source.CookbookSource, error) {
+	return p.get("url "+url, func() (source.CookbookSource, error) {
+		return p.factory.CreateFromURL(url)
+	})
+}
+
+// size returns the number of sources kept
+func (p *pool) size() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.sources)
+}
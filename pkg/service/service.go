@@ -0,0 +1,526 @@
+// Package service serves dependency resolution over HTTP. Sources are kept
+// between requests with the versions and metadata they have fetched, so the
+// CI jobs of an organization can share one warm resolver rather than each
+// querying the Supermarket for every cookbook again.
+//
+// Each endpoint takes a Request as JSON:
+//
+//	POST /v1/resolve   resolves the Berksfile and lists the cookbooks
+//	POST /v1/lock      resolves the Berksfile and returns its lock files
+//	POST /v1/outdated  lists the cookbooks of the lock file with newer versions
+//	GET  /healthz      reports that the service is up
+//
+// Failures are returned as {"error": ...} with the fields of an
+// errors.Summary.
+package service
+
+import (
+	"cmp"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berks"
+	"github.com/bdwyertech/go-berkshelf/pkg/berksfile"
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	"github.com/bdwyertech/go-berkshelf/pkg/credentials"
+	berrors "github.com/bdwyertech/go-berkshelf/pkg/errors"
+	"github.com/bdwyertech/go-berkshelf/pkg/lockfile"
+	"github.com/bdwyertech/go-berkshelf/pkg/logging"
+	"github.com/bdwyertech/go-berkshelf/pkg/metrics"
+	"github.com/bdwyertech/go-berkshelf/pkg/outdated"
+	"github.com/bdwyertech/go-berkshelf/pkg/resolver"
+	"github.com/bdwyertech/go-berkshelf/pkg/source"
+)
+
+var log = logging.For("service")
+
+// DefaultMaxRequestSize limits request bodies when Options.MaxRequestSize is 0
+const DefaultMaxRequestSize = 4 << 20
+
+// Options configures a Server
+type Options struct {
+	// Factory creates the sources; nil uses a plain source.Factory
+	Factory *source.Factory
+	// Refresh is how long a source is reused before it is created again,
+	// forgetting what it has fetched; 0 reuses sources for the life of the
+	// server
+	Refresh time.Duration
+	// GroupSources maps Berksfile groups to the default source of their cookbooks
+	GroupSources map[string]string
+	// SourceRoutes send cookbooks to sources by name; the first match wins
+	SourceRoutes []berks.SourceRoute
+	// TrustedSources are the URLs of the Chef Server, git, S3, OCI and other
+	// non-Supermarket sources Berksfiles may name, which are reached with the
+	// service's credentials. The URLs of GroupSources and SourceRoutes are
+	// trusted as well.
+	TrustedSources []string
+	// ResolveTimeout limits each resolution; 0 is no limit
+	ResolveTimeout time.Duration
+	// Token, when set, must be sent by clients as a bearer token
+	Token string
+	// MaxRequestSize limits request bodies in bytes; 0 is DefaultMaxRequestSize
+	MaxRequestSize int64
+	// Metrics, when set, is served at /metrics in the Prometheus text format
+	Metrics *metrics.Registry
+}
+
+// Request is the body of the resolve, lock and outdated endpoints
+type Request struct {
+	// Berksfile is the content of the Berksfile
+	Berksfile string `json:"berksfile"`
+	// LockFile is the Berksfile.go.lock whose overrides pin cookbooks, and
+	// which outdated checks
+	LockFile json.RawMessage `json:"lockfile,omitempty"`
+	// Only and Except select Berksfile groups, as --only and --except do
+	Only   []string `json:"only,omitempty"`
+	Except []string `json:"except,omitempty"`
+	// Cookbooks limits outdated to these cookbooks
+	Cookbooks []string `json:"cookbooks,omitempty"`
+}
+
+// ResolveResponse is the response of /v1/resolve
+type ResolveResponse struct {
+	// Cookbooks are the resolved cookbooks, sorted by name
+	Cookbooks []Cookbook `json:"cookbooks"`
+	// Ignored are the ignored cookbooks resolution skipped
+	Ignored []string `json:"ignored,omitempty"`
+	// Warnings are non-fatal issues, such as overrides violating constraints
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// Cookbook is a resolved cookbook
+type Cookbook struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Source  string `json:"source,omitempty"`
+	// Dependencies maps each dependency to the version it resolved to
+	Dependencies map[string]string `json:"dependencies,omitempty"`
+}
+
+// LockResponse is the response of /v1/lock
+type LockResponse struct {
+	// LockFile is the Berksfile.go.lock of the resolution
+	LockFile json.RawMessage `json:"lockfile"`
+	// RubyLockFile is the Berksfile.lock of the resolution
+	RubyLockFile string `json:"berksfile_lock"`
+}
+
+// OutdatedResponse is the response of /v1/outdated
+type OutdatedResponse struct {
+	Outdated []outdated.Cookbook `json:"outdated"`
+	// Migrations are the deprecated cookbooks and their replacements
+	Migrations []outdated.Migration `json:"migrations,omitempty"`
+}
+
+// ErrorResponse is the body of a failed request
+type ErrorResponse struct {
+	Error berrors.Summary `json:"error"`
+}
+
+// Server serves resolution over HTTP
+type Server struct {
+	options Options
+	pool    *pool
+	trusted map[string]bool
+	mux     *http.ServeMux
+	started time.Time
+
+	// parseMu serializes Berksfile parsing, as the parser is not safe for
+	// concurrent use
+	parseMu sync.Mutex
+}
+
+// New returns a server with the given options
+func New(options Options) *Server {
+	factory := options.Factory
+	if factory == nil {
+		factory = source.NewFactory()
+	}
+	if options.MaxRequestSize <= 0 {
+		options.MaxRequestSize = DefaultMaxRequestSize
+	}
+	s := &Server{
+		options: options,
+		pool:    newPool(factory, options.Refresh),
+		trusted: make(map[string]bool),
+		mux:     http.NewServeMux(),
+		started: time.Now(),
+	}
+	for _, url := range options.TrustedSources {
+		s.trusted[strings.TrimSuffix(url, "/")] = true
+	}
+	for _, url := range options.GroupSources {
+		s.trusted[strings.TrimSuffix(url, "/")] = true
+	}
+	for _, route := range options.SourceRoutes {
+		s.trusted[strings.TrimSuffix(route.URL, "/")] = true
+	}
+	s.mux.HandleFunc("POST /v1/resolve", s.handle(s.resolveHandler))
+	s.mux.HandleFunc("POST /v1/lock", s.handle(s.lockHandler))
+	s.mux.HandleFunc("POST /v1/outdated", s.handle(s.outdatedHandler))
+	s.mux.HandleFunc("GET /healthz", s.health)
+	if options.Metrics != nil {
+		s.mux.HandleFunc("GET /metrics", s.authorized(s.serveMetrics))
+	}
+	return s
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	s.mux.ServeHTTP(recorder, r)
+	log.Infof("%s %s %d %s", r.Method, r.URL.Path, recorder.status, time.Since(start).Round(time.Millisecond))
+}
+
+// statusRecorder records the status of a response for the request log
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// authorized rejects requests without the configured bearer token
+func (s *Server) authorized(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.options.Token != "" {
+			token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.options.Token)) != 1 {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: berrors.Summarize(
+					berrors.WithType(errors.New("a valid bearer token is required"), berrors.ErrorTypeAuthentication))})
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+// handle decodes the request of an endpoint and writes its response, or
+// the error it failed with
+func (s *Server) handle(endpoint func(context.Context, *Request) (any, error)) http.HandlerFunc {
+	return s.authorized(func(w http.ResponseWriter, r *http.Request) {
+		var req Request
+		decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, s.options.MaxRequestSize))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&req); err != nil {
+			writeError(w, berrors.WithType(fmt.Errorf("invalid request: %w", err), berrors.ErrorTypeValidation))
+			return
+		}
+		response, err := endpoint(r.Context(), &req)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, response)
+	})
+}
+
+func (s *Server) resolveHandler(ctx context.Context, req *Request) (any, error) {
+	resolution, _, err := s.resolve(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	response := ResolveResponse{Cookbooks: []Cookbook{}, Ignored: resolution.Ignored, Warnings: resolution.Warnings}
+	for _, resolved := range resolution.AllCookbooks() {
+		cookbook := Cookbook{Name: resolved.Name, Version: resolved.Version.String(), Source: resolved.Source.String()}
+		if len(resolved.Dependencies) > 0 {
+			cookbook.Dependencies = make(map[string]string, len(resolved.Dependencies))
+			for name, version := range resolved.Dependencies {
+				cookbook.Dependencies[name] = version.String()
+			}
+		}
+		response.Cookbooks = append(response.Cookbooks, cookbook)
+	}
+	return response, nil
+}
+
+func (s *Server) lockHandler(ctx context.Context, req *Request) (any, error) {
+	resolution, bf, err := s.resolve(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	lockFile := lockfile.FromResolution(resolution)
+	if existing, err := requestLockFile(req); err == nil && existing != nil {
+		lockFile.Overrides = existing.Overrides
+	}
+	data, err := lockFile.ToJSON()
+	if err != nil {
+		return nil, err
+	}
+	cookbooks := berksfile.FilterCookbooksByGroup(bf.Cookbooks, req.Only, req.Except)
+	ruby, err := lockFile.ToRubyFormat(lockfile.DirectDependencies(cookbooks))
+	if err != nil {
+		return nil, err
+	}
+	return LockResponse{LockFile: data, RubyLockFile: string(ruby)}, nil
+}
+
+func (s *Server) outdatedHandler(ctx context.Context, req *Request) (any, error) {
+	lockFile, err := requestLockFile(req)
+	if err != nil {
+		return nil, err
+	}
+	if lockFile == nil {
+		return nil, berrors.WithType(errors.New("outdated needs the lockfile to check"), berrors.ErrorTypeValidation)
+	}
+	bf, err := s.parse(req.Berksfile)
+	if err != nil {
+		return nil, err
+	}
+	sources, err := s.sources(bf)
+	if err != nil {
+		return nil, err
+	}
+	manager := source.NewManager()
+	for _, src := range sources {
+		manager.AddSource(src)
+	}
+
+	checker := outdated.New(lockFile, manager)
+	cookbooks, err := checker.Check(ctx, req.Cookbooks)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for outdated cookbooks: %w", err)
+	}
+	migrations, err := checker.Migrations(ctx, req.Cookbooks)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for deprecated cookbooks: %w", err)
+	}
+	if cookbooks == nil {
+		cookbooks = []outdated.Cookbook{}
+	}
+	return OutdatedResponse{Outdated: cookbooks, Migrations: migrations}, nil
+}
+
+// health reports the uptime and the number of sources kept
+func (s *Server) health(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"status":  "ok",
+		"uptime":  time.Since(s.started).Round(time.Second).String(),
+		"sources": s.pool.size(),
+	})
+}
+
+func (s *Server) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	if err := s.options.Metrics.WritePrometheus(w); err != nil {
+		log.Warnf("Failed to write metrics: %v", err)
+	}
+}
+
+// parse parses the Berksfile of a request. Cookbooks on the client's disk
+// cannot be read by the service, so path sources and the metadata
+// directive are rejected, as are sources the service does not trust. The
+// Berksfile reads neither the service's environment nor its files, so
+// conditionals on them are skipped and interpolations of them rejected.
+func (s *Server) parse(content string) (*berksfile.Berksfile, error) {
+	s.parseMu.Lock()
+	bf, err := berksfile.ParseWithOptions(content, berksfile.ParseOptions{})
+	s.parseMu.Unlock()
+	if err != nil {
+		return nil, berrors.WithType(fmt.Errorf("failed to parse Berksfile: %w", err), berrors.ErrorTypeParsing)
+	}
+
+	if bf.HasMetadata {
+		return nil, berrors.WithType(errors.New("the metadata directive is not supported, as the service cannot read the cookbook"), berrors.ErrorTypeValidation)
+	}
+	for _, loc := range bf.Sources {
+		if err := s.checkSource(loc); err != nil {
+			return nil, berrors.WithType(fmt.Errorf("source %s: %w", loc.URL, err), berrors.ErrorTypeValidation)
+		}
+	}
+	for _, cookbook := range slices.Concat(bf.Cookbooks, bf.Overrides) {
+		if err := s.checkSource(cookbook.Source); err != nil {
+			return nil, berrors.WithType(fmt.Errorf("cookbook %s: %w", cookbook.Name, err), berrors.ErrorTypeValidation)
+		}
+	}
+	return bf, nil
+}
+
+// checkSource returns an error if a request may not use a source: one on
+// the client's disk, one whose interpolations, client_key or credential
+// references would have the service read its own secrets, or one other
+// than a Supermarket, which the service reaches with its own credentials,
+// whose URL is not trusted
+func (s *Server) checkSource(loc *berkshelf.SourceLocation) error {
+	if loc == nil || (loc.Type == "" && loc.URL == "") {
+		return nil
+	}
+	if loc.Type == "path" || strings.HasPrefix(loc.URL, "file://") {
+		return errors.New("local sources are not supported")
+	}
+	if slices.ContainsFunc([]string{loc.URL, loc.Ref, loc.Path}, interpolated) {
+		return errors.New("interpolation is not supported")
+	}
+	if _, ok := loc.Options["client_key"]; ok || strings.Contains(loc.URL, "client_key=") {
+		return errors.New("client_key is not supported; use a profile of the service's config")
+	}
+	for name, value := range loc.Options {
+		value, ok := value.(string)
+		if ok && credentials.IsReference(value) {
+			return fmt.Errorf("option %s refers to a credential, which is not supported", name)
+		}
+		if ok && interpolated(value) {
+			return fmt.Errorf("option %s is interpolated, which is not supported", name)
+		}
+	}
+	supermarket := (loc.Type == "" || loc.Type == "supermarket") &&
+		(loc.URL == "" || strings.HasPrefix(loc.URL, "https://") || strings.HasPrefix(loc.URL, "http://"))
+	if !supermarket && !s.trusted[strings.TrimSuffix(loc.URL, "/")] {
+		return fmt.Errorf("%s source %s is not one of the service's trusted sources", cmp.Or(loc.Type, "supermarket"), loc.URL)
+	}
+	return nil
+}
+
+// interpolated reports whether a value holds a #{...} sequence the
+// Berksfile was not allowed to interpolate
+func interpolated(value string) bool {
+	return strings.Contains(value, "#{")
+}
+
+// requestLockFile returns the lock file of a request, or nil if it has none
+func requestLockFile(req *Request) (*lockfile.LockFile, error) {
+	if len(req.LockFile) == 0 || string(req.LockFile) == "null" {
+		return nil, nil
+	}
+	lockFile, err := lockfile.FromJSON(req.LockFile)
+	if err != nil {
+		return nil, berrors.WithType(fmt.Errorf("invalid lockfile: %w", err), berrors.ErrorTypeValidation)
+	}
+	return lockFile, nil
+}
+
+// sources returns the default sources of a Berksfile from the pool, or the
+// public Supermarket if it declares none
+func (s *Server) sources(bf *berksfile.Berksfile) ([]source.CookbookSource, error) {
+	if len(bf.Sources) == 0 {
+		src, err := s.pool.CreateFromURL(source.PUBLIC_SUPERMARKET)
+		if err != nil {
+			return nil, err
+		}
+		return []source.CookbookSource{src}, nil
+	}
+	sources := make([]source.CookbookSource, 0, len(bf.Sources))
+	for _, loc := range bf.Sources {
+		src, err := s.pool.CreateSource(loc)
+		if err != nil {
+			return nil, berrors.WithType(fmt.Errorf("creating source from %s: %w", loc.URL, err), berrors.ErrorTypeConfiguration)
+		}
+		sources = append(sources, src)
+	}
+	return sources, nil
+}
+
+// resolve resolves the cookbooks of the request's Berksfile in the selected
+// groups, as 'berks install' does, with the pinned overrides of its lock file
+func (s *Server) resolve(ctx context.Context, req *Request) (*resolver.Resolution, *berksfile.Berksfile, error) {
+	bf, err := s.parse(req.Berksfile)
+	if err != nil {
+		return nil, nil, err
+	}
+	existing, err := requestLockFile(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	sources, err := s.sources(bf)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cookbooks := berksfile.FilterCookbooksByGroup(bf.Cookbooks, req.Only, req.Except)
+	cookbooks = berksfile.ApplyGroupSources(cookbooks, s.options.GroupSources)
+	requirements := make([]*resolver.Requirement, 0, len(cookbooks))
+	for _, cookbook := range cookbooks {
+		req := resolver.NewRequirement(cookbook.Name, cookbook.Constraint)
+		if loc := cookbook.Source; loc != nil && loc.Type != "" && (loc.URL != "" || loc.Path != "") {
+			req.Source = loc
+		}
+		req.MetadataName = cookbook.MetadataName
+		requirements = append(requirements, req)
+	}
+
+	var overrides []*resolver.Requirement
+	if existing != nil {
+		if overrides, err = existing.OverrideRequirements(); err != nil {
+			return nil, nil, berrors.WithType(fmt.Errorf("invalid override in lockfile: %w", err), berrors.ErrorTypeValidation)
+		}
+		for _, override := range overrides {
+			if err := s.checkSource(override.Source); err != nil {
+				return nil, nil, berrors.WithType(fmt.Errorf("lockfile override of %s: %w", override.Name, err), berrors.ErrorTypeValidation)
+			}
+		}
+	}
+	for _, override := range bf.Overrides {
+		req := resolver.NewRequirement(override.Name, override.Constraint)
+		if loc := override.Source; loc != nil && loc.Type != "" && (loc.URL != "" || loc.Path != "") {
+			req.Source = loc
+		}
+		overrides = append(overrides, req)
+	}
+
+	r := resolver.NewResolver(sources)
+	r.SetSourceFactory(s.pool)
+	r.Override(overrides...)
+	r.Ignore(bf.Ignored...)
+	for _, route := range s.options.SourceRoutes {
+		src, err := s.pool.CreateFromURL(route.URL)
+		if err != nil {
+			return nil, nil, berrors.WithType(fmt.Errorf("invalid source route to %s: %w", route.URL, err), berrors.ErrorTypeConfiguration)
+		}
+		r.Route(resolver.SourceRoute{Pattern: route.Pattern, Source: src})
+	}
+
+	resolveCtx, cancel := berrors.WithPhaseTimeout(ctx, berrors.PhaseResolve, s.options.ResolveTimeout)
+	defer cancel()
+	resolution, err := r.Resolve(resolveCtx, requirements)
+	if err != nil {
+		return nil, nil, berrors.WithType(fmt.Errorf("failed to resolve dependencies: %w", berrors.PhaseError(resolveCtx, err)), berrors.ErrorTypeResolution)
+	}
+	if resolution.HasErrors() {
+		err := fmt.Errorf("%w: %w", resolution.Failure(), errors.Join(resolution.Errors...))
+		return nil, nil, berrors.WithType(berrors.PhaseError(resolveCtx, err), berrors.ErrorTypeResolution)
+	}
+	return resolution, bf, nil
+}
+
+// writeError writes err with the HTTP status of its type
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		status = http.StatusGatewayTimeout
+	default:
+		switch berrors.TypeOf(err) {
+		case berrors.ErrorTypeParsing, berrors.ErrorTypeValidation:
+			status = http.StatusBadRequest
+		case berrors.ErrorTypeResolution:
+			status = http.StatusUnprocessableEntity
+		case berrors.ErrorTypeNetwork, berrors.ErrorTypeAuthentication:
+			status = http.StatusBadGateway
+		}
+	}
+	writeJSON(w, status, ErrorResponse{Error: berrors.Summarize(err)})
+}
+
+// writeJSON writes v as the JSON body of a response with status
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		log.Debugf("Failed to write response: %v", err)
+	}
+}
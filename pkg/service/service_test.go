@@ -0,0 +1,309 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	"github.com/bdwyertech/go-berkshelf/pkg/lockfile"
+	"github.com/bdwyertech/go-berkshelf/pkg/source"
+)
+
+// supermarket serves app 1.0.0, which depends on base, and base 2.0.0 and
+// 2.1.0, counting the cookbook lookups
+func supermarket(t *testing.T, lookups *atomic.Int32) *httptest.Server {
+	t.Helper()
+	versions := map[string]map[string]map[string]string{
+		"app":  {"1.0.0": {"base": ">= 2.0"}},
+		"base": {"2.0.0": {}, "2.1.0": {}},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var name, version string
+		path := strings.TrimPrefix(r.URL.Path, "/api/v1/cookbooks/")
+		if n, v, ok := strings.Cut(path, "/versions/"); ok {
+			name, version = n, v
+		} else {
+			name = path
+		}
+		cookbook, ok := versions[name]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if version == "" {
+			lookups.Add(1)
+			// The Supermarket lists the newest version first
+			var urls []string
+			for _, v := range slices.Backward(slices.Sorted(maps.Keys(cookbook))) {
+				urls = append(urls, fmt.Sprintf("http://%s/api/v1/cookbooks/%s/versions/%s", r.Host, name, v))
+			}
+			json.NewEncoder(w).Encode(map[string]any{"name": name, "versions": urls})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"version":      version,
+			"file":         fmt.Sprintf("http://%s/%s-%s.tgz", r.Host, name, version),
+			"dependencies": cookbook[version],
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// post sends req to an endpoint of s and decodes the response into v
+func post(t *testing.T, s *Server, endpoint string, req Request, v any) int {
+	t.Helper()
+	body, err := json.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body)))
+	if err := json.Unmarshal(recorder.Body.Bytes(), v); err != nil {
+		t.Fatalf("%s response %s: %v", endpoint, recorder.Body, err)
+	}
+	return recorder.Code
+}
+
+func TestResolve(t *testing.T) {
+	var lookups atomic.Int32
+	market := supermarket(t, &lookups)
+	s := New(Options{})
+	req := Request{Berksfile: fmt.Sprintf("source %q\ncookbook 'app', '~> 1.0'\n", market.URL)}
+
+	var got ResolveResponse
+	if status := post(t, s, "/v1/resolve", req, &got); status != http.StatusOK {
+		t.Fatalf("status = %d, response %+v", status, got)
+	}
+	if len(got.Cookbooks) != 2 || got.Cookbooks[0].Name != "app" || got.Cookbooks[1].Version != "2.1.0" ||
+		got.Cookbooks[0].Dependencies["base"] != "2.1.0" || got.Cookbooks[0].Source != market.URL {
+		t.Errorf("cookbooks = %+v, want app 1.0.0 and base 2.1.0", got.Cookbooks)
+	}
+
+	// The second request is answered from the sources kept in memory
+	before := lookups.Load()
+	if status := post(t, s, "/v1/resolve", req, &got); status != http.StatusOK {
+		t.Fatalf("second status = %d", status)
+	}
+	if after := lookups.Load(); after != before {
+		t.Errorf("cookbook lookups went from %d to %d, want the cached sources reused", before, after)
+	}
+}
+
+func TestLock(t *testing.T) {
+	market := supermarket(t, new(atomic.Int32))
+	s := New(Options{})
+
+	previous := lockfile.NewLockFile()
+	previous.Overrides = map[string]*lockfile.Override{"base": {Version: "2.0.0", Reason: "CVE fix pending"}}
+	data, err := previous.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := Request{Berksfile: fmt.Sprintf("source %q\ncookbook 'app', '~> 1.0'\n", market.URL), LockFile: data}
+
+	var got LockResponse
+	if status := post(t, s, "/v1/lock", req, &got); status != http.StatusOK {
+		t.Fatalf("status = %d, response %+v", status, got)
+	}
+	lockFile, err := lockfile.FromJSON(got.LockFile)
+	if err != nil {
+		t.Fatalf("lockfile %s: %v", got.LockFile, err)
+	}
+	// The override in the lock file pins base and is carried over
+	if base, _, ok := lockFile.GetCookbook("base"); !ok || base.Version != "2.0.0" || lockFile.Overrides["base"] == nil {
+		t.Errorf("lock file = %s, want base overridden to 2.0.0", got.LockFile)
+	}
+	if !strings.Contains(got.RubyLockFile, "DEPENDENCIES\n  app (~> 1.0)") {
+		t.Errorf("Berksfile.lock = %q, want app among the dependencies", got.RubyLockFile)
+	}
+}
+
+func TestOutdated(t *testing.T) {
+	market := supermarket(t, new(atomic.Int32))
+	s := New(Options{})
+
+	locked := lockfile.NewLockFile()
+	locked.Sources[market.URL] = &lockfile.SourceLock{
+		Type:      "supermarket",
+		URL:       market.URL,
+		Cookbooks: map[string]*lockfile.CookbookLock{"base": {Version: "2.0.0"}},
+	}
+	data, err := locked.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got OutdatedResponse
+	req := Request{Berksfile: fmt.Sprintf("source %q\ncookbook 'base'\n", market.URL), LockFile: data}
+	if status := post(t, s, "/v1/outdated", req, &got); status != http.StatusOK {
+		t.Fatalf("status = %d, response %+v", status, got)
+	}
+	if len(got.Outdated) != 1 || got.Outdated[0].Name != "base" || got.Outdated[0].LatestVersion != "2.1.0" {
+		t.Errorf("outdated = %+v, want base 2.1.0", got.Outdated)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	market := supermarket(t, new(atomic.Int32))
+	s := New(Options{})
+	tests := []struct {
+		name     string
+		endpoint string
+		req      Request
+		status   int
+		want     string
+	}{
+		{"path source", "/v1/resolve", Request{Berksfile: "cookbook 'app', path: '../app'\n"}, http.StatusBadRequest, "local source"},
+		{"metadata", "/v1/lock", Request{Berksfile: "metadata\n"}, http.StatusBadRequest, "metadata directive"},
+		{"parse error", "/v1/resolve", Request{Berksfile: "cookbook 'app',,\n"}, http.StatusBadRequest, "failed to parse Berksfile"},
+		{"unresolvable", "/v1/resolve", Request{Berksfile: fmt.Sprintf("source %q\ncookbook 'missing'\n", market.URL)}, http.StatusUnprocessableEntity, "missing"},
+		{"no lockfile", "/v1/outdated", Request{Berksfile: "cookbook 'app'\n"}, http.StatusBadRequest, "needs the lockfile"},
+		{"chef server", "/v1/resolve", Request{Berksfile: "source chef_server: 'https://attacker.example.com'\ncookbook 'app'\n"}, http.StatusBadRequest, "trusted sources"},
+		{"client key", "/v1/resolve", Request{Berksfile: "source chef_server: 'https://chef.example.com', client_name: 'ci', client_key: '/etc/chef/client.pem'\ncookbook 'app'\n"}, http.StatusBadRequest, "client_key"},
+		{"client key in URL", "/v1/resolve", Request{Berksfile: "source 'chef_server://chef.example.com?client_key=/etc/chef/client.pem'\ncookbook 'app'\n"}, http.StatusBadRequest, "client_key"},
+		{"git", "/v1/lock", Request{Berksfile: "cookbook 'app', git: 'https://attacker.example.com/app.git'\n"}, http.StatusBadRequest, "trusted sources"},
+		{"s3", "/v1/resolve", Request{Berksfile: "source s3: 's3://bucket/cookbooks'\ncookbook 'app'\n"}, http.StatusBadRequest, "trusted sources"},
+		{"oci", "/v1/resolve", Request{Berksfile: "cookbook 'app', oci: 'oci://registry.example.com/app'\n"}, http.StatusBadRequest, "trusted sources"},
+		{"credential", "/v1/resolve", Request{Berksfile: "source supermarket: 'https://attacker.example.com', api_key: 'env:HOME'\ncookbook 'app'\n"}, http.StatusBadRequest, "credential"},
+		{"lockfile override", "/v1/resolve", Request{Berksfile: "cookbook 'app'\n", LockFile: json.RawMessage(`{"overrides": {"app": {"version": "1.0.0", "source": {"type": "git", "url": "https://attacker.example.com/app.git"}}}}`)}, http.StatusBadRequest, "trusted sources"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got ErrorResponse
+			if status := post(t, s, tt.endpoint, tt.req, &got); status != tt.status || !strings.Contains(got.Error.Message, tt.want) {
+				t.Errorf("status = %d, error %+v, want %d and %q", status, got.Error, tt.status, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequestsReadNoSecrets(t *testing.T) {
+	var lookups atomic.Int32
+	market := supermarket(t, &lookups)
+	t.Setenv("BERKS_SERVICE_SECRET", "s3cr3t")
+	t.Setenv("BERKS_SERVICE_COOKBOOK", "missing")
+	s := New(Options{})
+
+	var failed ErrorResponse
+	req := Request{Berksfile: fmt.Sprintf("source \"%s/#{ENV['BERKS_SERVICE_SECRET']}\"\ncookbook 'app'\n", market.URL)}
+	if status := post(t, s, "/v1/resolve", req, &failed); status != http.StatusBadRequest || !strings.Contains(failed.Error.Message, "interpolation") {
+		t.Errorf("status = %d, error %+v, want 400 rejecting the interpolation", status, failed.Error)
+	}
+	if lookups.Load() != 0 {
+		t.Error("the interpolated source was queried")
+	}
+
+	// Conditionals on the service's environment and files are skipped, so
+	// they tell nothing about them
+	var got ResolveResponse
+	req = Request{Berksfile: fmt.Sprintf("source %q\ncookbook 'app'\ncookbook 'missing' if ENV['BERKS_SERVICE_COOKBOOK']\ncookbook 'missing' if File.exist?('/')\n", market.URL)}
+	if status := post(t, s, "/v1/resolve", req, &got); status != http.StatusOK {
+		t.Fatalf("status = %d, want 200", status)
+	}
+	if len(got.Cookbooks) != 2 {
+		t.Errorf("resolved %+v, want app and base without missing", got.Cookbooks)
+	}
+}
+
+func TestTrustedSources(t *testing.T) {
+	s := New(Options{
+		TrustedSources: []string{"https://chef.example.com/organizations/ci/"},
+		GroupSources:   map[string]string{"internal": "git@git.example.com:cookbooks.git"},
+	})
+	for _, loc := range []*berkshelf.SourceLocation{
+		{Type: "supermarket", URL: "https://supermarket.example.com"},
+		{Type: "chef_server", URL: "https://chef.example.com/organizations/ci"},
+		{Type: "git", URL: "git@git.example.com:cookbooks.git"},
+	} {
+		if err := s.checkSource(loc); err != nil {
+			t.Errorf("checkSource(%s %s) = %v, want it allowed", loc.Type, loc.URL, err)
+		}
+	}
+	untrusted := &berkshelf.SourceLocation{Type: "chef_server", URL: "https://chef.example.com/organizations/other"}
+	if err := s.checkSource(untrusted); err == nil {
+		t.Errorf("checkSource(%s) allowed a source the service does not trust", untrusted.URL)
+	}
+}
+
+func TestToken(t *testing.T) {
+	s := New(Options{Token: "s3cret"})
+
+	var got ErrorResponse
+	if status := post(t, s, "/v1/resolve", Request{}, &got); status != http.StatusUnauthorized {
+		t.Errorf("status without a token = %d, want 401", status)
+	}
+
+	// The health check is open to load balancers
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if recorder.Code != http.StatusOK {
+		t.Errorf("health status = %d, want 200", recorder.Code)
+	}
+
+	recorder = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/resolve", strings.NewReader(`{"berksfile": ""}`))
+	req.Header.Set("Authorization", "Bearer s3cret")
+	s.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusOK {
+		t.Errorf("status with the token = %d, body %s", recorder.Code, recorder.Body)
+	}
+}
+
+func TestPoolRefresh(t *testing.T) {
+	p := newPool(source.NewFactory(), time.Hour)
+	first, err := p.CreateFromURL("https://supermarket.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := p.CreateFromURL("https://supermarket.example.com"); again != first {
+		t.Error("CreateFromURL() created the source again, want it reused")
+	}
+
+	p.refresh = time.Nanosecond
+	time.Sleep(time.Millisecond)
+	if again, _ := p.CreateFromURL("https://supermarket.example.com"); again == first {
+		t.Error("CreateFromURL() reused a source due to be refreshed")
+	}
+}
+
+func TestPoolEviction(t *testing.T) {
+	p := newPool(source.NewFactory(), time.Hour)
+	p.max = 2
+	first, _ := p.CreateFromURL("https://one.example.com")
+	p.CreateFromURL("https://two.example.com")
+	// Using the first source makes the second the least recently used
+	p.CreateFromURL("https://one.example.com")
+	p.CreateFromURL("https://three.example.com")
+	if size := p.size(); size != 2 {
+		t.Errorf("size() = %d, want 2", size)
+	}
+	if _, ok := p.sources["url https://two.example.com"]; ok {
+		t.Error("the least recently used source was kept")
+	}
+	if again, _ := p.CreateFromURL("https://one.example.com"); again != first {
+		t.Error("CreateFromURL() created a recently used source again")
+	}
+}
+
+func TestPoolGitSources(t *testing.T) {
+	p := newPool(source.NewFactory(), 0)
+	location := &berkshelf.SourceLocation{Type: "git", URL: "https://git.example.com/app.git"}
+	first, err := p.CreateSource(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Git sources record the commit they check out, so requests never share one
+	if again, _ := p.CreateSource(location); again == first || p.size() != 0 {
+		t.Error("CreateSource() pooled a git source")
+	}
+}
@@ -0,0 +1,53 @@
+package signing
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Policy requires the artifacts downloaded from the sources it covers to be
+// signed by a trusted key
+type Policy struct {
+	verifier *Verifier
+	store    *Store
+	sources  []string
+}
+
+// NewPolicy returns a policy verifying signatures from store with verifier.
+// It covers the artifacts of the source URLs given, or of every source when
+// none are.
+func NewPolicy(verifier *Verifier, store *Store, sources ...string) *Policy {
+	normalized := make([]string, len(sources))
+	for i, source := range sources {
+		normalized[i] = strings.TrimSuffix(source, "/")
+	}
+	return &Policy{verifier: verifier, store: store, sources: normalized}
+}
+
+// Covers reports whether the policy applies to the source at url
+func (p *Policy) Covers(url string) bool {
+	if len(p.sources) == 0 {
+		return true
+	}
+	url = strings.TrimSuffix(url, "/")
+	for _, source := range p.sources {
+		if strings.EqualFold(source, url) {
+			return true
+		}
+	}
+	return false
+}
+
+// VerifyArtifact checks that the stored signature of a cookbook version signs
+// the hex SHA-256 of its artifact with a trusted key
+func (p *Policy) VerifyArtifact(ctx context.Context, name, version, sha256Hex string) error {
+	signature, err := p.store.Get(ctx, name, version)
+	if err != nil {
+		return err
+	}
+	if err := p.verifier.VerifyDigest(sha256Hex, signature); err != nil {
+		return fmt.Errorf("%s %s: %w", name, version, err)
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+// Package signing signs cookbook artifacts and lock files with a key pair,
+// and verifies the signatures against trusted public keys, in the manner of
+// cosign's key-pair signing. A signature covers the SHA-256 digest of the
+// signed file, so it can be checked against a digest computed while the file
+// is downloaded.
+package signing
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+
+	berrors "github.com/bdwyertech/go-berkshelf/pkg/errors"
+)
+
+// Extension is appended to the name of a signed file to name its signature
+const Extension = ".sig"
+
+// Signature is a detached signature of a file's SHA-256 digest
+type Signature struct {
+	// KeyID identifies the signing key: the hex SHA-256 of its public key
+	KeyID string `json:"key_id"`
+	// Digest is the signed digest, as sha256:HEX
+	Digest string `json:"digest"`
+	// Signature signs the raw bytes of the digest
+	Signature []byte `json:"signature"`
+}
+
+// ErrUntrustedKey is returned for signatures by a key the verifier does not trust
+var ErrUntrustedKey = berrors.WithType(errors.New("signed by an untrusted key"), berrors.ErrorTypeIntegrity)
+
+// GenerateKey returns a new Ed25519 key pair, PEM encoded as a PKCS #8
+// private key and a PKIX public key
+func GenerateKey() (privateKey, publicKey []byte, err error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, nil, err
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER}),
+		pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}), nil
+}
+
+// Signer signs digests with a private key
+type Signer struct {
+	key   crypto.Signer
+	keyID string
+}
+
+// NewSigner returns a signer for a PEM encoded PKCS #8 Ed25519 or ECDSA
+// private key
+func NewSigner(privateKey []byte) (*Signer, error) {
+	block, _ := pem.Decode(privateKey)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM private key found")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing private key: %w", err)
+	}
+	var key crypto.Signer
+	switch k := parsed.(type) {
+	case ed25519.PrivateKey:
+		key = k
+	case *ecdsa.PrivateKey:
+		key = k
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T, use Ed25519 or ECDSA", parsed)
+	}
+	keyID, err := KeyID(key.Public())
+	if err != nil {
+		return nil, err
+	}
+	return &Signer{key: key, keyID: keyID}, nil
+}
+
+// KeyID returns the ID of the signer's key
+func (s *Signer) KeyID() string {
+	return s.keyID
+}
+
+// Sign signs the SHA-256 digest of data
+func (s *Signer) Sign(data []byte) (*Signature, error) {
+	sum := sha256.Sum256(data)
+	return s.SignDigest(hex.EncodeToString(sum[:]))
+}
+
+// SignDigest signs a hex SHA-256 digest
+func (s *Signer) SignDigest(sha256Hex string) (*Signature, error) {
+	sum, err := decodeDigest(sha256Hex)
+	if err != nil {
+		return nil, err
+	}
+	var signature []byte
+	switch key := s.key.(type) {
+	case ed25519.PrivateKey:
+		signature = ed25519.Sign(key, sum)
+	case *ecdsa.PrivateKey:
+		if signature, err = ecdsa.SignASN1(rand.Reader, key, sum); err != nil {
+			return nil, err
+		}
+	}
+	return &Signature{KeyID: s.keyID, Digest: "sha256:" + strings.ToLower(sha256Hex), Signature: signature}, nil
+}
+
+// Verifier checks signatures against a set of trusted public keys
+type Verifier struct {
+	keys map[string]crypto.PublicKey
+}
+
+// NewVerifier returns a verifier trusting the PEM encoded PKIX public keys
+func NewVerifier(publicKeys ...[]byte) (*Verifier, error) {
+	v := &Verifier{keys: make(map[string]crypto.PublicKey)}
+	for _, data := range publicKeys {
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("no PEM public key found")
+		}
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parsing public key: %w", err)
+		}
+		switch key.(type) {
+		case ed25519.PublicKey, *ecdsa.PublicKey:
+		default:
+			return nil, fmt.Errorf("unsupported public key type %T, use Ed25519 or ECDSA", key)
+		}
+		id, err := KeyID(key)
+		if err != nil {
+			return nil, err
+		}
+		v.keys[id] = key
+	}
+	if len(v.keys) == 0 {
+		return nil, fmt.Errorf("no trusted public keys")
+	}
+	return v, nil
+}
+
+// Verify checks that signature signs the SHA-256 digest of data
+func (v *Verifier) Verify(data []byte, signature *Signature) error {
+	sum := sha256.Sum256(data)
+	return v.VerifyDigest(hex.EncodeToString(sum[:]), signature)
+}
+
+// VerifyDigest checks that signature signs the hex SHA-256 digest with a
+// trusted key. Failures are integrity errors.
+func (v *Verifier) VerifyDigest(sha256Hex string, signature *Signature) error {
+	if !strings.EqualFold(signature.Digest, "sha256:"+sha256Hex) {
+		return berrors.WithType(fmt.Errorf("signature is for %s, not sha256:%s", signature.Digest, sha256Hex), berrors.ErrorTypeIntegrity)
+	}
+	key, ok := v.keys[signature.KeyID]
+	if !ok {
+		return fmt.Errorf("%w %s", ErrUntrustedKey, signature.KeyID)
+	}
+	sum, err := decodeDigest(sha256Hex)
+	if err != nil {
+		return err
+	}
+	var valid bool
+	switch k := key.(type) {
+	case ed25519.PublicKey:
+		valid = ed25519.Verify(k, sum, signature.Signature)
+	case *ecdsa.PublicKey:
+		valid = ecdsa.VerifyASN1(k, sum, signature.Signature)
+	}
+	if !valid {
+		return berrors.WithType(fmt.Errorf("invalid signature by key %s", signature.KeyID), berrors.ErrorTypeIntegrity)
+	}
+	return nil
+}
+
+// KeyID returns the hex SHA-256 of the PKIX encoding of a public key
+func KeyID(key crypto.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// decodeDigest decodes a hex SHA-256 digest
+func decodeDigest(sha256Hex string) ([]byte, error) {
+	sum, err := hex.DecodeString(sha256Hex)
+	if err != nil || len(sum) != sha256.Size {
+		return nil, fmt.Errorf("invalid SHA-256 digest %q", sha256Hex)
+	}
+	return sum, nil
+}
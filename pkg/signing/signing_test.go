@@ -0,0 +1,209 @@
+package signing
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	berrors "github.com/bdwyertech/go-berkshelf/pkg/errors"
+)
+
+func newKeyPair(t *testing.T) (*Signer, *Verifier) {
+	t.Helper()
+	privateKey, publicKey, err := GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	signer, err := NewSigner(privateKey)
+	if err != nil {
+		t.Fatalf("NewSigner() error = %v", err)
+	}
+	verifier, err := NewVerifier(publicKey)
+	if err != nil {
+		t.Fatalf("NewVerifier() error = %v", err)
+	}
+	return signer, verifier
+}
+
+func digestOf(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestSignVerify(t *testing.T) {
+	signer, verifier := newKeyPair(t)
+	data := []byte(`{"cookbooks":{}}`)
+
+	signature, err := signer.Sign(data)
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	if signature.KeyID != signer.KeyID() || signature.Digest != "sha256:"+digestOf(string(data)) {
+		t.Errorf("signature = %+v", signature)
+	}
+	if err := verifier.Verify(data, signature); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+
+	err = verifier.Verify([]byte(`{"cookbooks":{"evil":{}}}`), signature)
+	if err == nil || berrors.TypeOf(err) != berrors.ErrorTypeIntegrity {
+		t.Errorf("Verify() of tampered data error = %v, want an integrity error", err)
+	}
+
+	forged := *signature
+	forged.Signature = append([]byte(nil), signature.Signature...)
+	forged.Signature[0] ^= 0xff
+	if err := verifier.Verify(data, &forged); err == nil {
+		t.Error("Verify() accepted a forged signature")
+	}
+}
+
+func TestVerify_UntrustedKey(t *testing.T) {
+	signer, _ := newKeyPair(t)
+	_, verifier := newKeyPair(t)
+
+	signature, err := signer.SignDigest(digestOf("tarball"))
+	if err != nil {
+		t.Fatalf("SignDigest() error = %v", err)
+	}
+	if err := verifier.VerifyDigest(digestOf("tarball"), signature); !errors.Is(err, ErrUntrustedKey) {
+		t.Errorf("VerifyDigest() error = %v, want ErrUntrustedKey", err)
+	}
+}
+
+func TestSignVerify_ECDSA(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateDER, _ := x509.MarshalPKCS8PrivateKey(key)
+	publicDER, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+
+	signer, err := NewSigner(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER}))
+	if err != nil {
+		t.Fatalf("NewSigner() error = %v", err)
+	}
+	verifier, err := NewVerifier(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}))
+	if err != nil {
+		t.Fatalf("NewVerifier() error = %v", err)
+	}
+	signature, err := signer.SignDigest(digestOf("tarball"))
+	if err != nil {
+		t.Fatalf("SignDigest() error = %v", err)
+	}
+	if err := verifier.VerifyDigest(digestOf("tarball"), signature); err != nil {
+		t.Errorf("VerifyDigest() error = %v", err)
+	}
+}
+
+func TestNewSigner_Invalid(t *testing.T) {
+	if _, err := NewSigner([]byte("not a key")); err == nil {
+		t.Error("NewSigner() accepted a non-PEM key")
+	}
+	if _, err := NewVerifier(); err == nil {
+		t.Error("NewVerifier() accepted no keys")
+	}
+	signer, _ := newKeyPair(t)
+	if _, err := signer.SignDigest("abc"); err == nil {
+		t.Error("SignDigest() accepted an invalid digest")
+	}
+}
+
+func TestStore_Directory(t *testing.T) {
+	signer, verifier := newKeyPair(t)
+	store := NewStore(t.TempDir(), "")
+	policy := NewPolicy(verifier, store)
+	ctx := context.Background()
+
+	if err := policy.VerifyArtifact(ctx, "nginx", "1.0.0", digestOf("tarball")); !errors.Is(err, ErrNotSigned) {
+		t.Fatalf("VerifyArtifact() of an unsigned artifact error = %v, want ErrNotSigned", err)
+	}
+
+	signature, _ := signer.SignDigest(digestOf("tarball"))
+	if err := store.Put(ctx, "nginx", "1.0.0", signature); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if err := policy.VerifyArtifact(ctx, "nginx", "1.0.0", digestOf("tarball")); err != nil {
+		t.Errorf("VerifyArtifact() error = %v", err)
+	}
+	if err := policy.VerifyArtifact(ctx, "nginx", "1.0.0", digestOf("tampered")); err == nil {
+		t.Error("VerifyArtifact() accepted a tampered artifact")
+	}
+}
+
+func TestStore_HTTP(t *testing.T) {
+	var mu sync.Mutex
+	objects := make(map[string][]byte)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case http.MethodPut:
+			objects[r.URL.Path], _ = io.ReadAll(r.Body)
+			w.WriteHeader(http.StatusCreated)
+		case http.MethodGet:
+			data, ok := objects[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write(data)
+		}
+	}))
+	defer server.Close()
+
+	signer, _ := newKeyPair(t)
+	signature, _ := signer.SignDigest(digestOf("tarball"))
+	ctx := context.Background()
+
+	err := NewStore(server.URL, "wrong").Put(ctx, "nginx", "1.0.0", signature)
+	if berrors.TypeOf(err) != berrors.ErrorTypeAuthentication {
+		t.Errorf("Put() with a bad token error = %v, want an authentication error", err)
+	}
+
+	store := NewStore(server.URL+"/signatures/", "secret")
+	if _, err := store.Get(ctx, "nginx", "1.0.0"); !errors.Is(err, ErrNotSigned) {
+		t.Errorf("Get() error = %v, want ErrNotSigned", err)
+	}
+	if err := store.Put(ctx, "nginx", "1.0.0", signature); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if _, ok := objects["/signatures/nginx/1.0.0.sig"]; !ok {
+		t.Errorf("stored %v, want /signatures/nginx/1.0.0.sig", objects)
+	}
+	got, err := store.Get(ctx, "nginx", "1.0.0")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got.Digest != signature.Digest || got.KeyID != signature.KeyID {
+		t.Errorf("Get() = %+v, want %+v", got, signature)
+	}
+}
+
+func TestPolicy_Covers(t *testing.T) {
+	policy := NewPolicy(nil, nil, "https://supermarket.example.com/")
+	if !policy.Covers("https://supermarket.example.com") {
+		t.Error("policy does not cover its source")
+	}
+	if policy.Covers("https://supermarket.chef.io") {
+		t.Error("policy covers another source")
+	}
+	if !NewPolicy(nil, nil).Covers("https://supermarket.chef.io") {
+		t.Error("policy without sources does not cover every source")
+	}
+}
@@ -0,0 +1,156 @@
+package signing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	berrors "github.com/bdwyertech/go-berkshelf/pkg/errors"
+)
+
+// ErrNotSigned is returned for artifacts the store has no signature for
+var ErrNotSigned = berrors.WithType(errors.New("no signature found"), berrors.ErrorTypeIntegrity)
+
+// Store keeps the signatures of cookbook artifacts at
+// <location>/<name>/<version>.sig, in a local directory or on an HTTP server
+// that accepts PUT, such as a generic Artifactory repository
+type Store struct {
+	location   string
+	token      string
+	httpClient *http.Client
+}
+
+// NewStore returns the signature store at location, a directory or an
+// http(s) URL. token, if set, is sent as a bearer token.
+func NewStore(location, token string) *Store {
+	return &Store{
+		location:   strings.TrimSuffix(location, "/"),
+		token:      token,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Location returns where the store keeps signatures
+func (s *Store) Location() string {
+	return s.location
+}
+
+func (s *Store) remote() bool {
+	return strings.HasPrefix(s.location, "http://") || strings.HasPrefix(s.location, "https://")
+}
+
+// path returns the location of the signature of a cookbook version
+func (s *Store) path(name, version string) string {
+	if s.remote() {
+		return s.location + "/" + url.PathEscape(name) + "/" + url.PathEscape(version) + Extension
+	}
+	return filepath.Join(s.location, name, version+Extension)
+}
+
+// Get returns the signature of a cookbook version, or ErrNotSigned
+func (s *Store) Get(ctx context.Context, name, version string) (*Signature, error) {
+	location := s.path(name, version)
+	var data []byte
+	if s.remote() {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+		if err != nil {
+			return nil, fmt.Errorf("creating request: %w", err)
+		}
+		s.authorize(req)
+		resp, err := s.httpClient.Do(req)
+		if err != nil {
+			return nil, berrors.WithType(fmt.Errorf("fetching signature %s: %w", location, err), berrors.ErrorTypeNetwork)
+		}
+		defer resp.Body.Close()
+		switch {
+		case resp.StatusCode == http.StatusNotFound:
+			return nil, fmt.Errorf("%w for %s %s", ErrNotSigned, name, version)
+		case resp.StatusCode != http.StatusOK:
+			return nil, fmt.Errorf("fetching signature %s: HTTP %d", location, resp.StatusCode)
+		}
+		if data, err = io.ReadAll(io.LimitReader(resp.Body, 1<<20)); err != nil {
+			return nil, fmt.Errorf("fetching signature %s: %w", location, err)
+		}
+	} else {
+		var err error
+		if data, err = os.ReadFile(location); errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("%w for %s %s", ErrNotSigned, name, version)
+		} else if err != nil {
+			return nil, fmt.Errorf("reading signature: %w", err)
+		}
+	}
+	return Decode(data)
+}
+
+// Put stores the signature of a cookbook version
+func (s *Store) Put(ctx context.Context, name, version string, signature *Signature) error {
+	data, err := Encode(signature)
+	if err != nil {
+		return err
+	}
+	location := s.path(name, version)
+	if !s.remote() {
+		if err := os.MkdirAll(filepath.Dir(location), 0755); err != nil {
+			return fmt.Errorf("creating signature directory: %w", err)
+		}
+		return os.WriteFile(location, data, 0644)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, location, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	s.authorize(req)
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("uploading signature to %s: %w", location, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		err := fmt.Errorf("uploading signature to %s: HTTP %d %s", location, resp.StatusCode, bytes.TrimSpace(message))
+		if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+			return berrors.WithType(err, berrors.ErrorTypeAuthentication)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *Store) authorize(req *http.Request) {
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+}
+
+// Encode returns the JSON encoding of a signature, as kept in .sig files
+func Encode(signature *Signature) ([]byte, error) {
+	data, err := json.MarshalIndent(signature, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// Decode parses a .sig file
+func Decode(data []byte) (*Signature, error) {
+	var signature Signature
+	if err := json.Unmarshal(data, &signature); err != nil {
+		return nil, berrors.WithType(fmt.Errorf("parsing signature: %w", err), berrors.ErrorTypeIntegrity)
+	}
+	if signature.KeyID == "" || signature.Digest == "" || len(signature.Signature) == 0 {
+		return nil, berrors.WithType(fmt.Errorf("parsing signature: missing key_id, digest or signature"), berrors.ErrorTypeIntegrity)
+	}
+	return &signature, nil
+}
@@ -0,0 +1,260 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/goccy/go-json"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+)
+
+// Capabilities records which optional endpoints a Supermarket-compatible
+// source serves. Air-gapped mirrors often serve a subset: a static mirror may
+// only have /universe and tarballs, and Artifactory has no search.
+type Capabilities struct {
+	// CookbooksAPI is the per-cookbook /api/v1/cookbooks endpoints
+	CookbooksAPI bool `json:"cookbooks_api"`
+	// Universe is the /universe dependency index
+	Universe bool `json:"universe"`
+	// Search is /api/v1/search
+	Search bool `json:"search"`
+	// Checksums is set when tarball downloads carry an X-Checksum-Sha256 header
+	Checksums bool `json:"checksums"`
+	// RangeRequests is set when tarball downloads accept byte ranges
+	RangeRequests bool `json:"range_requests"`
+}
+
+// String lists the supported capabilities for log messages
+func (c Capabilities) String() string {
+	var names []string
+	for _, capability := range []struct {
+		name      string
+		supported bool
+	}{
+		{"cookbooks_api", c.CookbooksAPI},
+		{"universe", c.Universe},
+		{"search", c.Search},
+		{"checksums", c.Checksums},
+		{"range_requests", c.RangeRequests},
+	} {
+		if capability.supported {
+			names = append(names, capability.name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ", ")
+}
+
+// allCapabilities is assumed for sources that have not been probed, which
+// keeps the API code paths sources used before probing existed
+var allCapabilities = Capabilities{CookbooksAPI: true, Search: true}
+
+// CapabilityStore persists probed capabilities between runs, keyed by source URL
+type CapabilityStore interface {
+	GetCapabilities(sourceURL string) (Capabilities, bool)
+	PutCapabilities(sourceURL string, capabilities Capabilities) error
+}
+
+// CapabilitySource is implemented by sources whose optional endpoints vary by server
+type CapabilitySource interface {
+	// Capabilities returns the source's capabilities, probing them on first use
+	Capabilities(ctx context.Context) Capabilities
+}
+
+// EnableCapabilityProbe makes the source probe its capabilities before first
+// use and adapt to missing endpoints. Probe results are read from and saved
+// to store when it is non-nil.
+func (s *SupermarketSource) EnableCapabilityProbe(store CapabilityStore) {
+	s.probe = true
+	s.capabilityStore = store
+}
+
+// Capabilities returns the probed capabilities of the source. Sources without
+// probing enabled, and sources that could not be reached, report the
+// cookbook API and search as available.
+func (s *SupermarketSource) Capabilities(ctx context.Context) Capabilities {
+	if !s.probe {
+		return allCapabilities
+	}
+	s.probeOnce.Do(func() {
+		s.capabilities = allCapabilities
+
+		if s.capabilityStore != nil {
+			if cached, ok := s.capabilityStore.GetCapabilities(s.baseURL); ok {
+				log.Debugf("Using cached capabilities for %s: %s", s.baseURL, cached)
+				s.capabilities = cached
+				return
+			}
+		}
+
+		probed, err := s.ProbeCapabilities(ctx)
+		if err != nil {
+			log.Debugf("Capability probe of %s failed, assuming the full API: %v", s.baseURL, err)
+			return
+		}
+		log.Debugf("Probed capabilities for %s: %s", s.baseURL, probed)
+		s.capabilities = probed
+
+		if s.capabilityStore != nil {
+			if err := s.capabilityStore.PutCapabilities(s.baseURL, probed); err != nil {
+				log.Debugf("Failed to cache capabilities for %s: %v", s.baseURL, err)
+			}
+		}
+	})
+	return s.capabilities
+}
+
+// ProbeCapabilities queries the source's optional endpoints. Checksum and
+// range support are read from a HEAD of one sample tarball. An error is
+// returned if the source cannot be reached or answers with a server error,
+// since that says nothing about which endpoints exist.
+func (s *SupermarketSource) ProbeCapabilities(ctx context.Context) (Capabilities, error) {
+	var caps Capabilities
+	var err error
+
+	if caps.Universe, err = s.endpointExists(ctx, http.MethodHead, s.baseURL+"/universe"); err != nil {
+		return caps, err
+	}
+	if caps.Search, err = s.endpointExists(ctx, http.MethodGet, s.baseURL+"/api/v1/search?q=&items=1"); err != nil {
+		return caps, err
+	}
+
+	sample, err := s.sampleTarballURL(ctx, &caps)
+	if err != nil {
+		return caps, err
+	}
+	if sample == "" {
+		return caps, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, sample, nil)
+	if err != nil {
+		return caps, fmt.Errorf("creating request: %w", err)
+	}
+	s.authorize(req)
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return caps, &ErrSourceUnavailable{Source: s.Name(), Reason: err.Error(), Err: err}
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		caps.Checksums = resp.Header.Get(checksumHeader) != ""
+		caps.RangeRequests = strings.EqualFold(resp.Header.Get("Accept-Ranges"), "bytes")
+	}
+	return caps, nil
+}
+
+// sampleTarballURL records whether the cookbook API exists in caps and
+// returns the download URL of any one cookbook, or "" if the source is empty
+func (s *SupermarketSource) sampleTarballURL(ctx context.Context, caps *Capabilities) (string, error) {
+	var list struct {
+		Items []struct {
+			Name string `json:"cookbook_name"`
+		} `json:"items"`
+	}
+	status, err := s.getJSON(ctx, s.baseURL+"/api/v1/cookbooks?items=1", &list)
+	if err != nil {
+		return "", err
+	}
+	caps.CookbooksAPI = status == http.StatusOK
+
+	if caps.CookbooksAPI {
+		if len(list.Items) == 0 {
+			return "", nil
+		}
+		details, err := s.fetchCookbookDetails(ctx, list.Items[0].Name)
+		if err != nil {
+			return "", err
+		}
+		version, err := parseVersionURL(details.LatestVersion)
+		if err != nil {
+			return "", nil
+		}
+		versionResp, err := s.fetchVersionAPI(ctx, details.Name, version)
+		if err != nil {
+			return "", err
+		}
+		return versionResp.FileURL, nil
+	}
+
+	if caps.Universe {
+		universe, err := s.loadUniverse(ctx)
+		if err != nil {
+			return "", err
+		}
+		for _, versions := range universe {
+			for _, entry := range versions {
+				return entry.DownloadURL, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// endpointExists reports whether endpoint answers successfully. Missing
+// endpoints are 404, 405 or 501; other failures are returned as errors.
+func (s *SupermarketSource) endpointExists(ctx context.Context, method, endpoint string) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, nil)
+	if err != nil {
+		return false, fmt.Errorf("creating request: %w", err)
+	}
+	s.authorize(req)
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return false, &ErrSourceUnavailable{Source: s.Name(), Reason: err.Error(), Err: err}
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return true, nil
+	case isMissingEndpoint(resp.StatusCode):
+		return false, nil
+	default:
+		return false, fmt.Errorf("probing %s: HTTP %d", endpoint, resp.StatusCode)
+	}
+}
+
+// getJSON decodes a successful response from endpoint into v and returns the
+// status code. Missing endpoints return their status without an error.
+func (s *SupermarketSource) getJSON(ctx context.Context, endpoint string, v any) (int, error) {
+	status, body, err := s.get(ctx, endpoint)
+	if err != nil {
+		return 0, err
+	}
+
+	if isMissingEndpoint(status) {
+		return status, nil
+	}
+	if status != http.StatusOK {
+		return status, fmt.Errorf("supermarket API error: %d %s", status, string(body))
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return status, fmt.Errorf("decoding response: %w", err)
+	}
+	return status, nil
+}
+
+func isMissingEndpoint(status int) bool {
+	return status == http.StatusNotFound || status == http.StatusMethodNotAllowed || status == http.StatusNotImplemented
+}
+
+// parseVersionURL extracts the version from a cookbook version API URL
+// such as ".../api/v1/cookbooks/apt/versions/7.4.0"
+func parseVersionURL(versionURL string) (*berkshelf.Version, error) {
+	u, err := url.Parse(versionURL)
+	if err != nil {
+		return nil, err
+	}
+	parts := strings.Split(strings.TrimSuffix(u.Path, "/"), "/")
+	return berkshelf.NewVersion(parts[len(parts)-1])
+}
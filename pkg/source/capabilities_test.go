@@ -0,0 +1,188 @@
+package source
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+)
+
+// memoryCapabilityStore is an in-memory CapabilityStore
+type memoryCapabilityStore struct {
+	mu   sync.Mutex
+	caps map[string]Capabilities
+}
+
+func (m *memoryCapabilityStore) GetCapabilities(sourceURL string) (Capabilities, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	caps, ok := m.caps[sourceURL]
+	return caps, ok
+}
+
+func (m *memoryCapabilityStore) PutCapabilities(sourceURL string, caps Capabilities) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.caps == nil {
+		m.caps = make(map[string]Capabilities)
+	}
+	m.caps[sourceURL] = caps
+	return nil
+}
+
+// newMirror serves a static mirror: /universe and tarballs, no API or search
+func newMirror(t *testing.T, tarball []byte, headers map[string]string) *httptest.Server {
+	t.Helper()
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/universe":
+			fmt.Fprintf(w, `{
+				"apt": {
+					"7.4.0": {"location_type": "opscode", "download_url": "%[1]s/apt-7.4.0.tgz", "dependencies": {}},
+					"7.3.0": {"location_type": "opscode", "download_url": "%[1]s/apt-7.3.0.tgz", "dependencies": {}}
+				},
+				"nginx": {
+					"2.7.6": {"location_type": "opscode", "download_url": "%[1]s/nginx-2.7.6.tgz", "dependencies": {"apt": ">= 7.0.0"}}
+				}
+			}`, server.URL)
+		case "/apt-7.4.0.tgz", "/apt-7.3.0.tgz", "/nginx-2.7.6.tgz":
+			for k, v := range headers {
+				w.Header().Set(k, v)
+			}
+			w.Header().Set("Accept-Ranges", "bytes")
+			w.Write(tarball)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestSupermarketSource_ProbeCapabilities(t *testing.T) {
+	tarball := buildTarball(t, []string{"apt/metadata.rb"})
+	sum := sha256.Sum256(tarball)
+	server := newMirror(t, tarball, map[string]string{checksumHeader: hex.EncodeToString(sum[:])})
+
+	got, err := NewSupermarketSource(server.URL).ProbeCapabilities(context.Background())
+	if err != nil {
+		t.Fatalf("ProbeCapabilities() error = %v", err)
+	}
+	want := Capabilities{Universe: true, Checksums: true, RangeRequests: true}
+	if got != want {
+		t.Errorf("ProbeCapabilities() = %+v, want %+v", got, want)
+	}
+}
+
+func TestSupermarketSource_ProbeCapabilities_ServerError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	store := &memoryCapabilityStore{}
+	src := NewSupermarketSource(server.URL)
+	src.EnableCapabilityProbe(store)
+
+	if got := src.Capabilities(context.Background()); got != allCapabilities {
+		t.Errorf("Capabilities() = %+v, want the full API to be assumed", got)
+	}
+	if _, ok := store.GetCapabilities(server.URL); ok {
+		t.Error("a failed probe was cached")
+	}
+}
+
+func TestSupermarketSource_UniverseMirror(t *testing.T) {
+	tarball := buildTarball(t, []string{"nginx/metadata.rb", "nginx/recipes/default.rb"})
+	server := newMirror(t, tarball, nil)
+
+	store := &memoryCapabilityStore{}
+	src := NewSupermarketSource(server.URL)
+	src.EnableCapabilityProbe(store)
+	ctx := context.Background()
+
+	versions, err := src.ListVersions(ctx, "apt")
+	if err != nil {
+		t.Fatalf("ListVersions() error = %v", err)
+	}
+	if len(versions) != 2 {
+		t.Errorf("ListVersions() = %v, want 2 versions", versions)
+	}
+
+	cookbook, err := src.FetchCookbook(ctx, "nginx", berkshelf.MustVersion("2.7.6"))
+	if err != nil {
+		t.Fatalf("FetchCookbook() error = %v", err)
+	}
+	if _, ok := cookbook.Dependencies["apt"]; !ok || cookbook.TarballURL != server.URL+"/nginx-2.7.6.tgz" {
+		t.Errorf("FetchCookbook() = dependencies %v, tarball %s", cookbook.Dependencies, cookbook.TarballURL)
+	}
+
+	dir := t.TempDir()
+	if err := src.DownloadAndExtractCookbook(ctx, cookbook, dir); err != nil {
+		t.Fatalf("DownloadAndExtractCookbook() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "recipes", "default.rb")); err != nil {
+		t.Errorf("cookbook was not extracted: %v", err)
+	}
+
+	if _, err := src.Search(ctx, "apt"); !errors.Is(err, ErrNotImplemented) {
+		t.Errorf("Search() error = %v, want ErrNotImplemented", err)
+	}
+	if deprecation, err := src.Deprecation(ctx, "apt"); deprecation != nil || err != nil {
+		t.Errorf("Deprecation() = %v, %v", deprecation, err)
+	}
+
+	// A second source reuses the stored probe instead of querying again
+	if _, ok := store.GetCapabilities(server.URL); !ok {
+		t.Fatal("probed capabilities were not stored")
+	}
+	store.PutCapabilities(server.URL, Capabilities{Universe: true, Search: true})
+	cached := NewSupermarketSource(server.URL)
+	cached.EnableCapabilityProbe(store)
+	if !cached.Capabilities(ctx).Search {
+		t.Error("Capabilities() did not use the stored probe")
+	}
+}
+
+func TestSupermarketSource_DownloadChecksumMismatch(t *testing.T) {
+	tarball := buildTarball(t, []string{"apt/metadata.rb"})
+	server := newMirror(t, tarball, map[string]string{checksumHeader: "0000"})
+
+	src, _ := newDownloadSource(t, server.URL)
+	cookbook := &berkshelf.Cookbook{Name: "apt", TarballURL: server.URL + "/apt-7.4.0.tgz"}
+	err := src.DownloadAndExtractCookbook(context.Background(), cookbook, t.TempDir())
+	if err == nil {
+		t.Fatal("DownloadAndExtractCookbook() expected a checksum error")
+	}
+}
+
+func TestSupermarketSource_Checksum(t *testing.T) {
+	tarball := buildTarball(t, []string{"apt/metadata.rb"})
+	sum := sha256.Sum256(tarball)
+	cookbook := func(server *httptest.Server) *berkshelf.Cookbook {
+		return &berkshelf.Cookbook{Name: "apt", TarballURL: server.URL + "/apt-7.4.0.tgz"}
+	}
+
+	server := newMirror(t, tarball, nil)
+	got, err := NewSupermarketSource(server.URL).Checksum(context.Background(), cookbook(server))
+	if err != nil || got != hex.EncodeToString(sum[:]) {
+		t.Errorf("Checksum() = %q, %v", got, err)
+	}
+
+	// A published checksum is used as is
+	server = newMirror(t, tarball, map[string]string{checksumHeader: "ABCD"})
+	got, err = NewSupermarketSource(server.URL).Checksum(context.Background(), cookbook(server))
+	if err != nil || got != "abcd" {
+		t.Errorf("Checksum() = %q, %v", got, err)
+	}
+}
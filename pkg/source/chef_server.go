@@ -2,12 +2,19 @@ package source
 
 import (
 	"context"
+	"crypto/md5"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	berrors "github.com/bdwyertech/go-berkshelf/pkg/errors"
+	"github.com/bdwyertech/go-berkshelf/pkg/fsys"
 	"github.com/go-chef/chef"
 )
 
@@ -18,10 +25,19 @@ type ChefServerSource struct {
 	clientKey  string
 	priority   int
 	chefClient *chef.Client
+	// fs is the filesystem cookbooks are downloaded to, see SetFS
+	fs fsys.FS
 }
 
-// NewChefServerSource creates a new Chef Server source.
+// NewChefServerSource creates a new Chef Server source. baseURL is the
+// organization URL, and may include a port and the path prefix of a reverse
+// proxy, e.g. https://chef.internal/chef/organizations/acme.
 func NewChefServerSource(baseURL, clientName, clientKey string) (*ChefServerSource, error) {
+	apiURL, err := chefServerAPIURL(baseURL)
+	if err != nil {
+		return nil, err
+	}
+
 	// Expand tilde in client key path
 	if strings.HasPrefix(clientKey, "~/") {
 		homeDir, err := os.UserHomeDir()
@@ -41,7 +57,7 @@ func NewChefServerSource(baseURL, clientName, clientKey string) (*ChefServerSour
 	chefClient, err := chef.NewClient(&chef.Config{
 		Name:    clientName,
 		Key:     string(keyData),
-		BaseURL: baseURL,
+		BaseURL: apiURL,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("creating chef client: %w", err)
@@ -56,6 +72,36 @@ func NewChefServerSource(baseURL, clientName, clientKey string) (*ChefServerSour
 	}, nil
 }
 
+// chefServerAPIURL returns the organization URL API paths are resolved
+// against. It must end in a slash, or resolving "cookbooks" would replace the
+// last segment of the path rather than extend it.
+func chefServerAPIURL(baseURL string) (string, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid Chef Server URL %q: %w", baseURL, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("invalid Chef Server URL %q: expected an http or https URL", baseURL)
+	}
+	u.RawQuery, u.Fragment = "", ""
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/"
+	return u.String(), nil
+}
+
+// SetProxy sends requests to the Chef Server through proxy instead of the
+// proxy from the environment. Credentials in the proxy URL are sent to it
+// with each request.
+func (s *ChefServerSource) SetProxy(proxy *url.URL) {
+	if transport, ok := s.chefClient.Client.Transport.(*http.Transport); ok {
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+}
+
+// SetFS sets the filesystem cookbooks are downloaded to
+func (s *ChefServerSource) SetFS(filesystem fsys.FS) {
+	s.fs = filesystem
+}
+
 // Name returns the name of this source.
 func (s *ChefServerSource) Name() string {
 	return fmt.Sprintf("chef-server (%s)", s.baseURL)
@@ -73,10 +119,12 @@ func (s *ChefServerSource) SetPriority(priority int) {
 
 // ListVersions returns all available versions of a cookbook.
 func (s *ChefServerSource) ListVersions(ctx context.Context, name string) ([]*berkshelf.Version, error) {
-	// Get cookbook list with versions
-	cookbooks, err := s.chefClient.Cookbooks.List()
-	if err != nil {
-		return nil, &ErrSourceUnavailable{Source: s.Name(), Reason: err.Error()}
+	var cookbooks chef.CookbookListResult
+	if err := s.get(ctx, "cookbooks/"+url.PathEscape(name)+"?num_versions=all", &cookbooks); err != nil {
+		if isNotFound(err) {
+			return nil, &ErrCookbookNotFound{Name: name}
+		}
+		return nil, s.unavailable(err)
 	}
 
 	cookbookVersions, exists := cookbooks[name]
@@ -98,12 +146,9 @@ func (s *ChefServerSource) ListVersions(ctx context.Context, name string) ([]*be
 
 // FetchMetadata downloads just the metadata for a cookbook version.
 func (s *ChefServerSource) FetchMetadata(ctx context.Context, name string, version *berkshelf.Version) (*berkshelf.Metadata, error) {
-	cookbook, err := s.chefClient.Cookbooks.GetVersion(name, version.String())
+	cookbook, err := s.getVersion(ctx, name, version)
 	if err != nil {
-		if strings.Contains(err.Error(), "404") {
-			return nil, &ErrVersionNotFound{Name: name, Version: version.String()}
-		}
-		return nil, &ErrSourceUnavailable{Source: s.Name(), Reason: err.Error()}
+		return nil, err
 	}
 
 	// Convert dependencies from Chef Server format to berkshelf constraints
@@ -158,14 +203,22 @@ func (s *ChefServerSource) FetchCookbook(ctx context.Context, name string, versi
 
 // DownloadAndExtractCookbook downloads the cookbook files and extracts them to the specified directory.
 func (s *ChefServerSource) DownloadAndExtractCookbook(ctx context.Context, cookbook *berkshelf.Cookbook, targetDir string) error {
-	if err := s.chefClient.Cookbooks.DownloadTo(cookbook.Name, cookbook.Version.String(), filepath.Dir(targetDir)); err != nil {
+	version, err := s.getVersion(ctx, cookbook.Name, cookbook.Version)
+	if err != nil {
 		return fmt.Errorf("downloading cookbook %s version %s: %w", cookbook.Name, cookbook.Version.String(), err)
 	}
-	if err := os.Remove(targetDir); err != nil {
-		return fmt.Errorf("error removing target directory: %w", err)
+
+	segments := [][]chef.CookbookItem{
+		version.RootFiles, version.Files, version.Templates, version.Attributes, version.Recipes,
+		version.Definitions, version.Libraries, version.Providers, version.Resources,
 	}
-	if err := os.Rename(targetDir+"-"+cookbook.Version.String(), targetDir); err != nil {
-		return fmt.Errorf("error renaming target directory: %w", err)
+	filesystem := fsys.Or(s.fs)
+	for _, items := range segments {
+		for _, item := range items {
+			if err := s.downloadFile(ctx, filesystem, item, targetDir); err != nil {
+				return fmt.Errorf("downloading cookbook %s version %s: %w", cookbook.Name, cookbook.Version.String(), err)
+			}
+		}
 	}
 
 	// Set the cookbook path
@@ -174,13 +227,94 @@ func (s *ChefServerSource) DownloadAndExtractCookbook(ctx context.Context, cookb
 	return nil
 }
 
+// getVersion fetches the manifest of a cookbook version
+func (s *ChefServerSource) getVersion(ctx context.Context, name string, version *berkshelf.Version) (*chef.Cookbook, error) {
+	var cookbook chef.Cookbook
+	if err := s.get(ctx, "cookbooks/"+url.PathEscape(name)+"/"+url.PathEscape(version.String()), &cookbook); err != nil {
+		if isNotFound(err) {
+			return nil, &ErrVersionNotFound{Name: name, Version: version.String()}
+		}
+		return nil, s.unavailable(err)
+	}
+	return &cookbook, nil
+}
+
+// downloadFile downloads one file of a cookbook manifest below dir in
+// filesystem and verifies its checksum. The item's path is relative to the
+// cookbook root.
+func (s *ChefServerSource) downloadFile(ctx context.Context, filesystem fsys.FS, item chef.CookbookItem, dir string) error {
+	rel := item.Path
+	if rel == "" {
+		rel = item.Name
+	}
+	rel = filepath.FromSlash(rel)
+	if !filepath.IsLocal(rel) {
+		return fmt.Errorf("cookbook file %q is outside the cookbook", item.Path)
+	}
+	target := filepath.Join(dir, rel)
+	if err := filesystem.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	req, err := s.chefClient.NewRequest(http.MethodGet, item.Url, nil)
+	if err != nil {
+		return err
+	}
+	res, err := s.chefClient.Do(req.WithContext(ctx), nil)
+	if res != nil {
+		defer res.Body.Close()
+	}
+	if err != nil {
+		return err
+	}
+
+	f, err := filesystem.Create(target)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	hash := md5.New()
+	if _, err := io.Copy(io.MultiWriter(f, hash), res.Body); err != nil {
+		return err
+	}
+	if sum := fmt.Sprintf("%x", hash.Sum(nil)); item.Checksum != "" && sum != item.Checksum {
+		return berrors.WithType(fmt.Errorf("cookbook file %s checksum mismatch (expected %s, got %s)", rel, item.Checksum, sum), berrors.ErrorTypeIntegrity)
+	}
+	return nil
+}
+
+// get sends an API request bound to ctx and decodes the response into v
+func (s *ChefServerSource) get(ctx context.Context, path string, v any) error {
+	req, err := s.chefClient.NewRequest(http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	res, err := s.chefClient.Do(req.WithContext(ctx), v)
+	if res != nil {
+		res.Body.Close()
+	}
+	return err
+}
+
+// unavailable wraps an API error
+func (s *ChefServerSource) unavailable(err error) error {
+	return &ErrSourceUnavailable{Source: s.Name(), Reason: err.Error(), Err: err}
+}
+
+// isNotFound reports whether err is a Chef Server 404
+func isNotFound(err error) bool {
+	var resp *chef.ErrorResponse
+	return errors.As(err, &resp) && resp.Response != nil && resp.Response.StatusCode == http.StatusNotFound
+}
+
 // Search returns cookbooks matching the query.
 func (s *ChefServerSource) Search(ctx context.Context, query string) ([]*berkshelf.Cookbook, error) {
 	// Chef Server doesn't have a direct search API like Supermarket
 	// This is a simplified implementation that lists all cookbooks
-	cookbooks, err := s.chefClient.Cookbooks.List()
-	if err != nil {
-		return nil, &ErrSourceUnavailable{Source: s.Name(), Reason: err.Error()}
+	var cookbooks chef.CookbookListResult
+	if err := s.get(ctx, "cookbooks", &cookbooks); err != nil {
+		return nil, s.unavailable(err)
 	}
 
 	var results []*berkshelf.Cookbook
@@ -223,6 +357,11 @@ func (s *ChefServerSource) GetSourceLocation() *berkshelf.SourceLocation {
 	}
 }
 
+// Client returns the underlying Chef Server API client
+func (s *ChefServerSource) Client() *chef.Client {
+	return s.chefClient
+}
+
 // GetSourceType returns the source type
 func (s *ChefServerSource) GetSourceType() string {
 	return "chef_server"
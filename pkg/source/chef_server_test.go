@@ -0,0 +1,323 @@
+package source
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+)
+
+// writeClientKey writes a throwaway client key and returns its path
+func writeClientKey(t *testing.T) (string, *rsa.PrivateKey) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(t.TempDir(), "client.pem")
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := os.WriteFile(keyPath, keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return keyPath, key
+}
+
+// newChefServerSource returns a source for server with a throwaway client key
+func newChefServerSource(t *testing.T, server *httptest.Server) *ChefServerSource {
+	t.Helper()
+	keyPath, _ := writeClientKey(t)
+	src, err := NewChefServerSource(server.URL+"/organizations/acme/", "ci", keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return src
+}
+
+func TestChefServerSource(t *testing.T) {
+	recipe := "log 'hello'\n"
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/organizations/acme/cookbooks/nginx":
+			if r.URL.Query().Get("num_versions") != "all" {
+				t.Errorf("listed versions with %q, want num_versions=all", r.URL.RawQuery)
+			}
+			fmt.Fprint(w, `{"nginx": {"versions": [{"version": "2.0.0"}, {"version": "1.0.0"}]}}`)
+		case "/organizations/acme/cookbooks/nginx/2.0.0":
+			json.NewEncoder(w).Encode(map[string]any{
+				"cookbook_name": "nginx",
+				"name":          "nginx-2.0.0",
+				"version":       "2.0.0",
+				"metadata":      map[string]any{"name": "nginx", "version": "2.0.0", "dependencies": map[string]string{"apt": ">= 1.0"}},
+				"recipes": []map[string]string{{
+					"name":     "recipes/default.rb",
+					"path":     "recipes/default.rb",
+					"url":      server.URL + "/files/default.rb",
+					"checksum": fmt.Sprintf("%x", md5.Sum([]byte(recipe))),
+				}},
+			})
+		case "/files/default.rb":
+			fmt.Fprint(w, recipe)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error": ["not found"]}`)
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	src := newChefServerSource(t, server)
+
+	versions, err := src.ListVersions(ctx, "nginx")
+	if err != nil || len(versions) != 2 {
+		t.Fatalf("ListVersions() = %v, %v, want 2 versions", versions, err)
+	}
+	var notFound *ErrCookbookNotFound
+	if _, err := src.ListVersions(ctx, "missing"); !errors.As(err, &notFound) {
+		t.Errorf("ListVersions(missing) error = %v, want ErrCookbookNotFound", err)
+	}
+
+	metadata, err := src.FetchMetadata(ctx, "nginx", berkshelf.MustVersion("2.0.0"))
+	if err != nil || metadata.Dependencies["apt"] == nil {
+		t.Fatalf("FetchMetadata() = %+v, %v", metadata, err)
+	}
+	var versionNotFound *ErrVersionNotFound
+	if _, err := src.FetchMetadata(ctx, "nginx", berkshelf.MustVersion("9.9.9")); !errors.As(err, &versionNotFound) {
+		t.Errorf("FetchMetadata(9.9.9) error = %v, want ErrVersionNotFound", err)
+	}
+
+	target := filepath.Join(t.TempDir(), "nginx")
+	cookbook := &berkshelf.Cookbook{Name: "nginx", Version: berkshelf.MustVersion("2.0.0")}
+	if err := src.DownloadAndExtractCookbook(ctx, cookbook, target); err != nil {
+		t.Fatalf("DownloadAndExtractCookbook() error = %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(target, "recipes", "default.rb")); err != nil || string(data) != recipe {
+		t.Errorf("downloaded recipe = %q, %v", data, err)
+	}
+}
+
+// newBlockingServer returns a server that answers nothing until the request
+// is abandoned
+func newBlockingServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// assertCancels calls fn with a context cancelled shortly after, and fails
+// unless fn returns the cancellation promptly
+func assertCancels(t *testing.T, fn func(ctx context.Context) error) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	done := make(chan error, 1)
+	go func() { done <- fn(ctx) }()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("error = %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("did not return after the context was cancelled")
+	}
+}
+
+func TestChefServerSource_Cancel(t *testing.T) {
+	src := newChefServerSource(t, newBlockingServer(t))
+	version := berkshelf.MustVersion("1.0.0")
+
+	assertCancels(t, func(ctx context.Context) error {
+		_, err := src.ListVersions(ctx, "nginx")
+		return err
+	})
+	assertCancels(t, func(ctx context.Context) error {
+		_, err := src.FetchMetadata(ctx, "nginx", version)
+		return err
+	})
+	assertCancels(t, func(ctx context.Context) error {
+		return src.DownloadAndExtractCookbook(ctx, &berkshelf.Cookbook{Name: "nginx", Version: version}, t.TempDir())
+	})
+	assertCancels(t, func(ctx context.Context) error {
+		_, err := src.Search(ctx, "nginx")
+		return err
+	})
+}
+
+// verifyChefSignature checks the protocol 1.0 signature of r against the
+// path the server received, which is what a Chef Server verifies
+func verifyChefSignature(r *http.Request, key *rsa.PublicKey) error {
+	var encoded strings.Builder
+	for i := 1; r.Header.Get(fmt.Sprintf("X-Ops-Authorization-%d", i)) != ""; i++ {
+		encoded.WriteString(r.Header.Get(fmt.Sprintf("X-Ops-Authorization-%d", i)))
+	}
+	signature, err := base64.StdEncoding.DecodeString(encoded.String())
+	if err != nil {
+		return err
+	}
+	// go-chef drops leading zero bytes of the signature, which the
+	// verification expects to be the size of the key
+	if size := key.Size(); len(signature) < size {
+		signature = append(make([]byte, size-len(signature)), signature...)
+	}
+	pathSum := sha1.Sum([]byte(r.URL.Path))
+	content := fmt.Sprintf("Method:%s\nHashed Path:%s\nX-Ops-Content-Hash:%s\nX-Ops-Timestamp:%s\nX-Ops-UserId:%s",
+		r.Method, base64.StdEncoding.EncodeToString(pathSum[:]), r.Header.Get("X-Ops-Content-Hash"),
+		r.Header.Get("X-Ops-Timestamp"), r.Header.Get("X-Ops-UserId"))
+	return rsa.VerifyPKCS1v15(key, 0, []byte(content), signature)
+}
+
+// newAuthProxy returns the URL, with credentials, of a forward proxy that
+// turns away requests without them, and a count of the requests it forwarded
+func newAuthProxy(t *testing.T) (*url.URL, *atomic.Int32) {
+	t.Helper()
+	var forwarded atomic.Int32
+	want := "Basic " + base64.StdEncoding.EncodeToString([]byte("proxyuser:s3cret"))
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Proxy-Authorization") != want {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+		out := r.Clone(r.Context())
+		out.RequestURI = ""
+		out.Header.Del("Proxy-Authorization")
+		resp, err := http.DefaultTransport.RoundTrip(out)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+		forwarded.Add(1)
+		maps.Copy(w.Header(), resp.Header)
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+	}))
+	t.Cleanup(proxy.Close)
+
+	proxyURL, err := url.Parse(proxy.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxyURL.User = url.UserPassword("proxyuser", "s3cret")
+	return proxyURL, &forwarded
+}
+
+func TestChefServerSource_URLShapes(t *testing.T) {
+	// httptest servers listen on a nonstandard port, which every case keeps
+	tests := []struct {
+		name   string
+		prefix string
+		suffix string
+		proxy  bool
+	}{
+		{name: "organization URL", prefix: "/organizations/acme", suffix: "/"},
+		{name: "without a trailing slash", prefix: "/organizations/acme"},
+		{name: "reverse proxy path prefix", prefix: "/chef/organizations/acme"},
+		{name: "authenticated proxy", prefix: "/chef/organizations/acme", suffix: "/", proxy: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recipe := "log 'hello'\n"
+			keyPath, key := writeClientKey(t)
+
+			var mu sync.Mutex
+			var rejected []string
+			var server *httptest.Server
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if err := verifyChefSignature(r, &key.PublicKey); err != nil {
+					mu.Lock()
+					rejected = append(rejected, r.URL.Path)
+					mu.Unlock()
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				switch r.URL.Path {
+				case tt.prefix + "/cookbooks/nginx":
+					fmt.Fprint(w, `{"nginx": {"versions": [{"version": "1.0.0"}]}}`)
+				case tt.prefix + "/cookbooks/nginx/1.0.0":
+					json.NewEncoder(w).Encode(map[string]any{
+						"cookbook_name": "nginx",
+						"version":       "1.0.0",
+						"metadata":      map[string]any{"name": "nginx", "version": "1.0.0"},
+						"recipes": []map[string]string{{
+							"name":     "recipes/default.rb",
+							"path":     "recipes/default.rb",
+							"url":      server.URL + "/bookshelf/organization-acme/checksum-1",
+							"checksum": fmt.Sprintf("%x", md5.Sum([]byte(recipe))),
+						}},
+					})
+				case "/bookshelf/organization-acme/checksum-1":
+					fmt.Fprint(w, recipe)
+				default:
+					w.WriteHeader(http.StatusNotFound)
+					fmt.Fprint(w, `{"error": ["not found"]}`)
+				}
+			}))
+			defer server.Close()
+
+			src, err := NewChefServerSource(server.URL+tt.prefix+tt.suffix, "ci", keyPath)
+			if err != nil {
+				t.Fatalf("NewChefServerSource() error = %v", err)
+			}
+			var forwarded *atomic.Int32
+			if tt.proxy {
+				var proxyURL *url.URL
+				proxyURL, forwarded = newAuthProxy(t)
+				src.SetProxy(proxyURL)
+			}
+
+			ctx := context.Background()
+			if versions, err := src.ListVersions(ctx, "nginx"); err != nil || len(versions) != 1 {
+				t.Fatalf("ListVersions() = %v, %v, want 1 version", versions, err)
+			}
+			target := filepath.Join(t.TempDir(), "nginx")
+			cookbook := &berkshelf.Cookbook{Name: "nginx", Version: berkshelf.MustVersion("1.0.0")}
+			if err := src.DownloadAndExtractCookbook(ctx, cookbook, target); err != nil {
+				t.Fatalf("DownloadAndExtractCookbook() error = %v", err)
+			}
+			if data, err := os.ReadFile(filepath.Join(target, "recipes", "default.rb")); err != nil || string(data) != recipe {
+				t.Errorf("downloaded recipe = %q, %v", data, err)
+			}
+			if len(rejected) > 0 {
+				t.Errorf("signatures did not match the requested paths: %v", rejected)
+			}
+			if forwarded != nil && forwarded.Load() != 3 {
+				t.Errorf("proxy forwarded %d requests, want 3", forwarded.Load())
+			}
+		})
+	}
+}
+
+func TestNewChefServerSource_InvalidURL(t *testing.T) {
+	keyPath, _ := writeClientKey(t)
+	for _, baseURL := range []string{"chef.internal/organizations/acme", "ftp://chef.internal/organizations/acme", "https:///organizations/acme"} {
+		if _, err := NewChefServerSource(baseURL, "ci", keyPath); err == nil {
+			t.Errorf("NewChefServerSource(%q) succeeded, want an error", baseURL)
+		}
+	}
+}
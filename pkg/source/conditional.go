@@ -0,0 +1,83 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/metrics"
+)
+
+// CachedResponse is an API response body with the validators the server
+// sent for it
+type CachedResponse struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	Body         []byte `json:"body"`
+}
+
+// ResponseStore persists API responses between runs, so they can be
+// revalidated with a conditional request instead of downloaded again
+type ResponseStore interface {
+	GetResponse(url string) (CachedResponse, bool)
+	PutResponse(url string, response CachedResponse) error
+}
+
+// SetResponseStore makes the source revalidate the API responses kept in
+// store, and keep the responses that carry an ETag or Last-Modified there
+func (s *SupermarketSource) SetResponseStore(store ResponseStore) {
+	s.responses = store
+}
+
+// get reads endpoint and returns the status code and body. A response kept
+// in the response store is sent as If-None-Match and If-Modified-Since, and
+// its body returned with 200 when the server answers 304 Not Modified.
+func (s *SupermarketSource) get(ctx context.Context, endpoint string) (int, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return 0, nil, fmt.Errorf("creating request: %w", err)
+	}
+	s.authorize(req)
+
+	var cached CachedResponse
+	var revalidate bool
+	if s.responses != nil {
+		if cached, revalidate = s.responses.GetResponse(endpoint); revalidate {
+			if cached.ETag != "" {
+				req.Header.Set("If-None-Match", cached.ETag)
+			}
+			if cached.LastModified != "" {
+				req.Header.Set("If-Modified-Since", cached.LastModified)
+			}
+		}
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return 0, nil, &ErrSourceUnavailable{Source: s.Name(), Reason: err.Error(), Err: err}
+	}
+	defer resp.Body.Close()
+
+	if revalidate {
+		metrics.Lookup("responses", resp.StatusCode == http.StatusNotModified)
+	}
+	if resp.StatusCode == http.StatusNotModified && revalidate {
+		log.Debugf("Not modified: %s", endpoint)
+		return http.StatusOK, cached.Body, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, fmt.Errorf("reading response: %w", err)
+	}
+
+	etag, lastModified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	if s.responses != nil && resp.StatusCode == http.StatusOK && (etag != "" || lastModified != "") {
+		response := CachedResponse{ETag: etag, LastModified: lastModified, Body: body}
+		if err := s.responses.PutResponse(endpoint, response); err != nil {
+			log.Debugf("Failed to cache the response of %s: %v", endpoint, err)
+		}
+	}
+	return resp.StatusCode, body, nil
+}
@@ -0,0 +1,284 @@
+package source
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-json"
+
+	berrors "github.com/bdwyertech/go-berkshelf/pkg/errors"
+	"github.com/bdwyertech/go-berkshelf/pkg/metrics"
+)
+
+// downloadAttempts is how many times a tarball download is tried; each
+// attempt resumes from the bytes the previous ones wrote
+const downloadAttempts = 3
+
+// downloadRetryDelay is the pause before the second attempt, doubled before
+// each one after
+var downloadRetryDelay = time.Second
+
+// staleDownloadLock is the age at which a download lock is taken to have been
+// left behind by a process that died
+const staleDownloadLock = time.Hour
+
+// partialDownload records what a .partial file holds, so an interrupted
+// download can be resumed with a Range request
+type partialDownload struct {
+	URL  string `json:"url"`
+	ETag string `json:"etag,omitempty"`
+	// Checksum is the SHA-256 the server published for the whole tarball
+	Checksum string `json:"checksum,omitempty"`
+	Bytes    int64  `json:"bytes"`
+}
+
+// DefaultDownloadDir is where tarballs are downloaded unless SetDownloadDir
+// is called. Interrupted downloads are kept there to be resumed. It is in
+// the user's berkshelf directory rather than a shared temporary directory,
+// so other users cannot plant partial downloads to be resumed.
+func DefaultDownloadDir() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".berkshelf", "downloads")
+}
+
+// privateDownloadDir creates dir, readable only by the current user, and
+// refuses one another user owns, who could plant files in it
+func privateDownloadDir(dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	if !ownedByCurrentUser(info) {
+		return fmt.Errorf("%s is owned by another user", dir)
+	}
+	if info.Mode().Perm() != 0700 {
+		return os.Chmod(dir, 0700)
+	}
+	return nil
+}
+
+// SetDownloadDir sets the directory tarballs are downloaded to
+func (s *SupermarketSource) SetDownloadDir(dir string) {
+	s.downloadDir = dir
+}
+
+// downloadTarball downloads url and returns the path of the verified file,
+// which the caller removes. An interrupted download leaves a .partial file
+// that later attempts, in this run or a later one, resume from if the
+// server still reports the same ETag. The download is checked against the
+// checksum header, when the server sends one, before it is returned.
+func (s *SupermarketSource) downloadTarball(ctx context.Context, url string) (string, error) {
+	dir := s.downloadDir
+	if dir == "" {
+		dir = DefaultDownloadDir()
+	}
+	if err := privateDownloadDir(dir); err != nil {
+		return "", fmt.Errorf("creating download directory: %w", err)
+	}
+
+	sum := sha256.Sum256([]byte(url))
+	base := filepath.Join(dir, hex.EncodeToString(sum[:16]))
+	if unlock, ok := lockDownload(base + ".lock"); ok {
+		defer unlock()
+	} else {
+		// Another process is downloading the same tarball; download it
+		// separately rather than writing to the same partial file
+		tmp, err := os.MkdirTemp(dir, "download-")
+		if err != nil {
+			return "", fmt.Errorf("creating download directory: %w", err)
+		}
+		defer os.RemoveAll(tmp)
+		base = filepath.Join(tmp, "tarball")
+	}
+
+	var err error
+	for attempt := range downloadAttempts {
+		if attempt > 0 {
+			log.Debugf("Retrying download of %s: %v", url, err)
+			metrics.Add(metrics.Retries, 1, metrics.L("operation", "download"))
+			select {
+			case <-ctx.Done():
+				return "", err
+			case <-time.After(downloadRetryDelay << (attempt - 1)):
+			}
+		}
+		var retry bool
+		if retry, err = s.downloadAttempt(ctx, url, base); err == nil || !retry || ctx.Err() != nil {
+			break
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// Hand the finished download over under a name of its own
+	done, err := os.CreateTemp(dir, "*.tar.gz")
+	if err != nil {
+		return "", fmt.Errorf("creating download file: %w", err)
+	}
+	done.Close()
+	if err := os.Rename(base+".partial", done.Name()); err != nil {
+		os.Remove(done.Name())
+		return "", fmt.Errorf("moving download into place: %w", err)
+	}
+	os.Remove(base + ".partial.json")
+	return done.Name(), nil
+}
+
+// downloadAttempt downloads url into base.partial, resuming from the bytes
+// recorded in base.partial.json. retry is set for failures another attempt
+// may not have.
+func (s *SupermarketSource) downloadAttempt(ctx context.Context, url, base string) (retry bool, err error) {
+	partialPath, recordPath := base+".partial", base+".partial.json"
+
+	file, err := os.OpenFile(partialPath, os.O_RDWR|os.O_CREATE|openNoFollow, 0600)
+	if err != nil {
+		return false, fmt.Errorf("creating download file: %w", err)
+	}
+	defer file.Close()
+
+	// Resume only what the record vouches for: bytes written after it was
+	// last saved are dropped, and without an ETag there is no telling
+	// whether the tarball changed since
+	record := loadPartialDownload(recordPath)
+	if info, err := file.Stat(); err != nil || record.URL != url || record.ETag == "" || info.Size() < record.Bytes {
+		record = partialDownload{URL: url}
+	}
+	if err := file.Truncate(record.Bytes); err != nil {
+		return false, fmt.Errorf("truncating download file: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return false, fmt.Errorf("creating download request: %w", err)
+	}
+	s.authorize(req)
+	if record.Bytes > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", record.Bytes))
+		req.Header.Set("If-Range", record.ETag)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("downloading tarball: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && record.Bytes > 0:
+		if start, ok := contentRangeStart(resp.Header.Get("Content-Range")); !ok || start != record.Bytes {
+			savePartialDownload(recordPath, partialDownload{URL: url})
+			return true, fmt.Errorf("failed to resume download: unexpected Content-Range %q", resp.Header.Get("Content-Range"))
+		}
+		log.Debugf("Resuming download of %s at byte %d", url, record.Bytes)
+	case resp.StatusCode == http.StatusOK:
+		// Sent in full, either as asked or because the tarball changed
+		record = partialDownload{
+			URL:      url,
+			ETag:     resp.Header.Get("ETag"),
+			Checksum: strings.ToLower(resp.Header.Get(checksumHeader)),
+		}
+		if err := file.Truncate(0); err != nil {
+			return false, fmt.Errorf("truncating download file: %w", err)
+		}
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		savePartialDownload(recordPath, partialDownload{URL: url})
+		return true, fmt.Errorf("failed to resume download: HTTP %d", resp.StatusCode)
+	default:
+		return resp.StatusCode >= 500, fmt.Errorf("failed to download tarball: HTTP %d", resp.StatusCode)
+	}
+
+	if _, err := file.Seek(record.Bytes, io.SeekStart); err != nil {
+		return false, fmt.Errorf("seeking download file: %w", err)
+	}
+	n, copyErr := io.Copy(file, resp.Body)
+	record.Bytes += n
+	metrics.Add(metrics.DownloadBytes, float64(n), metrics.L("source", s.Name()))
+	if err := savePartialDownload(recordPath, record); err != nil {
+		log.Debugf("Download of %s cannot be resumed: %v", url, err)
+	}
+	if copyErr != nil {
+		return true, fmt.Errorf("downloading tarball: %w", copyErr)
+	}
+
+	if record.Checksum != "" {
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return false, fmt.Errorf("seeking download file: %w", err)
+		}
+		hash := sha256.New()
+		if _, err := io.Copy(hash, file); err != nil {
+			return false, fmt.Errorf("reading download file: %w", err)
+		}
+		if actual := hex.EncodeToString(hash.Sum(nil)); actual != record.Checksum {
+			// Start the next attempt from scratch
+			savePartialDownload(recordPath, partialDownload{URL: url})
+			return true, berrors.WithType(fmt.Errorf("checksum mismatch for %s: expected sha256 %s, got %s", url, record.Checksum, actual), berrors.ErrorTypeIntegrity)
+		}
+	}
+	return false, nil
+}
+
+// contentRangeStart returns the first byte of a "bytes START-END/SIZE" range
+func contentRangeStart(contentRange string) (int64, bool) {
+	var start, end int64
+	if _, err := fmt.Sscanf(contentRange, "bytes %d-%d/", &start, &end); err != nil {
+		return 0, false
+	}
+	return start, true
+}
+
+// loadPartialDownload reads a download record; a missing or unreadable
+// record is empty
+func loadPartialDownload(path string) partialDownload {
+	var record partialDownload
+	if data, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(data, &record); err != nil {
+			return partialDownload{}
+		}
+	}
+	return record
+}
+
+func savePartialDownload(path string, record partialDownload) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// lockDownload takes the lock at path, replacing one older than
+// staleDownloadLock, and reports whether it did
+func lockDownload(path string) (unlock func(), ok bool) {
+	for range 2 {
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err == nil {
+			file.Close()
+			return func() { os.Remove(path) }, true
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, false
+		}
+		info, err := os.Stat(path)
+		if err != nil || time.Since(info.ModTime()) < staleDownloadLock {
+			return nil, false
+		}
+		os.Remove(path)
+	}
+	return nil, false
+}
@@ -0,0 +1,255 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+)
+
+// tarballServer serves a tarball with Range support. The first cut responses
+// stop after half of what they promised.
+type tarballServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	tarball  []byte
+	etag     string
+	checksum string
+	cut      int
+	ranges   []string
+}
+
+func newTarballServer(t *testing.T, tarball []byte) *tarballServer {
+	t.Helper()
+	ts := &tarballServer{tarball: tarball, etag: `"v1"`}
+	ts.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ts.mu.Lock()
+		ts.ranges = append(ts.ranges, r.Header.Get("Range"))
+		cut := ts.cut > 0
+		if cut {
+			ts.cut--
+		}
+		tarball, etag, checksum := ts.tarball, ts.etag, ts.checksum
+		ts.mu.Unlock()
+
+		w.Header().Set("ETag", etag)
+		if checksum != "" {
+			w.Header().Set(checksumHeader, checksum)
+		}
+		if cut {
+			// Promise the whole tarball and drop the connection halfway
+			w.Header().Set("Content-Length", fmt.Sprint(len(tarball)))
+			w.Write(tarball[:len(tarball)/2])
+			return
+		}
+		http.ServeContent(w, r, "nginx.tgz", time.Time{}, bytes.NewReader(tarball))
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func (ts *tarballServer) requests() []string {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return append([]string(nil), ts.ranges...)
+}
+
+func newDownloadSource(t *testing.T, url string) (*SupermarketSource, string) {
+	t.Helper()
+	delay := downloadRetryDelay
+	downloadRetryDelay = 0
+	t.Cleanup(func() { downloadRetryDelay = delay })
+
+	source := NewSupermarketSource(url)
+	dir := t.TempDir()
+	source.SetDownloadDir(dir)
+	return source, dir
+}
+
+func TestSupermarketSource_DownloadResume(t *testing.T) {
+	tarball := buildTarball(t, []string{"nginx/metadata.json", "nginx/recipes/default.rb"})
+	server := newTarballServer(t, tarball)
+	server.cut = 1
+	sum := sha256.Sum256(tarball)
+	server.checksum = hex.EncodeToString(sum[:])
+
+	source, downloads := newDownloadSource(t, server.URL)
+	cookbook := &berkshelf.Cookbook{Name: "nginx", TarballURL: server.URL + "/nginx.tgz"}
+	targetDir := filepath.Join(t.TempDir(), "nginx")
+	if err := source.DownloadAndExtractCookbook(context.Background(), cookbook, targetDir); err != nil {
+		t.Fatalf("DownloadAndExtractCookbook() error = %v", err)
+	}
+
+	want := []string{"", fmt.Sprintf("bytes=%d-", len(tarball)/2)}
+	if got := server.requests(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Range headers = %q, want %q", got, want)
+	}
+	if _, err := os.Stat(filepath.Join(targetDir, "recipes", "default.rb")); err != nil {
+		t.Errorf("cookbook not extracted: %v", err)
+	}
+	assertOnlyEntries(t, downloads)
+}
+
+func TestSupermarketSource_DownloadResume_NextRun(t *testing.T) {
+	tarball := buildTarball(t, []string{"nginx/metadata.json"})
+	server := newTarballServer(t, tarball)
+	server.cut = downloadAttempts
+
+	source, downloads := newDownloadSource(t, server.URL)
+	cookbook := &berkshelf.Cookbook{Name: "nginx", TarballURL: server.URL + "/nginx.tgz"}
+	err := source.DownloadAndExtractCookbook(context.Background(), cookbook, filepath.Join(t.TempDir(), "nginx"))
+	if err == nil {
+		t.Fatal("DownloadAndExtractCookbook() succeeded with every response cut short")
+	}
+
+	// The partial file is kept for the next run, which resumes from it
+	if err := source.DownloadAndExtractCookbook(context.Background(), cookbook, filepath.Join(t.TempDir(), "nginx")); err != nil {
+		t.Fatalf("DownloadAndExtractCookbook() error = %v", err)
+	}
+	requests := server.requests()
+	if last := requests[len(requests)-1]; last != fmt.Sprintf("bytes=%d-", len(tarball)/2) {
+		t.Errorf("last Range header = %q, want a resume", last)
+	}
+	assertOnlyEntries(t, downloads)
+}
+
+func TestSupermarketSource_DownloadResume_Changed(t *testing.T) {
+	old := buildTarball(t, []string{"nginx/metadata.json"})
+	server := newTarballServer(t, old)
+	server.cut = downloadAttempts
+
+	source, _ := newDownloadSource(t, server.URL)
+	cookbook := &berkshelf.Cookbook{Name: "nginx", TarballURL: server.URL + "/nginx.tgz"}
+	if err := source.DownloadAndExtractCookbook(context.Background(), cookbook, t.TempDir()); err == nil {
+		t.Fatal("DownloadAndExtractCookbook() succeeded with every response cut short")
+	}
+
+	// A changed tarball is sent in full rather than spliced onto the old one
+	server.mu.Lock()
+	server.tarball, server.etag = buildTarball(t, []string{"nginx/metadata.json", "nginx/README.md"}), `"v2"`
+	server.mu.Unlock()
+
+	targetDir := filepath.Join(t.TempDir(), "nginx")
+	if err := source.DownloadAndExtractCookbook(context.Background(), cookbook, targetDir); err != nil {
+		t.Fatalf("DownloadAndExtractCookbook() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(targetDir, "README.md")); err != nil {
+		t.Errorf("changed tarball not extracted: %v", err)
+	}
+}
+
+func TestSupermarketSource_DownloadChecksumMismatch_Retried(t *testing.T) {
+	server := newTarballServer(t, buildTarball(t, []string{"nginx/metadata.json"}))
+	server.checksum = strings.Repeat("0", 64)
+
+	source, _ := newDownloadSource(t, server.URL)
+	cookbook := &berkshelf.Cookbook{Name: "nginx", TarballURL: server.URL + "/nginx.tgz"}
+	targetDir := filepath.Join(t.TempDir(), "nginx")
+	err := source.DownloadAndExtractCookbook(context.Background(), cookbook, targetDir)
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("DownloadAndExtractCookbook() error = %v, want a checksum mismatch", err)
+	}
+	if _, err := os.Stat(targetDir); !os.IsNotExist(err) {
+		t.Error("tarball was extracted before its checksum was verified")
+	}
+	if requests := server.requests(); len(requests) != downloadAttempts || requests[1] != "" {
+		t.Errorf("requests = %q, want %d full downloads", requests, downloadAttempts)
+	}
+}
+
+// digestVerifier accepts only the artifact with the digest it expects
+type digestVerifier struct {
+	want   string
+	called string
+}
+
+func (v *digestVerifier) VerifyArtifact(ctx context.Context, name, version, sha256Hex string) error {
+	v.called = name + " " + version
+	if sha256Hex != v.want {
+		return fmt.Errorf("untrusted artifact %s", sha256Hex)
+	}
+	return nil
+}
+
+func TestSupermarketSource_ArtifactVerifier(t *testing.T) {
+	tarball := buildTarball(t, []string{"nginx/metadata.json"})
+	server := newTarballServer(t, tarball)
+	sum := sha256.Sum256(tarball)
+
+	source, _ := newDownloadSource(t, server.URL)
+	cookbook := &berkshelf.Cookbook{Name: "nginx", Version: berkshelf.MustVersion("1.2.3"), TarballURL: server.URL + "/nginx.tgz"}
+
+	verifier := &digestVerifier{want: hex.EncodeToString(sum[:])}
+	source.SetArtifactVerifier(verifier)
+	if err := source.DownloadAndExtractCookbook(context.Background(), cookbook, filepath.Join(t.TempDir(), "nginx")); err != nil {
+		t.Fatalf("DownloadAndExtractCookbook() error = %v", err)
+	}
+	if verifier.called != "nginx 1.2.3" {
+		t.Errorf("verified %q, want nginx 1.2.3", verifier.called)
+	}
+
+	verifier.want = strings.Repeat("0", 64)
+	targetDir := filepath.Join(t.TempDir(), "nginx")
+	if err := source.DownloadAndExtractCookbook(context.Background(), cookbook, targetDir); err == nil {
+		t.Fatal("DownloadAndExtractCookbook() accepted an artifact the verifier refused")
+	}
+	if _, err := os.Stat(targetDir); !os.IsNotExist(err) {
+		t.Error("tarball was extracted before its signature was verified")
+	}
+}
+
+func TestSupermarketSource_DownloadDirPrivate(t *testing.T) {
+	tarball := buildTarball(t, []string{"nginx/metadata.json"})
+	server := newTarballServer(t, tarball)
+
+	source, downloads := newDownloadSource(t, server.URL)
+	if err := os.Chmod(downloads, 0777); err != nil {
+		t.Fatal(err)
+	}
+	cookbook := &berkshelf.Cookbook{Name: "nginx", TarballURL: server.URL + "/nginx.tgz"}
+	if err := source.DownloadAndExtractCookbook(context.Background(), cookbook, filepath.Join(t.TempDir(), "nginx")); err != nil {
+		t.Fatalf("DownloadAndExtractCookbook() error = %v", err)
+	}
+	if info, err := os.Stat(downloads); err != nil || info.Mode().Perm() != 0700 {
+		t.Errorf("download directory mode = %v, %v, want 0700", info.Mode().Perm(), err)
+	}
+}
+
+func TestSupermarketSource_DownloadPartialSymlink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("O_NOFOLLOW is not available on Windows")
+	}
+	tarball := buildTarball(t, []string{"nginx/metadata.json"})
+	server := newTarballServer(t, tarball)
+
+	source, downloads := newDownloadSource(t, server.URL)
+	url := server.URL + "/nginx.tgz"
+	sum := sha256.Sum256([]byte(url))
+	victim := filepath.Join(t.TempDir(), "victim")
+	if err := os.WriteFile(victim, []byte("keep"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(victim, filepath.Join(downloads, hex.EncodeToString(sum[:16])+".partial")); err != nil {
+		t.Fatal(err)
+	}
+
+	cookbook := &berkshelf.Cookbook{Name: "nginx", TarballURL: url}
+	if err := source.DownloadAndExtractCookbook(context.Background(), cookbook, filepath.Join(t.TempDir(), "nginx")); err == nil {
+		t.Error("DownloadAndExtractCookbook() followed a planted .partial symlink")
+	}
+	if data, err := os.ReadFile(victim); err != nil || string(data) != "keep" {
+		t.Errorf("the symlink target = %q, %v, want it untouched", data, err)
+	}
+}
@@ -0,0 +1,19 @@
+//go:build !windows
+
+package source
+
+import (
+	"os"
+	"syscall"
+)
+
+// openNoFollow makes opening a download file fail rather than follow a
+// symbolic link planted in its place
+const openNoFollow = syscall.O_NOFOLLOW
+
+// ownedByCurrentUser reports whether the file info describes is owned by
+// the user running berks
+func ownedByCurrentUser(info os.FileInfo) bool {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	return ok && int(stat.Uid) == os.Getuid()
+}
@@ -0,0 +1,15 @@
+//go:build windows
+
+package source
+
+import "os"
+
+// openNoFollow is unset on Windows, which has no O_NOFOLLOW; the download
+// directory is private to the user instead
+const openNoFollow = 0
+
+// ownedByCurrentUser reports true on Windows, where the download directory
+// is under the user's profile, which other users cannot write to
+func ownedByCurrentUser(info os.FileInfo) bool {
+	return true
+}
@@ -3,6 +3,8 @@ package source
 import (
 	"errors"
 	"fmt"
+
+	berrors "github.com/bdwyertech/go-berkshelf/pkg/errors"
 )
 
 // Common errors
@@ -14,7 +16,7 @@ var (
 	ErrInvalidSource = errors.New("invalid source configuration")
 
 	// ErrAuthenticationRequired is returned when authentication is needed but not provided.
-	ErrAuthenticationRequired = errors.New("authentication required")
+	ErrAuthenticationRequired = berrors.WithType(errors.New("authentication required"), berrors.ErrorTypeAuthentication)
 )
 
 // ErrCookbookNotFound is returned when a cookbook cannot be found.
@@ -30,6 +32,11 @@ func (e *ErrCookbookNotFound) Error() string {
 	return fmt.Sprintf("cookbook %s not found", e.Name)
 }
 
+// ErrorType reports a missing cookbook as a resolution error
+func (e *ErrCookbookNotFound) ErrorType() berrors.ErrorType {
+	return berrors.ErrorTypeResolution
+}
+
 // ErrVersionNotFound is returned when a specific version cannot be found.
 type ErrVersionNotFound struct {
 	Name    string
@@ -40,6 +47,11 @@ func (e *ErrVersionNotFound) Error() string {
 	return fmt.Sprintf("version %s of cookbook %s not found", e.Version, e.Name)
 }
 
+// ErrorType reports a missing version as a resolution error
+func (e *ErrVersionNotFound) ErrorType() berrors.ErrorType {
+	return berrors.ErrorTypeResolution
+}
+
 // ErrInvalidMetadata is returned when cookbook metadata is invalid or corrupt.
 type ErrInvalidMetadata struct {
 	Name   string
@@ -50,12 +62,52 @@ func (e *ErrInvalidMetadata) Error() string {
 	return fmt.Sprintf("invalid metadata for cookbook %s: %s", e.Name, e.Reason)
 }
 
+// ErrorType reports invalid metadata as a parsing error
+func (e *ErrInvalidMetadata) ErrorType() berrors.ErrorType {
+	return berrors.ErrorTypeParsing
+}
+
 // ErrSourceUnavailable is returned when a source is temporarily unavailable.
+// Err is the underlying error, if any, so callers can tell a cancelled
+// context from an unreachable source.
 type ErrSourceUnavailable struct {
 	Source string
 	Reason string
+	Err    error
 }
 
 func (e *ErrSourceUnavailable) Error() string {
 	return fmt.Sprintf("source %s unavailable: %s", e.Source, e.Reason)
 }
+
+func (e *ErrSourceUnavailable) Unwrap() error {
+	return e.Err
+}
+
+// ErrorType reports an unavailable source as a network error
+func (e *ErrSourceUnavailable) ErrorType() berrors.ErrorType {
+	return berrors.ErrorTypeNetwork
+}
+
+// ErrRevisionMismatch is returned when a git source locked to a revision
+// no longer resolves to it: the ref it was locked from has moved to
+// Resolved, or the locked commit is unreachable when Resolved is empty.
+type ErrRevisionMismatch struct {
+	URI      string
+	Ref      string
+	Locked   string
+	Resolved string
+}
+
+func (e *ErrRevisionMismatch) Error() string {
+	if e.Resolved == "" {
+		return fmt.Sprintf("locked revision %s of %s is unreachable", e.Locked, e.URI)
+	}
+	return fmt.Sprintf("%s of %s has moved from locked revision %s to %s", e.Ref, e.URI, e.Locked, e.Resolved)
+}
+
+// ErrorType reports a moved or unreachable locked revision as an integrity
+// error
+func (e *ErrRevisionMismatch) ErrorType() berrors.ErrorType {
+	return berrors.ErrorTypeIntegrity
+}
@@ -1,17 +1,37 @@
 package source
 
 import (
+	"cmp"
 	"fmt"
 	"net/url"
+	"strconv"
 	"strings"
 
 	"github.com/bdwyertech/go-berkshelf/pkg/berksfile"
 	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	"github.com/bdwyertech/go-berkshelf/pkg/credentials"
+	"github.com/bdwyertech/go-berkshelf/pkg/fsys"
 )
 
 // Factory creates CookbookSource instances from Berksfile entries.
 type Factory struct {
-	defaultSources []CookbookSource
+	defaultSources  []CookbookSource
+	apiKeys         map[string]string
+	capabilityStore CapabilityStore
+	rateLimit       RateLimit
+	responseStore   ResponseStore
+	verifierFor     func(url string) ArtifactVerifier
+	chefDefaults    ChefServerCredentials
+	chefProfiles    map[string]ChefServerCredentials
+	fs              fsys.FS
+}
+
+// ChefServerCredentials are a Chef Server organization URL and the client
+// that authenticates to it
+type ChefServerCredentials struct {
+	URL        string
+	ClientName string
+	ClientKey  string
 }
 
 // NewFactory creates a new source factory.
@@ -26,6 +46,116 @@ func (f *Factory) AddDefaultSource(source CookbookSource) {
 	f.defaultSources = append(f.defaultSources, source)
 }
 
+// SetAPIKeys sets the API key for each Supermarket source URL. Keys may be
+// credential references such as env:VAR or keychain:NAME.
+func (f *Factory) SetAPIKeys(apiKeys map[string]string) {
+	f.apiKeys = apiKeys
+}
+
+// SetCapabilityStore enables capability probing of the Supermarket sources
+// the factory creates, caching the results in store
+func (f *Factory) SetCapabilityStore(store CapabilityStore) {
+	f.capabilityStore = store
+}
+
+// SetResponseStore makes the Supermarket sources the factory creates
+// revalidate the API responses kept in store with conditional requests
+func (f *Factory) SetResponseStore(store ResponseStore) {
+	f.responseStore = store
+}
+
+// SetRateLimit limits the requests the Supermarket sources send to each host
+func (f *Factory) SetRateLimit(limit RateLimit) {
+	f.rateLimit = limit
+}
+
+// SetArtifactVerifier makes the Supermarket sources the factory creates
+// verify their downloads with the verifier verifierFor returns for their URL,
+// if any
+func (f *Factory) SetArtifactVerifier(verifierFor func(url string) ArtifactVerifier) {
+	f.verifierFor = verifierFor
+}
+
+// SetChefServerCredentials gives chef_server sources that leave out their
+// URL or client the settings of the profile their profile option names, or
+// defaults when they name none
+func (f *Factory) SetChefServerCredentials(defaults ChefServerCredentials, profiles map[string]ChefServerCredentials) {
+	f.chefDefaults = defaults
+	f.chefProfiles = profiles
+}
+
+// SetFS makes the sources the factory creates read path cookbooks from and
+// extract cookbooks to filesystem instead of the operating system's
+func (f *Factory) SetFS(filesystem fsys.FS) {
+	f.fs = filesystem
+}
+
+// withFS gives src the factory's filesystem, if one is set and src accepts it
+func (f *Factory) withFS(src CookbookSource, err error) (CookbookSource, error) {
+	if err != nil {
+		return nil, err
+	}
+	if setter, ok := src.(FSSetter); ok && f.fs != nil {
+		setter.SetFS(f.fs)
+	}
+	return src, nil
+}
+
+// newChefServerSource creates a Chef Server source, filling in what the
+// source leaves out from the named profile or the default credentials
+func (f *Factory) newChefServerSource(serverURL, clientName, clientKey, profile string) (CookbookSource, error) {
+	credentials := f.chefDefaults
+	if profile != "" {
+		var ok bool
+		if credentials, ok = f.chefProfiles[profile]; !ok {
+			return nil, fmt.Errorf("chef_server source uses unknown profile %q", profile)
+		}
+	}
+	serverURL = cmp.Or(serverURL, credentials.URL)
+	clientName = cmp.Or(clientName, credentials.ClientName)
+	clientKey = cmp.Or(clientKey, credentials.ClientKey)
+
+	if serverURL == "" {
+		return nil, fmt.Errorf("chef_server source requires a URL or a profile")
+	}
+	if clientName == "" || clientKey == "" {
+		return nil, fmt.Errorf("chef_server source requires client_name and client_key options, a profile or chef credentials in the config")
+	}
+	return NewChefServerSource(serverURL, clientName, clientKey)
+}
+
+// newSupermarketSource creates a Supermarket source authenticated with apiKey,
+// or with the key configured for its URL if apiKey is empty
+func (f *Factory) newSupermarketSource(url, apiKey string) (CookbookSource, error) {
+	source := NewSupermarketSource(url)
+	if apiKey == "" {
+		apiKey = f.apiKeys[strings.TrimSuffix(url, "/")]
+		if apiKey == "" {
+			apiKey = f.apiKeys[strings.TrimSuffix(url, "/")+"/"]
+		}
+	}
+	if apiKey != "" {
+		key, err := credentials.Resolve(apiKey)
+		if err != nil {
+			return nil, fmt.Errorf("API key for %s: %w", url, err)
+		}
+		source.SetAPIKey(key)
+	}
+	if f.capabilityStore != nil {
+		source.EnableCapabilityProbe(f.capabilityStore)
+	}
+	source.SetRateLimit(f.rateLimit)
+	if f.responseStore != nil {
+		source.SetResponseStore(f.responseStore)
+	}
+	if f.verifierFor != nil {
+		if verifier := f.verifierFor(url); verifier != nil {
+			source.SetArtifactVerifier(verifier)
+		}
+	}
+	return source, nil
+}
+
 // CreateFromBerksfile creates a Manager with sources from a Berksfile.
 func (f *Factory) CreateFromBerksfile(bf *berksfile.Berksfile) (*Manager, error) {
 	manager := NewManager()
@@ -47,15 +177,30 @@ func (f *Factory) CreateFromBerksfile(bf *berksfile.Berksfile) (*Manager, error)
 
 		// If no defaults either, add the public Supermarket
 		if len(f.defaultSources) == 0 {
-			manager.AddSource(NewSupermarketSource("https://supermarket.chef.io"))
+			source, err := f.withFS(f.newSupermarketSource(PUBLIC_SUPERMARKET, ""))
+			if err != nil {
+				return nil, err
+			}
+			manager.AddSource(source)
 		}
 	}
 
 	return manager, nil
 }
 
+// CreateSource creates a source from a SourceLocation, implementing SourceFactory.
+func (f *Factory) CreateSource(location *berkshelf.SourceLocation) (CookbookSource, error) {
+	return f.CreateFromLocation(location)
+}
+
 // CreateFromLocation creates a source from a SourceLocation.
 func (f *Factory) CreateFromLocation(location *berkshelf.SourceLocation) (CookbookSource, error) {
+	return f.withFS(f.createFromLocation(location))
+}
+
+// createFromLocation creates a source from a SourceLocation, for
+// CreateFromLocation to give the factory's filesystem.
+func (f *Factory) createFromLocation(location *berkshelf.SourceLocation) (CookbookSource, error) {
 	if location == nil {
 		return nil, fmt.Errorf("location cannot be nil")
 	}
@@ -82,27 +227,43 @@ func (f *Factory) CreateFromLocation(location *berkshelf.SourceLocation) (Cookbo
 		if path == "" {
 			path = location.URL
 		}
-		return NewPathSource(path)
+		src, err := NewPathSourceFS(fsys.Or(f.fs), path)
+		if err != nil {
+			return nil, err
+		}
+		if name, ok := location.Options["metadata_name"].(string); ok {
+			src.metadataName = name
+		}
+		return src, nil
 
 	case "supermarket":
 		url := location.URL
 		if url == "" {
 			url = "https://supermarket.chef.io"
 		}
-		return NewSupermarketSource(url), nil
+		apiKey := getStringOption(location.Options, "api_key")
+		if apiKey == "" {
+			apiKey = getStringOption(location.Options, "artifactory_api_key")
+		}
+		return f.newSupermarketSource(url, apiKey)
 
 	case "chef_server":
 		// Extract authentication details from options
-		clientName := getStringOption(location.Options, "client_name")
-		clientKey := getStringOption(location.Options, "client_key")
+		return f.newChefServerSource(location.URL,
+			getStringOption(location.Options, "client_name"),
+			getStringOption(location.Options, "client_key"),
+			getStringOption(location.Options, "profile"))
 
-		if clientName == "" || clientKey == "" {
-			return nil, fmt.Errorf("chef_server source requires client_name and client_key options")
-		}
+	case "s3":
+		return NewS3Source(location.URL, location.Options)
 
-		return NewChefServerSource(location.URL, clientName, clientKey)
+	case "oci":
+		return NewOCISource(location.URL, location.Options)
 
 	default:
+		if src, ok, err := createRegistered(location); ok {
+			return src, err
+		}
 		return nil, fmt.Errorf("unknown source type: %s", location.Type)
 	}
 }
@@ -120,34 +281,61 @@ func getStringOption(options map[string]any, key string) string {
 	return ""
 }
 
+// getBoolOption returns the option key as a bool, which may be given as
+// one or as a string such as "true"
+func getBoolOption(options map[string]any, key string) bool {
+	switch v := options[key].(type) {
+	case bool:
+		return v
+	case string:
+		b, _ := strconv.ParseBool(v)
+		return b
+	}
+	return false
+}
+
 // createFromURL creates a source from a URL string.
 func (f *Factory) createFromURL(uri string) (CookbookSource, error) {
 	// Handle Chef Server URLs with authentication
 	if strings.HasPrefix(uri, "chef_server://") {
-		// Parse chef_server://hostname?client_name=name&client_key=path
-		chefUrl, err := url.Parse(strings.TrimPrefix(uri, "chef_server://"))
+		// Parse chef_server://hostname[:port][/path]?client_name=name&client_key=path,
+		// which is served over HTTPS. The client may instead come from
+		// ?profile=name or the default credentials.
+		chefUrl, err := url.Parse("https://" + strings.TrimPrefix(uri, "chef_server://"))
 		if err != nil {
 			return nil, fmt.Errorf("error parsing %w", err)
 		}
 
 		// Parse query parameters
 		q := chefUrl.Query()
-		clientName := q.Get("client_name")
-		clientKey := q.Get("client_key")
+		chefUrl.RawQuery = ""
 
-		chefUrl.Path = ""
-
-		if clientName == "" || clientKey == "" {
-			return nil, fmt.Errorf("chef_server URL missing client_name or client_key: %s", chefUrl.String())
+		serverURL := chefUrl.String()
+		if chefUrl.Host == "" {
+			serverURL = "" // chef_server://?profile=name
 		}
+		return f.newChefServerSource(serverURL, q.Get("client_name"), q.Get("client_key"), q.Get("profile"))
+	}
+
+	if strings.HasPrefix(uri, "s3://") {
+		return NewS3Source(uri, nil)
+	}
 
-		return NewChefServerSource(chefUrl.String(), clientName, clientKey)
+	if strings.HasPrefix(uri, "oci://") {
+		return NewOCISource(uri, nil)
+	}
+
+	// Registered types handle URLs with their type as the scheme
+	if scheme, _, ok := strings.Cut(uri, "://"); ok {
+		if src, ok, err := createRegistered(&berkshelf.SourceLocation{Type: scheme, URL: uri}); ok {
+			return src, err
+		}
 	}
 
 	// Determine the type of source from the URL
 	if strings.HasPrefix(uri, "http://") || strings.HasPrefix(uri, "https://") {
 		// Assume it's a Supermarket API endpoint
-		return NewSupermarketSource(uri), nil
+		return f.newSupermarketSource(uri, "")
 	}
 
 	if strings.HasPrefix(uri, "git://") || strings.HasPrefix(uri, "git@") {
@@ -163,16 +351,16 @@ func (f *Factory) createFromURL(uri string) (CookbookSource, error) {
 	if strings.HasPrefix(uri, "file://") {
 		// Local path
 		path := strings.TrimPrefix(uri, "file://")
-		return NewPathSource(path)
+		return NewPathSourceFS(fsys.Or(f.fs), path)
 	}
 
 	// Default to Supermarket
-	return NewSupermarketSource(uri), nil
+	return f.newSupermarketSource(uri, "")
 }
 
 // CreateFromURL creates a source from a URL string (public method)
 func (f *Factory) CreateFromURL(url string) (CookbookSource, error) {
-	return f.createFromURL(url)
+	return f.withFS(f.createFromURL(url))
 }
 
 // CreateSourceForCookbook creates appropriate sources for a cookbook definition.
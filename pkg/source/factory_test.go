@@ -1,6 +1,7 @@
 package source
 
 import (
+	"net/url"
 	"os"
 	"testing"
 
@@ -142,6 +143,10 @@ func TestFactory_CreateFromURL(t *testing.T) {
 		{"git://github.com/user/repo.git", "git", false},
 		{"git@github.com:user/repo.git", "git", false},
 		{"file://" + tmpDir, "path", false},
+		{"s3://cookbooks/prod", "s3", false},
+		{"s3:///prod", "s3", true},
+		{"oci://ghcr.io/acme/cookbooks", "oci", false},
+		{"oci://ghcr.io/acme?plain_http=maybe", "oci", true},
 		{"file:///nonexistent/path", "path", true},
 		{"custom-url", "supermarket", false}, // Default
 	}
@@ -160,6 +165,69 @@ func TestFactory_CreateFromURL(t *testing.T) {
 	}
 }
 
+func TestFactory_CreateFromURL_ChefServer(t *testing.T) {
+	keyPath, _ := writeClientKey(t)
+	source, err := NewFactory().createFromURL("chef_server://chef.internal:8443/chef/organizations/acme?client_name=ci&client_key=" + url.QueryEscape(keyPath))
+	if err != nil {
+		t.Fatalf("createFromURL() error = %v", err)
+	}
+	chefServer, ok := source.(*ChefServerSource)
+	if !ok {
+		t.Fatalf("createFromURL() = %T, want *ChefServerSource", source)
+	}
+	// The port and organization path are kept, and the credentials dropped
+	if got, want := chefServer.GetSourceURL(), "https://chef.internal:8443/chef/organizations/acme"; got != want {
+		t.Errorf("GetSourceURL() = %q, want %q", got, want)
+	}
+	if got, want := chefServer.Client().BaseURL.String(), "https://chef.internal:8443/chef/organizations/acme/"; got != want {
+		t.Errorf("API base URL = %q, want %q", got, want)
+	}
+}
+
+func TestFactory_ChefServerProfiles(t *testing.T) {
+	keyPath, _ := writeClientKey(t)
+	factory := NewFactory()
+	factory.SetChefServerCredentials(
+		ChefServerCredentials{URL: "https://chef.example.com/organizations/dev", ClientName: "dev-user", ClientKey: keyPath},
+		map[string]ChefServerCredentials{
+			"prod": {URL: "https://chef-prod.example.com/organizations/acme", ClientName: "deployer", ClientKey: keyPath},
+		})
+
+	tests := []struct {
+		name       string
+		location   *berkshelf.SourceLocation
+		wantURL    string
+		wantClient string
+	}{
+		{"defaults", &berkshelf.SourceLocation{Type: "chef_server", URL: "https://chef.example.com/organizations/qa"}, "https://chef.example.com/organizations/qa", "dev-user"},
+		{"profile", &berkshelf.SourceLocation{Type: "chef_server", Options: map[string]any{"profile": "prod"}}, "https://chef-prod.example.com/organizations/acme", "deployer"},
+		{"explicit client", &berkshelf.SourceLocation{Type: "chef_server", Options: map[string]any{"profile": "prod", "client_name": "ci"}}, "https://chef-prod.example.com/organizations/acme", "ci"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, err := factory.CreateSource(tt.location)
+			if err != nil {
+				t.Fatalf("CreateSource() error = %v", err)
+			}
+			chefServer := source.(*ChefServerSource)
+			if chefServer.GetSourceURL() != tt.wantURL || chefServer.clientName != tt.wantClient {
+				t.Errorf("CreateSource() = %s as %s, want %s as %s", chefServer.GetSourceURL(), chefServer.clientName, tt.wantURL, tt.wantClient)
+			}
+		})
+	}
+
+	source, err := factory.createFromURL("chef_server://?profile=prod")
+	if err != nil || source.GetSourceURL() != "https://chef-prod.example.com/organizations/acme" {
+		t.Errorf("createFromURL(?profile=prod) = %v, %v", source, err)
+	}
+	if _, err := factory.CreateSource(&berkshelf.SourceLocation{Type: "chef_server", Options: map[string]any{"profile": "staging"}}); err == nil {
+		t.Error("CreateSource() with an unknown profile succeeded")
+	}
+	if _, err := NewFactory().CreateSource(&berkshelf.SourceLocation{Type: "chef_server", URL: "https://chef.example.com"}); err == nil {
+		t.Error("CreateSource() without credentials succeeded")
+	}
+}
+
 func TestFactory_AddDefaultSource(t *testing.T) {
 	factory := NewFactory()
 
@@ -186,3 +254,37 @@ func TestFactory_AddDefaultSource(t *testing.T) {
 		t.Errorf("Source name = %s, want internal supermarket", manager.sources[0].Name())
 	}
 }
+
+func TestFactory_APIKeys(t *testing.T) {
+	t.Setenv("TEST_SUPERMARKET_KEY", "secret")
+
+	factory := NewFactory()
+	factory.SetAPIKeys(map[string]string{"https://private.example.com": "env:TEST_SUPERMARKET_KEY"})
+
+	src, err := factory.CreateFromURL("https://private.example.com/")
+	if err != nil {
+		t.Fatalf("CreateFromURL() error = %v", err)
+	}
+	if key := src.(*SupermarketSource).apiKey; key != "secret" {
+		t.Errorf("apiKey = %q, want resolved from environment", key)
+	}
+
+	// An api_key option on the location takes precedence
+	src, err = factory.CreateFromLocation(&berkshelf.SourceLocation{
+		Type:    "supermarket",
+		URL:     "https://private.example.com",
+		Options: map[string]any{"api_key": "literal"},
+	})
+	if err != nil {
+		t.Fatalf("CreateFromLocation() error = %v", err)
+	}
+	if key := src.(*SupermarketSource).apiKey; key != "literal" {
+		t.Errorf("apiKey = %q, want literal option", key)
+	}
+
+	// Unresolvable references are errors rather than unauthenticated requests
+	factory.SetAPIKeys(map[string]string{"https://private.example.com": "env:TEST_MISSING_KEY"})
+	if _, err := factory.CreateFromURL("https://private.example.com"); err == nil {
+		t.Error("CreateFromURL() expected error for unset environment variable")
+	}
+}
@@ -1,23 +1,40 @@
 package source
 
 import (
+	"cmp"
 	"context"
 	"fmt"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
-
-	log "github.com/sirupsen/logrus"
+	"sync"
 
 	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	"github.com/bdwyertech/go-berkshelf/pkg/fsys"
+	"github.com/bdwyertech/go-berkshelf/pkg/logging"
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/go-git/go-git/v5/plumbing/transport"
 	"github.com/go-git/go-git/v5/plumbing/transport/http"
 	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
 )
 
+var log = logging.For("source")
+
+// repoLocks serializes the clones, checkouts and reads of the same cache
+// directory, which several resolutions running at once (as in a workspace
+// install or the resolution service) may share
+var repoLocks sync.Map
+
+// GitCacheDir returns the directory where git repositories are cloned
+func GitCacheDir() string {
+	return filepath.Join(os.TempDir(), "berkshelf-git-cache")
+}
+
 // GitSource implements CookbookSource for Git repositories.
 type GitSource struct {
 	uri      string
@@ -25,9 +42,23 @@ type GitSource struct {
 	tag      string
 	ref      string
 	revision string
-	auth     transport.AuthMethod
-	cacheDir string
-	priority int
+	// resolved is the commit last checked out
+	resolved string
+	// locked is the commit a locked install must check out, see LockRevision
+	locked      string
+	tagPrefixes []string
+	// rel is the cookbook's directory in the repository, for monorepos;
+	// only its tree is extracted
+	rel string
+	// submodules makes extraction initialize and update the repository's
+	// submodules, recursively
+	submodules bool
+	auth       transport.AuthMethod
+	cacheDir   string
+	priority   int
+	// fs is the filesystem cookbooks are copied to, see SetFS; clones are
+	// always kept on disk
+	fs fsys.FS
 }
 
 // NewGitSource creates a new Git source.
@@ -43,13 +74,16 @@ func NewGitSource(uri string, opts *berkshelf.SourceLocation) (*GitSource, error
 	}
 
 	source := &GitSource{
-		uri:      uri,
-		branch:   getStringOption(opts.Options, "branch"),
-		tag:      getStringOption(opts.Options, "tag"),
-		ref:      opts.Ref,
-		revision: getStringOption(opts.Options, "revision"),
-		cacheDir: filepath.Join(os.TempDir(), "berkshelf-git-cache"),
-		priority: 50, // Lower priority than Supermarket
+		uri:         uri,
+		branch:      getStringOption(opts.Options, "branch"),
+		tag:         getStringOption(opts.Options, "tag"),
+		ref:         opts.Ref,
+		revision:    getStringOption(opts.Options, "revision"),
+		tagPrefixes: gitTagPrefixes(opts.Options),
+		rel:         strings.Trim(path.Clean("/"+filepath.ToSlash(opts.Path)), "/"),
+		submodules:  getBoolOption(opts.Options, "submodules"),
+		cacheDir:    GitCacheDir(),
+		priority:    50, // Lower priority than Supermarket
 	}
 
 	// Set up authentication if needed
@@ -105,6 +139,18 @@ func (g *GitSource) setupAuth(opts *berkshelf.SourceLocation) error {
 	return nil
 }
 
+// LockRevision makes the source check out revision, the commit it was
+// locked to, and fail when its ref has moved since or the commit is
+// unreachable
+func (g *GitSource) LockRevision(revision string) {
+	g.locked = revision
+}
+
+// SetFS sets the filesystem cookbooks are copied to
+func (g *GitSource) SetFS(filesystem fsys.FS) {
+	g.fs = filesystem
+}
+
 // Name returns the name of this source.
 func (g *GitSource) Name() string {
 	return fmt.Sprintf("git (%s)", g.uri)
@@ -137,29 +183,74 @@ func (g *GitSource) GetRevision() string {
 
 // getCacheDir returns the cache directory for a specific cookbook.
 func (g *GitSource) getCacheDir(name string) string {
-	// Create a safe directory name from the URI
-	safeName := strings.ReplaceAll(g.uri, "/", "_")
+	return filepath.Join(g.cacheDir, gitCacheName(g.uri), name)
+}
+
+// gitCacheName returns a safe directory name for a repository URI
+func gitCacheName(uri string) string {
+	safeName := strings.ReplaceAll(uri, "/", "_")
 	safeName = strings.ReplaceAll(safeName, ":", "_")
 	safeName = strings.ReplaceAll(safeName, ".", "_")
+	return safeName
+}
 
-	return filepath.Join(g.cacheDir, safeName, name)
+// RemoveGitClones removes the clones under cacheDir of the repository at uri,
+// of the cookbook name, or of both when both are set, and returns how many
+// clones were removed.
+func RemoveGitClones(cacheDir, uri, name string) (int, error) {
+	repos, err := os.ReadDir(cacheDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	removed := 0
+	for _, repo := range repos {
+		if !repo.IsDir() {
+			continue
+		}
+		if uri != "" && repo.Name() != gitCacheName(uri) && repo.Name() != gitCacheName(strings.TrimRight(uri, "/")) {
+			continue
+		}
+
+		clones, err := os.ReadDir(filepath.Join(cacheDir, repo.Name()))
+		if err != nil {
+			return removed, err
+		}
+		for _, clone := range clones {
+			if !clone.IsDir() || (name != "" && clone.Name() != name) {
+				continue
+			}
+			if err := os.RemoveAll(filepath.Join(cacheDir, repo.Name(), clone.Name())); err != nil {
+				return removed, err
+			}
+			removed++
+		}
+	}
+	return removed, nil
 }
 
 // clone clones or updates the repository.
 func (g *GitSource) clone(ctx context.Context, name string) (*git.Repository, error) {
 	cacheDir := g.getCacheDir(name)
+	defer g.lockRepo(name)()
 
 	// Check if already cloned
 	repo, err := git.PlainOpen(cacheDir)
 	if err == nil {
 		// Repository exists, try to fetch updates
-		err = repo.Fetch(&git.FetchOptions{
+		err = repo.FetchContext(ctx, &git.FetchOptions{
 			RemoteName: "origin",
 			Auth:       g.auth,
 		})
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
 		if err != nil && err != git.NoErrAlreadyUpToDate {
 			// If fetch fails, continue with existing clone
-			log.Debugf("Failed to fetch updates for %s: %v", name, err)
+			log.WithField(logging.CookbookField, name).Debugf("Failed to fetch updates for %s: %v", name, err)
 		}
 		return repo, nil
 	}
@@ -169,89 +260,187 @@ func (g *GitSource) clone(ctx context.Context, name string) (*git.Repository, er
 		URL:      g.uri,
 		Auth:     g.auth,
 		Progress: nil, // Could add progress reporting
+		// A cookbook of a monorepo is extracted from its tree, so the rest
+		// of the repository is never checked out
+		NoCheckout: g.rel != "" && !g.submodules,
 	}
 
-	// Ensure cache directory exists
-	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+	// Clone into a staging directory so an interrupted clone is never
+	// opened as the cached repository; this also replaces a broken one
+	staging, err := StagingDir(filepath.Dir(cacheDir), filepath.Base(cacheDir))
+	if err != nil {
 		return nil, fmt.Errorf("creating cache directory: %w", err)
 	}
-
-	repo, err = git.PlainCloneContext(ctx, cacheDir, false, cloneOpts)
-	if err != nil {
+	if _, err := git.PlainCloneContext(ctx, staging, false, cloneOpts); err != nil {
+		os.RemoveAll(staging)
 		return nil, fmt.Errorf("cloning repository: %w", err)
 	}
+	if err := ReplaceDir(staging, cacheDir); err != nil {
+		os.RemoveAll(staging)
+		return nil, fmt.Errorf("caching clone: %w", err)
+	}
+
+	return git.PlainOpen(cacheDir)
+}
 
-	return repo, nil
+// lockRepo locks the cache directory of the repository of name, returning
+// the function that unlocks it
+func (g *GitSource) lockRepo(name string) func() {
+	lock, _ := repoLocks.LoadOrStore(g.getCacheDir(name), &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	return lock.(*sync.Mutex).Unlock
 }
 
-// checkout checks out the specified ref, tag, or branch.
-func (g *GitSource) checkout(repo *git.Repository) error {
+// checkout checks out the commit target returns
+func (g *GitSource) checkout(repo *git.Repository, version *berkshelf.Version) error {
 	w, err := repo.Worktree()
 	if err != nil {
 		return fmt.Errorf("getting worktree: %w", err)
 	}
 
-	// Determine what to checkout
-	var checkoutRef string
-	if g.revision != "" {
-		checkoutRef = g.revision
-	} else if g.ref != "" {
-		checkoutRef = g.ref
-	} else if g.tag != "" {
-		checkoutRef = "refs/tags/" + g.tag
-	} else if g.branch != "" {
-		checkoutRef = "refs/heads/" + g.branch
-	} else {
-		// Default to master/main
-		checkoutRef = "refs/heads/master"
+	hash, requested, err := g.target(repo, version)
+	if err != nil {
+		return err
 	}
 
-	// Try to resolve the reference
-	hash, err := repo.ResolveRevision(plumbing.Revision(checkoutRef))
+	// Checkout the specific commit
+	err = w.Checkout(&git.CheckoutOptions{
+		Hash: hash,
+	})
 	if err != nil {
-		// If it's a branch that doesn't exist locally, try remote reference
-		if g.branch != "" {
-			remoteRef := "refs/remotes/origin/" + g.branch
-			hash, err = repo.ResolveRevision(plumbing.Revision(remoteRef))
-			if err == nil {
-				// Create local branch tracking the remote branch
-				branchRef := plumbing.NewBranchReferenceName(g.branch)
-				ref := plumbing.NewHashReference(branchRef, *hash)
-				err = repo.Storer.SetReference(ref)
-				if err != nil {
-					log.Debugf("Failed to create local branch %s: %v", g.branch, err)
-				}
-				checkoutRef = "refs/heads/" + g.branch
-			}
-		}
+		return fmt.Errorf("checking out %s: %w", requested, err)
+	}
+	g.resolved = hash.String()
 
-		// Try alternative default branch names
-		if checkoutRef == "refs/heads/master" {
-			checkoutRef = "refs/heads/main"
-			hash, err = repo.ResolveRevision(plumbing.Revision(checkoutRef))
-			if err != nil {
-				// Try remote main
-				hash, err = repo.ResolveRevision(plumbing.Revision("refs/remotes/origin/main"))
-			}
-		}
+	return nil
+}
 
+// target returns the commit resolve returns, and what was resolved. A
+// source locked to a revision returns exactly that commit, and fails with
+// an ErrRevisionMismatch when it is unreachable or the requested ref no
+// longer points to it.
+func (g *GitSource) target(repo *git.Repository, version *berkshelf.Version) (plumbing.Hash, string, error) {
+	var locked *plumbing.Hash
+	if g.locked != "" {
+		var err error
+		locked, err = repo.ResolveRevision(plumbing.Revision(g.locked))
+		if err == nil {
+			_, err = repo.CommitObject(*locked)
+		}
 		if err != nil {
-			return fmt.Errorf("resolving ref %s: %w", checkoutRef, err)
+			return plumbing.ZeroHash, g.locked, &ErrRevisionMismatch{URI: g.uri, Ref: g.requested(), Locked: g.locked}
 		}
 	}
+	hash, requested, err := g.resolve(repo, version)
+	if err != nil {
+		return plumbing.ZeroHash, requested, err
+	}
+	if locked != nil && *locked != hash {
+		return plumbing.ZeroHash, requested, &ErrRevisionMismatch{URI: g.uri, Ref: requested, Locked: g.locked, Resolved: hash.String()}
+	}
+	return hash, requested, nil
+}
 
-	// Checkout the specific commit
-	err = w.Checkout(&git.CheckoutOptions{
-		Hash: *hash,
-	})
+// cookbookTree returns the tree of the cookbook's directory in the commit
+// target returns, read from the repository's objects without checking out
+// the rest of the repository
+func (g *GitSource) cookbookTree(repo *git.Repository, version *berkshelf.Version) (*object.Tree, error) {
+	hash, requested, err := g.target(repo, version)
 	if err != nil {
-		return fmt.Errorf("checking out %s: %w", checkoutRef, err)
+		return nil, err
 	}
-	g.revision = hash.String()
+	commit, err := repo.CommitObject(hash)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", requested, err)
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("reading the tree of %s: %w", requested, err)
+	}
+	if g.rel != "" {
+		if tree, err = tree.Tree(g.rel); err != nil {
+			return nil, fmt.Errorf("reading %s of %s: %w", g.rel, requested, err)
+		}
+	}
+	g.resolved = hash.String()
+	return tree, nil
+}
+
+// requested returns the revision, ref, tag or branch the source was
+// configured with, if any
+func (g *GitSource) requested() string {
+	return cmp.Or(g.revision, g.ref, g.tag, g.branch)
+}
 
+// updateSubmodules initializes and updates the submodules of the checked out
+// commit, and theirs, with the source's credentials, when the submodules
+// option is set
+func (g *GitSource) updateSubmodules(ctx context.Context, repo *git.Repository) error {
+	if !g.submodules {
+		return nil
+	}
+	w, err := repo.Worktree()
+	if err != nil {
+		return fmt.Errorf("getting worktree: %w", err)
+	}
+	submodules, err := w.Submodules()
+	if err != nil {
+		return fmt.Errorf("reading submodules: %w", err)
+	}
+	err = submodules.UpdateContext(ctx, &git.SubmoduleUpdateOptions{
+		Init:              true,
+		RecurseSubmodules: git.DefaultSubmoduleRecursionDepth,
+		Auth:              g.auth,
+	})
+	if err != nil {
+		return fmt.Errorf("updating submodules: %w", err)
+	}
 	return nil
 }
 
+// resolve returns the commit the specified revision, ref, tag or branch
+// points to, or else the locked revision, the commit tagged with version, if
+// any, or the head of the default branch, and what was resolved.
+func (g *GitSource) resolve(repo *git.Repository, version *berkshelf.Version) (plumbing.Hash, string, error) {
+	if g.requested() == "" && g.locked == "" && version != nil {
+		if hash, ok := g.taggedCommit(repo, version); ok {
+			return hash, "the tag of version " + version.String(), nil
+		}
+	}
+
+	// Determine what to checkout. Branches resolve to the remote-tracking
+	// branch the last fetch updated, or else the local branch.
+	var candidates []string
+	switch {
+	case g.revision != "":
+		candidates = []string{g.revision}
+	case g.ref != "":
+		candidates = []string{g.ref}
+	case g.tag != "":
+		candidates = []string{"refs/tags/" + g.tag}
+	case g.branch != "":
+		candidates = []string{"refs/remotes/origin/" + g.branch, "refs/heads/" + g.branch}
+	case g.locked != "":
+		// Nothing but the lock names a commit, so there is no ref to have moved
+		candidates = []string{g.locked}
+	default:
+		// Default to master/main
+		candidates = []string{"refs/remotes/origin/master", "refs/heads/master", "refs/remotes/origin/main", "refs/heads/main"}
+	}
+
+	var firstErr error
+	for _, candidate := range candidates {
+		hash, err := repo.ResolveRevision(plumbing.Revision(candidate))
+		if err == nil {
+			return *hash, candidate, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return plumbing.ZeroHash, candidates[0], fmt.Errorf("resolving ref %s: %w", candidates[0], firstErr)
+}
+
 // ListVersions returns available versions (tags) from the Git repository.
 func (g *GitSource) ListVersions(ctx context.Context, name string) ([]*berkshelf.Version, error) {
 	repo, err := g.clone(ctx, name)
@@ -259,24 +448,13 @@ func (g *GitSource) ListVersions(ctx context.Context, name string) ([]*berkshelf
 		return nil, err
 	}
 
-	// List all tags
-	tags, err := repo.Tags()
+	tags, err := g.readTags(repo)
 	if err != nil {
-		return nil, fmt.Errorf("listing tags: %w", err)
+		return nil, err
 	}
-
-	versions := make([]*berkshelf.Version, 0)
-	err = tags.ForEach(func(ref *plumbing.Reference) error {
-		tagName := ref.Name().Short()
-		// Try to parse as version
-		if v, err := berkshelf.NewVersion(tagName); err == nil {
-			versions = append(versions, v)
-		}
-		return nil
-	})
-
-	if err != nil {
-		return nil, fmt.Errorf("iterating tags: %w", err)
+	versions := make([]*berkshelf.Version, 0, len(tags))
+	for _, tag := range tags {
+		versions = append(versions, tag.Version)
 	}
 
 	// If no version tags found but we have a specific ref, return a pseudo-version
@@ -295,8 +473,30 @@ func (g *GitSource) FetchMetadata(ctx context.Context, name string, version *ber
 	if err != nil {
 		return nil, err
 	}
+	// The worktree must stay at version until its metadata is read
+	defer g.lockRepo(name)()
+
+	if g.rel != "" {
+		// Only the cookbook's directory is read, without a checkout
+		tree, err := g.cookbookTree(repo, version)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tree.File("metadata.json"); err != nil {
+			if _, err := tree.File("metadata.rb"); err != nil {
+				return nil, &ErrInvalidMetadata{
+					Name:   name,
+					Reason: "no metadata.json or metadata.rb found in " + g.rel,
+				}
+			}
+		}
+		return &berkshelf.Metadata{
+			Name:    name,
+			Version: version,
+		}, nil
+	}
 
-	if err := g.checkout(repo); err != nil {
+	if err := g.checkout(repo, version); err != nil {
 		return nil, err
 	}
 
@@ -366,21 +566,40 @@ func (g *GitSource) DownloadAndExtractCookbook(ctx context.Context, cookbook *be
 	if err != nil {
 		return fmt.Errorf("cloning repository: %w", err)
 	}
+	// The worktree must stay at the version until its files are copied
+	defer g.lockRepo(cookbook.Name)()
 
-	if err := g.checkout(repo); err != nil {
+	filesystem := fsys.Or(g.fs)
+	if g.rel != "" && !g.submodules {
+		// Only the cookbook's tree is extracted, not the whole monorepo
+		tree, err := g.cookbookTree(repo, cookbook.Version)
+		if err != nil {
+			return fmt.Errorf("checking out version: %w", err)
+		}
+		if err := extractTree(ctx, tree, filesystem, targetDir); err != nil {
+			return fmt.Errorf("extracting cookbook files: %w", err)
+		}
+		cookbook.Path = targetDir
+		return nil
+	}
+
+	if err := g.checkout(repo, cookbook.Version); err != nil {
 		return fmt.Errorf("checking out version: %w", err)
 	}
+	if err := g.updateSubmodules(ctx, repo); err != nil {
+		return err
+	}
 
-	// Get the source directory (repository root)
+	// Get the source directory (the cookbook's directory of the worktree)
 	w, err := repo.Worktree()
 	if err != nil {
 		return fmt.Errorf("getting worktree: %w", err)
 	}
 
-	sourceDir := w.Filesystem.Root()
+	sourceDir := filepath.Join(w.Filesystem.Root(), filepath.FromSlash(g.rel))
 
 	// Create target directory
-	if err := os.MkdirAll(targetDir, 0755); err != nil {
+	if err := filesystem.MkdirAll(targetDir, 0755); err != nil {
 		return fmt.Errorf("creating target directory: %w", err)
 	}
 
@@ -389,6 +608,9 @@ func (g *GitSource) DownloadAndExtractCookbook(ctx context.Context, cookbook *be
 		if err != nil {
 			return err
 		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 
 		// Skip .git directory
 		if strings.Contains(path, ".git") {
@@ -407,11 +629,11 @@ func (g *GitSource) DownloadAndExtractCookbook(ctx context.Context, cookbook *be
 		targetPath := filepath.Join(targetDir, relPath)
 
 		if info.IsDir() {
-			return os.MkdirAll(targetPath, info.Mode())
+			return filesystem.MkdirAll(targetPath, info.Mode())
 		}
 
 		// Copy file
-		return copyFile(path, targetPath, info.Mode())
+		return copyFile(fsys.OS(), path, filesystem, targetPath, info.Mode())
 	})
 
 	if err != nil {
@@ -424,15 +646,59 @@ func (g *GitSource) DownloadAndExtractCookbook(ctx context.Context, cookbook *be
 	return nil
 }
 
-// copyFile copies a file from src to dst with the given mode.
-func copyFile(src, dst string, mode os.FileMode) error {
-	sourceFile, err := os.Open(src)
+// extractTree writes the files of tree below targetDir, with the modes git
+// would check them out with
+func extractTree(ctx context.Context, tree *object.Tree, filesystem fsys.FS, targetDir string) error {
+	if err := filesystem.MkdirAll(targetDir, 0755); err != nil {
+		return err
+	}
+	return tree.Files().ForEach(func(f *object.File) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		targetPath := filepath.Join(targetDir, filepath.FromSlash(f.Name))
+		if err := filesystem.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+			return err
+		}
+
+		if f.Mode == filemode.Symlink {
+			link, err := f.Contents()
+			if err != nil {
+				return err
+			}
+			return fsys.Symlink(filesystem, link, targetPath)
+		}
+
+		mode := os.FileMode(0644)
+		if f.Mode == filemode.Executable {
+			mode = 0755
+		}
+		reader, err := f.Reader()
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+		out, err := filesystem.OpenFile(targetPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, reader); err != nil {
+			out.Close()
+			return err
+		}
+		return out.Close()
+	})
+}
+
+// copyFile copies the file src in from to dst in to with the given mode.
+func copyFile(from fsys.FS, src string, to fsys.FS, dst string, mode os.FileMode) error {
+	sourceFile, err := from.Open(src)
 	if err != nil {
 		return err
 	}
 	defer sourceFile.Close()
 
-	destFile, err := os.Create(dst)
+	destFile, err := to.Create(dst)
 	if err != nil {
 		return err
 	}
@@ -443,7 +709,7 @@ func copyFile(src, dst string, mode os.FileMode) error {
 		return err
 	}
 
-	return os.Chmod(dst, mode)
+	return to.Chmod(dst, mode)
 }
 
 // Search is not implemented for Git sources.
@@ -457,10 +723,12 @@ func (g *GitSource) GetSourceLocation() *berkshelf.SourceLocation {
 		Type: "git",
 		URL:  g.uri,
 		Ref:  g.ref,
+		Path: g.rel,
 	}
 
 	// Add Git-specific options
-	if g.branch != "" || g.tag != "" || g.revision != "" {
+	revision := cmp.Or(g.resolved, g.revision)
+	if g.branch != "" || g.tag != "" || revision != "" || g.submodules {
 		location.Options = make(map[string]any)
 		if g.branch != "" {
 			location.Options["branch"] = g.branch
@@ -468,8 +736,11 @@ func (g *GitSource) GetSourceLocation() *berkshelf.SourceLocation {
 		if g.tag != "" {
 			location.Options["tag"] = g.tag
 		}
-		if g.revision != "" {
-			location.Options["revision"] = g.revision
+		if revision != "" {
+			location.Options["revision"] = revision
+		}
+		if g.submodules {
+			location.Options["submodules"] = true
 		}
 	}
 
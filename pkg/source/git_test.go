@@ -0,0 +1,406 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	berrors "github.com/bdwyertech/go-berkshelf/pkg/errors"
+	"github.com/bdwyertech/go-berkshelf/pkg/fsys"
+)
+
+func TestRemoveGitClones(t *testing.T) {
+	dir := t.TempDir()
+	for _, clone := range []string{
+		"https___github_com_acme_nginx_git/nginx",
+		"https___github_com_acme_nginx_git/nginx_test",
+		"https___github_com_acme_apt_git/apt",
+	} {
+		if err := os.MkdirAll(filepath.Join(dir, clone, ".git"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, err := RemoveGitClones(dir, "https://github.com/acme/nginx.git", "nginx")
+	if err != nil || removed != 1 {
+		t.Fatalf("RemoveGitClones() = %d, %v", removed, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "https___github_com_acme_nginx_git", "nginx_test")); err != nil {
+		t.Errorf("Expected nginx_test clone to remain: %v", err)
+	}
+
+	removed, err = RemoveGitClones(dir, "", "apt")
+	if err != nil || removed != 1 {
+		t.Fatalf("RemoveGitClones() = %d, %v", removed, err)
+	}
+
+	removed, err = RemoveGitClones(filepath.Join(dir, "missing"), "", "apt")
+	if err != nil || removed != 0 {
+		t.Fatalf("RemoveGitClones() on a missing directory = %d, %v", removed, err)
+	}
+}
+
+func TestGitSource_Cancel(t *testing.T) {
+	server := newBlockingServer(t)
+	src, err := NewGitSource(server.URL+"/nginx.git", &berkshelf.SourceLocation{Type: "git"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src.cacheDir = t.TempDir()
+
+	// Cloning
+	assertCancels(t, func(ctx context.Context) error {
+		_, err := src.ListVersions(ctx, "nginx")
+		return err
+	})
+
+	// Fetching into an existing clone
+	repo, err := git.PlainInit(src.getCacheDir("nginx"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{src.uri}}); err != nil {
+		t.Fatal(err)
+	}
+	assertCancels(t, func(ctx context.Context) error {
+		_, err := src.ListVersions(ctx, "nginx")
+		return err
+	})
+}
+
+// commitFile commits content to a file of the repository in dir
+func commitFile(t *testing.T, repo *git.Repository, dir, name, content string) plumbing.Hash {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	w, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Add(name); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := w.Commit("update "+name, &git.CommitOptions{Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Unix(0, 0)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+func TestGitSource_Tags(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := commitFile(t, repo, dir, "metadata.rb", "name 'app'\nversion '1.0.0'\n")
+	second := commitFile(t, repo, dir, "metadata.rb", "name 'app'\nversion '1.1.0'\n")
+	third := commitFile(t, repo, dir, "metadata.rb", "name 'app'\nversion '2.0.0'\n")
+	tagger := &object.Signature{Name: "test", Email: "test@example.com", When: time.Unix(0, 0)}
+	for name, hash := range map[string]plumbing.Hash{"1.0.0": first, "v1.0.0": second, "release-2.0.0": third, "nightly": third} {
+		if _, err := repo.CreateTag(name, hash, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := repo.CreateTag("v1.1.0", second, &git.CreateTagOptions{Tagger: tagger, Message: "1.1.0"}); err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := NewGitSource(dir, &berkshelf.SourceLocation{Type: "git"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src.cacheDir = t.TempDir()
+	ctx := context.Background()
+
+	versions, err := src.ListVersions(ctx, "app")
+	if err != nil {
+		t.Fatalf("ListVersions() error = %v", err)
+	}
+	var got []string
+	for _, v := range versions {
+		got = append(got, v.String())
+	}
+	if strings.Join(got, " ") != "2.0.0 1.1.0 1.0.0" {
+		t.Errorf("ListVersions() = %v, want 2.0.0 1.1.0 1.0.0", got)
+	}
+
+	tags, err := src.Tags(ctx, "app")
+	if err != nil || len(tags) != 3 {
+		t.Fatalf("Tags() = %+v, %v", tags, err)
+	}
+	secondCommit, _ := repo.CommitObject(second)
+	// The annotated tag is peeled to its commit, and an exact version wins over a prefixed one
+	if tags[1].Name != "v1.1.0" || tags[1].Commit != second.String() || tags[1].Tree != secondCommit.TreeHash.String() {
+		t.Errorf("Tags()[1] = %+v, want v1.1.0 at %s", tags[1], second)
+	}
+	if tags[2].Name != "1.0.0" || tags[2].Commit != first.String() {
+		t.Errorf("Tags()[2] = %+v, want 1.0.0 at %s", tags[2], first)
+	}
+
+	// Each version is extracted from the commit its tag points to
+	memory := fsys.Memory()
+	src.SetFS(memory)
+	for _, version := range []string{"1.0.0", "1.1.0"} {
+		cookbook := &berkshelf.Cookbook{Name: "app", Version: berkshelf.MustVersion(version)}
+		if err := src.DownloadAndExtractCookbook(ctx, cookbook, "/vendor/app-"+version); err != nil {
+			t.Fatalf("DownloadAndExtractCookbook(%s) error = %v", version, err)
+		}
+		data, err := fsys.ReadFile(memory, "/vendor/app-"+version+"/metadata.rb")
+		if err != nil || !strings.Contains(string(data), "version '"+version+"'") {
+			t.Errorf("extracted %s metadata.rb = %q, %v", version, data, err)
+		}
+	}
+	if revision := src.GetSourceLocation().Options["revision"]; revision != second.String() {
+		t.Errorf("GetSourceLocation() revision = %v, want %s", revision, second)
+	}
+}
+
+func TestGitSource_LockRevision(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := commitFile(t, repo, dir, "metadata.rb", "name 'app'\nversion '1.0.0'\n")
+	cacheDir := t.TempDir()
+	ctx := context.Background()
+	cookbook := &berkshelf.Cookbook{Name: "app", Version: berkshelf.MustVersion("1.0.0")}
+
+	extract := func(options map[string]any, locked string) error {
+		t.Helper()
+		src, err := NewGitSource(dir, &berkshelf.SourceLocation{Type: "git", Options: options})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The clone is shared, so each source fetches what the last one saw
+		src.cacheDir = cacheDir
+		src.SetFS(fsys.Memory())
+		src.LockRevision(locked)
+		return src.DownloadAndExtractCookbook(ctx, cookbook, "/vendor/app")
+	}
+
+	branch := map[string]any{"branch": "master"}
+	if err := extract(branch, first.String()); err != nil {
+		t.Fatalf("DownloadAndExtractCookbook() at the locked revision error = %v", err)
+	}
+
+	// The branch moves on after it was locked
+	second := commitFile(t, repo, dir, "metadata.rb", "name 'app'\nversion '1.0.0'\n# changed\n")
+	var mismatch *ErrRevisionMismatch
+	if err := extract(branch, first.String()); !errors.As(err, &mismatch) || mismatch.Resolved != second.String() {
+		t.Fatalf("DownloadAndExtractCookbook() of a moved branch error = %v, want a mismatch resolving to %s", err, second)
+	}
+	if mismatch.ErrorType() != berrors.ErrorTypeIntegrity {
+		t.Errorf("ErrorType() = %v, want integrity", mismatch.ErrorType())
+	}
+
+	// A source naming no ref checks out the locked commit as it is
+	if err := extract(nil, first.String()); err != nil {
+		t.Errorf("DownloadAndExtractCookbook() of a locked revision without a ref error = %v", err)
+	}
+
+	unreachable := strings.Repeat("ab", 20)
+	if err := extract(branch, unreachable); !errors.As(err, &mismatch) || mismatch.Resolved != "" || mismatch.Locked != unreachable {
+		t.Errorf("DownloadAndExtractCookbook() of an unreachable revision error = %v", err)
+	}
+}
+
+func TestGitSource_ConcurrentCheckouts(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, version := range []string{"1.0.0", "1.1.0"} {
+		hash := commitFile(t, repo, dir, "metadata.rb", "name 'app'\nversion '"+version+"'\n")
+		if _, err := repo.CreateTag(version, hash, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cacheDir := t.TempDir()
+	ctx := context.Background()
+
+	// Sources of the same repository share its clone and worktree, so each
+	// must see its version checked out until its files are copied
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := range 8 {
+		version := []string{"1.0.0", "1.1.0"}[i%2]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			src, err := NewGitSource(dir, &berkshelf.SourceLocation{Type: "git"})
+			if err != nil {
+				errs <- err
+				return
+			}
+			src.cacheDir = cacheDir
+			memory := fsys.Memory()
+			src.SetFS(memory)
+			cookbook := &berkshelf.Cookbook{Name: "app", Version: berkshelf.MustVersion(version)}
+			if err := src.DownloadAndExtractCookbook(ctx, cookbook, "/vendor/app"); err != nil {
+				errs <- err
+				return
+			}
+			data, err := fsys.ReadFile(memory, "/vendor/app/metadata.rb")
+			if err != nil || !strings.Contains(string(data), "version '"+version+"'") {
+				errs <- fmt.Errorf("extracted %s metadata.rb = %q, %v", version, data, err)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestGitSource_Submodules(t *testing.T) {
+	libDir := t.TempDir()
+	lib, err := git.PlainInit(libDir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	libCommit := commitFile(t, lib, libDir, "helper.rb", "# vendored\n")
+
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commitFile(t, repo, dir, ".gitmodules", "[submodule \"lib\"]\n\tpath = files/lib\n\turl = "+libDir+"\n")
+	// Record the submodule's commit as a gitlink, as git submodule add does
+	idx, err := repo.Storer.Index()
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx.Add("files/lib").Hash = libCommit
+	idx.Entries[len(idx.Entries)-1].Mode = filemode.Submodule
+	if err := repo.Storer.SetIndex(idx); err != nil {
+		t.Fatal(err)
+	}
+	commitFile(t, repo, dir, "metadata.rb", "name 'app'\nversion '1.0.0'\n")
+
+	ctx := context.Background()
+	cookbook := &berkshelf.Cookbook{Name: "app", Version: berkshelf.MustVersion("1.0.0")}
+	for _, submodules := range []bool{false, true} {
+		src, err := NewGitSource(dir, &berkshelf.SourceLocation{Type: "git", Options: map[string]any{"submodules": strconv.FormatBool(submodules)}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		src.cacheDir = t.TempDir()
+		memory := fsys.Memory()
+		src.SetFS(memory)
+		if err := src.DownloadAndExtractCookbook(ctx, cookbook, "/vendor/app"); err != nil {
+			t.Fatalf("DownloadAndExtractCookbook() with submodules %v error = %v", submodules, err)
+		}
+		if got := fsys.Exists(memory, "/vendor/app/files/lib/helper.rb"); got != submodules {
+			t.Errorf("DownloadAndExtractCookbook() with submodules %v extracted the submodule = %v", submodules, got)
+		}
+		if got := src.GetSourceLocation().Options["submodules"] == true; got != submodules {
+			t.Errorf("GetSourceLocation() with submodules %v records submodules = %v", submodules, got)
+		}
+	}
+}
+
+func TestGitSource_Rel(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sub := range []string{"cookbooks/app/recipes", "services/api"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	commitFile(t, repo, dir, "services/api/main.go", "package main\n")
+	commitFile(t, repo, dir, "cookbooks/app/recipes/default.rb", "package 'app'\n")
+	commitFile(t, repo, dir, "cookbooks/app/metadata.rb", "name 'app'\nversion '1.0.0'\n")
+
+	src, err := NewGitSource(dir, &berkshelf.SourceLocation{Type: "git", Path: "./cookbooks/app/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src.cacheDir = t.TempDir()
+	memory := fsys.Memory()
+	src.SetFS(memory)
+	ctx := context.Background()
+	cookbook := &berkshelf.Cookbook{Name: "app", Version: berkshelf.MustVersion("1.0.0")}
+
+	if _, err := src.FetchMetadata(ctx, "app", cookbook.Version); err != nil {
+		t.Fatalf("FetchMetadata() error = %v", err)
+	}
+	if err := src.DownloadAndExtractCookbook(ctx, cookbook, "/vendor/app"); err != nil {
+		t.Fatalf("DownloadAndExtractCookbook() error = %v", err)
+	}
+	for _, file := range []string{"metadata.rb", "recipes/default.rb"} {
+		if !fsys.Exists(memory, "/vendor/app/"+file) {
+			t.Errorf("DownloadAndExtractCookbook() did not extract %s", file)
+		}
+	}
+	if fsys.Exists(memory, "/vendor/app/services") {
+		t.Errorf("DownloadAndExtractCookbook() extracted files outside the cookbook")
+	}
+	// Nothing of the monorepo is checked out in the clone
+	if _, err := os.Stat(filepath.Join(src.getCacheDir("app"), "services")); !os.IsNotExist(err) {
+		t.Errorf("the clone has a checkout of the monorepo")
+	}
+	if location := src.GetSourceLocation(); location.Path != "cookbooks/app" || location.Options["revision"] == nil {
+		t.Errorf("GetSourceLocation() = %+v, want the rel and revision", location)
+	}
+
+	missing, err := NewGitSource(dir, &berkshelf.SourceLocation{Type: "git", Path: "cookbooks/missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	missing.cacheDir = t.TempDir()
+	if _, err := missing.FetchMetadata(ctx, "missing", cookbook.Version); err == nil {
+		t.Errorf("FetchMetadata() of a missing directory did not fail")
+	}
+}
+
+func TestGitTagPrefixes(t *testing.T) {
+	tests := []struct {
+		options map[string]any
+		tag     string
+		want    string
+	}{
+		{nil, "1.2.3", "1.2.3"},
+		{nil, "v1.2.3", "1.2.3"},
+		{nil, "release-1.2", "1.2.0"},
+		{nil, "rel/1.2.3", ""},
+		{nil, "v1.2.3-rc1", ""},
+		{nil, "20240101", ""},
+		{map[string]any{"tag_prefixes": "rel/, cookbook-"}, "rel/1.2.3", "1.2.3"},
+		{map[string]any{"tag_prefixes": []any{"cookbook-"}}, "cookbook-1.2.3", "1.2.3"},
+		{map[string]any{"tag_prefixes": []any{"cookbook-"}}, "v1.2.3", ""},
+		{map[string]any{"tag_prefixes": ""}, "1.2.3", "1.2.3"},
+	}
+	for _, tt := range tests {
+		v, _, err := tagVersion(tt.tag, gitTagPrefixes(tt.options))
+		got := ""
+		if err == nil {
+			got = v.String()
+		}
+		if got != tt.want {
+			t.Errorf("tagVersion(%q, %v) = %q, want %q", tt.tag, tt.options, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package source
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+)
+
+// DefaultGitTagPrefixes are stripped from tag names to read them as
+// versions, unless a git source sets its own with the tag_prefixes option
+var DefaultGitTagPrefixes = []string{"v", "release-"}
+
+// tagVersionPattern is a cookbook version as a tag name holds it once its
+// prefix is stripped
+var tagVersionPattern = regexp.MustCompile(`^\d+\.\d+(\.\d+)?$`)
+
+// GitTag is a version tag of a git repository
+type GitTag struct {
+	// Name is the tag's name, with its prefix
+	Name    string
+	Version *berkshelf.Version
+	// Commit is the hash of the commit the tag points to. Annotated tags
+	// are peeled to their commit.
+	Commit string
+	// Tree is the hash of the commit's tree
+	Tree string
+}
+
+// gitTagPrefixes returns the tag_prefixes option, a list or a comma
+// separated string, or DefaultGitTagPrefixes when it is not set
+func gitTagPrefixes(options map[string]any) []string {
+	value, ok := options["tag_prefixes"]
+	if !ok {
+		return DefaultGitTagPrefixes
+	}
+	var prefixes []string
+	switch v := value.(type) {
+	case string:
+		prefixes = strings.Split(v, ",")
+	case []string:
+		prefixes = v
+	case []any:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				prefixes = append(prefixes, s)
+			}
+		}
+	}
+	cleaned := make([]string, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		if prefix = strings.TrimSpace(prefix); prefix != "" {
+			cleaned = append(cleaned, prefix)
+		}
+	}
+	return cleaned
+}
+
+// tagVersion returns the version tag name holds, as it is or with the first
+// of prefixes it starts with stripped, and whether the prefix was stripped
+func tagVersion(name string, prefixes []string) (*berkshelf.Version, bool, error) {
+	if tagVersionPattern.MatchString(name) {
+		v, err := berkshelf.NewVersion(name)
+		return v, false, err
+	}
+	for _, prefix := range prefixes {
+		if rest, ok := strings.CutPrefix(name, prefix); ok && tagVersionPattern.MatchString(rest) {
+			v, err := berkshelf.NewVersion(rest)
+			return v, true, err
+		}
+	}
+	return nil, false, fmt.Errorf("tag %s is not a version", name)
+}
+
+// Tags returns the version tags of the repository the cookbook name is
+// cloned from, highest version first. When several tags hold the same
+// version, the one without a prefix wins, then the first by name.
+func (g *GitSource) Tags(ctx context.Context, name string) ([]GitTag, error) {
+	repo, err := g.clone(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return g.readTags(repo)
+}
+
+// readTags reads the version tags of repo, see Tags
+func (g *GitSource) readTags(repo *git.Repository) ([]GitTag, error) {
+	refs, err := repo.Tags()
+	if err != nil {
+		return nil, fmt.Errorf("listing tags: %w", err)
+	}
+
+	type candidate struct {
+		tag      GitTag
+		prefixed bool
+	}
+	byVersion := make(map[string]candidate)
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		tagName := ref.Name().Short()
+		version, prefixed, err := tagVersion(tagName, g.tagPrefixes)
+		if err != nil {
+			return nil
+		}
+		commit, err := peelTag(repo, ref.Hash())
+		if err != nil {
+			log.Debugf("Skipping tag %s of %s: %v", tagName, g.uri, err)
+			return nil
+		}
+		found := candidate{
+			tag: GitTag{
+				Name:    tagName,
+				Version: version,
+				Commit:  commit.Hash.String(),
+				Tree:    commit.TreeHash.String(),
+			},
+			prefixed: prefixed,
+		}
+		if existing, ok := byVersion[version.String()]; ok {
+			if (!existing.prefixed && prefixed) || (existing.prefixed == prefixed && existing.tag.Name < tagName) {
+				return nil
+			}
+		}
+		byVersion[version.String()] = found
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("iterating tags: %w", err)
+	}
+
+	tags := make([]GitTag, 0, len(byVersion))
+	for _, c := range byVersion {
+		tags = append(tags, c.tag)
+	}
+	slices.SortFunc(tags, func(a, b GitTag) int {
+		return cmp.Or(b.Version.Compare(a.Version), strings.Compare(a.Name, b.Name))
+	})
+	return tags, nil
+}
+
+// peelTag returns the commit hash points to, through an annotated tag object
+// if it is one
+func peelTag(repo *git.Repository, hash plumbing.Hash) (*object.Commit, error) {
+	tag, err := repo.TagObject(hash)
+	if errors.Is(err, plumbing.ErrObjectNotFound) {
+		return repo.CommitObject(hash)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return tag.Commit()
+}
+
+// taggedCommit returns the commit of the tag holding version in repo
+func (g *GitSource) taggedCommit(repo *git.Repository, version *berkshelf.Version) (plumbing.Hash, bool) {
+	tags, err := g.readTags(repo)
+	if err != nil {
+		log.Debugf("Failed to read the tags of %s: %v", g.uri, err)
+		return plumbing.ZeroHash, false
+	}
+	for _, tag := range tags {
+		if tag.Version.Equal(version) {
+			return plumbing.NewHash(tag.Commit), true
+		}
+	}
+	return plumbing.ZeroHash, false
+}
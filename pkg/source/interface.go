@@ -3,8 +3,11 @@ package source
 
 import (
 	"context"
+	"slices"
+	"time"
 
 	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	"github.com/bdwyertech/go-berkshelf/pkg/fsys"
 )
 
 // CookbookSource defines the interface for fetching cookbooks from various sources.
@@ -40,6 +43,79 @@ type CookbookSource interface {
 	GetSourceURL() string
 }
 
+// Deprecation describes a deprecated cookbook
+type Deprecation struct {
+	// Replacement is the cookbook the maintainers recommend instead, if declared
+	Replacement string
+}
+
+// DeprecationSource is implemented by sources that know whether a cookbook is deprecated.
+type DeprecationSource interface {
+	// Deprecation returns nil if the cookbook is not deprecated.
+	Deprecation(ctx context.Context, name string) (*Deprecation, error)
+}
+
+// ReleaseSource is implemented by sources that know when a cookbook was last released.
+type ReleaseSource interface {
+	// LastReleased returns when the latest version of the cookbook was published.
+	LastReleased(ctx context.Context, name string) (time.Time, error)
+	// Released returns when a version of the cookbook was published.
+	Released(ctx context.Context, name string, version *berkshelf.Version) (time.Time, error)
+}
+
+// RepositorySource is implemented by sources that know where the source
+// code of a cookbook is hosted.
+type RepositorySource interface {
+	// Repository returns the source repository URL, or "" if none is declared.
+	Repository(ctx context.Context, name string) (string, error)
+}
+
+// ChecksumSource is implemented by sources that serve cookbooks as downloadable artifacts.
+type ChecksumSource interface {
+	// Checksum returns the hex SHA-256 of the artifact FetchCookbook located.
+	Checksum(ctx context.Context, cookbook *berkshelf.Cookbook) (string, error)
+}
+
+// FSSetter is implemented by sources that can extract cookbooks to another
+// filesystem than the operating system's, such as an in-memory one in tests.
+type FSSetter interface {
+	// SetFS sets the filesystem cookbooks are written to.
+	SetFS(filesystem fsys.FS)
+}
+
+// RevisionLocker is implemented by sources that can be locked to the
+// revision a lock file recorded, such as git sources.
+type RevisionLocker interface {
+	// LockRevision makes the source fetch exactly revision, failing when
+	// it is unreachable or its ref has moved since it was locked.
+	LockRevision(revision string)
+}
+
+// Wrapper is implemented by sources that wrap another to add behavior, such
+// as caching, so the wrapped source's optional interfaces stay reachable.
+type Wrapper interface {
+	// Unwrap returns the wrapped source.
+	Unwrap() CookbookSource
+}
+
+// As returns the first source in the chain src wraps, starting with src,
+// that implements T, as errors.As does for errors. Callers use it instead
+// of a type assertion to find a source's optional interfaces.
+func As[T any](src CookbookSource) (T, bool) {
+	for src != nil {
+		if t, ok := src.(T); ok {
+			return t, true
+		}
+		wrapper, ok := src.(Wrapper)
+		if !ok {
+			break
+		}
+		src = wrapper.Unwrap()
+	}
+	var zero T
+	return zero, false
+}
+
 // SourceFactory creates a CookbookSource from a SourceLocation.
 type SourceFactory interface {
 	CreateSource(location *berkshelf.SourceLocation) (CookbookSource, error)
@@ -67,7 +143,7 @@ func (m *Manager) GetSources() []CookbookSource {
 	return m.sources
 }
 
-// ListVersions queries all sources for available versions.
+// ListVersions queries all sources for available versions, highest first.
 func (m *Manager) ListVersions(ctx context.Context, name string) ([]*berkshelf.Version, error) {
 	versionMap := make(map[string]*berkshelf.Version)
 
@@ -87,6 +163,7 @@ func (m *Manager) ListVersions(ctx context.Context, name string) ([]*berkshelf.V
 	for _, v := range versionMap {
 		result = append(result, v)
 	}
+	slices.SortFunc(result, func(a, b *berkshelf.Version) int { return b.Compare(a) })
 
 	return result, nil
 }
@@ -0,0 +1,350 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/goccy/go-json"
+	godigest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/registry"
+	"oras.land/oras-go/v2/registry/remote"
+	"oras.land/oras-go/v2/registry/remote/auth"
+	"oras.land/oras-go/v2/registry/remote/credentials"
+	"oras.land/oras-go/v2/registry/remote/errcode"
+	"oras.land/oras-go/v2/registry/remote/retry"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	"github.com/bdwyertech/go-berkshelf/pkg/fsys"
+)
+
+// Media types of a cookbook pushed as an OCI artifact, e.g. with
+//
+//	oras push ghcr.io/acme/cookbooks/nginx:1.2.0 \
+//	  --artifact-type application/vnd.chef.cookbook.v1 \
+//	  nginx-1.2.0.tgz:application/vnd.chef.cookbook.layer.v1.tar+gzip
+const (
+	OCIArtifactType   = "application/vnd.chef.cookbook.v1"
+	OCILayerMediaType = "application/vnd.chef.cookbook.layer.v1.tar+gzip"
+)
+
+// OCISource implements CookbookSource for cookbooks stored as OCI artifacts
+// in a registry such as GHCR or ECR. Each cookbook is a repository below the
+// source's namespace, tagged with its versions. Credentials come from the
+// Docker config and its credential helpers, as for `docker login`.
+type OCISource struct {
+	url       string
+	registry  string
+	namespace string
+	plainHTTP bool
+	client    remote.Client
+	priority  int
+
+	mu       sync.Mutex
+	metadata map[godigest.Digest]*berkshelf.Metadata
+	// fs is the filesystem cookbooks are extracted to, see SetFS
+	fs fsys.FS
+}
+
+// NewOCISource creates a source for oci://registry/namespace. The plain_http
+// option, given in options or as a query parameter of the URL, talks to the
+// registry over HTTP, for local registries.
+func NewOCISource(rawURL string, options map[string]any) (*OCISource, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "oci" || u.Host == "" {
+		return nil, fmt.Errorf("invalid OCI source %q: expected oci://registry/namespace", rawURL)
+	}
+
+	settings := url.Values{}
+	plainHTTP := getStringOption(options, "plain_http")
+	if plainHTTP == "" {
+		plainHTTP = u.Query().Get("plain_http")
+	}
+	if plainHTTP != "" {
+		if _, err := strconv.ParseBool(plainHTTP); err != nil {
+			return nil, fmt.Errorf("invalid OCI source %q: plain_http must be true or false", rawURL)
+		}
+		settings.Set("plain_http", plainHTTP)
+	}
+
+	client := &auth.Client{
+		Client: retry.DefaultClient,
+		Cache:  auth.NewCache(),
+	}
+	if store, err := credentials.NewStoreFromDocker(credentials.StoreOptions{}); err == nil {
+		client.Credential = credentials.Credential(store)
+	} else {
+		log.Debugf("Using %s without credentials: %v", rawURL, err)
+	}
+
+	// The options are kept in the URL so that sources recreated from a
+	// lock file reach the registry the same way
+	canonical := url.URL{Scheme: "oci", Host: u.Host, Path: u.Path, RawQuery: settings.Encode()}
+	return newOCISource(canonical.String(), u.Host, strings.Trim(u.Path, "/"), settings.Get("plain_http") == "true", client), nil
+}
+
+func newOCISource(url, registry, namespace string, plainHTTP bool, client remote.Client) *OCISource {
+	return &OCISource{
+		url:       url,
+		registry:  registry,
+		namespace: namespace,
+		plainHTTP: plainHTTP,
+		client:    client,
+		priority:  100,
+		metadata:  make(map[godigest.Digest]*berkshelf.Metadata),
+	}
+}
+
+// SetFS sets the filesystem cookbooks are extracted to
+func (s *OCISource) SetFS(filesystem fsys.FS) {
+	s.fs = filesystem
+}
+
+// Name returns the name of this source.
+func (s *OCISource) Name() string {
+	return fmt.Sprintf("oci (%s)", s.url)
+}
+
+// Priority returns the priority of this source.
+func (s *OCISource) Priority() int {
+	return s.priority
+}
+
+// SetPriority sets the priority of this source.
+func (s *OCISource) SetPriority(priority int) {
+	s.priority = priority
+}
+
+// repository returns the repository of a cookbook
+func (s *OCISource) repository(name string) *remote.Repository {
+	return &remote.Repository{
+		Client: s.client,
+		Reference: registry.Reference{
+			Registry:   s.registry,
+			Repository: path.Join(s.namespace, name),
+		},
+		PlainHTTP: s.plainHTTP,
+	}
+}
+
+// ListVersions returns the cookbook's tags that are versions.
+func (s *OCISource) ListVersions(ctx context.Context, name string) ([]*berkshelf.Version, error) {
+	tags, err := registry.Tags(ctx, s.repository(name))
+	if err != nil {
+		if errors.Is(err, errdef.ErrNotFound) || isRegistryNotFound(err) {
+			return nil, &ErrCookbookNotFound{Name: name}
+		}
+		return nil, s.unavailable(err)
+	}
+
+	versions := make([]*berkshelf.Version, 0, len(tags))
+	for _, tag := range tags {
+		v, err := berkshelf.NewVersion(tag)
+		if err != nil || v.String() != tag {
+			continue // Skip tags such as latest
+		}
+		versions = append(versions, v)
+	}
+
+	if len(versions) == 0 {
+		return nil, &ErrCookbookNotFound{Name: name}
+	}
+	return versions, nil
+}
+
+// resolve returns the manifest of a cookbook version: its locked digest
+// when given, or else its version tag
+func (s *OCISource) resolve(ctx context.Context, name string, version *berkshelf.Version, locked string) (*remote.Repository, ocispec.Descriptor, error) {
+	repo := s.repository(name)
+	reference := version.String()
+	if locked != "" {
+		if _, err := godigest.Parse(locked); err != nil {
+			return nil, ocispec.Descriptor{}, fmt.Errorf("invalid digest %q locked for %s: %w", locked, name, err)
+		}
+		reference = locked
+	}
+
+	desc, err := repo.Resolve(ctx, reference)
+	if err != nil {
+		if errors.Is(err, errdef.ErrNotFound) || isRegistryNotFound(err) {
+			return nil, ocispec.Descriptor{}, &ErrVersionNotFound{Name: name, Version: version.String()}
+		}
+		return nil, ocispec.Descriptor{}, s.unavailable(err)
+	}
+	return repo, desc, nil
+}
+
+// fetchTarball returns the cookbook tarball of a manifest, verified against
+// its digest
+func (s *OCISource) fetchTarball(ctx context.Context, repo *remote.Repository, manifestDesc ocispec.Descriptor) ([]byte, ocispec.Descriptor, error) {
+	data, err := content.FetchAll(ctx, repo, manifestDesc)
+	if err != nil {
+		return nil, ocispec.Descriptor{}, s.unavailable(err)
+	}
+	var manifest ocispec.Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, ocispec.Descriptor{}, fmt.Errorf("parsing manifest %s: %w", manifestDesc.Digest, err)
+	}
+
+	layer, err := cookbookLayer(manifest.Layers)
+	if err != nil {
+		return nil, ocispec.Descriptor{}, fmt.Errorf("manifest %s: %w", manifestDesc.Digest, err)
+	}
+	tarball, err := content.FetchAll(ctx, repo, layer)
+	if err != nil {
+		return nil, ocispec.Descriptor{}, s.unavailable(err)
+	}
+	return tarball, layer, nil
+}
+
+// cookbookLayer picks the cookbook tarball from a manifest's layers: the
+// one with the cookbook media type, or else its only gzipped tarball
+func cookbookLayer(layers []ocispec.Descriptor) (ocispec.Descriptor, error) {
+	var tarballs []ocispec.Descriptor
+	for _, layer := range layers {
+		if layer.MediaType == OCILayerMediaType {
+			return layer, nil
+		}
+		if strings.HasSuffix(layer.MediaType, "tar+gzip") {
+			tarballs = append(tarballs, layer)
+		}
+	}
+	if len(tarballs) != 1 {
+		return ocispec.Descriptor{}, fmt.Errorf("no %s layer", OCILayerMediaType)
+	}
+	return tarballs[0], nil
+}
+
+// FetchMetadata reads the metadata from the cookbook version's tarball.
+func (s *OCISource) FetchMetadata(ctx context.Context, name string, version *berkshelf.Version) (*berkshelf.Metadata, error) {
+	md, _, err := s.fetchMetadata(ctx, name, version)
+	return md, err
+}
+
+// fetchMetadata returns the metadata and manifest digest of a cookbook version
+func (s *OCISource) fetchMetadata(ctx context.Context, name string, version *berkshelf.Version) (*berkshelf.Metadata, godigest.Digest, error) {
+	repo, desc, err := s.resolve(ctx, name, version, "")
+	if err != nil {
+		return nil, "", err
+	}
+
+	s.mu.Lock()
+	cached, ok := s.metadata[desc.Digest]
+	s.mu.Unlock()
+	if ok {
+		return cached, desc.Digest, nil
+	}
+
+	tarball, _, err := s.fetchTarball(ctx, repo, desc)
+	if err != nil {
+		return nil, "", err
+	}
+	md, err := tarballMetadata(bytes.NewReader(tarball), name)
+	if err != nil {
+		return nil, "", err
+	}
+	if md.Name == "" {
+		md.Name = name
+	}
+	md.Version = version
+
+	s.mu.Lock()
+	s.metadata[desc.Digest] = md
+	s.mu.Unlock()
+	return md, desc.Digest, nil
+}
+
+// FetchCookbook returns the cookbook version, pinned to the digest of the
+// manifest its tag names now.
+func (s *OCISource) FetchCookbook(ctx context.Context, name string, version *berkshelf.Version) (*berkshelf.Cookbook, error) {
+	md, manifestDigest, err := s.fetchMetadata(ctx, name, version)
+	if err != nil {
+		return nil, err
+	}
+
+	return &berkshelf.Cookbook{
+		Name:         name,
+		Version:      version,
+		Metadata:     md,
+		Dependencies: md.Dependencies,
+		Source:       *s.GetSourceLocation(),
+		TarballURL:   "oci://" + s.registry + "/" + path.Join(s.namespace, name) + "@" + manifestDigest.String(),
+		Digest:       manifestDigest.String(),
+	}, nil
+}
+
+// DownloadAndExtractCookbook downloads the cookbook's pinned digest, or its
+// version tag when it has none, and extracts it to the specified directory.
+func (s *OCISource) DownloadAndExtractCookbook(ctx context.Context, cookbook *berkshelf.Cookbook, targetDir string) error {
+	repo, desc, err := s.resolve(ctx, cookbook.Name, cookbook.Version, cookbook.Digest)
+	if err != nil {
+		return fmt.Errorf("downloading cookbook %s version %s: %w", cookbook.Name, cookbook.Version.String(), err)
+	}
+	tarball, _, err := s.fetchTarball(ctx, repo, desc)
+	if err != nil {
+		return fmt.Errorf("downloading cookbook %s version %s: %w", cookbook.Name, cookbook.Version.String(), err)
+	}
+
+	return extractTarball(fsys.Or(s.fs), bytes.NewReader(tarball), cookbook, targetDir)
+}
+
+// Checksum returns the SHA-256 of the cookbook tarball, which the registry
+// addresses it by.
+func (s *OCISource) Checksum(ctx context.Context, cookbook *berkshelf.Cookbook) (string, error) {
+	repo, desc, err := s.resolve(ctx, cookbook.Name, cookbook.Version, cookbook.Digest)
+	if err != nil {
+		return "", err
+	}
+	tarball, layer, err := s.fetchTarball(ctx, repo, desc)
+	if err != nil {
+		return "", err
+	}
+	if layer.Digest.Algorithm() == godigest.SHA256 {
+		return layer.Digest.Encoded(), nil
+	}
+	return godigest.SHA256.FromBytes(tarball).Encoded(), nil
+}
+
+// unavailable wraps a registry error
+func (s *OCISource) unavailable(err error) error {
+	return &ErrSourceUnavailable{Source: s.Name(), Reason: err.Error(), Err: err}
+}
+
+// isRegistryNotFound reports whether a registry rejected a request as
+// naming an unknown repository or manifest
+func isRegistryNotFound(err error) bool {
+	var errResp *errcode.ErrorResponse
+	return errors.As(err, &errResp) && errResp.StatusCode == 404
+}
+
+// Search is not supported by OCI sources.
+func (s *OCISource) Search(ctx context.Context, query string) ([]*berkshelf.Cookbook, error) {
+	return nil, ErrNotImplemented
+}
+
+// GetSourceLocation returns the source location for this OCI source
+func (s *OCISource) GetSourceLocation() *berkshelf.SourceLocation {
+	return &berkshelf.SourceLocation{
+		Type: "oci",
+		URL:  s.url,
+	}
+}
+
+// GetSourceType returns the source type
+func (s *OCISource) GetSourceType() string {
+	return "oci"
+}
+
+// GetSourceURL returns the source URL
+func (s *OCISource) GetSourceURL() string {
+	return s.url
+}
@@ -0,0 +1,196 @@
+package source
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/goccy/go-json"
+	godigest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+)
+
+// fakeRegistry serves cookbook artifacts over the OCI distribution API
+type fakeRegistry struct {
+	tags  map[string]map[string]godigest.Digest // repository → tag → manifest
+	blobs map[godigest.Digest][]byte
+}
+
+// push stores a cookbook tarball as an artifact and tags it
+func (f *fakeRegistry) push(t *testing.T, repo, tag string, tarball []byte) godigest.Digest {
+	t.Helper()
+	layer := ocispec.Descriptor{MediaType: OCILayerMediaType, Digest: godigest.FromBytes(tarball), Size: int64(len(tarball))}
+	config := []byte("{}")
+	manifest, err := json.Marshal(ocispec.Manifest{
+		MediaType:    ocispec.MediaTypeImageManifest,
+		ArtifactType: OCIArtifactType,
+		Config:       ocispec.Descriptor{MediaType: ocispec.MediaTypeEmptyJSON, Digest: godigest.FromBytes(config), Size: 2},
+		Layers:       []ocispec.Descriptor{layer},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifestDigest := godigest.FromBytes(manifest)
+	f.blobs[layer.Digest] = tarball
+	f.blobs[manifestDigest] = manifest
+	if f.tags[repo] == nil {
+		f.tags[repo] = make(map[string]godigest.Digest)
+	}
+	f.tags[repo][tag] = manifestDigest
+	return manifestDigest
+}
+
+func (f *fakeRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/v2/" {
+		return
+	}
+	notFound := func() {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"errors": [{"code": "NAME_UNKNOWN", "message": "repository name not known to registry"}]}`))
+	}
+
+	p := strings.TrimPrefix(r.URL.Path, "/v2/")
+	switch {
+	case strings.HasSuffix(p, "/tags/list"):
+		repo := strings.TrimSuffix(p, "/tags/list")
+		tags, ok := f.tags[repo]
+		if !ok {
+			notFound()
+			return
+		}
+		list := struct {
+			Name string   `json:"name"`
+			Tags []string `json:"tags"`
+		}{Name: repo}
+		for tag := range tags {
+			list.Tags = append(list.Tags, tag)
+		}
+		json.NewEncoder(w).Encode(list)
+
+	case strings.Contains(p, "/manifests/"):
+		repo, ref, _ := strings.Cut(p, "/manifests/")
+		d, ok := f.tags[repo][ref]
+		if !ok {
+			d = godigest.Digest(ref)
+		}
+		data, ok := f.blobs[d]
+		if !ok {
+			notFound()
+			return
+		}
+		w.Header().Set("Content-Type", ocispec.MediaTypeImageManifest)
+		w.Header().Set("Docker-Content-Digest", d.String())
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		if r.Method != http.MethodHead {
+			w.Write(data)
+		}
+
+	case strings.Contains(p, "/blobs/"):
+		_, ref, _ := strings.Cut(p, "/blobs/")
+		data, ok := f.blobs[godigest.Digest(ref)]
+		if !ok {
+			notFound()
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.Write(data)
+
+	default:
+		notFound()
+	}
+}
+
+func TestOCISource(t *testing.T) {
+	reg := &fakeRegistry{tags: map[string]map[string]godigest.Digest{}, blobs: map[godigest.Digest][]byte{}}
+	pinned := reg.push(t, "cookbooks/nginx", "1.0.0", cookbookTarball(t, "nginx", map[string]string{
+		"metadata.json":      `{"name": "nginx", "version": "1.0.0", "dependencies": {"apt": ">= 2.0.0"}}`,
+		"recipes/default.rb": "package 'nginx'\n",
+	}))
+	reg.push(t, "cookbooks/nginx", "1.2.0", cookbookTarball(t, "nginx", map[string]string{
+		"metadata.rb": "name 'nginx'\nversion '1.2.0'\n",
+	}))
+	reg.push(t, "cookbooks/nginx", "latest", cookbookTarball(t, "nginx", map[string]string{}))
+
+	server := httptest.NewServer(reg)
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	src, err := NewOCISource("oci://"+host+"/cookbooks", map[string]any{"plain_http": "true"})
+	if err != nil {
+		t.Fatalf("NewOCISource() error = %v", err)
+	}
+	ctx := context.Background()
+
+	versions, err := src.ListVersions(ctx, "nginx")
+	if err != nil {
+		t.Fatalf("ListVersions() error = %v", err)
+	}
+	if len(versions) != 2 {
+		t.Errorf("ListVersions() = %v, want 1.0.0 and 1.2.0", versions)
+	}
+	if _, err := src.ListVersions(ctx, "apache"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("ListVersions() of a missing cookbook error = %v", err)
+	}
+
+	v100, _ := berkshelf.NewVersion("1.0.0")
+	cookbook, err := src.FetchCookbook(ctx, "nginx", v100)
+	if err != nil {
+		t.Fatalf("FetchCookbook() error = %v", err)
+	}
+	if cookbook.Digest != pinned.String() || cookbook.Source.Type != "oci" || cookbook.Dependencies["apt"].String() != ">= 2.0.0" {
+		t.Errorf("FetchCookbook() = %+v", cookbook)
+	}
+	if cookbook.TarballURL != "oci://"+host+"/cookbooks/nginx@"+pinned.String() {
+		t.Errorf("FetchCookbook() TarballURL = %s", cookbook.TarballURL)
+	}
+	v300, _ := berkshelf.NewVersion("3.0.0")
+	if _, err := src.FetchMetadata(ctx, "nginx", v300); err == nil || !strings.Contains(err.Error(), "version 3.0.0 of cookbook nginx not found") {
+		t.Errorf("FetchMetadata() of a missing version error = %v", err)
+	}
+
+	// The locked digest is downloaded even after the tag moves
+	reg.push(t, "cookbooks/nginx", "1.0.0", cookbookTarball(t, "nginx", map[string]string{
+		"metadata.json":      `{"name": "nginx", "version": "1.0.0"}`,
+		"recipes/default.rb": "package 'nginx-retagged'\n",
+	}))
+	dir := t.TempDir()
+	if err := src.DownloadAndExtractCookbook(ctx, cookbook, dir); err != nil {
+		t.Fatalf("DownloadAndExtractCookbook() error = %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "recipes", "default.rb")); err != nil || string(data) != "package 'nginx'\n" {
+		t.Errorf("extracted recipe = %q, %v, want the pinned artifact", data, err)
+	}
+
+	if checksum, err := src.Checksum(ctx, cookbook); err != nil || len(checksum) != 64 {
+		t.Errorf("Checksum() = %q, %v", checksum, err)
+	}
+
+	cookbook.Digest = "sha256:" + strings.Repeat("0", 64)
+	if err := src.DownloadAndExtractCookbook(ctx, cookbook, t.TempDir()); err == nil {
+		t.Error("DownloadAndExtractCookbook() of an unknown digest succeeded")
+	}
+}
+
+func TestNewOCISource(t *testing.T) {
+	src, err := NewOCISource("oci://localhost:5000/acme/cookbooks/?plain_http=true", nil)
+	if err != nil {
+		t.Fatalf("NewOCISource() error = %v", err)
+	}
+	if src.GetSourceURL() != "oci://localhost:5000/acme/cookbooks/?plain_http=true" || src.namespace != "acme/cookbooks" || !src.plainHTTP {
+		t.Errorf("NewOCISource() = %s with namespace %q", src.GetSourceURL(), src.namespace)
+	}
+
+	for _, url := range []string{"oci:///cookbooks", "https://ghcr.io/acme", "oci://ghcr.io/acme?plain_http=maybe"} {
+		if _, err := NewOCISource(url, nil); err == nil {
+			t.Errorf("NewOCISource(%q) succeeded", url)
+		}
+	}
+}
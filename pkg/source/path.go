@@ -9,32 +9,52 @@ import (
 	"strings"
 
 	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	"github.com/bdwyertech/go-berkshelf/pkg/fsys"
+	"github.com/bdwyertech/go-berkshelf/pkg/metadata"
 )
 
 // PathSource implements CookbookSource for local filesystem paths.
 type PathSource struct {
 	basePath string
 	priority int
+	// metadataName is the metadata name of the cookbook at basePath when it
+	// is provided under another name, e.g. a fork
+	metadataName string
+	// fs is the filesystem cookbooks are read from and copied to
+	fs fsys.FS
 }
 
 // NewPathSource creates a new path-based cookbook source.
 func NewPathSource(path string) (*PathSource, error) {
+	return NewPathSourceFS(fsys.OS(), path)
+}
+
+// NewPathSourceFS creates a path-based cookbook source for path in
+// filesystem.
+func NewPathSourceFS(filesystem fsys.FS, path string) (*PathSource, error) {
+	filesystem = fsys.Or(filesystem)
 	absPath, err := filepath.Abs(path)
 	if err != nil {
 		return nil, fmt.Errorf("resolving path: %w", err)
 	}
 
 	// Check if path exists
-	if _, err := os.Stat(absPath); err != nil {
+	if _, err := filesystem.Stat(absPath); err != nil {
 		return nil, fmt.Errorf("path does not exist: %s", absPath)
 	}
 
 	return &PathSource{
 		basePath: absPath,
 		priority: 200, // Highest priority for local paths
+		fs:       filesystem,
 	}, nil
 }
 
+// SetFS sets the filesystem cookbooks are read from and copied to
+func (p *PathSource) SetFS(filesystem fsys.FS) {
+	p.fs = fsys.Or(filesystem)
+}
+
 // Name returns the name of this source.
 func (p *PathSource) Name() string {
 	return fmt.Sprintf("path (%s)", p.basePath)
@@ -51,13 +71,13 @@ func (p *PathSource) findCookbookPath(name string) (string, error) {
 	if p.isCookbook(p.basePath) {
 		// Check if the cookbook name matches
 		metadata, err := p.ReadMetadata(p.basePath)
-		if err == nil && metadata.Name == name {
+		if err == nil && (metadata.Name == name || (p.metadataName != "" && metadata.Name == p.metadataName)) {
 			return p.basePath, nil
 		}
 	}
 
 	// Check subdirectories
-	entries, err := os.ReadDir(p.basePath)
+	entries, err := fsys.ReadDir(p.fs, p.basePath)
 	if err != nil {
 		return "", fmt.Errorf("reading directory: %w", err)
 	}
@@ -91,10 +111,10 @@ func (p *PathSource) isCookbook(path string) bool {
 	metadataJSON := filepath.Join(path, "metadata.json")
 	metadataRB := filepath.Join(path, "metadata.rb")
 
-	if _, err := os.Stat(metadataJSON); err == nil {
+	if _, err := p.fs.Stat(metadataJSON); err == nil {
 		return true
 	}
-	if _, err := os.Stat(metadataRB); err == nil {
+	if _, err := p.fs.Stat(metadataRB); err == nil {
 		return true
 	}
 
@@ -105,13 +125,13 @@ func (p *PathSource) isCookbook(path string) bool {
 func (p *PathSource) ReadMetadata(cookbookPath string) (*berkshelf.Metadata, error) {
 	// Try metadata.json first
 	metadataPath := filepath.Join(cookbookPath, "metadata.json")
-	if _, err := os.Stat(metadataPath); err == nil {
+	if _, err := p.fs.Stat(metadataPath); err == nil {
 		return p.ReadMetadataJSON(metadataPath)
 	}
 
 	// Try metadata.rb
 	metadataPath = filepath.Join(cookbookPath, "metadata.rb")
-	if _, err := os.Stat(metadataPath); err == nil {
+	if _, err := p.fs.Stat(metadataPath); err == nil {
 		return p.ReadMetadataRB(metadataPath, cookbookPath)
 	}
 
@@ -129,11 +149,13 @@ type metadataJSON struct {
 	Maintainer   string                 `json:"maintainer"`
 	License      string                 `json:"license"`
 	Dependencies map[string]interface{} `json:"dependencies"`
+	ChefVersions [][]string             `json:"chef_versions"`
+	OhaiVersions [][]string             `json:"ohai_versions"`
 }
 
 // ReadMetadataJSON parses a metadata.json file.
 func (p *PathSource) ReadMetadataJSON(path string) (*berkshelf.Metadata, error) {
-	data, err := os.ReadFile(path)
+	data, err := fsys.ReadFile(p.fs, path)
 	if err != nil {
 		return nil, fmt.Errorf("reading metadata.json: %w", err)
 	}
@@ -177,108 +199,53 @@ func (p *PathSource) ReadMetadataJSON(path string) (*berkshelf.Metadata, error)
 		}
 	}
 
-	return &berkshelf.Metadata{
+	result := &berkshelf.Metadata{
 		Name:         meta.Name,
 		Version:      version,
 		Description:  meta.Description,
 		Maintainer:   meta.Maintainer,
 		License:      meta.License,
 		Dependencies: dependencies,
-	}, nil
-}
-
-// ReadMetadataRB parses a metadata.rb file (simplified).
-func (p *PathSource) ReadMetadataRB(path string, cookbookPath string) (*berkshelf.Metadata, error) {
-	// For now, we'll do a very simple parsing of metadata.rb
-	// In a full implementation, we would need a Ruby parser
-
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return nil, fmt.Errorf("reading metadata.rb: %w", err)
 	}
-
-	content := string(data)
-	metadata := &berkshelf.Metadata{
-		Dependencies: make(map[string]*berkshelf.Constraint),
-	}
-
-	// Extract name
-	if matches := extractRubyString(content, "name"); len(matches) > 0 {
-		metadata.Name = matches[0]
-	} else {
-		// Use directory name as fallback
-		metadata.Name = filepath.Base(cookbookPath)
-	}
-
-	// Extract version
-	if matches := extractRubyString(content, "version"); len(matches) > 0 {
-		if v, err := berkshelf.NewVersion(matches[0]); err == nil {
-			metadata.Version = v
+	if len(meta.ChefVersions) > 0 {
+		if constraint, err := berkshelf.NewChefVersionConstraint(meta.ChefVersions); err == nil {
+			result.ChefVersion = constraint
 		}
 	}
-	if metadata.Version == nil {
-		// Default version
-		metadata.Version, _ = berkshelf.NewVersion("0.0.0")
-	}
-
-	// Extract description
-	if matches := extractRubyString(content, "description"); len(matches) > 0 {
-		metadata.Description = matches[0]
-	}
-
-	// Extract maintainer
-	if matches := extractRubyString(content, "maintainer"); len(matches) > 0 {
-		metadata.Maintainer = matches[0]
-	}
-
-	// Extract license
-	if matches := extractRubyString(content, "license"); len(matches) > 0 {
-		metadata.License = matches[0]
-	}
-
-	// Extract dependencies (simplified)
-	lines := strings.Split(content, "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if strings.HasPrefix(line, "depends") {
-			parts := strings.Fields(line)
-			if len(parts) >= 2 {
-				name := strings.Trim(parts[1], `"',`)
-				constraintStr := ">= 0.0.0"
-				if len(parts) >= 3 {
-					constraintStr = strings.Trim(strings.Join(parts[2:], " "), `"',`)
-				}
-
-				if constraint, err := berkshelf.NewConstraint(constraintStr); err == nil {
-					metadata.Dependencies[name] = constraint
-				}
-			}
+	if len(meta.OhaiVersions) > 0 {
+		if constraint, err := berkshelf.NewChefVersionConstraint(meta.OhaiVersions); err == nil {
+			result.OhaiVersion = constraint
 		}
 	}
-
-	return metadata, nil
+	return result, nil
 }
 
-// extractRubyString extracts string values from Ruby code (simplified).
-func extractRubyString(content, key string) []string {
-	var matches []string
-	lines := strings.Split(content, "\n")
-
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if strings.HasPrefix(line, key) && strings.Contains(line, " ") {
-			// Extract the value after the key
-			parts := strings.SplitN(line, " ", 2)
-			if len(parts) == 2 {
-				value := strings.TrimSpace(parts[1])
-				// Remove quotes
-				value = strings.Trim(value, `"'`)
-				matches = append(matches, value)
-			}
+// ReadMetadataRB parses a metadata.rb file.
+func (p *PathSource) ReadMetadataRB(path string, cookbookPath string) (*berkshelf.Metadata, error) {
+	data, err := fsys.ReadFile(p.fs, path)
+	if err != nil {
+		return nil, &ErrInvalidMetadata{
+			Name:   filepath.Base(cookbookPath),
+			Reason: err.Error(),
 		}
 	}
+	parsed, err := metadata.Parse(string(data), path)
+	if err != nil {
+		return nil, &ErrInvalidMetadata{
+			Name:   filepath.Base(cookbookPath),
+			Reason: err.Error(),
+		}
+	}
+	for _, warning := range parsed.Warnings {
+		log.Debugf("%s: %s", path, warning)
+	}
 
-	return matches
+	result := parsed.Berkshelf()
+	if result.Name == "" {
+		// Use directory name as fallback
+		result.Name = filepath.Base(cookbookPath)
+	}
+	return result, nil
 }
 
 // ListVersions returns the versions available in the path source.
@@ -367,14 +334,17 @@ func (p *PathSource) DownloadAndExtractCookbook(ctx context.Context, cookbook *b
 	// Get the vendor root (parent of targetDir)
 	vendorRoot := filepath.Dir(absTargetDir)
 
-	if err := os.MkdirAll(targetDir, 0755); err != nil {
+	if err := p.fs.MkdirAll(targetDir, 0755); err != nil {
 		return fmt.Errorf("creating target directory: %w", err)
 	}
 
-	err = filepath.Walk(sourceDir, func(path string, info os.FileInfo, err error) error {
+	err = fsys.Walk(p.fs, sourceDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 
 		absPath, _ := filepath.Abs(path)
 		// Skip the vendor root directory and anything inside it
@@ -384,6 +354,10 @@ func (p *PathSource) DownloadAndExtractCookbook(ctx context.Context, cookbook *b
 			}
 			return nil
 		}
+		// Test Kitchen's state, where suites may be vendored, is not part of the cookbook
+		if info.IsDir() && info.Name() == ".kitchen" && path != sourceDir {
+			return filepath.SkipDir
+		}
 
 		relPath, err := filepath.Rel(sourceDir, path)
 		if err != nil {
@@ -393,10 +367,10 @@ func (p *PathSource) DownloadAndExtractCookbook(ctx context.Context, cookbook *b
 		targetPath := filepath.Join(targetDir, relPath)
 
 		if info.IsDir() {
-			return os.MkdirAll(targetPath, info.Mode())
+			return p.fs.MkdirAll(targetPath, info.Mode())
 		}
 
-		return copyFile(path, targetPath, info.Mode())
+		return copyFile(p.fs, path, p.fs, targetPath, info.Mode())
 	})
 
 	if err != nil {
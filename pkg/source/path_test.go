@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	"github.com/bdwyertech/go-berkshelf/pkg/fsys"
 )
 
 func TestPathSource_NewPathSource(t *testing.T) {
@@ -246,6 +247,29 @@ func TestPathSource_DirectCookbookPath(t *testing.T) {
 	}
 }
 
+func TestPathSource_MetadataName(t *testing.T) {
+	tmpDir := t.TempDir()
+	os.WriteFile(filepath.Join(tmpDir, "metadata.json"), []byte(`{"name": "nginx", "version": "12.1.0"}`), 0644)
+
+	location := &berkshelf.SourceLocation{Type: "path", Path: tmpDir, Options: map[string]any{"metadata_name": "nginx"}}
+	src, err := NewFactory().CreateFromLocation(location)
+	if err != nil {
+		t.Fatalf("CreateFromLocation() error = %v", err)
+	}
+	cookbook, err := src.FetchCookbook(context.Background(), "internal-nginx", nil)
+	if err != nil {
+		t.Fatalf("FetchCookbook() error = %v", err)
+	}
+	if cookbook.Name != "internal-nginx" || cookbook.Path != tmpDir {
+		t.Errorf("FetchCookbook() = %s at %s, want internal-nginx at %s", cookbook.Name, cookbook.Path, tmpDir)
+	}
+
+	plain, _ := NewPathSource(tmpDir)
+	if _, err := plain.FetchCookbook(context.Background(), "internal-nginx", nil); err == nil {
+		t.Error("Expected a path source without a metadata name to reject another name")
+	}
+}
+
 func TestPathSource_Priority(t *testing.T) {
 	tmpDir, _ := os.MkdirTemp("", "berkshelf-test")
 	defer os.RemoveAll(tmpDir)
@@ -257,6 +281,20 @@ func TestPathSource_Priority(t *testing.T) {
 	}
 }
 
+func TestPathSource_SetFSNil(t *testing.T) {
+	tmpDir := t.TempDir()
+	source, err := NewPathSourceFS(nil, tmpDir)
+	if err != nil {
+		t.Fatalf("NewPathSourceFS(nil) error = %v", err)
+	}
+	source.SetFS(nil)
+
+	// A nil filesystem is the operating system's, not a nil pointer
+	if _, err := source.ListVersions(context.Background(), "missing"); err == nil {
+		t.Errorf("ListVersions() of a missing cookbook did not fail")
+	}
+}
+
 func TestPathSource_Search(t *testing.T) {
 	tmpDir, _ := os.MkdirTemp("", "berkshelf-test")
 	defer os.RemoveAll(tmpDir)
@@ -324,3 +362,66 @@ func TestPathSource_DownloadAndExtractCookbook_NestedVendor(t *testing.T) {
 		t.Error("berks-cookbooks directory should NOT be copied into target")
 	}
 }
+
+func TestPathSource_DownloadAndExtractCookbook_SkipsKitchenState(t *testing.T) {
+	cookbookDir := t.TempDir()
+	os.WriteFile(filepath.Join(cookbookDir, "metadata.rb"), []byte("name 'test-cookbook'\nversion '1.0.0'\n"), 0644)
+	os.MkdirAll(filepath.Join(cookbookDir, ".kitchen", "berkshelf", "other"), 0755)
+	os.WriteFile(filepath.Join(cookbookDir, ".kitchen", "default-ubuntu.yml"), []byte("---\n"), 0644)
+
+	// Vendored for a kitchen suite, into the cookbook's own .kitchen
+	targetDir := filepath.Join(cookbookDir, ".kitchen", "berkshelf", "default", "test-cookbook")
+	source, _ := NewPathSource(cookbookDir)
+	cookbook, err := source.FetchCookbook(context.Background(), "test-cookbook", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := source.DownloadAndExtractCookbook(context.Background(), cookbook, targetDir); err != nil {
+		t.Fatalf("DownloadAndExtractCookbook() error = %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(targetDir, "metadata.rb")); err != nil {
+		t.Error("metadata.rb should exist in target directory")
+	}
+	if _, err := os.Stat(filepath.Join(targetDir, ".kitchen")); err == nil {
+		t.Error(".kitchen should NOT be copied into target")
+	}
+}
+
+func TestPathSource_MemoryFS(t *testing.T) {
+	memory := fsys.Memory()
+	for name, content := range map[string]string{
+		"/cookbooks/base/metadata.rb":        "name 'base'\nversion '1.2.0'\ndepends 'apt', '~> 7.0'\n",
+		"/cookbooks/base/recipes/default.rb": "log 'hello'\n",
+	} {
+		if err := fsys.WriteFile(memory, name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	factory := NewFactory()
+	factory.SetFS(memory)
+	src, err := factory.CreateFromLocation(&berkshelf.SourceLocation{Type: "path", Path: "/cookbooks"})
+	if err != nil {
+		t.Fatalf("CreateFromLocation() error = %v", err)
+	}
+
+	cookbook, err := src.FetchCookbook(context.Background(), "base", nil)
+	if err != nil {
+		t.Fatalf("FetchCookbook() error = %v", err)
+	}
+	if cookbook.Version.String() != "1.2.0" || cookbook.Metadata.Dependencies["apt"] == nil {
+		t.Errorf("FetchCookbook() = %s %s, want 1.2.0 depending on apt", cookbook.Name, cookbook.Version)
+	}
+
+	if err := src.DownloadAndExtractCookbook(context.Background(), cookbook, "/vendor/base"); err != nil {
+		t.Fatalf("DownloadAndExtractCookbook() error = %v", err)
+	}
+	data, err := fsys.ReadFile(memory, "/vendor/base/recipes/default.rb")
+	if err != nil || string(data) != "log 'hello'\n" {
+		t.Errorf("extracted recipe = %q, %v", data, err)
+	}
+	if _, err := os.Stat("/vendor/base"); !os.IsNotExist(err) {
+		t.Errorf("the in-memory path source wrote to disk")
+	}
+}
@@ -0,0 +1,97 @@
+package source
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// sharedTransport pools the connections of all Supermarket sources, so the
+// bursts of a resolution reuse keep-alive connections to each host instead
+// of opening new ones
+var sharedTransport = newPooledTransport()
+
+// newPooledTransport returns the default transport with room for the
+// concurrent requests of a resolution to be kept alive per host
+func newPooledTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = 100
+	transport.MaxIdleConnsPerHost = 32
+	transport.IdleConnTimeout = 90 * time.Second
+	return transport
+}
+
+// RateLimit limits the requests sent to each host with a token bucket
+type RateLimit struct {
+	// RequestsPerSecond is the sustained rate; 0 is no limit
+	RequestsPerSecond float64
+	// Burst is how many requests may be sent at once before the rate applies
+	Burst int
+}
+
+// tokenBucket holds the requests a host can take now. Tokens go negative
+// while requests wait for their turn.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// buckets are shared by every source in the process, so sources for the
+// same host share its limit
+var (
+	bucketsMu sync.Mutex
+	buckets   = map[string]*tokenBucket{}
+)
+
+// wait blocks until host may be sent another request under limit, or ctx
+// is done
+func (limit RateLimit) wait(ctx context.Context, host string) error {
+	if limit.RequestsPerSecond <= 0 {
+		return nil
+	}
+	burst := float64(max(limit.Burst, 1))
+
+	bucketsMu.Lock()
+	now := time.Now()
+	bucket, ok := buckets[host]
+	if !ok {
+		bucket = &tokenBucket{tokens: burst, last: now}
+		buckets[host] = bucket
+	}
+	bucket.tokens = min(burst, bucket.tokens+now.Sub(bucket.last).Seconds()*limit.RequestsPerSecond)
+	bucket.last = now
+	bucket.tokens--
+	deficit := -bucket.tokens
+	bucketsMu.Unlock()
+
+	if deficit <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(time.Duration(deficit / limit.RequestsPerSecond * float64(time.Second)))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		// Give the turn back to the requests queued behind this one
+		bucketsMu.Lock()
+		bucket.tokens++
+		bucketsMu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// rateLimitedTransport sends requests through base under a per-host limit
+type rateLimitedTransport struct {
+	base  http.RoundTripper
+	limit RateLimit
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *rateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.limit.wait(req.Context(), req.URL.Host); err != nil {
+		return nil, err
+	}
+	return t.base.RoundTrip(req)
+}
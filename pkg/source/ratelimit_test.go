@@ -0,0 +1,60 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRateLimit_Burst(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	// Sources for the same host share its bucket
+	limit := RateLimit{RequestsPerSecond: 20, Burst: 2}
+	first, second := NewSupermarketSource(server.URL), NewSupermarketSource(server.URL)
+	first.SetRateLimit(limit)
+	second.SetRateLimit(limit)
+
+	start := time.Now()
+	for _, src := range []*SupermarketSource{first, second, first, second} {
+		_, _ = src.ListVersions(context.Background(), "nginx")
+	}
+	// Two requests go at once; the other two wait 50ms each
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("4 requests took %v, want at least 100ms at 20/s with a burst of 2", elapsed)
+	}
+	if got := requests.Load(); got != 4 {
+		t.Errorf("server saw %d requests, want 4", got)
+	}
+}
+
+func TestRateLimit_Canceled(t *testing.T) {
+	limit := RateLimit{RequestsPerSecond: 0.01, Burst: 1}
+	host := "ratelimit-canceled.example"
+	if err := limit.wait(context.Background(), host); err != nil {
+		t.Fatalf("first request waited: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := limit.wait(ctx, host); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("wait() = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestRateLimit_Unlimited(t *testing.T) {
+	for range 100 {
+		if err := (RateLimit{}).wait(context.Background(), "unlimited.example"); err != nil {
+			t.Fatalf("wait() = %v", err)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package source
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+)
+
+// Constructor creates a source from the location a Berksfile gives for it:
+// the URL, and the other options of the source statement or cookbook
+type Constructor func(location *berkshelf.SourceLocation) (CookbookSource, error)
+
+// builtinTypes are created by the Factory and cannot be registered
+var builtinTypes = []string{"git", "github", "path", "supermarket", "chef_server", "s3", "oci"}
+
+var (
+	registryMu   sync.RWMutex
+	constructors = make(map[string]Constructor)
+)
+
+// Register makes a source type available to Berksfile sources and
+// cookbooks, as in
+//
+//	source artifacts: "artifacts://prod", endpoint: "https://artifacts.example.com"
+//	cookbook "internal", type: "artifacts", url: "artifacts://prod"
+//
+// URLs with the type as their scheme, such as configured default sources,
+// are created with it too. Register is meant to be called from the init
+// function of the package providing the source, and panics if typ is
+// empty, built in or already registered, or if constructor is nil.
+func Register(typ string, constructor Constructor) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	switch {
+	case typ == "":
+		panic("source: Register with an empty type")
+	case constructor == nil:
+		panic("source: Register of " + typ + " with a nil constructor")
+	case slices.Contains(builtinTypes, typ):
+		panic("source: Register of built-in type " + typ)
+	}
+	if _, exists := constructors[typ]; exists {
+		panic("source: Register called twice for type " + typ)
+	}
+	constructors[typ] = constructor
+}
+
+// Registered returns the registered source types, sorted
+func Registered() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	types := make([]string, 0, len(constructors))
+	for typ := range constructors {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// unregister removes a source type, for tests
+func unregister(typ string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	delete(constructors, typ)
+}
+
+// createRegistered creates a source of a registered type. ok is false when
+// the type is not registered.
+func createRegistered(location *berkshelf.SourceLocation) (src CookbookSource, ok bool, err error) {
+	registryMu.RLock()
+	constructor, ok := constructors[location.Type]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, false, nil
+	}
+	src, err = constructor(location)
+	if err != nil {
+		return nil, true, fmt.Errorf("%s source %s: %w", location.Type, location.String(), err)
+	}
+	return src, true, nil
+}
@@ -0,0 +1,87 @@
+package source
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+)
+
+// registerTest registers a source type for the duration of the test. Its
+// sources are Supermarkets at the location's endpoint option.
+func registerTest(t *testing.T, typ string) *[]*berkshelf.SourceLocation {
+	t.Helper()
+	var created []*berkshelf.SourceLocation
+	Register(typ, func(location *berkshelf.SourceLocation) (CookbookSource, error) {
+		created = append(created, location)
+		endpoint, _ := location.Options["endpoint"].(string)
+		if endpoint == "" {
+			return nil, errors.New("endpoint option is required")
+		}
+		return NewSupermarketSource(endpoint), nil
+	})
+	t.Cleanup(func() { unregister(typ) })
+	return &created
+}
+
+func TestRegister(t *testing.T) {
+	created := registerTest(t, "artifacts")
+	if !slices.Contains(Registered(), "artifacts") {
+		t.Errorf("Registered() = %v, want artifacts", Registered())
+	}
+
+	factory := NewFactory()
+	location := &berkshelf.SourceLocation{Type: "artifacts", URL: "artifacts://prod", Options: map[string]any{"endpoint": "https://artifacts.example.com"}}
+	src, err := factory.CreateFromLocation(location)
+	if err != nil {
+		t.Fatalf("CreateFromLocation() error = %v", err)
+	}
+	if src.GetSourceURL() != "https://artifacts.example.com" || len(*created) != 1 || (*created)[0] != location {
+		t.Errorf("CreateFromLocation() = %s, constructor called with %v", src.GetSourceURL(), *created)
+	}
+
+	// Constructor errors name the source
+	_, err = factory.CreateFromLocation(&berkshelf.SourceLocation{Type: "artifacts", URL: "artifacts://prod"})
+	if err == nil || !strings.Contains(err.Error(), "artifacts source artifacts://prod: endpoint option is required") {
+		t.Errorf("CreateFromLocation() without endpoint error = %v", err)
+	}
+
+	// URLs with the registered scheme use the constructor too
+	if _, err := factory.CreateFromURL("artifacts://prod"); err == nil || len(*created) != 3 {
+		t.Errorf("CreateFromURL() error = %v after %d constructor calls, want the constructor's error", err, len(*created))
+	}
+}
+
+func TestRegister_Unknown(t *testing.T) {
+	_, err := NewFactory().CreateFromLocation(&berkshelf.SourceLocation{Type: "artifacts"})
+	if err == nil || !strings.Contains(err.Error(), "unknown source type: artifacts") {
+		t.Errorf("CreateFromLocation() error = %v, want an unknown type", err)
+	}
+}
+
+func TestRegister_Panics(t *testing.T) {
+	registerTest(t, "artifacts")
+	constructor := func(*berkshelf.SourceLocation) (CookbookSource, error) { return nil, nil }
+	tests := []struct {
+		name        string
+		typ         string
+		constructor Constructor
+	}{
+		{"empty type", "", constructor},
+		{"nil constructor", "blobs", nil},
+		{"built-in type", "git", constructor},
+		{"registered twice", "artifacts", constructor},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Register(%q) did not panic", tt.typ)
+				}
+			}()
+			Register(tt.typ, tt.constructor)
+		})
+	}
+}
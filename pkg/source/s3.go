@@ -0,0 +1,321 @@
+package source
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	"github.com/bdwyertech/go-berkshelf/pkg/fsys"
+)
+
+// DefaultS3Layout is where cookbook tarballs are kept below an S3 source's prefix
+const DefaultS3Layout = "{name}/{version}/{name}-{version}.tgz"
+
+// s3Options are the options of an S3 source, given as Berksfile options or
+// as query parameters of its URL
+var s3Options = []string{"region", "endpoint", "profile", "layout"}
+
+// s3API is the part of the S3 client S3Source uses
+type s3API interface {
+	s3.ListObjectsV2APIClient
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+}
+
+// S3Source implements CookbookSource for cookbook tarballs in an S3 bucket,
+// such as those written by `berks package`. Credentials come from the
+// standard AWS chain: the environment, shared config and credentials files,
+// SSO and instance or task roles.
+type S3Source struct {
+	url      string
+	bucket   string
+	prefix   string
+	layout   string
+	client   s3API
+	priority int
+
+	mu       sync.Mutex
+	metadata map[string]*berkshelf.Metadata
+	// fs is the filesystem cookbooks are extracted to, see SetFS
+	fs fsys.FS
+}
+
+// NewS3Source creates a source for s3://bucket/prefix. The options region,
+// endpoint (for S3 compatible stores such as MinIO), profile and layout may
+// be given in options or as query parameters of the URL; options win.
+func NewS3Source(rawURL string, options map[string]any) (*S3Source, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "s3" || u.Host == "" {
+		return nil, fmt.Errorf("invalid S3 source %q: expected s3://bucket/prefix", rawURL)
+	}
+
+	query := u.Query()
+	settings := url.Values{}
+	for _, key := range s3Options {
+		value := getStringOption(options, key)
+		if value == "" {
+			value = query.Get(key)
+		}
+		if value != "" {
+			settings.Set(key, value)
+		}
+	}
+
+	layout := settings.Get("layout")
+	if layout == "" {
+		layout = DefaultS3Layout
+	}
+	if err := validateS3Layout(layout); err != nil {
+		return nil, fmt.Errorf("invalid S3 source %q: %w", rawURL, err)
+	}
+
+	var loadOptions []func(*awsconfig.LoadOptions) error
+	if region := settings.Get("region"); region != "" {
+		loadOptions = append(loadOptions, awsconfig.WithRegion(region))
+	}
+	if profile := settings.Get("profile"); profile != "" {
+		loadOptions = append(loadOptions, awsconfig.WithSharedConfigProfile(profile))
+	}
+	cfg, err := awsconfig.LoadDefaultConfig(context.Background(), loadOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("loading AWS config for %s: %w", rawURL, err)
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if endpoint := settings.Get("endpoint"); endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+			o.UsePathStyle = true
+		}
+	})
+
+	// The options are kept in the URL so that sources recreated from a
+	// lock file find the same objects
+	canonical := url.URL{Scheme: "s3", Host: u.Host, Path: u.Path, RawQuery: settings.Encode()}
+	return newS3Source(canonical.String(), u.Host, strings.Trim(u.Path, "/"), layout, client), nil
+}
+
+func newS3Source(url, bucket, prefix, layout string, client s3API) *S3Source {
+	return &S3Source{
+		url:      url,
+		bucket:   bucket,
+		prefix:   prefix,
+		layout:   layout,
+		client:   client,
+		priority: 100,
+		metadata: make(map[string]*berkshelf.Metadata),
+	}
+}
+
+// validateS3Layout checks that a layout names one object per cookbook version
+func validateS3Layout(layout string) error {
+	if !strings.Contains(layout, "{name}") || !strings.Contains(layout, "{version}") {
+		return fmt.Errorf("layout %q must contain {name} and {version}", layout)
+	}
+	if strings.HasPrefix(layout, "/") {
+		return fmt.Errorf("layout %q is relative to the prefix and cannot start with /", layout)
+	}
+	return nil
+}
+
+// SetFS sets the filesystem cookbooks are extracted to
+func (s *S3Source) SetFS(filesystem fsys.FS) {
+	s.fs = filesystem
+}
+
+// Name returns the name of this source.
+func (s *S3Source) Name() string {
+	return fmt.Sprintf("s3 (%s)", s.url)
+}
+
+// Priority returns the priority of this source.
+func (s *S3Source) Priority() int {
+	return s.priority
+}
+
+// SetPriority sets the priority of this source.
+func (s *S3Source) SetPriority(priority int) {
+	s.priority = priority
+}
+
+// key returns the object key of a cookbook version
+func (s *S3Source) key(name, version string) string {
+	key := strings.NewReplacer("{name}", name, "{version}", version).Replace(s.layout)
+	return path.Join(s.prefix, key)
+}
+
+// ListVersions lists the tarballs of a cookbook under the source's layout.
+func (s *S3Source) ListVersions(ctx context.Context, name string) ([]*berkshelf.Version, error) {
+	// List below the part of the key that precedes the version, and take
+	// the version from each key that has the layout
+	listPrefix, rest, _ := strings.Cut(s.key(name, "\x00"), "\x00")
+	keyPattern, err := regexp.Compile("^" + regexp.QuoteMeta(listPrefix) + "([^/]+?)" +
+		strings.ReplaceAll(regexp.QuoteMeta(rest), "\x00", "[^/]+") + "$")
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []*berkshelf.Version
+	seen := make(map[string]bool)
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(listPrefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, s.unavailable(err)
+		}
+		for _, object := range page.Contents {
+			key := aws.ToString(object.Key)
+			match := keyPattern.FindStringSubmatch(key)
+			if match == nil || seen[match[1]] || s.key(name, match[1]) != key {
+				continue
+			}
+			version, err := berkshelf.NewVersion(match[1])
+			if err != nil {
+				continue // Skip invalid versions
+			}
+			seen[match[1]] = true
+			versions = append(versions, version)
+		}
+	}
+
+	if len(versions) == 0 {
+		return nil, &ErrCookbookNotFound{Name: name}
+	}
+	return versions, nil
+}
+
+// FetchMetadata reads the metadata from the cookbook version's tarball.
+func (s *S3Source) FetchMetadata(ctx context.Context, name string, version *berkshelf.Version) (*berkshelf.Metadata, error) {
+	key := s.key(name, version.String())
+
+	s.mu.Lock()
+	cached, ok := s.metadata[key]
+	s.mu.Unlock()
+	if ok {
+		return cached, nil
+	}
+
+	body, err := s.get(ctx, name, version)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	md, err := tarballMetadata(body, name)
+	if err != nil {
+		return nil, err
+	}
+	if md.Name == "" {
+		md.Name = name
+	}
+	md.Version = version
+
+	s.mu.Lock()
+	s.metadata[key] = md
+	s.mu.Unlock()
+	return md, nil
+}
+
+// FetchCookbook returns the cookbook version, with the metadata of its tarball.
+func (s *S3Source) FetchCookbook(ctx context.Context, name string, version *berkshelf.Version) (*berkshelf.Cookbook, error) {
+	md, err := s.FetchMetadata(ctx, name, version)
+	if err != nil {
+		return nil, err
+	}
+
+	return &berkshelf.Cookbook{
+		Name:         name,
+		Version:      version,
+		Metadata:     md,
+		Dependencies: md.Dependencies,
+		Source:       *s.GetSourceLocation(),
+		TarballURL:   "s3://" + s.bucket + "/" + s.key(name, version.String()),
+	}, nil
+}
+
+// DownloadAndExtractCookbook downloads the cookbook tarball and extracts it to the specified directory.
+func (s *S3Source) DownloadAndExtractCookbook(ctx context.Context, cookbook *berkshelf.Cookbook, targetDir string) error {
+	body, err := s.get(ctx, cookbook.Name, cookbook.Version)
+	if err != nil {
+		return fmt.Errorf("downloading cookbook %s version %s: %w", cookbook.Name, cookbook.Version.String(), err)
+	}
+	defer body.Close()
+
+	return extractTarball(fsys.Or(s.fs), body, cookbook, targetDir)
+}
+
+// Checksum returns the SHA-256 of the cookbook tarball.
+func (s *S3Source) Checksum(ctx context.Context, cookbook *berkshelf.Cookbook) (string, error) {
+	body, err := s.get(ctx, cookbook.Name, cookbook.Version)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, body); err != nil {
+		return "", fmt.Errorf("downloading tarball: %w", err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// get opens the tarball of a cookbook version
+func (s *S3Source) get(ctx context.Context, name string, version *berkshelf.Version) (io.ReadCloser, error) {
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.key(name, version.String())),
+	})
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return nil, &ErrVersionNotFound{Name: name, Version: version.String()}
+		}
+		return nil, s.unavailable(err)
+	}
+	return out.Body, nil
+}
+
+// unavailable wraps an S3 error
+func (s *S3Source) unavailable(err error) error {
+	return &ErrSourceUnavailable{Source: s.Name(), Reason: err.Error(), Err: err}
+}
+
+// Search is not supported by S3 sources.
+func (s *S3Source) Search(ctx context.Context, query string) ([]*berkshelf.Cookbook, error) {
+	return nil, ErrNotImplemented
+}
+
+// GetSourceLocation returns the source location for this S3 source
+func (s *S3Source) GetSourceLocation() *berkshelf.SourceLocation {
+	return &berkshelf.SourceLocation{
+		Type: "s3",
+		URL:  s.url,
+	}
+}
+
+// GetSourceType returns the source type
+func (s *S3Source) GetSourceType() string {
+	return "s3"
+}
+
+// GetSourceURL returns the source URL
+func (s *S3Source) GetSourceURL() string {
+	return s.url
+}
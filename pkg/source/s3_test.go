@@ -0,0 +1,214 @@
+package source
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	"github.com/bdwyertech/go-berkshelf/pkg/fsys"
+)
+
+// fakeS3 serves objects from memory, two keys to a page
+type fakeS3 struct {
+	objects map[string][]byte
+	gets    []string
+}
+
+func (f *fakeS3) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	var keys []string
+	for key := range f.objects {
+		if strings.HasPrefix(key, aws.ToString(params.Prefix)) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	start, _ := strconv.Atoi(aws.ToString(params.ContinuationToken))
+	end := min(start+2, len(keys))
+	out := &s3.ListObjectsV2Output{IsTruncated: aws.Bool(end < len(keys))}
+	if end < len(keys) {
+		out.NextContinuationToken = aws.String(strconv.Itoa(end))
+	}
+	for _, key := range keys[start:end] {
+		out.Contents = append(out.Contents, types.Object{Key: aws.String(key)})
+	}
+	return out, nil
+}
+
+func (f *fakeS3) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	key := aws.ToString(params.Key)
+	f.gets = append(f.gets, key)
+	data, ok := f.objects[key]
+	if !ok {
+		return nil, &types.NoSuchKey{}
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(data))}, nil
+}
+
+// cookbookTarball returns a gzipped tarball of files below a name/ directory
+func cookbookTarball(t *testing.T, name string, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for file, body := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name + "/" + file, Mode: 0644, Size: int64(len(body)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestS3Source(t *testing.T) {
+	client := &fakeS3{objects: map[string][]byte{
+		"prod/nginx/1.0.0/nginx-1.0.0.tgz": cookbookTarball(t, "nginx", map[string]string{
+			"metadata.json":      `{"name": "nginx", "version": "1.0.0", "dependencies": {"apt": ">= 2.0.0"}}`,
+			"recipes/default.rb": "package 'nginx'\n",
+		}),
+		"prod/nginx/1.2.0/nginx-1.2.0.tgz": cookbookTarball(t, "nginx", map[string]string{
+			"metadata.rb": "name 'nginx'\nversion '1.2.0'\ndepends 'apt', '~> 3.0'\n",
+		}),
+		"prod/nginx/2.0.0/nginx-2.0.0.tgz":   cookbookTarball(t, "nginx", map[string]string{}),
+		"prod/nginx/2.0.0/README.md":         []byte("not a tarball"),
+		"prod/nginx-proxy/1.0.0/x-1.0.0.tgz": []byte("another cookbook"),
+		"prod/nginx/latest/nginx-latest.tgz": []byte("not a version"),
+	}}
+	src := newS3Source("s3://cookbooks/prod", "cookbooks", "prod", DefaultS3Layout, client)
+	ctx := context.Background()
+
+	versions, err := src.ListVersions(ctx, "nginx")
+	if err != nil {
+		t.Fatalf("ListVersions() error = %v", err)
+	}
+	var got []string
+	for _, v := range versions {
+		got = append(got, v.String())
+	}
+	if strings.Join(got, " ") != "1.0.0 1.2.0 2.0.0" {
+		t.Errorf("ListVersions() = %v, want 1.0.0 1.2.0 2.0.0", got)
+	}
+	if _, err := src.ListVersions(ctx, "apache"); err == nil {
+		t.Error("ListVersions() of a missing cookbook succeeded")
+	}
+
+	// Metadata comes from metadata.json, or metadata.rb, and is read once
+	v100, _ := berkshelf.NewVersion("1.0.0")
+	cookbook, err := src.FetchCookbook(ctx, "nginx", v100)
+	if err != nil {
+		t.Fatalf("FetchCookbook() error = %v", err)
+	}
+	if cookbook.Dependencies["apt"].String() != ">= 2.0.0" || cookbook.Source.Type != "s3" || cookbook.TarballURL != "s3://cookbooks/prod/nginx/1.0.0/nginx-1.0.0.tgz" {
+		t.Errorf("FetchCookbook() = %+v", cookbook)
+	}
+	if _, err := src.FetchMetadata(ctx, "nginx", v100); err != nil || len(client.gets) != 1 {
+		t.Errorf("FetchMetadata() error = %v after %d gets, want the cached metadata", err, len(client.gets))
+	}
+	v120, _ := berkshelf.NewVersion("1.2.0")
+	if md, err := src.FetchMetadata(ctx, "nginx", v120); err != nil || md.Dependencies["apt"].String() != "~> 3.0" {
+		t.Errorf("FetchMetadata() from metadata.rb = %+v, %v", md, err)
+	}
+	v200, _ := berkshelf.NewVersion("2.0.0")
+	if _, err := src.FetchMetadata(ctx, "nginx", v200); err == nil || !strings.Contains(err.Error(), "no metadata.json or metadata.rb") {
+		t.Errorf("FetchMetadata() without metadata error = %v", err)
+	}
+	v300, _ := berkshelf.NewVersion("3.0.0")
+	if _, err := src.FetchMetadata(ctx, "nginx", v300); err == nil || !strings.Contains(err.Error(), "version 3.0.0 of cookbook nginx not found") {
+		t.Errorf("FetchMetadata() of a missing version error = %v", err)
+	}
+
+	dir := t.TempDir()
+	if err := src.DownloadAndExtractCookbook(ctx, cookbook, dir); err != nil {
+		t.Fatalf("DownloadAndExtractCookbook() error = %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "recipes", "default.rb")); err != nil || string(data) != "package 'nginx'\n" {
+		t.Errorf("extracted recipe = %q, %v", data, err)
+	}
+
+	if checksum, err := src.Checksum(ctx, cookbook); err != nil || len(checksum) != 64 {
+		t.Errorf("Checksum() = %q, %v", checksum, err)
+	}
+}
+
+func TestS3Source_Layout(t *testing.T) {
+	client := &fakeS3{objects: map[string][]byte{
+		"nginx-1.0.0.tgz":       nil,
+		"nginx-1.1.0.tgz":       nil,
+		"nginx-proxy-1.0.0.tgz": nil,
+	}}
+	src := newS3Source("s3://cookbooks?layout=%7Bname%7D-%7Bversion%7D.tgz", "cookbooks", "", "{name}-{version}.tgz", client)
+
+	versions, err := src.ListVersions(context.Background(), "nginx")
+	if err != nil || len(versions) != 2 {
+		t.Errorf("ListVersions() = %v, %v, want 1.0.0 and 1.1.0", versions, err)
+	}
+}
+
+func TestNewS3Source(t *testing.T) {
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+	t.Setenv("AWS_REGION", "")
+
+	src, err := NewS3Source("s3://cookbooks/prod/?region=eu-west-1", map[string]any{"endpoint": "http://localhost:9000"})
+	if err != nil {
+		t.Fatalf("NewS3Source() error = %v", err)
+	}
+	// Options are kept in the URL, for sources recreated from the lock file
+	if src.GetSourceURL() != "s3://cookbooks/prod/?endpoint=http%3A%2F%2Flocalhost%3A9000&region=eu-west-1" || src.prefix != "prod" {
+		t.Errorf("NewS3Source() = %s with prefix %q", src.GetSourceURL(), src.prefix)
+	}
+
+	for _, url := range []string{"s3:///prod", "https://cookbooks/prod", "s3://cookbooks?layout=%7Bname%7D.tgz"} {
+		if _, err := NewS3Source(url, nil); err == nil {
+			t.Errorf("NewS3Source(%q) succeeded", url)
+		}
+	}
+}
+
+func TestS3Source_MemoryFS(t *testing.T) {
+	client := &fakeS3{objects: map[string][]byte{
+		"nginx/1.0.0/nginx-1.0.0.tgz": cookbookTarball(t, "nginx", map[string]string{
+			"metadata.rb":        "name 'nginx'\nversion '1.0.0'\n",
+			"recipes/default.rb": "package 'nginx'\n",
+		}),
+	}}
+	src := newS3Source("s3://cookbooks", "cookbooks", "", DefaultS3Layout, client)
+	memory := fsys.Memory()
+	src.SetFS(memory)
+
+	v100, _ := berkshelf.NewVersion("1.0.0")
+	cookbook, err := src.FetchCookbook(context.Background(), "nginx", v100)
+	if err != nil {
+		t.Fatalf("FetchCookbook() error = %v", err)
+	}
+	if err := src.DownloadAndExtractCookbook(context.Background(), cookbook, "/vendor/nginx"); err != nil {
+		t.Fatalf("DownloadAndExtractCookbook() error = %v", err)
+	}
+	data, err := fsys.ReadFile(memory, "/vendor/nginx/recipes/default.rb")
+	if err != nil || string(data) != "package 'nginx'\n" {
+		t.Errorf("extracted recipe = %q, %v", data, err)
+	}
+	if _, err := os.Stat("/vendor/nginx"); !os.IsNotExist(err) {
+		t.Errorf("the in-memory S3 source wrote to disk")
+	}
+}
@@ -0,0 +1,51 @@
+package source
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+)
+
+// ArtifactVerifier checks the signature of a downloaded cookbook artifact
+// before it is extracted
+type ArtifactVerifier interface {
+	VerifyArtifact(ctx context.Context, name, version, sha256Hex string) error
+}
+
+// SetArtifactVerifier makes the source verify every tarball it downloads
+// with verifier, and refuse the ones that fail
+func (s *SupermarketSource) SetArtifactVerifier(verifier ArtifactVerifier) {
+	s.verifier = verifier
+}
+
+// verifyArtifact verifies the downloaded tarball of cookbook, if the source
+// has a verifier
+func (s *SupermarketSource) verifyArtifact(ctx context.Context, cookbook *berkshelf.Cookbook, tarball string) error {
+	if s.verifier == nil {
+		return nil
+	}
+	f, err := os.Open(tarball)
+	if err != nil {
+		return fmt.Errorf("opening tarball: %w", err)
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return fmt.Errorf("reading tarball: %w", err)
+	}
+	version := ""
+	if cookbook.Version != nil {
+		version = cookbook.Version.String()
+	}
+	if err := s.verifier.VerifyArtifact(ctx, cookbook.Name, version, hex.EncodeToString(hash.Sum(nil))); err != nil {
+		return fmt.Errorf("verifying signature of %s: %w", cookbook.Name, err)
+	}
+	log.Debugf("Verified signature of %s %s", cookbook.Name, version)
+	return nil
+}
@@ -0,0 +1,54 @@
+package source
+
+import (
+	"errors"
+	"io/fs"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/fsys"
+)
+
+// StagingDir creates a unique, hidden directory in parent to extract the
+// cookbook name into before ReplaceDir moves it into place. Staging beside
+// the final location keeps the move a rename on the same filesystem.
+func StagingDir(parent, name string) (string, error) {
+	return StagingDirFS(fsys.OS(), parent, name)
+}
+
+// StagingDirFS is StagingDir on filesystem
+func StagingDirFS(filesystem fsys.FS, parent, name string) (string, error) {
+	if err := filesystem.MkdirAll(parent, 0755); err != nil {
+		return "", err
+	}
+	dir, err := fsys.MkdirTemp(filesystem, parent, "."+name+".staging-")
+	if err != nil {
+		return "", err
+	}
+	if err := filesystem.Chmod(dir, 0755); err != nil {
+		filesystem.RemoveAll(dir)
+		return "", err
+	}
+	return dir, nil
+}
+
+// ReplaceDir moves the fully written staging dir to target with a rename,
+// so target is never seen half written. An existing target is moved aside
+// first and removed afterwards. When a concurrent run puts its own target in
+// place between the two renames, the swap is retried and the last run wins.
+func ReplaceDir(dir, target string) error {
+	return ReplaceDirFS(fsys.OS(), dir, target)
+}
+
+// ReplaceDirFS is ReplaceDir on filesystem
+func ReplaceDirFS(filesystem fsys.FS, dir, target string) error {
+	old := dir + ".old"
+	for attempt := 1; ; attempt++ {
+		if err := filesystem.Rename(target, old); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		err := filesystem.Rename(dir, target)
+		filesystem.RemoveAll(old)
+		if err == nil || !errors.Is(err, fs.ErrExist) || attempt == 3 {
+			return err
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package source
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestReplaceDir(t *testing.T) {
+	parent := t.TempDir()
+	target := filepath.Join(parent, "nginx")
+	if err := os.MkdirAll(target, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(target, "stale.rb"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	staging, err := StagingDir(parent, "nginx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(staging); err != nil || info.Mode().Perm() != 0755 {
+		t.Fatalf("StagingDir() = %v, %v, want a 0755 directory", info, err)
+	}
+	if err := os.WriteFile(filepath.Join(staging, "metadata.rb"), []byte("name 'nginx'\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ReplaceDir(staging, target); err != nil {
+		t.Fatalf("ReplaceDir() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(target, "metadata.rb")); err != nil {
+		t.Errorf("ReplaceDir() did not move the staged files: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(target, "stale.rb")); !os.IsNotExist(err) {
+		t.Errorf("ReplaceDir() kept a file of the replaced directory")
+	}
+	assertOnlyEntries(t, parent, "nginx")
+}
+
+func TestReplaceDir_Concurrent(t *testing.T) {
+	parent := t.TempDir()
+	target := filepath.Join(parent, "nginx")
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			staging, err := StagingDir(parent, "nginx")
+			if err != nil {
+				errs <- err
+				return
+			}
+			for _, name := range []string{"metadata.rb", "README.md"} {
+				if err := os.WriteFile(filepath.Join(staging, name), []byte(fmt.Sprint(i)), 0644); err != nil {
+					errs <- err
+					return
+				}
+			}
+			errs <- ReplaceDir(staging, target)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("ReplaceDir() error = %v", err)
+		}
+	}
+
+	// Whichever run won, its cookbook is complete
+	metadata, err := os.ReadFile(filepath.Join(target, "metadata.rb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	readme, err := os.ReadFile(filepath.Join(target, "README.md"))
+	if err != nil || string(readme) != string(metadata) {
+		t.Errorf("target mixes runs: metadata.rb %q, README.md %q", metadata, readme)
+	}
+	assertOnlyEntries(t, parent, "nginx")
+}
+
+// assertOnlyEntries fails if dir holds anything but names, such as
+// leftover staging directories
+func assertOnlyEntries(t *testing.T, dir string, names ...string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, entry := range entries {
+		got = append(got, entry.Name())
+	}
+	if fmt.Sprint(got) != fmt.Sprint(names) {
+		t.Errorf("%s holds %v, want %v", dir, got, names)
+	}
+}
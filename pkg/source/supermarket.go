@@ -1,21 +1,29 @@
 package source
 
 import (
-	"archive/tar"
-	"compress/gzip"
+	"bytes"
+	"cmp"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/goccy/go-json"
 
 	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	"github.com/bdwyertech/go-berkshelf/pkg/digest"
+	"github.com/bdwyertech/go-berkshelf/pkg/fsys"
+	"github.com/bdwyertech/go-berkshelf/pkg/logging"
 )
 
 // SupermarketSource implements CookbookSource for Chef Supermarket API.
@@ -24,8 +32,27 @@ type SupermarketSource struct {
 	httpClient *http.Client
 	apiKey     string
 	priority   int
+	details    sync.Map // cookbook name -> *cookbookResponse
+
+	probe           bool
+	probeOnce       sync.Once
+	capabilities    Capabilities
+	capabilityStore CapabilityStore
+	responses       ResponseStore
+	verifier        ArtifactVerifier
+
+	universeMu sync.Mutex
+	universe   universe
+
+	downloadDir string
+	// fs is the filesystem cookbooks are extracted to, see SetFS
+	fs fsys.FS
 }
 
+// checksumHeader carries the SHA-256 of a download on Artifactory and mirrors
+// that follow its convention
+const checksumHeader = "X-Checksum-Sha256"
+
 // NewSupermarketSource creates a new Supermarket source.
 func NewSupermarketSource(baseURL string) *SupermarketSource {
 	if baseURL == "" {
@@ -35,7 +62,8 @@ func NewSupermarketSource(baseURL string) *SupermarketSource {
 	return &SupermarketSource{
 		baseURL: baseURL,
 		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
+			Timeout:   30 * time.Second,
+			Transport: sharedTransport,
 		},
 		priority: 100, // Default priority
 	}
@@ -46,6 +74,28 @@ func (s *SupermarketSource) SetAPIKey(key string) {
 	s.apiKey = key
 }
 
+// SetRateLimit limits the requests sent to the source's host, shared with
+// every other source for the same host. A zero limit removes it.
+func (s *SupermarketSource) SetRateLimit(limit RateLimit) {
+	if limit.RequestsPerSecond <= 0 {
+		s.httpClient.Transport = sharedTransport
+		return
+	}
+	s.httpClient.Transport = &rateLimitedTransport{base: sharedTransport, limit: limit}
+}
+
+// authorize adds the API key, if any, to req
+func (s *SupermarketSource) authorize(req *http.Request) {
+	if s.apiKey != "" {
+		req.Header.Set("X-Ops-Userid", s.apiKey)
+	}
+}
+
+// SetFS sets the filesystem cookbooks are extracted to
+func (s *SupermarketSource) SetFS(filesystem fsys.FS) {
+	s.fs = filesystem
+}
+
 // Name returns the name of this source.
 func (s *SupermarketSource) Name() string {
 	return fmt.Sprintf("supermarket (%s)", s.baseURL)
@@ -63,94 +113,120 @@ func (s *SupermarketSource) SetPriority(priority int) {
 
 // cookbookResponse represents the API response for a cookbook.
 type cookbookResponse struct {
-	Name            string                 `json:"name"`
-	Maintainer      string                 `json:"maintainer"`
-	Description     string                 `json:"description"`
-	Category        string                 `json:"category"`
-	LatestVersion   string                 `json:"latest_version"`
-	ExternalURL     string                 `json:"external_url"`
-	SourceURL       string                 `json:"source_url"`
-	IssuesURL       string                 `json:"issues_url"`
-	Deprecated      bool                   `json:"deprecated"`
-	Versions        []string               `json:"versions"`
-	VersionsDetails map[string]versionInfo `json:"versions_details"`
-}
-
-// versionInfo contains information about a specific version.
-type versionInfo struct {
-	Version      string            `json:"version"`
-	TarballURL   string            `json:"tarball_file_url"`
-	Dependencies map[string]string `json:"dependencies"`
+	Name          string      `json:"name"`
+	Maintainer    string      `json:"maintainer"`
+	Description   string      `json:"description"`
+	Category      string      `json:"category"`
+	LatestVersion string      `json:"latest_version"`
+	ExternalURL   string      `json:"external_url"`
+	SourceURL     string      `json:"source_url"`
+	IssuesURL     string      `json:"issues_url"`
+	Deprecated    bool        `json:"deprecated"`
+	Replacement   string      `json:"replacement"`
+	Versions      versionList `json:"versions"`
+}
+
+// versionList is the versions of a cookbook response, parsed from their URLs
+// one at a time as they are decoded, so the URLs of a cookbook with
+// thousands of versions are never held at once. Invalid URLs and versions
+// are skipped.
+type versionList []*berkshelf.Version
+
+func (l *versionList) UnmarshalJSON(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if token, err := decoder.Token(); err != nil || token == nil {
+		return err // null lists no versions
+	}
+	for decoder.More() {
+		var versionURL string
+		if err := decoder.Decode(&versionURL); err != nil {
+			return err
+		}
+		if v, err := parseVersionURL(versionURL); err == nil {
+			*l = append(*l, v)
+		}
+	}
+	_, err := decoder.Token()
+	return err
 }
 
 // ListVersions returns all available versions of a cookbook.
 func (s *SupermarketSource) ListVersions(ctx context.Context, name string) ([]*berkshelf.Version, error) {
-	endpoint := fmt.Sprintf("%s/api/v1/cookbooks/%s", s.baseURL, url.PathEscape(name))
+	if s.useUniverse(ctx) {
+		return s.universeVersions(ctx, name)
+	}
 
-	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	cookbook, err := s.fetchCookbookDetails(ctx, name)
 	if err != nil {
-		return nil, fmt.Errorf("creating request: %w", err)
+		return nil, err
 	}
 
-	if s.apiKey != "" {
-		req.Header.Set("X-Ops-Userid", s.apiKey)
+	// The details are cached, so callers get their own slice
+	return slices.Clone([]*berkshelf.Version(cookbook.Versions)), nil
+}
+
+// useUniverse reports whether lookups should use /universe because the
+// source has no per-cookbook API, as on static mirrors
+func (s *SupermarketSource) useUniverse(ctx context.Context) bool {
+	caps := s.Capabilities(ctx)
+	return !caps.CookbooksAPI && caps.Universe
+}
+
+// fetchCookbookDetails returns the cookbook-level API response, which is cached
+// per source since it is shared by every version of the cookbook.
+func (s *SupermarketSource) fetchCookbookDetails(ctx context.Context, name string) (*cookbookResponse, error) {
+	if cached, ok := s.details.Load(name); ok {
+		return cached.(*cookbookResponse), nil
 	}
 
-	resp, err := s.httpClient.Do(req)
+	endpoint := fmt.Sprintf("%s/api/v1/cookbooks/%s", s.baseURL, url.PathEscape(name))
+
+	status, body, err := s.get(ctx, endpoint)
 	if err != nil {
-		return nil, &ErrSourceUnavailable{Source: s.Name(), Reason: err.Error()}
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode == http.StatusNotFound {
+	if status == http.StatusNotFound {
 		return nil, &ErrCookbookNotFound{Name: name}
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("supermarket API error: %d %s", resp.StatusCode, string(body))
+	if status != http.StatusOK {
+		return nil, apiError(status, body)
 	}
 
 	var cookbook cookbookResponse
-	if err := json.NewDecoder(resp.Body).Decode(&cookbook); err != nil {
+	if err := json.Unmarshal(body, &cookbook); err != nil {
 		return nil, fmt.Errorf("decoding response: %w", err)
 	}
 
-	versions := make([]*berkshelf.Version, 0, len(cookbook.Versions))
-	for _, versionURL := range cookbook.Versions {
-		// Extract version from URL path (e.g., ".../versions/9.2.1" -> "9.2.1")
-		u, err := url.Parse(versionURL)
-		if err != nil {
-			continue // Skip invalid URLs
-		}
-
-		// Extract version from path: /api/v1/cookbooks/name/versions/VERSION
-		pathParts := strings.Split(u.Path, "/")
-		if len(pathParts) < 2 {
-			continue // Skip malformed paths
-		}
-		versionStr := pathParts[len(pathParts)-1]
+	s.details.Store(name, &cookbook)
+	return &cookbook, nil
+}
 
-		v, err := berkshelf.NewVersion(versionStr)
-		if err != nil {
-			continue // Skip invalid versions
-		}
-		versions = append(versions, v)
+// apiError describes an unexpected Supermarket API response. A rejected
+// request wraps ErrAuthenticationRequired.
+func apiError(status int, body []byte) error {
+	if status == http.StatusUnauthorized || status == http.StatusForbidden {
+		return fmt.Errorf("supermarket API error: %d %s: %w", status, body, ErrAuthenticationRequired)
 	}
-
-	return versions, nil
+	return fmt.Errorf("supermarket API error: %d %s", status, body)
 }
 
 // cookbookVersionResponse represents the API response for a specific cookbook version.
 type cookbookVersionResponse struct {
 	Version      string            `json:"version"`
+	License      string            `json:"license"`
 	FileURL      string            `json:"file"`
 	Dependencies map[string]string `json:"dependencies"`
+	Platforms    map[string]string `json:"platforms"`
 	Attributes   []string          `json:"attributes"`
 	Recipes      []recipeInfo      `json:"recipes"`
 	Resources    []string          `json:"resources"`
 	Providers    []string          `json:"providers"`
 	RootFiles    []fileInfo        `json:"root_files"`
+	ChefVersions [][]string        `json:"chef_versions"`
+	OhaiVersions [][]string        `json:"ohai_versions"`
+	PublishedAt  time.Time         `json:"published_at"`
 }
 
 type recipeInfo struct {
@@ -164,95 +240,201 @@ type fileInfo struct {
 	Checksum string `json:"checksum"`
 }
 
+// Deprecation reports whether a cookbook is deprecated and its declared replacement.
+func (s *SupermarketSource) Deprecation(ctx context.Context, name string) (*Deprecation, error) {
+	if !s.Capabilities(ctx).CookbooksAPI {
+		return nil, nil
+	}
+	details, err := s.fetchCookbookDetails(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if !details.Deprecated {
+		return nil, nil
+	}
+
+	// The replacement is given as the API URL of the replacement cookbook
+	replacement := details.Replacement
+	if u, err := url.Parse(replacement); err == nil && u.Path != "" {
+		replacement = path.Base(strings.TrimSuffix(u.Path, "/"))
+	}
+	return &Deprecation{Replacement: replacement}, nil
+}
+
+// Repository returns the source_url declared for a cookbook. Sources without
+// the per-cookbook API cannot tell and return "".
+func (s *SupermarketSource) Repository(ctx context.Context, name string) (string, error) {
+	if !s.Capabilities(ctx).CookbooksAPI {
+		return "", nil
+	}
+	details, err := s.fetchCookbookDetails(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	return details.SourceURL, nil
+}
+
+// LastReleased returns when the latest version of a cookbook was published.
+// Sources without the per-cookbook API cannot tell and return ErrNotImplemented.
+func (s *SupermarketSource) LastReleased(ctx context.Context, name string) (time.Time, error) {
+	if !s.Capabilities(ctx).CookbooksAPI {
+		return time.Time{}, ErrNotImplemented
+	}
+
+	details, err := s.fetchCookbookDetails(ctx, name)
+	if err != nil {
+		return time.Time{}, err
+	}
+	latest, err := parseVersionURL(details.LatestVersion)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid latest version for %s: %w", name, err)
+	}
+	return s.Released(ctx, name, latest)
+}
+
+// Released returns when a version of a cookbook was published. Sources
+// without the per-cookbook API cannot tell and return ErrNotImplemented.
+func (s *SupermarketSource) Released(ctx context.Context, name string, version *berkshelf.Version) (time.Time, error) {
+	if !s.Capabilities(ctx).CookbooksAPI {
+		return time.Time{}, ErrNotImplemented
+	}
+	versionResp, err := s.fetchVersionAPI(ctx, name, version)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if versionResp.PublishedAt.IsZero() {
+		return time.Time{}, ErrNotImplemented
+	}
+	return versionResp.PublishedAt, nil
+}
+
 // FetchMetadata downloads just the metadata for a cookbook version.
 func (s *SupermarketSource) FetchMetadata(ctx context.Context, name string, version *berkshelf.Version) (*berkshelf.Metadata, error) {
-	endpoint := fmt.Sprintf("%s/api/v1/cookbooks/%s/versions/%s",
-		s.baseURL, url.PathEscape(name), url.PathEscape(version.String()))
-
-	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	versionResp, err := s.fetchVersion(ctx, name, version)
 	if err != nil {
-		return nil, fmt.Errorf("creating request: %w", err)
+		return nil, err
 	}
 
-	if s.apiKey != "" {
-		req.Header.Set("X-Ops-Userid", s.apiKey)
+	return s.buildMetadata(ctx, name, version, versionResp), nil
+}
+
+// fetchVersion returns the API response for a specific cookbook version,
+// read from /universe on sources without the per-cookbook API
+func (s *SupermarketSource) fetchVersion(ctx context.Context, name string, version *berkshelf.Version) (*cookbookVersionResponse, error) {
+	if s.useUniverse(ctx) {
+		return s.universeVersion(ctx, name, version)
 	}
+	return s.fetchVersionAPI(ctx, name, version)
+}
 
-	resp, err := s.httpClient.Do(req)
+// fetchVersionAPI returns the cookbook version from the per-cookbook API
+func (s *SupermarketSource) fetchVersionAPI(ctx context.Context, name string, version *berkshelf.Version) (*cookbookVersionResponse, error) {
+	endpoint := fmt.Sprintf("%s/api/v1/cookbooks/%s/versions/%s",
+		s.baseURL, url.PathEscape(name), url.PathEscape(version.String()))
+
+	status, body, err := s.get(ctx, endpoint)
 	if err != nil {
-		return nil, &ErrSourceUnavailable{Source: s.Name(), Reason: err.Error()}
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode == http.StatusNotFound {
+	if status == http.StatusNotFound {
 		return nil, &ErrVersionNotFound{Name: name, Version: version.String()}
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("supermarket API error: %d %s", resp.StatusCode, string(body))
+	if status != http.StatusOK {
+		return nil, apiError(status, body)
 	}
 
 	var versionResp cookbookVersionResponse
-	if err := json.NewDecoder(resp.Body).Decode(&versionResp); err != nil {
+	if err := json.Unmarshal(body, &versionResp); err != nil {
 		return nil, fmt.Errorf("decoding response: %w", err)
 	}
 
-	// Convert dependencies
-	dependencies := make(map[string]*berkshelf.Constraint)
-	for depName, constraintStr := range versionResp.Dependencies {
-		constraint, err := berkshelf.NewConstraint(constraintStr)
-		if err != nil {
-			continue // Skip invalid constraints
-		}
-		dependencies[depName] = constraint
-	}
+	return &versionResp, nil
+}
 
+// buildMetadata converts a version response, plus the cookbook-level details
+// when available, into cookbook metadata
+func (s *SupermarketSource) buildMetadata(ctx context.Context, name string, version *berkshelf.Version, versionResp *cookbookVersionResponse) *berkshelf.Metadata {
 	metadata := &berkshelf.Metadata{
 		Name:         name,
 		Version:      version,
-		Dependencies: dependencies,
-		// Additional fields can be populated from the API response
+		License:      versionResp.License,
+		Dependencies: parseConstraintMap(versionResp.Dependencies),
 	}
 
-	return metadata, nil
-}
+	if len(versionResp.Platforms) > 0 {
+		metadata.Platforms = parseConstraintMap(versionResp.Platforms)
+	}
 
-// FetchCookbook downloads the complete cookbook at the specified version.
-func (s *SupermarketSource) FetchCookbook(ctx context.Context, name string, version *berkshelf.Version) (*berkshelf.Cookbook, error) {
-	// First fetch the metadata to get the tarball URL
-	metadata, err := s.FetchMetadata(ctx, name, version)
-	if err != nil {
-		return nil, err
+	if len(versionResp.Recipes) > 0 {
+		metadata.Recipes = make(map[string]string, len(versionResp.Recipes))
+		for _, recipe := range versionResp.Recipes {
+			metadata.Recipes[recipe.Name] = recipe.Description
+		}
 	}
 
-	// Get the tarball URL from the version API response
-	endpoint := fmt.Sprintf("%s/api/v1/cookbooks/%s/versions/%s",
-		s.baseURL, url.PathEscape(name), url.PathEscape(version.String()))
+	if len(versionResp.Attributes) > 0 {
+		metadata.Attributes = make(map[string]interface{}, len(versionResp.Attributes))
+		for _, attribute := range versionResp.Attributes {
+			metadata.Attributes[attribute] = nil
+		}
+	}
 
-	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
-	if err != nil {
-		return nil, fmt.Errorf("creating request: %w", err)
+	for _, file := range versionResp.RootFiles {
+		metadata.RootFiles = append(metadata.RootFiles, file.Name)
 	}
 
-	if s.apiKey != "" {
-		req.Header.Set("X-Ops-Userid", s.apiKey)
+	if len(versionResp.ChefVersions) > 0 {
+		if constraint, err := berkshelf.NewChefVersionConstraint(versionResp.ChefVersions); err == nil {
+			metadata.ChefVersion = constraint
+		}
+	}
+	if len(versionResp.OhaiVersions) > 0 {
+		if constraint, err := berkshelf.NewChefVersionConstraint(versionResp.OhaiVersions); err == nil {
+			metadata.OhaiVersion = constraint
+		}
 	}
 
-	resp, err := s.httpClient.Do(req)
+	// Description, maintainer and project URLs live on the cookbook, not the version
+	if !s.Capabilities(ctx).CookbooksAPI {
+		return metadata
+	}
+	details, err := s.fetchCookbookDetails(ctx, name)
 	if err != nil {
-		return nil, &ErrSourceUnavailable{Source: s.Name(), Reason: err.Error()}
+		log.WithField(logging.CookbookField, name).Debugf("Failed to fetch cookbook details for %s: %v", name, err)
+		return metadata
 	}
-	defer resp.Body.Close()
+	metadata.Description = details.Description
+	metadata.Maintainer = details.Maintainer
+	metadata.Source = details.SourceURL
+	metadata.Issues = details.IssuesURL
+	metadata.ExternalURL = details.ExternalURL
+	metadata.Deprecated = details.Deprecated
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to get version details: %d", resp.StatusCode)
+	return metadata
+}
+
+// parseConstraintMap parses a name -> constraint string map, skipping invalid constraints
+func parseConstraintMap(raw map[string]string) map[string]*berkshelf.Constraint {
+	constraints := make(map[string]*berkshelf.Constraint, len(raw))
+	for name, constraintStr := range raw {
+		constraint, err := berkshelf.NewConstraint(constraintStr)
+		if err != nil {
+			continue // Skip invalid constraints
+		}
+		constraints[name] = constraint
 	}
+	return constraints
+}
 
-	var versionResp cookbookVersionResponse
-	if err := json.NewDecoder(resp.Body).Decode(&versionResp); err != nil {
-		return nil, fmt.Errorf("decoding version response: %w", err)
+// FetchCookbook downloads the complete cookbook at the specified version.
+func (s *SupermarketSource) FetchCookbook(ctx context.Context, name string, version *berkshelf.Version) (*berkshelf.Cookbook, error) {
+	versionResp, err := s.fetchVersion(ctx, name, version)
+	if err != nil {
+		return nil, err
 	}
+	metadata := s.buildMetadata(ctx, name, version, versionResp)
 
 	// Use FileURL if available, otherwise fall back to TarballURL
 	tarballURL := versionResp.FileURL
@@ -270,114 +452,105 @@ func (s *SupermarketSource) FetchCookbook(ctx context.Context, name string, vers
 			Type: "supermarket",
 			URL:  s.baseURL,
 		},
-		TarballURL: tarballURL, // Store the download URL
-		Path:       "",         // Will be set when extracted
+		TarballURL:    tarballURL, // Store the download URL
+		Path:          "",         // Will be set when extracted
+		FileChecksums: fileChecksums(versionResp.RootFiles),
 	}
 
 	return cookbook, nil
 }
 
+// fileChecksums returns the published checksum of each file by its path
+// relative to the cookbook root, with its algorithm, or nil if the API lists
+// none. Checksums of an unknown algorithm are left out.
+func fileChecksums(files []fileInfo) map[string]string {
+	var checksums map[string]string
+	for _, file := range files {
+		name := cmp.Or(file.Path, file.Name)
+		if file.Checksum == "" || !filepath.IsLocal(filepath.FromSlash(name)) {
+			continue
+		}
+		sum, err := digest.Parse(file.Checksum)
+		if err != nil {
+			log.Debugf("Not verifying %s: %v", name, err)
+			continue
+		}
+		if checksums == nil {
+			checksums = make(map[string]string)
+		}
+		checksums[path.Clean(name)] = sum.String()
+	}
+	return checksums
+}
+
 // DownloadAndExtractCookbook downloads the cookbook tarball and extracts it to the specified directory.
+// An interrupted download is resumed by the next attempt; see downloadTarball.
+// Files with a checksum in cookbook.FileChecksums are verified as they are extracted.
 func (s *SupermarketSource) DownloadAndExtractCookbook(ctx context.Context, cookbook *berkshelf.Cookbook, targetDir string) error {
 	if cookbook.TarballURL == "" {
 		return fmt.Errorf("no tarball URL available for cookbook %s", cookbook.Name)
 	}
 
-	// Download the tarball
-	req, err := http.NewRequestWithContext(ctx, "GET", cookbook.TarballURL, nil)
+	// Download the tarball, verified before anything is extracted
+	tarball, err := s.downloadTarball(ctx, cookbook.TarballURL)
 	if err != nil {
-		return fmt.Errorf("creating download request: %w", err)
+		return err
 	}
+	defer os.Remove(tarball)
 
-	if s.apiKey != "" {
-		req.Header.Set("X-Ops-Userid", s.apiKey)
+	if err := s.verifyArtifact(ctx, cookbook, tarball); err != nil {
+		return err
 	}
 
-	resp, err := s.httpClient.Do(req)
+	body, err := os.Open(tarball)
 	if err != nil {
-		return fmt.Errorf("downloading tarball: %w", err)
+		return fmt.Errorf("opening tarball: %w", err)
 	}
-	defer resp.Body.Close()
+	defer body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to download tarball: HTTP %d", resp.StatusCode)
-	}
+	return extractTarball(fsys.Or(s.fs), body, cookbook, targetDir)
+}
 
-	// Create target directory
-	if err := os.MkdirAll(targetDir, 0755); err != nil {
-		return fmt.Errorf("creating target directory: %w", err)
+// Checksum downloads the cookbook tarball and returns its SHA-256. A checksum
+// header published by the source is trusted without reading the body.
+func (s *SupermarketSource) Checksum(ctx context.Context, cookbook *berkshelf.Cookbook) (string, error) {
+	if cookbook.TarballURL == "" {
+		return "", fmt.Errorf("no tarball URL available for cookbook %s", cookbook.Name)
 	}
 
-	// Extract the tarball
-	gzipReader, err := gzip.NewReader(resp.Body)
+	req, err := http.NewRequestWithContext(ctx, "GET", cookbook.TarballURL, nil)
 	if err != nil {
-		return fmt.Errorf("creating gzip reader: %w", err)
+		return "", fmt.Errorf("creating download request: %w", err)
 	}
-	defer gzipReader.Close()
 
-	tarReader := tar.NewReader(gzipReader)
-
-	for {
-		header, err := tarReader.Next()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return fmt.Errorf("reading tar: %w", err)
-		}
-
-		// Skip directories and non-regular files
-		if header.Typeflag != tar.TypeReg {
-			continue
-		}
-
-		// Clean the path and remove leading cookbook directory
-		// Supermarket tarballs typically have a top-level directory like "cookbook-name-version/"
-		pathParts := strings.Split(header.Name, "/")
-		if len(pathParts) <= 1 {
-			continue // Skip files in root
-		}
-
-		// Skip the first directory component and join the rest
-		relativePath := filepath.Join(pathParts[1:]...)
-		if relativePath == "" {
-			continue
-		}
+	s.authorize(req)
 
-		targetPath := filepath.Join(targetDir, relativePath)
-
-		// Create directory if needed
-		if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
-			return fmt.Errorf("creating directory for %s: %w", targetPath, err)
-		}
-
-		// Extract the file
-		outFile, err := os.Create(targetPath)
-		if err != nil {
-			return fmt.Errorf("creating file %s: %w", targetPath, err)
-		}
-
-		_, err = io.Copy(outFile, tarReader)
-		outFile.Close()
-		if err != nil {
-			return fmt.Errorf("extracting file %s: %w", targetPath, err)
-		}
-
-		// Set file permissions
-		if err := os.Chmod(targetPath, os.FileMode(header.Mode)); err != nil {
-			// Don't fail on permission errors, just log them
-			continue
-		}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("downloading tarball: %w", err)
 	}
+	defer resp.Body.Close()
 
-	// Set the cookbook path
-	cookbook.Path = targetDir
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to download tarball: HTTP %d", resp.StatusCode)
+	}
+	if expected := resp.Header.Get(checksumHeader); expected != "" {
+		return strings.ToLower(expected), nil
+	}
 
-	return nil
+	hash := sha256.New()
+	if _, err := io.Copy(hash, resp.Body); err != nil {
+		return "", fmt.Errorf("downloading tarball: %w", err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
 }
 
 // Search returns cookbooks matching the query.
 func (s *SupermarketSource) Search(ctx context.Context, query string) ([]*berkshelf.Cookbook, error) {
+	if !s.Capabilities(ctx).Search {
+		return nil, ErrNotImplemented
+	}
+
 	endpoint := fmt.Sprintf("%s/api/v1/search?q=%s", s.baseURL, url.QueryEscape(query))
 
 	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
@@ -385,19 +558,17 @@ func (s *SupermarketSource) Search(ctx context.Context, query string) ([]*berksh
 		return nil, fmt.Errorf("creating request: %w", err)
 	}
 
-	if s.apiKey != "" {
-		req.Header.Set("X-Ops-Userid", s.apiKey)
-	}
+	s.authorize(req)
 
 	resp, err := s.httpClient.Do(req)
 	if err != nil {
-		return nil, &ErrSourceUnavailable{Source: s.Name(), Reason: err.Error()}
+		return nil, &ErrSourceUnavailable{Source: s.Name(), Reason: err.Error(), Err: err}
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("supermarket API error: %d %s", resp.StatusCode, string(body))
+		return nil, apiError(resp.StatusCode, body)
 	}
 
 	// Parse search results
@@ -1,10 +1,22 @@
 package source
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
@@ -18,10 +30,10 @@ func TestSupermarketSource_ListVersions(t *testing.T) {
 			return
 		}
 
-		response := cookbookResponse{
-			Name:          "nginx",
-			LatestVersion: "2.7.6",
-			Versions: []string{
+		response := map[string]any{
+			"name":           "nginx",
+			"latest_version": "2.7.6",
+			"versions": []string{
 				"http://example.com/api/v1/cookbooks/nginx/versions/2.7.6",
 				"http://example.com/api/v1/cookbooks/nginx/versions/2.7.4",
 				"http://example.com/api/v1/cookbooks/nginx/versions/2.7.2",
@@ -50,6 +62,40 @@ func TestSupermarketSource_ListVersions(t *testing.T) {
 	}
 }
 
+func TestSupermarketSource_ListVersions_Many(t *testing.T) {
+	// Versions are parsed as the list is decoded; invalid ones are skipped
+	var body strings.Builder
+	body.WriteString(`{"name": "big", "versions": [`)
+	for i := range 5000 {
+		fmt.Fprintf(&body, `"http://example.com/api/v1/cookbooks/big/versions/1.%d.0", `, i)
+	}
+	body.WriteString(`"http://example.com/api/v1/cookbooks/big/versions/latest"]}`)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, body.String())
+	}))
+	defer server.Close()
+
+	src := NewSupermarketSource(server.URL)
+	versions, err := src.ListVersions(context.Background(), "big")
+	if err != nil {
+		t.Fatalf("ListVersions() error = %v", err)
+	}
+	if len(versions) != 5000 || versions[4999].String() != "1.4999.0" {
+		t.Fatalf("ListVersions() returned %d versions, want 5000 ending with 1.4999.0", len(versions))
+	}
+
+	// Callers cannot change the cached list
+	versions[0] = nil
+	if again, _ := src.ListVersions(context.Background(), "big"); again[0] == nil {
+		t.Error("ListVersions() returned the cached list itself")
+	}
+
+	var details cookbookResponse
+	if err := json.Unmarshal([]byte(`{"name": "none", "versions": null}`), &details); err != nil || len(details.Versions) != 0 {
+		t.Errorf("decoding null versions = %v, %v", details.Versions, err)
+	}
+}
+
 func TestSupermarketSource_ListVersions_NotFound(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusNotFound)
@@ -117,6 +163,267 @@ func TestSupermarketSource_FetchMetadata(t *testing.T) {
 	}
 }
 
+func TestSupermarketSource_FetchMetadata_Full(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/cookbooks/nginx":
+			json.NewEncoder(w).Encode(cookbookResponse{
+				Name:        "nginx",
+				Maintainer:  "sous-chefs",
+				Description: "Installs and configures nginx",
+				SourceURL:   "https://github.com/sous-chefs/nginx",
+				IssuesURL:   "https://github.com/sous-chefs/nginx/issues",
+				ExternalURL: "https://nginx.org",
+			})
+		case "/api/v1/cookbooks/nginx/versions/2.7.6":
+			json.NewEncoder(w).Encode(cookbookVersionResponse{
+				Version:      "2.7.6",
+				License:      "Apache-2.0",
+				FileURL:      "https://example.com/nginx-2.7.6.tar.gz",
+				Dependencies: map[string]string{"apt": "~> 2.2"},
+				Platforms:    map[string]string{"ubuntu": ">= 16.04", "centos": ">= 0.0.0"},
+				Attributes:   []string{"default.rb"},
+				Recipes:      []recipeInfo{{Name: "nginx::default", Description: "Installs nginx"}},
+				RootFiles:    []fileInfo{{Name: "metadata.json"}, {Name: "README.md"}},
+				ChefVersions: [][]string{{">= 15.3", "< 19"}},
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	source := NewSupermarketSource(server.URL)
+	version, _ := berkshelf.NewVersion("2.7.6")
+	metadata, err := source.FetchMetadata(context.Background(), "nginx", version)
+	if err != nil {
+		t.Fatalf("FetchMetadata() error = %v", err)
+	}
+
+	if metadata.License != "Apache-2.0" {
+		t.Errorf("License = %q, want Apache-2.0", metadata.License)
+	}
+	if c, ok := metadata.Platforms["ubuntu"]; !ok || c.String() != ">= 16.04.0" {
+		t.Errorf("Platforms = %v, want ubuntu >= 16.04.0", metadata.Platforms)
+	}
+	if metadata.Recipes["nginx::default"] != "Installs nginx" {
+		t.Errorf("Recipes = %v", metadata.Recipes)
+	}
+	if _, ok := metadata.Attributes["default.rb"]; !ok {
+		t.Errorf("Attributes = %v", metadata.Attributes)
+	}
+	if len(metadata.RootFiles) != 2 || metadata.RootFiles[1] != "README.md" {
+		t.Errorf("RootFiles = %v", metadata.RootFiles)
+	}
+	if metadata.ChefVersion == nil || metadata.ChefVersion.Check(berkshelf.MustVersion("19.0.0")) {
+		t.Errorf("ChefVersion = %v, want >= 15.3, < 19", metadata.ChefVersion)
+	}
+	if metadata.Description != "Installs and configures nginx" || metadata.Maintainer != "sous-chefs" {
+		t.Errorf("Description/Maintainer = %q/%q", metadata.Description, metadata.Maintainer)
+	}
+	if metadata.Issues != "https://github.com/sous-chefs/nginx/issues" ||
+		metadata.Source != "https://github.com/sous-chefs/nginx" ||
+		metadata.ExternalURL != "https://nginx.org" {
+		t.Errorf("URLs = %q, %q, %q", metadata.Issues, metadata.Source, metadata.ExternalURL)
+	}
+}
+
+func TestSupermarketSource_Deprecation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/cookbooks/old":
+			json.NewEncoder(w).Encode(cookbookResponse{
+				Name:        "old",
+				Deprecated:  true,
+				Replacement: "https://supermarket.example.com/api/v1/cookbooks/new",
+			})
+		case "/api/v1/cookbooks/new":
+			json.NewEncoder(w).Encode(cookbookResponse{Name: "new"})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	source := NewSupermarketSource(server.URL)
+
+	deprecation, err := source.Deprecation(context.Background(), "old")
+	if err != nil {
+		t.Fatalf("Deprecation() error = %v", err)
+	}
+	if deprecation == nil || deprecation.Replacement != "new" {
+		t.Errorf("Deprecation(old) = %+v, want replacement new", deprecation)
+	}
+
+	deprecation, err = source.Deprecation(context.Background(), "new")
+	if err != nil || deprecation != nil {
+		t.Errorf("Deprecation(new) = %+v, %v; want nil", deprecation, err)
+	}
+}
+
+func TestSupermarketSource_Repository(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/cookbooks/nginx" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(cookbookResponse{Name: "nginx", SourceURL: "https://github.com/sous-chefs/nginx"})
+	}))
+	defer server.Close()
+
+	source := NewSupermarketSource(server.URL)
+
+	repository, err := source.Repository(context.Background(), "nginx")
+	if err != nil {
+		t.Fatalf("Repository() error = %v", err)
+	}
+	if repository != "https://github.com/sous-chefs/nginx" {
+		t.Errorf("Repository(nginx) = %q", repository)
+	}
+
+	if _, err := source.Repository(context.Background(), "missing"); err == nil {
+		t.Error("Repository(missing) error = nil, want an error")
+	}
+}
+
+func TestSupermarketSource_DownloadAndExtractCookbook_Collisions(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   []string
+		wantErr string
+	}{
+		{name: "single top-level directory", files: []string{"nginx/metadata.json", "nginx/recipes/default.rb"}},
+		{name: "flattened top-level directories", files: []string{"nginx/metadata.json", "nginx-2.7.6/metadata.json"}, wantErr: "both extract to metadata.json"},
+		{name: "case-only difference", files: []string{"nginx/README.md", "nginx/readme.md"}, wantErr: "both extract to readme.md"},
+		{name: "escaping entry", files: []string{"nginx/../../evil.rb"}, wantErr: "escapes the cookbook directory"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tarball := buildTarball(t, tt.files)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write(tarball)
+			}))
+			defer server.Close()
+
+			source := NewSupermarketSource(server.URL)
+			cookbook := &berkshelf.Cookbook{Name: "nginx", TarballURL: server.URL + "/nginx.tgz"}
+			targetDir := filepath.Join(t.TempDir(), "nginx")
+
+			err := source.DownloadAndExtractCookbook(context.Background(), cookbook, targetDir)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("DownloadAndExtractCookbook() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("DownloadAndExtractCookbook() error = %v, want %q", err, tt.wantErr)
+			}
+			if _, statErr := os.Stat(filepath.Join(filepath.Dir(targetDir), "..", "evil.rb")); statErr == nil {
+				t.Error("escaping entry was written outside the target directory")
+			}
+		})
+	}
+}
+
+func TestSupermarketSource_FileChecksums(t *testing.T) {
+	tarball := buildTarball(t, []string{"nginx/metadata.json", "nginx/README.md"})
+	md5sum := func(content string) string {
+		sum := md5.Sum([]byte(content))
+		return hex.EncodeToString(sum[:])
+	}
+	sha256sum := func(content string) string {
+		sum := sha256.Sum256([]byte(content))
+		return hex.EncodeToString(sum[:])
+	}
+	sha512sum := func(content string) string {
+		sum := sha512.Sum512([]byte(content))
+		return hex.EncodeToString(sum[:])
+	}
+
+	var rootFiles []fileInfo
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/cookbooks/nginx/versions/1.0.0":
+			json.NewEncoder(w).Encode(cookbookVersionResponse{Version: "1.0.0", FileURL: "http://" + r.Host + "/nginx.tgz", RootFiles: rootFiles})
+		case "/nginx.tgz":
+			w.Write(tarball)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		files   []fileInfo
+		wantErr string
+	}{
+		{name: "matching", files: []fileInfo{
+			{Name: "metadata.json", Path: "metadata.json", Checksum: md5sum("# nginx/metadata.json\n")},
+			{Name: "README.md", Checksum: strings.ToUpper(sha256sum("# nginx/README.md\n"))},
+		}},
+		{name: "with algorithm", files: []fileInfo{{Path: "README.md", Checksum: "sha512:" + sha512sum("# nginx/README.md\n")}}},
+		{name: "none published"},
+		{name: "mismatch", files: []fileInfo{{Path: "README.md", Checksum: md5sum("tampered")}}, wantErr: "checksum mismatch for nginx README.md"},
+		{name: "missing", files: []fileInfo{{Path: "CHANGELOG.md", Checksum: md5sum("")}}, wantErr: "CHANGELOG.md is missing from the tarball"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rootFiles = tt.files
+			source := NewSupermarketSource(server.URL)
+			cookbook, err := source.FetchCookbook(context.Background(), "nginx", berkshelf.MustVersion("1.0.0"))
+			if err != nil {
+				t.Fatalf("FetchCookbook() error = %v", err)
+			}
+			if len(cookbook.FileChecksums) != len(tt.files) {
+				t.Errorf("FileChecksums = %v, want %d", cookbook.FileChecksums, len(tt.files))
+			}
+			for name, checksum := range cookbook.FileChecksums {
+				if !strings.Contains(checksum, ":") {
+					t.Errorf("FileChecksums[%s] = %s, want it recorded with its algorithm", name, checksum)
+				}
+			}
+
+			err = source.DownloadAndExtractCookbook(context.Background(), cookbook, filepath.Join(t.TempDir(), "nginx"))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("DownloadAndExtractCookbook() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("DownloadAndExtractCookbook() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// buildTarball returns a gzipped tarball holding the named files
+func buildTarball(t *testing.T, files []string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, name := range files {
+		body := []byte("# " + name + "\n")
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(body)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
 func TestSupermarketSource_Search(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path != "/api/v1/search" {
@@ -198,3 +505,66 @@ func TestSupermarketSource_Name(t *testing.T) {
 		}
 	}
 }
+
+func TestSupermarketSource_Cancel(t *testing.T) {
+	server := newBlockingServer(t)
+	source := NewSupermarketSource(server.URL)
+
+	assertCancels(t, func(ctx context.Context) error {
+		_, err := source.ListVersions(ctx, "nginx")
+		return err
+	})
+	assertCancels(t, func(ctx context.Context) error {
+		cookbook := &berkshelf.Cookbook{Name: "nginx", Version: berkshelf.MustVersion("1.0.0"), TarballURL: server.URL + "/nginx.tgz"}
+		return source.DownloadAndExtractCookbook(ctx, cookbook, t.TempDir())
+	})
+}
+
+// memoryResponses is a ResponseStore kept in memory
+type memoryResponses map[string]CachedResponse
+
+func (m memoryResponses) GetResponse(url string) (CachedResponse, bool) {
+	response, ok := m[url]
+	return response, ok
+}
+
+func (m memoryResponses) PutResponse(url string, response CachedResponse) error {
+	m[url] = response
+	return nil
+}
+
+func TestSupermarketSource_ConditionalRequests(t *testing.T) {
+	var full, notModified int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		full++
+		w.Header().Set("ETag", `"v1"`)
+		json.NewEncoder(w).Encode(map[string]any{
+			"name":     "nginx",
+			"versions": []string{"http://example.com/api/v1/cookbooks/nginx/versions/2.7.6"},
+		})
+	}))
+	defer server.Close()
+
+	store := memoryResponses{}
+	for range 3 {
+		// A new source per run, as each berks command starts without the
+		// in-memory cache
+		src := NewSupermarketSource(server.URL)
+		src.SetResponseStore(store)
+		versions, err := src.ListVersions(context.Background(), "nginx")
+		if err != nil {
+			t.Fatalf("ListVersions() error = %v", err)
+		}
+		if len(versions) != 1 || versions[0].String() != "2.7.6" {
+			t.Errorf("ListVersions() = %v, want [2.7.6]", versions)
+		}
+	}
+	if full != 1 || notModified != 2 {
+		t.Errorf("server sent %d full and %d not modified responses, want 1 and 2", full, notModified)
+	}
+}
@@ -0,0 +1,188 @@
+package source
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	"github.com/bdwyertech/go-berkshelf/pkg/digest"
+	berrors "github.com/bdwyertech/go-berkshelf/pkg/errors"
+	"github.com/bdwyertech/go-berkshelf/pkg/fsys"
+	"github.com/bdwyertech/go-berkshelf/pkg/metadata"
+)
+
+// extractTarball extracts a gzipped cookbook tarball to targetDir in
+// filesystem, dropping its top-level directory. Files with a checksum in
+// cookbook.FileChecksums are verified as they are extracted.
+func extractTarball(filesystem fsys.FS, body io.Reader, cookbook *berkshelf.Cookbook, targetDir string) error {
+	// Create target directory
+	if err := filesystem.MkdirAll(targetDir, 0755); err != nil {
+		return fmt.Errorf("creating target directory: %w", err)
+	}
+
+	// Extract the tarball
+	gzipReader, err := gzip.NewReader(body)
+	if err != nil {
+		return fmt.Errorf("creating gzip reader: %w", err)
+	}
+	defer gzipReader.Close()
+
+	tarReader := tar.NewReader(gzipReader)
+
+	// Tarballs with several top-level directories flatten onto the same
+	// relative paths; report that instead of letting the last entry win.
+	written := make(map[string]string)
+
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("reading tar: %w", err)
+		}
+
+		// Skip directories and non-regular files
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		// Clean the path and remove leading cookbook directory
+		// Supermarket tarballs typically have a top-level directory like "cookbook-name-version/"
+		pathParts := strings.Split(header.Name, "/")
+		if len(pathParts) <= 1 {
+			continue // Skip files in root
+		}
+
+		// Skip the first directory component and join the rest
+		relativePath := filepath.Join(pathParts[1:]...)
+		if relativePath == "" {
+			continue
+		}
+		if !filepath.IsLocal(relativePath) {
+			return fmt.Errorf("tarball entry %s escapes the cookbook directory", header.Name)
+		}
+		folded := strings.ToLower(relativePath)
+		if previous, ok := written[folded]; ok {
+			return fmt.Errorf("tarball entries %s and %s both extract to %s", previous, header.Name, filepath.ToSlash(relativePath))
+		}
+		written[folded] = header.Name
+
+		targetPath := filepath.Join(targetDir, relativePath)
+
+		// Create directory if needed
+		if err := filesystem.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+			return fmt.Errorf("creating directory for %s: %w", targetPath, err)
+		}
+
+		// Extract the file
+		outFile, err := filesystem.Create(targetPath)
+		if err != nil {
+			return fmt.Errorf("creating file %s: %w", targetPath, err)
+		}
+
+		var out io.Writer = outFile
+		var expected digest.Digest
+		var fileHash hash.Hash
+		if checksum, ok := cookbook.FileChecksums[filepath.ToSlash(relativePath)]; ok {
+			// Lock files written before algorithms were recorded hold bare checksums
+			if expected, err = digest.Parse(checksum); err == nil {
+				fileHash, err = expected.New()
+			}
+			switch {
+			case errors.Is(err, digest.ErrNotApproved):
+				// Recorded outside FIPS mode, e.g. the MD5 a Supermarket publishes
+				log.Debugf("Not verifying %s %s: %v", cookbook.Name, filepath.ToSlash(relativePath), err)
+			case err != nil:
+				outFile.Close()
+				return fmt.Errorf("checksum of %s %s: %w", cookbook.Name, filepath.ToSlash(relativePath), err)
+			default:
+				out = io.MultiWriter(outFile, fileHash)
+			}
+		}
+		_, err = io.Copy(out, tarReader)
+		outFile.Close()
+		if err != nil {
+			return fmt.Errorf("extracting file %s: %w", targetPath, err)
+		}
+		if fileHash != nil && !expected.Matches(fileHash) {
+			return berrors.WithType(fmt.Errorf("checksum mismatch for %s %s: expected %s, got %s", cookbook.Name, filepath.ToSlash(relativePath), expected, digest.Of(expected.Algorithm, fileHash)), berrors.ErrorTypeIntegrity)
+		}
+
+		// Set file permissions
+		if err := filesystem.Chmod(targetPath, os.FileMode(header.Mode)); err != nil {
+			// Don't fail on permission errors, just log them
+			continue
+		}
+	}
+
+	for name := range cookbook.FileChecksums {
+		if _, ok := written[strings.ToLower(filepath.FromSlash(name))]; !ok {
+			return berrors.WithType(fmt.Errorf("checksum mismatch for %s: %s is missing from the tarball", cookbook.Name, name), berrors.ErrorTypeIntegrity)
+		}
+	}
+
+	// Set the cookbook path
+	cookbook.Path = targetDir
+
+	return nil
+}
+
+// tarballMetadata reads metadata.json, or failing that metadata.rb, from
+// the top-level directory of a gzipped cookbook tarball
+func tarballMetadata(body io.Reader, name string) (*berkshelf.Metadata, error) {
+	gzipReader, err := gzip.NewReader(body)
+	if err != nil {
+		return nil, fmt.Errorf("creating gzip reader: %w", err)
+	}
+	defer gzipReader.Close()
+
+	var rb []byte
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading tar: %w", err)
+		}
+		parts := strings.Split(header.Name, "/")
+		if header.Typeflag != tar.TypeReg || len(parts) != 2 {
+			continue
+		}
+
+		switch parts[1] {
+		case "metadata.json":
+			data, err := io.ReadAll(tarReader)
+			if err != nil {
+				return nil, fmt.Errorf("reading tar: %w", err)
+			}
+			md, err := metadata.ParseJSON(data)
+			if err != nil {
+				return nil, &ErrInvalidMetadata{Name: name, Reason: err.Error()}
+			}
+			return md.Berkshelf(), nil
+		case "metadata.rb":
+			if rb, err = io.ReadAll(tarReader); err != nil {
+				return nil, fmt.Errorf("reading tar: %w", err)
+			}
+		}
+	}
+
+	if rb == nil {
+		return nil, &ErrInvalidMetadata{Name: name, Reason: "no metadata.json or metadata.rb found"}
+	}
+	md, err := metadata.Parse(string(rb), "metadata.rb")
+	if err != nil {
+		return nil, &ErrInvalidMetadata{Name: name, Reason: err.Error()}
+	}
+	return md.Berkshelf(), nil
+}
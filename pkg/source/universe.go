@@ -0,0 +1,86 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+)
+
+// universeEntry is one cookbook version in a /universe response
+type universeEntry struct {
+	LocationType string            `json:"location_type"`
+	LocationPath string            `json:"location_path"`
+	DownloadURL  string            `json:"download_url"`
+	Dependencies map[string]string `json:"dependencies"`
+}
+
+// universe maps cookbook name -> version -> entry
+type universe map[string]map[string]universeEntry
+
+// loadUniverse fetches the /universe index once per source
+func (s *SupermarketSource) loadUniverse(ctx context.Context) (universe, error) {
+	s.universeMu.Lock()
+	defer s.universeMu.Unlock()
+	if s.universe != nil {
+		return s.universe, nil
+	}
+
+	var index universe
+	status, err := s.getJSON(ctx, s.baseURL+"/universe", &index)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("%s has no universe endpoint (HTTP %d)", s.baseURL, status)
+	}
+	s.universe = index
+	return index, nil
+}
+
+// universeVersions lists the versions of a cookbook in the universe
+func (s *SupermarketSource) universeVersions(ctx context.Context, name string) ([]*berkshelf.Version, error) {
+	index, err := s.loadUniverse(ctx)
+	if err != nil {
+		return nil, err
+	}
+	entries, ok := index[name]
+	if !ok {
+		return nil, &ErrCookbookNotFound{Name: name}
+	}
+
+	versions := make([]*berkshelf.Version, 0, len(entries))
+	for raw := range entries {
+		v, err := berkshelf.NewVersion(raw)
+		if err != nil {
+			continue // Skip invalid versions
+		}
+		versions = append(versions, v)
+	}
+	return versions, nil
+}
+
+// universeVersion returns the universe entry for a cookbook version as a
+// version response. The universe only carries dependencies and the download
+// URL, so the other metadata fields are empty.
+func (s *SupermarketSource) universeVersion(ctx context.Context, name string, version *berkshelf.Version) (*cookbookVersionResponse, error) {
+	index, err := s.loadUniverse(ctx)
+	if err != nil {
+		return nil, err
+	}
+	entries, ok := index[name]
+	if !ok {
+		return nil, &ErrCookbookNotFound{Name: name}
+	}
+	for raw, entry := range entries {
+		if v, err := berkshelf.NewVersion(raw); err == nil && v.Equal(version) {
+			return &cookbookVersionResponse{
+				Version:      raw,
+				FileURL:      entry.DownloadURL,
+				Dependencies: entry.Dependencies,
+			}, nil
+		}
+	}
+	return nil, &ErrVersionNotFound{Name: name, Version: version.String()}
+}
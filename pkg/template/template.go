@@ -9,15 +9,17 @@ import (
 	"strings"
 	"text/template"
 
-	log "github.com/sirupsen/logrus"
-
 	"github.com/go-sprout/sprout"
 	"github.com/go-sprout/sprout/group/all"
 	"github.com/go-sprout/sprout/registry/crypto"
 	"github.com/goccy/go-yaml"
 	"github.com/google/uuid"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/logging"
 )
 
+var log = logging.For("template")
+
 func New() *template.Template {
 	handler := sprout.New()
 	handler.AddGroups(all.RegistryGroup())
@@ -0,0 +1,108 @@
+// Package ui provides user interaction helpers for the CLI.
+package ui
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ErrNonInteractive is returned when confirmation is required but no user can answer
+var ErrNonInteractive = errors.New("confirmation required but running non-interactively; pass --yes to proceed")
+
+// ciEnvVars are set by common CI systems
+var ciEnvVars = []string{
+	"CI",
+	"BUILD_NUMBER",
+	"GITHUB_ACTIONS",
+	"GITLAB_CI",
+	"JENKINS_URL",
+	"TEAMCITY_VERSION",
+	"TF_BUILD",
+	"BUILDKITE",
+}
+
+// Prompter asks the user to confirm actions
+type Prompter interface {
+	// Confirm asks a yes/no question and reports whether the user agreed
+	Confirm(message string) (bool, error)
+}
+
+// NewPrompter returns the prompter for the current process. With assumeYes every
+// confirmation is accepted; in CI or without a terminal on stdin, confirmations
+// fail with ErrNonInteractive; otherwise the user is asked on stdin/stderr.
+func NewPrompter(assumeYes bool) Prompter {
+	switch {
+	case assumeYes:
+		return AutoPrompter{Answer: true}
+	case IsCI() || !isTerminal(os.Stdin):
+		return nonInteractivePrompter{}
+	default:
+		return NewTerminalPrompter(os.Stdin, os.Stderr)
+	}
+}
+
+// IsCI reports whether the process appears to be running under a CI system
+func IsCI() bool {
+	for _, name := range ciEnvVars {
+		if val, ok := os.LookupEnv(name); ok && val != "" && !strings.EqualFold(val, "false") && val != "0" {
+			return true
+		}
+	}
+	return false
+}
+
+// TerminalPrompter asks questions on an output stream and reads answers from an input stream
+type TerminalPrompter struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// NewTerminalPrompter creates a prompter reading from in and writing to out
+func NewTerminalPrompter(in io.Reader, out io.Writer) *TerminalPrompter {
+	return &TerminalPrompter{in: bufio.NewReader(in), out: out}
+}
+
+// Confirm implements Prompter. Anything other than y or yes is treated as no.
+func (p *TerminalPrompter) Confirm(message string) (bool, error) {
+	fmt.Fprintf(p.out, "%s [y/N]: ", message)
+
+	answer, err := p.in.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, fmt.Errorf("failed to read answer: %w", err)
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+// AutoPrompter answers every confirmation without asking
+type AutoPrompter struct {
+	Answer bool
+}
+
+// Confirm implements Prompter
+func (p AutoPrompter) Confirm(string) (bool, error) {
+	return p.Answer, nil
+}
+
+type nonInteractivePrompter struct{}
+
+func (nonInteractivePrompter) Confirm(string) (bool, error) {
+	return false, ErrNonInteractive
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,72 @@
+package ui
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestTerminalPrompter_Confirm(t *testing.T) {
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{"y\n", true},
+		{"YES\n", true},
+		{" yes \n", true},
+		{"n\n", false},
+		{"\n", false},
+		{"maybe\n", false},
+		{"y", true}, // no trailing newline
+		{"", false}, // EOF
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		p := NewTerminalPrompter(strings.NewReader(tt.input), &out)
+
+		got, err := p.Confirm("Delete everything?")
+		if err != nil {
+			t.Fatalf("Confirm(%q) error = %v", tt.input, err)
+		}
+		if got != tt.want {
+			t.Errorf("Confirm(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+		if !strings.Contains(out.String(), "Delete everything? [y/N]") {
+			t.Errorf("prompt not written, got %q", out.String())
+		}
+	}
+}
+
+func TestNewPrompter(t *testing.T) {
+	ok, err := NewPrompter(true).Confirm("proceed?")
+	if err != nil || !ok {
+		t.Errorf("assumeYes prompter = %v, %v; want true, nil", ok, err)
+	}
+
+	t.Setenv("CI", "true")
+	ok, err = NewPrompter(false).Confirm("proceed?")
+	if ok || !errors.Is(err, ErrNonInteractive) {
+		t.Errorf("CI prompter = %v, %v; want false, ErrNonInteractive", ok, err)
+	}
+}
+
+func TestIsCI(t *testing.T) {
+	for _, name := range ciEnvVars {
+		t.Setenv(name, "")
+	}
+	if IsCI() {
+		t.Error("IsCI() = true with no CI variables set")
+	}
+
+	t.Setenv("CI", "false")
+	if IsCI() {
+		t.Error("IsCI() = true with CI=false")
+	}
+
+	t.Setenv("GITHUB_ACTIONS", "true")
+	if !IsCI() {
+		t.Error("IsCI() = false with GITHUB_ACTIONS=true")
+	}
+}
@@ -0,0 +1,164 @@
+package ui
+
+import (
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/olekukonko/tablewriter/pkg/twwidth"
+	"github.com/olekukonko/tablewriter/tw"
+)
+
+// Ellipsis marks a truncated cell
+const Ellipsis = "…"
+
+// minColumnWidth is the narrowest a column is truncated to
+const minColumnWidth = 8
+
+// TableOptions configures table rendering
+type TableOptions struct {
+	// Width is the terminal width to fit the table into. Zero disables
+	// truncation, which is what non-terminal output should use.
+	Width int
+	// Wide disables truncation regardless of Width
+	Wide bool
+	// Unsorted keeps rows in the order they were added
+	Unsorted bool
+}
+
+// Table collects rows and renders them as a bordered, left-aligned table.
+// Rows are sorted by their cells from left to right and cell widths are
+// measured without East Asian ambiguous-width rules, so output is the same
+// in every locale.
+type Table struct {
+	headers []string
+	rows    [][]string
+	opts    TableOptions
+}
+
+// NewTable returns an empty table with the given column headers
+func NewTable(opts TableOptions, headers ...string) *Table {
+	return &Table{headers: headers, opts: opts}
+}
+
+// Append adds a row. Missing cells are rendered empty and extra cells are dropped.
+func (t *Table) Append(cells ...string) {
+	row := make([]string, len(t.headers))
+	copy(row, cells)
+	t.rows = append(t.rows, row)
+}
+
+// Len returns the number of rows
+func (t *Table) Len() int {
+	return len(t.rows)
+}
+
+// Render writes the table to w
+func (t *Table) Render(w io.Writer) error {
+	twwidth.SetEastAsian(false)
+
+	rows := slices.Clone(t.rows)
+	if !t.opts.Unsorted {
+		slices.SortStableFunc(rows, slices.Compare)
+	}
+
+	if t.opts.Width > 0 && !t.opts.Wide {
+		widths := fitColumns(t.headers, rows, t.opts.Width)
+		for i, row := range rows {
+			truncated := make([]string, len(row))
+			for col, cell := range row {
+				truncated[col] = truncateCell(cell, widths[col])
+			}
+			rows[i] = truncated
+		}
+	}
+
+	table := tablewriter.NewTable(w, tablewriter.WithEastAsian(tw.Off))
+	table.Configure(func(config *tablewriter.Config) {
+		config.Row.Alignment.Global = tw.AlignLeft
+		config.Row.Formatting.AutoWrap = tw.WrapNone
+		config.Header.Formatting.AutoWrap = tw.WrapNone
+	})
+	headers := make([]any, len(t.headers))
+	for i, header := range t.headers {
+		headers[i] = header
+	}
+	table.Header(headers...)
+
+	if err := table.Bulk(rows); err != nil {
+		return err
+	}
+	return table.Render()
+}
+
+// fitColumns returns column widths whose rendered table fits in width.
+// The widest column is narrowed first; headers are never truncated.
+func fitColumns(headers []string, rows [][]string, width int) []int {
+	widths := make([]int, len(headers))
+	floors := make([]int, len(headers))
+	for col, header := range headers {
+		floors[col] = max(twwidth.Width(header), minColumnWidth)
+		widths[col] = twwidth.Width(header)
+	}
+	for _, row := range rows {
+		for col, cell := range row {
+			widths[col] = max(widths[col], cellWidth(cell))
+		}
+	}
+
+	// Each column is padded by a space on both sides and followed by a border
+	budget := width - 1 - 3*len(headers)
+	for total(widths) > budget {
+		widest := 0
+		for col := range widths {
+			if widths[col]-floors[col] > widths[widest]-floors[widest] {
+				widest = col
+			}
+		}
+		if widths[widest] <= floors[widest] {
+			break
+		}
+		widths[widest]--
+	}
+	return widths
+}
+
+// truncateCell shortens each line of cell to width, marking cuts with an ellipsis
+func truncateCell(cell string, width int) string {
+	lines := strings.Split(cell, "\n")
+	for i, line := range lines {
+		if twwidth.Width(line) <= width {
+			continue
+		}
+		var b strings.Builder
+		used := twwidth.Width(Ellipsis)
+		for _, r := range line {
+			w := twwidth.Width(string(r))
+			if used+w > width {
+				break
+			}
+			b.WriteRune(r)
+			used += w
+		}
+		lines[i] = b.String() + Ellipsis
+	}
+	return strings.Join(lines, "\n")
+}
+
+// cellWidth returns the width of the widest line in cell
+func cellWidth(cell string) int {
+	width := 0
+	for line := range strings.SplitSeq(cell, "\n") {
+		width = max(width, twwidth.Width(line))
+	}
+	return width
+}
+
+func total(widths []int) int {
+	sum := 0
+	for _, w := range widths {
+		sum += w
+	}
+	return sum
+}
@@ -0,0 +1,98 @@
+package ui
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func renderTable(t *testing.T, opts TableOptions, rows [][]string) string {
+	t.Helper()
+	table := NewTable(opts, "COOKBOOK", "VERSION", "SOURCE")
+	for _, row := range rows {
+		table.Append(row...)
+	}
+	var buf bytes.Buffer
+	if err := table.Render(&buf); err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	return buf.String()
+}
+
+var tableRows = [][]string{
+	{"nginx", "2.7.6", "https://supermarket.chef.io"},
+	{"apt", "7.4.0", "https://supermarket.chef.io"},
+	{"apt", "7.3.0", "https://supermarket.example.com"},
+}
+
+func TestTable_Sorted(t *testing.T) {
+	got := renderTable(t, TableOptions{}, tableRows)
+	want := `┌──────────┬─────────┬─────────────────────────────────┐
+│ COOKBOOK │ VERSION │             SOURCE              │
+├──────────┼─────────┼─────────────────────────────────┤
+│ apt      │ 7.3.0   │ https://supermarket.example.com │
+│ apt      │ 7.4.0   │ https://supermarket.chef.io     │
+│ nginx    │ 2.7.6   │ https://supermarket.chef.io     │
+└──────────┴─────────┴─────────────────────────────────┘
+`
+	if got != want {
+		t.Errorf("Render() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestTable_Unsorted(t *testing.T) {
+	got := renderTable(t, TableOptions{Unsorted: true}, tableRows)
+	if strings.Index(got, "nginx") > strings.Index(got, "apt") {
+		t.Errorf("Unsorted table reordered rows:\n%s", got)
+	}
+}
+
+func TestTable_Truncate(t *testing.T) {
+	got := renderTable(t, TableOptions{Width: 40}, tableRows)
+	for _, line := range strings.Split(strings.TrimSuffix(got, "\n"), "\n") {
+		if width := len([]rune(line)); width > 40 {
+			t.Errorf("line is %d columns wide, want at most 40: %q", width, line)
+		}
+	}
+	if !strings.Contains(got, "https://superm"+Ellipsis) {
+		t.Errorf("expected the SOURCE column to be truncated:\n%s", got)
+	}
+	if !strings.Contains(got, "│ nginx    │ 2.7.6   │") {
+		t.Errorf("narrow columns should not be truncated:\n%s", got)
+	}
+
+	wide := renderTable(t, TableOptions{Width: 40, Wide: true}, tableRows)
+	if wide != renderTable(t, TableOptions{}, tableRows) {
+		t.Errorf("Wide table was truncated:\n%s", wide)
+	}
+}
+
+func TestTable_LocaleIndependent(t *testing.T) {
+	rows := [][]string{{"café", "±1.0", "→ new"}}
+
+	t.Setenv("LC_ALL", "C")
+	want := renderTable(t, TableOptions{}, rows)
+
+	t.Setenv("LC_ALL", "ja_JP.UTF-8")
+	if got := renderTable(t, TableOptions{}, rows); got != want {
+		t.Errorf("output changed with the locale:\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestTruncateCell(t *testing.T) {
+	tests := []struct {
+		cell  string
+		width int
+		want  string
+	}{
+		{"short", 10, "short"},
+		{"exactly10!", 10, "exactly10!"},
+		{"much too long", 8, "much to" + Ellipsis},
+		{"first line\nok", 6, "first" + Ellipsis + "\nok"},
+	}
+	for _, tt := range tests {
+		if got := truncateCell(tt.cell, tt.width); got != tt.want {
+			t.Errorf("truncateCell(%q, %d) = %q, want %q", tt.cell, tt.width, got, tt.want)
+		}
+	}
+}
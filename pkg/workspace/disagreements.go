@@ -0,0 +1,135 @@
+package workspace
+
+import (
+	"sort"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+)
+
+// Requirement is a constraint one project places on a cookbook
+type Requirement struct {
+	Project    string `json:"project"`
+	Constraint string `json:"constraint"`
+	// From is the cookbook declaring the dependency, or empty for the Berksfile
+	From string `json:"from,omitempty"`
+}
+
+// Disagreement is a cookbook the projects of a workspace do not agree on
+type Disagreement struct {
+	Cookbook string `json:"cookbook"`
+	// Locked maps each locked version to the projects that locked it
+	Locked map[string][]string `json:"locked"`
+	// Requirements are the constraints the projects place on the cookbook
+	Requirements []Requirement `json:"requirements"`
+	// Conflict is set when no known version satisfies every requirement, so
+	// the projects cannot be moved to a single version
+	Conflict bool `json:"conflict"`
+}
+
+// Disagreements compares the lock files of the installed projects, and
+// returns the cookbooks locked at different versions, sorted by name.
+// Versions are checked against the versions the universe saw to tell
+// whether the projects could agree.
+func Disagreements(projects []*Project, universe *Universe) []Disagreement {
+	locked := make(map[string]map[string][]string)
+	requirements := make(map[string][]Requirement)
+	require := func(name string, req Requirement) {
+		if req.Constraint == "" || req.Constraint == ">= 0.0.0" {
+			return
+		}
+		for _, existing := range requirements[name] {
+			if existing == req {
+				return
+			}
+		}
+		requirements[name] = append(requirements[name], req)
+	}
+
+	for _, project := range projects {
+		if project.LockFile == nil {
+			continue
+		}
+		for name, cookbook := range project.LockFile.ListCookbooks() {
+			if locked[name] == nil {
+				locked[name] = make(map[string][]string)
+			}
+			locked[name][cookbook.Version] = append(locked[name][cookbook.Version], project.Name)
+			for dep, constraint := range cookbook.Dependencies {
+				require(dep, Requirement{Project: project.Name, Constraint: constraint, From: name})
+			}
+		}
+		if project.Berksfile != nil {
+			for _, cookbook := range project.Berksfile.Cookbooks {
+				if cookbook.Constraint != nil {
+					require(cookbook.Name, Requirement{Project: project.Name, Constraint: cookbook.Constraint.String()})
+				}
+			}
+		}
+	}
+
+	var disagreements []Disagreement
+	for name, versions := range locked {
+		if len(versions) < 2 {
+			continue
+		}
+		for _, projects := range versions {
+			sort.Strings(projects)
+		}
+		reqs := requirements[name]
+		sort.Slice(reqs, func(i, j int) bool {
+			if reqs[i].Project != reqs[j].Project {
+				return reqs[i].Project < reqs[j].Project
+			}
+			return reqs[i].From < reqs[j].From
+		})
+		disagreements = append(disagreements, Disagreement{
+			Cookbook:     name,
+			Locked:       versions,
+			Requirements: reqs,
+			Conflict:     !satisfiable(reqs, candidates(name, versions, universe)),
+		})
+	}
+
+	sort.Slice(disagreements, func(i, j int) bool { return disagreements[i].Cookbook < disagreements[j].Cookbook })
+	return disagreements
+}
+
+// candidates returns the known versions of a cookbook: those the universe
+// saw and those the projects locked
+func candidates(name string, locked map[string][]string, universe *Universe) []*berkshelf.Version {
+	var versions []*berkshelf.Version
+	if universe != nil {
+		versions = universe.Versions(name)
+	}
+	for v := range locked {
+		if version, err := berkshelf.NewVersion(v); err == nil {
+			versions = append(versions, version)
+		}
+	}
+	return versions
+}
+
+// satisfiable reports whether any of versions meets every requirement.
+// Requirements that do not parse are ignored.
+func satisfiable(reqs []Requirement, versions []*berkshelf.Version) bool {
+	var constraints []*berkshelf.Constraint
+	for _, req := range reqs {
+		if constraint, err := berkshelf.NewConstraint(req.Constraint); err == nil {
+			constraints = append(constraints, constraint)
+		}
+	}
+
+	for _, version := range versions {
+		ok := true
+		for _, constraint := range constraints {
+			if !constraint.Check(version) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,174 @@
+package workspace
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	"github.com/bdwyertech/go-berkshelf/pkg/source"
+)
+
+// Universe is the set of cookbook versions shared by every project in a
+// workspace. Projects resolving concurrently ask each remote source for a
+// cookbook's versions and metadata once; later and concurrent lookups are
+// answered from the first.
+type Universe struct {
+	mu       sync.Mutex
+	calls    map[string]*call
+	versions map[string]map[string]*berkshelf.Version
+}
+
+// call is a lookup that is in flight or done
+type call struct {
+	done  chan struct{}
+	value any
+	err   error
+}
+
+// NewUniverse returns an empty universe
+func NewUniverse() *Universe {
+	return &Universe{
+		calls:    make(map[string]*call),
+		versions: make(map[string]map[string]*berkshelf.Version),
+	}
+}
+
+// do runs fn once per key, and returns its result to every caller
+func (u *Universe) do(key string, fn func() (any, error)) (any, error) {
+	u.mu.Lock()
+	if c, ok := u.calls[key]; ok {
+		u.mu.Unlock()
+		<-c.done
+		return c.value, c.err
+	}
+	c := &call{done: make(chan struct{})}
+	u.calls[key] = c
+	u.mu.Unlock()
+
+	c.value, c.err = fn()
+	if c.err != nil {
+		// Let the next caller retry rather than sharing a transient failure
+		u.mu.Lock()
+		delete(u.calls, key)
+		u.mu.Unlock()
+	}
+	close(c.done)
+	return c.value, c.err
+}
+
+// record adds versions of a cookbook to the universe
+func (u *Universe) record(name string, versions []*berkshelf.Version) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	known := u.versions[name]
+	if known == nil {
+		known = make(map[string]*berkshelf.Version)
+		u.versions[name] = known
+	}
+	for _, version := range versions {
+		known[version.String()] = version
+	}
+}
+
+// Versions returns every version of a cookbook any source reported, oldest first
+func (u *Universe) Versions(name string) []*berkshelf.Version {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	versions := make([]*berkshelf.Version, 0, len(u.versions[name]))
+	for _, version := range u.versions[name] {
+		versions = append(versions, version)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].LessThan(versions[j]) })
+	return versions
+}
+
+// Wrap returns src with its lookups shared through the universe. Only
+// remote API sources are shared; git and path sources are returned
+// unchanged, as they differ by ref and by project.
+func (u *Universe) Wrap(src source.CookbookSource) source.CookbookSource {
+	switch src.GetSourceType() {
+	case "supermarket", "chef_server":
+		return &sharedSource{CookbookSource: src, universe: u}
+	default:
+		return src
+	}
+}
+
+// WrapAll wraps every source in sources
+func (u *Universe) WrapAll(sources []source.CookbookSource) []source.CookbookSource {
+	wrapped := make([]source.CookbookSource, len(sources))
+	for i, src := range sources {
+		wrapped[i] = u.Wrap(src)
+	}
+	return wrapped
+}
+
+// sharedSource answers lookups from a Universe
+type sharedSource struct {
+	source.CookbookSource
+	universe *Universe
+}
+
+// ListVersions returns the versions of a cookbook, querying the wrapped
+// source once per workspace
+func (s *sharedSource) ListVersions(ctx context.Context, name string) ([]*berkshelf.Version, error) {
+	value, err := s.universe.do("versions\x00"+s.GetSourceURL()+"\x00"+name, func() (any, error) {
+		versions, err := s.CookbookSource.ListVersions(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		s.universe.record(name, versions)
+		return versions, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.([]*berkshelf.Version), nil
+}
+
+// FetchMetadata returns the metadata of a cookbook version, querying the
+// wrapped source once per workspace
+func (s *sharedSource) FetchMetadata(ctx context.Context, name string, version *berkshelf.Version) (*berkshelf.Metadata, error) {
+	value, err := s.universe.do("metadata\x00"+s.GetSourceURL()+"\x00"+name+"\x00"+version.String(), func() (any, error) {
+		return s.CookbookSource.FetchMetadata(ctx, name, version)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.(*berkshelf.Metadata), nil
+}
+
+// FetchCookbook returns a cookbook version, querying the wrapped source once
+// per workspace
+func (s *sharedSource) FetchCookbook(ctx context.Context, name string, version *berkshelf.Version) (*berkshelf.Cookbook, error) {
+	value, err := s.universe.do("cookbook\x00"+s.GetSourceURL()+"\x00"+name+"\x00"+version.String(), func() (any, error) {
+		return s.CookbookSource.FetchCookbook(ctx, name, version)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.(*berkshelf.Cookbook), nil
+}
+
+// Unwrap returns the wrapped source, so source.As finds its optional
+// interfaces
+func (s *sharedSource) Unwrap() source.CookbookSource {
+	return s.CookbookSource
+}
+
+// Deprecation passes through to the wrapped source, so wrapping does not hide deprecations
+func (s *sharedSource) Deprecation(ctx context.Context, name string) (*source.Deprecation, error) {
+	if deprecations, ok := s.CookbookSource.(source.DeprecationSource); ok {
+		return deprecations.Deprecation(ctx, name)
+	}
+	return nil, nil
+}
+
+// Repository passes through to the wrapped source
+func (s *sharedSource) Repository(ctx context.Context, name string) (string, error) {
+	if repositories, ok := s.CookbookSource.(source.RepositorySource); ok {
+		return repositories.Repository(ctx, name)
+	}
+	return "", nil
+}
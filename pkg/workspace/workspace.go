@@ -0,0 +1,98 @@
+// Package workspace discovers the Berksfiles in a repository of cookbooks so
+// they can be resolved together, and compares what each project locked.
+package workspace
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berksfile"
+	"github.com/bdwyertech/go-berkshelf/pkg/lockfile"
+)
+
+// skipDirs are directories that never hold projects of their own, such as
+// vendored cookbooks and Test Kitchen state
+var skipDirs = map[string]bool{
+	"berks-cookbooks": true,
+	"vendor":          true,
+	"node_modules":    true,
+	"fixtures":        true,
+}
+
+// Project is one Berksfile in the workspace
+type Project struct {
+	// Dir is the directory holding the Berksfile
+	Dir string
+	// Name is Dir relative to the workspace root
+	Name string
+	// Berksfile is the parsed Berksfile, set by Load
+	Berksfile *berksfile.Berksfile
+	// LockFile is what the project locked, set once it is installed
+	LockFile *lockfile.LockFile
+	// Skipped is set when the lock file was already up to date
+	Skipped bool
+	// Err is why the project could not be loaded or installed
+	Err error
+}
+
+// Discover returns the projects under root, sorted by name. Hidden
+// directories, vendored cookbooks, and directories matching an exclude
+// pattern (relative to root, e.g. "legacy/*") are not searched.
+func Discover(root string, exclude []string) ([]*Project, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var projects []*Project
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if p != root && (strings.HasPrefix(d.Name(), ".") || skipDirs[d.Name()] || excluded(rel, exclude)) {
+			return filepath.SkipDir
+		}
+
+		if _, err := os.Stat(filepath.Join(p, "Berksfile")); err == nil {
+			projects = append(projects, &Project{Dir: p, Name: rel})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(projects, func(i, j int) bool { return projects[i].Name < projects[j].Name })
+	return projects, nil
+}
+
+func excluded(rel string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(strings.TrimSuffix(pattern, "/"), rel); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Load parses the Berksfile of each project, recording failures on the
+// project. Berksfiles are parsed one at a time, as the parser keeps global
+// state.
+func Load(projects []*Project) {
+	for _, project := range projects {
+		project.Berksfile, project.Err = berksfile.Load(filepath.Join(project.Dir, "Berksfile"))
+	}
+}
@@ -0,0 +1,143 @@
+package workspace
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/bdwyertech/go-berkshelf/pkg/berksfile"
+	"github.com/bdwyertech/go-berkshelf/pkg/berkshelf"
+	"github.com/bdwyertech/go-berkshelf/pkg/lockfile"
+	"github.com/bdwyertech/go-berkshelf/pkg/source"
+)
+
+func TestDiscover(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{".", "apps/web", "apps/db", "legacy/old", ".kitchen/x", "apps/web/berks-cookbooks/nginx", "vendor/y"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, dir, "Berksfile"), []byte("source 'https://supermarket.chef.io'\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	projects, err := Discover(root, []string{"legacy/*"})
+	if err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+	var names []string
+	for _, project := range projects {
+		names = append(names, project.Name)
+	}
+	if want := []string{".", "apps/db", "apps/web"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Discover() = %v, want %v", names, want)
+	}
+
+	Load(projects)
+	for _, project := range projects {
+		if project.Err != nil || project.Berksfile == nil {
+			t.Errorf("Load(%s) = %v", project.Name, project.Err)
+		}
+	}
+}
+
+// countingSource counts the lookups that reach it
+type countingSource struct {
+	source.CookbookSource
+	lists atomic.Int32
+}
+
+func (s *countingSource) GetSourceType() string { return "supermarket" }
+func (s *countingSource) GetSourceURL() string  { return "https://supermarket.example.com" }
+
+func (s *countingSource) ListVersions(ctx context.Context, name string) ([]*berkshelf.Version, error) {
+	s.lists.Add(1)
+	return []*berkshelf.Version{berkshelf.MustVersion("1.0.0"), berkshelf.MustVersion("2.0.0")}, nil
+}
+
+func TestUniverseSharesLookups(t *testing.T) {
+	universe := NewUniverse()
+	src := &countingSource{}
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Each project wraps its own sources
+			versions, err := universe.Wrap(src).ListVersions(context.Background(), "nginx")
+			if err != nil || len(versions) != 2 {
+				t.Errorf("ListVersions() = %v, %v", versions, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := src.lists.Load(); got != 1 {
+		t.Errorf("source queried %d times, want 1", got)
+	}
+	if got := universe.Versions("nginx"); len(got) != 2 || got[0].String() != "1.0.0" {
+		t.Errorf("Versions() = %v", got)
+	}
+}
+
+func lockOf(cookbooks map[string]*lockfile.CookbookLock) *lockfile.LockFile {
+	return &lockfile.LockFile{Sources: map[string]*lockfile.SourceLock{
+		"https://supermarket.chef.io": {Cookbooks: cookbooks},
+	}}
+}
+
+func TestDisagreements(t *testing.T) {
+	universe := NewUniverse()
+	universe.record("apt", []*berkshelf.Version{berkshelf.MustVersion("7.0.0"), berkshelf.MustVersion("7.5.0")})
+
+	projects := []*Project{
+		{
+			Name: "web",
+			Berksfile: &berksfile.Berksfile{Cookbooks: []*berksfile.CookbookDef{
+				{Name: "nginx", Constraint: berkshelf.MustConstraint("~> 12.0")},
+			}},
+			LockFile: lockOf(map[string]*lockfile.CookbookLock{
+				"nginx": {Version: "12.1.0", Dependencies: map[string]string{"apt": ">= 7.0"}},
+				"apt":   {Version: "7.5.0"},
+				"yum":   {Version: "5.0.0"},
+			}),
+		},
+		{
+			Name: "db",
+			Berksfile: &berksfile.Berksfile{Cookbooks: []*berksfile.CookbookDef{
+				{Name: "nginx", Constraint: berkshelf.MustConstraint("< 12.0")},
+			}},
+			LockFile: lockOf(map[string]*lockfile.CookbookLock{
+				"nginx": {Version: "11.0.0", Dependencies: map[string]string{"apt": "< 7.5"}},
+				"apt":   {Version: "7.0.0"},
+				"yum":   {Version: "5.0.0"},
+			}),
+		},
+		{Name: "broken"},
+	}
+
+	got := Disagreements(projects, universe)
+	if len(got) != 2 {
+		t.Fatalf("Disagreements() = %+v, want apt and nginx", got)
+	}
+
+	apt, nginx := got[0], got[1]
+	if apt.Cookbook != "apt" || apt.Conflict {
+		t.Errorf("apt = %+v, want an agreeable disagreement (7.0.0 fits both)", apt)
+	}
+	if !reflect.DeepEqual(apt.Locked, map[string][]string{"7.5.0": {"web"}, "7.0.0": {"db"}}) {
+		t.Errorf("apt.Locked = %v", apt.Locked)
+	}
+	if nginx.Cookbook != "nginx" || !nginx.Conflict {
+		t.Errorf("nginx = %+v, want a conflict", nginx)
+	}
+	if want := []Requirement{{Project: "db", Constraint: "< 12.0.0"}, {Project: "web", Constraint: "~> 12.0"}}; !reflect.DeepEqual(nginx.Requirements, want) {
+		t.Errorf("nginx.Requirements = %+v, want %+v", nginx.Requirements, want)
+	}
+}